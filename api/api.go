@@ -0,0 +1,12 @@
+// Package api holds the OpenAPI description of the HTTP API and, under
+// names/v1, the protobuf definition of the gRPC API with its generated code.
+package api
+
+//go:generate buf generate
+
+import _ "embed"
+
+// OpenAPI is hand-written; update it whenever a route or payload changes.
+//
+//go:embed openapi.json
+var OpenAPI []byte
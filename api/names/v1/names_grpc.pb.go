@@ -0,0 +1,280 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: names/v1/names.proto
+
+package namesv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+	emptypb "google.golang.org/protobuf/types/known/emptypb"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	NameService_CreateName_FullMethodName = "/names.v1.NameService/CreateName"
+	NameService_GetName_FullMethodName    = "/names.v1.NameService/GetName"
+	NameService_ListNames_FullMethodName  = "/names.v1.NameService/ListNames"
+	NameService_UpdateName_FullMethodName = "/names.v1.NameService/UpdateName"
+	NameService_DeleteName_FullMethodName = "/names.v1.NameService/DeleteName"
+)
+
+// NameServiceClient is the client API for NameService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// NameService is the gRPC counterpart of the /names HTTP resource. Both are
+// served from the same store and share its validation rules.
+type NameServiceClient interface {
+	CreateName(ctx context.Context, in *CreateNameRequest, opts ...grpc.CallOption) (*Name, error)
+	GetName(ctx context.Context, in *GetNameRequest, opts ...grpc.CallOption) (*Name, error)
+	ListNames(ctx context.Context, in *ListNamesRequest, opts ...grpc.CallOption) (*ListNamesResponse, error)
+	UpdateName(ctx context.Context, in *UpdateNameRequest, opts ...grpc.CallOption) (*Name, error)
+	DeleteName(ctx context.Context, in *DeleteNameRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+}
+
+type nameServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewNameServiceClient(cc grpc.ClientConnInterface) NameServiceClient {
+	return &nameServiceClient{cc}
+}
+
+func (c *nameServiceClient) CreateName(ctx context.Context, in *CreateNameRequest, opts ...grpc.CallOption) (*Name, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Name)
+	err := c.cc.Invoke(ctx, NameService_CreateName_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *nameServiceClient) GetName(ctx context.Context, in *GetNameRequest, opts ...grpc.CallOption) (*Name, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Name)
+	err := c.cc.Invoke(ctx, NameService_GetName_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *nameServiceClient) ListNames(ctx context.Context, in *ListNamesRequest, opts ...grpc.CallOption) (*ListNamesResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListNamesResponse)
+	err := c.cc.Invoke(ctx, NameService_ListNames_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *nameServiceClient) UpdateName(ctx context.Context, in *UpdateNameRequest, opts ...grpc.CallOption) (*Name, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Name)
+	err := c.cc.Invoke(ctx, NameService_UpdateName_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *nameServiceClient) DeleteName(ctx context.Context, in *DeleteNameRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, NameService_DeleteName_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// NameServiceServer is the server API for NameService service.
+// All implementations must embed UnimplementedNameServiceServer
+// for forward compatibility.
+//
+// NameService is the gRPC counterpart of the /names HTTP resource. Both are
+// served from the same store and share its validation rules.
+type NameServiceServer interface {
+	CreateName(context.Context, *CreateNameRequest) (*Name, error)
+	GetName(context.Context, *GetNameRequest) (*Name, error)
+	ListNames(context.Context, *ListNamesRequest) (*ListNamesResponse, error)
+	UpdateName(context.Context, *UpdateNameRequest) (*Name, error)
+	DeleteName(context.Context, *DeleteNameRequest) (*emptypb.Empty, error)
+	mustEmbedUnimplementedNameServiceServer()
+}
+
+// UnimplementedNameServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedNameServiceServer struct{}
+
+func (UnimplementedNameServiceServer) CreateName(context.Context, *CreateNameRequest) (*Name, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateName not implemented")
+}
+func (UnimplementedNameServiceServer) GetName(context.Context, *GetNameRequest) (*Name, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetName not implemented")
+}
+func (UnimplementedNameServiceServer) ListNames(context.Context, *ListNamesRequest) (*ListNamesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListNames not implemented")
+}
+func (UnimplementedNameServiceServer) UpdateName(context.Context, *UpdateNameRequest) (*Name, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateName not implemented")
+}
+func (UnimplementedNameServiceServer) DeleteName(context.Context, *DeleteNameRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteName not implemented")
+}
+func (UnimplementedNameServiceServer) mustEmbedUnimplementedNameServiceServer() {}
+func (UnimplementedNameServiceServer) testEmbeddedByValue()                     {}
+
+// UnsafeNameServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to NameServiceServer will
+// result in compilation errors.
+type UnsafeNameServiceServer interface {
+	mustEmbedUnimplementedNameServiceServer()
+}
+
+func RegisterNameServiceServer(s grpc.ServiceRegistrar, srv NameServiceServer) {
+	// If the following call pancis, it indicates UnimplementedNameServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&NameService_ServiceDesc, srv)
+}
+
+func _NameService_CreateName_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateNameRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NameServiceServer).CreateName(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: NameService_CreateName_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NameServiceServer).CreateName(ctx, req.(*CreateNameRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _NameService_GetName_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetNameRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NameServiceServer).GetName(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: NameService_GetName_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NameServiceServer).GetName(ctx, req.(*GetNameRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _NameService_ListNames_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListNamesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NameServiceServer).ListNames(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: NameService_ListNames_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NameServiceServer).ListNames(ctx, req.(*ListNamesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _NameService_UpdateName_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateNameRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NameServiceServer).UpdateName(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: NameService_UpdateName_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NameServiceServer).UpdateName(ctx, req.(*UpdateNameRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _NameService_DeleteName_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteNameRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NameServiceServer).DeleteName(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: NameService_DeleteName_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NameServiceServer).DeleteName(ctx, req.(*DeleteNameRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// NameService_ServiceDesc is the grpc.ServiceDesc for NameService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var NameService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "names.v1.NameService",
+	HandlerType: (*NameServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "CreateName",
+			Handler:    _NameService_CreateName_Handler,
+		},
+		{
+			MethodName: "GetName",
+			Handler:    _NameService_GetName_Handler,
+		},
+		{
+			MethodName: "ListNames",
+			Handler:    _NameService_ListNames_Handler,
+		},
+		{
+			MethodName: "UpdateName",
+			Handler:    _NameService_UpdateName_Handler,
+		},
+		{
+			MethodName: "DeleteName",
+			Handler:    _NameService_DeleteName_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "names/v1/names.proto",
+}
@@ -0,0 +1,422 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"go.mongodb.org/mongo-driver/event"
+
+	"app/internal/audit"
+	"app/internal/bus"
+	"app/internal/cache"
+	"app/internal/changes"
+	"app/internal/chaos"
+	"app/internal/config"
+	"app/internal/handlers"
+	"app/internal/history"
+	"app/internal/ids"
+	"app/internal/metrics"
+	"app/internal/normalize"
+	"app/internal/notes"
+	"app/internal/outbox"
+	"app/internal/owner"
+	"app/internal/prewrite"
+	"app/internal/resource"
+	"app/internal/retry"
+	"app/internal/revision"
+	"app/internal/shadow"
+	"app/internal/store"
+	"app/internal/tracing"
+	"app/internal/usage"
+	"app/internal/webhook"
+)
+
+// backend is the storage the server runs on, chosen with STORE.
+type backend struct {
+	names  store.NameStore
+	users  store.UserStore
+	idem   store.IdempotencyStore
+	keys   store.APIKeyStore
+	audit  store.AuditStore
+	hist   store.HistoryStore
+	notes  store.NoteStore
+	stats  store.StatsStore      // the names store, undecorated
+	dups   store.DuplicateStore  // the same, for GET /names/duplicates
+	sample store.SampleStore     // the same, for GET /names/random
+	byKey  store.NameKeyStore    // the same, for finding names by UUID or slug
+	due    store.ExpiryStore     // the same, for the cleanup
+	forms  store.NormalizedStore // the same, for renormalizing
+	tx     store.Transactor      // the same, for transactions; nil without them
+	docs   store.DocStore
+	jobs   store.JobStore
+	hooks  store.WebhookStore
+	revs   store.RevisionStore
+	box    store.OutboxStore          // nil unless OUTBOX
+	sinks  []outbox.Sink              // what the outbox hands its events to
+	caps   store.CaptureStore         // recorded requests; nil without CAPTURE_PERCENT
+	usage  store.UsageStore           // nil unless USAGE
+	log    store.ChangeLogStore       // nil unless CHANGES
+	leases store.LeaseStore           // nil unless LEADER_ELECTION
+	shadow *shadow.Names              // nil unless SHADOW_STORE
+	mongo  *store.Mongo               // nil unless STORE=mongo
+	res    *resource.Registry         // the resources docs serves; none without RESOURCES_FILE
+	pool   handlers.PoolStatter       // nil if there is no connection pool
+	checks map[string]handlers.Pinger // what GET /readyz pings
+	close  func(context.Context) error
+}
+
+func openBackend(ctx context.Context, cfg *config.Config) (*backend, error) {
+	var res *resource.Registry
+	if cfg.ResourcesFile != "" {
+		var err error
+		if res, err = resource.Load(cfg.ResourcesFile); err != nil { return nil, err }
+		slog.Info("serving declared resources", "file", cfg.ResourcesFile, "collections", res.Collections())
+	}
+
+	if cfg.Store == "memory" {
+		slog.Warn("STORE=memory: data lives in this process only and is lost on restart")
+		names := store.NewMemoryNames()
+		return &backend{
+			names:  names,
+			stats:  names,
+			dups:   names,
+			sample: names,
+			byKey:  names,
+			due:    names,
+			forms:  names,
+			tx:     names,
+			users:  store.NewMemoryUsers(),
+			idem:   store.NewMemoryIdempotency(),
+			keys:   store.NewMemoryAPIKeys(),
+			audit:  store.NewMemoryAudit(),
+			hist:   store.NewMemoryHistory(),
+			notes:  store.NewMemoryNotes(names),
+			docs:   store.NewMemoryDocs(),
+			jobs:   store.NewMemoryJobs(),
+			hooks:  store.NewMemoryWebhooks(),
+			revs:   store.NewMemoryRevisions(),
+			box:    store.NewMemoryOutbox(),
+			usage:  store.NewMemoryUsage(),
+			log:    store.NewMemoryChangeLog(cfg.Changes.Retention),
+			leases: store.NewMemoryLeases(),
+			res:    res,
+			close:  func(context.Context) error { return nil },
+		}, nil
+	}
+
+	if cfg.Store == "sql" {
+		db, err := store.OpenSQL(ctx, cfg.DatabaseURL)
+		if err != nil { return nil, err }
+		slog.Info("opened SQL database", "url", config.RedactURI(cfg.DatabaseURL))
+		names := store.NewSQLNames(db)
+		return &backend{
+			names:  names,
+			stats:  names,
+			dups:   names,
+			sample: names,
+			byKey:  names,
+			due:    names,
+			forms:  names,
+			users:  store.NewSQLUsers(db),
+			idem:   store.NewSQLIdempotency(db),
+			keys:   store.NewSQLAPIKeys(db),
+			audit:  store.NewSQLAudit(db),
+			hist:   store.NewSQLHistory(db),
+			notes:  store.NewSQLNotes(db),
+			docs:   store.NewSQLDocs(db),
+			jobs:   store.NewSQLJobs(db),
+			hooks:  store.NewSQLWebhooks(db),
+			revs:   store.NewSQLRevisions(db),
+			res:    res,
+			checks: map[string]handlers.Pinger{"database": db},
+			close:  db.Close,
+		}, nil
+	}
+
+	db, err := store.Connect(ctx, store.MongoConfig{
+		URI:                    cfg.Mongo.URI,
+		Database:               cfg.Mongo.Database,
+		MaxPoolSize:            uint64(cfg.Mongo.MaxPoolSize),
+		MinPoolSize:            uint64(cfg.Mongo.MinPoolSize),
+		MaxConnIdleTime:        cfg.Mongo.MaxConnIdleTime,
+		ServerSelectionTimeout: cfg.Mongo.ServerSelectionTimeout,
+		ConnectRetry:           cfg.Mongo.ConnectRetry,
+		ReadPref:               cfg.Mongo.ReadPref,
+		ListReadPref:           cfg.Mongo.ListReadPref,
+		ListReadConcern:        cfg.Mongo.ListReadConcern,
+		WriteConcern:           cfg.Mongo.WriteConcern,
+		Collation:              store.Collation{Locale: cfg.Mongo.CollationLocale, Strength: cfg.Mongo.CollationStrength},
+		Monitors:               []*event.CommandMonitor{metrics.CommandMonitor(), tracing.CommandMonitor()},
+	})
+	if err != nil { return nil, err }
+	if err := migrateMongo(ctx, db, cfg); err != nil { _ = db.Disconnect(ctx); return nil, err }
+	b := &backend{res: res, pool: db, mongo: db, checks: map[string]handlers.Pinger{"mongo": db}, close: db.Disconnect}
+	if cfg.Migrate { return b, nil }
+	names, err := store.NewMongoNames(ctx, db, cfg.Mongo.Collection, cfg.Mongo.EventsCollection)
+	if err != nil { return nil, err }
+	b.names, b.stats, b.dups, b.sample, b.byKey, b.due, b.forms, b.tx = names, names, names, names, names, names, names, names
+	if b.idem, err = store.NewMongoIdempotency(ctx, db, cfg.Mongo.IdempotencyCollection); err != nil { return nil, err }
+	if b.users, err = store.NewMongoUsers(ctx, db, cfg.Mongo.UsersCollection); err != nil { return nil, err }
+	if b.keys, err = store.NewMongoAPIKeys(ctx, db, cfg.Mongo.APIKeysCollection); err != nil { return nil, err }
+	if b.audit, err = store.NewMongoAudit(ctx, db, cfg.Mongo.AuditCollection); err != nil { return nil, err }
+	if b.hist, err = store.NewMongoHistory(ctx, db, cfg.Mongo.HistoryCollection); err != nil { return nil, err }
+	if b.notes, err = store.NewMongoNotes(ctx, db, cfg.Mongo.NotesCollection, cfg.Mongo.Collection); err != nil { return nil, err }
+	if b.docs, err = store.NewMongoDocs(ctx, db, res.Collections()); err != nil { return nil, err }
+	if b.jobs, err = store.NewMongoJobs(ctx, db, cfg.Mongo.JobsCollection); err != nil { return nil, err }
+	if b.hooks, err = store.NewMongoWebhooks(ctx, db, cfg.Mongo.WebhooksCollection, cfg.Mongo.DeliveriesCollection); err != nil { return nil, err }
+	b.revs = store.NewMongoRevisions(db, cfg.Mongo.RevisionsCollection)
+	if cfg.Outbox.Enabled {
+		if b.box, err = store.NewMongoOutbox(ctx, db, cfg.Mongo.OutboxCollection); err != nil { return nil, err }
+	}
+	if cfg.Usage.Enabled {
+		if b.usage, err = store.NewMongoUsage(ctx, db, cfg.Mongo.UsageCollection); err != nil { return nil, err }
+	}
+	if cfg.Changes.Enabled {
+		if b.log, err = store.NewMongoChangeLog(ctx, db, cfg.Mongo.ChangesCollection, cfg.Changes.Retention); err != nil { return nil, err }
+	}
+	if cfg.Leader.Enabled { b.leases = store.NewMongoLeases(db, cfg.Mongo.LeasesCollection) }
+	slog.Info("connected to MongoDB", "uri", config.RedactURI(cfg.Mongo.URI), "db", cfg.Mongo.Database, "collection", cfg.Mongo.Collection)
+	return b, nil
+}
+
+// migrateMongo applies MongoDB's pending migrations, or with -migrate-to
+// undoes some, when MONGO_MIGRATIONS=auto or -migrate asks for it. Otherwise
+// it refuses to run on documents this build is newer than.
+func migrateMongo(ctx context.Context, db *store.Mongo, cfg *config.Config) error {
+	latest := store.LatestMongoMigration()
+	if !cfg.Migrate && cfg.Mongo.Migrations == "manual" {
+		v, err := db.MigrationVersion(ctx)
+		if err != nil { return err }
+		if v < latest { return fmt.Errorf("MongoDB is at migration %d of %d: run with -migrate first", v, latest) }
+		return nil
+	}
+	from, to, err := db.Migrate(ctx, store.MongoCollections{Names: cfg.Mongo.Collection, Events: cfg.Mongo.EventsCollection}, cfg.MigrateTo)
+	if err != nil { return fmt.Errorf("migrating MongoDB: %w", err) }
+	if from != to { slog.Info("migrated MongoDB", "from", from, "to", to) }
+	return nil
+}
+
+// useCaptures opens the CAPTURE_SINK the requests CAPTURE_PERCENT samples
+// are recorded in.
+func (b *backend) useCaptures(ctx context.Context, cfg *config.Config) error {
+	cp := cfg.Capture
+	if cp.Percent <= 0 { return nil }
+	switch cp.Sink {
+	case "mongo":
+		caps, err := store.NewMongoCaptures(ctx, b.mongo, cfg.Mongo.CapturesCollection, cp.CollectionBytes)
+		if err != nil { return err }
+		b.caps = caps
+	case "files":
+		caps, err := store.NewFileCaptures(cp.Dir, cp.FileBytes, cp.Files)
+		if err != nil { return err }
+		closeStore := b.close
+		b.close = func(ctx context.Context) error { return errors.Join(closeStore(ctx), caps.Close()) }
+		b.caps = caps
+	case "memory":
+		b.caps = store.NewMemoryCaptures(1000)
+	}
+	slog.Warn("recording requests and responses in full", "percent", cp.Percent, "sink", cp.Sink)
+	return nil
+}
+
+// useRetry retries the names store's operations after transient MongoDB
+// errors. It goes first, beneath the other layers, so that they see each
+// operation once.
+func (b *backend) useRetry(cfg *config.Config) {
+	if cfg.Store != "mongo" || cfg.Retry.MaxAttempts <= 1 { return }
+	b.names = retry.NewNames(b.names, retry.Policy{
+		MaxAttempts: cfg.Retry.MaxAttempts,
+		BaseDelay:   cfg.Retry.BaseDelay,
+		MaxDelay:    cfg.Retry.MaxDelay,
+		Retryable:   store.TransientMongo,
+		Unapplied:   store.UnappliedMongo,
+	})
+}
+
+// useShadow, with SHADOW_STORE, opens the second store every write to the
+// names is copied to. It goes right above the retries, so that the shadow
+// is asked for each write the primary made, and for nothing else.
+func (b *backend) useShadow(ctx context.Context, cfg *config.Config) error {
+	sh := cfg.Shadow
+	var names store.NameStore
+	switch sh.Store {
+	case "off":
+		return nil
+	case "sql":
+		db, err := store.OpenSQL(ctx, sh.DatabaseURL)
+		if err != nil { return fmt.Errorf("opening the shadow store: %w", err) }
+		names = store.NewSQLNames(db)
+		closeStore := b.close
+		b.close = func(ctx context.Context) error { return errors.Join(closeStore(ctx), db.Close(ctx)) }
+		slog.Info("copying name writes to a shadow SQL database", "url", config.RedactURI(sh.DatabaseURL))
+	case "mongo":
+		database := cmp.Or(sh.MongoDatabase, cfg.Mongo.Database)
+		db, err := store.Connect(ctx, store.MongoConfig{
+			URI:                    sh.MongoURI,
+			Database:               database,
+			MaxPoolSize:            uint64(cfg.Mongo.MaxPoolSize),
+			ServerSelectionTimeout: cfg.Mongo.ServerSelectionTimeout,
+			ConnectRetry:           cfg.Mongo.ConnectRetry,
+			WriteConcern:           cfg.Mongo.WriteConcern,
+			Collation:              store.Collation{Locale: cfg.Mongo.CollationLocale, Strength: cfg.Mongo.CollationStrength},
+			Monitors:               []*event.CommandMonitor{metrics.CommandMonitor(), tracing.CommandMonitor()},
+		})
+		if err != nil { return fmt.Errorf("connecting to the shadow store: %w", err) }
+		closeStore := b.close
+		b.close = func(ctx context.Context) error { return errors.Join(closeStore(ctx), db.Disconnect(ctx)) }
+		if _, _, err := db.Migrate(ctx, store.MongoCollections{Names: cfg.Mongo.Collection, Events: cfg.Mongo.EventsCollection}, -1); err != nil { return fmt.Errorf("migrating the shadow store: %w", err) }
+		if names, err = store.NewMongoNames(ctx, db, cfg.Mongo.Collection, cfg.Mongo.EventsCollection); err != nil { return err }
+		slog.Info("copying name writes to a shadow MongoDB", "uri", config.RedactURI(sh.MongoURI), "db", database)
+	}
+	b.shadow = shadow.NewNames(b.names, names, sh.Timeout)
+	b.names = b.shadow
+	return nil
+}
+
+// useChaos, outside production, lets requests' faults time out calls to the
+// names. It goes above the retries and the shadow, so that clients see the
+// timeouts and the shadow isn't asked to copy writes that never happened.
+func (b *backend) useChaos(cfg *config.Config) {
+	if cfg.Production() { return }
+	b.names = chaos.NewNames(b.names)
+}
+
+// useAudit records every write to the names store in the audit log, and
+// keeps the versions writes replace.
+func (b *backend) useAudit() { b.names = audit.NewNames(history.NewNames(b.names, b.hist), b.audit) }
+
+// useCache puts the CACHE read cache in front of the names store. The
+// memory store is left alone: it is already as fast as a cache.
+func (b *backend) useCache(ctx context.Context, cfg *config.Config) error {
+	if cfg.Cache.Backend == "off" || cfg.Store == "memory" { return nil }
+
+	var c cache.Cache = cache.NewMemory(cfg.Cache.MaxEntries)
+	if cfg.Cache.Backend == "redis" {
+		r, err := cache.NewRedis(ctx, cfg.Cache.RedisURL)
+		if err != nil { return err }
+		if b.checks == nil { b.checks = map[string]handlers.Pinger{} }
+		b.checks["redis"] = r
+		closeStore := b.close
+		b.close = func(ctx context.Context) error { return errors.Join(closeStore(ctx), r.Close(ctx)) }
+		c = r
+		slog.Info("caching name reads in Redis", "url", config.RedactURI(cfg.Cache.RedisURL), "ttl", cfg.Cache.TTL)
+	}
+	b.names = cache.NewNames(b.names, c, cfg.Cache.TTL)
+	return nil
+}
+
+// useRevisions counts the writes to the names store, for GET /names to
+// answer 304 by. It goes above the cache, which is invalidated first, and
+// beneath the notes, so that refused deletes aren't counted.
+func (b *backend) useRevisions() { b.names = revision.NewNames(b.names, b.revs) }
+
+// useNotes applies NOTES_ON_DELETE to hard deletes. It goes on top, so that
+// a refused delete costs the layers beneath nothing.
+func (b *backend) useNotes(cfg *config.Config) { b.names = notes.NewNames(b.names, b.notes, cfg.NotesOnDelete) }
+
+// useOwners stamps new names with their creator and lets only them or an
+// admin change them. It goes above every layer that records a write, so
+// those see who made the name and a refused write reaches none of them.
+func (b *backend) useOwners() { b.names = owner.NewNames(b.names, b.byKey) }
+
+// useWebhooks queues a delivery to the subscribed webhooks for every write
+// to the names store, or with OUTBOX for every event of the outbox, and
+// returns the dispatcher that makes them. It goes above the owners and the
+// notes, so that only writes that happened are told of. The layers put on
+// after it still hold to that: the bus, the outbox and the change log only
+// record a write, and the IDs, the normalizing and the prewrite hooks shape
+// or refuse the name on its way down, so a delivery has it as stored.
+func (b *backend) useWebhooks(cfg *config.Config) *webhook.Dispatcher {
+	d := webhook.New(b.hooks, webhook.Config{
+		Workers:     cfg.Webhooks.Workers,
+		Timeout:     cfg.Webhooks.Timeout,
+		Poll:        cfg.Webhooks.Poll,
+		MaxAttempts: cfg.Webhooks.MaxAttempts,
+		Backoff:     cfg.Webhooks.Backoff,
+		MaxBackoff:  cfg.Webhooks.MaxBackoff,
+		Retention:   cfg.Webhooks.Retention,
+	})
+	if cfg.Outbox.Enabled { b.sinks = append(b.sinks, d.EnqueueEvent); return d }
+	b.names = webhook.NewNames(b.names, d)
+	return d
+}
+
+// useIDs gives new names the key ID_STRATEGY says. It goes on top, so
+// that every layer beneath sees the name as it is stored.
+func (b *backend) useIDs(cfg *config.Config) { b.names = ids.NewNames(b.names, b.byKey, cfg.IDStrategy) }
+
+// useNormalize gives every name written its canonical form by the
+// NAME_* rules and matches lookups on it. Like useIDs it goes on top, so
+// that the layers beneath, the history and the change log among them,
+// see the form that is stored.
+func (b *backend) useNormalize(cfg *config.Config) *normalize.Names {
+	n := normalize.NewNames(b.names, b.forms, normalize.Rules{StripDiacritics: cfg.Normalize.StripDiacritics, LowerWords: config.Split(cfg.Normalize.LowerWords)})
+	b.names = n
+	return n
+}
+
+// usePrewrite, with PREWRITE_HOOKS_FILE, runs its hooks on every name
+// written. It goes on top of useNormalize and useIDs, so that the form and
+// the slug are those of the name as the hooks left it.
+func (b *backend) usePrewrite(cfg *config.Config) error {
+	if cfg.PrewriteFile == "" { return nil }
+	hooks, err := prewrite.Load(cfg.PrewriteFile)
+	if err != nil { return err }
+	b.names = prewrite.NewNames(b.names, hooks)
+	slog.Info("running prewrite hooks on names", "file", cfg.PrewriteFile, "hooks", hooks.Len())
+	return nil
+}
+
+// useBus publishes every write to the names store to the BUS message bus.
+// Like the webhooks, it goes on top of what decides whether a write happens.
+func (b *backend) useBus(ctx context.Context, cfg *config.Config) error {
+	if cfg.Bus.Kind == "off" { return nil }
+	pub, err := bus.Open(ctx, cfg.Bus.Kind, cfg.Bus.URL, cfg.Bus.Topic)
+	if err != nil { return err }
+	closeStore := b.close
+	b.close = func(ctx context.Context) error { return errors.Join(closeStore(ctx), pub.Close()) }
+	if cfg.Outbox.Enabled {
+		b.sinks = append(b.sinks, func(ctx context.Context, e store.OutboxEvent) error { return bus.PublishEvent(ctx, pub, cfg.Bus.Format, e) })
+	} else {
+		b.names = bus.NewNames(b.names, pub, cfg.Bus.Format)
+	}
+	slog.Info("publishing name changes", "bus", cfg.Bus.Kind, "url", config.RedactURI(cfg.Bus.URL), "topic", cfg.Bus.Topic, "format", cfg.Bus.Format)
+	return nil
+}
+
+// useOutbox, with OUTBOX, adds an event to the outbox in the transaction of
+// every write to the names store, and returns the dispatcher that hands
+// them to the webhooks and the bus; nil without. It goes where those would.
+func (b *backend) useOutbox(cfg *config.Config) *outbox.Dispatcher {
+	if !cfg.Outbox.Enabled { return nil }
+	d := outbox.New(b.box, outbox.Config{Poll: cfg.Outbox.Poll, Lease: cfg.Outbox.Lease, Retention: cfg.Outbox.Retention}, b.sinks...)
+	b.names = outbox.NewNames(b.names, b.tx, d)
+	return d
+}
+
+// useChanges, with CHANGES, appends a change to the log in the transaction
+// of every write to the names store, and returns the store that reads them
+// for GET /names/changes; nil without. Like the outbox, it goes above every
+// layer that writes.
+func (b *backend) useChanges(cfg *config.Config) *changes.Names {
+	if !cfg.Changes.Enabled { return nil }
+	c := changes.NewNames(b.names, b.tx, b.log, changes.Config{Retention: cfg.Changes.Retention, MaxWait: cfg.Changes.MaxWait, Poll: cfg.Changes.Poll})
+	b.names = c
+	return c
+}
+
+// useUsage, with USAGE, returns the tracker that counts what API keys use
+// and holds them to the QUOTA_* quotas; nil without.
+func (b *backend) useUsage(cfg *config.Config) *usage.Tracker {
+	if !cfg.Usage.Enabled { return nil }
+	u := cfg.Usage
+	return usage.New(b.usage, usage.Config{Flush: u.Flush, Quotas: usage.Quotas{
+		DailyRequests:   u.DailyRequests,
+		MonthlyRequests: u.MonthlyRequests,
+		DailyBytes:      u.DailyBytes,
+		MonthlyBytes:    u.MonthlyBytes,
+	}})
+}
@@ -0,0 +1,210 @@
+// Package client is a Go client for the names API, for services that would
+// otherwise hand-roll its HTTP calls.
+//
+//	c, err := client.New("https://names.example.com", client.WithToken(token))
+//	n, err := c.CreateName(ctx, client.NameInput{Name: "Alice"})
+//	if errors.Is(err, client.ErrDuplicateName) { ... }
+//
+// Requests that fail in a way worth retrying, such as a dropped connection,
+// a 503 or a 429, are retried with backoff. Every method is safe to retry:
+// reads and conditional writes by nature, and creates because each carries
+// an Idempotency-Key the server deduplicates on.
+package client
+
+import (
+	"bytes"
+	"context"
+	crand "crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// apiPrefix is the version of the API the client speaks.
+const apiPrefix = "/api/v1"
+
+// Client calls the API at one base URL. It is safe for concurrent use.
+type Client struct {
+	base      *url.URL
+	http      *http.Client
+	token     string // bearer token, or
+	apiKey    string // X-API-Key
+	userAgent string
+	retry     Retry
+}
+
+// Retry says how often and how patiently a Client retries.
+type Retry struct {
+	MaxAttempts int           // the first try included; 1 never retries
+	BaseDelay   time.Duration // wait before the first retry, doubled for each one after
+	MaxDelay    time.Duration // cap on the wait, and on a Retry-After the server asks for
+}
+
+var defaultRetry = Retry{MaxAttempts: 3, BaseDelay: 100 * time.Millisecond, MaxDelay: 5 * time.Second}
+
+type Option func(*Client)
+
+// WithHTTPClient sends requests through hc instead of http.DefaultClient.
+func WithHTTPClient(hc *http.Client) Option { return func(c *Client) { c.http = hc } }
+
+// WithToken authenticates as a user, with a token from POST /auth/login.
+func WithToken(token string) Option { return func(c *Client) { c.token = token } }
+
+// WithAPIKey authenticates with an API key from POST /apikeys.
+func WithAPIKey(key string) Option { return func(c *Client) { c.apiKey = key } }
+
+// WithUserAgent sets the User-Agent header, so the server's logs can tell
+// callers apart.
+func WithUserAgent(ua string) Option { return func(c *Client) { c.userAgent = ua } }
+
+// WithRetry replaces the default policy of three attempts, 100ms apart at
+// first, at most 5s.
+func WithRetry(r Retry) Option { return func(c *Client) { c.retry = r } }
+
+// New returns a Client for the API served at baseURL, e.g.
+// "https://names.example.com".
+func New(baseURL string, opts ...Option) (*Client, error) {
+	base, err := url.Parse(baseURL)
+	if err != nil { return nil, fmt.Errorf("client: base URL: %w", err) }
+	if base.Scheme != "http" && base.Scheme != "https" || base.Host == "" {
+		return nil, fmt.Errorf("client: base URL %q must be http(s)://host", baseURL)
+	}
+	base.Path = strings.TrimSuffix(base.Path, "/")
+	c := &Client{base: base, http: http.DefaultClient, userAgent: "names-go-client", retry: defaultRetry}
+	for _, opt := range opts { opt(c) }
+	if c.token != "" && c.apiKey != "" { return nil, errors.New("client: WithToken and WithAPIKey are mutually exclusive") }
+	return c, nil
+}
+
+// request is one API call.
+type request struct {
+	method, path string
+	query        url.Values
+	body         any // JSON-encoded if not nil
+	// upload is sent as is, with contentType, instead of body. It can only
+	// be read once, so the request isn't retried.
+	upload      io.Reader
+	contentType string
+	header      http.Header
+}
+
+// do sends req, retrying as the policy allows, and hands a successful
+// response to out: copied into it if it is an io.Writer, else decoded into
+// it unless it is nil. An error response comes back as an *Error.
+func (c *Client) do(ctx context.Context, req request, out any) error {
+	var body []byte
+	if req.body != nil {
+		var err error
+		if body, err = json.Marshal(req.body); err != nil { return fmt.Errorf("client: encoding request: %w", err) }
+		req.contentType = "application/json"
+	}
+	u := *c.base
+	u.Path += apiPrefix + req.path
+	u.RawQuery = req.query.Encode()
+	attempts := c.retry.MaxAttempts
+	if req.upload != nil { attempts = 1 }
+
+	for attempt := 1; ; attempt++ {
+		rd := req.upload
+		if body != nil { rd = bytes.NewReader(body) }
+		resp, err := c.send(ctx, req, u.String(), rd)
+		if err == nil && resp.StatusCode < 300 { return readResponse(resp, out) }
+		var wait time.Duration
+		if err == nil {
+			err = readError(resp)
+			wait = retryAfter(resp.Header)
+		}
+		if attempt >= attempts || !retryable(err) || ctx.Err() != nil { return err }
+
+		wait = max(wait, c.backoff(attempt))
+		if c.retry.MaxDelay > 0 { wait = min(wait, c.retry.MaxDelay) }
+		t := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return err
+		case <-t.C:
+		}
+	}
+}
+
+func (c *Client) send(ctx context.Context, req request, u string, body io.Reader) (*http.Response, error) {
+	r, err := http.NewRequestWithContext(ctx, req.method, u, body)
+	if err != nil { return nil, fmt.Errorf("client: %w", err) }
+	for k, v := range req.header { r.Header[k] = v }
+	// The client decodes bodies as they are, whether or not the server
+	// envelopes them by default.
+	if r.Header.Get("Accept") == "" { r.Header.Set("Accept", "application/json; envelope=false") }
+	if req.contentType != "" { r.Header.Set("Content-Type", req.contentType) }
+	if c.userAgent != "" { r.Header.Set("User-Agent", c.userAgent) }
+	switch {
+	case c.token != "":
+		r.Header.Set("Authorization", "Bearer "+c.token)
+	case c.apiKey != "":
+		r.Header.Set("X-API-Key", c.apiKey)
+	}
+	return c.http.Do(r)
+}
+
+func readResponse(resp *http.Response, out any) error {
+	defer resp.Body.Close()
+	switch out := out.(type) {
+	case nil:
+		return nil
+	case io.Writer:
+		if _, err := io.Copy(out, resp.Body); err != nil { return fmt.Errorf("client: reading response: %w", err) }
+		return nil
+	}
+	if resp.StatusCode == http.StatusNoContent { return nil }
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil { return fmt.Errorf("client: decoding response: %w", err) }
+	return nil
+}
+
+// backoff returns the wait before retry n (1 for the first): exponential,
+// capped, and with jitter so clients failing together don't retry together.
+func (c *Client) backoff(n int) time.Duration {
+	d := c.retry.BaseDelay
+	if shift := n - 1; shift < 32 { d <<= shift }
+	if c.retry.MaxDelay > 0 && (d > c.retry.MaxDelay || d < 0) { d = c.retry.MaxDelay }
+	if d <= 0 { return 0 }
+	return d/2 + rand.N(d/2+1)
+}
+
+// retryAfter reads a Retry-After header given in seconds.
+func retryAfter(h http.Header) time.Duration {
+	s, err := strconv.Atoi(h.Get("Retry-After"))
+	if err != nil || s < 0 { return 0 }
+	return time.Duration(s) * time.Second
+}
+
+// retryable tells whether a failed attempt is worth repeating: a transport
+// error other than the caller's context ending, or an answer the server
+// marks as temporary.
+func retryable(err error) bool {
+	var e *Error
+	if !errors.As(err, &e) { return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded) }
+	switch e.Code {
+	case CodeRateLimited, CodeTimeout, CodeOverloaded, CodeIdempotencyKeyInUse:
+		return true
+	case CodeAuthDisabled:
+		return false
+	}
+	// Proxies in front of the API answer these without a code.
+	return e.Status == http.StatusBadGateway || e.Status == http.StatusServiceUnavailable || e.Status == http.StatusGatewayTimeout
+}
+
+// newIdempotencyKey returns a random key for one logical create, kept
+// across its retries.
+func newIdempotencyKey() string {
+	b := make([]byte, 16)
+	_, _ = crand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,127 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"app/internal/auth"
+	"app/internal/handlers"
+	"app/internal/server"
+	"app/internal/store"
+	"app/internal/tenant"
+)
+
+// newServer serves the real API over memory stores and returns a client
+// signed in to it.
+func newServer(t *testing.T) *Client {
+	t.Helper()
+	tokens := auth.NewTokens([]byte("test-secret"), time.Hour)
+	keys, idem := store.NewMemoryAPIKeys(), store.NewMemoryIdempotency()
+	h := handlers.New(handlers.Deps{Names: store.NewMemoryNames(), Users: store.NewMemoryUsers(), APIKeys: keys, Tokens: tokens})
+	s := server.New(server.Config{MaxBodyBytes: 1 << 20, IdempotencyTTL: time.Hour}, h, tokens, idem, keys)
+	ts := httptest.NewServer(s.Handler())
+	t.Cleanup(ts.Close)
+
+	token, err := tokens.Issue(primitive.NewObjectID(), tenant.Default, auth.RoleEditor)
+	if err != nil { t.Fatal(err) }
+	c, err := New(ts.URL, WithToken(token))
+	if err != nil { t.Fatal(err) }
+	return c
+}
+
+func TestClient(t *testing.T) {
+	c, ctx := newServer(t), context.Background()
+
+	n, err := c.CreateName(ctx, NameInput{Name: "Alice", Tags: []string{"vip"}})
+	if err != nil { t.Fatal(err) }
+	if n.ID == "" || n.Version != 1 || n.CreatedAt.IsZero() { t.Fatalf("created %+v", n) }
+	if _, err := c.CreateName(ctx, NameInput{Name: "Alice"}); !errors.Is(err, ErrDuplicateName) { t.Fatalf("duplicate: %v", err) }
+
+	var e *Error
+	_, err = c.CreateName(ctx, NameInput{})
+	if !errors.As(err, &e) || e.Status != http.StatusUnprocessableEntity || len(e.Fields) == 0 || e.RequestID == "" { t.Fatalf("invalid: %#v", err) }
+
+	if got, err := c.GetName(ctx, n.ID); err != nil || got.Name != "Alice" { t.Fatalf("get: %+v, %v", got, err) }
+	if _, err := c.GetName(ctx, primitive.NewObjectID().Hex()); !errors.Is(err, ErrNotFound) { t.Fatalf("get unknown: %v", err) }
+
+	if _, err := c.UpdateName(ctx, n.ID, 7, NameInput{Name: "Bob"}); !errors.Is(err, ErrVersionMismatch) { t.Fatalf("stale update: %v", err) }
+	n, err = c.UpdateName(ctx, n.ID, n.Version, NameInput{Name: "Bob"})
+	if err != nil || n.Name != "Bob" || n.Version != 2 || len(n.Tags) != 0 { t.Fatalf("update: %+v, %v", n, err) }
+
+	if _, err := c.CreateName(ctx, NameInput{Name: "Carol"}); err != nil { t.Fatal(err) }
+	page, err := c.ListNames(ctx, ListOptions{Limit: 1, Sort: "-name"})
+	if err != nil || page.Total != 2 || len(page.Items) != 1 || page.Items[0].Name != "Carol" || page.Next == "" { t.Fatalf("list: %+v, %v", page, err) }
+	page, err = c.ListNames(ctx, ListOptions{Limit: 1, Sort: "-name", After: page.Next})
+	if err != nil || len(page.Items) != 1 || page.Items[0].Name != "Bob" { t.Fatalf("second page: %+v, %v", page, err) }
+
+	if err := c.DeleteName(ctx, n.ID, 1); !errors.Is(err, ErrVersionMismatch) { t.Fatalf("stale delete: %v", err) }
+	if err := c.DeleteName(ctx, n.ID, n.Version); err != nil { t.Fatal(err) }
+	if _, err := c.GetName(ctx, n.ID); !errors.Is(err, ErrNotFound) { t.Fatalf("get deleted: %v", err) }
+}
+
+func TestRetry(t *testing.T) {
+	var attempts int
+	var keys []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		keys = append(keys, r.Header.Get("Idempotency-Key"))
+		switch r.URL.Path {
+		case "/api/v1/names":
+			if attempts < 3 { handlers.WriteProblem(w, http.StatusServiceUnavailable, handlers.CodeTimeout, "", nil); return }
+			handlers.WriteJSON(w, http.StatusCreated, Name{ID: "1", Name: "Alice", Version: 1})
+		case "/api/v1/names/bad":
+			handlers.BadRequest(w, "invalid id")
+		default:
+			w.WriteHeader(http.StatusBadGateway) // no problem body
+		}
+	}))
+	defer ts.Close()
+	c, err := New(ts.URL, WithRetry(Retry{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond}))
+	if err != nil { t.Fatal(err) }
+	ctx := context.Background()
+
+	n, err := c.CreateName(ctx, NameInput{Name: "Alice"})
+	if err != nil || n.Name != "Alice" { t.Fatalf("create: %+v, %v", n, err) }
+	if attempts != 3 || keys[0] == "" || keys[1] != keys[0] || keys[2] != keys[0] { t.Fatalf("%d attempts, keys %q", attempts, keys) }
+
+	attempts = 0
+	if _, err := c.GetName(ctx, "bad"); err == nil || attempts != 1 { t.Fatalf("400 tried %d times: %v", attempts, err) }
+
+	attempts = 0
+	var e *Error
+	_, err = c.GetName(ctx, "gone")
+	if !errors.As(err, &e) || e.Status != http.StatusBadGateway || attempts != 3 { t.Fatalf("502 tried %d times: %#v", attempts, err) }
+
+	attempts = 0
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := c.GetName(cancelled, "x"); !errors.Is(err, context.Canceled) || attempts != 0 { t.Fatalf("canceled: %d attempts, %v", attempts, err) }
+}
+
+func TestCodesMatchServer(t *testing.T) {
+	for ours, theirs := range map[string]string{
+		CodeBadRequest: handlers.CodeBadRequest, CodeInvalidJSON: handlers.CodeInvalidJSON, CodeValidationFailed: handlers.CodeValidationFailed,
+		CodeUnauthorized: handlers.CodeUnauthorized, CodeForbidden: handlers.CodeForbidden, CodeNotFound: handlers.CodeNotFound,
+		CodeMethodNotAllowed: handlers.CodeMethodNotAllowed, CodeDuplicateName: handlers.CodeDuplicateName, CodeDuplicateUsername: handlers.CodeDuplicateUsername,
+		CodeIdempotencyKeyInUse: handlers.CodeIdempotencyKeyInUse, CodeResumeExpired: handlers.CodeResumeExpired, CodeVersionMismatch: handlers.CodeVersionMismatch,
+		CodePreconditionRequired: handlers.CodePreconditionRequired, CodeBodyTooLarge: handlers.CodeBodyTooLarge, CodeMalformedCSV: handlers.CodeMalformedCSV,
+		CodeLineTooLong: handlers.CodeLineTooLong, CodeRateLimited: handlers.CodeRateLimited, CodeInternal: handlers.CodeInternal,
+		CodeWatchUnsupported: handlers.CodeWatchUnsupported, CodeAuthDisabled: handlers.CodeAuthDisabled, CodeTimeout: handlers.CodeTimeout,
+		CodeRequestCanceled: handlers.CodeRequestCanceled,
+	} {
+		if ours != theirs { t.Errorf("client has %q where the server has %q", ours, theirs) }
+	}
+}
+
+func TestNew(t *testing.T) {
+	for _, u := range []string{"", "names.example.com", "ftp://names.example.com", "http://"} {
+		if _, err := New(u); err == nil { t.Errorf("New(%q) succeeded", u) }
+	}
+	if _, err := New("http://x", WithToken("t"), WithAPIKey("k")); err == nil { t.Error("token and key both accepted") }
+}
@@ -0,0 +1,89 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// Problem codes, as the server sends them in every error body. Branch on
+// these, or on the Err values below, rather than on status codes.
+const (
+	CodeBadRequest           = "bad_request"
+	CodeInvalidJSON          = "invalid_json"
+	CodeValidationFailed     = "validation_failed"
+	CodeUnauthorized         = "unauthorized"
+	CodeForbidden            = "forbidden"
+	CodeNotFound             = "not_found"
+	CodeMethodNotAllowed     = "method_not_allowed"
+	CodeDuplicateName        = "duplicate_name"
+	CodeDuplicateUsername    = "duplicate_username"
+	CodeIdempotencyKeyInUse  = "idempotency_key_in_use"
+	CodeResumeExpired        = "resume_expired"
+	CodeVersionMismatch      = "version_mismatch"
+	CodePreconditionRequired = "precondition_required"
+	CodeBodyTooLarge         = "body_too_large"
+	CodeMalformedCSV         = "malformed_csv"
+	CodeLineTooLong          = "line_too_long"
+	CodeRateLimited          = "rate_limited"
+	CodeInternal             = "internal"
+	CodeWatchUnsupported     = "watch_unsupported"
+	CodeAuthDisabled         = "auth_disabled"
+	CodeTimeout              = "timeout"
+	CodeRequestCanceled      = "request_canceled"
+	CodeOverloaded           = "overloaded"
+)
+
+// Errors to match with errors.Is; an *Error is each of them whose code it
+// has.
+var (
+	ErrValidationFailed = &Error{Code: CodeValidationFailed}
+	ErrUnauthorized     = &Error{Code: CodeUnauthorized}
+	ErrForbidden        = &Error{Code: CodeForbidden}
+	ErrNotFound         = &Error{Code: CodeNotFound}
+	ErrDuplicateName    = &Error{Code: CodeDuplicateName}
+	ErrVersionMismatch  = &Error{Code: CodeVersionMismatch}
+	ErrRateLimited      = &Error{Code: CodeRateLimited}
+	ErrTimeout          = &Error{Code: CodeTimeout}
+)
+
+// Error is an error response from the API: its RFC 7807 problem body.
+type Error struct {
+	Status    int          `json:"status"`
+	Code      string       `json:"code"`
+	Detail    string       `json:"detail,omitempty"`
+	RequestID string       `json:"request_id,omitempty"` // quote it when reporting a problem
+	Fields    []FieldError `json:"fields,omitempty"`     // what failed validation, for a 422
+}
+
+// FieldError is one validation failure.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	msg := fmt.Sprintf("names API: %d %s", e.Status, e.Code)
+	if e.Detail != "" { msg += ": " + e.Detail }
+	for _, f := range e.Fields { msg += fmt.Sprintf("; %s %s", f.Field, f.Message) }
+	return msg
+}
+
+// Is matches the Err values by code.
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code != "" && t.Code == e.Code
+}
+
+// readError turns an error response into an *Error, and closes its body.
+// A response without a problem body, as a proxy might send, gets just the
+// status.
+func readError(resp *http.Response) error {
+	defer resp.Body.Close()
+	e := &Error{}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if json.Unmarshal(body, e) != nil { e.Detail = http.StatusText(resp.StatusCode) }
+	e.Status = resp.StatusCode
+	return e
+}
@@ -0,0 +1,103 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Name is a stored name.
+type Name struct {
+	ID        string         `json:"id"`
+	Name      string         `json:"name"`
+	Tags      []string       `json:"tags,omitempty"`
+	Metadata  map[string]any `json:"metadata,omitempty"`
+	CreatedAt time.Time      `json:"created_at,omitzero"`
+	UpdatedAt time.Time      `json:"updated_at,omitzero"`
+	DeletedAt *time.Time     `json:"deleted_at,omitempty"` // set while in the trash
+	// Version counts the changes made to the name; updates and deletes
+	// must quote the one they were based on.
+	Version int64 `json:"version"`
+}
+
+// NameInput is what a create or a full update sends.
+type NameInput struct {
+	Name     string         `json:"name"`
+	Tags     []string       `json:"tags,omitempty"`
+	Metadata map[string]any `json:"metadata,omitempty"`
+}
+
+// ListOptions narrows and orders ListNames; the zero value lists the first
+// page, oldest first.
+type ListOptions struct {
+	Limit          int    // page size; 0 leaves it to the server
+	Offset         int
+	After          string // Page.Next of the previous page; faster than Offset
+	Sort           string // "name" or "created_at", "-" prefixed for descending
+	NamePrefix     string
+	IncludeDeleted bool
+}
+
+func (o ListOptions) query() url.Values {
+	q := url.Values{}
+	if o.Limit > 0 { q.Set("limit", strconv.Itoa(o.Limit)) }
+	if o.Offset > 0 { q.Set("offset", strconv.Itoa(o.Offset)) }
+	if o.After != "" { q.Set("after", o.After) }
+	if o.Sort != "" { q.Set("sort", o.Sort) }
+	if o.NamePrefix != "" { q.Set("name", o.NamePrefix) }
+	if o.IncludeDeleted { q.Set("includeDeleted", "true") }
+	return q
+}
+
+// Page is one page of ListNames; Next, when not empty, is the After of the
+// next one.
+type Page struct {
+	Items []Name `json:"items"`
+	Total int64  `json:"total"`
+	Next  string `json:"next,omitempty"`
+}
+
+// CreateName adds a name. A name already taken fails with ErrDuplicateName.
+func (c *Client) CreateName(ctx context.Context, in NameInput) (Name, error) {
+	var n Name
+	// One key for all attempts, so a retry after a lost response gets the
+	// first one's result instead of a duplicate.
+	h := http.Header{"Idempotency-Key": {newIdempotencyKey()}}
+	err := c.do(ctx, request{method: http.MethodPost, path: "/names", body: in, header: h}, &n)
+	return n, err
+}
+
+// GetName returns the name with id; ErrNotFound if there is none or it is
+// in the trash.
+func (c *Client) GetName(ctx context.Context, id string) (Name, error) {
+	var n Name
+	err := c.do(ctx, request{method: http.MethodGet, path: "/names/" + url.PathEscape(id)}, &n)
+	return n, err
+}
+
+func (c *Client) ListNames(ctx context.Context, opts ListOptions) (Page, error) {
+	var p Page
+	err := c.do(ctx, request{method: http.MethodGet, path: "/names", query: opts.query()}, &p)
+	return p, err
+}
+
+// UpdateName replaces the name with id, provided it is still at version;
+// otherwise it fails with ErrVersionMismatch. Tags and metadata left out of
+// in are cleared.
+func (c *Client) UpdateName(ctx context.Context, id string, version int64, in NameInput) (Name, error) {
+	var n Name
+	err := c.do(ctx, request{method: http.MethodPut, path: "/names/" + url.PathEscape(id), body: in, header: ifMatch(version)}, &n)
+	return n, err
+}
+
+// DeleteName moves the name with id to the trash, provided it is still at
+// version; otherwise it fails with ErrVersionMismatch.
+func (c *Client) DeleteName(ctx context.Context, id string, version int64) error {
+	return c.do(ctx, request{method: http.MethodDelete, path: "/names/" + url.PathEscape(id), header: ifMatch(version)}, nil)
+}
+
+func ifMatch(version int64) http.Header {
+	return http.Header{"If-Match": {`"` + strconv.FormatInt(version, 10) + `"`}}
+}
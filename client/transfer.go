@@ -0,0 +1,72 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// ExportOptions picks the format and the names of Export. Paging fields of
+// ListOptions are ignored: every matching name is exported.
+type ExportOptions struct {
+	ListOptions
+	Format string // "ndjson" (the default) or "csv"
+}
+
+// Export streams every matching name into w: one JSON object per line, or
+// CSV with a header row. If the server fails partway, the last line says
+// so ("export aborted"), since the status has been sent by then.
+func (c *Client) Export(ctx context.Context, opts ExportOptions, w io.Writer) error {
+	q := opts.query()
+	q.Del("limit")
+	q.Del("offset")
+	q.Del("after")
+	accept := "application/x-ndjson"
+	if opts.Format != "" {
+		q.Set("format", opts.Format)
+		if opts.Format == "csv" { accept = "text/csv" }
+	}
+	return c.do(ctx, request{method: http.MethodGet, path: "/names/export", query: q, header: http.Header{"Accept": {accept}}}, w)
+}
+
+// ImportOptions describes an Import upload.
+type ImportOptions struct {
+	Format string // "csv" or "ndjson"
+	Header bool   // the CSV starts with a header row, as Export writes
+}
+
+// ImportSummary accounts for every row of an upload. Skipped rows held a
+// name that already existed; Errored ones were invalid. Errors lists the
+// first hundred of either.
+type ImportSummary struct {
+	Inserted int              `json:"inserted"`
+	Skipped  int              `json:"skipped"`
+	Errored  int              `json:"errored"`
+	Errors   []ImportRowError `json:"errors"`
+}
+
+type ImportRowError struct {
+	Line  int    `json:"line"`
+	Error string `json:"error"`
+}
+
+// Import uploads names from r. It is sent once, never retried: r can't be
+// rewound, and a retry would report the names of the first attempt as
+// skipped.
+func (c *Client) Import(ctx context.Context, r io.Reader, opts ImportOptions) (ImportSummary, error) {
+	req := request{method: http.MethodPost, path: "/names/import", upload: r}
+	switch opts.Format {
+	case "csv":
+		req.contentType = "text/csv"
+		if opts.Header { req.query = url.Values{"header": {"true"}} }
+	case "ndjson":
+		req.contentType = "application/x-ndjson"
+	default:
+		return ImportSummary{}, fmt.Errorf("client: import format %q must be csv or ndjson", opts.Format)
+	}
+	var sum ImportSummary
+	err := c.do(ctx, req, &sum)
+	return sum, err
+}
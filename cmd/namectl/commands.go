@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"app/client"
+)
+
+// opts holds the flags of every command; each registers those it takes.
+type opts struct {
+	list     client.ListOptions
+	tags     tagList
+	metadata string
+	version  int64
+	format   string
+	header   bool
+}
+
+// tagList is a flag that can be given again for each tag.
+type tagList []string
+
+func (t *tagList) String() string     { return strings.Join(*t, ",") }
+func (t *tagList) Set(v string) error { *t = append(*t, v); return nil }
+
+func (o *opts) filterFlags(fs *flag.FlagSet) {
+	fs.StringVar(&o.list.Sort, "sort", "", "name or created_at, - prefixed for descending")
+	fs.StringVar(&o.list.NamePrefix, "name", "", "only names starting with this")
+	fs.BoolVar(&o.list.IncludeDeleted, "all", false, "include names in the trash")
+}
+
+func (o *opts) listFlags(fs *flag.FlagSet) {
+	o.filterFlags(fs)
+	fs.IntVar(&o.list.Limit, "limit", 0, "page size")
+	fs.StringVar(&o.list.After, "after", "", "cursor from the previous page")
+}
+
+func (o *opts) nameFlags(fs *flag.FlagSet) {
+	fs.Var(&o.tags, "tag", "a tag; repeat for more")
+	fs.StringVar(&o.metadata, "metadata", "", "metadata as a JSON object")
+}
+
+func (o *opts) versionFlag(fs *flag.FlagSet) {
+	fs.Int64Var(&o.version, "version", 0, "the version to change; 0 reads the current one")
+}
+
+func (o *opts) updateFlags(fs *flag.FlagSet) { o.nameFlags(fs); o.versionFlag(fs) }
+
+func (o *opts) importFlags(fs *flag.FlagSet) {
+	fs.StringVar(&o.format, "format", "", "csv or ndjson; guessed from the file extension if not given")
+	fs.BoolVar(&o.header, "header", false, "the CSV starts with a header row")
+}
+
+func (o *opts) exportFlags(fs *flag.FlagSet) {
+	o.filterFlags(fs)
+	fs.StringVar(&o.format, "format", "ndjson", "ndjson or csv")
+}
+
+// input builds what create and update send.
+func (o *opts) input(name string) (client.NameInput, error) {
+	in := client.NameInput{Name: name, Tags: o.tags}
+	if o.metadata != "" {
+		if err := json.Unmarshal([]byte(o.metadata), &in.Metadata); err != nil { return in, usagef("--metadata must be a JSON object: %v", err) }
+	}
+	return in, nil
+}
+
+// currentVersion returns o.version, or if it is 0 the version id is at now.
+func (o *opts) currentVersion(ctx context.Context, api *client.Client, id string) (int64, error) {
+	if o.version != 0 { return o.version, nil }
+	n, err := api.GetName(ctx, id)
+	return n.Version, err
+}
+
+func args(args []string, names ...string) error {
+	if len(args) != len(names) { return usagef("want %s, got %d argument(s)", strings.Join(names, " and "), len(args)) }
+	return nil
+}
+
+// ---- commands ----
+
+func list(ctx context.Context, c *cli, api *client.Client, o *opts, a []string) error {
+	if err := args(a); err != nil { return err }
+	page, err := api.ListNames(ctx, o.list)
+	if err != nil { return err }
+	if c.output == "json" { return c.json(page) }
+	c.table(page.Items...)
+	if page.Next != "" { fmt.Fprintf(c.stderr, "%d of %d; next page: --after %s\n", len(page.Items), page.Total, page.Next) }
+	return nil
+}
+
+func get(ctx context.Context, c *cli, api *client.Client, _ *opts, a []string) error {
+	if err := args(a, "<id>"); err != nil { return err }
+	n, err := api.GetName(ctx, a[0])
+	if err != nil { return err }
+	return c.print(n)
+}
+
+func create(ctx context.Context, c *cli, api *client.Client, o *opts, a []string) error {
+	if err := args(a, "<name>"); err != nil { return err }
+	in, err := o.input(a[0])
+	if err != nil { return err }
+	n, err := api.CreateName(ctx, in)
+	if err != nil { return err }
+	return c.print(n)
+}
+
+func update(ctx context.Context, c *cli, api *client.Client, o *opts, a []string) error {
+	if err := args(a, "<id>", "<name>"); err != nil { return err }
+	in, err := o.input(a[1])
+	if err != nil { return err }
+	version, err := o.currentVersion(ctx, api, a[0])
+	if err != nil { return err }
+	n, err := api.UpdateName(ctx, a[0], version, in)
+	if err != nil { return err }
+	return c.print(n)
+}
+
+func del(ctx context.Context, c *cli, api *client.Client, o *opts, a []string) error {
+	if err := args(a, "<id>"); err != nil { return err }
+	version, err := o.currentVersion(ctx, api, a[0])
+	if err != nil { return err }
+	return api.DeleteName(ctx, a[0], version)
+}
+
+func importNames(ctx context.Context, c *cli, api *client.Client, o *opts, a []string) error {
+	if err := args(a, "<file|->"); err != nil { return err }
+	format := o.format
+	if format == "" {
+		switch strings.ToLower(filepath.Ext(a[0])) {
+		case ".ndjson", ".jsonl":
+			format = "ndjson"
+		default:
+			format = "csv"
+		}
+	}
+	var src io.Reader = c.stdin
+	if a[0] != "-" {
+		f, err := os.Open(a[0])
+		if err != nil { return err }
+		defer f.Close()
+		src = f
+	}
+	sum, err := api.Import(ctx, src, client.ImportOptions{Format: format, Header: o.header})
+	if err != nil { return err }
+	if c.output == "json" {
+		if err := c.json(sum); err != nil { return err }
+	} else {
+		fmt.Fprintf(c.stdout, "inserted %d, skipped %d, errored %d\n", sum.Inserted, sum.Skipped, sum.Errored)
+		for _, e := range sum.Errors { fmt.Fprintf(c.stdout, "line %d: %s\n", e.Line, e.Error) }
+	}
+	if sum.Errored > 0 { return errors.New("some rows were rejected") }
+	return nil
+}
+
+func export(ctx context.Context, c *cli, api *client.Client, o *opts, a []string) error {
+	if err := args(a); err != nil { return err }
+	return api.Export(ctx, client.ExportOptions{ListOptions: o.list, Format: o.format}, c.stdout)
+}
+
+// ---- output ----
+
+func (c *cli) json(v any) error {
+	enc := json.NewEncoder(c.stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func (c *cli) print(n client.Name) error {
+	if c.output == "json" { return c.json(n) }
+	c.table(n)
+	return nil
+}
+
+func (c *cli) table(names ...client.Name) {
+	w := tabwriter.NewWriter(c.stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tTAGS\tVERSION\tUPDATED")
+	for _, n := range names {
+		updated := "-"
+		if !n.UpdatedAt.IsZero() { updated = n.UpdatedAt.Local().Format(time.DateTime) }
+		if n.DeletedAt != nil { updated += " (deleted)" }
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\n", n.ID, n.Name, strings.Join(n.Tags, ","), n.Version, updated)
+	}
+	w.Flush()
+}
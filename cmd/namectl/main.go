@@ -0,0 +1,163 @@
+// Command namectl manages names from the command line, through the HTTP
+// API, for operators and scripts.
+//
+//	namectl [flags] <command> [command flags] [args]
+//
+//	list    [--limit n] [--sort s] [--name prefix] [--after cursor] [--all]
+//	get     <id>
+//	create  <name> [--tag t]... [--metadata json]
+//	update  <id> <name> [--version n] [--tag t]... [--metadata json]
+//	delete  <id> [--version n]
+//	import  <file|-> [--format csv|ndjson] [--header]
+//	export  [--format ndjson|csv] [--sort s] [--name prefix] [--all]
+//
+// --server (NAMECTL_SERVER) is the API's base URL. Requests authenticate
+// with --api-key (NAMECTL_API_KEY) or --token (NAMECTL_TOKEN). --output
+// table prints names in columns; json, the default, prints what the API
+// returned. update and delete without --version act on the current one.
+//
+// namectl exits 1 if the API refuses a request, or an import rejects rows,
+// and 2 for a usage error.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+
+	"app/client"
+)
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	os.Exit(run(ctx, os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// cli is one invocation: the global flags and where it reads and writes.
+type cli struct {
+	server, apiKey, token, output string
+	stdin                         io.Reader
+	stdout, stderr                io.Writer
+}
+
+// errUsage fails an invocation with exit status 2.
+type errUsage struct{ msg string }
+
+func (e errUsage) Error() string { return e.msg }
+
+func usagef(format string, args ...any) error { return errUsage{fmt.Sprintf(format, args...)} }
+
+// command is a subcommand: its usage line, the flags it takes on top of
+// the global ones, and what it does with them and its arguments.
+type command struct {
+	usage string
+	flags func(o *opts, fs *flag.FlagSet)
+	run   func(ctx context.Context, c *cli, api *client.Client, o *opts, args []string) error
+}
+
+var commands = map[string]command{
+	"list":   {"list [--limit n] [--sort s] [--name prefix] [--after cursor] [--all]", (*opts).listFlags, list},
+	"get":    {"get <id>", nil, get},
+	"create": {"create <name> [--tag t]... [--metadata json]", (*opts).nameFlags, create},
+	"update": {"update <id> <name> [--version n] [--tag t]... [--metadata json]", (*opts).updateFlags, update},
+	"delete": {"delete <id> [--version n]", (*opts).versionFlag, del},
+	"import": {"import <file|-> [--format csv|ndjson] [--header]", (*opts).importFlags, importNames},
+	"export": {"export [--format ndjson|csv] [--sort s] [--name prefix] [--all]", (*opts).exportFlags, export},
+}
+
+func run(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	c := &cli{stdin: stdin, stdout: stdout, stderr: stderr}
+	err := c.run(ctx, args)
+	var usage errUsage
+	switch {
+	case err == nil:
+		return 0
+	case errors.Is(err, flag.ErrHelp):
+		return 0
+	case errors.As(err, &usage):
+		fmt.Fprintf(stderr, "namectl: %s\n\n", err)
+		c.usage()
+		return 2
+	default:
+		fmt.Fprintf(stderr, "namectl: %s\n", err)
+		return 1
+	}
+}
+
+func (c *cli) usage() {
+	fmt.Fprintln(c.stderr, "usage: namectl [--server url] [--api-key key | --token token] [--output json|table] <command> ...")
+	for _, name := range []string{"list", "get", "create", "update", "delete", "import", "export"} {
+		fmt.Fprintln(c.stderr, "  namectl "+commands[name].usage)
+	}
+}
+
+// globals registers the global flags on fs, defaulting to their current
+// values, so that they may come before or after the command.
+func (c *cli) globals(fs *flag.FlagSet) {
+	fs.StringVar(&c.server, "server", c.server, "API base URL (NAMECTL_SERVER)")
+	fs.StringVar(&c.apiKey, "api-key", c.apiKey, "API key (NAMECTL_API_KEY)")
+	fs.StringVar(&c.token, "token", c.token, "bearer token (NAMECTL_TOKEN)")
+	fs.StringVar(&c.output, "output", c.output, "json or table")
+}
+
+func (c *cli) run(ctx context.Context, args []string) error {
+	c.server, c.apiKey, c.token, c.output = envOr("NAMECTL_SERVER", "http://localhost:8080"), os.Getenv("NAMECTL_API_KEY"), os.Getenv("NAMECTL_TOKEN"), "json"
+	fs := flag.NewFlagSet("namectl", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	c.globals(fs)
+	if err := fs.Parse(args); err != nil { return c.flagErr(err) }
+	if fs.NArg() == 0 { return usagef("no command given") }
+	name := fs.Arg(0)
+	cmd, ok := commands[name]
+	if !ok { return usagef("unknown command %q", name) }
+
+	sub := flag.NewFlagSet(name, flag.ContinueOnError)
+	sub.SetOutput(io.Discard)
+	c.globals(sub)
+	var o opts
+	if cmd.flags != nil { cmd.flags(&o, sub) }
+	rest, err := parseInterspersed(sub, fs.Args()[1:])
+	if err != nil { return c.flagErr(err) }
+	if c.output != "json" && c.output != "table" { return usagef("--output must be json or table") }
+
+	var auth []client.Option
+	switch {
+	case c.apiKey != "" && c.token != "":
+		return usagef("--api-key and --token are mutually exclusive")
+	case c.apiKey != "":
+		auth = append(auth, client.WithAPIKey(c.apiKey))
+	case c.token != "":
+		auth = append(auth, client.WithToken(c.token))
+	}
+	api, err := client.New(c.server, append(auth, client.WithUserAgent("namectl"))...)
+	if err != nil { return usagef("%s", strings.TrimPrefix(err.Error(), "client: ")) }
+	return cmd.run(ctx, c, api, &o, rest)
+}
+
+func (c *cli) flagErr(err error) error {
+	if errors.Is(err, flag.ErrHelp) { c.usage(); return err }
+	return usagef("%s", err)
+}
+
+// parseInterspersed parses fs from args, letting flags follow positional
+// arguments, and returns the positional ones.
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var rest []string
+	for {
+		if err := fs.Parse(args); err != nil { return nil, err }
+		if fs.NArg() == 0 { return rest, nil }
+		rest = append(rest, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
+
+func envOr(key, def string) string {
+	if v := os.Getenv(key); v != "" { return v }
+	return def
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"app/client"
+	"app/internal/auth"
+	"app/internal/handlers"
+	"app/internal/server"
+	"app/internal/store"
+	"app/internal/tenant"
+)
+
+func TestNamectl(t *testing.T) {
+	tokens := auth.NewTokens([]byte("test-secret"), time.Hour)
+	keys, idem := store.NewMemoryAPIKeys(), store.NewMemoryIdempotency()
+	h := handlers.New(handlers.Deps{Names: store.NewMemoryNames(), Users: store.NewMemoryUsers(), APIKeys: keys, Tokens: tokens, ImportMaxBytes: 1 << 20})
+	ts := httptest.NewServer(server.New(server.Config{MaxBodyBytes: 1 << 20, IdempotencyTTL: time.Hour}, h, tokens, idem, keys).Handler())
+	defer ts.Close()
+	token, err := tokens.Issue(primitive.NewObjectID(), tenant.Default, auth.RoleEditor)
+	if err != nil { t.Fatal(err) }
+
+	// namectl runs the command line and returns its exit status and output.
+	namectl := func(stdin string, args ...string) (int, string, string) {
+		t.Helper()
+		var stdout, stderr bytes.Buffer
+		args = append([]string{"--server", ts.URL, "--token", token}, args...)
+		code := run(context.Background(), args, strings.NewReader(stdin), &stdout, &stderr)
+		return code, stdout.String(), stderr.String()
+	}
+	must := func(stdin string, args ...string) string {
+		t.Helper()
+		code, out, errOut := namectl(stdin, args...)
+		if code != 0 { t.Fatalf("namectl %s: exit %d: %s", strings.Join(args, " "), code, errOut) }
+		return out
+	}
+
+	var n client.Name
+	if err := json.Unmarshal([]byte(must("", "create", "Alice", "--tag", "vip", "--tag", "core", "--metadata", `{"team":"x"}`)), &n); err != nil { t.Fatal(err) }
+	if n.Name != "Alice" || len(n.Tags) != 2 || n.Metadata["team"] != "x" { t.Fatalf("created %+v", n) }
+
+	if out := must("", "get", n.ID, "--output", "table"); !strings.Contains(out, "Alice") || !strings.Contains(out, "vip,core") { t.Fatalf("get:\n%s", out) }
+	if out := must("", "update", n.ID, "Alicia"); !strings.Contains(out, `"version": 2`) { t.Fatalf("update:\n%s", out) }
+	if code, _, errOut := namectl("", "update", n.ID, "Alice", "--version", "1"); code != 1 || !strings.Contains(errOut, client.CodeVersionMismatch) { t.Fatalf("stale update: exit %d: %s", code, errOut) }
+
+	code, out, _ := namectl("Name\nBob\nCarol\n<b>\n", "import", "-", "--header", "--output", "table")
+	if code != 1 || !strings.HasPrefix(out, "inserted 2, skipped 0, errored 1\nline 4: ") { t.Fatalf("import: exit %d:\n%s", code, out) }
+	if out := must("", "list", "--sort", "name", "--output", "table"); strings.Count(out, "\n") != 4 || !strings.Contains(out, "Carol") { t.Fatalf("list:\n%s", out) }
+	if out := must("", "export", "--format", "csv", "--name", "B"); !strings.Contains(out, "Bob") || strings.Contains(out, "Carol") { t.Fatalf("export:\n%s", out) }
+
+	must("", "delete", n.ID)
+	if code, _, _ := namectl("", "get", n.ID); code != 1 { t.Fatalf("get deleted: exit %d", code) }
+
+	for _, args := range [][]string{{}, {"frob"}, {"get"}, {"list", "--output", "yaml"}, {"create", "x", "--metadata", "[]"}, {"list", "--limit", "many"}} {
+		if code, _, _ := namectl("", args...); code != 2 { t.Errorf("namectl %q: exit %d, want 2", args, code) }
+	}
+}
@@ -0,0 +1,168 @@
+// Package audit records who changed what: every write to names, through
+// any of the APIs, leaves an entry with the actor, the request ID and the
+// document before and after.
+package audit
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"app/internal/auth"
+	"app/internal/requestid"
+	"app/internal/store"
+)
+
+// Entity is what audit entries about names are filed under.
+const Entity = "names"
+
+// Names wraps a NameStore and records every successful write to it in an
+// AuditStore. Reads pass straight through.
+//
+// The before documents are read just ahead of the write and entries are
+// stored just after it, or after the commit of the transaction it is part
+// of (see store.AfterCommit), not within it: a concurrent writer can slip
+// in between, and an entry that fails to be stored is logged, not
+// returned, since the write it describes has already happened.
+type Names struct {
+	store.NameStore
+	log store.AuditStore
+}
+
+func NewNames(s store.NameStore, log store.AuditStore) *Names {
+	return &Names{NameStore: s, log: log}
+}
+
+// lookup returns the current documents of ids, soft-deleted ones included.
+// A failure costs the entries their before or after, not the write.
+func (a *Names) lookup(ctx context.Context, ids ...primitive.ObjectID) map[primitive.ObjectID]store.Name {
+	docs, err := a.NameStore.Lookup(ctx, ids)
+	if err != nil { slog.ErrorContext(ctx, "reading names for the audit log", "err", err) }
+	return docs
+}
+
+func entry(ctx context.Context, action string, id primitive.ObjectID, before, after *store.Name) store.AuditEntry {
+	e := store.AuditEntry{
+		ID: primitive.NewObjectID(), Entity: Entity, EntityID: id, Action: action,
+		RequestID: requestid.FromContext(ctx), At: time.Now().UTC(), Before: before, After: after,
+	}
+	if uid := auth.UserIDFromContext(ctx); !uid.IsZero() { e.Actor = uid.Hex() }
+	return e
+}
+
+// record stores entries even if ctx has been canceled meanwhile: the write
+// they describe has happened regardless.
+func (a *Names) record(ctx context.Context, entries ...store.AuditEntry) {
+	if len(entries) == 0 { return }
+	store.AfterCommit(ctx, func(ctx context.Context) {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+		defer cancel()
+		if err := a.log.RecordAudit(ctx, entries); err != nil {
+			slog.ErrorContext(ctx, "writing the audit log", "entries", len(entries), "err", err)
+		}
+	})
+}
+
+// found returns a pointer to docs[id], nil if there is none.
+func found(docs map[primitive.ObjectID]store.Name, id primitive.ObjectID) *store.Name {
+	if n, ok := docs[id]; ok { return &n }
+	return nil
+}
+
+func (a *Names) Create(ctx context.Context, n *store.Name) error {
+	if err := a.NameStore.Create(ctx, n); err != nil { return err }
+	after := *n
+	a.record(ctx, entry(ctx, "created", n.ID, nil, &after))
+	return nil
+}
+
+func (a *Names) CreateIfAbsent(ctx context.Context, n *store.Name) (bool, error) {
+	created, err := a.NameStore.CreateIfAbsent(ctx, n)
+	if created { after := *n; a.record(ctx, entry(ctx, "created", n.ID, nil, &after)) }
+	return created, err
+}
+
+func (a *Names) Upsert(ctx context.Context, n store.Name, ifVersion int64) (*store.Name, store.Name, error) {
+	before, after, err := a.NameStore.Upsert(ctx, n, ifVersion)
+	if err != nil { return before, after, err }
+	action := "updated"
+	if before == nil { action = "created" }
+	a.record(ctx, entry(ctx, action, after.ID, before, &after))
+	return before, after, nil
+}
+
+func (a *Names) Update(ctx context.Context, id primitive.ObjectID, n store.Name, ifVersion int64) (store.Name, error) {
+	before := a.lookup(ctx, id)
+	after, err := a.NameStore.Update(ctx, id, n, ifVersion)
+	if err == nil { a.record(ctx, entry(ctx, "updated", id, found(before, id), &after)) }
+	return after, err
+}
+
+func (a *Names) Patch(ctx context.Context, id primitive.ObjectID, p store.NamePatch, ifVersion int64) (store.Name, error) {
+	before := a.lookup(ctx, id)
+	after, err := a.NameStore.Patch(ctx, id, p, ifVersion)
+	if err == nil { a.record(ctx, entry(ctx, "updated", id, found(before, id), &after)) }
+	return after, err
+}
+
+func (a *Names) SoftDelete(ctx context.Context, id primitive.ObjectID, ifVersion int64) error {
+	before := a.lookup(ctx, id)
+	if err := a.NameStore.SoftDelete(ctx, id, ifVersion); err != nil { return err }
+	a.record(ctx, entry(ctx, "deleted", id, found(before, id), found(a.lookup(ctx, id), id)))
+	return nil
+}
+
+func (a *Names) HardDelete(ctx context.Context, id primitive.ObjectID, ifVersion int64) error {
+	before := a.lookup(ctx, id)
+	if err := a.NameStore.HardDelete(ctx, id, ifVersion); err != nil { return err }
+	a.record(ctx, entry(ctx, "removed", id, found(before, id), nil))
+	return nil
+}
+
+func (a *Names) Restore(ctx context.Context, id primitive.ObjectID) (store.Name, error) {
+	before := a.lookup(ctx, id)
+	after, err := a.NameStore.Restore(ctx, id)
+	if err == nil { a.record(ctx, entry(ctx, "restored", id, found(before, id), &after)) }
+	return after, err
+}
+
+func (a *Names) CreateMany(ctx context.Context, ns []store.Name) ([]error, error) {
+	errs, err := a.NameStore.CreateMany(ctx, ns)
+	if err == nil { a.recordCreated(ctx, ns, errs) }
+	return errs, err
+}
+
+func (a *Names) InsertMany(ctx context.Context, ns []store.Name) ([]error, error) {
+	errs, err := a.NameStore.InsertMany(ctx, ns)
+	if err == nil { a.recordCreated(ctx, ns, errs) }
+	return errs, err
+}
+
+// recordCreated records the items of a batch insert that were stored.
+func (a *Names) recordCreated(ctx context.Context, ns []store.Name, errs []error) {
+	var entries []store.AuditEntry
+	for i := range ns {
+		if i < len(errs) && errs[i] == nil { after := ns[i]; entries = append(entries, entry(ctx, "created", ns[i].ID, nil, &after)) }
+	}
+	a.record(ctx, entries...)
+}
+
+func (a *Names) DeleteMany(ctx context.Context, ids []primitive.ObjectID, hard bool) (map[primitive.ObjectID]bool, error) {
+	before := a.lookup(ctx, ids...)
+	existed, err := a.NameStore.DeleteMany(ctx, ids, hard)
+	if err != nil { return existed, err }
+
+	var deleted []primitive.ObjectID
+	for _, id := range ids {
+		if existed[id] && !slices.Contains(deleted, id) { deleted = append(deleted, id) }
+	}
+	action, after := "removed", map[primitive.ObjectID]store.Name{}
+	if !hard { action, after = "deleted", a.lookup(ctx, deleted...) }
+	entries := make([]store.AuditEntry, 0, len(deleted))
+	for _, id := range deleted { entries = append(entries, entry(ctx, action, id, found(before, id), found(after, id))) }
+	a.record(ctx, entries...)
+	return existed, nil
+}
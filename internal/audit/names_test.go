@@ -0,0 +1,64 @@
+package audit
+
+import (
+	"context"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"app/internal/auth"
+	"app/internal/requestid"
+	"app/internal/store"
+)
+
+func TestNames(t *testing.T) {
+	user := primitive.NewObjectID()
+	ctx := requestid.NewContext(auth.WithUserID(context.Background(), user), "req-1")
+	log := store.NewMemoryAudit()
+	s := NewNames(store.NewMemoryNames(), log)
+
+	n := store.Name{Name: "alice"}
+	if err := s.Create(ctx, &n); err != nil { t.Fatal(err) }
+	_ = s.Create(ctx, &store.Name{Name: "alice"}) // duplicate: not recorded
+	if _, err := s.Patch(ctx, n.ID, store.NamePatch{Tags: &[]string{"vip"}}, store.AnyVersion); err != nil { t.Fatal(err) }
+	if err := s.SoftDelete(ctx, n.ID, store.AnyVersion); err != nil { t.Fatal(err) }
+	if _, err := s.Restore(ctx, n.ID); err != nil { t.Fatal(err) }
+	errs, _ := s.CreateMany(ctx, []store.Name{{Name: "bob"}, {Name: "alice"}})
+	if errs[1] == nil { t.Fatal("duplicate in batch stored") }
+	if _, err := s.DeleteMany(ctx, []primitive.ObjectID{n.ID, n.ID}, true); err != nil { t.Fatal(err) }
+
+	page, err := log.ListAudit(ctx, store.AuditQuery{Entity: Entity, EntityID: n.ID, Limit: 10})
+	if err != nil { t.Fatal(err) }
+	want := []string{"removed", "restored", "deleted", "updated", "created"}
+	if len(page.Items) != len(want) { t.Fatalf("%d entries: %+v", len(page.Items), page.Items) }
+	for i, e := range page.Items {
+		if e.Action != want[i] || e.Actor != user.Hex() || e.RequestID != "req-1" { t.Errorf("entry %d: %s by %q in %q, want %s", i, e.Action, e.Actor, e.RequestID, want[i]) }
+	}
+	removed, restored, deleted, updated := page.Items[0], page.Items[1], page.Items[2], page.Items[3]
+	if removed.After != nil || removed.Before == nil || removed.Before.Version != 4 { t.Errorf("removed: %+v", removed) }
+	if restored.Before.DeletedAt == nil || restored.After.DeletedAt != nil { t.Errorf("restored: %+v", restored) }
+	if deleted.After == nil || deleted.After.DeletedAt == nil { t.Errorf("deleted: %+v", deleted) }
+	if len(updated.Before.Tags) != 0 || updated.After.Tags[0] != "vip" { t.Errorf("updated: %+v -> %+v", updated.Before, updated.After) }
+
+	if all, _ := log.ListAudit(ctx, store.AuditQuery{Limit: 10}); len(all.Items) != 6 { t.Fatalf("%d entries in all, want 6 with bob's", len(all.Items)) }
+}
+
+func TestNamesUpsert(t *testing.T) {
+	ctx := context.Background()
+	log := store.NewMemoryAudit()
+	s := NewNames(store.NewMemoryNames(), log)
+
+	n := store.Name{Name: "alice"}
+	if created, err := s.CreateIfAbsent(ctx, &n); err != nil || !created { t.Fatal(created, err) }
+	if created, _ := s.CreateIfAbsent(ctx, &store.Name{Name: "alice"}); created { t.Fatal("created twice") } // not recorded
+	if _, _, err := s.Upsert(ctx, store.Name{Name: "alice", Tags: []string{"vip"}}, store.AnyVersion); err != nil { t.Fatal(err) }
+	if _, _, err := s.Upsert(ctx, store.Name{Name: "bob"}, store.AnyVersion); err != nil { t.Fatal(err) }
+
+	page, err := log.ListAudit(ctx, store.AuditQuery{Limit: 10})
+	if err != nil { t.Fatal(err) }
+	if len(page.Items) != 3 { t.Fatalf("%d entries: %+v", len(page.Items), page.Items) }
+	bob, updated, created := page.Items[0], page.Items[1], page.Items[2]
+	if bob.Action != "created" || bob.Before != nil || bob.After.Name != "bob" { t.Errorf("bob: %+v", bob) }
+	if updated.Action != "updated" || updated.EntityID != n.ID || updated.Before.Version != 1 || updated.After.Tags[0] != "vip" { t.Errorf("updated: %+v", updated) }
+	if created.Action != "created" || created.EntityID != n.ID { t.Errorf("created: %+v", created) }
+}
@@ -0,0 +1,69 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"slices"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Scopes an API key can be minted with. Bearer tokens carry those of their
+// user's role.
+const (
+	ScopeRead  = "names:read"
+	ScopeWrite = "names:write"
+	ScopeAudit = "audit:read"
+	ScopeAdmin = "admin:read"
+)
+
+var Scopes = []string{ScopeRead, ScopeWrite, ScopeAudit, ScopeAdmin}
+
+// APIKeyPrefix starts every key, so leaked keys are easy to grep for.
+const APIKeyPrefix = "nk_"
+
+// NewAPIKey returns a random key and the hash to store in its place.
+func NewAPIKey() (key, hash string, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil { return "", "", err }
+	key = APIKeyPrefix + base64.RawURLEncoding.EncodeToString(b)
+	return key, HashAPIKey(key), nil
+}
+
+// HashAPIKey is how keys are stored and looked up. Keys are random, so a
+// plain SHA-256 is enough; there's nothing to brute-force like a password.
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+type scopesKey struct{}
+
+// WithScopes limits the request to scopes, those its role or API key grants.
+func WithScopes(ctx context.Context, scopes []string) context.Context {
+	return context.WithValue(ctx, scopesKey{}, scopes)
+}
+
+// HasScope reports whether the caller may act with scope. Requests with auth
+// disabled aren't limited.
+func HasScope(ctx context.Context, scope string) bool {
+	scopes, limited := ctx.Value(scopesKey{}).([]string)
+	return !limited || slices.Contains(scopes, scope)
+}
+
+type apiKeyKey struct{}
+
+// WithAPIKeyID records that the request came with API key id.
+func WithAPIKeyID(ctx context.Context, id primitive.ObjectID) context.Context {
+	return context.WithValue(ctx, apiKeyKey{}, id)
+}
+
+// APIKeyIDFromContext returns the API key the request came with, or the
+// zero ObjectID for bearer tokens and with auth disabled.
+func APIKeyIDFromContext(ctx context.Context) primitive.ObjectID {
+	id, _ := ctx.Value(apiKeyKey{}).(primitive.ObjectID)
+	return id
+}
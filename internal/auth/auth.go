@@ -0,0 +1,76 @@
+// Package auth issues and verifies the bearer tokens that protect /names.
+package auth
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var ErrInvalidToken = errors.New("invalid token")
+
+// Tokens signs HS256 JWTs whose subject is the user's ID, whose "tenant"
+// claim is the tenant the user belongs to and whose "role" claim is the
+// user's role, so a role change takes effect at the user's next login. A
+// Tokens without a secret is disabled: it issues nothing and callers should
+// skip checks.
+type Tokens struct {
+	secret []byte
+	ttl    time.Duration
+}
+
+func NewTokens(secret []byte, ttl time.Duration) *Tokens {
+	return &Tokens{secret: secret, ttl: ttl}
+}
+
+func (t *Tokens) Enabled() bool      { return len(t.secret) > 0 }
+func (t *Tokens) TTL() time.Duration { return t.ttl }
+
+type claims struct {
+	jwt.RegisteredClaims
+	Tenant string `json:"tenant,omitempty"`
+	Role   string `json:"role,omitempty"`
+}
+
+func (t *Tokens) Issue(userID primitive.ObjectID, tenant, role string) (string, error) {
+	now := time.Now()
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   userID.Hex(),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(t.ttl)),
+		},
+		Tenant: tenant,
+		Role:   role,
+	}).SignedString(t.secret)
+}
+
+// Verify returns the user ID, tenant and role a token was issued for, or
+// ErrInvalidToken. Tokens from before tenants existed have an empty tenant,
+// which tenant.FromContext reads as the default one; those from before roles
+// an empty role, which RoleScopes grants everything.
+func (t *Tokens) Verify(raw string) (userID primitive.ObjectID, tenant, role string, err error) {
+	var c claims
+	_, err = jwt.ParseWithClaims(raw, &c, func(*jwt.Token) (any, error) { return t.secret, nil },
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
+	if err != nil { return primitive.NilObjectID, "", "", ErrInvalidToken }
+	uid, err := primitive.ObjectIDFromHex(c.Subject)
+	if err != nil { return primitive.NilObjectID, "", "", ErrInvalidToken }
+	return uid, c.Tenant, c.Role, nil
+}
+
+type ctxKey struct{}
+
+func WithUserID(ctx context.Context, id primitive.ObjectID) context.Context {
+	return context.WithValue(ctx, ctxKey{}, id)
+}
+
+// UserIDFromContext returns the authenticated caller, or the zero ObjectID
+// when auth is disabled.
+func UserIDFromContext(ctx context.Context) primitive.ObjectID {
+	id, _ := ctx.Value(ctxKey{}).(primitive.ObjectID)
+	return id
+}
@@ -0,0 +1,62 @@
+package auth
+
+import (
+	"context"
+	"slices"
+)
+
+// Roles a user or API key can hold, each allowed everything the one before
+// it is and more.
+const (
+	RoleViewer = "viewer" // reads names
+	RoleEditor = "editor" // and writes them
+	RoleAdmin  = "admin"  // and reads the audit log and stats, and manages users and keys
+)
+
+var Roles = []string{RoleViewer, RoleEditor, RoleAdmin}
+
+// roleScopes are the scopes each role grants. Users and keys created before
+// roles existed have none, and keep the access they had: all of it.
+var roleScopes = map[string][]string{
+	RoleViewer: {ScopeRead},
+	RoleEditor: {ScopeRead, ScopeWrite},
+	RoleAdmin:  Scopes,
+	"":         Scopes,
+}
+
+func ValidRole(role string) bool { return slices.Contains(Roles, role) }
+
+// RoleScopes returns the scopes role grants.
+func RoleScopes(role string) []string { return roleScopes[role] }
+
+// RoleWithin reports whether role grants nothing beyond max.
+func RoleWithin(role, max string) bool { return rank(role) <= rank(max) }
+
+func rank(role string) int {
+	if role == "" { return len(Roles) }
+	return slices.Index(Roles, role) + 1
+}
+
+// GrantedScopes is what an API key may do: the scopes it was minted with
+// that its role still grants.
+func GrantedScopes(role string, scopes []string) []string {
+	var granted []string
+	for _, sc := range scopes {
+		if slices.Contains(RoleScopes(role), sc) { granted = append(granted, sc) }
+	}
+	return granted
+}
+
+type roleKey struct{}
+
+// WithRole records the role of the caller.
+func WithRole(ctx context.Context, role string) context.Context {
+	return context.WithValue(ctx, roleKey{}, role)
+}
+
+// RoleFromContext returns the caller's role, and false when auth is
+// disabled and there is no caller to have one.
+func RoleFromContext(ctx context.Context) (string, bool) {
+	role, ok := ctx.Value(roleKey{}).(string)
+	return role, ok
+}
@@ -0,0 +1,232 @@
+// Package backup dumps a tenant's names to a Target, a directory or an
+// S3-compatible bucket, and reads the dumps back for restoring. A dump is a
+// gzipped stream of documents, in one of two formats: NDJSON, one Name as
+// the API shows it per line, or BSON, the documents end to end as
+// mongodump writes a collection. Either way it is written as the store
+// yields the names, so memory stays flat however many there are.
+//
+// Dumps are kept as <tenant>/<name>, so a tenant only ever restores its own.
+package backup
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+
+	"app/internal/store"
+)
+
+// Formats.
+const (
+	NDJSON = "ndjson"
+	BSON   = "bson"
+)
+
+// maxRecord caps a record of a dump being read, well above the largest
+// name validate allows.
+const maxRecord = 1 << 20
+
+// ErrNotFound is a dump that isn't in the Target.
+var ErrNotFound = errors.New("backup not found")
+
+// Target is where dumps are kept.
+type Target interface {
+	// Put stores what r yields as key. The dump only replaces one of that
+	// key once r has been read to the end; if r fails, nothing is kept.
+	Put(ctx context.Context, key string, r io.Reader) error
+	// Get opens the dump stored as key; ErrNotFound if there is none.
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+}
+
+var nameRE = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,127}\.(ndjson|bson)\.gz$`)
+
+// Name is what the dump of names taken at at is called.
+func Name(at time.Time, format string) string {
+	return "names-" + at.UTC().Format("20060102T150405.000Z") + "." + format + ".gz"
+}
+
+// Key is where a tenant's dump called name is kept. It is false if the
+// name isn't one a dump may have: letters, digits, dots, dashes and
+// underscores ending in .ndjson.gz or .bson.gz, so no key leads out of the
+// tenant's folder.
+func Key(tenant, name string) (string, bool) {
+	if !nameRE.MatchString(name) { return "", false }
+	return tenant + "/" + name, true
+}
+
+// FormatOf is the format of a dump by its name.
+func FormatOf(name string) string {
+	if strings.HasSuffix(name, "."+BSON+".gz") { return BSON }
+	return NDJSON
+}
+
+// Result describes a dump that was taken.
+type Result struct {
+	Backup    string    `json:"backup"` // its name, for POST /admin/restore
+	Format    string    `json:"format"`
+	Names     int       `json:"names"`
+	Bytes     int64     `json:"bytes"` // compressed
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Dump writes the names each yields to the tenant's new dump in target.
+// each is called with the func to hand every name to, as
+// store.NameStore.Each would be.
+func Dump(ctx context.Context, target Target, tenant, format string, each func(fn func(store.Name) error) error) (Result, error) {
+	res := Result{Format: format, CreatedAt: time.Now().UTC().Truncate(time.Millisecond)}
+	res.Backup = Name(res.CreatedAt, format)
+	key, _ := Key(tenant, res.Backup)
+
+	pr, pw := io.Pipe()
+	cw := &countingWriter{w: pw}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		w := NewWriter(cw, format)
+		err := each(func(n store.Name) error {
+			if err := w.Write(n); err != nil { return err }
+			res.Names++
+			return nil
+		})
+		if err == nil { err = w.Close() }
+		pw.CloseWithError(err) // nil is io.EOF
+	}()
+	err := target.Put(ctx, key, pr)
+	pr.CloseWithError(errors.New("backup: the target stopped reading")) // so a failed Put doesn't leave the writer blocked
+	<-done
+	res.Bytes = cw.n
+	return res, err
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// Writer writes names to a dump.
+type Writer struct {
+	gz     *gzip.Writer
+	format string
+	enc    *json.Encoder
+}
+
+func NewWriter(w io.Writer, format string) *Writer {
+	gz := gzip.NewWriter(w)
+	return &Writer{gz: gz, format: format, enc: json.NewEncoder(gz)}
+}
+
+func (w *Writer) Write(n store.Name) error {
+	if w.format == NDJSON { return w.enc.Encode(n) }
+	n.Tenant = "" // restores go to the restoring tenant
+	b, err := bson.Marshal(n)
+	if err != nil { return err }
+	_, err = w.gz.Write(b)
+	return err
+}
+
+// Close finishes the dump; it doesn't close the io.Writer under it.
+func (w *Writer) Close() error { return w.gz.Close() }
+
+// Reader reads the names back from a dump.
+type Reader struct {
+	format string
+	br     *bufio.Reader
+	record int
+}
+
+// NewReader fails if r isn't gzipped.
+func NewReader(r io.Reader, format string) (*Reader, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil { return nil, fmt.Errorf("not a gzipped dump: %w", err) }
+	return &Reader{format: format, br: bufio.NewReaderSize(gz, 64<<10)}, nil
+}
+
+// Record is the number of the record Next last read, from 1: its line, in
+// NDJSON.
+func (r *Reader) Record() int { return r.record }
+
+// Next returns the next name in the dump, io.EOF after the last. Any other
+// error means the dump is damaged and can't be read further.
+func (r *Reader) Next() (store.Name, error) {
+	var n store.Name
+	if r.format == BSON {
+		var size [4]byte
+		if _, err := io.ReadFull(r.br, size[:]); err != nil {
+			if errors.Is(err, io.ErrUnexpectedEOF) { return n, fmt.Errorf("record %d: truncated", r.record+1) }
+			return n, err
+		}
+		r.record++
+		l := binary.LittleEndian.Uint32(size[:])
+		if l < 5 || l > maxRecord { return n, fmt.Errorf("record %d: a document can't be %d bytes", r.record, l) }
+		doc := make([]byte, l)
+		copy(doc, size[:])
+		if _, err := io.ReadFull(r.br, doc[4:]); err != nil { return n, fmt.Errorf("record %d: truncated", r.record) }
+		if err := bson.Unmarshal(doc, &n); err != nil { return n, fmt.Errorf("record %d: %w", r.record, err) }
+		return n, nil
+	}
+	for {
+		line, err := r.br.ReadSlice('\n')
+		if errors.Is(err, bufio.ErrBufferFull) {
+			line, err = readLong(r.br, line)
+		}
+		if len(line) == 0 && err != nil { return n, err }
+		if err != nil && !errors.Is(err, io.EOF) { return n, err }
+		r.record++
+		if strings.TrimSpace(string(line)) == "" { continue }
+		if err := json.Unmarshal(line, &n); err != nil { return n, fmt.Errorf("record %d: %w", r.record, err) }
+		return n, nil
+	}
+}
+
+// readLong finishes reading a line longer than the buffer, up to maxRecord.
+func readLong(br *bufio.Reader, start []byte) ([]byte, error) {
+	line := append([]byte(nil), start...)
+	for {
+		more, err := br.ReadSlice('\n')
+		line = append(line, more...)
+		if len(line) > maxRecord { return nil, fmt.Errorf("a line is longer than %d bytes", maxRecord) }
+		if !errors.Is(err, bufio.ErrBufferFull) { return line, err }
+	}
+}
+
+// Dir keeps dumps as files under a directory.
+type Dir string
+
+func (d Dir) path(key string) string { return filepath.Join(string(d), filepath.FromSlash(key)) }
+
+func (d Dir) Put(_ context.Context, key string, r io.Reader) error {
+	path := d.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil { return err }
+	f, err := os.CreateTemp(filepath.Dir(path), ".partial-*")
+	if err != nil { return err }
+	defer os.Remove(f.Name()) // once renamed, there's nothing to remove
+	_, err = io.Copy(f, r)
+	if err == nil { err = f.Sync() }
+	if cerr := f.Close(); err == nil { err = cerr }
+	if err != nil { return err }
+	return os.Rename(f.Name(), path)
+}
+
+func (d Dir) Get(_ context.Context, key string) (io.ReadCloser, error) {
+	f, err := os.Open(d.path(key))
+	if errors.Is(err, os.ErrNotExist) { return nil, ErrNotFound }
+	return f, err
+}
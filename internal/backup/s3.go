@@ -0,0 +1,154 @@
+package backup
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// S3 keeps dumps as objects in a bucket of S3 or any store speaking its
+// API (MinIO, R2, Ceph...), addressed path-style, with requests signed
+// with AWS Signature Version 4.
+type S3 struct {
+	endpoint  *url.URL // scheme and host
+	bucket    string
+	prefix    string // prepended to the keys, "" or ending in "/"
+	region    string
+	accessKey string
+	secretKey string
+	client    *http.Client
+}
+
+// NewS3 keeps dumps under rawURL, https://host/bucket with an optional
+// /prefix after it. An empty region is us-east-1.
+func NewS3(rawURL, region, accessKey, secretKey string) (*S3, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil { return nil, err }
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" { return nil, errors.New("must be an http(s)://host/bucket URL") }
+	bucket, prefix, _ := strings.Cut(strings.Trim(u.Path, "/"), "/")
+	if bucket == "" { return nil, errors.New("names no bucket: use http(s)://host/bucket[/prefix]") }
+	if prefix != "" { prefix += "/" }
+	if region == "" { region = "us-east-1" }
+	return &S3{
+		endpoint: &url.URL{Scheme: u.Scheme, Host: u.Host}, bucket: bucket, prefix: prefix, region: region,
+		accessKey: accessKey, secretKey: secretKey, client: &http.Client{Timeout: time.Hour},
+	}, nil
+}
+
+func (s *S3) url(key string) *url.URL {
+	u := *s.endpoint
+	u.Path = "/" + s.bucket + "/" + s.prefix + key
+	return &u
+}
+
+// Put spools r to a temporary file first: S3 wants the length and, for the
+// signature, the hash of a body before it is sent.
+func (s *S3) Put(ctx context.Context, key string, r io.Reader) error {
+	f, err := os.CreateTemp("", "backup-*")
+	if err != nil { return err }
+	defer func() { f.Close(); os.Remove(f.Name()) }()
+	h := sha256.New()
+	size, err := io.Copy(io.MultiWriter(f, h), r)
+	if err != nil { return err }
+	if _, err := f.Seek(0, io.SeekStart); err != nil { return err }
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, s.url(key).String(), f)
+	if err != nil { return err }
+	req.ContentLength = size
+	req.Header.Set("Content-Type", "application/gzip")
+	resp, err := s.do(req, hex.EncodeToString(h.Sum(nil)))
+	if err != nil { return err }
+	resp.Body.Close()
+	return nil
+}
+
+func (s *S3) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url(key).String(), nil)
+	if err != nil { return nil, err }
+	resp, err := s.do(req, emptySHA256)
+	if err != nil { return nil, err }
+	return resp.Body, nil
+}
+
+// do signs and sends req, turning answers other than 2xx into errors.
+func (s *S3) do(req *http.Request, payloadHash string) (*http.Response, error) {
+	sign(req, payloadHash, s.region, s.accessKey, s.secretKey, time.Now())
+	resp, err := s.client.Do(req)
+	if err != nil { return nil, err }
+	if resp.StatusCode/100 == 2 { return resp, nil }
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound { return nil, ErrNotFound }
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<10))
+	return nil, fmt.Errorf("s3 %s %s: %s: %s", req.Method, req.URL.Path, resp.Status, strings.TrimSpace(string(body)))
+}
+
+var emptySHA256 = hex.EncodeToString(sha256.New().Sum(nil))
+
+// sign adds the headers of AWS Signature Version 4 to req, signing its
+// host and every header it has.
+func sign(req *http.Request, payloadHash, region, accessKey, secretKey string, now time.Time) {
+	stamp := now.UTC().Format("20060102T150405Z")
+	day := stamp[:8]
+	req.Header.Set("X-Amz-Date", stamp)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header { headers[strings.ToLower(k)] = strings.TrimSpace(strings.Join(v, ",")) }
+	names := make([]string, 0, len(headers))
+	for k := range headers { names = append(names, k) }
+	sort.Strings(names)
+	var canonHeaders strings.Builder
+	for _, k := range names { canonHeaders.WriteString(k + ":" + headers[k] + "\n") }
+	signed := strings.Join(names, ";")
+
+	canonical := strings.Join([]string{req.Method, escapePath(req.URL.Path), canonicalQuery(req.URL.Query()), canonHeaders.String(), signed, payloadHash}, "\n")
+	scope := day + "/" + region + "/s3/aws4_request"
+	toSign := "AWS4-HMAC-SHA256\n" + stamp + "\n" + scope + "\n" + hexSHA256(canonical)
+
+	key := hmacSHA256([]byte("AWS4"+secretKey), day)
+	for _, part := range []string{region, "s3", "aws4_request"} { key = hmacSHA256(key, part) }
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		accessKey, scope, signed, hex.EncodeToString(hmacSHA256(key, toSign))))
+}
+
+// escapePath URI-encodes each segment of path as SigV4 wants: everything
+// but unreserved characters.
+func escapePath(path string) string {
+	segs := strings.Split(path, "/")
+	for i, seg := range segs { segs[i] = escape(seg) }
+	return strings.Join(segs, "/")
+}
+
+func canonicalQuery(q url.Values) string {
+	var pairs []string
+	for k, vs := range q {
+		for _, v := range vs { pairs = append(pairs, escape(k)+"="+escape(v)) }
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+func escape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+func hexSHA256(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	m := hmac.New(sha256.New, key)
+	m.Write([]byte(data))
+	return m.Sum(nil)
+}
@@ -0,0 +1,103 @@
+// Package bus publishes the changes to names to a message bus, a Kafka
+// topic or a NATS subject, so that other systems can react to them
+// without polling the API. Each write that succeeds is published just
+// after it, as JSON or as a CloudEvent (structured mode), keyed by the
+// name's ID so that Kafka keeps each name's changes in order.
+//
+// Publishing is best effort, like the audit log: a message the bus won't
+// take is logged and counted, not returned, since the write it tells of
+// has happened regardless. Consumers that can't miss a change should
+// reconcile against the API now and then, or the server run with OUTBOX,
+// which publishes every change at least once (see package outbox).
+package bus
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"app/internal/metrics"
+	"app/internal/store"
+)
+
+// The formats messages are published in.
+const (
+	FormatJSON        = "json"
+	FormatCloudEvents = "cloudevents"
+)
+
+// Message is one message: its value and the key a bus may partition by.
+type Message struct {
+	Key   string
+	Value []byte
+}
+
+// Publisher sends messages to the topic or subject it was opened for.
+type Publisher interface {
+	// Publish returns once the bus has all of msgs, in order.
+	Publish(ctx context.Context, msgs ...Message) error
+	Close() error
+}
+
+// Open connects to the bus of kind, kafka or nats, at addr: a
+// comma-separated list of brokers for Kafka, a nats:// URL for NATS.
+func Open(ctx context.Context, kind, addr, topic string) (Publisher, error) {
+	switch kind {
+	case "kafka":
+		return openKafka(ctx, addr, topic)
+	case "nats":
+		return openNATS(ctx, addr, topic)
+	}
+	return nil, fmt.Errorf("unknown bus %q", kind)
+}
+
+// Event is a change to a name, as published in the json format. Name is
+// the document after the change, or before it for removed.
+type Event struct {
+	ID        string             `json:"id"`
+	Type      string             `json:"type"` // created, updated, deleted, restored or removed
+	Tenant    string             `json:"tenant"`
+	NameID    primitive.ObjectID `json:"name_id"`
+	Name      *store.Name        `json:"name"`
+	At        time.Time          `json:"at"`
+	RequestID string             `json:"request_id,omitempty"`
+}
+
+// cloudEvent is Event in the CloudEvents 1.0 JSON format, with the Event
+// itself as its data.
+type cloudEvent struct {
+	SpecVersion     string    `json:"specversion"`
+	ID              string    `json:"id"`
+	Source          string    `json:"source"`
+	Type            string    `json:"type"`
+	Subject         string    `json:"subject"`
+	Time            time.Time `json:"time"`
+	DataContentType string    `json:"datacontenttype"`
+	Tenant          string    `json:"tenant"` // an extension attribute
+	Data            Event     `json:"data"`
+}
+
+// encode renders e in format.
+func encode(format string, e Event) ([]byte, error) {
+	if format != FormatCloudEvents { return json.Marshal(e) }
+	return json.Marshal(cloudEvent{
+		SpecVersion: "1.0", ID: e.ID, Source: "/api/v1/names", Type: "names-api.name." + e.Type, Subject: e.NameID.Hex(),
+		Time: e.At, DataContentType: "application/json", Tenant: e.Tenant, Data: e,
+	})
+}
+
+// PublishEvent publishes the outbox's event e in format, with e's ID, the
+// same on each copy of it, as the outbox may publish one twice.
+func PublishEvent(ctx context.Context, pub Publisher, format string, e store.OutboxEvent) error {
+	value, err := encode(format, Event{ID: e.ID.Hex(), Type: e.Type, Tenant: e.Tenant, NameID: e.NameID, Name: e.Name, At: e.At, RequestID: e.RequestID})
+	if err != nil { return err }
+	if err := pub.Publish(ctx, Message{Key: e.NameID.Hex(), Value: value}); err != nil {
+		metrics.BusPublished("failed", 1)
+		return err
+	}
+	metrics.BusPublished("ok", 1)
+	return nil
+}
@@ -0,0 +1,355 @@
+package bus
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"hash/fnv"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// kafkaPublisher produces to a Kafka topic, speaking just enough of the
+// protocol for that: Metadata (v1) to learn each partition's leader, and
+// Produce (v3, acks=1) with uncompressed v2 record batches. A message goes
+// to the partition its key hashes to (FNV-1a, not the Java client's
+// murmur2), so each name's changes stay in order. Plaintext only; any
+// error forgets the metadata and the connections, and the messages not
+// yet produced are tried once more with fresh ones.
+type kafkaPublisher struct {
+	brokers []string // to bootstrap from
+	topic   string
+
+	mu      sync.Mutex
+	nodes   map[int32]string // broker addresses by node ID
+	leaders []int32          // each partition's leader; nil until known
+	conns   map[int32]*kafkaConn
+	next    int // partition for the next message without a key
+}
+
+// The Kafka API keys used.
+const (
+	kafkaProduce  = 0
+	kafkaMetadata = 3
+)
+
+// openKafka bootstraps from the brokers in addr, host:port separated by
+// commas, to produce to topic.
+func openKafka(ctx context.Context, addr, topic string) (*kafkaPublisher, error) {
+	p := &kafkaPublisher{topic: topic, conns: map[int32]*kafkaConn{}}
+	for _, b := range strings.Split(addr, ",") {
+		if b = strings.TrimSpace(b); b == "" { continue }
+		if _, _, err := net.SplitHostPort(b); err != nil { return nil, fmt.Errorf("bus url: broker %q: want host:port", b) }
+		p.brokers = append(p.brokers, b)
+	}
+	if len(p.brokers) == 0 { return nil, errors.New("bus url: no kafka brokers") }
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.metadata(ctx); err != nil { return nil, err }
+	return p, nil
+}
+
+func (p *kafkaPublisher) Publish(ctx context.Context, msgs ...Message) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for retry := true; len(msgs) > 0; retry = false {
+		var err error
+		if msgs, err = p.produce(ctx, msgs); err == nil { return nil }
+		p.reset()
+		if !retry || ctx.Err() != nil { return err }
+	}
+	return nil
+}
+
+// produce sends msgs to their partitions' leaders, one request per leader,
+// and returns the ones not produced with the first error.
+func (p *kafkaPublisher) produce(ctx context.Context, msgs []Message) ([]Message, error) {
+	if p.leaders == nil {
+		if err := p.metadata(ctx); err != nil { return msgs, err }
+	}
+	// Messages by leader, then partition, in their order.
+	byLeader := map[int32]map[int32][]int{}
+	var order []int32
+	for i, m := range msgs {
+		part := p.partition(m.Key)
+		leader := p.leaders[part]
+		if byLeader[leader] == nil { byLeader[leader] = map[int32][]int{}; order = append(order, leader) }
+		byLeader[leader][part] = append(byLeader[leader][part], i)
+	}
+
+	sent := make([]bool, len(msgs))
+	var err error
+	for _, leader := range order {
+		if err = p.produceTo(ctx, leader, msgs, byLeader[leader]); err != nil { break }
+		for _, idx := range byLeader[leader] {
+			for _, i := range idx { sent[i] = true }
+		}
+	}
+	if err == nil { return nil, nil }
+	var left []Message
+	for i, m := range msgs {
+		if !sent[i] { left = append(left, m) }
+	}
+	return left, err
+}
+
+// partition returns the partition for key: by its hash, or round robin if
+// it has none.
+func (p *kafkaPublisher) partition(key string) int32 {
+	n := uint32(len(p.leaders))
+	if key == "" { p.next++; return int32(uint32(p.next) % n) }
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int32(h.Sum32() % n)
+}
+
+// produceTo sends the messages of parts to leader in one Produce request.
+func (p *kafkaPublisher) produceTo(ctx context.Context, leader int32, msgs []Message, parts map[int32][]int) error {
+	c, err := p.conn(ctx, leader)
+	if err != nil { return err }
+
+	timeout := 10 * time.Second
+	if dl, ok := ctx.Deadline(); ok { timeout = time.Until(dl) }
+	var w kwriter
+	w.int16(-1) // no transactional ID
+	w.int16(1)  // acks from the leader
+	w.int32(int32(timeout.Milliseconds()))
+	w.int32(1)
+	w.string(p.topic)
+	w.int32(int32(len(parts)))
+	now := time.Now()
+	for part, idx := range parts {
+		batch := make([]Message, len(idx))
+		for j, i := range idx { batch[j] = msgs[i] }
+		w.int32(part)
+		w.bytes(recordBatch(batch, now))
+	}
+
+	resp, err := c.roundTrip(ctx, kafkaProduce, 3, w.b)
+	if err != nil { return err }
+	r := kreader{b: resp}
+	for range r.count() {
+		r.string()
+		for range r.count() {
+			part, code := r.int32(), r.int16()
+			r.int64() // base offset
+			r.int64() // log append time
+			if code != 0 && r.err == nil { return fmt.Errorf("kafka produce to %s/%d: error code %d", p.topic, part, code) }
+		}
+	}
+	return r.err
+}
+
+// recordBatch encodes msgs as a v2 record batch, timestamped now.
+func recordBatch(msgs []Message, now time.Time) []byte {
+	ts := now.UnixMilli()
+	var recs []byte
+	for i, m := range msgs {
+		var rec []byte
+		rec = append(rec, 0)                     // attributes
+		rec = binary.AppendVarint(rec, 0)        // timestamp delta
+		rec = binary.AppendVarint(rec, int64(i)) // offset delta
+		if m.Key == "" {
+			rec = binary.AppendVarint(rec, -1)
+		} else {
+			rec = binary.AppendVarint(rec, int64(len(m.Key)))
+			rec = append(rec, m.Key...)
+		}
+		rec = binary.AppendVarint(rec, int64(len(m.Value)))
+		rec = append(rec, m.Value...)
+		rec = binary.AppendVarint(rec, 0) // headers
+		recs = binary.AppendVarint(recs, int64(len(rec)))
+		recs = append(recs, rec...)
+	}
+
+	// What the CRC covers: attributes to the end.
+	var body kwriter
+	body.int16(0) // attributes: no compression, create time
+	body.int32(int32(len(msgs) - 1))
+	body.int64(ts)
+	body.int64(ts)
+	body.int64(-1) // producer ID
+	body.int16(-1) // producer epoch
+	body.int32(-1) // base sequence
+	body.int32(int32(len(msgs)))
+	body.b = append(body.b, recs...)
+
+	var w kwriter
+	w.int64(0)                              // base offset
+	w.int32(int32(4 + 1 + 4 + len(body.b))) // length, after this field
+	w.int32(-1)                             // partition leader epoch
+	w.int8(2)                               // magic
+	w.int32(int32(crc32.Checksum(body.b, crc32.MakeTable(crc32.Castagnoli))))
+	w.b = append(w.b, body.b...)
+	return w.b
+}
+
+// metadata learns the brokers and the leader of each of the topic's
+// partitions from the first bootstrap broker that answers.
+func (p *kafkaPublisher) metadata(ctx context.Context) error {
+	var w kwriter
+	w.int32(1)
+	w.string(p.topic)
+	var err error
+	for _, b := range p.brokers {
+		var c *kafkaConn
+		if c, err = dialKafka(ctx, b); err != nil { continue }
+		var resp []byte
+		resp, err = c.roundTrip(ctx, kafkaMetadata, 1, w.b)
+		c.Close()
+		if err == nil { return p.parseMetadata(resp) }
+	}
+	return err
+}
+
+func (p *kafkaPublisher) parseMetadata(resp []byte) error {
+	r := kreader{b: resp}
+	nodes := map[int32]string{}
+	for range r.count() {
+		id, host, port := r.int32(), r.string(), r.int32()
+		r.nullableString() // rack
+		nodes[id] = net.JoinHostPort(host, strconv.Itoa(int(port)))
+	}
+	r.int32() // controller
+	var leaders []int32
+	for range r.count() {
+		code, name := r.int16(), r.string()
+		r.int8() // internal
+		if name == p.topic && code != 0 && r.err == nil { return fmt.Errorf("kafka metadata for %s: error code %d", p.topic, code) }
+		for range r.count() {
+			code, part, leader := r.int16(), r.int32(), r.int32()
+			for range r.count() { r.int32() } // replicas
+			for range r.count() { r.int32() } // in sync
+			if name != p.topic || r.err != nil { continue }
+			if code != 0 || leader < 0 { return fmt.Errorf("kafka metadata for %s/%d: error code %d, leader %d", p.topic, part, code, leader) }
+			for int(part) >= len(leaders) { leaders = append(leaders, -1) }
+			leaders[part] = leader
+		}
+	}
+	if r.err != nil { return fmt.Errorf("kafka metadata: %w", r.err) }
+	if len(leaders) == 0 { return fmt.Errorf("kafka topic %s has no partitions", p.topic) }
+	for part, leader := range leaders {
+		if _, ok := nodes[leader]; !ok { return fmt.Errorf("kafka metadata for %s/%d: no broker %d", p.topic, part, leader) }
+	}
+	p.nodes, p.leaders = nodes, leaders
+	return nil
+}
+
+// conn returns the connection to node, dialing it if need be.
+func (p *kafkaPublisher) conn(ctx context.Context, node int32) (*kafkaConn, error) {
+	if c := p.conns[node]; c != nil { return c, nil }
+	c, err := dialKafka(ctx, p.nodes[node])
+	if err != nil { return nil, err }
+	p.conns[node] = c
+	return c, nil
+}
+
+// reset forgets the metadata and closes the connections.
+func (p *kafkaPublisher) reset() {
+	for id, c := range p.conns { c.Close(); delete(p.conns, id) }
+	p.leaders = nil
+}
+
+func (p *kafkaPublisher) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.reset()
+	return nil
+}
+
+// kafkaConn is a connection to one broker.
+type kafkaConn struct {
+	net.Conn
+	r    *bufio.Reader
+	corr int32
+}
+
+func dialKafka(ctx context.Context, addr string) (*kafkaConn, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil { return nil, fmt.Errorf("connecting to kafka: %w", err) }
+	return &kafkaConn{Conn: conn, r: bufio.NewReader(conn)}, nil
+}
+
+// roundTrip sends a request of api at version with body and returns the
+// body of the response.
+func (c *kafkaConn) roundTrip(ctx context.Context, api, version int16, body []byte) ([]byte, error) {
+	dl, ok := ctx.Deadline()
+	if !ok { dl = time.Now().Add(10 * time.Second) }
+	c.SetDeadline(dl)
+
+	c.corr++
+	var w kwriter
+	w.int32(0) // the size, filled in below
+	w.int16(api)
+	w.int16(version)
+	w.int32(c.corr)
+	w.string("names-api")
+	w.b = append(w.b, body...)
+	binary.BigEndian.PutUint32(w.b, uint32(len(w.b)-4))
+	if _, err := c.Write(w.b); err != nil { return nil, fmt.Errorf("kafka: %w", err) }
+
+	var head [8]byte
+	if _, err := io.ReadFull(c.r, head[:]); err != nil { return nil, fmt.Errorf("kafka: %w", err) }
+	size, corr := binary.BigEndian.Uint32(head[:4]), int32(binary.BigEndian.Uint32(head[4:]))
+	if corr != c.corr { return nil, fmt.Errorf("kafka: answer %d to request %d", corr, c.corr) }
+	if size < 4 || size > 64<<20 { return nil, fmt.Errorf("kafka: answer of %d bytes", size) }
+	resp := make([]byte, size-4)
+	if _, err := io.ReadFull(c.r, resp); err != nil { return nil, fmt.Errorf("kafka: %w", err) }
+	return resp, nil
+}
+
+// kwriter appends Kafka's big-endian primitives.
+type kwriter struct{ b []byte }
+
+func (w *kwriter) int8(v int8)   { w.b = append(w.b, byte(v)) }
+func (w *kwriter) int16(v int16) { w.b = binary.BigEndian.AppendUint16(w.b, uint16(v)) }
+func (w *kwriter) int32(v int32) { w.b = binary.BigEndian.AppendUint32(w.b, uint32(v)) }
+func (w *kwriter) int64(v int64) { w.b = binary.BigEndian.AppendUint64(w.b, uint64(v)) }
+func (w *kwriter) string(s string) { w.int16(int16(len(s))); w.b = append(w.b, s...) }
+func (w *kwriter) bytes(b []byte)  { w.int32(int32(len(b))); w.b = append(w.b, b...) }
+
+// kreader reads them back; past the end it reads zeros and sets err.
+type kreader struct {
+	b   []byte
+	err error
+}
+
+func (r *kreader) take(n int) []byte {
+	if n < 0 || n > len(r.b) {
+		if r.err == nil { r.err = io.ErrUnexpectedEOF }
+		r.b = nil
+		return make([]byte, max(n, 0))
+	}
+	v := r.b[:n]
+	r.b = r.b[n:]
+	return v
+}
+
+func (r *kreader) int8() int8   { return int8(r.take(1)[0]) }
+func (r *kreader) int16() int16 { return int16(binary.BigEndian.Uint16(r.take(2))) }
+func (r *kreader) int32() int32 { return int32(binary.BigEndian.Uint32(r.take(4))) }
+func (r *kreader) int64() int64 { return int64(binary.BigEndian.Uint64(r.take(8))) }
+func (r *kreader) string() string { return string(r.take(int(r.int16()))) }
+func (r *kreader) bytes() []byte  { return r.take(int(r.int32())) }
+
+// count reads the length of an array, taken as 0 if there can't be as
+// many items left.
+func (r *kreader) count() int32 {
+	n := r.int32()
+	if n < 0 || int(n) > len(r.b) { return 0 }
+	return n
+}
+
+func (r *kreader) nullableString() string {
+	n := r.int16()
+	if n < 0 { return "" }
+	return string(r.take(int(n)))
+}
@@ -0,0 +1,194 @@
+package bus
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeKafka is a broker, node 1, leading both partitions of its one topic.
+// It keeps the records produced to each partition, as "key=value".
+type fakeKafka struct {
+	t     *testing.T
+	addr  string
+	topic string
+
+	mu      sync.Mutex
+	records map[int32][]string
+}
+
+func newFakeKafka(t *testing.T, topic string) *fakeKafka {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil { t.Fatal(err) }
+	t.Cleanup(func() { l.Close() })
+	k := &fakeKafka{t: t, addr: l.Addr().String(), topic: topic, records: map[int32][]string{}}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil { return }
+			go k.serve(conn)
+		}
+	}()
+	return k
+}
+
+func (k *fakeKafka) serve(conn net.Conn) {
+	defer conn.Close()
+	for {
+		var size [4]byte
+		if _, err := io.ReadFull(conn, size[:]); err != nil { return }
+		req := make([]byte, binary.BigEndian.Uint32(size[:]))
+		if _, err := io.ReadFull(conn, req); err != nil { return }
+		r := kreader{b: req}
+		api, version, corr, client := r.int16(), r.int16(), r.int32(), r.string()
+		if client != "names-api" { k.t.Errorf("client ID %q", client) }
+
+		var w kwriter
+		w.int32(0)
+		w.int32(corr)
+		switch {
+		case api == kafkaMetadata && version == 1:
+			k.metadata(&r, &w)
+		case api == kafkaProduce && version == 3:
+			k.produce(&r, &w)
+		default:
+			k.t.Errorf("request for API %d v%d", api, version)
+			return
+		}
+		if r.err != nil { k.t.Errorf("request: %v", r.err) }
+		binary.BigEndian.PutUint32(w.b, uint32(len(w.b)-4))
+		if _, err := conn.Write(w.b); err != nil { return }
+	}
+}
+
+func (k *fakeKafka) metadata(r *kreader, w *kwriter) {
+	for range r.count() {
+		if topic := r.string(); topic != k.topic { k.t.Errorf("metadata for %q", topic) }
+	}
+	host, port, _ := net.SplitHostPort(k.addr)
+	p, _ := strconv.Atoi(port)
+	w.int32(1)
+	w.int32(1)
+	w.string(host)
+	w.int32(int32(p))
+	w.int16(-1) // no rack
+	w.int32(1)  // controller
+	w.int32(1)
+	w.int16(0)
+	w.string(k.topic)
+	w.int8(0)
+	w.int32(2)
+	for part := range int32(2) {
+		w.int16(0)
+		w.int32(part)
+		w.int32(1) // leader
+		w.int32(1)
+		w.int32(1) // replicas
+		w.int32(1)
+		w.int32(1) // in sync
+	}
+}
+
+func (k *fakeKafka) produce(r *kreader, w *kwriter) {
+	r.nullableString()
+	if acks := r.int16(); acks != 1 { k.t.Errorf("acks %d", acks) }
+	r.int32()
+	w.int32(1)
+	for range r.count() {
+		topic := r.string()
+		w.string(topic)
+		n := r.count()
+		w.int32(n)
+		for range n {
+			part := r.int32()
+			recs, err := decodeBatch(r.bytes())
+			if err != nil { k.t.Errorf("partition %d: %v", part, err) }
+			k.mu.Lock()
+			k.records[part] = append(k.records[part], recs...)
+			k.mu.Unlock()
+			w.int32(part)
+			w.int16(0)
+			w.int64(0)
+			w.int64(-1)
+		}
+	}
+	w.int32(0) // throttle
+}
+
+// decodeBatch checks a v2 record batch and returns its records.
+func decodeBatch(b []byte) ([]string, error) {
+	r := kreader{b: b}
+	r.int64()
+	if n := r.int32(); int(n) != len(r.b) { return nil, fmt.Errorf("batch length %d of %d", n, len(r.b)) }
+	r.int32()
+	if magic := r.int8(); magic != 2 { return nil, fmt.Errorf("magic %d", magic) }
+	crc := uint32(r.int32())
+	if sum := crc32.Checksum(r.b, crc32.MakeTable(crc32.Castagnoli)); sum != crc { return nil, fmt.Errorf("crc %x, want %x", crc, sum) }
+	r.int16()
+	last := r.int32()
+	r.take(8 + 8 + 8 + 2 + 4)
+	n := r.int32()
+	if last != n-1 { return nil, fmt.Errorf("last offset delta %d of %d records", last, n) }
+
+	varint := func() int64 {
+		v, size := binary.Varint(r.b)
+		if size <= 0 { r.err = io.ErrUnexpectedEOF; return 0 }
+		r.b = r.b[size:]
+		return v
+	}
+	var out []string
+	for i := range n {
+		varint() // length
+		r.int8()
+		varint()
+		if delta := varint(); delta != int64(i) { return nil, fmt.Errorf("offset delta %d of record %d", delta, i) }
+		key := string(r.take(int(varint())))
+		value := string(r.take(int(varint())))
+		varint() // headers
+		out = append(out, key+"="+value)
+	}
+	if r.err == nil && len(r.b) > 0 { return nil, fmt.Errorf("%d bytes after the records", len(r.b)) }
+	return out, r.err
+}
+
+func TestKafka(t *testing.T) {
+	k := newFakeKafka(t, "names.events")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	p, err := Open(ctx, "kafka", "localhost:1, "+k.addr, "names.events")
+	if err != nil { t.Fatal(err) }
+	defer p.Close()
+
+	var msgs []Message
+	for i := range 6 { msgs = append(msgs, Message{Key: fmt.Sprintf("k%d", i%3), Value: []byte(strconv.Itoa(i))}) }
+	if err := p.Publish(ctx, msgs...); err != nil { t.Fatal(err) }
+	if err := p.Publish(ctx, Message{Key: "k0", Value: []byte("6")}); err != nil { t.Fatal(err) }
+
+	// Each key lands on one partition, in order.
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	where := map[string]int32{}
+	total := 0
+	for part, recs := range k.records {
+		total += len(recs)
+		for _, rec := range recs {
+			key := rec[:2]
+			if p, ok := where[key]; ok && p != part { t.Fatalf("key %s on partitions %d and %d", key, p, part) }
+			where[key] = part
+		}
+	}
+	if total != 7 { t.Fatalf("records: %v", k.records) }
+	var k0 []string
+	for _, rec := range k.records[where["k0"]] {
+		if rec[:2] == "k0" { k0 = append(k0, rec) }
+	}
+	if fmt.Sprint(k0) != "[k0=0 k0=3 k0=6]" { t.Fatalf("k0: %v", k0) }
+}
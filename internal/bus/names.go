@@ -0,0 +1,164 @@
+package bus
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"app/internal/metrics"
+	"app/internal/requestid"
+	"app/internal/store"
+	"app/internal/tenant"
+)
+
+// Names wraps a NameStore and publishes every successful write to it.
+// Reads pass straight through.
+type Names struct {
+	store.NameStore
+	pub    Publisher
+	format string
+}
+
+func NewNames(s store.NameStore, pub Publisher, format string) *Names {
+	return &Names{NameStore: s, pub: pub, format: format}
+}
+
+// change is a name written to, with its document for the event.
+type change struct {
+	id   primitive.ObjectID
+	name *store.Name
+}
+
+// publish sends an event of typ about each of changes, even if ctx has
+// been canceled meanwhile, once the transaction it may be part of has
+// committed.
+func (b *Names) publish(ctx context.Context, typ string, changes ...change) {
+	if len(changes) == 0 { return }
+	now := time.Now().UTC()
+	msgs := make([]Message, 0, len(changes))
+	for _, c := range changes {
+		e := Event{ID: primitive.NewObjectID().Hex(), Type: typ, Tenant: tenant.FromContext(ctx), NameID: c.id, Name: c.name, At: now, RequestID: requestid.FromContext(ctx)}
+		value, err := encode(b.format, e)
+		if err != nil { slog.ErrorContext(ctx, "encoding a bus message", "err", err); continue }
+		msgs = append(msgs, Message{Key: c.id.Hex(), Value: value})
+	}
+	store.AfterCommit(ctx, func(ctx context.Context) {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+		defer cancel()
+		if err := b.pub.Publish(ctx, msgs...); err != nil {
+			metrics.BusPublished("failed", len(msgs))
+			slog.ErrorContext(ctx, "publishing name changes", "messages", len(msgs), "err", err)
+			return
+		}
+		metrics.BusPublished("ok", len(msgs))
+	})
+}
+
+// lookup returns the current documents of ids, soft-deleted ones included;
+// a failure costs the events their name.
+func (b *Names) lookup(ctx context.Context, ids ...primitive.ObjectID) map[primitive.ObjectID]store.Name {
+	docs, err := b.NameStore.Lookup(ctx, ids)
+	if err != nil { slog.ErrorContext(ctx, "reading names for the bus", "err", err) }
+	return docs
+}
+
+func (b *Names) Create(ctx context.Context, n *store.Name) error {
+	if err := b.NameStore.Create(ctx, n); err != nil { return err }
+	after := *n
+	b.publish(ctx, "created", change{n.ID, &after})
+	return nil
+}
+
+func (b *Names) CreateIfAbsent(ctx context.Context, n *store.Name) (bool, error) {
+	created, err := b.NameStore.CreateIfAbsent(ctx, n)
+	if created { after := *n; b.publish(ctx, "created", change{n.ID, &after}) }
+	return created, err
+}
+
+func (b *Names) Upsert(ctx context.Context, n store.Name, ifVersion int64) (*store.Name, store.Name, error) {
+	before, after, err := b.NameStore.Upsert(ctx, n, ifVersion)
+	if err != nil { return before, after, err }
+	typ := "updated"
+	if before == nil { typ = "created" }
+	b.publish(ctx, typ, change{after.ID, &after})
+	return before, after, nil
+}
+
+func (b *Names) Update(ctx context.Context, id primitive.ObjectID, n store.Name, ifVersion int64) (store.Name, error) {
+	after, err := b.NameStore.Update(ctx, id, n, ifVersion)
+	if err == nil { b.publish(ctx, "updated", change{id, &after}) }
+	return after, err
+}
+
+func (b *Names) Patch(ctx context.Context, id primitive.ObjectID, p store.NamePatch, ifVersion int64) (store.Name, error) {
+	after, err := b.NameStore.Patch(ctx, id, p, ifVersion)
+	if err == nil { b.publish(ctx, "updated", change{id, &after}) }
+	return after, err
+}
+
+func (b *Names) SoftDelete(ctx context.Context, id primitive.ObjectID, ifVersion int64) error {
+	if err := b.NameStore.SoftDelete(ctx, id, ifVersion); err != nil { return err }
+	b.publish(ctx, "deleted", found(b.lookup(ctx, id), id))
+	return nil
+}
+
+func (b *Names) HardDelete(ctx context.Context, id primitive.ObjectID, ifVersion int64) error {
+	before := b.lookup(ctx, id)
+	if err := b.NameStore.HardDelete(ctx, id, ifVersion); err != nil { return err }
+	b.publish(ctx, "removed", found(before, id))
+	return nil
+}
+
+func (b *Names) Restore(ctx context.Context, id primitive.ObjectID) (store.Name, error) {
+	after, err := b.NameStore.Restore(ctx, id)
+	if err == nil { b.publish(ctx, "restored", change{id, &after}) }
+	return after, err
+}
+
+func (b *Names) CreateMany(ctx context.Context, ns []store.Name) ([]error, error) {
+	errs, err := b.NameStore.CreateMany(ctx, ns)
+	if err == nil { b.publishCreated(ctx, ns, errs) }
+	return errs, err
+}
+
+func (b *Names) InsertMany(ctx context.Context, ns []store.Name) ([]error, error) {
+	errs, err := b.NameStore.InsertMany(ctx, ns)
+	if err == nil { b.publishCreated(ctx, ns, errs) }
+	return errs, err
+}
+
+// publishCreated publishes the items of a batch insert that were stored.
+func (b *Names) publishCreated(ctx context.Context, ns []store.Name, errs []error) {
+	var created []change
+	for i := range ns {
+		if i < len(errs) && errs[i] == nil { n := ns[i]; created = append(created, change{n.ID, &n}) }
+	}
+	b.publish(ctx, "created", created...)
+}
+
+func (b *Names) DeleteMany(ctx context.Context, ids []primitive.ObjectID, hard bool) (map[primitive.ObjectID]bool, error) {
+	var before map[primitive.ObjectID]store.Name
+	if hard { before = b.lookup(ctx, ids...) }
+	existed, err := b.NameStore.DeleteMany(ctx, ids, hard)
+	if err != nil { return existed, err }
+
+	var deleted []primitive.ObjectID
+	for _, id := range ids {
+		if existed[id] && !slices.Contains(deleted, id) { deleted = append(deleted, id) }
+	}
+	typ := "removed"
+	if !hard { typ, before = "deleted", b.lookup(ctx, deleted...) }
+	changes := make([]change, 0, len(deleted))
+	for _, id := range deleted { changes = append(changes, found(before, id)) }
+	b.publish(ctx, typ, changes...)
+	return existed, nil
+}
+
+// found returns the change to id with its document in docs, if any.
+func found(docs map[primitive.ObjectID]store.Name, id primitive.ObjectID) change {
+	if n, ok := docs[id]; ok { return change{id, &n} }
+	return change{id: id}
+}
@@ -0,0 +1,70 @@
+package bus
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+
+	"app/internal/store"
+	"app/internal/tenant"
+)
+
+// recorder is a Publisher that keeps what it's given, or fails.
+type recorder struct {
+	mu   sync.Mutex
+	msgs []Message
+	fail bool
+}
+
+func (r *recorder) Publish(_ context.Context, msgs ...Message) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.fail { return errors.New("bus down") }
+	r.msgs = append(r.msgs, msgs...)
+	return nil
+}
+
+func (r *recorder) Close() error { return nil }
+
+func TestNames(t *testing.T) {
+	ctx := tenant.NewContext(context.Background(), "team-a")
+	pub := &recorder{}
+	names := NewNames(store.NewMemoryNames(), pub, FormatJSON)
+
+	n := store.Name{Name: "alice"}
+	if err := names.Create(ctx, &n); err != nil { t.Fatal(err) }
+	if _, err := names.Patch(ctx, n.ID, store.NamePatch{Tags: &[]string{"vip"}}, 2); err == nil { t.Fatal("stale patch went through") }
+	if err := names.SoftDelete(ctx, n.ID, store.AnyVersion); err != nil { t.Fatal(err) }
+	if errs, err := names.CreateMany(ctx, []store.Name{{Name: "bob"}, {Name: "carol"}}); err != nil || errs[0] != nil || errs[1] != nil { t.Fatal(errs, err) }
+	// A bus that's down doesn't fail the write.
+	pub.fail = true
+	if err := names.HardDelete(ctx, n.ID, store.AnyVersion); err != nil { t.Fatal(err) }
+
+	var got []Event
+	for _, m := range pub.msgs {
+		var e Event
+		if err := json.Unmarshal(m.Value, &e); err != nil { t.Fatalf("message %s: %v", m.Value, err) }
+		if m.Key != e.NameID.Hex() || e.Tenant != "team-a" || e.ID == "" || e.Name == nil { t.Fatalf("message %q: %s", m.Key, m.Value) }
+		got = append(got, e)
+	}
+	if len(got) != 4 || got[0].Type != "created" || got[1].Type != "deleted" || got[1].Name.DeletedAt == nil || got[2].Name.Name != "bob" || got[3].Name.Name != "carol" { t.Fatalf("events: %+v", got) }
+}
+
+func TestCloudEvents(t *testing.T) {
+	n := store.Name{Name: "alice"}
+	pub := &recorder{}
+	names := NewNames(store.NewMemoryNames(), pub, FormatCloudEvents)
+	if err := names.Create(tenant.NewContext(context.Background(), "team-a"), &n); err != nil { t.Fatal(err) }
+
+	var ce struct {
+		SpecVersion, ID, Source, Type, Subject, DataContentType, Tenant string
+		Data                                                            Event
+	}
+	if len(pub.msgs) != 1 { t.Fatalf("%d messages", len(pub.msgs)) }
+	if err := json.Unmarshal(pub.msgs[0].Value, &ce); err != nil { t.Fatal(err) }
+	if ce.SpecVersion != "1.0" || ce.Type != "names-api.name.created" || ce.Source != "/api/v1/names" || ce.Subject != n.ID.Hex() || ce.ID != ce.Data.ID || ce.Tenant != "team-a" || ce.Data.Name.Name != "alice" {
+		t.Fatalf("cloud event: %s", pub.msgs[0].Value)
+	}
+}
@@ -0,0 +1,141 @@
+package bus
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// natsPublisher publishes to a NATS subject over the client protocol: a
+// CONNECT when it connects, then a PUB for each message, each batch
+// followed by a PING whose PONG means the server has them all. A
+// connection that breaks, or that the server closes for PINGs gone
+// unanswered while idle, is made anew on the next publish.
+type natsPublisher struct {
+	addr    string
+	subject string
+	connect []byte // the CONNECT line, credentials included
+
+	mu   sync.Mutex
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+// openNATS connects to the NATS server at addr, nats://[user:pass@]host[:port]
+// or nats://token@host, to publish to subject.
+func openNATS(ctx context.Context, addr, subject string) (*natsPublisher, error) {
+	u, err := url.Parse(addr)
+	if err != nil || u.Scheme != "nats" || u.Hostname() == "" { return nil, fmt.Errorf("bus url %q: want nats://host[:port]", addr) }
+	host := u.Host
+	if u.Port() == "" { host = net.JoinHostPort(u.Hostname(), "4222") }
+
+	opts := map[string]any{"verbose": false, "pedantic": false, "lang": "go", "version": "1", "name": "names-api"}
+	if pass, ok := u.User.Password(); ok {
+		opts["user"], opts["pass"] = u.User.Username(), pass
+	} else if u.User != nil {
+		opts["auth_token"] = u.User.Username()
+	}
+	connect, _ := json.Marshal(opts)
+
+	p := &natsPublisher{addr: host, subject: subject, connect: []byte("CONNECT " + string(connect) + "\r\n")}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.dial(ctx); err != nil { return nil, err }
+	return p, nil
+}
+
+// dial connects, reads the server's INFO, and sends CONNECT and a PING to
+// learn that it was accepted.
+func (p *natsPublisher) dial(ctx context.Context) error {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", p.addr)
+	if err != nil { return fmt.Errorf("connecting to nats: %w", err) }
+	if dl, ok := ctx.Deadline(); ok { conn.SetDeadline(dl) }
+	r := bufio.NewReader(conn)
+
+	line, err := r.ReadString('\n')
+	if err != nil { conn.Close(); return fmt.Errorf("reading nats INFO: %w", err) }
+	if !strings.HasPrefix(line, "INFO ") { conn.Close(); return fmt.Errorf("nats: expected INFO, got %q", strings.TrimSpace(line)) }
+	var info struct{ TLSRequired bool `json:"tls_required"` }
+	if err := json.Unmarshal([]byte(line[5:]), &info); err != nil { conn.Close(); return fmt.Errorf("nats INFO: %w", err) }
+	if info.TLSRequired { conn.Close(); return errors.New("nats: the server requires TLS, which isn't supported") }
+
+	if _, err := conn.Write(append(p.connect, "PING\r\n"...)); err != nil { conn.Close(); return fmt.Errorf("nats CONNECT: %w", err) }
+	p.conn, p.r = conn, r
+	if err := p.pong(); err != nil { p.drop(); return err }
+	return nil
+}
+
+// pong reads until the PONG to the last PING, answering the server's own
+// PINGs meanwhile.
+func (p *natsPublisher) pong() error {
+	for {
+		line, err := p.r.ReadString('\n')
+		if err != nil { return fmt.Errorf("reading from nats: %w", err) }
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "PONG":
+			return nil
+		case line == "PING":
+			if _, err := p.conn.Write([]byte("PONG\r\n")); err != nil { return fmt.Errorf("nats: %w", err) }
+		case strings.HasPrefix(line, "-ERR"):
+			return fmt.Errorf("nats: %s", strings.TrimSpace(strings.TrimPrefix(line, "-ERR")))
+		}
+		// +OK and INFO updates are of no interest.
+	}
+}
+
+// drop closes the connection, for the next publish to make a new one.
+func (p *natsPublisher) drop() {
+	if p.conn != nil { p.conn.Close() }
+	p.conn, p.r = nil, nil
+}
+
+func (p *natsPublisher) Publish(ctx context.Context, msgs ...Message) error {
+	if len(msgs) == 0 { return nil }
+	var buf []byte
+	for _, m := range msgs {
+		buf = append(buf, "PUB "+p.subject+" "+strconv.Itoa(len(m.Value))+"\r\n"...)
+		buf = append(buf, m.Value...)
+		buf = append(buf, "\r\n"...)
+	}
+	buf = append(buf, "PING\r\n"...)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	// A connection the server has since closed shows only once written to,
+	// so a failure on one that was already open is tried again on a new one.
+	for retry := p.conn != nil; ; retry = false {
+		if p.conn == nil {
+			if err := p.dial(ctx); err != nil { return err }
+		}
+		err := p.send(ctx, buf)
+		if err == nil { return nil }
+		p.drop()
+		if !retry || ctx.Err() != nil { return err }
+	}
+}
+
+// send writes buf and waits for the PONG that ends it.
+func (p *natsPublisher) send(ctx context.Context, buf []byte) error {
+	dl, ok := ctx.Deadline()
+	if !ok { dl = time.Now().Add(10 * time.Second) }
+	p.conn.SetDeadline(dl)
+	if _, err := p.conn.Write(buf); err != nil { return fmt.Errorf("publishing to nats: %w", err) }
+	return p.pong()
+}
+
+func (p *natsPublisher) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.drop()
+	return nil
+}
@@ -0,0 +1,74 @@
+package bus
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeNATS accepts connections and sends what's published on them to pubs,
+// closing the first connection after its first PONG to a publish.
+func fakeNATS(t *testing.T, pubs chan<- string) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil { t.Fatal(err) }
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for first := true; ; first = false {
+			conn, err := l.Accept()
+			if err != nil { return }
+			go serveNATS(conn, pubs, first)
+		}
+	}()
+	return "nats://tok3n@" + l.Addr().String()
+}
+
+func serveNATS(conn net.Conn, pubs chan<- string, hangUp bool) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	conn.Write([]byte(`INFO {"server_id":"fake","max_payload":1048576}` + "\r\n"))
+	published := false
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil { return }
+		verb, args, _ := strings.Cut(strings.TrimSpace(line), " ")
+		switch verb {
+		case "CONNECT":
+			if !strings.Contains(args, `"auth_token":"tok3n"`) { conn.Write([]byte("-ERR 'Authorization Violation'\r\n")); return }
+		case "PUB":
+			subject, size, _ := strings.Cut(args, " ")
+			n, _ := strconv.Atoi(size)
+			payload := make([]byte, n+2)
+			if _, err := io.ReadFull(r, payload); err != nil { return }
+			pubs <- subject + " " + string(payload[:n])
+			published = true
+		case "PING":
+			conn.Write([]byte("PING\r\nPONG\r\n")) // a PING of its own to be answered first
+			if published && hangUp { return }
+		}
+	}
+}
+
+func TestNATS(t *testing.T) {
+	pubs := make(chan string, 10)
+	addr := fakeNATS(t, pubs)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	p, err := Open(ctx, "nats", addr, "names.events")
+	if err != nil { t.Fatal(err) }
+	defer p.Close()
+
+	if err := p.Publish(ctx, Message{Key: "a", Value: []byte(`{"n":1}`)}, Message{Key: "b", Value: []byte(`{"n":2}`)}); err != nil { t.Fatal(err) }
+	// The server hung up after that; the next publish connects again.
+	if err := p.Publish(ctx, Message{Key: "a", Value: []byte(`{"n":3}`)}); err != nil { t.Fatal(err) }
+	for _, want := range []string{`names.events {"n":1}`, `names.events {"n":2}`, `names.events {"n":3}`} {
+		if got := <-pubs; got != want { t.Fatalf("published %q, want %q", got, want) }
+	}
+
+	if _, err := Open(ctx, "nats", strings.Replace(addr, "tok3n", "wrong", 1), "names.events"); err == nil || !strings.Contains(err.Error(), "Authorization Violation") { t.Fatalf("bad token: %v", err) }
+}
@@ -0,0 +1,123 @@
+// Package cache keeps recently read names close at hand, in process memory
+// or in Redis when several replicas should share one cache.
+package cache
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Cache holds byte values under string keys for a limited time, and
+// counters that never expire.
+type Cache interface {
+	// Get reports a miss as ok == false, not as an error.
+	Get(ctx context.Context, key string) (val []byte, ok bool, err error)
+	Set(ctx context.Context, key string, val []byte, ttl time.Duration) error
+	// Counter reads the counter at key, 0 if it was never incremented.
+	Counter(ctx context.Context, key string) (int64, error)
+	Incr(ctx context.Context, key string) error
+}
+
+// Memory is a Cache local to the process, evicting the least recently used
+// entry once it holds maxEntries.
+type Memory struct {
+	mu         sync.Mutex
+	maxEntries int
+	entries    map[string]*list.Element
+	lru        *list.List // of *memoryEntry, most recently used first
+	counters   map[string]int64
+}
+
+type memoryEntry struct {
+	key     string
+	val     []byte
+	expires time.Time
+}
+
+func NewMemory(maxEntries int) *Memory {
+	return &Memory{maxEntries: maxEntries, entries: map[string]*list.Element{}, lru: list.New(), counters: map[string]int64{}}
+}
+
+func (m *Memory) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	el, ok := m.entries[key]
+	if !ok { return nil, false, nil }
+	e := el.Value.(*memoryEntry)
+	if time.Now().After(e.expires) { m.lru.Remove(el); delete(m.entries, key); return nil, false, nil }
+	m.lru.MoveToFront(el)
+	return e.val, true, nil
+}
+
+func (m *Memory) Set(ctx context.Context, key string, val []byte, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e := &memoryEntry{key: key, val: val, expires: time.Now().Add(ttl)}
+	if el, ok := m.entries[key]; ok {
+		el.Value = e
+		m.lru.MoveToFront(el)
+		return nil
+	}
+	m.entries[key] = m.lru.PushFront(e)
+	for m.lru.Len() > m.maxEntries {
+		oldest := m.lru.Back()
+		m.lru.Remove(oldest)
+		delete(m.entries, oldest.Value.(*memoryEntry).key)
+	}
+	return nil
+}
+
+func (m *Memory) Counter(ctx context.Context, key string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.counters[key], nil
+}
+
+func (m *Memory) Incr(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.counters[key]++
+	return nil
+}
+
+// Redis is a Cache shared by every replica pointed at the same server.
+type Redis struct {
+	client *redis.Client
+}
+
+// NewRedis connects to url (redis://[:password@]host:port/db) and pings it.
+func NewRedis(ctx context.Context, url string) (*Redis, error) {
+	opts, err := redis.ParseURL(url)
+	if err != nil { return nil, err }
+	r := &Redis{client: redis.NewClient(opts)}
+	if err := r.Ping(ctx); err != nil { _ = r.client.Close(); return nil, err }
+	return r, nil
+}
+
+func (r *Redis) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	val, err := r.client.Get(ctx, key).Bytes()
+	if errors.Is(err, redis.Nil) { return nil, false, nil }
+	return val, err == nil, err
+}
+
+func (r *Redis) Set(ctx context.Context, key string, val []byte, ttl time.Duration) error {
+	return r.client.Set(ctx, key, val, ttl).Err()
+}
+
+func (r *Redis) Counter(ctx context.Context, key string) (int64, error) {
+	n, err := r.client.Get(ctx, key).Int64()
+	if errors.Is(err, redis.Nil) { return 0, nil }
+	return n, err
+}
+
+func (r *Redis) Incr(ctx context.Context, key string) error { return r.client.Incr(ctx, key).Err() }
+
+// Ping checks the server answers, for readiness probes.
+func (r *Redis) Ping(ctx context.Context) error { return r.client.Ping(ctx).Err() }
+
+func (r *Redis) Close(ctx context.Context) error { return r.client.Close() }
@@ -0,0 +1,165 @@
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"app/internal/metrics"
+	"app/internal/store"
+	"app/internal/tenant"
+)
+
+// Names is a read-through cache in front of a NameStore: Get and List are
+// answered from the cache when they can be, everything else goes straight
+// to the store.
+//
+// Rather than tracking which entries a write affects, every tenant has a
+// generation counter that is part of its cache keys. Any write bumps it,
+// which orphans all of the tenant's entries at once; they expire with their
+// TTL. Writes made through other replicas are only seen once the entries
+// expire, unless the replicas share a Redis cache.
+//
+// The cache is an optimisation: when it fails, reads fall back to the store
+// and the error is only logged.
+type Names struct {
+	store.NameStore
+	cache Cache
+	ttl   time.Duration
+}
+
+func NewNames(s store.NameStore, c Cache, ttl time.Duration) *Names {
+	return &Names{NameStore: s, cache: c, ttl: ttl}
+}
+
+func genKey(tid string) string { return "names:" + tid + ":gen" }
+
+// prefix returns the key prefix of the tenant in ctx at its current
+// generation, or "" if the cache can't be used.
+func (c *Names) prefix(ctx context.Context) string {
+	tid := tenant.FromContext(ctx)
+	gen, err := c.cache.Counter(ctx, genKey(tid))
+	if err != nil { slog.WarnContext(ctx, "reading the cache generation", "err", err); return "" }
+	return "names:" + tid + ":" + strconv.FormatInt(gen, 10) + ":"
+}
+
+// through returns the value at key, loading and storing it on a miss. The
+// generation is read before the store is, so a write racing the load
+// leaves the loaded value under a generation nobody asks for any more.
+func through[T any](ctx context.Context, c *Names, key string, load func() (T, error)) (T, error) {
+	// A transaction reads its own writes, which may yet be rolled back.
+	if store.InTransaction(ctx) { return load() }
+	prefix := c.prefix(ctx)
+	if prefix == "" { return load() }
+	key = prefix + key
+
+	var v T
+	b, hit, err := c.cache.Get(ctx, key)
+	if err != nil { slog.WarnContext(ctx, "reading the cache", "err", err) }
+	if hit && json.Unmarshal(b, &v) == nil {
+		metrics.CacheLookup(true)
+		return v, nil
+	}
+	metrics.CacheLookup(false)
+
+	v, err = load()
+	if err != nil { return v, err }
+	if b, err = json.Marshal(v); err == nil { err = c.cache.Set(ctx, key, b, c.ttl) }
+	if err != nil { slog.WarnContext(ctx, "writing the cache", "err", err) }
+	return v, nil
+}
+
+// invalidate bumps the generation of the tenant in ctx. It runs whether or
+// not the write succeeded: a failed batch may still have changed some names,
+// and one canceled with its request may have gone through anyway. Inside a
+// transaction it waits for the commit, until which others read what was.
+func (c *Names) invalidate(ctx context.Context) {
+	store.AfterCommit(ctx, func(ctx context.Context) {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+		defer cancel()
+		if err := c.cache.Incr(ctx, genKey(tenant.FromContext(ctx))); err != nil {
+			slog.ErrorContext(ctx, "invalidating the cache, reads may be stale until entries expire", "err", err)
+		}
+	})
+}
+
+// ---- cached reads ----
+
+// The tenant isn't part of a name's JSON, so it's put back on the way out.
+
+func (c *Names) Get(ctx context.Context, id primitive.ObjectID) (store.Name, error) {
+	n, err := through(ctx, c, "id:"+id.Hex(), func() (store.Name, error) { return c.NameStore.Get(ctx, id) })
+	n.Tenant = tenant.FromContext(ctx)
+	return n, err
+}
+
+func (c *Names) List(ctx context.Context, opts store.ListOptions) (store.Page, error) {
+	b, _ := json.Marshal(opts)
+	sum := sha256.Sum256(b)
+	page, err := through(ctx, c, "list:"+hex.EncodeToString(sum[:16]), func() (store.Page, error) { return c.NameStore.List(ctx, opts) })
+	for i := range page.Items { page.Items[i].Tenant = tenant.FromContext(ctx) }
+	return page, err
+}
+
+// ---- writes ----
+
+func (c *Names) Create(ctx context.Context, n *store.Name) error {
+	defer c.invalidate(ctx)
+	return c.NameStore.Create(ctx, n)
+}
+
+func (c *Names) CreateIfAbsent(ctx context.Context, n *store.Name) (bool, error) {
+	defer c.invalidate(ctx)
+	return c.NameStore.CreateIfAbsent(ctx, n)
+}
+
+func (c *Names) Upsert(ctx context.Context, n store.Name, ifVersion int64) (*store.Name, store.Name, error) {
+	defer c.invalidate(ctx)
+	return c.NameStore.Upsert(ctx, n, ifVersion)
+}
+
+func (c *Names) Update(ctx context.Context, id primitive.ObjectID, n store.Name, ifVersion int64) (store.Name, error) {
+	defer c.invalidate(ctx)
+	return c.NameStore.Update(ctx, id, n, ifVersion)
+}
+
+func (c *Names) Patch(ctx context.Context, id primitive.ObjectID, p store.NamePatch, ifVersion int64) (store.Name, error) {
+	defer c.invalidate(ctx)
+	return c.NameStore.Patch(ctx, id, p, ifVersion)
+}
+
+func (c *Names) SoftDelete(ctx context.Context, id primitive.ObjectID, ifVersion int64) error {
+	defer c.invalidate(ctx)
+	return c.NameStore.SoftDelete(ctx, id, ifVersion)
+}
+
+func (c *Names) HardDelete(ctx context.Context, id primitive.ObjectID, ifVersion int64) error {
+	defer c.invalidate(ctx)
+	return c.NameStore.HardDelete(ctx, id, ifVersion)
+}
+
+func (c *Names) Restore(ctx context.Context, id primitive.ObjectID) (store.Name, error) {
+	defer c.invalidate(ctx)
+	return c.NameStore.Restore(ctx, id)
+}
+
+func (c *Names) CreateMany(ctx context.Context, ns []store.Name) ([]error, error) {
+	defer c.invalidate(ctx)
+	return c.NameStore.CreateMany(ctx, ns)
+}
+
+func (c *Names) DeleteMany(ctx context.Context, ids []primitive.ObjectID, hard bool) (map[primitive.ObjectID]bool, error) {
+	defer c.invalidate(ctx)
+	return c.NameStore.DeleteMany(ctx, ids, hard)
+}
+
+func (c *Names) InsertMany(ctx context.Context, ns []store.Name) ([]error, error) {
+	defer c.invalidate(ctx)
+	return c.NameStore.InsertMany(ctx, ns)
+}
@@ -0,0 +1,69 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"app/internal/store"
+	"app/internal/tenant"
+)
+
+// countingNames counts the reads that reach the store.
+type countingNames struct {
+	store.NameStore
+	gets, lists int
+}
+
+func (c *countingNames) Get(ctx context.Context, id primitive.ObjectID) (store.Name, error) {
+	c.gets++
+	return c.NameStore.Get(ctx, id)
+}
+
+func (c *countingNames) List(ctx context.Context, opts store.ListOptions) (store.Page, error) {
+	c.lists++
+	return c.NameStore.List(ctx, opts)
+}
+
+func TestNames(t *testing.T) {
+	ctx := context.Background()
+	under := &countingNames{NameStore: store.NewMemoryNames()}
+	s := NewNames(under, NewMemory(100), time.Minute)
+	n := store.Name{Name: "alice"}
+	if err := s.Create(ctx, &n); err != nil { t.Fatal(err) }
+
+	for range 2 {
+		if got, err := s.Get(ctx, n.ID); err != nil || got.Name != "alice" { t.Fatalf("get: %+v, %v", got, err) }
+		if page, err := s.List(ctx, store.ListOptions{Limit: 10}); err != nil || page.Total != 1 { t.Fatalf("list: %+v, %v", page, err) }
+	}
+	if under.gets != 1 || under.lists != 1 { t.Fatalf("store read %d gets, %d lists; want 1 of each", under.gets, under.lists) }
+	if _, _ = s.List(ctx, store.ListOptions{Limit: 5}); under.lists != 2 { t.Fatal("different options shared an entry") }
+
+	// A write, successful or not, makes the next reads go to the store.
+	if _, err := s.Patch(ctx, n.ID, store.NamePatch{Tags: &[]string{"vip"}}, store.AnyVersion); err != nil { t.Fatal(err) }
+	if got, _ := s.Get(ctx, n.ID); len(got.Tags) != 1 || under.gets != 2 { t.Fatalf("stale get after patch: %+v", got) }
+	_ = s.Create(ctx, &store.Name{Name: "alice"}) // duplicate
+	if _, _ = s.Get(ctx, n.ID); under.gets != 3 { t.Fatal("failed write didn't invalidate") }
+
+	// Another tenant neither sees the entries nor invalidates them.
+	other := tenant.NewContext(ctx, "team-b")
+	if _, err := s.Get(other, n.ID); err == nil { t.Fatal("get across tenants") }
+	_ = s.Create(other, &store.Name{Name: "bob"})
+	if got, _ := s.Get(ctx, n.ID); got.Tenant != tenant.Default || under.gets != 4 { t.Fatalf("after other tenant's write: %+v, %d gets", got, under.gets) }
+}
+
+func TestMemoryEvicts(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory(2)
+	_ = m.Set(ctx, "a", []byte("1"), time.Minute)
+	_ = m.Set(ctx, "b", []byte("2"), time.Minute)
+	_, _, _ = m.Get(ctx, "a") // b is now the least recently used
+	_ = m.Set(ctx, "c", []byte("3"), time.Minute)
+	if _, ok, _ := m.Get(ctx, "b"); ok { t.Fatal("b survived") }
+	if _, ok, _ := m.Get(ctx, "a"); !ok { t.Fatal("a evicted") }
+
+	_ = m.Set(ctx, "d", []byte("4"), -time.Second)
+	if _, ok, _ := m.Get(ctx, "d"); ok { t.Fatal("expired entry served") }
+}
@@ -0,0 +1,210 @@
+// Package changes keeps a change log of the writes to names, for clients
+// that sync: an offline or mobile client asks for what changed since the
+// token it was last given, applies that, and keeps the new token for next
+// time, rather than reloading every name. See Names.Since.
+//
+// Each write to a name appends a change to the log in the transaction of
+// the write, numbered per tenant in the order the writes commit. A change
+// tells which name changed and how, not what it became: Since reads the
+// names as they are when asked.
+package changes
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"app/internal/store"
+	"app/internal/tenant"
+)
+
+type Config struct {
+	Retention time.Duration // how long changes are kept; a token older than that has to reload
+	MaxWait   time.Duration // the longest a client may wait for a change
+	Poll      time.Duration // how often a waiting client looks for the changes other servers made
+}
+
+// Names wraps a NameStore and appends a change to the log for every
+// successful write to it, in the transaction of the write. Reads pass
+// straight through.
+//
+// Without transactions, as on a standalone mongod, the changes are
+// appended just after the write, and a failure to append them is logged,
+// not returned. So are those of batch inserts always, as for the outbox.
+type Names struct {
+	store.NameStore
+	tx  store.Transactor // nil without transactions
+	log store.ChangeLogStore
+	cfg Config
+
+	mu      sync.Mutex
+	waiting map[string]chan struct{} // per tenant, closed by its next write
+}
+
+func NewNames(s store.NameStore, tx store.Transactor, log store.ChangeLogStore, cfg Config) *Names {
+	return &Names{NameStore: s, tx: tx, log: log, cfg: cfg, waiting: map[string]chan struct{}{}}
+}
+
+// MaxWait is the longest Since waits for a change.
+func (c *Names) MaxWait() time.Duration { return c.cfg.MaxWait }
+
+// write runs fn, a write returning the changes it makes, and appends those
+// to the log, in one transaction if it can.
+func (c *Names) write(ctx context.Context, fn func(ctx context.Context) ([]store.Change, error)) error {
+	if c.tx != nil {
+		err := c.tx.InTransaction(ctx, func(ctx context.Context) error {
+			cs, err := fn(ctx)
+			if err != nil || len(cs) == 0 { return err }
+			if err := c.log.AppendChanges(ctx, cs); err != nil { return err }
+			store.AfterCommit(ctx, c.notify)
+			return nil
+		})
+		if !errors.Is(err, store.ErrTransactionsUnsupported) { return err }
+	}
+	cs, err := fn(ctx)
+	if err == nil { c.append(ctx, cs) }
+	return err
+}
+
+// append appends cs to the log after their write, even if ctx has been
+// canceled meanwhile.
+func (c *Names) append(ctx context.Context, cs []store.Change) {
+	if len(cs) == 0 { return }
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer cancel()
+	if err := c.log.AppendChanges(ctx, cs); err != nil { slog.ErrorContext(ctx, "appending to the change log", "changes", len(cs), "err", err); return }
+	c.notify(ctx)
+}
+
+// wake returns the channel the next write of the tenant in ctx closes.
+func (c *Names) wake(ctx context.Context) <-chan struct{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	tid := tenant.FromContext(ctx)
+	ch, found := c.waiting[tid]
+	if !found { ch = make(chan struct{}); c.waiting[tid] = ch }
+	return ch
+}
+
+// notify wakes those waiting for a change of the tenant in ctx.
+func (c *Names) notify(ctx context.Context) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	tid := tenant.FromContext(ctx)
+	if ch, found := c.waiting[tid]; found { close(ch); delete(c.waiting, tid) }
+}
+
+func change(typ string, id primitive.ObjectID) store.Change {
+	return store.Change{Type: typ, NameID: id, At: time.Now().UTC()}
+}
+
+func (c *Names) Create(ctx context.Context, n *store.Name) error {
+	return c.write(ctx, func(ctx context.Context) ([]store.Change, error) {
+		if err := c.NameStore.Create(ctx, n); err != nil { return nil, err }
+		return []store.Change{change("created", n.ID)}, nil
+	})
+}
+
+func (c *Names) CreateIfAbsent(ctx context.Context, n *store.Name) (bool, error) {
+	var created bool
+	err := c.write(ctx, func(ctx context.Context) (_ []store.Change, err error) {
+		if created, err = c.NameStore.CreateIfAbsent(ctx, n); !created { return nil, err }
+		return []store.Change{change("created", n.ID)}, err
+	})
+	return created, err
+}
+
+func (c *Names) Upsert(ctx context.Context, n store.Name, ifVersion int64) (*store.Name, store.Name, error) {
+	var before *store.Name
+	var after store.Name
+	err := c.write(ctx, func(ctx context.Context) (_ []store.Change, err error) {
+		if before, after, err = c.NameStore.Upsert(ctx, n, ifVersion); err != nil { return nil, err }
+		typ := "updated"
+		if before == nil { typ = "created" }
+		return []store.Change{change(typ, after.ID)}, nil
+	})
+	return before, after, err
+}
+
+func (c *Names) Update(ctx context.Context, id primitive.ObjectID, n store.Name, ifVersion int64) (store.Name, error) {
+	var after store.Name
+	err := c.write(ctx, func(ctx context.Context) (_ []store.Change, err error) {
+		if after, err = c.NameStore.Update(ctx, id, n, ifVersion); err != nil { return nil, err }
+		return []store.Change{change("updated", id)}, nil
+	})
+	return after, err
+}
+
+func (c *Names) Patch(ctx context.Context, id primitive.ObjectID, p store.NamePatch, ifVersion int64) (store.Name, error) {
+	var after store.Name
+	err := c.write(ctx, func(ctx context.Context) (_ []store.Change, err error) {
+		if after, err = c.NameStore.Patch(ctx, id, p, ifVersion); err != nil { return nil, err }
+		return []store.Change{change("updated", id)}, nil
+	})
+	return after, err
+}
+
+func (c *Names) SoftDelete(ctx context.Context, id primitive.ObjectID, ifVersion int64) error {
+	return c.write(ctx, func(ctx context.Context) ([]store.Change, error) {
+		if err := c.NameStore.SoftDelete(ctx, id, ifVersion); err != nil { return nil, err }
+		return []store.Change{change("deleted", id)}, nil
+	})
+}
+
+func (c *Names) HardDelete(ctx context.Context, id primitive.ObjectID, ifVersion int64) error {
+	return c.write(ctx, func(ctx context.Context) ([]store.Change, error) {
+		if err := c.NameStore.HardDelete(ctx, id, ifVersion); err != nil { return nil, err }
+		return []store.Change{change("removed", id)}, nil
+	})
+}
+
+func (c *Names) Restore(ctx context.Context, id primitive.ObjectID) (store.Name, error) {
+	var after store.Name
+	err := c.write(ctx, func(ctx context.Context) (_ []store.Change, err error) {
+		if after, err = c.NameStore.Restore(ctx, id); err != nil { return nil, err }
+		return []store.Change{change("restored", id)}, nil
+	})
+	return after, err
+}
+
+func (c *Names) CreateMany(ctx context.Context, ns []store.Name) ([]error, error) {
+	errs, err := c.NameStore.CreateMany(ctx, ns)
+	if err == nil { c.append(ctx, created(ns, errs)) }
+	return errs, err
+}
+
+func (c *Names) InsertMany(ctx context.Context, ns []store.Name) ([]error, error) {
+	errs, err := c.NameStore.InsertMany(ctx, ns)
+	if err == nil { c.append(ctx, created(ns, errs)) }
+	return errs, err
+}
+
+// created returns the changes of the items of a batch insert that were
+// stored.
+func created(ns []store.Name, errs []error) []store.Change {
+	var cs []store.Change
+	for i := range ns {
+		if i < len(errs) && errs[i] == nil { cs = append(cs, change("created", ns[i].ID)) }
+	}
+	return cs
+}
+
+func (c *Names) DeleteMany(ctx context.Context, ids []primitive.ObjectID, hard bool) (map[primitive.ObjectID]bool, error) {
+	typ := "deleted"
+	if hard { typ = "removed" }
+	var existed map[primitive.ObjectID]bool
+	err := c.write(ctx, func(ctx context.Context) (_ []store.Change, err error) {
+		if existed, err = c.NameStore.DeleteMany(ctx, ids, hard); err != nil { return nil, err }
+		var cs []store.Change
+		for i, id := range ids {
+			if existed[id] && !slices.Contains(ids[:i], id) { cs = append(cs, change(typ, id)) }
+		}
+		return cs, nil
+	})
+	return existed, err
+}
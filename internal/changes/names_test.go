@@ -0,0 +1,79 @@
+package changes
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"app/internal/store"
+	"app/internal/tenant"
+)
+
+func TestNames(t *testing.T) {
+	names := store.NewMemoryNames()
+	c := NewNames(names, names, store.NewMemoryChangeLog(time.Hour), Config{Retention: time.Hour, MaxWait: time.Second, Poll: time.Second})
+	ctx, other := tenant.NewContext(context.Background(), "team-a"), tenant.NewContext(context.Background(), "team-b")
+
+	start, err := c.Since(ctx, "", 10, 0)
+	if err != nil || len(start.Changes) != 0 || start.More { t.Fatalf("start: %+v, %v", start, err) }
+	alice, bob, carol := &store.Name{Name: "alice"}, &store.Name{Name: "bob"}, &store.Name{Name: "carol"}
+	for _, n := range []*store.Name{alice, bob, carol} {
+		if err := c.Create(ctx, n); err != nil { t.Fatal(err) }
+	}
+	if err := c.Create(other, &store.Name{Name: "dave"}); err != nil { t.Fatal(err) }
+	if _, err := c.Patch(ctx, alice.ID, store.NamePatch{Tags: &[]string{"vip"}}, store.AnyVersion); err != nil { t.Fatal(err) }
+	if err := c.SoftDelete(ctx, bob.ID, store.AnyVersion); err != nil { t.Fatal(err) }
+	if err := c.HardDelete(ctx, carol.ID, store.AnyVersion); err != nil { t.Fatal(err) }
+	if _, err := c.Update(ctx, alice.ID, store.Name{Name: "alice"}, 7); !errors.Is(err, store.ErrVersionMismatch) { t.Fatalf("Update = %v", err) }
+
+	// A name once, at its latest change, as it is now.
+	d, err := c.Since(ctx, start.Next, 10, 0)
+	if err != nil { t.Fatal(err) }
+	if len(d.Changes) != 3 || d.More { t.Fatalf("delta: %+v", d) }
+	if e := d.Changes[0]; e.ID != alice.ID || e.Type != "updated" || e.Seq != 4 || e.Name == nil || len(e.Name.Tags) != 1 { t.Fatalf("alice: %+v", e) }
+	if e := d.Changes[1]; e.ID != bob.ID || e.Type != "deleted" || e.Name == nil || e.Name.DeletedAt == nil { t.Fatalf("bob: %+v", e) }
+	if e := d.Changes[2]; e.ID != carol.ID || e.Type != "removed" || e.Name != nil { t.Fatalf("carol: %+v", e) }
+
+	// Paged: the rest waits.
+	first, err := c.Since(ctx, start.Next, 2, 0)
+	if err != nil || len(first.Changes) != 2 || !first.More || first.Changes[0].ID != alice.ID || first.Changes[1].ID != bob.ID { t.Fatalf("first page: %+v, %v", first, err) }
+	// Alice and Bob changed again after the page they are listed in: they
+	// are read as they are now, and again on the next page.
+	rest, err := c.Since(ctx, first.Next, 10, 0)
+	if err != nil || len(rest.Changes) != 3 || rest.More || rest.Changes[0].ID != alice.ID || rest.Changes[2].ID != carol.ID || rest.Next != d.Next { t.Fatalf("rest: %+v, %v", rest, err) }
+
+	// Nothing new: the token moves on in time but not in the log.
+	idle, err := c.Since(ctx, d.Next, 10, 0)
+	if err != nil || len(idle.Changes) != 0 { t.Fatalf("idle: %+v, %v", idle, err) }
+
+	// A long poll returns with the next write.
+	go func() { time.Sleep(20 * time.Millisecond); _, _ = c.Restore(ctx, bob.ID) }()
+	begun := time.Now()
+	woken, err := c.Since(ctx, idle.Next, 10, time.Second)
+	if err != nil || len(woken.Changes) != 1 || woken.Changes[0].Type != "restored" { t.Fatalf("woken: %+v, %v", woken, err) }
+	if took := time.Since(begun); took > 500*time.Millisecond { t.Fatalf("woken after %s", took) }
+	timedOut, err := c.Since(ctx, woken.Next, 10, 30*time.Millisecond)
+	if err != nil || len(timedOut.Changes) != 0 { t.Fatalf("timed out: %+v, %v", timedOut, err) }
+
+	// The other tenant's log is its own.
+	if d, err := c.Since(other, start.Next, 10, 0); err != nil || len(d.Changes) != 1 || d.Changes[0].Name.Name != "dave" { t.Fatalf("other tenant: %+v, %v", d, err) }
+
+	if _, err := c.Since(ctx, "nope", 10, 0); !errors.Is(err, ErrBadToken) { t.Fatalf("bad token: %v", err) }
+	old := token{Seq: 1, At: time.Now().Add(-2 * time.Hour).UnixMilli()}.encode()
+	if _, err := c.Since(ctx, old, 10, 0); !errors.Is(err, store.ErrResumeExpired) { t.Fatalf("old token: %v", err) }
+}
+
+func TestNamesBatches(t *testing.T) {
+	names := store.NewMemoryNames()
+	c := NewNames(names, names, store.NewMemoryChangeLog(time.Hour), Config{Retention: time.Hour, Poll: time.Second})
+	ctx := context.Background()
+	start, _ := c.Since(ctx, "", 10, 0)
+	ns := []store.Name{{Name: "alice"}, {Name: "bob"}, {Name: "alice"}}
+	if errs, err := c.CreateMany(ctx, ns); err != nil || errs[2] == nil { t.Fatalf("CreateMany = %v, %v", errs, err) }
+	if _, err := c.DeleteMany(ctx, []primitive.ObjectID{ns[0].ID, ns[0].ID, primitive.NewObjectID()}, true); err != nil { t.Fatal(err) }
+	d, err := c.Since(ctx, start.Next, 10, 0)
+	if err != nil || len(d.Changes) != 2 || d.Changes[0].ID != ns[1].ID || d.Changes[1].ID != ns[0].ID || d.Changes[1].Type != "removed" { t.Fatalf("delta: %+v, %v", d, err) }
+}
@@ -0,0 +1,114 @@
+package changes
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"slices"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"app/internal/store"
+)
+
+// ErrBadToken: the change token isn't one Since gave out.
+var ErrBadToken = errors.New("malformed change token")
+
+// Delta is what changed since a token: a name once each, in the order of
+// its latest change, and the token to ask from next time.
+type Delta struct {
+	Changes []Entry `json:"changes"`
+	Next    string  `json:"next"`
+	More    bool    `json:"has_more"` // whether more changes are waiting: ask again at once
+}
+
+// Entry is a name that changed. Type is its latest change: created,
+// updated, deleted (soft), restored or removed (hard delete). Name is the
+// document as it is now, soft-deleted ones included, and is absent for
+// removed; a name removed after the change it is listed for reads as
+// removed.
+type Entry struct {
+	Seq  int64              `json:"seq"`
+	Type string             `json:"type"`
+	ID   primitive.ObjectID `json:"id"`
+	Name *store.Name        `json:"name,omitempty"`
+}
+
+// token is a position in a tenant's log: after change Seq, all of whose
+// successors were appended from At on, so are kept until At+Retention.
+// Clients only ever see it encoded.
+type token struct {
+	Seq int64 `json:"s"`
+	At  int64 `json:"t"` // Unix milliseconds
+}
+
+func (t token) encode() string {
+	b, _ := json.Marshal(t)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeToken(s string) (token, error) {
+	var t token
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || json.Unmarshal(b, &t) != nil || t.Seq < 0 || t.At <= 0 { return t, ErrBadToken }
+	return t, nil
+}
+
+// Since returns up to limit of the changes to the tenant's names after
+// those seen by the client holding since. With none yet, it waits up to
+// wait for one. An empty since returns no changes and the latest token,
+// for a client about to load every name: it syncs from there once it has.
+//
+// A token too old to have all its successors still in the log is
+// store.ErrResumeExpired: the client has to load every name again.
+func (c *Names) Since(ctx context.Context, since string, limit int, wait time.Duration) (Delta, error) {
+	if since == "" {
+		last, err := c.log.LastChange(ctx)
+		return Delta{Changes: []Entry{}, Next: token{Seq: last, At: time.Now().UnixMilli()}.encode()}, err
+	}
+	t, err := decodeToken(since)
+	if err != nil { return Delta{}, err }
+	if time.Since(time.UnixMilli(t.At)) >= c.cfg.Retention { return Delta{}, store.ErrResumeExpired }
+
+	deadline := time.Now().Add(wait)
+	var cs []store.Change
+	for {
+		wake := c.wake(ctx) // before reading, so that a write meanwhile isn't missed
+		if cs, err = c.log.Changes(ctx, t.Seq, limit+1); err != nil { return Delta{}, err }
+		left := time.Until(deadline)
+		if len(cs) > 0 || left <= 0 { break }
+		timer := time.NewTimer(min(left, c.cfg.Poll))
+		select {
+		case <-wake:
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return Delta{}, ctx.Err()
+		}
+		timer.Stop()
+	}
+
+	d := Delta{Changes: []Entry{}, More: len(cs) > limit}
+	if d.More { cs = cs[:limit] }
+	if len(cs) == 0 { d.Next = token{Seq: t.Seq, At: time.Now().UnixMilli()}.encode(); return d, nil }
+	d.Next = token{Seq: cs[len(cs)-1].Seq, At: cs[len(cs)-1].At.UnixMilli()}.encode()
+
+	// A name once, at its latest change.
+	ids := []primitive.ObjectID{}
+	for i := len(cs) - 1; i >= 0; i-- {
+		if id := cs[i].NameID; !slices.Contains(ids, id) {
+			ids = append(ids, id)
+			d.Changes = append(d.Changes, Entry{Seq: cs[i].Seq, Type: cs[i].Type, ID: id})
+		}
+	}
+	slices.Reverse(d.Changes)
+	docs, err := c.NameStore.Lookup(ctx, ids)
+	if err != nil { return Delta{}, err }
+	for i := range d.Changes {
+		e := &d.Changes[i]
+		if n, found := docs[e.ID]; found && e.Type != "removed" { e.Name = &n } else { e.Type = "removed" }
+	}
+	return d, nil
+}
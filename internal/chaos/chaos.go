@@ -0,0 +1,98 @@
+// Package chaos injects faults, for exercising clients' retries and the
+// alerting on a server that isn't failing: latency, 500s, and MongoDB
+// timeouts, each at a rate. The faults come from the configuration, or for
+// one request from its X-Chaos header, which production servers ignore.
+package chaos
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Faults are the faults to inject, and how often: each rate is the chance,
+// from 0 to 1, that a request (or for timeouts, a call into the store)
+// gets one.
+type Faults struct {
+	Latency     time.Duration // added to a request
+	LatencyRate float64
+	ErrorRate   float64 // of requests answered 500 instead of served
+	TimeoutRate float64 // of calls to the names store failing as if MongoDB timed out
+}
+
+// Any reports whether f injects anything.
+func (f Faults) Any() bool {
+	return f.Latency > 0 && f.LatencyRate > 0 || f.ErrorRate > 0 || f.TimeoutRate > 0
+}
+
+// Validate reports the first rate outside 0 to 1, or a negative latency.
+func (f Faults) Validate() error {
+	if f.Latency < 0 { return fmt.Errorf("latency must be >= 0, got %s", f.Latency) }
+	for _, r := range []struct {
+		name string
+		rate float64
+	}{{"latency_rate", f.LatencyRate}, {"error_rate", f.ErrorRate}, {"timeout_rate", f.TimeoutRate}} {
+		if r.rate < 0 || r.rate > 1 { return fmt.Errorf("%s must be between 0 and 1, got %g", r.name, r.rate) }
+	}
+	return nil
+}
+
+// Header is the request header that asks for faults of its own.
+const Header = "X-Chaos"
+
+// Parse reads an X-Chaos header, such as "latency=300ms, error_rate=0.5",
+// into the faults it asks for. Those it doesn't name are none; a latency
+// without a latency_rate is added every time.
+func Parse(header string) (Faults, error) {
+	var f Faults
+	rateSet := false
+	for _, part := range strings.Split(header, ",") {
+		if part = strings.TrimSpace(part); part == "" { continue }
+		key, value, found := strings.Cut(part, "=")
+		if !found { return f, fmt.Errorf("%q is not key=value", part) }
+		var err error
+		switch key = strings.TrimSpace(key); key {
+		case "latency":
+			f.Latency, err = time.ParseDuration(strings.TrimSpace(value))
+		case "latency_rate":
+			f.LatencyRate, err = strconv.ParseFloat(strings.TrimSpace(value), 64)
+			rateSet = true
+		case "error_rate":
+			f.ErrorRate, err = strconv.ParseFloat(strings.TrimSpace(value), 64)
+		case "timeout_rate":
+			f.TimeoutRate, err = strconv.ParseFloat(strings.TrimSpace(value), 64)
+		default:
+			return f, fmt.Errorf("unknown fault %q: want latency, latency_rate, error_rate or timeout_rate", key)
+		}
+		if err != nil { return f, fmt.Errorf("%s: %w", key, err) }
+	}
+	if !rateSet && f.Latency > 0 { f.LatencyRate = 1 }
+	return f, f.Validate()
+}
+
+// roll reports whether a fault of rate happens this time.
+func roll(rate float64) bool { return rate > 0 && rand.Float64() < rate }
+
+// Delay is the latency to add to a request: f.Latency, or 0, at random.
+func (f Faults) Delay() time.Duration {
+	if roll(f.LatencyRate) { return f.Latency }
+	return 0
+}
+
+// Fail reports whether to answer a request with a 500, at random.
+func (f Faults) Fail() bool { return roll(f.ErrorRate) }
+
+type ctxKey struct{}
+
+// NewContext returns ctx carrying the faults of its request, for the names
+// store to time out by.
+func NewContext(ctx context.Context, f Faults) context.Context { return context.WithValue(ctx, ctxKey{}, f) }
+
+// FromContext returns the faults in ctx; none if there are none.
+func FromContext(ctx context.Context) Faults {
+	f, _ := ctx.Value(ctxKey{}).(Faults)
+	return f
+}
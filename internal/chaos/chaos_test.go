@@ -0,0 +1,58 @@
+package chaos
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"app/internal/store"
+)
+
+func TestParse(t *testing.T) {
+	for _, tc := range []struct {
+		header string
+		want   Faults
+		err    string
+	}{
+		{"", Faults{}, ""},
+		{"latency=300ms", Faults{Latency: 300 * time.Millisecond, LatencyRate: 1}, ""},
+		{"latency=1s, latency_rate=0.25, error_rate=0.5", Faults{Latency: time.Second, LatencyRate: 0.25, ErrorRate: 0.5}, ""},
+		{" timeout_rate = 1 ,", Faults{TimeoutRate: 1}, ""},
+		{"error_rate", Faults{}, "not key=value"},
+		{"error_rate=lots", Faults{}, "error_rate:"},
+		{"error_rate=2", Faults{}, "error_rate must be between 0 and 1"},
+		{"latency=-1s", Faults{}, "latency must be >= 0"},
+		{"outage=1", Faults{}, `unknown fault "outage"`},
+	} {
+		got, err := Parse(tc.header)
+		if tc.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.err) { t.Errorf("Parse(%q) = %v, want an error with %q", tc.header, err, tc.err) }
+			continue
+		}
+		if err != nil || got != tc.want { t.Errorf("Parse(%q) = %+v, %v; want %+v", tc.header, got, err, tc.want) }
+	}
+}
+
+func TestFaults(t *testing.T) {
+	always := Faults{Latency: time.Second, LatencyRate: 1, ErrorRate: 1}
+	if !always.Any() || always.Delay() != time.Second || !always.Fail() { t.Errorf("%+v didn't inject its faults", always) }
+	never := Faults{Latency: time.Second}
+	if never.Any() || never.Delay() != 0 || never.Fail() { t.Errorf("%+v injected a fault", never) }
+}
+
+func TestNames(t *testing.T) {
+	c := NewNames(store.NewMemoryNames())
+	n := &store.Name{Name: "alice"}
+	if err := c.Create(context.Background(), n); err != nil { t.Fatal(err) }
+
+	ctx := NewContext(context.Background(), Faults{TimeoutRate: 1})
+	if _, err := c.Get(ctx, n.ID); !errors.Is(err, ErrTimeout) || !errors.Is(err, context.DeadlineExceeded) { t.Errorf("Get = %v, want a simulated timeout", err) }
+	if err := c.Create(ctx, &store.Name{Name: "bob"}); !errors.Is(err, ErrTimeout) { t.Errorf("Create = %v, want a simulated timeout", err) }
+	if _, _, err := c.Upsert(ctx, store.Name{Name: "carol"}, store.AnyVersion); !errors.Is(err, ErrTimeout) { t.Errorf("Upsert = %v, want a simulated timeout", err) }
+	if names, _ := c.ExistingNames(context.Background(), []string{"bob", "carol"}); len(names) != 0 { t.Errorf("the timed out writes were made: %v", names) }
+
+	ctx = NewContext(context.Background(), Faults{ErrorRate: 1}) // not the store's to inject
+	if got, err := c.Get(ctx, n.ID); err != nil || got.Name != "alice" { t.Errorf("Get = %+v, %v", got, err) }
+}
@@ -0,0 +1,113 @@
+package chaos
+
+import (
+	"context"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"app/internal/metrics"
+	"app/internal/store"
+)
+
+// ErrTimeout is what a call into the store fails with when it is made to
+// time out. It is a context.DeadlineExceeded, which the handlers answer
+// like a real timeout: 503.
+var ErrTimeout = fmt.Errorf("chaos: simulated MongoDB timeout: %w", context.DeadlineExceeded)
+
+// Names wraps a NameStore and fails its calls with ErrTimeout as often as
+// the faults in their context say. It goes above the retries, which would
+// otherwise hide the timeouts from the clients they are meant for.
+type Names struct{ store.NameStore }
+
+func NewNames(s store.NameStore) *Names { return &Names{NameStore: s} }
+
+// timeout reports whether the call with ctx is to time out.
+func timeout(ctx context.Context) bool {
+	if !roll(FromContext(ctx).TimeoutRate) { return false }
+	metrics.ChaosFault("timeout")
+	return true
+}
+
+// call runs fn unless the call is to time out.
+func call[T any](ctx context.Context, fn func() (T, error)) (T, error) {
+	if timeout(ctx) { var zero T; return zero, ErrTimeout }
+	return fn()
+}
+
+func (c *Names) Create(ctx context.Context, n *store.Name) error {
+	if timeout(ctx) { return ErrTimeout }
+	return c.NameStore.Create(ctx, n)
+}
+
+func (c *Names) CreateIfAbsent(ctx context.Context, n *store.Name) (bool, error) {
+	return call(ctx, func() (bool, error) { return c.NameStore.CreateIfAbsent(ctx, n) })
+}
+
+func (c *Names) Get(ctx context.Context, id primitive.ObjectID) (store.Name, error) {
+	return call(ctx, func() (store.Name, error) { return c.NameStore.Get(ctx, id) })
+}
+
+func (c *Names) Lookup(ctx context.Context, ids []primitive.ObjectID) (map[primitive.ObjectID]store.Name, error) {
+	return call(ctx, func() (map[primitive.ObjectID]store.Name, error) { return c.NameStore.Lookup(ctx, ids) })
+}
+
+func (c *Names) List(ctx context.Context, opts store.ListOptions) (store.Page, error) {
+	return call(ctx, func() (store.Page, error) { return c.NameStore.List(ctx, opts) })
+}
+
+func (c *Names) Each(ctx context.Context, opts store.ListOptions, fn func(store.Name) error) error {
+	if timeout(ctx) { return ErrTimeout }
+	return c.NameStore.Each(ctx, opts, fn)
+}
+
+func (c *Names) Update(ctx context.Context, id primitive.ObjectID, n store.Name, ifVersion int64) (store.Name, error) {
+	return call(ctx, func() (store.Name, error) { return c.NameStore.Update(ctx, id, n, ifVersion) })
+}
+
+func (c *Names) Patch(ctx context.Context, id primitive.ObjectID, p store.NamePatch, ifVersion int64) (store.Name, error) {
+	return call(ctx, func() (store.Name, error) { return c.NameStore.Patch(ctx, id, p, ifVersion) })
+}
+
+func (c *Names) Upsert(ctx context.Context, n store.Name, ifVersion int64) (*store.Name, store.Name, error) {
+	if timeout(ctx) { return nil, store.Name{}, ErrTimeout }
+	return c.NameStore.Upsert(ctx, n, ifVersion)
+}
+
+func (c *Names) SoftDelete(ctx context.Context, id primitive.ObjectID, ifVersion int64) error {
+	if timeout(ctx) { return ErrTimeout }
+	return c.NameStore.SoftDelete(ctx, id, ifVersion)
+}
+
+func (c *Names) HardDelete(ctx context.Context, id primitive.ObjectID, ifVersion int64) error {
+	if timeout(ctx) { return ErrTimeout }
+	return c.NameStore.HardDelete(ctx, id, ifVersion)
+}
+
+func (c *Names) Restore(ctx context.Context, id primitive.ObjectID) (store.Name, error) {
+	return call(ctx, func() (store.Name, error) { return c.NameStore.Restore(ctx, id) })
+}
+
+func (c *Names) Events(ctx context.Context, id primitive.ObjectID) ([]store.NameEvent, error) {
+	return call(ctx, func() ([]store.NameEvent, error) { return c.NameStore.Events(ctx, id) })
+}
+
+func (c *Names) Search(ctx context.Context, opts store.SearchOptions) ([]store.SearchHit, error) {
+	return call(ctx, func() ([]store.SearchHit, error) { return c.NameStore.Search(ctx, opts) })
+}
+
+func (c *Names) CreateMany(ctx context.Context, ns []store.Name) ([]error, error) {
+	return call(ctx, func() ([]error, error) { return c.NameStore.CreateMany(ctx, ns) })
+}
+
+func (c *Names) DeleteMany(ctx context.Context, ids []primitive.ObjectID, hard bool) (map[primitive.ObjectID]bool, error) {
+	return call(ctx, func() (map[primitive.ObjectID]bool, error) { return c.NameStore.DeleteMany(ctx, ids, hard) })
+}
+
+func (c *Names) ExistingNames(ctx context.Context, names []string) (map[string]bool, error) {
+	return call(ctx, func() (map[string]bool, error) { return c.NameStore.ExistingNames(ctx, names) })
+}
+
+func (c *Names) InsertMany(ctx context.Context, ns []store.Name) ([]error, error) {
+	return call(ctx, func() ([]error, error) { return c.NameStore.InsertMany(ctx, ns) })
+}
@@ -0,0 +1,93 @@
+// Package cleanup removes the names that are due for it: those whose
+// expires_at has passed and, if DeletedRetention is set, those soft-deleted
+// longer ago than that. It runs on a cron schedule in every instance, or
+// under LEADER_ELECTION in the leader alone. Running in several at once
+// does no harm: each removal is conditional on the version the sweep
+// found, so only one of them removes a name, and a name changed
+// meanwhile, say given a later expiry, is left alone.
+package cleanup
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"app/internal/metrics"
+	"app/internal/store"
+	"app/internal/tenant"
+)
+
+// Config tunes a Cleaner.
+type Config struct {
+	Schedule         Schedule
+	DeletedRetention time.Duration // 0 keeps soft-deleted names until someone removes them
+	BatchSize        int           // names read per query
+}
+
+// Cleaner sweeps on schedule.
+type Cleaner struct {
+	names store.NameStore // decorated, so that removals are audited
+	due   store.ExpiryStore
+	cfg   Config
+}
+
+func New(names store.NameStore, due store.ExpiryStore, cfg Config) *Cleaner {
+	return &Cleaner{names: names, due: due, cfg: cfg}
+}
+
+// Counts are what a sweep did: the names it removed because they expired
+// or had been in the trash long enough, and those it left because they
+// have notes (see package notes).
+type Counts struct {
+	Expired, Deleted, Kept int
+}
+
+// Run sweeps at each time of the schedule until ctx ends. A failed sweep
+// is logged, and what it left is picked up by the next one.
+func (c *Cleaner) Run(ctx context.Context) error {
+	slog.Info("cleaning up names on schedule", "next", c.cfg.Schedule.Next(time.Now()), "deleted_retention", c.cfg.DeletedRetention)
+	for {
+		wait := time.NewTimer(time.Until(c.cfg.Schedule.Next(time.Now())))
+		select {
+		case <-ctx.Done():
+			wait.Stop()
+			return nil
+		case <-wait.C:
+		}
+		counts, err := c.Sweep(ctx)
+		if err != nil && ctx.Err() == nil { slog.ErrorContext(ctx, "cleaning up names", "err", err) }
+		if counts != (Counts{}) { slog.InfoContext(ctx, "cleaned up names", "expired", counts.Expired, "deleted", counts.Deleted, "kept", counts.Kept) }
+	}
+}
+
+// Sweep removes the names due now, batch by batch, each in its tenant.
+func (c *Cleaner) Sweep(ctx context.Context) (Counts, error) {
+	var counts Counts
+	now := time.Now().UTC()
+	q := store.DueQuery{ExpiredBy: now, Limit: c.cfg.BatchSize}
+	if c.cfg.DeletedRetention > 0 { q.DeletedBefore = now.Add(-c.cfg.DeletedRetention) }
+	for {
+		batch, err := c.due.DueNames(ctx, q)
+		if err != nil { return counts, err }
+		for _, n := range batch {
+			err := c.names.HardDelete(tenant.NewContext(ctx, n.Tenant), n.ID, n.Version)
+			switch {
+			case errors.Is(err, store.ErrNotFound), errors.Is(err, store.ErrVersionMismatch):
+				// Removed by another instance, or changed since it was read.
+			case errors.Is(err, store.ErrHasNotes):
+				counts.Kept++
+			case err != nil:
+				return counts, err
+			case n.ExpiresAt != nil && !n.ExpiresAt.After(now):
+				counts.Expired++
+				metrics.NamePurged("expired")
+			default:
+				counts.Deleted++
+				metrics.NamePurged("deleted")
+			}
+		}
+		if len(batch) < q.Limit { return counts, nil }
+		q.After = batch[len(batch)-1].ID
+	}
+}
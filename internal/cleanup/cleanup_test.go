@@ -0,0 +1,43 @@
+package cleanup
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"app/internal/notes"
+	"app/internal/store"
+	"app/internal/tenant"
+)
+
+func TestSweep(t *testing.T) {
+	names := store.NewMemoryNames()
+	nts := store.NewMemoryNotes(names)
+	c := New(notes.NewNames(names, nts, notes.Block), names, Config{DeletedRetention: time.Hour, BatchSize: 2})
+
+	a, b := tenant.NewContext(context.Background(), "team-a"), tenant.NewContext(context.Background(), "team-b")
+	soon := time.Now().Add(50 * time.Millisecond)
+	expiring := []store.Name{{Name: "a1", ExpiresAt: &soon}, {Name: "a2", ExpiresAt: &soon}, {Name: "noted", ExpiresAt: &soon}}
+	for i := range expiring {
+		if err := names.Create(a, &expiring[i]); err != nil { t.Fatal(err) }
+	}
+	if err := nts.CreateNote(a, &store.Note{NameID: expiring[2].ID, Body: "keep me"}); err != nil { t.Fatal(err) }
+	other, trashed, live := store.Name{Name: "b1", ExpiresAt: &soon}, store.Name{Name: "b2"}, store.Name{Name: "b3"}
+	for _, n := range []*store.Name{&other, &trashed, &live} {
+		if err := names.Create(b, n); err != nil { t.Fatal(err) }
+	}
+	if err := names.SoftDelete(b, trashed.ID, store.AnyVersion); err != nil { t.Fatal(err) }
+
+	if counts, err := c.Sweep(context.Background()); err != nil || counts != (Counts{}) { t.Fatalf("sweep before anything is due: %+v, %v", counts, err) }
+	time.Sleep(60 * time.Millisecond)
+	counts, err := c.Sweep(context.Background())
+	if err != nil || counts != (Counts{Expired: 3, Kept: 1}) { t.Fatalf("sweep: %+v, %v", counts, err) }
+	if _, err := names.Get(b, other.ID); !errors.Is(err, store.ErrNotFound) { t.Fatalf("expired name in another tenant: %v", err) }
+	if _, err := names.Get(a, expiring[2].ID); err != nil { t.Fatalf("name with notes: %v", err) }
+	if _, err := names.Get(b, live.ID); err != nil { t.Fatalf("live name: %v", err) }
+
+	// Soft-deleted names go once they have been in the trash long enough.
+	c.cfg.DeletedRetention = time.Nanosecond
+	if counts, err = c.Sweep(context.Background()); err != nil || counts != (Counts{Deleted: 1, Kept: 1}) { t.Fatalf("sweep of the trash: %+v, %v", counts, err) }
+}
@@ -0,0 +1,105 @@
+package cleanup
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule is a cron schedule: five fields, minute hour day-of-month month
+// day-of-week, each *, a number or a range a-b, optionally with a /step,
+// or a comma-separated list of those. As in cron, when both day fields are
+// restricted a day matching either one will do. @hourly, @daily, @weekly
+// and @monthly stand for the usual expressions. Times are UTC.
+type Schedule struct {
+	minute, hour, dom, month, dow uint64 // bit i: value i matches
+	anyDom, anyDow                bool
+}
+
+var descriptors = map[string]string{
+	"@hourly":  "0 * * * *",
+	"@daily":   "0 0 * * *",
+	"@weekly":  "0 0 * * 0",
+	"@monthly": "0 0 1 * *",
+}
+
+// cronFields are the fields in order, with their ranges.
+var cronFields = []struct {
+	name     string
+	min, max int
+}{{"minute", 0, 59}, {"hour", 0, 23}, {"day of month", 1, 31}, {"month", 1, 12}, {"day of week", 0, 7}}
+
+func ParseSchedule(spec string) (Schedule, error) {
+	if d, ok := descriptors[spec]; ok { spec = d }
+	fields := strings.Fields(spec)
+	if len(fields) != len(cronFields) { return Schedule{}, fmt.Errorf("%q: want 5 fields (minute hour day month weekday) or @hourly, @daily, @weekly, @monthly", spec) }
+
+	var bits [5]uint64
+	for i, f := range cronFields {
+		var err error
+		if bits[i], err = parseField(fields[i], f.min, f.max); err != nil { return Schedule{}, fmt.Errorf("%q: %s: %w", spec, f.name, err) }
+	}
+	// 7 is Sunday too.
+	if bits[4]&(1<<7) != 0 { bits[4] |= 1 }
+	s := Schedule{minute: bits[0], hour: bits[1], dom: bits[2], month: bits[3], dow: bits[4], anyDom: fields[2] == "*", anyDow: fields[4] == "*"}
+	if s.Next(time.Now()).IsZero() { return Schedule{}, fmt.Errorf("%q never comes", spec) }
+	return s, nil
+}
+
+// parseField reads one field's list of values between lo and hi.
+func parseField(field string, lo, hi int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rng, stepStr, stepped := strings.Cut(part, "/")
+		step := 1
+		if stepped {
+			var err error
+			if step, err = strconv.Atoi(stepStr); err != nil || step < 1 { return 0, fmt.Errorf("bad step %q", stepStr) }
+		}
+		from, to := lo, hi
+		if rng != "*" {
+			a, b, isRange := strings.Cut(rng, "-")
+			var err error
+			if from, err = strconv.Atoi(a); err != nil { return 0, fmt.Errorf("bad value %q", a) }
+			to = from
+			if isRange {
+				if to, err = strconv.Atoi(b); err != nil { return 0, fmt.Errorf("bad value %q", b) }
+			} else if stepped {
+				to = hi // a/n is a-hi/n
+			}
+		}
+		if from < lo || to > hi || from > to { return 0, fmt.Errorf("%q is outside %d-%d", part, lo, hi) }
+		for v := from; v <= to; v += step { bits |= 1 << v }
+	}
+	if bits == 0 { return 0, errors.New("empty") }
+	return bits, nil
+}
+
+// Next returns the first time after t that s matches, or the zero time if
+// there is none within five years, as with February 30.
+func (s Schedule) Next(t time.Time) time.Time {
+	t = t.UTC().Truncate(time.Minute).Add(time.Minute)
+	for end := t.AddDate(5, 0, 0); t.Before(end); {
+		switch {
+		case s.month&(1<<int(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+		case !s.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, time.UTC)
+		case s.hour&(1<<t.Hour()) == 0:
+			t = t.Truncate(time.Hour).Add(time.Hour)
+		case s.minute&(1<<t.Minute()) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+func (s Schedule) dayMatches(t time.Time) bool {
+	dom, dow := s.dom&(1<<t.Day()) != 0, s.dow&(1<<int(t.Weekday())) != 0
+	if s.anyDom || s.anyDow { return dom && dow }
+	return dom || dow
+}
@@ -0,0 +1,38 @@
+package cleanup
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSchedule(t *testing.T) {
+	from := time.Date(2026, 1, 31, 10, 30, 15, 0, time.UTC) // a Saturday
+	for _, tc := range []struct{ spec, want string }{
+		{"@hourly", "2026-01-31T11:00"},
+		{"@daily", "2026-02-01T00:00"},
+		{"@weekly", "2026-02-01T00:00"},
+		{"@monthly", "2026-02-01T00:00"},
+		{"*/20 * * * *", "2026-01-31T10:40"},
+		{"5,35 9-17 * * *", "2026-01-31T10:35"},
+		{"0 3 * * 1-5", "2026-02-02T03:00"},
+		{"0 3 * * 7", "2026-02-01T03:00"},
+		{"0 0 29 2 *", "2028-02-29T00:00"},
+		{"0 0 13 * 5", "2026-02-06T00:00"}, // the 13th or any Friday
+	} {
+		s, err := ParseSchedule(tc.spec)
+		if err != nil { t.Errorf("%s: %v", tc.spec, err); continue }
+		if got := s.Next(from).Format("2006-01-02T15:04"); got != tc.want { t.Errorf("%s: next %s, want %s", tc.spec, got, tc.want) }
+	}
+
+	for spec, want := range map[string]string{
+		"* * * *": "want 5 fields",
+		"60 * * * *": "outside 0-59",
+		"*/0 * * * *": "bad step",
+		"a * * * *": "bad value",
+		"0 0 31 4 *": "never comes",
+		"5-1 * * * *": "outside",
+	} {
+		if _, err := ParseSchedule(spec); err == nil || !strings.Contains(err.Error(), want) { t.Errorf("%s: %v, want %q", spec, err, want) }
+	}
+}
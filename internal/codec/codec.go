@@ -0,0 +1,176 @@
+// Package codec lets clients trade JSON for another encoding of the same
+// values, such as XML for legacy integrations or MessagePack for compact
+// binary ones. The handlers only speak JSON: package server turns request
+// bodies of a registered Content-Type into JSON for them, and their JSON
+// responses into the encoding the client's Accept prefers.
+//
+// Codecs work on the values JSON has, as Parse gives them: nil, bool,
+// json.Number, string, []any and Object, which keeps its members in order.
+// Register adds one; XML and MessagePack come registered.
+package codec
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Codec encodes and decodes the values of JSON in some other way.
+type Codec interface {
+	// ContentType is what encoded bodies are sent as. problem says the
+	// body is an RFC 9457 problem, which some encodings have a type for.
+	ContentType(problem bool) string
+	Encode(w io.Writer, v any) error
+	Decode(r io.Reader) (any, error)
+}
+
+// Object is a JSON object, its members in order.
+type Object []Member
+
+type Member struct {
+	Key   string
+	Value any
+}
+
+var (
+	mu     sync.RWMutex
+	byType = map[string]Codec{}
+)
+
+// Register makes c the codec of the given media types, in Content-Type and
+// Accept alike.
+func Register(c Codec, mediaTypes ...string) {
+	mu.Lock()
+	defer mu.Unlock()
+	for _, mt := range mediaTypes { byType[strings.ToLower(mt)] = c }
+}
+
+// Lookup returns the codec of Content-Type ct, or nil if it has none, as
+// JSON has not.
+func Lookup(ct string) Codec {
+	mt, _, err := mime.ParseMediaType(ct)
+	if err != nil { return nil }
+	mu.RLock()
+	defer mu.RUnlock()
+	return byType[mt]
+}
+
+// Negotiate returns the codec of the media type accept prefers, by its q
+// values and then its order, or nil for JSON: when JSON or any type will
+// do as well, and when accept names nothing registered.
+func Negotiate(accept string) Codec {
+	type choice struct {
+		c Codec // nil for JSON
+		q float64
+	}
+	var choices []choice
+	for _, part := range strings.Split(accept, ",") {
+		mt, params, err := mime.ParseMediaType(part)
+		if err != nil { continue }
+		q := 1.0
+		if v, found := params["q"]; found {
+			if q, err = strconv.ParseFloat(v, 64); err != nil || q <= 0 { continue }
+		}
+		switch {
+		case mt == "application/json" || mt == "*/*" || mt == "application/*":
+			choices = append(choices, choice{nil, q})
+		default:
+			mu.RLock()
+			c := byType[mt]
+			mu.RUnlock()
+			if c != nil { choices = append(choices, choice{c, q}) }
+		}
+	}
+	sort.SliceStable(choices, func(i, j int) bool { return choices[i].q > choices[j].q })
+	if len(choices) == 0 { return nil }
+	return choices[0].c
+}
+
+// Parse decodes the JSON in data into the values codecs work on.
+func Parse(data []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	v, err := parse(dec)
+	if err != nil { return nil, err }
+	if _, err := dec.Token(); !errors.Is(err, io.EOF) { return nil, errors.New("unexpected data after the JSON value") }
+	return v, nil
+}
+
+func parse(dec *json.Decoder) (any, error) {
+	tok, err := dec.Token()
+	if err != nil { return nil, err }
+	switch tok {
+	case json.Delim('{'):
+		obj := Object{}
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil { return nil, err }
+			v, err := parse(dec)
+			if err != nil { return nil, err }
+			obj = append(obj, Member{key.(string), v})
+		}
+		_, err := dec.Token()
+		return obj, err
+	case json.Delim('['):
+		arr := []any{}
+		for dec.More() {
+			v, err := parse(dec)
+			if err != nil { return nil, err }
+			arr = append(arr, v)
+		}
+		_, err := dec.Token()
+		return arr, err
+	}
+	return tok, nil
+}
+
+// JSON encodes v, a value as Parse gives them, as JSON.
+func JSON(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	err := writeJSON(&buf, v)
+	return buf.Bytes(), err
+}
+
+func writeJSON(buf *bytes.Buffer, v any) error {
+	switch v := v.(type) {
+	case Object:
+		buf.WriteByte('{')
+		for i, m := range v {
+			if i > 0 { buf.WriteByte(',') }
+			k, _ := json.Marshal(m.Key)
+			buf.Write(k)
+			buf.WriteByte(':')
+			if err := writeJSON(buf, m.Value); err != nil { return err }
+		}
+		buf.WriteByte('}')
+	case []any:
+		buf.WriteByte('[')
+		for i, e := range v {
+			if i > 0 { buf.WriteByte(',') }
+			if err := writeJSON(buf, e); err != nil { return err }
+		}
+		buf.WriteByte(']')
+	case json.Number:
+		if !validNumber(string(v)) { return fmt.Errorf("%q is not a number", string(v)) }
+		buf.WriteString(string(v))
+	case nil, bool, string:
+		b, _ := json.Marshal(v)
+		buf.Write(b)
+	default:
+		return fmt.Errorf("%T is not a JSON value", v)
+	}
+	return nil
+}
+
+// validNumber reports whether s is a number as JSON writes them.
+func validNumber(s string) bool {
+	_, err := strconv.ParseFloat(s, 64)
+	return (err == nil || errors.Is(err, strconv.ErrRange)) && json.Valid([]byte(s))
+}
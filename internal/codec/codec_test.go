@@ -0,0 +1,82 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+const sample = `{"id":"6a1f","name":"Zoë","version":2,"big":18446744073709551615,"neg":-40000,"ratio":0.25,"tiny":-1e-7,` +
+	`"tags":["vip","core"],"none":[],"empty":{},"gone":null,"ok":true,"blank":"",` +
+	`"metadata":{"team lead":"Bo","xmlish":"x","n":{"deep":[1,[2,{"three":3}]]}},"long":"` + "0123456789abcdef0123456789abcdef0123456789" + `"}`
+
+func TestRoundTrips(t *testing.T) {
+	v, err := Parse([]byte(sample))
+	if err != nil { t.Fatal(err) }
+	for name, c := range map[string]Codec{"xml": XML, "msgpack": MessagePack} {
+		var buf bytes.Buffer
+		if err := c.Encode(&buf, v); err != nil { t.Fatalf("%s: %v", name, err) }
+		back, err := c.Decode(&buf)
+		if err != nil { t.Fatalf("%s: %v", name, err) }
+		got, err := JSON(back)
+		if err != nil { t.Fatalf("%s: %v", name, err) }
+		if string(got) != sample { t.Errorf("%s round trip:\n got %s\nwant %s", name, got, sample) }
+	}
+}
+
+func TestXML(t *testing.T) {
+	var buf bytes.Buffer
+	if err := XML.Encode(&buf, Object{{"name", "A&B"}, {"n", json.Number("1")}, {"team lead", nil}, {"tags", []any{"x"}}}); err != nil { t.Fatal(err) }
+	want := `<response><name>A&amp;B</name><n type="number">1</n><member name="team lead" type="null"></member><tags type="array"><item>x</item></tags></response>`
+	if got := strings.TrimPrefix(buf.String(), `<?xml version="1.0" encoding="UTF-8"?>`+"\n"); got != want { t.Fatalf("encoded\n%s\nwant\n%s", got, want) }
+
+	v, err := XML.Decode(strings.NewReader(`<?xml version="1.0"?><name-request>
+		<name> Alice </name>
+		<expires_in type="number">3600</expires_in>
+		<metadata><team>core</team></metadata>
+	</name-request>`))
+	if err != nil { t.Fatal(err) }
+	if got, _ := JSON(v); string(got) != `{"name":" Alice ","expires_in":3600,"metadata":{"team":"core"}}` { t.Fatalf("decoded %s", got) }
+
+	for _, bad := range []string{``, `<a type="number">ten</a>`, `<a type="boolean">yes</a>`, `<a type="string"><b/></a>`, `<a>text<b/></a>`, `<a/><b/>`, `<a type="set"/>`, `<a>`} {
+		if _, err := XML.Decode(strings.NewReader(bad)); err == nil { t.Errorf("decoded %q", bad) }
+	}
+}
+
+func TestMessagePack(t *testing.T) {
+	var buf bytes.Buffer
+	if err := MessagePack.Encode(&buf, Object{{"a", json.Number("1")}, {"b", []any{true, nil, json.Number("-1"), json.Number("300")}}}); err != nil { t.Fatal(err) }
+	if want := []byte{0x82, 0xa1, 'a', 0x01, 0xa1, 'b', 0x94, 0xc3, 0xc0, 0xff, 0xcd, 0x01, 0x2c}; !bytes.Equal(buf.Bytes(), want) { t.Fatalf("encoded % x, want % x", buf.Bytes(), want) }
+
+	for name, bad := range map[string][]byte{
+		"empty":        {},
+		"truncated":    {0x82, 0xa1, 'a'},
+		"huge array":   {0xdd, 0xff, 0xff, 0xff, 0xff, 0x01},
+		"binary":       {0xc4, 0x01, 0x00},
+		"timestamp":    {0xd6, 0xff, 0, 0, 0, 0},
+		"int key":      {0x81, 0x01, 0x01},
+		"NaN":          {0xcb, 0x7f, 0xf8, 0, 0, 0, 0, 0, 0},
+		"trailing":     {0xc0, 0xc0},
+		"nested deeply": bytes.Repeat([]byte{0x91}, msgpackMaxDepth+2),
+	} {
+		if _, err := MessagePack.Decode(bytes.NewReader(bad)); err == nil { t.Errorf("decoded %s", name) }
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	for accept, want := range map[string]Codec{
+		"":                                            nil,
+		"application/json":                            nil,
+		"application/xml":                             XML,
+		"text/xml, application/json;q=0.5":            XML,
+		"application/json;q=0.5, application/msgpack": MessagePack,
+		"application/xml, application/msgpack":        XML,
+		"*/*, application/xml":                        nil,
+		"image/png":                                   nil,
+		"application/xml;q=0":                         nil,
+	} {
+		if got := Negotiate(accept); got != want { t.Errorf("Negotiate(%q) = %v, want %v", accept, got, want) }
+	}
+	if Lookup("application/xml; charset=utf-8") != XML || Lookup("application/json") != nil { t.Error("Lookup") }
+}
@@ -0,0 +1,245 @@
+package codec
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+)
+
+func init() { Register(MessagePack, "application/msgpack", "application/x-msgpack", "application/vnd.msgpack") }
+
+// MessagePack encodes values as https://msgpack.org does: numbers as the
+// smallest integer that holds them, or a float64, and objects as maps
+// with string keys. Decoding refuses what JSON has nothing for: binary,
+// extensions such as timestamps, keys that aren't strings, NaN and the
+// infinities.
+var MessagePack Codec = msgpackCodec{}
+
+type msgpackCodec struct{}
+
+// msgpackMaxDepth bounds the arrays and maps in each other a body may
+// have, as encoding/json does.
+const msgpackMaxDepth = 10000
+
+func (msgpackCodec) ContentType(bool) string { return "application/msgpack" }
+
+func (msgpackCodec) Encode(w io.Writer, v any) error {
+	b, err := appendMsgpack(nil, v)
+	if err != nil { return err }
+	_, err = w.Write(b)
+	return err
+}
+
+func appendMsgpack(b []byte, v any) ([]byte, error) {
+	switch v := v.(type) {
+	case nil:
+		return append(b, 0xc0), nil
+	case bool:
+		if v { return append(b, 0xc3), nil }
+		return append(b, 0xc2), nil
+	case json.Number:
+		if i, err := strconv.ParseInt(string(v), 10, 64); err == nil { return appendMsgpackInt(b, i), nil }
+		if u, err := strconv.ParseUint(string(v), 10, 64); err == nil { return binary.BigEndian.AppendUint64(append(b, 0xcf), u), nil }
+		f, err := strconv.ParseFloat(string(v), 64)
+		if err != nil { return nil, fmt.Errorf("%q is not a number", string(v)) }
+		return binary.BigEndian.AppendUint64(append(b, 0xcb), math.Float64bits(f)), nil
+	case string:
+		return appendMsgpackString(b, v), nil
+	case []any:
+		b = appendMsgpackHeader(b, len(v), 0x90, 0xdc)
+		var err error
+		for _, e := range v {
+			if b, err = appendMsgpack(b, e); err != nil { return nil, err }
+		}
+		return b, nil
+	case Object:
+		b = appendMsgpackHeader(b, len(v), 0x80, 0xde)
+		var err error
+		for _, m := range v {
+			b = appendMsgpackString(b, m.Key)
+			if b, err = appendMsgpack(b, m.Value); err != nil { return nil, err }
+		}
+		return b, nil
+	}
+	return nil, fmt.Errorf("%T is not a JSON value", v)
+}
+
+func appendMsgpackInt(b []byte, i int64) []byte {
+	switch {
+	case i >= 0 && i <= math.MaxInt8, i < 0 && i >= -32:
+		return append(b, byte(i))
+	case i >= 0 && i <= math.MaxUint8:
+		return append(b, 0xcc, byte(i))
+	case i >= 0 && i <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(b, 0xcd), uint16(i))
+	case i >= 0 && i <= math.MaxUint32:
+		return binary.BigEndian.AppendUint32(append(b, 0xce), uint32(i))
+	case i >= 0:
+		return binary.BigEndian.AppendUint64(append(b, 0xcf), uint64(i))
+	case i >= math.MinInt8:
+		return append(b, 0xd0, byte(i))
+	case i >= math.MinInt16:
+		return binary.BigEndian.AppendUint16(append(b, 0xd1), uint16(i))
+	case i >= math.MinInt32:
+		return binary.BigEndian.AppendUint32(append(b, 0xd2), uint32(i))
+	}
+	return binary.BigEndian.AppendUint64(append(b, 0xd3), uint64(i))
+}
+
+func appendMsgpackString(b []byte, s string) []byte {
+	switch n := len(s); {
+	case n < 32:
+		b = append(b, 0xa0|byte(n))
+	case n <= math.MaxUint8:
+		b = append(b, 0xd9, byte(n))
+	case n <= math.MaxUint16:
+		b = binary.BigEndian.AppendUint16(append(b, 0xda), uint16(n))
+	default:
+		b = binary.BigEndian.AppendUint32(append(b, 0xdb), uint32(n))
+	}
+	return append(b, s...)
+}
+
+// appendMsgpackHeader starts an array or map of n: fix|n below 16, or
+// else the 16 bit form, type16, or the 32 bit one, which follows it.
+func appendMsgpackHeader(b []byte, n int, fix, type16 byte) []byte {
+	switch {
+	case n < 16:
+		return append(b, fix|byte(n))
+	case n <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(b, type16), uint16(n))
+	}
+	return binary.BigEndian.AppendUint32(append(b, type16+1), uint32(n))
+}
+
+func (msgpackCodec) Decode(r io.Reader) (any, error) {
+	data, err := io.ReadAll(r)
+	if err != nil { return nil, err }
+	if len(data) == 0 { return nil, io.EOF }
+	d := &msgpackDecoder{b: data}
+	v, err := d.value(0)
+	if err != nil { return nil, err }
+	if len(d.b) > 0 { return nil, errors.New("unexpected data after the MessagePack value") }
+	return v, nil
+}
+
+type msgpackDecoder struct{ b []byte }
+
+var errMsgpackShort = errors.New("unexpected end of MessagePack input")
+
+func (d *msgpackDecoder) take(n int) ([]byte, error) {
+	if n < 0 || n > len(d.b) { return nil, errMsgpackShort }
+	b := d.b[:n]
+	d.b = d.b[n:]
+	return b, nil
+}
+
+// uint reads an n byte big-endian unsigned integer.
+func (d *msgpackDecoder) uint(n int) (uint64, error) {
+	b, err := d.take(n)
+	if err != nil { return 0, err }
+	var u uint64
+	for _, c := range b { u = u<<8 | uint64(c) }
+	return u, nil
+}
+
+func (d *msgpackDecoder) value(depth int) (any, error) {
+	if depth > msgpackMaxDepth { return nil, errors.New("MessagePack nested too deeply") }
+	tb, err := d.take(1)
+	if err != nil { return nil, err }
+	c := tb[0]
+	switch {
+	case c <= 0x7f:
+		return json.Number(strconv.Itoa(int(c))), nil
+	case c >= 0xe0:
+		return json.Number(strconv.Itoa(int(int8(c)))), nil
+	case c&0xf0 == 0x80:
+		return d.object(int(c&0x0f), depth)
+	case c&0xf0 == 0x90:
+		return d.array(int(c&0x0f), depth)
+	case c&0xe0 == 0xa0:
+		return d.str(int(c & 0x1f))
+	}
+	switch c {
+	case 0xc0:
+		return nil, nil
+	case 0xc2, 0xc3:
+		return c == 0xc3, nil
+	case 0xca:
+		u, err := d.uint(4)
+		if err != nil { return nil, err }
+		return floatNumber(float64(math.Float32frombits(uint32(u))), 32)
+	case 0xcb:
+		u, err := d.uint(8)
+		if err != nil { return nil, err }
+		return floatNumber(math.Float64frombits(u), 64)
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		u, err := d.uint(1 << (c - 0xcc))
+		if err != nil { return nil, err }
+		return json.Number(strconv.FormatUint(u, 10)), nil
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		n := 1 << (c - 0xd0)
+		u, err := d.uint(n)
+		if err != nil { return nil, err }
+		i := int64(u<<(64-8*n)) >> (64 - 8*n) // sign-extended
+		return json.Number(strconv.FormatInt(i, 10)), nil
+	case 0xd9, 0xda, 0xdb:
+		n, err := d.uint(1 << (c - 0xd9))
+		if err != nil { return nil, err }
+		return d.str(int(min(n, math.MaxInt32)))
+	case 0xdc, 0xdd:
+		n, err := d.uint(2 << (c - 0xdc))
+		if err != nil { return nil, err }
+		return d.array(int(min(n, math.MaxInt32)), depth)
+	case 0xde, 0xdf:
+		n, err := d.uint(2 << (c - 0xde))
+		if err != nil { return nil, err }
+		return d.object(int(min(n, math.MaxInt32)), depth)
+	}
+	return nil, fmt.Errorf("MessagePack type 0x%02x has no JSON equivalent", c)
+}
+
+func floatNumber(f float64, bits int) (any, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) { return nil, errors.New("NaN and the infinities aren't JSON numbers") }
+	// Format the way encoding/json does, so a float spells the same in both.
+	var b []byte
+	if bits == 32 { b, _ = json.Marshal(float32(f)) } else { b, _ = json.Marshal(f) }
+	return json.Number(b), nil
+}
+
+func (d *msgpackDecoder) str(n int) (string, error) {
+	b, err := d.take(n)
+	return string(b), err
+}
+
+// array and object size what they allocate by what is left of the
+// input, which each element takes a byte of at least, not by n alone.
+func (d *msgpackDecoder) array(n, depth int) (any, error) {
+	if n > len(d.b) { return nil, errMsgpackShort }
+	arr := make([]any, n)
+	for i := range arr {
+		v, err := d.value(depth + 1)
+		if err != nil { return nil, err }
+		arr[i] = v
+	}
+	return arr, nil
+}
+
+func (d *msgpackDecoder) object(n, depth int) (any, error) {
+	if 2*n > len(d.b) { return nil, errMsgpackShort }
+	obj := make(Object, n)
+	for i := range obj {
+		k, err := d.value(depth + 1)
+		if err != nil { return nil, err }
+		key, isString := k.(string)
+		if !isString { return nil, fmt.Errorf("a map key is %T, not a string", k) }
+		v, err := d.value(depth + 1)
+		if err != nil { return nil, err }
+		obj[i] = Member{key, v}
+	}
+	return obj, nil
+}
@@ -0,0 +1,174 @@
+package codec
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+func init() { Register(XML, "application/xml", "text/xml", "application/problem+xml") }
+
+// XML encodes values as elements named by their keys, in a <response>.
+// Strings are the text of theirs; other values say what they are with a
+// type attribute: number, boolean, null, array (of <item>s) or object,
+// which non-empty objects can leave out. A key that can't name an element
+// is the name attribute of a <member>:
+//
+//	<response><name>Alice</name><version type="number">2</version>
+//	<tags type="array"><item>vip</item></tags>
+//	<metadata><member name="team lead">Bo</member></metadata></response>
+//
+// Decoding reads the same, whatever the root is called; without a type,
+// an element with elements in it is an object and any other a string.
+var XML Codec = xmlCodec{}
+
+type xmlCodec struct{}
+
+func (xmlCodec) ContentType(problem bool) string {
+	if problem { return "application/problem+xml" }
+	return "application/xml"
+}
+
+func (xmlCodec) Encode(w io.Writer, v any) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil { return err }
+	enc := xml.NewEncoder(w)
+	if err := encodeXML(enc, "response", v); err != nil { return err }
+	return enc.Flush()
+}
+
+// xmlNameRE is what encodeXML names elements by; other keys go in a
+// <member>'s name.
+var xmlNameRE = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9._-]*$`)
+
+func encodeXML(enc *xml.Encoder, key string, v any) error {
+	start := xml.StartElement{Name: xml.Name{Local: key}}
+	if !xmlNameRE.MatchString(key) || strings.HasPrefix(strings.ToLower(key), "xml") {
+		start = xml.StartElement{Name: xml.Name{Local: "member"}, Attr: []xml.Attr{{Name: xml.Name{Local: "name"}, Value: key}}}
+	}
+	typed := func(typ string) { start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: "type"}, Value: typ}) }
+	var text string
+	switch v := v.(type) {
+	case nil:
+		typed("null")
+	case bool:
+		typed("boolean")
+		text = strconv.FormatBool(v)
+	case json.Number:
+		typed("number")
+		text = string(v)
+	case string:
+		text = v
+	case []any:
+		typed("array")
+		if err := enc.EncodeToken(start); err != nil { return err }
+		for _, e := range v {
+			if err := encodeXML(enc, "item", e); err != nil { return err }
+		}
+		return enc.EncodeToken(start.End())
+	case Object:
+		if len(v) == 0 { typed("object") }
+		if err := enc.EncodeToken(start); err != nil { return err }
+		for _, m := range v {
+			if err := encodeXML(enc, m.Key, m.Value); err != nil { return err }
+		}
+		return enc.EncodeToken(start.End())
+	default:
+		return fmt.Errorf("%T is not a JSON value", v)
+	}
+	if err := enc.EncodeToken(start); err != nil { return err }
+	if text != "" {
+		if err := enc.EncodeToken(xml.CharData(text)); err != nil { return err }
+	}
+	return enc.EncodeToken(start.End())
+}
+
+func (xmlCodec) Decode(r io.Reader) (any, error) {
+	dec := xml.NewDecoder(r)
+	var root any
+	seen := false
+	for {
+		tok, err := dec.Token()
+		if errors.Is(err, io.EOF) { break }
+		if err != nil { return nil, err }
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if seen { return nil, errors.New("more than one root element") }
+			if _, root, err = decodeXML(dec, t); err != nil { return nil, err }
+			seen = true
+		case xml.CharData:
+			if strings.TrimSpace(string(t)) != "" { return nil, errors.New("text outside the root element") }
+		}
+	}
+	if !seen { return nil, io.EOF }
+	return root, nil
+}
+
+// decodeXML reads the element start opens, up to its end, into its key
+// and value.
+func decodeXML(dec *xml.Decoder, start xml.StartElement) (string, any, error) {
+	key, typ := start.Name.Local, ""
+	for _, a := range start.Attr {
+		switch {
+		case a.Name.Local == "type":
+			typ = a.Value
+		case a.Name.Local == "name" && key == "member":
+			key = a.Value
+		}
+	}
+	var text strings.Builder
+	var members []Member
+	for {
+		tok, err := dec.Token()
+		if err != nil { return "", nil, err }
+		switch t := tok.(type) {
+		case xml.StartElement:
+			k, v, err := decodeXML(dec, t)
+			if err != nil { return "", nil, err }
+			members = append(members, Member{k, v})
+		case xml.CharData:
+			text.Write(t)
+		case xml.EndElement:
+			v, err := xmlValue(typ, text.String(), members)
+			if err != nil { return "", nil, fmt.Errorf("<%s>: %w", start.Name.Local, err) }
+			return key, v, nil
+		}
+	}
+}
+
+func xmlValue(typ, text string, members []Member) (any, error) {
+	if len(members) > 0 && typ != "" && typ != "array" && typ != "object" { return nil, fmt.Errorf("a %s has no elements in it", typ) }
+	switch typ {
+	case "":
+		if len(members) == 0 { return text, nil }
+		if strings.TrimSpace(text) != "" { return nil, errors.New("text next to elements") }
+		return Object(members), nil
+	case "string":
+		return text, nil
+	case "null":
+		return nil, nil
+	case "boolean":
+		switch strings.TrimSpace(text) {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		}
+		return nil, fmt.Errorf("%q is not true or false", text)
+	case "number":
+		n := strings.TrimSpace(text)
+		if !validNumber(n) { return nil, fmt.Errorf("%q is not a number", text) }
+		return json.Number(n), nil
+	case "array":
+		arr := make([]any, len(members))
+		for i, m := range members { arr[i] = m.Value }
+		return arr, nil
+	case "object":
+		return Object(members), nil
+	}
+	return nil, fmt.Errorf("unknown type %q", typ)
+}
@@ -0,0 +1,883 @@
+// Package config loads the service configuration. Each setting can come from
+// a YAML or JSON file, an environment variable or a command-line flag; later
+// sources win: defaults < file < environment < flags.
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/netip"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"gopkg.in/yaml.v3"
+
+	"app/internal/backup"
+	"app/internal/bus"
+	"app/internal/cleanup"
+	"app/internal/ids"
+	"app/internal/normalize"
+	"app/internal/notes"
+	"app/internal/prewrite"
+	"app/internal/resource"
+	"app/internal/store"
+)
+
+type Config struct {
+	Addr          string        `yaml:"addr"`
+	GRPCAddr      string        `yaml:"grpc_addr"` // empty disables the gRPC API
+	AdminAddr     string        `yaml:"admin_addr"` // pprof, expvar and build info; loopback only, empty disables
+	ShutdownGrace time.Duration `yaml:"shutdown_grace"`
+	DrainGrace    time.Duration `yaml:"drain_grace"` // see POST /admin/drain
+	LogLevel      string        `yaml:"log_level"`
+	Environment   string        `yaml:"environment"` // production, staging or development
+	Store         string        `yaml:"store"` // mongo, sql or memory
+	DatabaseURL   string        `yaml:"database_url"` // for STORE=sql
+
+	Mongo struct {
+		URI                    string        `yaml:"uri"`
+		Database               string        `yaml:"database"`
+		Collection             string        `yaml:"collection"`
+		EventsCollection       string        `yaml:"events_collection"`
+		IdempotencyCollection  string        `yaml:"idempotency_collection"`
+		UsersCollection        string        `yaml:"users_collection"`
+		APIKeysCollection      string        `yaml:"apikeys_collection"`
+		AuditCollection        string        `yaml:"audit_collection"`
+		HistoryCollection      string        `yaml:"history_collection"`
+		NotesCollection        string        `yaml:"notes_collection"`
+		JobsCollection         string        `yaml:"jobs_collection"`
+		WebhooksCollection     string        `yaml:"webhooks_collection"`
+		DeliveriesCollection   string        `yaml:"webhook_deliveries_collection"`
+		RevisionsCollection    string        `yaml:"revisions_collection"`
+		CapturesCollection     string        `yaml:"captures_collection"`
+		OutboxCollection       string        `yaml:"outbox_collection"`
+		UsageCollection        string        `yaml:"usage_collection"`
+		ChangesCollection      string        `yaml:"changes_collection"`
+		LeasesCollection       string        `yaml:"leases_collection"`
+		MaxPoolSize            int           `yaml:"max_pool_size"`
+		MinPoolSize            int           `yaml:"min_pool_size"`
+		MaxConnIdleTime        time.Duration `yaml:"max_conn_idle_time"`
+		ServerSelectionTimeout time.Duration `yaml:"server_selection_timeout"`
+		ConnectRetry           time.Duration `yaml:"connect_retry"` // 0 gives up after the first failed ping
+		Migrations             string        `yaml:"migrations"`    // auto applies them at startup; manual leaves them to -migrate
+		ReadPref               string        `yaml:"read_pref"`
+		ListReadPref           string        `yaml:"list_read_pref"`    // of eventual lists and searches
+		ListReadConcern        string        `yaml:"list_read_concern"` // likewise
+		ListConsistency        string        `yaml:"list_consistency"`  // strong or eventual, for lists and searches without ?consistency=
+		WriteConcern           string        `yaml:"write_concern"`
+		CollationLocale        string        `yaml:"collation_locale"` // empty compares names by code point
+		CollationStrength      int           `yaml:"collation_strength"`
+	} `yaml:"mongo"`
+
+	Auth struct {
+		JWTSecret string        `yaml:"jwt_secret"` // empty disables authentication, outside production
+		JWTTTL    time.Duration `yaml:"jwt_ttl"`
+	} `yaml:"auth"`
+
+	Concurrency struct {
+		MaxInFlight      int           `yaml:"max_in_flight"`       // across all routes; <= 0 disables
+		RouteMaxInFlight int           `yaml:"route_max_in_flight"` // per route; <= 0 disables
+		Routes           string        `yaml:"routes"`              // per-route caps overriding it: "GET /api/v1/names/export=4, ..."
+		QueueTimeout     time.Duration `yaml:"queue_timeout"`
+	} `yaml:"concurrency"`
+
+	RateLimit struct {
+		RPS          float64 `yaml:"rps"` // <= 0 disables rate limiting
+		Burst        int     `yaml:"burst"`
+		MaxClients   int     `yaml:"max_clients"`
+		TrustProxy   bool    `yaml:"trust_proxy"`
+		APIKeyHeader string  `yaml:"api_key_header"`
+	} `yaml:"rate_limit"`
+
+	TLS struct {
+		CertFile         string `yaml:"cert_file"`
+		KeyFile          string `yaml:"key_file"`
+		AutocertDomains  string `yaml:"autocert_domains"` // comma-separated; enables Let's Encrypt
+		AutocertCacheDir string `yaml:"autocert_cache_dir"`
+		AutocertEmail    string `yaml:"autocert_email"`
+		RedirectAddr     string `yaml:"redirect_addr"`
+	} `yaml:"tls"`
+
+	// HTTP hardens the API's HTTP server; a timeout of 0 is none. Streams
+	// and imports are spared the read and write timeouts.
+	HTTP struct {
+		ReadHeaderTimeout time.Duration `yaml:"read_header_timeout"`
+		ReadTimeout       time.Duration `yaml:"read_timeout"`  // to read a whole request
+		WriteTimeout      time.Duration `yaml:"write_timeout"` // to answer one; longer than REQUEST_TIMEOUT
+		IdleTimeout       time.Duration `yaml:"idle_timeout"`  // between the requests of a kept-alive connection
+		MaxHeaderBytes    int           `yaml:"max_header_bytes"`
+		HSTSMaxAge        time.Duration `yaml:"hsts_max_age"` // Strict-Transport-Security over HTTPS; 0 sends none
+	} `yaml:"http"`
+
+	Retry struct {
+		MaxAttempts int           `yaml:"max_attempts"` // 1 disables retries
+		BaseDelay   time.Duration `yaml:"base_delay"`
+		MaxDelay    time.Duration `yaml:"max_delay"`
+	} `yaml:"retry"`
+
+	CORS struct {
+		AllowedOrigins   string        `yaml:"allowed_origins"` // comma-separated; * allows any
+		AllowedMethods   string        `yaml:"allowed_methods"`
+		AllowedHeaders   string        `yaml:"allowed_headers"`
+		AllowCredentials bool          `yaml:"allow_credentials"`
+		MaxAge           time.Duration `yaml:"max_age"` // how long browsers may cache a preflight
+	} `yaml:"cors"`
+
+	Compression struct {
+		MinBytes int  `yaml:"min_bytes"` // <= 0 disables
+		Level    int  `yaml:"level"`     // gzip and deflate, 1-9
+		Zstd     bool `yaml:"zstd"`
+	} `yaml:"compression"`
+
+	// Capture records a sample of requests and their responses, bodies and
+	// all, for GET /admin/captures/{request_id} to reproduce client reports.
+	Capture struct {
+		Percent         float64 `yaml:"percent"` // of requests recorded; 0 disables
+		Sink            string  `yaml:"sink"`    // mongo, files or memory
+		MaxBodyBytes    int     `yaml:"max_body_bytes"`
+		CollectionBytes int64   `yaml:"collection_bytes"` // size of the capped collection, for mongo
+		Dir             string  `yaml:"dir"`              // for files
+		FileBytes       int64   `yaml:"file_bytes"`       // a file is rotated at this size
+		Files           int     `yaml:"files"`            // rotated files kept
+	} `yaml:"capture"`
+
+	Jobs struct {
+		Workers     int           `yaml:"workers"` // 0 runs no jobs here; another instance must
+		Lease       time.Duration `yaml:"lease"`
+		Poll        time.Duration `yaml:"poll"`
+		Retention   time.Duration `yaml:"retention"`
+		MaxAttempts int           `yaml:"max_attempts"`
+	} `yaml:"jobs"`
+
+	Webhooks struct {
+		Workers     int           `yaml:"workers"` // 0 delivers none from here; another instance must
+		Timeout     time.Duration `yaml:"timeout"`
+		Poll        time.Duration `yaml:"poll"`
+		MaxAttempts int           `yaml:"max_attempts"`
+		Backoff     time.Duration `yaml:"backoff"`
+		MaxBackoff  time.Duration `yaml:"max_backoff"`
+		Retention   time.Duration `yaml:"retention"`
+	} `yaml:"webhooks"`
+
+	Bus struct {
+		Kind   string `yaml:"kind"`   // kafka, nats or off
+		URL    string `yaml:"url"`    // host:port,... for kafka; nats://[user:pass@]host:port for nats
+		Topic  string `yaml:"topic"`  // the Kafka topic or NATS subject
+		Format string `yaml:"format"` // json or cloudevents
+	} `yaml:"bus"`
+
+	// Outbox, if enabled, tells the webhooks and the bus of each write
+	// through a transactional outbox instead of just after it, so that a
+	// crash loses none (see package outbox).
+	Outbox struct {
+		Enabled   bool          `yaml:"enabled"`
+		Poll      time.Duration `yaml:"poll"`
+		Lease     time.Duration `yaml:"lease"` // how long an event being published is held before it's retried
+		Retention time.Duration `yaml:"retention"`
+	} `yaml:"outbox"`
+
+	// Changes, if enabled, logs every write to the names for clients to
+	// sync by with GET /names/changes (see package changes).
+	Changes struct {
+		Enabled   bool          `yaml:"enabled"`
+		Retention time.Duration `yaml:"retention"` // how long changes are kept; clients offline longer reload
+		MaxWait   time.Duration `yaml:"max_wait"`  // the longest ?wait= a client may long-poll for
+		Poll      time.Duration `yaml:"poll"`      // how often a long poll looks for other servers' writes
+	} `yaml:"changes"`
+
+	// Usage, if enabled, counts what each API key uses and holds the keys
+	// to the quotas (see package usage); a quota of 0 is off.
+	Usage struct {
+		Enabled         bool          `yaml:"enabled"`
+		Flush           time.Duration `yaml:"flush"` // how often counts are stored, and other servers' read
+		DailyRequests   int64         `yaml:"daily_requests"`
+		MonthlyRequests int64         `yaml:"monthly_requests"`
+		DailyBytes      int64         `yaml:"daily_bytes"` // of request and response bodies together
+		MonthlyBytes    int64         `yaml:"monthly_bytes"`
+	} `yaml:"usage"`
+
+	// Leader, if enabled, elects one of the instances sharing the store to
+	// run the cleanup, the outbox and the webhook deliveries; the others
+	// don't (see package leader).
+	Leader struct {
+		Enabled bool          `yaml:"enabled"`
+		ID      string        `yaml:"id"`    // this instance's; default host name and process ID
+		TTL     time.Duration `yaml:"ttl"`   // how long the work may go undone once a leader dies
+		Renew   time.Duration `yaml:"renew"` // at most a third of TTL
+	} `yaml:"leader"`
+
+	// Shadow, unless off, copies every write to the names onto a second
+	// store, for moving them to another database without downtime (see
+	// package shadow). MongoDatabase defaults to the primary's.
+	Shadow struct {
+		Store         string        `yaml:"store"`        // sql, mongo or off
+		DatabaseURL   string        `yaml:"database_url"` // for store sql
+		MongoURI      string        `yaml:"mongo_uri"`    // for store mongo
+		MongoDatabase string        `yaml:"mongo_database"`
+		Timeout       time.Duration `yaml:"timeout"` // for each write copied
+	} `yaml:"shadow"`
+
+	// Chaos injects faults into every request, at rates from 0 to 1, for
+	// testing clients' retries and the alerting (see package chaos). Outside
+	// production a request can also ask for its own with an X-Chaos header.
+	Chaos struct {
+		Latency     time.Duration `yaml:"latency"`
+		LatencyRate float64       `yaml:"latency_rate"`
+		ErrorRate   float64       `yaml:"error_rate"`   // of requests answered 500
+		TimeoutRate float64       `yaml:"timeout_rate"` // of calls to the names store timing out
+	} `yaml:"chaos"`
+
+	// Backup is where POST /admin/backup writes dumps of the names and
+	// /admin/restore reads them back (see package backup): a directory, or
+	// a bucket of S3 or a store speaking its API. Without either, both
+	// endpoints answer 404.
+	Backup struct {
+		Dir         string `yaml:"dir"`
+		S3URL       string `yaml:"s3_url"`    // https://host/bucket[/prefix], path-style
+		S3Region    string `yaml:"s3_region"` // default us-east-1
+		S3AccessKey string `yaml:"s3_access_key"`
+		S3SecretKey string `yaml:"s3_secret_key"`
+	} `yaml:"backup"`
+
+	// Normalize is the rules names are put in their canonical form by, the
+	// name_normalized lookups match on (see package normalize). Changing
+	// them leaves the forms already stored until POST /admin/normalize.
+	Normalize struct {
+		StripDiacritics bool   `yaml:"strip_diacritics"`
+		LowerWords      string `yaml:"lower_words"` // comma-separated particles kept lower-case past a name's first word
+	} `yaml:"normalize"`
+
+	Cleanup struct {
+		Schedule         string        `yaml:"schedule"`          // cron expression, or off
+		DeletedRetention time.Duration `yaml:"deleted_retention"` // 0 keeps the trash until emptied by hand
+		BatchSize        int           `yaml:"batch_size"`
+	} `yaml:"cleanup"`
+
+	Cache struct {
+		Backend    string        `yaml:"backend"` // memory, redis or off
+		TTL        time.Duration `yaml:"ttl"`
+		MaxEntries int           `yaml:"max_entries"` // for the memory backend
+		RedisURL   string        `yaml:"redis_url"`
+	} `yaml:"cache"`
+
+	MaxBodyBytes    int64         `yaml:"max_body_bytes"`
+	RequestTimeout  time.Duration `yaml:"request_timeout"`   // <= 0 disables
+	LegacySunset    string        `yaml:"legacy_sunset"`     // YYYY-MM-DD; empty sends no Sunset header
+	Envelope        bool          `yaml:"response_envelope"` // {data, meta, links} bodies unless Accept says envelope=false
+	IdempotencyTTL  time.Duration `yaml:"idempotency_ttl"`
+	AllowHardDelete bool          `yaml:"allow_hard_delete"`
+	NotesOnDelete   string        `yaml:"notes_on_delete"` // block or cascade: what hard-deleting a name with notes does
+	IDStrategy      string        `yaml:"id_strategy"`     // objectid, uuid or slug: the key new names get besides their ObjectID
+	ImportMaxBytes  int64         `yaml:"import_max_bytes"`
+	ResourcesFile   string        `yaml:"resources_file"` // YAML declaring the resources served next to names; empty declares none
+	PrewriteFile    string        `yaml:"prewrite_hooks_file"` // YAML declaring the hooks run on names before they are written
+
+	// PrintConfig asks for the effective configuration to be dumped instead
+	// of starting the server. Flag only.
+	PrintConfig bool `yaml:"-"`
+	// Migrate asks for the store's migrations to be applied, or with
+	// MigrateTo >= 0 for MongoDB's to be applied or undone up to that
+	// version, instead of starting the server. Flags only.
+	Migrate   bool `yaml:"-"`
+	MigrateTo int  `yaml:"-"`
+}
+
+func Default() *Config {
+	c := &Config{Addr: ":8080", GRPCAddr: ":9090", ShutdownGrace: 15 * time.Second, DrainGrace: 10 * time.Second, LogLevel: "info", Environment: "production", Store: "mongo", DatabaseURL: "sqlite:names.db", MigrateTo: -1}
+	c.Mongo.URI = "mongodb://localhost:27017"
+	c.Mongo.Database = "testdb"
+	c.Mongo.Collection = "names"
+	c.Mongo.EventsCollection = "name_events"
+	c.Mongo.IdempotencyCollection = "idempotency_keys"
+	c.Mongo.UsersCollection = "users"
+	c.Mongo.APIKeysCollection = "apikeys"
+	c.Mongo.AuditCollection = "audit"
+	c.Mongo.HistoryCollection = "names_history"
+	c.Mongo.NotesCollection = "notes"
+	c.Mongo.JobsCollection = "jobs"
+	c.Mongo.WebhooksCollection = "webhooks"
+	c.Mongo.DeliveriesCollection = "webhook_deliveries"
+	c.Mongo.RevisionsCollection = "name_revisions"
+	c.Mongo.CapturesCollection = "captures"
+	c.Mongo.OutboxCollection = "outbox"
+	c.Mongo.UsageCollection = "usage"
+	c.Mongo.ChangesCollection = "changes"
+	c.Mongo.LeasesCollection = "leases"
+	c.Mongo.MaxPoolSize = 100
+	c.Mongo.MaxConnIdleTime = 5 * time.Minute
+	c.Mongo.ServerSelectionTimeout = 30 * time.Second
+	c.Mongo.ConnectRetry = time.Minute
+	c.Mongo.Migrations = "auto"
+	c.Mongo.CollationStrength = 3
+	c.Auth.JWTTTL = time.Hour
+	c.RateLimit.RPS, c.RateLimit.Burst, c.RateLimit.MaxClients = 10, 20, 10000
+	c.Concurrency.QueueTimeout = 250 * time.Millisecond
+	c.TLS.AutocertCacheDir = "autocert-cache"
+	c.Retry.MaxAttempts, c.Retry.BaseDelay, c.Retry.MaxDelay = 3, 50*time.Millisecond, time.Second
+	c.CORS.AllowedOrigins = "*"
+	c.CORS.AllowedMethods = "GET, POST, PUT, PATCH, DELETE"
+	c.CORS.AllowedHeaders = "Content-Type, Authorization, X-Request-ID, Idempotency-Key, X-API-Key, Last-Event-ID, If-Match, If-None-Match, If-Modified-Since"
+	c.CORS.MaxAge = 10 * time.Minute
+	c.Compression.MinBytes, c.Compression.Level, c.Compression.Zstd = 1024, 6, true
+	c.HTTP.ReadHeaderTimeout, c.HTTP.ReadTimeout, c.HTTP.WriteTimeout, c.HTTP.IdleTimeout = 10*time.Second, time.Minute, time.Minute, 2*time.Minute
+	c.HTTP.MaxHeaderBytes, c.HTTP.HSTSMaxAge = 64<<10, 365*24*time.Hour
+	c.Capture.Sink, c.Capture.MaxBodyBytes, c.Capture.CollectionBytes = "mongo", 64<<10, 256<<20
+	c.Capture.Dir, c.Capture.FileBytes, c.Capture.Files = "captures", 100<<20, 5
+	c.Jobs.Workers, c.Jobs.Lease, c.Jobs.Poll, c.Jobs.Retention, c.Jobs.MaxAttempts = 2, time.Minute, 5*time.Second, 7*24*time.Hour, 3
+	c.Webhooks.Workers, c.Webhooks.Timeout, c.Webhooks.Poll, c.Webhooks.MaxAttempts = 2, 10*time.Second, 5*time.Second, 8
+	c.Webhooks.Backoff, c.Webhooks.MaxBackoff, c.Webhooks.Retention = 30*time.Second, time.Hour, 7*24*time.Hour
+	c.Bus.Kind, c.Bus.Topic, c.Bus.Format = "off", "names.events", bus.FormatJSON
+	c.Outbox.Poll, c.Outbox.Lease, c.Outbox.Retention = time.Second, 30*time.Second, 24*time.Hour
+	c.Changes.Retention, c.Changes.MaxWait, c.Changes.Poll = 30*24*time.Hour, time.Minute, time.Second
+	c.Usage.Flush = 10 * time.Second
+	c.Leader.TTL, c.Leader.Renew = 15*time.Second, 5*time.Second
+	c.Shadow.Store, c.Shadow.Timeout = "off", 5*time.Second
+	c.Mongo.ListReadPref, c.Mongo.ListReadConcern, c.Mongo.ListConsistency = "secondaryPreferred", "local", store.Strong
+	c.Normalize.LowerWords = strings.Join(normalize.DefaultLowerWords, ",")
+	c.Cleanup.Schedule, c.Cleanup.BatchSize = "@hourly", 500
+	c.Cache.Backend, c.Cache.TTL, c.Cache.MaxEntries = "memory", 30*time.Second, 10000
+	c.IdempotencyTTL = 24 * time.Hour
+	c.MaxBodyBytes = 1 << 20
+	c.RequestTimeout = 30 * time.Second
+	c.LegacySunset = "2027-04-15"
+	c.ImportMaxBytes = 10 << 20
+	c.NotesOnDelete = notes.Block
+	c.IDStrategy = ids.ObjectID
+	return c
+}
+
+// setting ties a Config field to its environment variable. The flag name is
+// derived from it: MONGO_URI -> --mongo-uri.
+type setting struct {
+	env   string
+	usage string
+	ptr   any // *string, *int, *int64, *float64, *bool or *time.Duration
+}
+
+func (c *Config) settings() []setting {
+	return []setting{
+		{"ADDR", "HTTP listen address", &c.Addr},
+		{"GRPC_ADDR", "gRPC listen address; empty disables the gRPC API", &c.GRPCAddr},
+		{"ADMIN_ADDR", "listen address of /debug/pprof, /debug/vars and /debug/buildinfo, such as 127.0.0.1:6060; must be a loopback one, empty disables them", &c.AdminAddr},
+		{"SHUTDOWN_GRACE", "how long in-flight requests get on shutdown", &c.ShutdownGrace},
+		{"DRAIN_GRACE", "how long after POST /admin/drain new requests are still served, while the load balancer sees /readyz fail", &c.DrainGrace},
+		{"LOG_LEVEL", "debug, info, warn or error", &c.LogLevel},
+		{"ENVIRONMENT", "production, staging or development; outside production, POST /admin/seed makes up names for demos", &c.Environment},
+		{"STORE", "storage backend: mongo, sql, or memory (nothing is persisted)", &c.Store},
+		{"DATABASE_URL", "for STORE=sql: postgres://... or sqlite:<file>", &c.DatabaseURL},
+		{"MONGO_URI", "MongoDB connection string", &c.Mongo.URI},
+		{"DB_NAME", "database name", &c.Mongo.Database},
+		{"COLLECTION", "names collection", &c.Mongo.Collection},
+		{"EVENTS_COLLECTION", "audit events collection", &c.Mongo.EventsCollection},
+		{"IDEMPOTENCY_COLLECTION", "Idempotency-Key collection", &c.Mongo.IdempotencyCollection},
+		{"USERS_COLLECTION", "users collection", &c.Mongo.UsersCollection},
+		{"APIKEYS_COLLECTION", "API keys collection", &c.Mongo.APIKeysCollection},
+		{"AUDIT_COLLECTION", "audit log collection", &c.Mongo.AuditCollection},
+		{"HISTORY_COLLECTION", "past versions of names", &c.Mongo.HistoryCollection},
+		{"NOTES_COLLECTION", "notes about names", &c.Mongo.NotesCollection},
+		{"JOBS_COLLECTION", "background jobs", &c.Mongo.JobsCollection},
+		{"WEBHOOKS_COLLECTION", "webhooks", &c.Mongo.WebhooksCollection},
+		{"WEBHOOK_DELIVERIES_COLLECTION", "webhook deliveries and their logs", &c.Mongo.DeliveriesCollection},
+		{"REVISIONS_COLLECTION", "the count of writes to each tenant's names", &c.Mongo.RevisionsCollection},
+		{"CAPTURES_COLLECTION", "for CAPTURE_SINK=mongo, the capped collection of recorded requests", &c.Mongo.CapturesCollection},
+		{"OUTBOX_COLLECTION", "for OUTBOX, the events still to publish, and those published lately", &c.Mongo.OutboxCollection},
+		{"USAGE_COLLECTION", "for USAGE, what each API key used a day", &c.Mongo.UsageCollection},
+		{"CHANGES_COLLECTION", "for CHANGES, the log of writes to the names", &c.Mongo.ChangesCollection},
+		{"LEASES_COLLECTION", "for LEADER_ELECTION, the lease of the leader", &c.Mongo.LeasesCollection},
+		{"MONGO_MAX_POOL_SIZE", "max connections in the pool", &c.Mongo.MaxPoolSize},
+		{"MONGO_MIN_POOL_SIZE", "connections kept open when idle", &c.Mongo.MinPoolSize},
+		{"MONGO_MAX_CONN_IDLE_TIME", "close pooled connections idle this long", &c.Mongo.MaxConnIdleTime},
+		{"MONGO_SERVER_SELECTION_TIMEOUT", "how long an operation waits for a suitable server", &c.Mongo.ServerSelectionTimeout},
+		{"MONGO_CONNECT_RETRY", "how long startup keeps trying to reach MongoDB; 0 gives up at the first failure", &c.Mongo.ConnectRetry},
+		{"MONGO_MIGRATIONS", "auto applies pending MongoDB migrations at startup; manual refuses to start until -migrate has", &c.Mongo.Migrations},
+		{"READ_PREF", "primary, primaryPreferred, secondary, secondaryPreferred or nearest; lists and searches read strong from the primary or eventual per LIST_READ_PREF", &c.Mongo.ReadPref},
+		{"LIST_READ_PREF", "read preference of eventual lists, searches and exports, e.g. secondaryPreferred", &c.Mongo.ListReadPref},
+		{"LIST_READ_CONCERN", "read concern of eventual lists, searches and exports: local, available or majority", &c.Mongo.ListReadConcern},
+		{"LIST_CONSISTENCY", "strong or eventual: what lists and searches read without ?consistency=", &c.Mongo.ListConsistency},
+		{"WRITE_CONCERN", "majority or a number of nodes", &c.Mongo.WriteConcern},
+		{"MONGO_COLLATION_LOCALE", "ICU locale names sort and are told apart by, e.g. fr; empty compares code points", &c.Mongo.CollationLocale},
+		{"MONGO_COLLATION_STRENGTH", "1 ignores accents and case, 2 only case, 3 neither; up to 5", &c.Mongo.CollationStrength},
+		{"JWT_SECRET", "HS256 signing secret; required in production, elsewhere empty disables authentication", &c.Auth.JWTSecret},
+		{"JWT_TTL", "token lifetime", &c.Auth.JWTTTL},
+		{"RATE_LIMIT_RPS", "requests per second per client; <= 0 disables", &c.RateLimit.RPS},
+		{"RATE_LIMIT_BURST", "token bucket size", &c.RateLimit.Burst},
+		{"RATE_LIMIT_MAX_CLIENTS", "clients tracked at once", &c.RateLimit.MaxClients},
+		{"TRUST_PROXY", "take the client IP from X-Forwarded-For", &c.RateLimit.TrustProxy},
+		// Only keys this server has found valid get buckets, by their hash; a
+		// request with an unknown or made-up key counts against its IP's.
+		{"RATE_LIMIT_API_KEY_HEADER", "bucket requests by the valid API key in this header, normally X-API-Key, rather than by IP", &c.RateLimit.APIKeyHeader},
+		{"MAX_IN_FLIGHT", "requests served at once; more wait, then get a 503; <= 0 disables", &c.Concurrency.MaxInFlight},
+		{"ROUTE_MAX_IN_FLIGHT", "requests served at once on each route; <= 0 disables", &c.Concurrency.RouteMaxInFlight},
+		{"ROUTE_LIMITS", "requests served at once on particular routes, overriding ROUTE_MAX_IN_FLIGHT: \"GET /api/v1/names/export=4, POST /api/v1/names/import=2\"", &c.Concurrency.Routes},
+		{"CONCURRENCY_QUEUE_TIMEOUT", "how long a request over a concurrency cap waits for a slot before its 503", &c.Concurrency.QueueTimeout},
+		{"TLS_CERT", "PEM certificate file; with TLS_KEY, ADDR serves HTTPS and HTTP/2", &c.TLS.CertFile},
+		{"TLS_KEY", "PEM private key file for TLS_CERT", &c.TLS.KeyFile},
+		{"AUTOCERT_DOMAINS", "comma-separated domains to get Let's Encrypt certificates for, instead of TLS_CERT", &c.TLS.AutocertDomains},
+		{"AUTOCERT_CACHE_DIR", "where Let's Encrypt certificates are kept", &c.TLS.AutocertCacheDir},
+		{"AUTOCERT_EMAIL", "contact address given to Let's Encrypt", &c.TLS.AutocertEmail},
+		{"HTTP_REDIRECT_ADDR", "plain HTTP listener redirecting to HTTPS; with autocert it answers the challenges and defaults to :80", &c.TLS.RedirectAddr},
+		{"HTTP_READ_HEADER_TIMEOUT", "how long a client may take to send a request's headers; 0 is no limit", &c.HTTP.ReadHeaderTimeout},
+		{"HTTP_READ_TIMEOUT", "how long a client may take to send a whole request, streams and imports excepted; 0 is no limit", &c.HTTP.ReadTimeout},
+		{"HTTP_WRITE_TIMEOUT", "how long a response may take, streams and imports excepted; longer than REQUEST_TIMEOUT, 0 is no limit", &c.HTTP.WriteTimeout},
+		{"HTTP_IDLE_TIMEOUT", "how long a kept-alive connection may wait for its next request; 0 is no limit", &c.HTTP.IdleTimeout},
+		{"HTTP_MAX_HEADER_BYTES", "largest request headers accepted, request line included", &c.HTTP.MaxHeaderBytes},
+		{"HSTS_MAX_AGE", "Strict-Transport-Security max-age sent over HTTPS, directly or behind a proxy setting X-Forwarded-Proto; 0 sends none", &c.HTTP.HSTSMaxAge},
+		{"RETRY_MAX_ATTEMPTS", "tries per MongoDB operation when it fails transiently, the first included; 1 disables retries", &c.Retry.MaxAttempts},
+		{"RETRY_BASE_DELAY", "wait before the first retry, doubled for each one after", &c.Retry.BaseDelay},
+		{"RETRY_MAX_DELAY", "longest wait between retries", &c.Retry.MaxDelay},
+		{"CORS_ALLOWED_ORIGINS", "comma-separated origins browsers may call from, or * for any", &c.CORS.AllowedOrigins},
+		{"CORS_ALLOWED_METHODS", "comma-separated methods allowed cross-origin", &c.CORS.AllowedMethods},
+		{"CORS_ALLOWED_HEADERS", "comma-separated request headers allowed cross-origin", &c.CORS.AllowedHeaders},
+		{"CORS_ALLOW_CREDENTIALS", "let browsers send cookies and Authorization cross-origin; needs explicit origins", &c.CORS.AllowCredentials},
+		{"CORS_MAX_AGE", "how long browsers may cache a preflight response", &c.CORS.MaxAge},
+		{"COMPRESS_MIN_BYTES", "compress text responses at least this large for clients that accept it; <= 0 disables", &c.Compression.MinBytes},
+		{"COMPRESS_LEVEL", "gzip and deflate level, 1 (fastest) to 9 (smallest)", &c.Compression.Level},
+		{"COMPRESS_ZSTD", "also offer zstd compression", &c.Compression.Zstd},
+		{"CAPTURE_PERCENT", "percentage of requests recorded in full, for debugging; 0 disables", &c.Capture.Percent},
+		{"CAPTURE_SINK", "where recorded requests go: mongo (a capped collection), files, or memory (this process only)", &c.Capture.Sink},
+		{"CAPTURE_MAX_BODY_BYTES", "bytes of each request and response body recorded", &c.Capture.MaxBodyBytes},
+		{"CAPTURE_COLLECTION_BYTES", "for CAPTURE_SINK=mongo, the size the collection is capped at when created", &c.Capture.CollectionBytes},
+		{"CAPTURE_DIR", "for CAPTURE_SINK=files, the directory of the files", &c.Capture.Dir},
+		{"CAPTURE_FILE_BYTES", "for CAPTURE_SINK=files, the size at which a file is rotated", &c.Capture.FileBytes},
+		{"CAPTURE_FILES", "for CAPTURE_SINK=files, the rotated files kept", &c.Capture.Files},
+		{"JOB_WORKERS", "background jobs run at once by this instance; 0 leaves them to others", &c.Jobs.Workers},
+		{"JOB_LEASE", "how long a worker holds a job between renewals; others take it over once it lapses", &c.Jobs.Lease},
+		{"JOB_POLL", "how often idle workers look for jobs queued by other instances", &c.Jobs.Poll},
+		{"JOB_RETENTION", "how long finished jobs, and their output, are kept", &c.Jobs.Retention},
+		{"JOB_MAX_ATTEMPTS", "workers a job may outlive before it fails", &c.Jobs.MaxAttempts},
+		{"WEBHOOK_WORKERS", "webhook deliveries POSTed at once by this instance; 0 leaves them to others", &c.Webhooks.Workers},
+		{"WEBHOOK_TIMEOUT", "how long a webhook has to answer", &c.Webhooks.Timeout},
+		{"WEBHOOK_POLL", "how often idle workers look for deliveries due, such as retries", &c.Webhooks.Poll},
+		{"WEBHOOK_MAX_ATTEMPTS", "POSTs of a delivery before it fails for good", &c.Webhooks.MaxAttempts},
+		{"WEBHOOK_BACKOFF", "wait before the first retry of a delivery, doubled for each one after", &c.Webhooks.Backoff},
+		{"WEBHOOK_MAX_BACKOFF", "the longest wait between retries", &c.Webhooks.MaxBackoff},
+		{"WEBHOOK_RETENTION", "how long finished deliveries, and their logs, are kept", &c.Webhooks.Retention},
+		{"BUS", "publish name changes to a message bus: kafka, nats or off", &c.Bus.Kind},
+		{"BUS_URL", "for BUS=kafka, brokers as host:port,...; for BUS=nats, nats://[user:pass@]host:port", &c.Bus.URL},
+		{"BUS_TOPIC", "the Kafka topic or NATS subject published to", &c.Bus.Topic},
+		{"BUS_FORMAT", "how changes are published: json, or cloudevents (structured JSON)", &c.Bus.Format},
+		{"OUTBOX", "tell the webhooks and the bus of writes through a transactional outbox, at least once", &c.Outbox.Enabled},
+		{"OUTBOX_POLL", "how often an idle outbox dispatcher looks for events, such as retries", &c.Outbox.Poll},
+		{"OUTBOX_LEASE", "how long an event the dispatcher failed to publish waits to be retried", &c.Outbox.Lease},
+		{"OUTBOX_RETENTION", "how long published outbox events are kept", &c.Outbox.Retention},
+		{"CHANGES", "log every write to the names, for clients to sync by with GET /names/changes", &c.Changes.Enabled},
+		{"CHANGES_RETENTION", "how long logged changes are kept; clients that last synced longer ago reload", &c.Changes.Retention},
+		{"CHANGES_MAX_WAIT", "the longest GET /names/changes?wait= may wait for a change", &c.Changes.MaxWait},
+		{"CHANGES_POLL", "how often a waiting GET /names/changes looks for other servers' writes", &c.Changes.Poll},
+		{"LEADER_ELECTION", "run the cleanup, the outbox and the webhook deliveries only in the instance elected leader, not in every one", &c.Leader.Enabled},
+		{"LEADER_ID", "this instance's name in the election; default its host name and process ID", &c.Leader.ID},
+		{"LEADER_TTL", "how long the leader's lease lasts unrenewed, before another instance takes over", &c.Leader.TTL},
+		{"LEADER_RENEW", "how often the leader renews its lease, and the others try to take it", &c.Leader.Renew},
+		{"USAGE", "count the requests and bytes of each API key, for GET /usage and the QUOTA_* quotas", &c.Usage.Enabled},
+		{"USAGE_FLUSH", "how often usage counts are stored, and so how far behind other servers' a key's can be", &c.Usage.Flush},
+		{"QUOTA_DAILY_REQUESTS", "requests an API key may make a day (UTC), then 429s; 0 is no limit", &c.Usage.DailyRequests},
+		{"QUOTA_MONTHLY_REQUESTS", "requests an API key may make a calendar month; 0 is no limit", &c.Usage.MonthlyRequests},
+		{"QUOTA_DAILY_BYTES", "bytes of request and response bodies an API key may move a day; 0 is no limit", &c.Usage.DailyBytes},
+		{"QUOTA_MONTHLY_BYTES", "bytes of request and response bodies an API key may move a calendar month; 0 is no limit", &c.Usage.MonthlyBytes},
+		{"SHADOW_STORE", "also copy every write to the names to a second store, to migrate to: sql, mongo or off", &c.Shadow.Store},
+		{"SHADOW_DATABASE_URL", "for SHADOW_STORE=sql: postgres://... or sqlite:<file>", &c.Shadow.DatabaseURL},
+		{"SHADOW_MONGO_URI", "for SHADOW_STORE=mongo: the MongoDB to copy to", &c.Shadow.MongoURI},
+		{"SHADOW_MONGO_DATABASE", "for SHADOW_STORE=mongo: its database; default MONGO_DATABASE", &c.Shadow.MongoDatabase},
+		{"SHADOW_TIMEOUT", "how long a write copied to the shadow store may take before it is given up on", &c.Shadow.Timeout},
+		{"CHAOS_LATENCY", "latency to add to requests on purpose, at CHAOS_LATENCY_RATE; not in production", &c.Chaos.Latency},
+		{"CHAOS_LATENCY_RATE", "share of requests, 0 to 1, to add CHAOS_LATENCY to", &c.Chaos.LatencyRate},
+		{"CHAOS_ERROR_RATE", "share of requests, 0 to 1, to answer 500 on purpose; not in production", &c.Chaos.ErrorRate},
+		{"CHAOS_TIMEOUT_RATE", "share of calls to the names store, 0 to 1, to fail as MongoDB timeouts on purpose; not in production", &c.Chaos.TimeoutRate},
+		{"BACKUP_DIR", "directory POST /admin/backup writes dumps of the names to", &c.Backup.Dir},
+		{"BACKUP_S3_URL", "or the S3-compatible bucket it writes them to: https://host/bucket[/prefix]", &c.Backup.S3URL},
+		{"BACKUP_S3_REGION", "the bucket's region; default us-east-1", &c.Backup.S3Region},
+		{"BACKUP_S3_ACCESS_KEY", "the access key ID signing requests to the bucket", &c.Backup.S3AccessKey},
+		{"BACKUP_S3_SECRET_KEY", "and its secret", &c.Backup.S3SecretKey},
+		{"NAME_STRIP_DIACRITICS", "drop diacritics from the normalized form of names, so that Jose matches José", &c.Normalize.StripDiacritics},
+		{"NAME_LOWER_WORDS", "particles such as van or de the normalized form keeps lower-case past a name's first word, comma-separated", &c.Normalize.LowerWords},
+		{"CLEANUP_SCHEDULE", "when expired names are removed: a cron expression (UTC), @hourly, @daily, ... or off", &c.Cleanup.Schedule},
+		{"CLEANUP_DELETED_RETENTION", "also remove names soft-deleted longer ago than this; 0 keeps them", &c.Cleanup.DeletedRetention},
+		{"CLEANUP_BATCH_SIZE", "names the cleanup reads at a time", &c.Cleanup.BatchSize},
+		{"CACHE", "name read cache: memory, redis (shared by replicas) or off", &c.Cache.Backend},
+		{"CACHE_TTL", "how long a cached read is served", &c.Cache.TTL},
+		{"CACHE_MAX_ENTRIES", "entries kept by the memory cache", &c.Cache.MaxEntries},
+		{"REDIS_URL", "for CACHE=redis: redis://[:password@]host:port/db", &c.Cache.RedisURL},
+		{"MAX_BODY_BYTES", "largest accepted JSON request body", &c.MaxBodyBytes},
+		{"REQUEST_TIMEOUT", "deadline for each request, streams and imports excepted; <= 0 disables", &c.RequestTimeout},
+		{"LEGACY_SUNSET", "date (YYYY-MM-DD) the unversioned API paths go away, sent in their Sunset header", &c.LegacySunset},
+		{"RESPONSE_ENVELOPE", "wrap JSON bodies in {data, meta, links} unless the client's Accept has envelope=false; off, only those asking with envelope=true get it", &c.Envelope},
+		{"IDEMPOTENCY_TTL", "how long Idempotency-Key responses are kept", &c.IdempotencyTTL},
+		{"ALLOW_HARD_DELETE", "allow DELETE ...?hard=true", &c.AllowHardDelete},
+		{"NOTES_ON_DELETE", "hard-deleting a name with notes: block (refused) or cascade (the notes go too)", &c.NotesOnDelete},
+		{"ID_STRATEGY", "what new names get to be addressed by besides their ObjectID: objectid (nothing more), uuid (a UUIDv7) or slug (from the name)", &c.IDStrategy},
+		{"IMPORT_MAX_BYTES", "largest accepted CSV import", &c.ImportMaxBytes},
+		{"RESOURCES_FILE", "YAML file declaring the resources served at /api/v1/{resource}", &c.ResourcesFile},
+		{"PREWRITE_HOOKS_FILE", "YAML file declaring the hooks that check, normalize or fill in names before any write stores them", &c.PrewriteFile},
+	}
+}
+
+// Production reports whether the service runs in production, where the
+// endpoints that make up data are off.
+func (c *Config) Production() bool { return c.Environment == "production" }
+
+// Split breaks a comma-separated setting into its trimmed, non-empty items.
+func Split(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ' ' })
+}
+
+// RouteLimits parses a concurrency.routes setting: comma-separated
+// "METHOD /path=N", the route pattern as served and its cap.
+func RouteLimits(s string) (map[string]int, error) {
+	limits := map[string]int{}
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item == "" { continue }
+		pattern, v, found := strings.Cut(item, "=")
+		method, path, spaced := strings.Cut(strings.TrimSpace(pattern), " ")
+		n, err := strconv.Atoi(strings.TrimSpace(v))
+		if !found || !spaced || !strings.HasPrefix(path, "/") || err != nil || n < 1 {
+			return nil, fmt.Errorf("%q is not METHOD /path=N with N >= 1", item)
+		}
+		limits[strings.ToUpper(method)+" "+path] = n
+	}
+	return limits, nil
+}
+
+// loopback reports whether addr listens on a loopback address only, so
+// that nothing off the host can reach it. A hostname other than localhost
+// could resolve to anything, so it doesn't count.
+func loopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil { return false }
+	if host == "localhost" { return true }
+	ip, err := netip.ParseAddr(host)
+	return err == nil && ip.IsLoopback()
+}
+
+func flagName(env string) string { return strings.ReplaceAll(strings.ToLower(env), "_", "-") }
+
+// Load builds the configuration from args (without the program name), the
+// environment and, if --config or CONFIG_FILE names one, a YAML or JSON file.
+// It returns flag.ErrHelp if -h was given.
+func Load(args []string) (*Config, error) {
+	c := Default()
+	settings := c.settings()
+
+	// Flags are parsed first (the file path may be one) but applied last.
+	fs := flag.NewFlagSet("app", flag.ContinueOnError)
+	file := fs.String("config", os.Getenv("CONFIG_FILE"), "YAML or JSON config file (env CONFIG_FILE)")
+	fs.BoolVar(&c.PrintConfig, "print-config", false, "print the effective configuration and exit")
+	fs.BoolVar(&c.Migrate, "migrate", false, "apply the store's pending migrations and exit")
+	fs.IntVar(&c.MigrateTo, "migrate-to", -1, "with -migrate, apply or undo MongoDB's migrations up to this version")
+	flagged := map[string]string{}
+	for _, s := range settings {
+		name, usage := flagName(s.env), s.usage+" (env "+s.env+")"
+		record := func(v string) error { flagged[name] = v; return nil }
+		if _, isBool := s.ptr.(*bool); isBool {
+			fs.BoolFunc(name, usage, record)
+		} else {
+			fs.Func(name, usage, record)
+		}
+	}
+	if err := fs.Parse(args); err != nil { return nil, err }
+	if fs.NArg() > 0 { return nil, fmt.Errorf("unexpected arguments: %q", fs.Args()) }
+
+	if *file != "" {
+		if err := c.loadFile(*file); err != nil { return nil, err }
+	}
+	for _, s := range settings {
+		if v := os.Getenv(s.env); v != "" {
+			if err := parseInto(s.ptr, v); err != nil { return nil, fmt.Errorf("%s: %w", s.env, err) }
+		}
+	}
+	for _, s := range settings {
+		if v, ok := flagged[flagName(s.env)]; ok {
+			if err := parseInto(s.ptr, v); err != nil { return nil, fmt.Errorf("--%s: %w", flagName(s.env), err) }
+		}
+	}
+	return c, c.Validate()
+}
+
+// loadFile overlays the settings present in path. JSON is valid YAML, so
+// one decoder handles both; unknown keys are rejected to catch typos.
+func (c *Config) loadFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil { return err }
+	defer f.Close()
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+	if err := dec.Decode(c); err != nil && !errors.Is(err, io.EOF) { return fmt.Errorf("%s: %w", path, err) }
+	return nil
+}
+
+func parseInto(ptr any, v string) error {
+	var err error
+	switch p := ptr.(type) {
+	case *string:
+		*p = v
+	case *int:
+		*p, err = strconv.Atoi(v)
+	case *int64:
+		*p, err = strconv.ParseInt(v, 10, 64)
+	case *float64:
+		*p, err = strconv.ParseFloat(v, 64)
+	case *bool:
+		*p, err = strconv.ParseBool(v)
+	case *time.Duration:
+		*p, err = time.ParseDuration(v)
+	default:
+		panic(fmt.Sprintf("config: unsupported setting type %T", ptr))
+	}
+	return err
+}
+
+// Validate reports every invalid setting at once.
+func (c *Config) Validate() error {
+	var errs []error
+	bad := func(format string, args ...any) { errs = append(errs, fmt.Errorf(format, args...)) }
+
+	if c.Addr == "" { bad("addr is required") }
+	if c.GRPCAddr != "" && c.GRPCAddr == c.Addr { bad("grpc_addr must differ from addr") }
+	if c.AdminAddr != "" && !loopback(c.AdminAddr) { bad("admin_addr must be a loopback address such as 127.0.0.1:6060, got %q", c.AdminAddr) }
+	if c.ShutdownGrace < 0 { bad("shutdown_grace must be >= 0, got %s", c.ShutdownGrace) }
+	if c.DrainGrace < 0 { bad("drain_grace must be >= 0, got %s", c.DrainGrace) }
+	if c.MigrateTo >= 0 && (!c.Migrate || c.Store != "mongo") { bad("-migrate-to needs -migrate and store mongo") }
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.LogLevel)); err != nil { bad("log_level: %v", err) }
+	if !slices.Contains([]string{"production", "staging", "development"}, c.Environment) { bad("environment must be production, staging or development, got %q", c.Environment) }
+	if c.Production() && c.Auth.JWTSecret == "" { bad("auth.jwt_secret is required in production, where authentication can't be off") }
+	switch c.Store {
+	case "mongo", "memory":
+	case "sql":
+		if c.DatabaseURL == "" { bad("database_url is required with store sql") }
+	default:
+		bad("store must be mongo, sql or memory, got %q", c.Store)
+	}
+
+	m := c.Mongo
+	if m.URI == "" { bad("mongo.uri is required") }
+	if m.Database == "" || m.Collection == "" || m.EventsCollection == "" || m.IdempotencyCollection == "" || m.UsersCollection == "" || m.APIKeysCollection == "" || m.AuditCollection == "" || m.HistoryCollection == "" || m.NotesCollection == "" || m.JobsCollection == "" || m.WebhooksCollection == "" || m.DeliveriesCollection == "" || m.RevisionsCollection == "" || m.CapturesCollection == "" || m.OutboxCollection == "" || m.UsageCollection == "" || m.ChangesCollection == "" || m.LeasesCollection == "" {
+		bad("mongo database and collection names must not be empty")
+	}
+	switch {
+	case m.MaxPoolSize <= 0:
+		bad("mongo.max_pool_size must be > 0, got %d", m.MaxPoolSize)
+	case m.MinPoolSize < 0:
+		bad("mongo.min_pool_size must be >= 0, got %d", m.MinPoolSize)
+	case m.MinPoolSize > m.MaxPoolSize:
+		bad("mongo.min_pool_size (%d) exceeds mongo.max_pool_size (%d)", m.MinPoolSize, m.MaxPoolSize)
+	}
+	if m.MaxConnIdleTime < 0 { bad("mongo.max_conn_idle_time must be >= 0, got %s", m.MaxConnIdleTime) }
+	if m.ServerSelectionTimeout <= 0 { bad("mongo.server_selection_timeout must be positive, got %s", m.ServerSelectionTimeout) }
+	if m.ConnectRetry < 0 { bad("mongo.connect_retry must be >= 0, got %s", m.ConnectRetry) }
+	if m.Migrations != "auto" && m.Migrations != "manual" { bad("mongo.migrations must be auto or manual, got %q", m.Migrations) }
+	if _, err := store.CollectionOptions(m.ReadPref, m.WriteConcern); err != nil { bad("mongo: %v", err) }
+	if _, err := store.ListReadOptions(m.ListReadPref, m.ListReadConcern); err != nil { bad("mongo: %v", err) }
+	if m.ListConsistency != store.Strong && m.ListConsistency != store.Eventual {
+		bad("mongo.list_consistency must be strong or eventual, got %q", m.ListConsistency)
+	}
+	if m.CollationStrength < 1 || m.CollationStrength > 5 {
+		bad("mongo.collation_strength must be between 1 and 5, got %d", m.CollationStrength)
+	} else if err := (store.Collation{Locale: m.CollationLocale, Strength: m.CollationStrength}).Validate(); err != nil {
+		bad("mongo: %v", err)
+	}
+
+	if c.Auth.JWTTTL <= 0 { bad("auth.jwt_ttl must be positive, got %s", c.Auth.JWTTTL) }
+	if c.RateLimit.RPS > 0 {
+		if c.RateLimit.Burst < 1 { bad("rate_limit.burst must be >= 1, got %d", c.RateLimit.Burst) }
+		if c.RateLimit.MaxClients < 1 { bad("rate_limit.max_clients must be >= 1, got %d", c.RateLimit.MaxClients) }
+	}
+	if _, err := RouteLimits(c.Concurrency.Routes); err != nil { bad("concurrency.routes: %v", err) }
+	if c.Concurrency.QueueTimeout < 0 { bad("concurrency.queue_timeout must not be negative, got %s", c.Concurrency.QueueTimeout) }
+	t := c.TLS
+	if (t.CertFile == "") != (t.KeyFile == "") { bad("tls.cert_file and tls.key_file must be set together") }
+	if t.CertFile != "" && t.AutocertDomains != "" { bad("tls.cert_file and tls.autocert_domains are mutually exclusive") }
+	if t.AutocertDomains != "" && t.AutocertCacheDir == "" { bad("tls.autocert_cache_dir is required with tls.autocert_domains") }
+	if t.RedirectAddr != "" {
+		if t.CertFile == "" && t.AutocertDomains == "" { bad("tls.redirect_addr needs tls.cert_file or tls.autocert_domains") }
+		if t.RedirectAddr == c.Addr || t.RedirectAddr == c.GRPCAddr { bad("tls.redirect_addr must differ from addr and grpc_addr") }
+	}
+	if r := c.Retry; r.MaxAttempts < 1 {
+		bad("retry.max_attempts must be >= 1, got %d", r.MaxAttempts)
+	} else if r.MaxAttempts > 1 && (r.BaseDelay <= 0 || r.MaxDelay < r.BaseDelay) {
+		bad("retry.base_delay must be positive and at most retry.max_delay, got %s and %s", r.BaseDelay, r.MaxDelay)
+	}
+	origins := Split(c.CORS.AllowedOrigins)
+	for _, o := range origins {
+		if o == "*" { continue }
+		if u, err := url.Parse(o); err != nil || u.Scheme == "" || u.Host == "" || u.Path != "" {
+			bad("cors.allowed_origins: %q is not an origin like https://example.com", o)
+		}
+	}
+	if c.CORS.AllowCredentials && slices.Contains(origins, "*") { bad("cors.allow_credentials needs explicit cors.allowed_origins, not *") }
+	if c.CORS.MaxAge < 0 { bad("cors.max_age must be >= 0, got %s", c.CORS.MaxAge) }
+	if h := c.HTTP; h.ReadHeaderTimeout < 0 || h.ReadTimeout < 0 || h.WriteTimeout < 0 || h.IdleTimeout < 0 || h.HSTSMaxAge < 0 {
+		bad("the http timeouts and http.hsts_max_age must be >= 0")
+	} else if h.WriteTimeout > 0 && c.RequestTimeout > 0 && h.WriteTimeout <= c.RequestTimeout {
+		bad("http.write_timeout must be longer than request_timeout, or requests timing out can't be answered: got %s and %s", h.WriteTimeout, c.RequestTimeout)
+	}
+	if c.HTTP.MaxHeaderBytes < 4096 { bad("http.max_header_bytes must be at least 4096, got %d", c.HTTP.MaxHeaderBytes) }
+	if c.Compression.MinBytes > 0 && (c.Compression.Level < 1 || c.Compression.Level > 9) {
+		bad("compression.level must be 1 to 9, got %d", c.Compression.Level)
+	}
+	if cp := c.Capture; cp.Percent < 0 || cp.Percent > 100 {
+		bad("capture.percent must be between 0 and 100, got %g", cp.Percent)
+	} else if cp.Percent > 0 {
+		switch cp.Sink {
+		case "mongo":
+			if c.Store != "mongo" { bad("capture.sink mongo needs store mongo; use files") }
+			if cp.CollectionBytes < 4096 { bad("capture.collection_bytes must be at least 4096, got %d", cp.CollectionBytes) }
+		case "files":
+			if cp.Dir == "" { bad("capture.dir is required with capture.sink files") }
+			if cp.FileBytes < 1<<20 { bad("capture.file_bytes must be at least 1 MiB, got %d", cp.FileBytes) }
+			if cp.Files < 1 { bad("capture.files must be >= 1, got %d", cp.Files) }
+		case "memory":
+		default:
+			bad("capture.sink must be mongo, files or memory, got %q", cp.Sink)
+		}
+		if cp.MaxBodyBytes < 0 { bad("capture.max_body_bytes must be >= 0, got %d", cp.MaxBodyBytes) }
+	}
+	if j := c.Jobs; j.Workers < 0 {
+		bad("jobs.workers must be >= 0, got %d", j.Workers)
+	} else if j.Workers > 0 {
+		if j.Lease < time.Second { bad("jobs.lease must be at least 1s, got %s", j.Lease) }
+		if j.Poll <= 0 { bad("jobs.poll must be positive, got %s", j.Poll) }
+		if j.Retention <= 0 { bad("jobs.retention must be positive, got %s", j.Retention) }
+		if j.MaxAttempts < 1 { bad("jobs.max_attempts must be >= 1, got %d", j.MaxAttempts) }
+	}
+	if w := c.Webhooks; w.Workers < 0 {
+		bad("webhooks.workers must be >= 0, got %d", w.Workers)
+	} else if w.Workers > 0 {
+		if w.Timeout < time.Second { bad("webhooks.timeout must be at least 1s, got %s", w.Timeout) }
+		if w.Poll <= 0 { bad("webhooks.poll must be positive, got %s", w.Poll) }
+		if w.MaxAttempts < 1 { bad("webhooks.max_attempts must be >= 1, got %d", w.MaxAttempts) }
+		if w.Backoff <= 0 || w.MaxBackoff < w.Backoff { bad("webhooks.backoff must be positive and at most webhooks.max_backoff, got %s and %s", w.Backoff, w.MaxBackoff) }
+		if w.Retention <= 0 { bad("webhooks.retention must be positive, got %s", w.Retention) }
+	}
+	switch b := c.Bus; b.Kind {
+	case "off":
+	case "kafka", "nats":
+		if b.URL == "" { bad("bus.url is required with bus %s", b.Kind) }
+		if b.Topic == "" || strings.ContainsAny(b.Topic, " \t\r\n") { bad("bus.topic must be a name without spaces, got %q", b.Topic) }
+		if b.Format != bus.FormatJSON && b.Format != bus.FormatCloudEvents { bad("bus.format must be json or cloudevents, got %q", b.Format) }
+	default:
+		bad("bus.kind must be kafka, nats or off, got %q", b.Kind)
+	}
+	if o := c.Outbox; o.Enabled {
+		if c.Store == "sql" { bad("outbox needs store mongo or memory, which run transactions") }
+		if o.Poll <= 0 { bad("outbox.poll must be positive, got %s", o.Poll) }
+		if o.Lease < time.Second { bad("outbox.lease must be at least 1s, got %s", o.Lease) }
+		if o.Retention <= 0 { bad("outbox.retention must be positive, got %s", o.Retention) }
+	}
+	if ch := c.Changes; ch.Enabled {
+		if c.Store == "sql" { bad("changes needs store mongo or memory, which run transactions") }
+		if ch.Retention < time.Hour { bad("changes.retention must be at least 1h, got %s", ch.Retention) }
+		if ch.MaxWait < 0 { bad("changes.max_wait must be >= 0, got %s", ch.MaxWait) }
+		if ch.Poll <= 0 { bad("changes.poll must be positive, got %s", ch.Poll) }
+	}
+	if u := c.Usage; u.Enabled {
+		if c.Store == "sql" { bad("usage needs store mongo or memory") }
+		if u.Flush < time.Second { bad("usage.flush must be at least 1s, got %s", u.Flush) }
+		if u.DailyRequests < 0 || u.MonthlyRequests < 0 || u.DailyBytes < 0 || u.MonthlyBytes < 0 { bad("usage quotas must be >= 0") }
+	} else if u.DailyRequests != 0 || u.MonthlyRequests != 0 || u.DailyBytes != 0 || u.MonthlyBytes != 0 {
+		bad("the QUOTA_* quotas need USAGE")
+	}
+	if l := c.Leader; l.Enabled {
+		if c.Store == "sql" { bad("leader election needs store mongo or memory") }
+		if l.TTL < time.Second { bad("leader.ttl must be at least 1s, got %s", l.TTL) }
+		if l.Renew <= 0 || 3*l.Renew > l.TTL { bad("leader.renew must be positive and at most a third of leader.ttl, got %s and %s", l.Renew, l.TTL) }
+	}
+	switch sh := c.Shadow; sh.Store {
+	case "off":
+	case "sql", "mongo":
+		if c.Store != "mongo" { bad("shadow.store needs store mongo, the primary") }
+		if sh.Store == "sql" && sh.DatabaseURL == "" { bad("shadow.database_url is required with shadow store sql") }
+		if sh.Store == "mongo" && sh.MongoURI == "" { bad("shadow.mongo_uri is required with shadow store mongo") }
+		if sh.Store == "mongo" && sh.MongoURI == m.URI && (sh.MongoDatabase == "" || sh.MongoDatabase == m.Database) { bad("the shadow store must be another database than the primary") }
+		if sh.Timeout <= 0 { bad("shadow.timeout must be positive, got %s", sh.Timeout) }
+	default:
+		bad("shadow.store must be sql, mongo or off, got %q", sh.Store)
+	}
+	if ch := c.Chaos; ch.Latency != 0 || ch.LatencyRate != 0 || ch.ErrorRate != 0 || ch.TimeoutRate != 0 {
+		if c.Production() { bad("chaos can't be used in production") }
+		if ch.Latency < 0 { bad("chaos.latency must be >= 0, got %s", ch.Latency) }
+		if ch.Latency > 0 && ch.LatencyRate == 0 { bad("chaos.latency needs a chaos.latency_rate") }
+		if ch.LatencyRate < 0 || ch.LatencyRate > 1 { bad("chaos.latency_rate must be between 0 and 1, got %g", ch.LatencyRate) }
+		if ch.ErrorRate < 0 || ch.ErrorRate > 1 { bad("chaos.error_rate must be between 0 and 1, got %g", ch.ErrorRate) }
+		if ch.TimeoutRate < 0 || ch.TimeoutRate > 1 { bad("chaos.timeout_rate must be between 0 and 1, got %g", ch.TimeoutRate) }
+	}
+	if b := c.Backup; b.S3URL != "" {
+		if b.Dir != "" { bad("backup.dir and backup.s3_url can't both be set") }
+		if _, err := backup.NewS3(b.S3URL, b.S3Region, b.S3AccessKey, b.S3SecretKey); err != nil { bad("backup.s3_url %v", err) }
+		if b.S3AccessKey == "" || b.S3SecretKey == "" { bad("backup.s3_access_key and backup.s3_secret_key are required with backup.s3_url") }
+	}
+	for _, w := range Split(c.Normalize.LowerWords) {
+		if w != strings.ToLower(w) || strings.IndexFunc(w, func(r rune) bool { return !unicode.IsLetter(r) }) >= 0 { bad("normalize.lower_words must be lower-case words, got %q", w) }
+	}
+	if cl := c.Cleanup; cl.Schedule != "off" {
+		if _, err := cleanup.ParseSchedule(cl.Schedule); err != nil { bad("cleanup.schedule: %v", err) }
+		if cl.DeletedRetention < 0 { bad("cleanup.deleted_retention must be >= 0, got %s", cl.DeletedRetention) }
+		if cl.BatchSize < 1 { bad("cleanup.batch_size must be >= 1, got %d", cl.BatchSize) }
+	}
+	switch c.Cache.Backend {
+	case "off":
+	case "memory", "redis":
+		if c.Cache.TTL <= 0 { bad("cache.ttl must be positive, got %s", c.Cache.TTL) }
+		if c.Cache.Backend == "memory" && c.Cache.MaxEntries < 1 { bad("cache.max_entries must be >= 1, got %d", c.Cache.MaxEntries) }
+		if c.Cache.Backend == "redis" && c.Cache.RedisURL == "" { bad("cache.redis_url is required with cache backend redis") }
+	default:
+		bad("cache.backend must be memory, redis or off, got %q", c.Cache.Backend)
+	}
+	if c.IdempotencyTTL <= 0 { bad("idempotency_ttl must be positive, got %s", c.IdempotencyTTL) }
+	if c.MaxBodyBytes <= 0 { bad("max_body_bytes must be positive, got %d", c.MaxBodyBytes) }
+	if c.ImportMaxBytes <= 0 { bad("import_max_bytes must be positive, got %d", c.ImportMaxBytes) }
+	if c.LegacySunset != "" {
+		if _, err := time.Parse(time.DateOnly, c.LegacySunset); err != nil { bad("legacy_sunset must be a date like 2027-04-15, got %q", c.LegacySunset) }
+	}
+	if c.NotesOnDelete != notes.Block && c.NotesOnDelete != notes.Cascade {
+		bad("notes_on_delete must be block or cascade, got %q", c.NotesOnDelete)
+	}
+	if !slices.Contains(ids.Strategies, c.IDStrategy) { bad("id_strategy must be objectid, uuid or slug, got %q", c.IDStrategy) }
+	if c.ResourcesFile != "" {
+		reg, err := resource.Load(c.ResourcesFile)
+		if err != nil { bad("resources_file: %v", err) }
+		builtin := []string{m.Collection, m.EventsCollection, m.IdempotencyCollection, m.UsersCollection, m.APIKeysCollection, m.AuditCollection, m.HistoryCollection, m.NotesCollection, m.JobsCollection, m.WebhooksCollection, m.DeliveriesCollection, m.RevisionsCollection, m.CapturesCollection, m.OutboxCollection, m.UsageCollection, m.ChangesCollection, m.LeasesCollection}
+		for _, coll := range reg.Collections() {
+			if slices.Contains(builtin, coll) { bad("resources_file: collection %q is already used by the API", coll) }
+		}
+	}
+	if c.PrewriteFile != "" {
+		if _, err := prewrite.Load(c.PrewriteFile); err != nil { bad("prewrite_hooks_file: %v", err) }
+	}
+	return errors.Join(errs...)
+}
+
+// Print writes the configuration as YAML, with secrets masked.
+func (c *Config) Print(w io.Writer) error {
+	out := *c
+	out.Mongo.URI = RedactURI(out.Mongo.URI)
+	out.DatabaseURL = RedactURI(out.DatabaseURL)
+	out.Cache.RedisURL = RedactURI(out.Cache.RedisURL)
+	out.Bus.URL = RedactURI(out.Bus.URL)
+	out.Shadow.DatabaseURL = RedactURI(out.Shadow.DatabaseURL)
+	out.Shadow.MongoURI = RedactURI(out.Shadow.MongoURI)
+	if out.Auth.JWTSecret != "" { out.Auth.JWTSecret = "xxxxx" }
+	if out.Backup.S3SecretKey != "" { out.Backup.S3SecretKey = "xxxxx" }
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(&out); err != nil { return err }
+	return enc.Close()
+}
+
+// RedactURI hides the password of a connection string before it's shown.
+func RedactURI(uri string) string {
+	scheme, rest, found := strings.Cut(uri, "://")
+	if !found { return uri }
+	creds, host, found := strings.Cut(rest, "@")
+	if !found { return uri }
+	if user, _, hasPass := strings.Cut(creds, ":"); hasPass { creds = user + ":xxxxx" }
+	return scheme + "://" + creds + "@" + host
+}
@@ -0,0 +1,137 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadPrecedence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.yaml")
+	yaml := "addr: \":9000\"\nlog_level: warn\nmongo:\n  database: fromfile\n  max_pool_size: 50\nrate_limit:\n  rps: 3\n"
+	if err := os.WriteFile(path, []byte(yaml), 0o600); err != nil { t.Fatal(err) }
+
+	t.Setenv("CONFIG_FILE", path)
+	t.Setenv("DB_NAME", "fromenv")
+	t.Setenv("MONGO_MAX_POOL_SIZE", "60")
+	t.Setenv("JWT_TTL", "2h")
+	t.Setenv("JWT_SECRET", "s3cret")
+
+	c, err := Load([]string{"--mongo-max-pool-size=70", "--allow-hard-delete"})
+	if err != nil { t.Fatal(err) }
+
+	checks := []struct {
+		name      string
+		got, want any
+	}{
+		{"default", c.Mongo.Collection, "names"},
+		{"file", c.Addr, ":9000"},
+		{"file", c.RateLimit.RPS, 3.0},
+		{"env over file", c.Mongo.Database, "fromenv"},
+		{"env", c.Auth.JWTTTL, 2 * time.Hour},
+		{"flag over env", c.Mongo.MaxPoolSize, 70},
+		{"bool flag", c.AllowHardDelete, true},
+	}
+	for _, ch := range checks {
+		if ch.got != ch.want { t.Errorf("%s: got %v, want %v", ch.name, ch.got, ch.want) }
+	}
+}
+
+func TestLoadRejects(t *testing.T) {
+	for _, tc := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"--mongo-uri="}, "mongo.uri is required"},
+		{[]string{"--idempotency-ttl=0s"}, "idempotency_ttl must be positive"},
+		{[]string{"--shutdown-grace=soon"}, "--shutdown-grace"},
+		{[]string{"--mongo-min-pool-size=200"}, "exceeds mongo.max_pool_size"},
+		{[]string{"--write-concern=lots"}, "WRITE_CONCERN"},
+		{[]string{"--log-level=loud"}, "log_level"},
+		{[]string{"--admin-addr=:6060"}, "admin_addr must be a loopback address"},
+		{[]string{"--admin-addr=0.0.0.0:6060"}, "admin_addr must be a loopback address"},
+		{[]string{"--environment=prod"}, "environment must be production"},
+		{[]string{"--environment=production", "--jwt-secret="}, "auth.jwt_secret is required in production"},
+		{[]string{"--capture-percent=150"}, "capture.percent must be between 0 and 100"},
+		{[]string{"--capture-percent=5", "--store=sql", "--database-url=sqlite:x.db"}, "capture.sink mongo needs store mongo"},
+		{[]string{"--capture-percent=5", "--capture-sink=files", "--capture-files=0"}, "capture.files must be >= 1"},
+		{[]string{"--cache=redis"}, "cache.redis_url is required"},
+		{[]string{"--tls-cert=server.pem"}, "must be set together"},
+		{[]string{"--http-redirect-addr=:80"}, "needs tls.cert_file"},
+		{[]string{"--mongo-connect-retry=-1s"}, "mongo.connect_retry must be >= 0"},
+		{[]string{"--retry-base-delay=2s"}, "at most retry.max_delay"},
+		{[]string{"--cors-allow-credentials"}, "cors.allow_credentials needs explicit"},
+		{[]string{"--cors-allowed-origins=example.com"}, "is not an origin"},
+		{[]string{"--legacy-sunset=next spring"}, "legacy_sunset must be a date"},
+		{[]string{"--compress-level=0"}, "compression.level must be 1 to 9"},
+		{[]string{"--notes-on-delete=orphan"}, "notes_on_delete must be block or cascade"},
+		{[]string{"--id-strategy=serial"}, "id_strategy must be objectid, uuid or slug"},
+		{[]string{"--job-lease=100ms"}, "jobs.lease must be at least 1s"},
+		{[]string{"--webhook-backoff=2h"}, "webhooks.backoff must be positive and at most webhooks.max_backoff"},
+		{[]string{"--bus=rabbitmq"}, "bus.kind must be kafka, nats or off"},
+		{[]string{"--bus=nats"}, "bus.url is required with bus nats"},
+		{[]string{"--bus=kafka", "--bus-url=localhost:9092", "--bus-format=avro"}, "bus.format must be json or cloudevents"},
+		{[]string{"--mongo-migrations=never"}, "mongo.migrations must be auto or manual"},
+		{[]string{"--list-read-concern=linearizable"}, "LIST_READ_CONCERN: want local, available or majority"},
+		{[]string{"--list-read-pref=closest"}, "LIST_READ_PREF"},
+		{[]string{"--list-consistency=weak"}, "mongo.list_consistency must be strong or eventual"},
+		{[]string{"-migrate-to=2"}, "-migrate-to needs -migrate"},
+		{[]string{"-migrate", "-migrate-to=0", "--store=memory"}, "-migrate-to needs -migrate and store mongo"},
+		{[]string{"--outbox", "--store=sql", "--database-url=sqlite:x.db"}, "outbox needs store mongo or memory"},
+		{[]string{"--outbox", "--outbox-lease=10ms"}, "outbox.lease must be at least 1s"},
+		{[]string{"--quota-daily-requests=100"}, "the QUOTA_* quotas need USAGE"},
+		{[]string{"--usage", "--store=sql", "--database-url=sqlite:x.db"}, "usage needs store mongo or memory"},
+		{[]string{"--usage", "--quota-monthly-bytes=-1"}, "usage quotas must be >= 0"},
+		{[]string{"--leader-election", "--store=sql", "--database-url=sqlite:x.db"}, "leader election needs store mongo or memory"},
+		{[]string{"--leader-election", "--leader-ttl=500ms"}, "leader.ttl must be at least 1s"},
+		{[]string{"--leader-election", "--leader-ttl=10s", "--leader-renew=5s"}, "leader.renew must be positive and at most a third of leader.ttl"},
+		{[]string{"--shadow-store=postgres"}, "shadow.store must be sql, mongo or off"},
+		{[]string{"--shadow-store=sql", "--store=memory"}, "shadow.store needs store mongo"},
+		{[]string{"--shadow-store=sql"}, "shadow.database_url is required"},
+		{[]string{"--shadow-store=mongo", "--shadow-mongo-uri=mongodb://localhost:27017"}, "the shadow store must be another database"},
+		{[]string{"--shadow-store=mongo", "--shadow-mongo-uri=mongodb://new:27017", "--shadow-timeout=0s"}, "shadow.timeout must be positive"},
+		{[]string{"--changes", "--store=sql", "--database-url=sqlite:x.db"}, "changes needs store mongo or memory"},
+		{[]string{"--changes", "--changes-retention=10m"}, "changes.retention must be at least 1h"},
+		{[]string{"--changes", "--changes-poll=0s"}, "changes.poll must be positive"},
+		{[]string{"--chaos-error-rate=1.5"}, "chaos.error_rate must be between 0 and 1"},
+		{[]string{"--chaos-timeout-rate=-0.1"}, "chaos.timeout_rate must be between 0 and 1"},
+		{[]string{"--chaos-latency=1s"}, "chaos.latency needs a chaos.latency_rate"},
+		{[]string{"--chaos-error-rate=0.1", "--environment=production"}, "chaos can't be used in production"},
+		{[]string{"--backup-dir=backups", "--backup-s3-url=https://s3.example.com/b", "--backup-s3-access-key=k", "--backup-s3-secret-key=s"}, "backup.dir and backup.s3_url can't both be set"},
+		{[]string{"--backup-s3-url=https://s3.example.com", "--backup-s3-access-key=k", "--backup-s3-secret-key=s"}, "backup.s3_url names no bucket"},
+		{[]string{"--backup-s3-url=https://s3.example.com/b"}, "backup.s3_access_key and backup.s3_secret_key are required"},
+		{[]string{"--name-lower-words=van,De"}, `normalize.lower_words must be lower-case words, got "De"`},
+		{[]string{"--name-lower-words=van,d'"}, `normalize.lower_words must be lower-case words, got "d'"`},
+		{[]string{"--http-write-timeout=30s"}, "http.write_timeout must be longer than request_timeout"},
+		{[]string{"--http-idle-timeout=-1s"}, "the http timeouts and http.hsts_max_age must be >= 0"},
+		{[]string{"--http-max-header-bytes=1024"}, "http.max_header_bytes must be at least 4096"},
+		{[]string{"--mongo-collation-locale=French"}, `collation locale "French" is not an ICU locale`},
+		{[]string{"--mongo-collation-locale=fr", "--mongo-collation-strength=0"}, "mongo.collation_strength must be between 1 and 5"},
+		{[]string{"--route-limits=GET /api/v1/names/export=4, /api/v1/names=2"}, `concurrency.routes: "/api/v1/names=2" is not METHOD /path=N`},
+		{[]string{"--route-limits=GET /api/v1/names=0"}, "with N >= 1"},
+		{[]string{"--concurrency-queue-timeout=-1s"}, "concurrency.queue_timeout must not be negative"},
+		{[]string{"--cleanup-schedule=0 25 * * *"}, `cleanup.schedule: "0 25 * * *": hour: "25" is outside 0-23`},
+		{[]string{"--cleanup-schedule=0 0 30 2 *"}, "never comes"},
+		{[]string{"--resources-file=testdata/nope.yaml"}, "resources_file: open testdata/nope.yaml"},
+		{[]string{"--prewrite-hooks-file=testdata/nope.yaml"}, "prewrite_hooks_file: open testdata/nope.yaml"},
+	} {
+		_, err := Load(tc.args)
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("Load(%q) = %v, want error containing %q", tc.args, err, tc.want)
+		}
+	}
+}
+
+func TestPrintMasksSecrets(t *testing.T) {
+	c := Default()
+	c.Mongo.URI = "mongodb://app:hunter2@db:27017"
+	c.Auth.JWTSecret = "s3cret"
+	c.Backup.S3SecretKey = "bucketkey"
+	var b strings.Builder
+	if err := c.Print(&b); err != nil { t.Fatal(err) }
+	if out := b.String(); strings.Contains(out, "hunter2") || strings.Contains(out, "s3cret") || strings.Contains(out, "bucketkey") || !strings.Contains(out, "max_conn_idle_time: 5m0s") {
+		t.Fatalf("unexpected output:\n%s", out)
+	}
+}
@@ -0,0 +1,121 @@
+// Package debugserver serves the runtime's debugging endpoints, the pprof
+// profiles, expvar's variables and the build's provenance, on a listener of
+// their own. ADMIN_ADDR must be a loopback address, so that profiles can be
+// taken in production, through an SSH tunnel or kubectl port-forward,
+// without the API port exposing them or what they reveal.
+//
+// GET /debug/pprof/...    -> net/http/pprof: go tool pprof http://127.0.0.1:6060/debug/pprof/profile?seconds=30
+// GET /debug/vars         -> expvar, memstats and cmdline included
+// GET /debug/buildinfo    -> commit, build time and Go version
+package debugserver
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"log/slog"
+	"net/http"
+	"net/http/pprof"
+	"runtime/debug"
+	"time"
+
+	"app/internal/handlers"
+)
+
+// Set at link time by release builds, which know when they ran:
+//
+//	go build -ldflags "-X app/internal/debugserver.BuildTime=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+//
+// Commit, when set, overrides the revision the go command stamps into
+// builds made from a git checkout, for builds made from anything else.
+var (
+	BuildTime string
+	Commit    string
+)
+
+type Config struct {
+	Addr          string
+	ShutdownGrace time.Duration // how long a profile in progress gets on shutdown
+}
+
+type Server struct {
+	cfg Config
+	srv *http.Server
+}
+
+func New(cfg Config) *Server {
+	return &Server{cfg: cfg, srv: &http.Server{Addr: cfg.Addr, Handler: Handler()}}
+}
+
+// Handler routes the debugging endpoints. pprof's handlers take the whole
+// /debug/pprof/ tree, whatever the method, as net/http/pprof registers
+// them: the index and every named profile, such as heap, goroutine or
+// mutex.
+func Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol) // POSTed to by go tool pprof
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("GET /debug/vars", expvar.Handler())
+	mux.HandleFunc("GET /debug/buildinfo", buildInfo)
+	return mux
+}
+
+// Build is what GET /debug/buildinfo answers.
+type Build struct {
+	Commit     string `json:"commit,omitempty"`
+	Modified   bool   `json:"modified,omitempty"`    // built with uncommitted changes
+	CommitTime string `json:"commit_time,omitempty"` // of Commit, as the go command stamped it
+	BuildTime  string `json:"build_time,omitempty"`
+	GoVersion  string `json:"go_version"`
+	Module     string `json:"module,omitempty"`
+}
+
+// ReadBuild returns the provenance of the running binary. Fields the build
+// wasn't given, such as the commit of a build outside git, are empty.
+func ReadBuild() Build {
+	b := Build{Commit: Commit, BuildTime: BuildTime}
+	info, ok := debug.ReadBuildInfo()
+	if !ok { return b }
+	b.GoVersion, b.Module = info.GoVersion, info.Main.Path
+	for _, s := range info.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			if b.Commit == "" { b.Commit = s.Value }
+		case "vcs.time":
+			b.CommitTime = s.Value
+		case "vcs.modified":
+			b.Modified = s.Value == "true"
+		}
+	}
+	return b
+}
+
+func buildInfo(w http.ResponseWriter, r *http.Request) { handlers.WriteJSON(w, http.StatusOK, ReadBuild()) }
+
+// Run serves until ctx is cancelled, then gives requests in flight, such
+// as a CPU profile being taken, up to ShutdownGrace before cutting them off.
+func (s *Server) Run(ctx context.Context) error {
+	serveErr := make(chan error, 1)
+	go func() {
+		slog.Info("serving debug endpoints", "addr", s.srv.Addr)
+		serveErr <- s.srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serveErr:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), s.cfg.ShutdownGrace)
+	defer cancel()
+	if err := s.srv.Shutdown(shutdownCtx); err != nil {
+		slog.Warn("debug server grace period expired, closing remaining connections", "err", err)
+		_ = s.srv.Close()
+	}
+	if err := <-serveErr; !errors.Is(err, http.ErrServerClosed) { return err }
+	return nil
+}
@@ -0,0 +1,32 @@
+package debugserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestHandler(t *testing.T) {
+	h := Handler()
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != http.StatusOK { t.Fatalf("%s: %d %s", path, rec.Code, rec.Body) }
+		return rec
+	}
+
+	if body := get("/debug/pprof/").Body.String(); !strings.Contains(body, "goroutine") { t.Fatalf("pprof index: %s", body) }
+	if rec := get("/debug/pprof/heap?debug=1"); !strings.Contains(rec.Body.String(), "heap profile") { t.Fatalf("heap profile: %.200s", rec.Body) }
+
+	var vars map[string]json.RawMessage
+	if err := json.Unmarshal(get("/debug/vars").Body.Bytes(), &vars); err != nil || vars["memstats"] == nil { t.Fatalf("expvar: %v, %v", vars, err) }
+
+	Commit, BuildTime = "abc123", "2026-10-15T12:00:00Z"
+	t.Cleanup(func() { Commit, BuildTime = "", "" })
+	var b Build
+	if err := json.Unmarshal(get("/debug/buildinfo").Body.Bytes(), &b); err != nil { t.Fatal(err) }
+	if b.Commit != "abc123" || b.BuildTime != "2026-10-15T12:00:00Z" || b.GoVersion != runtime.Version() { t.Fatalf("build info: %+v", b) }
+}
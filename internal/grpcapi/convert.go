@@ -0,0 +1,68 @@
+package grpcapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	namesv1 "app/api/names/v1"
+	"app/internal/prewrite"
+	"app/internal/store"
+	"app/internal/validate"
+)
+
+// toProto converts a stored Name. Metadata goes through JSON first so it
+// has exactly the shape the HTTP API would have returned.
+func toProto(n store.Name) (*namesv1.Name, error) {
+	pb := &namesv1.Name{Id: n.ID.Hex(), Name: n.Name, Tags: n.Tags, Version: n.Version}
+	if !n.CreatedAt.IsZero() { pb.CreatedAt = timestamppb.New(n.CreatedAt) }
+	if !n.UpdatedAt.IsZero() { pb.UpdatedAt = timestamppb.New(n.UpdatedAt) }
+	if len(n.Metadata) > 0 {
+		b, err := json.Marshal(n.Metadata)
+		if err != nil { return nil, status.Error(codes.Internal, "internal server error") }
+		pb.Metadata = &structpb.Struct{}
+		if err := pb.Metadata.UnmarshalJSON(b); err != nil { return nil, status.Error(codes.Internal, "internal server error") }
+	}
+	return pb, nil
+}
+
+// invalid is the gRPC form of a 422: InvalidArgument with one field
+// violation per failure.
+func invalid(errs []validate.FieldError) error {
+	br := &errdetails.BadRequest{}
+	for _, e := range errs {
+		br.FieldViolations = append(br.FieldViolations, &errdetails.BadRequest_FieldViolation{Field: e.Field, Description: e.Message})
+	}
+	st, err := status.New(codes.InvalidArgument, "validation failed").WithDetails(br)
+	if err != nil { return status.Error(codes.InvalidArgument, "validation failed") }
+	return st.Err()
+}
+
+// storeError maps the store's sentinel errors to status codes; anything else
+// is logged and hidden behind Internal.
+func storeError(ctx context.Context, err error) error {
+	var rej *prewrite.Rejected
+	switch {
+	case errors.As(err, &rej):
+		return invalid(rej.Fields)
+	case errors.Is(err, store.ErrNotFound):
+		return status.Error(codes.NotFound, "not found")
+	case errors.Is(err, store.ErrDuplicate):
+		return status.Error(codes.AlreadyExists, "name already exists")
+	case errors.Is(err, store.ErrVersionMismatch):
+		return status.Error(codes.Aborted, "name was modified since it was read")
+	case errors.Is(err, store.ErrHasNotes):
+		return status.Error(codes.FailedPrecondition, "the name has notes")
+	case errors.Is(err, store.ErrNotOwner):
+		return status.Error(codes.PermissionDenied, "not the owner of the name")
+	}
+	slog.ErrorContext(ctx, "internal error", "err", err)
+	return status.Error(codes.Internal, "internal server error")
+}
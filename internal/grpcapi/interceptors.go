@@ -0,0 +1,77 @@
+package grpcapi
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	namesv1 "app/api/names/v1"
+	"app/internal/auth"
+	"app/internal/requestid"
+	"app/internal/tenant"
+)
+
+// requestIDInterceptor is requestid.Middleware for gRPC: the ID comes from
+// and is echoed in the x-request-id metadata.
+func requestIDInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	var id string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if v := md.Get(requestid.Header); len(v) > 0 { id = v[0] }
+	}
+	if !requestid.Valid(id) { id = requestid.New() }
+	_ = grpc.SetHeader(ctx, metadata.Pairs(requestid.Header, id))
+	return handler(requestid.NewContext(ctx, id), req)
+}
+
+// loggingInterceptor emits one structured line per call, like the HTTP
+// access log.
+func loggingInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	start := time.Now()
+	resp, err := handler(ctx, req)
+	code := status.Code(err)
+
+	level := slog.LevelInfo
+	if code == codes.Internal || code == codes.Unknown { level = slog.LevelError }
+	slog.LogAttrs(ctx, level, "rpc",
+		slog.String("method", info.FullMethod),
+		slog.String("code", code.String()),
+		slog.Float64("latency_ms", float64(time.Since(start).Microseconds())/1000),
+	)
+	return resp, err
+}
+
+// writeMethods change names, which takes the names:write scope.
+var writeMethods = map[string]bool{
+	namesv1.NameService_CreateName_FullMethodName: true,
+	namesv1.NameService_UpdateName_FullMethodName: true,
+	namesv1.NameService_DeleteName_FullMethodName: true,
+}
+
+// authInterceptor expects "authorization: Bearer <jwt>" metadata, exactly as
+// the HTTP API expects the header, and holds the token's role to the same
+// scopes. It is a no-op when no JWT secret is configured.
+func authInterceptor(tokens *auth.Tokens) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if !tokens.Enabled() { return handler(ctx, req) }
+
+		md, _ := metadata.FromIncomingContext(ctx)
+		var raw string
+		if v := md.Get("authorization"); len(v) > 0 { raw = v[0] }
+		raw, found := strings.CutPrefix(raw, "Bearer ")
+		if !found { return nil, status.Error(codes.Unauthenticated, "missing bearer token") }
+		uid, tid, role, err := tokens.Verify(strings.TrimSpace(raw))
+		if err != nil { return nil, status.Error(codes.Unauthenticated, "invalid token") }
+
+		ctx = auth.WithScopes(auth.WithRole(auth.WithUserID(tenant.NewContext(ctx, tid), uid), role), auth.RoleScopes(role))
+		scope := auth.ScopeRead
+		if writeMethods[info.FullMethod] { scope = auth.ScopeWrite }
+		if !auth.HasScope(ctx, scope) { return nil, status.Error(codes.PermissionDenied, "the "+role+" role lacks the "+scope+" scope") }
+		return handler(ctx, req)
+	}
+}
@@ -0,0 +1,185 @@
+// Package grpcapi serves names.v1.NameService next to the HTTP API. It talks
+// to the same store and applies the same validation, so both front ends see
+// and produce identical documents.
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+
+	namesv1 "app/api/names/v1"
+	"app/internal/auth"
+	"app/internal/store"
+	"app/internal/validate"
+)
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 500
+)
+
+type Config struct {
+	Addr            string
+	ShutdownGrace   time.Duration // how long in-flight calls get on shutdown
+	AllowHardDelete bool
+}
+
+type Server struct {
+	namesv1.UnimplementedNameServiceServer
+
+	cfg   Config
+	names store.NameStore
+	srv   *grpc.Server
+}
+
+func New(cfg Config, names store.NameStore, tokens *auth.Tokens) *Server {
+	s := &Server{cfg: cfg, names: names}
+	s.srv = grpc.NewServer(grpc.ChainUnaryInterceptor(requestIDInterceptor, loggingInterceptor, authInterceptor(tokens)))
+	namesv1.RegisterNameServiceServer(s.srv, s)
+	return s
+}
+
+// Run serves until ctx is cancelled, then waits up to ShutdownGrace for
+// in-flight calls before cutting them off.
+func (s *Server) Run(ctx context.Context) error {
+	lis, err := net.Listen("tcp", s.cfg.Addr)
+	if err != nil { return err }
+
+	serveErr := make(chan error, 1)
+	go func() {
+		slog.Info("serving gRPC", "addr", lis.Addr().String())
+		serveErr <- s.srv.Serve(lis)
+	}()
+
+	select {
+	case err := <-serveErr:
+		return err
+	case <-ctx.Done():
+	}
+
+	stopped := make(chan struct{})
+	go func() { s.srv.GracefulStop(); close(stopped) }()
+	select {
+	case <-stopped:
+	case <-time.After(s.cfg.ShutdownGrace):
+		slog.Warn("gRPC grace period expired, closing remaining connections")
+		s.srv.Stop()
+	}
+	if err := <-serveErr; !errors.Is(err, grpc.ErrServerStopped) { return err }
+	return nil
+}
+
+// ========== NameService ==========
+
+func (s *Server) CreateName(ctx context.Context, req *namesv1.CreateNameRequest) (*namesv1.Name, error) {
+	n := store.Name{Name: req.GetName(), Tags: req.GetTags(), Metadata: req.GetMetadata().AsMap()}
+	if len(n.Metadata) == 0 { n.Metadata = nil }
+	if errs := validate.Name(&n); errs != nil { return nil, invalid(errs) }
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if err := s.names.Create(ctx, &n); err != nil { return nil, storeError(ctx, err) }
+	return toProto(n)
+}
+
+func (s *Server) GetName(ctx context.Context, req *namesv1.GetNameRequest) (*namesv1.Name, error) {
+	oid, err := parseID(req.GetId())
+	if err != nil { return nil, err }
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	n, err := s.names.Get(ctx, oid)
+	if err != nil { return nil, storeError(ctx, err) }
+	return toProto(n)
+}
+
+func (s *Server) ListNames(ctx context.Context, req *namesv1.ListNamesRequest) (*namesv1.ListNamesResponse, error) {
+	opts := store.ListOptions{Limit: defaultPageSize, SortBy: "created_at", NamePrefix: req.GetNamePrefix()}
+	var errs []validate.FieldError
+	if size := req.GetPageSize(); size != 0 {
+		if size < 1 || size > maxPageSize { errs = append(errs, validate.FieldError{Field: "page_size", Message: "must be between 1 and 500"}) }
+		opts.Limit = int64(size)
+	}
+	switch req.GetSort() {
+	case "", "created_at":
+	case "-created_at":
+		opts.Desc = true
+	case "name", "-name":
+		opts.SortBy, opts.Desc = "name", req.GetSort() == "-name"
+	default:
+		errs = append(errs, validate.FieldError{Field: "sort", Message: "must be name or created_at, optionally prefixed with -"})
+	}
+	if tok := req.GetPageToken(); tok != "" {
+		c, err := store.DecodeCursor(tok)
+		if err != nil { errs = append(errs, validate.FieldError{Field: "page_token", Message: "is not a valid token"}) }
+		opts.After = c
+	}
+	if errs != nil { return nil, invalid(errs) }
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	page, err := s.names.List(ctx, opts)
+	if err != nil { return nil, storeError(ctx, err) }
+
+	resp := &namesv1.ListNamesResponse{Total: page.Total, NextPageToken: page.Next}
+	for _, n := range page.Items {
+		pb, err := toProto(n)
+		if err != nil { return nil, err }
+		resp.Names = append(resp.Names, pb)
+	}
+	return resp, nil
+}
+
+// UpdateName replaces the document like PUT /names/{id}: omitted tags and
+// metadata are cleared, and the version must be given, as If-Match.
+func (s *Server) UpdateName(ctx context.Context, req *namesv1.UpdateNameRequest) (*namesv1.Name, error) {
+	oid, err := parseID(req.GetId())
+	if err != nil { return nil, err }
+	n := store.Name{Name: req.GetName(), Tags: req.GetTags(), Metadata: req.GetMetadata().AsMap()}
+	if len(n.Metadata) == 0 { n.Metadata = nil }
+	errs := validate.Name(&n)
+	if req.GetVersion() < 1 { errs = append(errs, versionRequired) }
+	if errs != nil { return nil, invalid(errs) }
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	n, err = s.names.Update(ctx, oid, n, req.GetVersion())
+	if err != nil { return nil, storeError(ctx, err) }
+	return toProto(n)
+}
+
+func (s *Server) DeleteName(ctx context.Context, req *namesv1.DeleteNameRequest) (*emptypb.Empty, error) {
+	oid, err := parseID(req.GetId())
+	if err != nil { return nil, err }
+	if req.GetVersion() < 1 { return nil, invalid([]validate.FieldError{versionRequired}) }
+
+	del := s.names.SoftDelete
+	if req.GetHard() {
+		if !s.cfg.AllowHardDelete { return nil, status.Error(codes.PermissionDenied, "hard delete is disabled") }
+		del = s.names.HardDelete
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if err := del(ctx, oid, req.GetVersion()); err != nil { return nil, storeError(ctx, err) }
+	return &emptypb.Empty{}, nil
+}
+
+// versionRequired is the failure of an update or delete without a version,
+// which proto3 can't tell from 0.
+var versionRequired = validate.FieldError{Field: "version", Message: "is required"}
+
+func parseID(id string) (primitive.ObjectID, error) {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil { return oid, status.Error(codes.InvalidArgument, "invalid id") }
+	return oid, nil
+}
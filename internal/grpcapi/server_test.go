@@ -0,0 +1,97 @@
+package grpcapi
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	namesv1 "app/api/names/v1"
+	"app/internal/auth"
+	"app/internal/store"
+)
+
+func dial(t *testing.T, tokens *auth.Tokens) namesv1.NameServiceClient {
+	t.Helper()
+	s := New(Config{}, store.NewMemoryNames(), tokens)
+	lis := bufconn.Listen(1 << 20)
+	go func() { _ = s.srv.Serve(lis) }()
+	t.Cleanup(s.srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return lis.Dial() }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil { t.Fatal(err) }
+	t.Cleanup(func() { conn.Close() })
+	return namesv1.NewNameServiceClient(conn)
+}
+
+func TestNameServiceErrors(t *testing.T) {
+	c := dial(t, auth.NewTokens(nil, 0))
+	ctx := context.Background()
+
+	created, err := c.CreateName(ctx, &namesv1.CreateNameRequest{Name: "  Alice ", Tags: []string{" vip"}})
+	if err != nil { t.Fatal(err) }
+	if created.Name != "Alice" || created.Tags[0] != "vip" { t.Fatalf("input not normalized: %v", created) }
+
+	_, err = c.CreateName(ctx, &namesv1.CreateNameRequest{Name: "Alice"})
+	if status.Code(err) != codes.AlreadyExists { t.Fatalf("duplicate: got %v", err) }
+
+	_, err = c.CreateName(ctx, &namesv1.CreateNameRequest{Name: " ", Tags: []string{""}})
+	st := status.Convert(err)
+	if st.Code() != codes.InvalidArgument { t.Fatalf("invalid: got %v", err) }
+	var fields []string
+	for _, d := range st.Details() {
+		if br, ok := d.(*errdetails.BadRequest); ok {
+			for _, v := range br.FieldViolations { fields = append(fields, v.Field) }
+		}
+	}
+	if len(fields) != 2 || fields[0] != "name" || fields[1] != "tags[0]" { t.Fatalf("field violations: %v", fields) }
+
+	got, err := c.GetName(ctx, &namesv1.GetNameRequest{Id: created.Id})
+	if err != nil || got.Name != "Alice" { t.Fatalf("get: %v, %v", got, err) }
+	_, err = c.GetName(ctx, &namesv1.GetNameRequest{Id: primitive.NewObjectID().Hex()})
+	if status.Code(err) != codes.NotFound { t.Fatalf("missing: got %v", err) }
+	_, err = c.GetName(ctx, &namesv1.GetNameRequest{Id: "nope"})
+	if status.Code(err) != codes.InvalidArgument { t.Fatalf("bad id: got %v", err) }
+	_, err = c.DeleteName(ctx, &namesv1.DeleteNameRequest{Id: created.Id, Hard: true, Version: 1})
+	if status.Code(err) != codes.PermissionDenied { t.Fatalf("hard delete: got %v", err) }
+
+	// Updates and deletes apply to the version they give, which they must.
+	_, err = c.UpdateName(ctx, &namesv1.UpdateNameRequest{Id: created.Id, Name: "Alice"})
+	if status.Code(err) != codes.InvalidArgument { t.Fatalf("update without a version: got %v", err) }
+	_, err = c.UpdateName(ctx, &namesv1.UpdateNameRequest{Id: created.Id, Name: "Alice", Version: 2})
+	if status.Code(err) != codes.Aborted { t.Fatalf("update at another version: got %v", err) }
+	updated, err := c.UpdateName(ctx, &namesv1.UpdateNameRequest{Id: created.Id, Name: "Alice", Tags: []string{"core"}, Version: created.Version})
+	if err != nil || updated.Version != 2 || updated.Tags[0] != "core" { t.Fatalf("update: %v, %v", updated, err) }
+	_, err = c.DeleteName(ctx, &namesv1.DeleteNameRequest{Id: created.Id})
+	if status.Code(err) != codes.InvalidArgument { t.Fatalf("delete without a version: got %v", err) }
+	_, err = c.DeleteName(ctx, &namesv1.DeleteNameRequest{Id: created.Id, Version: 1})
+	if status.Code(err) != codes.Aborted { t.Fatalf("delete at another version: got %v", err) }
+	if _, err = c.DeleteName(ctx, &namesv1.DeleteNameRequest{Id: created.Id, Version: 2}); err != nil { t.Fatal(err) }
+}
+
+func TestNameServiceAuth(t *testing.T) {
+	tokens := auth.NewTokens([]byte("secret"), time.Minute)
+	c := dial(t, tokens)
+
+	_, err := c.GetName(context.Background(), &namesv1.GetNameRequest{Id: primitive.NewObjectID().Hex()})
+	if status.Code(err) != codes.Unauthenticated { t.Fatalf("no token: got %v", err) }
+
+	tok, err := tokens.Issue(primitive.NewObjectID(), "", auth.RoleViewer)
+	if err != nil { t.Fatal(err) }
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+tok)
+	_, err = c.GetName(ctx, &namesv1.GetNameRequest{Id: primitive.NewObjectID().Hex()})
+	if status.Code(err) != codes.NotFound { t.Fatalf("with token: got %v", err) }
+	_, err = c.CreateName(ctx, &namesv1.CreateNameRequest{Name: "Alice"})
+	if status.Code(err) != codes.PermissionDenied { t.Fatalf("viewer creating: got %v", err) }
+}
@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"app/internal/auth"
+	"app/internal/store"
+	"app/internal/tenant"
+)
+
+// POST /apikeys  { "name": "nightly export", "scopes": ["names:read"] }
+// -> the key's details plus "key", which is shown this once and never again
+//
+// The key gets the caller's role, and only scopes that role grants.
+func (h *Handlers) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	if !h.tokens.Enabled() {
+		authDisabled(w); return
+	}
+	var req struct {
+		Name   string   `json:"name"`
+		Scopes []string `json:"scopes"`
+	}
+	if !decodeJSON(w, r.Body, &req) { return }
+	req.Name = strings.TrimSpace(req.Name)
+
+	var errs []FieldError
+	if n := utf8.RuneCountInString(req.Name); n < 1 || n > 64 {
+		errs = append(errs, FieldError{Field: "name", Message: "must be 1 to 64 characters"})
+	}
+	if len(req.Scopes) == 0 {
+		errs = append(errs, FieldError{Field: "scopes", Message: "must list at least one of " + strings.Join(auth.Scopes, ", ")})
+	}
+	for _, sc := range req.Scopes {
+		if !slices.Contains(auth.Scopes, sc) { errs = append(errs, FieldError{Field: "scopes", Message: fmt.Sprintf("unknown scope %q", sc)}) }
+	}
+	if errs != nil { Unprocessable(w, errs); return }
+	for _, sc := range req.Scopes {
+		if !auth.HasScope(r.Context(), sc) { Forbidden(w, "your role doesn't grant the "+sc+" scope"); return }
+	}
+	role, _ := auth.RoleFromContext(r.Context())
+
+	key, hash, err := auth.NewAPIKey()
+	if err != nil { Internal(w, err); return }
+	slices.Sort(req.Scopes)
+	k := store.APIKey{
+		ID: primitive.NewObjectID(), UserID: auth.UserIDFromContext(r.Context()), Tenant: tenant.FromContext(r.Context()), Name: req.Name,
+		Prefix: key[:len(auth.APIKeyPrefix)+6], Hash: hash, Scopes: slices.Compact(req.Scopes), Role: role, CreatedAt: time.Now().UTC(),
+	}
+
+	ctx, cancel := requestCtx(r, 5*time.Second)
+	defer cancel()
+	if err := h.apiKeys.CreateAPIKey(ctx, k); err != nil { Internal(w, err); return }
+	created(w, struct {
+		store.APIKey
+		Key string `json:"key"`
+	}{k, key})
+}
+
+// DELETE /apikeys/{id} -> 204; requests with the key fail from then on
+//
+// Users revoke their own keys; admins any of their tenant's.
+func (h *Handlers) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	oid, valid := pathID(w, r)
+	if !valid { return }
+
+	ctx, cancel := requestCtx(r, 5*time.Second)
+	defer cancel()
+	var err error
+	if role, found := auth.RoleFromContext(r.Context()); found && auth.RoleWithin(auth.RoleAdmin, role) {
+		err = h.apiKeys.RevokeTenantAPIKey(ctx, tenant.FromContext(r.Context()), oid)
+	} else {
+		err = h.apiKeys.RevokeAPIKey(ctx, oid, auth.UserIDFromContext(r.Context()))
+	}
+	if errors.Is(err, store.ErrNotFound) { NotFound(w); return }
+	if err != nil { Internal(w, err); return }
+	noContent(w)
+}
+
+// GET /apikeys -> {"items": [...]}, the caller's tenant's keys, revoked ones
+// included, oldest first
+func (h *Handlers) ListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	if !h.tokens.Enabled() {
+		authDisabled(w); return
+	}
+	ctx, cancel := requestCtx(r, 5*time.Second)
+	defer cancel()
+	keys, err := h.apiKeys.APIKeys(ctx, tenant.FromContext(r.Context()))
+	if err != nil { Internal(w, err); return }
+	ok(w, map[string]any{"items": keys})
+}
+
+// PUT /apikeys/{id}/role  { "role": "viewer" } -> the key
+//
+// A key's role can't exceed its owner's.
+func (h *Handlers) SetAPIKeyRole(w http.ResponseWriter, r *http.Request) {
+	if !h.tokens.Enabled() {
+		authDisabled(w); return
+	}
+	oid, valid := pathID(w, r)
+	if !valid { return }
+	role, valid := decodeRole(w, r)
+	if !valid { return }
+
+	ctx, cancel := requestCtx(r, 5*time.Second)
+	defer cancel()
+	tid := tenant.FromContext(r.Context())
+	keys, err := h.apiKeys.APIKeys(ctx, tid)
+	if err != nil { Internal(w, err); return }
+	i := slices.IndexFunc(keys, func(k store.APIKey) bool { return k.ID == oid })
+	if i < 0 { NotFound(w); return }
+	users, err := h.users.Users(ctx, tid)
+	if err != nil { Internal(w, err); return }
+	if j := slices.IndexFunc(users, func(u store.User) bool { return u.ID == keys[i].UserID }); j >= 0 && !auth.RoleWithin(role, users[j].Role) {
+		Unprocessable(w, []FieldError{{Field: "role", Message: "must not exceed its owner's role, " + users[j].Role}}); return
+	}
+
+	k, err := h.apiKeys.SetAPIKeyRole(ctx, tid, oid, role)
+	if errors.Is(err, store.ErrNotFound) { NotFound(w); return }
+	if err != nil { Internal(w, err); return }
+	ok(w, k)
+}
@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"app/internal/audit"
+	"app/internal/store"
+)
+
+// GET /audit?entity=names&id=<name id>&limit=N&after=<next>  -> {"items", "next"}, newest first
+//
+// Every write to the caller's tenant, with its actor, request ID and the
+// document before and after. Bearer tokens may read it; API keys need the
+// audit:read scope.
+func (h *Handlers) Audit(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	query := store.AuditQuery{Entity: q.Get("entity"), Limit: defaultPageSize}
+	var errs []FieldError
+
+	if query.Entity != "" && query.Entity != audit.Entity {
+		errs = append(errs, FieldError{Field: "entity", Message: "must be " + audit.Entity})
+	}
+	if v := q.Get("id"); v != "" {
+		id, err := primitive.ObjectIDFromHex(v)
+		if err != nil { errs = append(errs, FieldError{Field: "id", Message: "is not a valid id"}) }
+		query.EntityID = id
+	}
+	if v := q.Get("after"); v != "" {
+		id, err := primitive.ObjectIDFromHex(v)
+		if err != nil { errs = append(errs, FieldError{Field: "after", Message: "is not a valid cursor"}) }
+		query.Before = id
+	}
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 1 || n > maxPageSize {
+			errs = append(errs, FieldError{Field: "limit", Message: "must be an integer between 1 and " + strconv.Itoa(maxPageSize)})
+		}
+		query.Limit = n
+	}
+	if errs != nil { Unprocessable(w, errs); return }
+
+	ctx, cancel := requestCtx(r, 10*time.Second)
+	defer cancel()
+	page, err := h.audit.ListAudit(ctx, query)
+	if err != nil { Internal(w, err); return }
+	pageLinks(w, r, page.Next, 0, query.Limit)
+	ok(w, page)
+}
@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"golang.org/x/crypto/bcrypt"
+
+	"app/internal/auth"
+	"app/internal/store"
+	"app/internal/tenant"
+)
+
+type credentials struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Tenant   string `json:"tenant"` // registration only
+}
+
+func decodeCredentials(w http.ResponseWriter, r *http.Request) (credentials, bool) {
+	var c credentials
+	if !decodeJSON(w, r.Body, &c) { return c, false }
+	c.Username = strings.ToLower(strings.TrimSpace(c.Username))
+	c.Tenant = strings.ToLower(strings.TrimSpace(c.Tenant))
+	return c, true
+}
+
+// POST /auth/register  { "username": "alice", "password": "...", "tenant": "team-a" }
+//
+// tenant defaults to the default tenant, which anyone may join. The first
+// user of any other tenant founds it; after that, joining takes the bearer
+// token of one of its admins, so a team registers its own people. A
+// tenant's founder is its admin and everyone after joins as an editor, until
+// an admin says otherwise (see SetUserRole).
+func (h *Handlers) Register(w http.ResponseWriter, r *http.Request) {
+	c, valid := decodeCredentials(w, r)
+	if !valid { return }
+	if c.Tenant == "" { c.Tenant = tenant.Default }
+
+	var errs []FieldError
+	if n := utf8.RuneCountInString(c.Username); n < 3 || n > 64 {
+		errs = append(errs, FieldError{Field: "username", Message: "must be 3 to 64 characters"})
+	}
+	if !tenant.Valid(c.Tenant) {
+		errs = append(errs, FieldError{Field: "tenant", Message: "must be 1 to 64 lower-case letters, digits and dashes, not starting with a dash"})
+	}
+	// bcrypt ignores everything past 72 bytes, so refuse rather than truncate.
+	if len(c.Password) < 8 || len(c.Password) > 72 {
+		errs = append(errs, FieldError{Field: "password", Message: "must be 8 to 72 bytes"})
+	}
+	if errs != nil { Unprocessable(w, errs); return }
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(c.Password), bcrypt.DefaultCost)
+	if err != nil { Internal(w, err); return }
+
+	ctx, cancel := requestCtx(r, 5*time.Second)
+	defer cancel()
+	u := store.User{ID: primitive.NewObjectID(), Username: c.Username, Tenant: c.Tenant, Role: auth.RoleEditor, PasswordHash: hash, CreatedAt: time.Now().UTC()}
+	// With auth off nobody founds anything: everyone joins, as an editor.
+	if h.tokens.Enabled() {
+		u.Role = auth.RoleAdmin
+		err = h.users.FoundTenant(ctx, u)
+	}
+	if !h.tokens.Enabled() || errors.Is(err, store.ErrTenantExists) {
+		if h.tokens.Enabled() && c.Tenant != tenant.Default {
+			if tid, role := h.bearer(r); tid != c.Tenant || !auth.RoleWithin(auth.RoleAdmin, role) {
+				Forbidden(w, "tenant "+c.Tenant+" exists; only its admins can register users into it"); return
+			}
+		}
+		u.Role = auth.RoleEditor
+		err = h.users.CreateUser(ctx, u)
+	}
+	if err != nil {
+		if errors.Is(err, store.ErrDuplicate) {
+			conflict(w, CodeDuplicateUsername, "username already taken"); return
+		}
+		Internal(w, err); return
+	}
+	created(w, u)
+}
+
+// POST /auth/login  { "username": "alice", "password": "..." } -> { "token": "<jwt>", ... }
+//
+// The token carries the user's role as it is now.
+func (h *Handlers) Login(w http.ResponseWriter, r *http.Request) {
+	if !h.tokens.Enabled() {
+		authDisabled(w); return
+	}
+	c, valid := decodeCredentials(w, r)
+	if !valid { return }
+
+	ctx, cancel := requestCtx(r, 5*time.Second)
+	defer cancel()
+	u, err := h.users.UserByUsername(ctx, c.Username)
+	if err != nil && !errors.Is(err, store.ErrNotFound) { Internal(w, err); return }
+	// Same answer for unknown user and wrong password.
+	if err != nil || bcrypt.CompareHashAndPassword(u.PasswordHash, []byte(c.Password)) != nil {
+		Unauthorized(w, "invalid username or password"); return
+	}
+
+	if u.Tenant == "" { u.Tenant = tenant.Default } // registered before tenants existed
+	token, err := h.tokens.Issue(u.ID, u.Tenant, u.Role)
+	if err != nil { Internal(w, err); return }
+	ok(w, map[string]any{"token": token, "token_type": "Bearer", "expires_in": int(h.tokens.TTL().Seconds()), "tenant": u.Tenant, "role": u.Role})
+}
+
+// bearer returns the tenant and role of the request's bearer token, or ""
+// and "" if it has no valid one. Register is a public route, so nothing has
+// checked it.
+func (h *Handlers) bearer(r *http.Request) (tid, role string) {
+	raw, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !found { return "", "" }
+	_, tid, role, err := h.tokens.Verify(strings.TrimSpace(raw))
+	if err != nil { return "", "" }
+	if tid == "" { tid = tenant.Default }
+	return tid, role
+}
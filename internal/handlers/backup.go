@@ -0,0 +1,264 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"app/internal/backup"
+	"app/internal/store"
+	"app/internal/tenant"
+	"app/internal/validate"
+)
+
+// Restore modes: insert only adds the names the tenant doesn't have;
+// upsert also gives those it has, by ID, their name, tags and metadata in
+// the dump.
+const (
+	restoreInsert = "insert"
+	restoreUpsert = "upsert"
+)
+
+// errBadBackup wraps what makes a dump unreadable.
+var errBadBackup = errors.New("the backup can't be read")
+
+// restoreSummary accounts for every name in a dump, like importSummary:
+// Skipped ones are in the trash, already there or, in insert mode, taken
+// by another name; Errored ones are invalid or failed to be written. An
+// error's line is the record's number in the dump, its line in NDJSON.
+type restoreSummary struct {
+	Backup   string           `json:"backup"`
+	Mode     string           `json:"mode"`
+	DryRun   bool             `json:"dry_run"`
+	Inserted int              `json:"inserted"`
+	Updated  int              `json:"updated"`
+	Skipped  int              `json:"skipped"`
+	Errored  int              `json:"errored"`
+	Errors   []importRowError `json:"errors"`
+}
+
+func (s *restoreSummary) reject(record int, msg string, skipped bool) {
+	if skipped { s.Skipped++ } else { s.Errored++ }
+	if len(s.Errors) < importMaxErrors { s.Errors = append(s.Errors, importRowError{record, msg}) }
+}
+
+// POST /admin/backup?format=ndjson|bson -> 202 and a job dumping the tenant's names, those in the trash too,
+// to BACKUP_DIR or BACKUP_S3_URL; its result names the backup for POST /admin/restore
+//
+// Without job workers the dump is taken in the request, and answered with
+// 201 and the same result.
+func (h *Handlers) Backup(w http.ResponseWriter, r *http.Request) {
+	if h.backups == nil { WriteProblem(w, http.StatusNotFound, CodeNotFound, "backups aren't kept anywhere; see BACKUP_DIR and BACKUP_S3_URL", nil); return }
+	format := r.URL.Query().Get("format")
+	switch format {
+	case "":
+		format = backup.NDJSON
+	case backup.NDJSON, backup.BSON:
+	default:
+		Unprocessable(w, []FieldError{{Field: "format", Message: "must be ndjson or bson"}}); return
+	}
+	if h.jobs != nil && h.jobs.Handles(jobBackup) {
+		h.enqueue(w, r, &store.Job{Type: jobBackup, Params: url.Values{"format": {format}}.Encode()})
+		return
+	}
+	ctx, cancel := requestCtx(r, time.Hour)
+	defer cancel()
+	res, err := h.backup(ctx, format)
+	if err != nil { Internal(w, err); return }
+	created(w, res)
+}
+
+func (h *Handlers) backup(ctx context.Context, format string) (backup.Result, error) {
+	res, err := backup.Dump(ctx, h.backups, tenant.FromContext(ctx), format, func(fn func(store.Name) error) error {
+		return h.names.Each(ctx, store.ListOptions{SortBy: "created_at", IncludeDeleted: true}, fn)
+	})
+	if err == nil { slog.InfoContext(ctx, "backed up the names", "backup", res.Backup, "names", res.Names, "bytes", res.Bytes) }
+	return res, err
+}
+
+func (h *Handlers) backupJob(ctx context.Context, j *store.Job) error {
+	q, err := url.ParseQuery(j.Params)
+	if err != nil { return err }
+	res, err := h.backup(ctx, q.Get("format"))
+	if err != nil { return err }
+	j.Result, err = json.Marshal(res)
+	return err
+}
+
+// POST /admin/restore?backup=<name>&mode=insert|upsert&dry_run=true -> 202 and a job loading the names
+// of one of the tenant's backups back into the store; its result counts them as restoreSummary does
+//
+// Names keep their IDs. mode=insert (the default) only adds those missing;
+// mode=upsert also overwrites those there with the backup's name, tags and
+// metadata. dry_run=true writes nothing and counts what would be. Without
+// job workers the restore is run in the request, and answered with 200;
+// a damaged backup is then a 422, the names before the damage restored.
+func (h *Handlers) Restore(w http.ResponseWriter, r *http.Request) {
+	if h.backups == nil { WriteProblem(w, http.StatusNotFound, CodeNotFound, "backups aren't kept anywhere; see BACKUP_DIR and BACKUP_S3_URL", nil); return }
+	q := r.URL.Query()
+	var errs []FieldError
+	name := q.Get("backup")
+	if _, valid := backup.Key(tenant.FromContext(r.Context()), name); !valid {
+		errs = append(errs, FieldError{Field: "backup", Message: "must name a backup, as POST /admin/backup answered"})
+	}
+	mode := q.Get("mode")
+	switch mode {
+	case "":
+		mode = restoreInsert
+	case restoreInsert, restoreUpsert:
+	default:
+		errs = append(errs, FieldError{Field: "mode", Message: "must be insert or upsert"})
+	}
+	dryRun, err := strconv.ParseBool(q.Get("dry_run"))
+	if q.Get("dry_run") != "" && err != nil { errs = append(errs, FieldError{Field: "dry_run", Message: "must be true or false"}) }
+	if errs != nil { Unprocessable(w, errs); return }
+
+	if h.jobs != nil && h.jobs.Handles(jobRestore) {
+		params := url.Values{"backup": {name}, "mode": {mode}, "dry_run": {strconv.FormatBool(dryRun)}}
+		h.enqueue(w, r, &store.Job{Type: jobRestore, Params: params.Encode()})
+		return
+	}
+	ctx, cancel := requestCtx(r, time.Hour)
+	defer cancel()
+	sum, err := h.restore(ctx, name, mode, dryRun)
+	switch {
+	case errors.Is(err, backup.ErrNotFound):
+		WriteProblem(w, http.StatusNotFound, CodeNotFound, "there is no backup "+name, nil)
+	case errors.Is(err, errBadBackup):
+		WriteProblem(w, http.StatusUnprocessableEntity, CodeBadBackup, err.Error(), map[string]any{"inserted": sum.Inserted, "updated": sum.Updated})
+	case err != nil:
+		Internal(w, err)
+	default:
+		ok(w, sum)
+	}
+}
+
+func (h *Handlers) restoreJob(ctx context.Context, j *store.Job) error {
+	q, err := url.ParseQuery(j.Params)
+	if err != nil { return err }
+	sum, err := h.restore(ctx, q.Get("backup"), q.Get("mode"), q.Get("dry_run") == "true")
+	if err != nil { return err }
+	j.Result, err = json.Marshal(sum)
+	return err
+}
+
+// restore loads the tenant's backup called name in batches of
+// importBatchSize. It stops at the first damaged record, wrapping
+// errBadBackup, or at the store's first error; the names before stay
+// restored either way. They keep the owner, UUID and slug of the backup.
+func (h *Handlers) restore(ctx context.Context, name, mode string, dryRun bool) (sum restoreSummary, err error) {
+	ctx = store.Restoring(ctx)
+	sum = restoreSummary{Backup: name, Mode: mode, DryRun: dryRun, Errors: []importRowError{}}
+	key, _ := backup.Key(tenant.FromContext(ctx), name)
+	f, err := h.backups.Get(ctx, key)
+	if err != nil { return sum, err }
+	defer f.Close()
+	dump, err := backup.NewReader(f, backup.FormatOf(name))
+	if err != nil { return sum, fmt.Errorf("%w: %w", errBadBackup, err) }
+
+	seenIDs, seenNames := map[primitive.ObjectID]bool{}, map[string]bool{}
+	var batch []store.Name
+	var records []int
+	flush := func() error {
+		if len(batch) == 0 { return nil }
+		err := h.restoreBatch(ctx, &sum, batch, records, mode, dryRun)
+		batch, records = batch[:0], records[:0]
+		return err
+	}
+	for {
+		n, err := dump.Next()
+		if errors.Is(err, io.EOF) { break }
+		if err != nil {
+			if ferr := flush(); ferr != nil { return sum, ferr }
+			return sum, fmt.Errorf("%w: %w", errBadBackup, err)
+		}
+		rec := dump.Record()
+		switch {
+		case n.ID.IsZero():
+			sum.reject(rec, "id is missing", false); continue
+		case n.ExpiresAt != nil && !n.ExpiresAt.After(time.Now()):
+			sum.reject(rec, "expired", true); continue
+		}
+		if errs := validate.Name(&n); errs != nil { sum.reject(rec, errs[0].Field+" "+errs[0].Message, false); continue }
+		if seenIDs[n.ID] || seenNames[n.Name] { sum.reject(rec, "appears earlier in the backup", true); continue }
+		seenIDs[n.ID], seenNames[n.Name] = true, true
+
+		batch, records = append(batch, n), append(records, rec)
+		if len(batch) == importBatchSize {
+			if err := flush(); err != nil { return sum, err }
+		}
+	}
+	if err := flush(); err != nil { return sum, err }
+	slices.SortStableFunc(sum.Errors, func(a, b importRowError) int { return a.Line - b.Line })
+	slog.InfoContext(ctx, "restored the names", "backup", name, "mode", mode, "dry_run", dryRun, "inserted", sum.Inserted, "updated", sum.Updated, "skipped", sum.Skipped, "errored", sum.Errored)
+	return sum, nil
+}
+
+// restoreBatch sorts a batch into the names to insert, those to update and
+// those to skip, and writes them unless dryRun.
+func (h *Handlers) restoreBatch(ctx context.Context, sum *restoreSummary, batch []store.Name, records []int, mode string, dryRun bool) error {
+	ids, names := make([]primitive.ObjectID, len(batch)), make([]string, len(batch))
+	for i, n := range batch { ids[i], names[i] = n.ID, n.Name }
+	stored, err := h.names.Lookup(ctx, ids)
+	if err != nil { return err }
+	taken, err := h.names.ExistingNames(ctx, names)
+	if err != nil { return err }
+
+	var inserts []store.Name
+	var insertRecords []int
+	for i, n := range batch {
+		old, exists := stored[n.ID]
+		switch {
+		case exists && mode == restoreInsert:
+			sum.reject(records[i], "already exists", true)
+		case exists && old.DeletedAt != nil:
+			sum.reject(records[i], "is in the trash", true)
+		case exists && dryRun:
+			sum.Updated++
+		case exists:
+			p := store.NamePatch{Name: &n.Name, Tags: &n.Tags, Metadata: &n.Metadata, ExpiresAt: store.Expiry{Set: true, At: n.ExpiresAt}, CreatedBy: &n.CreatedBy, UUID: &n.UUID, Slug: &n.Slug}
+			_, err := h.names.Patch(ctx, n.ID, p, store.AnyVersion)
+			switch {
+			case err == nil:
+				sum.Updated++
+			case errors.Is(err, store.ErrDuplicate):
+				sum.reject(records[i], "duplicate name", true)
+			case errors.Is(err, store.ErrNotFound): // deleted since Lookup
+				sum.reject(records[i], "is in the trash", true)
+			default:
+				return err
+			}
+		case taken[n.Name]:
+			sum.reject(records[i], "duplicate name", true)
+		case dryRun:
+			sum.Inserted++
+		default:
+			inserts, insertRecords = append(inserts, n), append(insertRecords, records[i])
+		}
+	}
+	if len(inserts) == 0 { return nil }
+	errs, err := h.names.InsertMany(ctx, inserts)
+	if err != nil { return err }
+	for i, err := range errs {
+		switch {
+		case err == nil:
+			sum.Inserted++
+		case errors.Is(err, store.ErrDuplicate): // created since ExistingNames
+			sum.reject(insertRecords[i], "duplicate name", true)
+		default:
+			slog.ErrorContext(ctx, "internal error", "err", err)
+			sum.reject(insertRecords[i], internalDetail, false)
+		}
+	}
+	return nil
+}
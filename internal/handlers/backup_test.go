@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"app/internal/auth"
+	"app/internal/backup"
+	"app/internal/ids"
+	"app/internal/owner"
+	"app/internal/prewrite"
+	"app/internal/store"
+	"app/internal/tenant"
+)
+
+// TestRestoreKeepsKeys round-trips names through a backup: whether a name
+// is inserted or updated, it gets back the owner, UUID and slug it had,
+// not those the layers above the store would give a name written now.
+func TestRestoreKeepsKeys(t *testing.T) {
+	mem := store.NewMemoryNames()
+	hooks, err := prewrite.New([]prewrite.Hook{{Name: "nothing", Check: "false"}})
+	if err != nil { t.Fatal(err) }
+	dir := backup.Dir(t.TempDir())
+	h := New(Deps{Names: prewrite.NewNames(ids.NewNames(owner.NewNames(mem, mem), mem, ids.Slug), hooks), Backups: dir})
+	ctx := auth.WithRole(auth.WithUserID(context.Background(), primitive.NewObjectID()), auth.RoleAdmin)
+
+	dumped := []store.Name{
+		{ID: primitive.NewObjectID(), Name: "Ada", UUID: ids.NewUUID(), Slug: "ada-lovelace", CreatedBy: primitive.NewObjectID().Hex()},
+		{ID: primitive.NewObjectID(), Name: "Grace", UUID: ids.NewUUID(), Slug: "grace-hopper", CreatedBy: primitive.NewObjectID().Hex(), Tags: []string{"navy"}},
+	}
+	// Grace is still there, with other keys and no owner, and gets updated.
+	if errs, err := mem.InsertMany(ctx, []store.Name{{ID: dumped[1].ID, Name: "Grace", Slug: "grace"}}); err != nil || errs[0] != nil { t.Fatal(err, errs) }
+	res, err := backup.Dump(ctx, dir, tenant.Default, backup.NDJSON, func(fn func(store.Name) error) error {
+		for _, n := range dumped {
+			if err := fn(n); err != nil { return err }
+		}
+		return nil
+	})
+	if err != nil { t.Fatal(err) }
+
+	sum, err := h.restore(ctx, res.Backup, restoreUpsert, false)
+	if err != nil || sum.Inserted != 1 || sum.Updated != 1 || sum.Errored != 0 { t.Fatalf("restore: %+v %v", sum, err) }
+	for _, want := range dumped {
+		got, err := mem.Get(ctx, want.ID)
+		if err != nil { t.Fatal(err) }
+		if got.CreatedBy != want.CreatedBy || got.UUID != want.UUID || got.Slug != want.Slug || len(got.Tags) != len(want.Tags) { t.Fatalf("restored %s: %+v, want %+v", want.Name, got, want) }
+	}
+
+	// Outside a restore, the same layers decide.
+	n := store.Name{Name: "Linus", UUID: "mine", Slug: "mine", CreatedBy: "someone"}
+	if err := ids.NewNames(owner.NewNames(mem, mem), mem, ids.Slug).Create(ctx, &n); err != nil { t.Fatal(err) }
+	if n.CreatedBy != auth.UserIDFromContext(ctx).Hex() || n.UUID != "" || n.Slug != "linus" { t.Fatalf("created: %+v", n) }
+}
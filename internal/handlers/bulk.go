@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"app/internal/prewrite"
+	"app/internal/store"
+	"app/internal/validate"
+)
+
+// Most items a single bulk request may carry.
+const maxBulkItems = 500
+
+// bulkResult is the outcome of one item of a bulk request. Status is the
+// code the equivalent single-item request would have answered with.
+type bulkResult struct {
+	Index  int          `json:"index"`
+	ID     string       `json:"id,omitempty"`
+	Status int          `json:"status"`
+	Error  string       `json:"error,omitempty"`
+	Code   string       `json:"code,omitempty"`
+	Fields []FieldError `json:"fields,omitempty"`
+}
+
+type bulkResponse struct {
+	Succeeded int          `json:"succeeded"`
+	Failed    int          `json:"failed"`
+	Results   []bulkResult `json:"results"`
+}
+
+func (b *bulkResponse) add(r bulkResult) {
+	if r.Status < 300 { b.Succeeded++ } else { b.Failed++ }
+	b.Results = append(b.Results, r)
+}
+
+// POST /names/bulk  [ { "name": "Alice" }, { "name": "Bob", "tags": [...] }, ... ]
+//
+// Valid items are inserted in one round trip; invalid ones are reported
+// without failing the rest. The response is 200 whenever the request itself
+// was acceptable, with a per-item status in results.
+func (h *Handlers) BulkCreate(w http.ResponseWriter, r *http.Request) {
+	var items []store.Name
+	if !decodeJSON(w, r.Body, &items) { return }
+	if len(items) == 0 || len(items) > maxBulkItems {
+		BadRequest(w, "expected between 1 and "+strconv.Itoa(maxBulkItems)+" names"); return
+	}
+
+	results := make([]bulkResult, len(items))
+	var valid []store.Name
+	var at []int // index in items of each valid entry
+	for i := range items {
+		results[i].Index = i
+		if errs := validate.Name(&items[i]); errs != nil {
+			results[i].Status, results[i].Error, results[i].Fields = http.StatusUnprocessableEntity, "validation failed", errs
+			continue
+		}
+		n := items[i]
+		valid, at = append(valid, store.Name{Name: n.Name, Tags: n.Tags, Metadata: n.Metadata, ExpiresAt: n.ExpiresAt}), append(at, i)
+	}
+
+	ctx, cancel := requestCtx(r, 30*time.Second)
+	defer cancel()
+	errs, err := h.names.CreateMany(ctx, valid)
+	if err != nil { Internal(w, err); return }
+	for j, n := range valid {
+		res := &results[at[j]]
+		var rej *prewrite.Rejected
+		switch {
+		case errs[j] == nil:
+			res.Status, res.ID = http.StatusCreated, n.ID.Hex()
+		case errors.Is(errs[j], store.ErrDuplicate):
+			res.Status, res.Error, res.Code = http.StatusConflict, "name already exists", CodeDuplicateName
+		case errors.As(errs[j], &rej):
+			res.Status, res.Error, res.Fields = http.StatusUnprocessableEntity, "validation failed", rej.Fields
+		default:
+			logInternal(w, errs[j])
+			res.Status, res.Error, res.Code = http.StatusInternalServerError, internalDetail, CodeInternal
+		}
+	}
+
+	resp := bulkResponse{Results: make([]bulkResult, 0, len(results))}
+	for _, res := range results { resp.add(res) }
+	ok(w, resp)
+}
+
+type batchGetResponse struct {
+	Items   []store.Name `json:"items"`
+	Missing []string     `json:"missing"`
+}
+
+// GET /names?ids=a,b,c&includeDeleted=  -> {"items", "missing"}, with a weak ETag
+//
+// Resolves the ids in one query. Items come in the order of ids, duplicates
+// once; ids that don't exist, or are soft-deleted unless includeDeleted=true,
+// are listed in missing instead.
+func (h *Handlers) BatchGet(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	raw := strings.Split(q.Get("ids"), ",")
+	if raw[0] == "" { BadRequest(w, "ids is required"); return }
+	if len(raw) > maxBulkItems { BadRequest(w, "at most "+strconv.Itoa(maxBulkItems)+" ids per request"); return }
+	var ids []primitive.ObjectID
+	for _, s := range raw {
+		oid, err := primitive.ObjectIDFromHex(strings.TrimSpace(s))
+		if err != nil { BadRequest(w, "invalid id "+strconv.Quote(s)); return }
+		if !slices.Contains(ids, oid) { ids = append(ids, oid) }
+	}
+
+	ctx, cancel := requestCtx(r, 10*time.Second)
+	defer cancel()
+	found, err := h.names.Lookup(ctx, ids)
+	if err != nil { Internal(w, err); return }
+
+	resp := batchGetResponse{Items: []store.Name{}, Missing: []string{}}
+	for _, id := range ids {
+		n, ok := found[id]
+		if ok && (n.DeletedAt == nil || q.Get("includeDeleted") == "true") {
+			resp.Items = append(resp.Items, n)
+		} else {
+			resp.Missing = append(resp.Missing, id.Hex())
+		}
+	}
+	okCached(w, r, resp)
+}
+
+// DELETE /names?ids=a,b,c            -> soft delete each
+// DELETE /names?ids=a,b,c&hard=true  -> permanent removal, only if ALLOW_HARD_DELETE=true
+func (h *Handlers) BulkDelete(w http.ResponseWriter, r *http.Request) {
+	raw := strings.Split(r.URL.Query().Get("ids"), ",")
+	if raw[0] == "" { BadRequest(w, "ids is required"); return }
+	if len(raw) > maxBulkItems { BadRequest(w, "at most "+strconv.Itoa(maxBulkItems)+" ids per request"); return }
+	hard := r.URL.Query().Get("hard") == "true"
+	if hard && !h.allowHardDelete { Forbidden(w, "hard delete is disabled"); return }
+
+	results := make([]bulkResult, len(raw))
+	var ids []primitive.ObjectID
+	for i, s := range raw {
+		s = strings.TrimSpace(s)
+		results[i] = bulkResult{Index: i, ID: s}
+		oid, err := primitive.ObjectIDFromHex(s)
+		if err != nil { results[i].Status, results[i].Error = http.StatusBadRequest, "invalid id"; continue }
+		ids = append(ids, oid)
+	}
+
+	ctx, cancel := requestCtx(r, 30*time.Second)
+	defer cancel()
+	existed := map[primitive.ObjectID]bool{}
+	if len(ids) > 0 {
+		var err error
+		existed, err = h.names.DeleteMany(ctx, ids, hard)
+		if errors.Is(err, store.ErrHasNotes) { hasNotes(w); return }
+		if errors.Is(err, store.ErrNotOwner) { notOwner(w); return }
+		if err != nil { Internal(w, err); return }
+	}
+
+	resp := bulkResponse{Results: make([]bulkResult, 0, len(results))}
+	for _, res := range results {
+		if res.Status == 0 {
+			oid, _ := primitive.ObjectIDFromHex(res.ID)
+			res.Status = http.StatusNoContent
+			if !existed[oid] { res.Status, res.Error = http.StatusNotFound, "not found" }
+		}
+		resp.add(res)
+	}
+	ok(w, resp)
+}
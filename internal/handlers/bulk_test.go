@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"app/internal/store"
+)
+
+func TestBatchGet(t *testing.T) {
+	ctx := context.Background()
+	names := store.NewMemoryNames()
+	alice, bob := store.Name{Name: "alice"}, store.Name{Name: "bob"}
+	for _, n := range []*store.Name{&alice, &bob} {
+		if err := names.Create(ctx, n); err != nil { t.Fatal(err) }
+	}
+	if err := names.SoftDelete(ctx, bob.ID, store.AnyVersion); err != nil { t.Fatal(err) }
+	h := New(Deps{Names: names})
+	unknown := primitive.NewObjectID().Hex()
+
+	get := func(query string) (int, batchGetResponse) {
+		rec := httptest.NewRecorder()
+		h.ListNames(rec, httptest.NewRequest(http.MethodGet, "/names?"+query, nil))
+		var body batchGetResponse
+		_ = json.Unmarshal(rec.Body.Bytes(), &body)
+		return rec.Code, body
+	}
+	ids := "ids=" + unknown + "," + alice.ID.Hex() + "," + bob.ID.Hex() + "," + alice.ID.Hex()
+
+	code, body := get(ids)
+	if code != http.StatusOK || len(body.Items) != 1 || body.Items[0].Name != "alice" || !slices.Equal(body.Missing, []string{unknown, bob.ID.Hex()}) {
+		t.Fatalf("batch get: %d %+v", code, body)
+	}
+	if code, body = get(ids + "&includeDeleted=true"); code != http.StatusOK || len(body.Items) != 2 || body.Items[1].Name != "bob" || len(body.Missing) != 1 {
+		t.Fatalf("with deleted: %d %+v", code, body)
+	}
+	if code, _ = get("ids=" + alice.ID.Hex() + ",nope"); code != http.StatusBadRequest { t.Fatalf("invalid id: %d", code) }
+	if code, _ = get("ids="); code != http.StatusBadRequest { t.Fatalf("no ids: %d", code) }
+}
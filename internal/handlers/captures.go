@@ -0,0 +1,29 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"app/internal/requestid"
+	"app/internal/store"
+)
+
+// GET /admin/captures/{request_id}  -> the request with that X-Request-ID and its response, as recorded
+// for the CAPTURE_PERCENT sample; 404 if it wasn't recorded, was another tenant's, or has been dropped since
+//
+// Secrets are left out: the Authorization, Cookie and X-API-Key headers,
+// and the bodies of sign-ups, logins and new API keys.
+func (h *Handlers) GetCapture(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("request_id")
+	if !requestid.Valid(id) { BadRequest(w, "malformed request ID"); return }
+	if h.captures == nil { WriteProblem(w, http.StatusNotFound, CodeNotFound, "requests aren't being recorded; see CAPTURE_PERCENT", nil); return }
+
+	ctx, cancel := requestCtx(r, 10*time.Second)
+	defer cancel()
+	c, err := h.captures.Capture(ctx, id)
+	if errors.Is(err, store.ErrNotFound) { NotFound(w); return }
+	if err != nil { Internal(w, err); return }
+	w.Header().Set("Cache-Control", "no-store")
+	ok(w, c)
+}
@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"app/internal/changes"
+	"app/internal/store"
+)
+
+const (
+	defaultChanges = 100
+	maxChanges     = 1000
+)
+
+// GET /names/changes?since=TOKEN&limit=N&wait=30s -> the names changed since the token, each once
+// and as it is now, with the token to ask from next; see changes.Names.Since
+//
+// A client starts without since, which answers the current token and no
+// changes, then loads the names. With wait, a request that finds no changes
+// waits up to that long (CHANGES_MAX_WAIT at most) for one. A token older
+// than CHANGES_RETENTION gets a 410: the client has to load the names again.
+func (h *Handlers) NameChanges(w http.ResponseWriter, r *http.Request) {
+	if h.changes == nil { WriteProblem(w, http.StatusNotFound, CodeNotFound, "writes aren't being logged; see CHANGES", nil); return }
+	q := r.URL.Query()
+	var errs []FieldError
+	limit := defaultChanges
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxChanges { errs = append(errs, FieldError{Field: "limit", Message: "must be an integer between 1 and " + strconv.Itoa(maxChanges)}) }
+		limit = n
+	}
+	var wait time.Duration
+	if v := q.Get("wait"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 || d > h.changes.MaxWait() { errs = append(errs, FieldError{Field: "wait", Message: "must be a duration such as 30s, up to " + h.changes.MaxWait().String()}) }
+		wait = d
+	}
+	if len(errs) > 0 { Unprocessable(w, errs); return }
+
+	ctx, cancel := requestCtx(r, wait+10*time.Second)
+	defer cancel()
+	d, err := h.changes.Since(ctx, q.Get("since"), limit, wait)
+	switch {
+	case errors.Is(err, changes.ErrBadToken):
+		Unprocessable(w, []FieldError{{Field: "since", Message: "must be a token given out by this endpoint"}})
+	case errors.Is(err, store.ErrResumeExpired):
+		WriteProblem(w, http.StatusGone, CodeResumeExpired, "the changes since this token are no longer kept; load the names again", nil)
+	case err != nil:
+		Internal(w, err)
+	default:
+		w.Header().Set("Cache-Control", "no-store")
+		ok(w, d)
+	}
+}
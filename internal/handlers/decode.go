@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// decodeJSON reads exactly one JSON value from body into v, rejecting
+// unknown fields and anything after the value. It answers itself on failure:
+// 413 if the body was cut off by a size limit, 400 otherwise, with details
+// saying what was wrong and where.
+func decodeJSON(w http.ResponseWriter, body io.Reader, v any) bool {
+	dec := json.NewDecoder(body)
+	dec.DisallowUnknownFields()
+	err := dec.Decode(v)
+	if err == nil {
+		// A second value, or garbage, after the first is an error too.
+		if err = dec.Decode(&json.RawMessage{}); errors.Is(err, io.EOF) { return true }
+		if err == nil { err = errors.New("unexpected data after the JSON value") }
+	}
+	jsonError(w, err)
+	return false
+}
+
+func jsonError(w http.ResponseWriter, err error) {
+	var (
+		mbe *http.MaxBytesError
+		se  *json.SyntaxError
+		ute *json.UnmarshalTypeError
+	)
+	var detail string
+	extra := map[string]any{}
+	switch {
+	case errors.As(err, &mbe):
+		TooLarge(w, mbe.Limit); return
+	case errors.Is(err, io.EOF):
+		detail = "request body is empty"
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		detail = "unexpected end of JSON input"
+	case errors.As(err, &se):
+		detail, extra["offset"] = se.Error(), se.Offset
+	case errors.As(err, &ute):
+		detail, extra["offset"] = fmt.Sprintf("expected %s, got %s", ute.Type, ute.Value), ute.Offset
+		if ute.Field != "" { extra["field"] = ute.Field }
+	default:
+		// Unknown fields only come back as text: json: unknown field "x".
+		detail = err.Error()
+		var field string
+		if _, scanErr := fmt.Sscanf(err.Error(), "json: unknown field %q", &field); scanErr == nil {
+			detail, extra["field"] = "unknown field", field
+		}
+	}
+	WriteProblem(w, http.StatusBadRequest, CodeInvalidJSON, "invalid JSON: "+detail, extra)
+}
@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"io/fs"
+	"net/http"
+	"strings"
+	"time"
+
+	"app/api"
+	"app/ui"
+)
+
+// GET /api/v1/openapi.json
+func (h *Handlers) OpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(api.OpenAPI)
+}
+
+// GET /api/v1/docs -> Swagger UI pointed at the openapi.json next to it
+func (h *Handlers) Docs(w http.ResponseWriter, r *http.Request) {
+	if !swaggerVendored { WriteProblem(w, http.StatusNotFound, CodeNotFound, "Swagger UI isn't vendored into this build; run go generate ./ui", nil); return }
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Security-Policy", docsCSP)
+	_, _ = w.Write([]byte(swaggerUIPage))
+}
+
+// GET /ui/ -> the admin UI; it signs in and works through the API like any client
+// GET /ui/{file} -> its script and stylesheet
+func (h *Handlers) UI(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/ui/")
+	if name == "" { name = "index.html" }
+	if _, err := fs.Stat(ui.Files, name); err != nil { NotFound(w); return } // a JSON 404, like any other
+	w.Header().Set("Content-Security-Policy", uiCSP)
+	uiFiles.ServeHTTP(w, r)
+}
+
+var uiFiles = http.StripPrefix("/ui/", http.FileServerFS(ui.Files))
+
+// GET /api/v1/docs/{file} -> Swagger UI's stylesheet and bundle, from the binary
+func (h *Handlers) DocsAsset(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("file")
+	if name != swaggerCSS && name != swaggerJS { NotFound(w); return } // not vendor.sh or the licence
+	b, err := fs.ReadFile(ui.Swagger, "swagger/"+name)
+	if err != nil { NotFound(w); return }
+	http.ServeContent(w, r, name, time.Time{}, bytes.NewReader(b))
+}
+
+const (
+	swaggerCSS = "swagger-ui.css"
+	swaggerJS  = "swagger-ui-bundle.js"
+)
+
+// swaggerVendored says whether ui/swagger/vendor.sh was run before the build.
+// Without the bundle the page would be blank, so the docs answer 404 instead.
+var swaggerVendored = func() bool {
+	_, err := fs.Stat(ui.Swagger, "swagger/"+swaggerJS)
+	return err == nil
+}()
+
+// The pages' Content-Security-Policies, which replace the API's. The UI
+// loads nothing but its own files; the docs load Swagger UI from the binary
+// too, and run the one inline script of theirs, by its hash. Swagger UI
+// styles its elements inline, which style-src has to allow.
+const uiCSP = "default-src 'self'; object-src 'none'; base-uri 'none'; form-action 'self'; frame-ancestors 'none'"
+
+var docsCSP = func() string {
+	sum := sha256.Sum256([]byte(swaggerUIInit))
+	return "default-src 'none'; script-src 'self' 'sha256-" + base64.StdEncoding.EncodeToString(sum[:]) + "'; " +
+		"style-src 'self' 'unsafe-inline'; img-src 'self' data:; connect-src 'self'; base-uri 'none'; frame-ancestors 'none'"
+}()
+
+const swaggerUIPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>LEARN_GO_API docs</title>
+  <link rel="stylesheet" href="docs/` + swaggerCSS + `">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="docs/` + swaggerJS + `"></script>
+  <script>` + swaggerUIInit + `</script>
+</body>
+</html>
+`
+
+const swaggerUIInit = `window.onload = () => { window.ui = SwaggerUIBundle({ url: "openapi.json", dom_id: "#swagger-ui" }); };`
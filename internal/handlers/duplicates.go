@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"app/internal/prewrite"
+	"app/internal/store"
+	"app/internal/validate"
+)
+
+const (
+	maxDuplicateDistance = 3   // edits GET /names/duplicates allows between names
+	maxDuplicateClusters = 500 // clusters it answers with at most
+	maxMergeSources      = 100 // names POST /names/merge folds into one at once
+)
+
+// GET /names/duplicates?distance=&limit=  -> {"items": [{"key", "names": [...]}]}, clusters of live names
+// that are one once accents, case and extra spaces are ignored, by key; with distance=1..3, also those
+// that many edits apart. limit (default 50) caps the clusters.
+//
+// Comparing with a distance is done name by name, so it is refused (422)
+// for tenants with more than store.MaxFuzzyKeys distinct names.
+func (h *Handlers) Duplicates(w http.ResponseWriter, r *http.Request) {
+	q, errs := store.DuplicateQuery{Limit: 50}, []FieldError(nil)
+	if v := r.URL.Query().Get("distance"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 || n > maxDuplicateDistance { errs = append(errs, FieldError{Field: "distance", Message: "must be an integer between 0 and " + strconv.Itoa(maxDuplicateDistance)}) }
+		q.Distance = n
+	}
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxDuplicateClusters { errs = append(errs, FieldError{Field: "limit", Message: "must be an integer between 1 and " + strconv.Itoa(maxDuplicateClusters)}) }
+		q.Limit = n
+	}
+	if errs != nil { Unprocessable(w, errs); return }
+
+	ctx, cancel := requestCtx(r, 30*time.Second)
+	defer cancel()
+	clusters, err := h.dups.Duplicates(ctx, q)
+	if errors.Is(err, store.ErrTooManyNames) {
+		Unprocessable(w, []FieldError{{Field: "distance", Message: "there are too many names to compare with a distance; leave it out to find the names that are equal once normalized"}})
+		return
+	}
+	if err != nil { Internal(w, err); return }
+	ok(w, map[string]any{"items": clusters})
+}
+
+// POST /names/merge  { "into": "<id>", "from": ["<id>", ...], "if_version": 3 }  -> the name merged into
+//
+// Folds the names of from into the one of into: it gains their tags, after
+// its own, and the metadata keys it lacks, the first of from having it
+// winning; then they are soft-deleted, so GET /names/trash still has them,
+// notes and all. if_version, the version into must be at, is required as
+// If-Match is for PUT: without it the answer is a 428. Every name must be
+// live, else 404. The writes apply together where the store has
+// transactions; elsewhere one after another, and a merge cut short may be
+// asked for again with the names of from that are left.
+func (h *Handlers) MergeNames(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Into      string   `json:"into"`
+		From      []string `json:"from"`
+		IfVersion *int64   `json:"if_version"`
+	}
+	if !decodeJSON(w, r.Body, &req) { return }
+	into, from, errs := parseMerge(req.Into, req.From)
+	if req.IfVersion != nil && *req.IfVersion < 1 { errs = append(errs, FieldError{Field: "if_version", Message: "must be a positive integer"}) }
+	if errs != nil { Unprocessable(w, errs); return }
+	if req.IfVersion == nil {
+		WriteProblem(w, http.StatusPreconditionRequired, CodePreconditionRequired, "if_version is required", nil); return
+	}
+	version := *req.IfVersion
+
+	ctx, cancel := requestCtx(r, 30*time.Second)
+	defer cancel()
+	var merged store.Name
+	run := func(ctx context.Context) (err error) {
+		merged, err = h.merge(ctx, into, from, version)
+		return err
+	}
+	err := store.ErrTransactionsUnsupported
+	if h.tx != nil { err = h.tx.InTransaction(ctx, run) }
+	if errors.Is(err, store.ErrTransactionsUnsupported) { err = run(ctx) }
+
+	var invalid validationError
+	var rej *prewrite.Rejected
+	switch {
+	case errors.As(err, &invalid):
+		Unprocessable(w, invalid)
+	case errors.As(err, &rej):
+		Unprocessable(w, rej.Fields)
+	case errors.Is(err, store.ErrNotFound):
+		NotFound(w)
+	case errors.Is(err, store.ErrVersionMismatch):
+		preconditionFailed(w)
+	case errors.Is(err, store.ErrNotOwner):
+		notOwner(w)
+	case err != nil:
+		Internal(w, err)
+	default:
+		setETag(w, merged)
+		ok(w, merged)
+	}
+}
+
+// validationError is a merge refused for the name it would make.
+type validationError []FieldError
+
+func (e validationError) Error() string { return "validation failed" }
+
+// parseMerge checks the IDs of a merge.
+func parseMerge(into string, from []string) (primitive.ObjectID, []primitive.ObjectID, []FieldError) {
+	var errs []FieldError
+	target, err := primitive.ObjectIDFromHex(into)
+	if err != nil { errs = append(errs, FieldError{Field: "into", Message: "must be the ID of a name"}) }
+	if len(from) == 0 || len(from) > maxMergeSources {
+		return target, nil, append(errs, FieldError{Field: "from", Message: "must have between 1 and " + strconv.Itoa(maxMergeSources) + " IDs"})
+	}
+	ids := make([]primitive.ObjectID, 0, len(from))
+	for _, s := range from {
+		id, err := primitive.ObjectIDFromHex(s)
+		switch {
+		case err != nil:
+			return target, nil, append(errs, FieldError{Field: "from", Message: "must be IDs of names"})
+		case id == target || slices.Contains(ids, id):
+			return target, nil, append(errs, FieldError{Field: "from", Message: "must not repeat an ID, nor have the one of into"})
+		}
+		ids = append(ids, id)
+	}
+	return target, ids, errs
+}
+
+// merge folds the names of from into the one of into, at version, and
+// returns it as stored.
+func (h *Handlers) merge(ctx context.Context, into primitive.ObjectID, from []primitive.ObjectID, version int64) (store.Name, error) {
+	found, err := h.names.Lookup(ctx, append([]primitive.ObjectID{into}, from...))
+	if err != nil { return store.Name{}, err }
+	target, live := found[into]
+	if !live || target.DeletedAt != nil { return store.Name{}, store.ErrNotFound }
+	if target.Version != version { return store.Name{}, store.ErrVersionMismatch }
+
+	tags, meta := slices.Clone(target.Tags), maps.Clone(target.Metadata)
+	for _, id := range from {
+		n, live := found[id]
+		if !live || n.DeletedAt != nil { return store.Name{}, store.ErrNotFound }
+		for _, t := range n.Tags {
+			if !slices.Contains(tags, t) { tags = append(tags, t) }
+		}
+		for k, v := range n.Metadata {
+			if meta == nil { meta = map[string]any{} }
+			if _, taken := meta[k]; !taken { meta[k] = v }
+		}
+	}
+	p := store.NamePatch{Tags: &tags, Metadata: &meta}
+	if errs := validate.Patch(&p); errs != nil { return store.Name{}, validationError(errs) }
+
+	merged, err := h.names.Patch(ctx, into, p, target.Version)
+	if err != nil { return store.Name{}, err }
+	for _, id := range from {
+		if err := h.names.SoftDelete(ctx, id, found[id].Version); err != nil { return store.Name{}, err }
+	}
+	return merged, nil
+}
@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"app/internal/auth"
+	"app/internal/store"
+	"app/internal/tenant"
+)
+
+// A name's ETag is its version in quotes: "3".
+func setETag(w http.ResponseWriter, n store.Name) { setVersionETag(w, n.Version) }
+
+// setVersionETag is setETag for anything else with a version: a resource's
+// documents.
+func setVersionETag(w http.ResponseWriter, version int64) {
+	w.Header().Set("ETag", `"`+strconv.FormatInt(version, 10)+`"`)
+}
+
+// ifMatch reads the version a write is conditional on. If-Match is required:
+// without it the answer is a 428, and a value that can't be one of our ETags
+// gets a 412 straight away. "*" matches any version.
+func ifMatch(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	v := strings.TrimSpace(r.Header.Get("If-Match"))
+	if v == "" { preconditionRequired(w); return 0, false }
+	if v == "*" { return store.AnyVersion, true }
+
+	unquoted, found := strings.CutPrefix(v, `"`)
+	unquoted, closed := strings.CutSuffix(unquoted, `"`)
+	n, err := strconv.ParseInt(unquoted, 10, 64)
+	if !found || !closed || err != nil || n < 0 { preconditionFailed(w); return 0, false }
+	return n, true
+}
+
+// Reads may be stored by the client but not shared with other users, and
+// must be revalidated every time: with the ETag, that costs a 304 and no
+// body when nothing changed.
+const cacheControl = "private, no-cache"
+
+// notModified answers 304 if If-None-Match lists etag (or is "*"). The
+// comparison is weak, as RFC 9110 asks for GET.
+func notModified(w http.ResponseWriter, r *http.Request, etag string) bool {
+	inm := r.Header.Get("If-None-Match")
+	if inm == "" { return false }
+	for _, tag := range strings.Split(inm, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == strings.TrimPrefix(etag, "W/") {
+			w.WriteHeader(http.StatusNotModified)
+			return true
+		}
+	}
+	return false
+}
+
+// unchanged is notModified that also honours If-Modified-Since, which
+// only counts without If-None-Match and only to the second. It sets
+// Last-Modified to modified, unless that's zero for unknown.
+func unchanged(w http.ResponseWriter, r *http.Request, etag string, modified time.Time) bool {
+	if !modified.IsZero() { w.Header().Set("Last-Modified", modified.UTC().Format(http.TimeFormat)) }
+	if r.Header.Get("If-None-Match") != "" { return notModified(w, r, etag) }
+	if modified.IsZero() { return false }
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil || modified.Truncate(time.Second).After(since) { return false }
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// listETag is the weak ETag of a listing at rev: whatever the query and the
+// representation asked for, it changes with every write to the tenant's
+// names. The caller is part of it, as ?mine=true lists different names to
+// each. The time is part of it so that a counter starting over, as the
+// memory store's does on a restart, can't bring back an old tag.
+func listETag(r *http.Request, rev store.Revision) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n%s\n%d\n%d\n%s\n%s", tenant.FromContext(r.Context()), auth.UserIDFromContext(r.Context()).Hex(), rev.N, rev.At.UnixNano(), r.URL.RawQuery, r.Header.Get("Accept"))
+	return `W/"` + hex.EncodeToString(h.Sum(nil)[:8]) + `"`
+}
+
+// okCached writes v as a 200 with a weak ETag derived from the body, or a
+// 304 if the client already has that body. For responses that, unlike a
+// single name, have no version of their own.
+func okCached(w http.ResponseWriter, r *http.Request, v any) {
+	body, err := json.Marshal(v)
+	if err != nil { Internal(w, err); return }
+	body = append(body, '\n')
+	sum := sha256.Sum256(body)
+	etag := `W/"` + hex.EncodeToString(sum[:8]) + `"`
+	w.Header().Set("Cache-Control", cacheControl)
+	w.Header().Set("ETag", etag)
+	if notModified(w, r, etag) { return }
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(body)
+}
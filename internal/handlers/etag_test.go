@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"app/internal/store"
+)
+
+func TestIfMatch(t *testing.T) {
+	for _, tc := range []struct {
+		header  string
+		version int64
+		status  int // 0: accepted
+	}{
+		{`"3"`, 3, 0},
+		{` "0" `, 0, 0},
+		{`*`, store.AnyVersion, 0},
+		{``, 0, http.StatusPreconditionRequired},
+		{`3`, 0, http.StatusPreconditionFailed},
+		{`W/"3"`, 0, http.StatusPreconditionFailed},
+		{`"-1"`, 0, http.StatusPreconditionFailed},
+		{`"3", "4"`, 0, http.StatusPreconditionFailed},
+	} {
+		r := httptest.NewRequest(http.MethodPut, "/names/x", nil)
+		if tc.header != "" { r.Header.Set("If-Match", tc.header) }
+		rec := httptest.NewRecorder()
+		v, accepted := ifMatch(rec, r)
+		switch {
+		case tc.status == 0 && (!accepted || v != tc.version):
+			t.Errorf("If-Match %q: got %d, %v; want %d", tc.header, v, accepted, tc.version)
+		case tc.status != 0 && (accepted || rec.Code != tc.status):
+			t.Errorf("If-Match %q: got status %d, want %d", tc.header, rec.Code, tc.status)
+		}
+	}
+}
+
+func TestNotModified(t *testing.T) {
+	for _, tc := range []struct {
+		header, etag string
+		want         bool
+	}{
+		{``, `"3"`, false},
+		{`"3"`, `"3"`, true},
+		{`"2", W/"3"`, `"3"`, true},
+		{`"abc"`, `W/"abc"`, true},
+		{`*`, `"3"`, true},
+		{`"4"`, `"3"`, false},
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/names/x", nil)
+		if tc.header != "" { r.Header.Set("If-None-Match", tc.header) }
+		rec := httptest.NewRecorder()
+		if got := notModified(rec, r, tc.etag); got != tc.want || got != (rec.Code == http.StatusNotModified) {
+			t.Errorf("If-None-Match %q against %s: got %v (status %d), want %v", tc.header, tc.etag, got, rec.Code, tc.want)
+		}
+	}
+}
+
+func TestUnchanged(t *testing.T) {
+	modified := time.Date(2024, 5, 1, 12, 0, 0, 500_000_000, time.UTC)
+	for _, tc := range []struct {
+		inm, ims string
+		modified time.Time
+		want     bool
+	}{
+		{``, ``, modified, false},
+		{``, `Wed, 01 May 2024 12:00:00 GMT`, modified, true}, // to the second
+		{``, `Wed, 01 May 2024 11:59:59 GMT`, modified, false},
+		{``, `Wed, 01 May 2024 13:00:00 GMT`, time.Time{}, false},
+		{``, `yesterday`, modified, false},
+		{`"3"`, `Wed, 01 May 2024 13:00:00 GMT`, modified, true},
+		{`"4"`, `Wed, 01 May 2024 13:00:00 GMT`, modified, false}, // If-None-Match wins
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/names/x", nil)
+		if tc.inm != "" { r.Header.Set("If-None-Match", tc.inm) }
+		if tc.ims != "" { r.Header.Set("If-Modified-Since", tc.ims) }
+		rec := httptest.NewRecorder()
+		if got := unchanged(rec, r, `"3"`, tc.modified); got != tc.want || got != (rec.Code == http.StatusNotModified) {
+			t.Errorf("If-None-Match %q, If-Modified-Since %q: got %v (status %d), want %v", tc.inm, tc.ims, got, rec.Code, tc.want)
+		}
+		if lm := rec.Header().Get("Last-Modified"); lm != "" != !tc.modified.IsZero() || lm != "" && lm != "Wed, 01 May 2024 12:00:00 GMT" {
+			t.Errorf("Last-Modified %q", lm)
+		}
+	}
+}
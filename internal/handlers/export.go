@@ -0,0 +1,254 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"app/internal/requestid"
+	"app/internal/store"
+)
+
+// Flush to the client every exportFlushEvery documents.
+const exportFlushEvery = 500
+
+var errClientGone = errors.New("client went away")
+
+// exportCSVHeader puts name first, so an export can go straight back into
+// POST /names/import?header=true. Tags are joined with ";" and metadata is
+// a JSON object.
+var exportCSVHeader = []string{"name", "tags", "metadata", "id", "created_at", "updated_at", "deleted_at", "version"}
+
+// exporter writes names in one of the export formats.
+type exporter interface {
+	begin() error
+	write(store.Name) error
+	// fail appends a marker for a failure after the 200 went out.
+	fail(requestID string)
+	flush() error
+	// end finishes the output after the last name.
+	end() error
+}
+
+type ndjsonExporter struct {
+	enc    *json.Encoder
+	fields []string // see project
+}
+
+func (e ndjsonExporter) begin() error { return nil }
+func (e ndjsonExporter) write(n store.Name) error {
+	v, err := project(n, e.fields)
+	if err != nil { return err }
+	return e.enc.Encode(v)
+}
+func (e ndjsonExporter) fail(id string) {
+	_ = e.enc.Encode(map[string]string{"error": "export aborted", "request_id": id})
+}
+func (e ndjsonExporter) flush() error { return nil }
+func (e ndjsonExporter) end() error   { return nil }
+
+// jsonArrayExporter writes one JSON array, a name at a time. A failure
+// leaves it unterminated, which no client will take for a whole array.
+type jsonArrayExporter struct {
+	w      io.Writer
+	enc    *json.Encoder
+	fields []string
+	n      int
+}
+
+func (e *jsonArrayExporter) begin() error { _, err := io.WriteString(e.w, "["); return err }
+func (e *jsonArrayExporter) write(n store.Name) error {
+	if e.n++; e.n > 1 {
+		if _, err := io.WriteString(e.w, ","); err != nil { return err }
+	}
+	v, err := project(n, e.fields)
+	if err != nil { return err }
+	return e.enc.Encode(v)
+}
+func (e *jsonArrayExporter) fail(string)  {}
+func (e *jsonArrayExporter) flush() error { return nil }
+func (e *jsonArrayExporter) end() error   { _, err := io.WriteString(e.w, "]\n"); return err }
+
+type csvExporter struct{ w *csv.Writer }
+
+func (e csvExporter) begin() error { return e.w.Write(exportCSVHeader) }
+func (e csvExporter) write(n store.Name) error {
+	var metadata, deleted string
+	if len(n.Metadata) > 0 {
+		b, err := json.Marshal(n.Metadata)
+		if err != nil { return err }
+		metadata = string(b)
+	}
+	if n.DeletedAt != nil { deleted = n.DeletedAt.Format(time.RFC3339Nano) }
+	return e.w.Write([]string{
+		n.Name, strings.Join(n.Tags, ";"), metadata, n.ID.Hex(),
+		n.CreatedAt.Format(time.RFC3339Nano), n.UpdatedAt.Format(time.RFC3339Nano), deleted, strconv.FormatInt(n.Version, 10),
+	})
+}
+func (e csvExporter) fail(id string) {
+	_ = e.w.Write([]string{"# export aborted", "request_id=" + id})
+	e.w.Flush()
+}
+func (e csvExporter) flush() error { e.w.Flush(); return e.w.Error() }
+func (e csvExporter) end() error   { return e.flush() }
+
+// newExporter writes names in format, "ndjson", "csv" or "json" (an
+// array), to w; but for CSV, only their fields if any are given.
+func newExporter(format string, w io.Writer, fields []string) exporter {
+	switch format {
+	case "csv":
+		return csvExporter{csv.NewWriter(w)}
+	case "json":
+		return &jsonArrayExporter{w: w, enc: json.NewEncoder(w), fields: fields}
+	}
+	return ndjsonExporter{json.NewEncoder(w), fields}
+}
+
+// exportContentType is the media type of an export in format.
+func exportContentType(format string) string {
+	switch format {
+	case "csv":
+		return "text/csv; charset=utf-8"
+	case "json":
+		return "application/json"
+	}
+	return "application/x-ndjson"
+}
+
+// parseExportQuery reads GET /names/export's query: parseListQuery's and
+// the format.
+func parseExportQuery(q url.Values) (store.ListOptions, string, []FieldError) {
+	opts, errs := parseListQuery(q)
+	format := q.Get("format")
+	switch format {
+	case "":
+		format = "ndjson"
+	case "ndjson", "csv":
+	default:
+		errs = append(errs, FieldError{Field: "format", Message: "must be ndjson or csv"})
+	}
+	return opts, format, errs
+}
+
+// StreamFormat is the format to stream GET /names in: "ndjson" if Accept
+// names application/x-ndjson, "json" with stream=true, or none.
+func StreamFormat(r *http.Request) string {
+	for _, v := range strings.Split(r.Header.Get("Accept"), ",") {
+		if mt, _, err := mime.ParseMediaType(v); err == nil && mt == "application/x-ndjson" { return "ndjson" }
+	}
+	if r.URL.Query().Get("stream") == "true" { return "json" }
+	return ""
+}
+
+// GET /names/export?format=ndjson|csv&sort=&name=&includeDeleted=
+//
+// Streams every name matching the GET /names filters, in its sort order;
+// paging parameters are ignored. NDJSON (the default) has one Name per line,
+// CSV the columns of exportCSVHeader. Documents are encoded as the store
+// yields them, so memory stays flat no matter how big the collection is.
+// The query runs on the request context: if the client goes away the
+// cursor is abandoned. With Prefer: respond-async a job writes the export
+// instead, for GET /jobs/{id}/output.
+func (h *Handlers) Export(w http.ResponseWriter, r *http.Request) {
+	if h.jobs != nil { w.Header().Add("Vary", "Prefer") }
+	opts, format, errs := parseExportQuery(r.URL.Query())
+	if errs != nil { Unprocessable(w, errs); return }
+	opts.Consistency = h.consistency(opts.Consistency)
+	if h.async(r, jobExport) {
+		h.enqueue(w, r, &store.Job{Type: jobExport, Params: r.URL.RawQuery})
+		return
+	}
+
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="names-%s.%s"`, time.Now().UTC().Format("20060102T150405Z"), format))
+	h.streamNames(w, r, opts, format)
+}
+
+// streamNames writes every name matching opts to w in format, encoding each
+// as the store yields it and flushing every exportFlushEvery, on the request
+// context: if the client goes away the cursor is abandoned.
+func (h *Handlers) streamNames(w http.ResponseWriter, r *http.Request, opts store.ListOptions, format string) {
+	ctx := r.Context()
+	rc := http.NewResponseController(w)
+	out := newExporter(format, w, opts.Fields)
+
+	// The status is only committed once the store has produced something (or
+	// finished cleanly), so a query that fails up front still gets a 500.
+	started := false
+	start := func() error {
+		if started { return nil }
+		started = true
+		w.Header().Set("Content-Type", exportContentType(format))
+		w.WriteHeader(http.StatusOK)
+		return out.begin()
+	}
+
+	n := 0
+	err := h.names.Each(ctx, opts, func(doc store.Name) error {
+		if err := start(); err != nil { return errClientGone }
+		if err := out.write(doc); err != nil { return errClientGone }
+		if n++; n%exportFlushEvery == 0 {
+			if err := out.flush(); err != nil { return errClientGone }
+			_ = rc.Flush()
+		}
+		return nil
+	})
+	switch {
+	case err == nil:
+		if start() == nil && out.end() == nil { _ = rc.Flush() }
+	case !started:
+		w.Header().Del("Content-Disposition")
+		listFailed(w, err)
+	case errors.Is(err, errClientGone) || ctx.Err() != nil:
+	default:
+		// Headers are long gone, so a mid-stream failure can't change the status:
+		// log it and leave a marker the client can detect.
+		exportFailed(ctx, out, err)
+	}
+}
+
+// exportJob is Export's work as a job. The export becomes the job's Output,
+// which is held in memory and so capped at jobMaxBytes; the Result counts
+// its names and bytes.
+func (h *Handlers) exportJob(ctx context.Context, j *store.Job) error {
+	q, err := url.ParseQuery(j.Params)
+	if err != nil { return err }
+	opts, format, errs := parseExportQuery(q)
+	if errs != nil { return fmt.Errorf("%s %s", errs[0].Field, errs[0].Message) }
+	opts.Consistency = h.consistency(opts.Consistency)
+
+	var buf bytes.Buffer
+	out, n := newExporter(format, &buf, nil), 0
+	if err := out.begin(); err != nil { return err }
+	err = h.names.Each(ctx, opts, func(doc store.Name) error {
+		if err := out.write(doc); err != nil { return err }
+		n++
+		if buf.Len() > jobMaxBytes { return errExportTooLarge }
+		return nil
+	})
+	if err == nil { err = out.end() }
+	if err != nil { return err }
+	if buf.Len() > jobMaxBytes { return errExportTooLarge }
+	j.Output = buf.Bytes()
+	j.Result, err = json.Marshal(map[string]any{"format": format, "names": n, "bytes": buf.Len()})
+	return err
+}
+
+var errExportTooLarge = fmt.Errorf("the export is larger than %d bytes; export without Prefer: respond-async to stream it", jobMaxBytes)
+
+// exportFailed logs err, which cut short a stream of names, and marks out
+// as aborted.
+func exportFailed(ctx context.Context, out exporter, err error) {
+	slog.ErrorContext(ctx, "export aborted", "err", err)
+	out.fail(requestid.FromContext(ctx))
+}
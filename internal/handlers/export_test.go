@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"app/internal/store"
+)
+
+func TestExport(t *testing.T) {
+	ctx := context.Background()
+	names := store.NewMemoryNames()
+	for _, n := range []store.Name{
+		{Name: "bob", Tags: []string{"a", "b"}, Metadata: map[string]any{"team": "core"}},
+		{Name: "alice"},
+		{Name: "carol"},
+	} {
+		if err := names.Create(ctx, &n); err != nil { t.Fatal(err) }
+	}
+	h := New(Deps{Names: names})
+
+	export := func(query string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.Export(rec, httptest.NewRequest(http.MethodGet, "/names/export?"+query, nil))
+		return rec
+	}
+
+	rec := export("format=csv&sort=name")
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "text/csv; charset=utf-8" { t.Fatalf("csv: %d %q", rec.Code, rec.Header().Get("Content-Type")) }
+	if cd := rec.Header().Get("Content-Disposition"); !strings.HasPrefix(cd, `attachment; filename="names-`) || !strings.HasSuffix(cd, `.csv"`) { t.Fatalf("Content-Disposition %q", cd) }
+	rows, err := csv.NewReader(rec.Body).ReadAll()
+	if err != nil { t.Fatal(err) }
+	if len(rows) != 4 || strings.Join(rows[0], ",") != strings.Join(exportCSVHeader, ",") { t.Fatalf("rows %q", rows) }
+	if got := rows[1][0] + rows[2][0] + rows[3][0]; got != "alicebobcarol" { t.Fatalf("order %q", got) }
+	if rows[2][1] != "a;b" || rows[2][2] != `{"team":"core"}` || rows[2][7] != "1" { t.Fatalf("bob %q", rows[2]) }
+
+	rec = export("sort=-name&name=b")
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/x-ndjson" { t.Fatalf("ndjson: %d", rec.Code) }
+	if lines := strings.Split(strings.TrimSpace(rec.Body.String()), "\n"); len(lines) != 1 || !strings.Contains(lines[0], `"name":"bob"`) {
+		t.Fatalf("filtered ndjson %q", rec.Body)
+	}
+
+	if rec = export("format=xml"); rec.Code != http.StatusUnprocessableEntity { t.Fatalf("bad format: %d", rec.Code) }
+}
+
+func TestListStream(t *testing.T) {
+	ctx := context.Background()
+	names := store.NewMemoryNames()
+	for _, name := range []string{"bob", "alice", "carol"} {
+		if err := names.Create(ctx, &store.Name{Name: name}); err != nil { t.Fatal(err) }
+	}
+	h := New(Deps{Names: names})
+
+	list := func(query string, header ...string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/names?"+query, nil)
+		for i := 0; i < len(header); i += 2 { r.Header.Set(header[i], header[i+1]) }
+		h.ListNames(rec, r)
+		return rec
+	}
+
+	// Paging is ignored; the filters and sort aren't.
+	rec := list("sort=name&limit=1&name=", "Accept", "application/json, application/x-ndjson;q=0.9")
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/x-ndjson" || rec.Header().Get("Vary") != "Accept" { t.Fatalf("ndjson: %d %v", rec.Code, rec.Header()) }
+	lines := strings.Split(strings.TrimSpace(rec.Body.String()), "\n")
+	if len(lines) != 3 || !strings.Contains(lines[0], `"name":"alice"`) || !strings.Contains(lines[2], `"name":"carol"`) { t.Fatalf("ndjson %q", rec.Body) }
+
+	rec = list("stream=true&sort=-name&offset=1")
+	var got []store.Name
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil || rec.Header().Get("Content-Type") != "application/json" { t.Fatalf("array %q: %v", rec.Body, err) }
+	if len(got) != 3 || got[0].Name != "carol" || got[2].Name != "alice" { t.Fatalf("array %+v", got) }
+	if rec = list("stream=true&name=zed"); strings.TrimSpace(rec.Body.String()) != "[]" { t.Fatalf("empty array %q", rec.Body) }
+	var slim []map[string]any
+	if rec = list("stream=true&fields=name"); json.Unmarshal(rec.Body.Bytes(), &slim) != nil || len(slim) != 3 || len(slim[0]) != 2 || slim[0]["name"] == nil { t.Fatalf("projected array %q", rec.Body) }
+
+	// Without either, a page as ever.
+	var page store.Page
+	if rec = list("limit=1"); json.Unmarshal(rec.Body.Bytes(), &page) != nil || len(page.Items) != 1 || page.Total != 3 { t.Fatalf("page %q", rec.Body) }
+}
@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/url"
+	"slices"
+	"strings"
+
+	"app/internal/store"
+)
+
+// parseFields reads ?fields=name,created_at: the fields of each name to
+// answer with, of store.NameFields. nil means all of them.
+func parseFields(q url.Values) ([]string, []FieldError) {
+	v := q.Get("fields")
+	if v == "" { return nil, nil }
+	fields := []string{"id"}
+	for _, f := range strings.Split(v, ",") {
+		f = strings.TrimSpace(f)
+		if !slices.Contains(store.NameFields, f) {
+			return nil, []FieldError{{Field: "fields", Message: "must be a comma-separated list of " + strings.Join(store.NameFields, ", ")}}
+		}
+		if !slices.Contains(fields, f) { fields = append(fields, f) }
+	}
+	return fields, nil
+}
+
+// project cuts n down to fields, or leaves it whole without any. Fields
+// that are empty are left out, as ever.
+func project(n store.Name, fields []string) (any, error) {
+	if fields == nil { return n, nil }
+	b, err := json.Marshal(n)
+	if err != nil { return nil, err }
+	var all map[string]json.RawMessage
+	if err := json.Unmarshal(b, &all); err != nil { return nil, err }
+	out := make(map[string]json.RawMessage, len(fields))
+	for _, f := range fields {
+		if v, ok := all[f]; ok { out[f] = v }
+	}
+	return out, nil
+}
+
+// projectPage is page with its items cut down to fields.
+func projectPage(page store.Page, fields []string) (any, error) {
+	if fields == nil { return page, nil }
+	out := struct {
+		Items []any  `json:"items"`
+		Total int64  `json:"total"`
+		Next  string `json:"next,omitempty"`
+	}{make([]any, len(page.Items)), page.Total, page.Next}
+	for i, n := range page.Items {
+		var err error
+		if out.Items[i], err = project(n, fields); err != nil { return nil, err }
+	}
+	return out, nil
+}
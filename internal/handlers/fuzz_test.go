@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"app/internal/validate"
+)
+
+// extractID is gone since the move to ServeMux patterns; the equivalent
+// surface is the mux's {id} matching plus pathID. Both must hold up for any
+// path: never panic, never yield an id containing "/", and accept exactly
+// the 24-hex-digit ObjectIDs.
+func FuzzPathID(f *testing.F) {
+	for _, seed := range []string{
+		"/names/",
+		"/names//",
+		"/names/%2F",
+		"/names/%2f/events",
+		"/names/665f1c2e9b1e8a3d4c5b6a79",
+		"/names/665F1C2E9B1E8A3D4C5B6A79",
+		"/names/665f1c2e9b1e8a3d4c5b6a7",
+		"/names/a/b/c/d/e/f/g/h",
+		"/names////////x",
+		"/names/../names/x",
+		"/names/\xff\xfe\xfd",
+		"/names/\x00",
+		"names/x",
+		"",
+	} {
+		f.Add(seed)
+	}
+
+	var hit bool
+	var id string
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /names/{id}", func(w http.ResponseWriter, r *http.Request) {
+		hit, id = true, r.PathValue("id")
+		pathID(w, r)
+	})
+
+	f.Fuzz(func(t *testing.T, path string) {
+		hit, id = false, ""
+		r := &http.Request{Method: http.MethodGet, URL: &url.URL{Path: path}, Header: http.Header{}}
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, r)
+		if !hit { return }
+
+		if id == "" || strings.Contains(id, "/") {
+			t.Fatalf("path %q matched with bad id %q", path, id)
+		}
+		wantValid := len(id) == 24 && strings.Trim(strings.ToLower(id), "0123456789abcdef") == ""
+		if gotValid := rec.Code != http.StatusBadRequest; gotValid != wantValid {
+			t.Fatalf("path %q: id %q valid=%v, want %v", path, id, gotValid, wantValid)
+		}
+
+		// Same input, same answer.
+		r2 := &http.Request{Method: http.MethodGet, URL: &url.URL{Path: path}, Header: http.Header{}}
+		r2.SetPathValue("id", id)
+		oid, valid := pathID(httptest.NewRecorder(), r2)
+		if valid != wantValid || (valid && oid.Hex() != strings.ToLower(id)) {
+			t.Fatalf("pathID(%q) = %v, %v", id, oid.Hex(), valid)
+		}
+	})
+}
+
+// Whatever the body, decoding either succeeds with a clean Name or answers a
+// problem+json 400 (malformed) / 422 (invalid) — never a panic or any other status.
+func FuzzDecodeName(f *testing.F) {
+	for _, seed := range []string{
+		`{"name":"Alice"}`,
+		`{"name":"  Bob  ","tags":[" a ","b"],"metadata":{"k":{"nested":[1,2,3]}}}`,
+		`{"name":""}`,
+		`{"name":"   "}`,
+		`{"name":"x","tags":[""]}`,
+		`{"name":"x","tags":null,"metadata":null}`,
+		`{"name":123}`,
+		`{"name":"x"} trailing`,
+		`{"name":"x"}{"name":"y"}`,
+		`{"name":"x","nmae":"y"}`,
+		`[]`,
+		`null`,
+		`{`,
+		"{\"name\":\"\xff\xfe\"}",
+		"",
+	} {
+		f.Add([]byte(seed))
+	}
+
+	f.Fuzz(func(t *testing.T, body []byte) {
+		rec := httptest.NewRecorder()
+		n, valid := decodeName(rec, bytes.NewReader(body))
+		if valid {
+			if rec.Body.Len() != 0 { t.Fatalf("valid decode wrote a response: %s", rec.Body) }
+			if n.Name == "" || n.Name != strings.TrimSpace(n.Name) { t.Fatalf("name not normalized: %q", n.Name) }
+			if !utf8.ValidString(n.Name) { t.Fatalf("name not valid UTF-8: %q", n.Name) }
+			if len(n.Tags) > validate.MaxTags { t.Fatalf("%d tags accepted", len(n.Tags)) }
+			for _, tag := range n.Tags {
+				if tag == "" || tag != strings.TrimSpace(tag) || len(tag) > validate.MaxTagLen { t.Fatalf("bad tag accepted: %q", tag) }
+			}
+			return
+		}
+		if rec.Code != http.StatusBadRequest && rec.Code != http.StatusUnprocessableEntity {
+			t.Fatalf("status %d for %q", rec.Code, body)
+		}
+		var resp map[string]any
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil || resp["status"] != float64(rec.Code) || resp["code"] == nil {
+			t.Fatalf("%d body is not a problem: %s", rec.Code, rec.Body)
+		}
+		if ct := rec.Header().Get("Content-Type"); ct != "application/problem+json" { t.Fatalf("Content-Type %q", ct) }
+		if rec.Code == http.StatusUnprocessableEntity {
+			if fields, _ := resp["fields"].([]any); len(fields) == 0 { t.Fatalf("422 without field errors: %s", rec.Body) }
+		}
+	})
+}
@@ -0,0 +1,335 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"app/internal/auth"
+	"app/internal/prewrite"
+	"app/internal/requestid"
+	"app/internal/store"
+	"app/internal/validate"
+)
+
+// POST /graphql  {"query": "...", "variables": {...}, "operationName": "..."}
+//
+// The schema mirrors the REST resource over the same store:
+//
+//	query    names(filter: NameFilter, limit: Int = 50, offset: Int = 0): [Name!]!
+//	query    name(id: ID!): Name
+//	mutation createName(input: NameInput!): Name!
+//	mutation updateName(id: ID!, version: Int!, input: NameInput!): Name!   (PUT semantics)
+//	mutation deleteName(id: ID!, version: Int!, hard: Boolean = false): Boolean!
+//
+// version is required as If-Match is for PUT and DELETE: the mutation only
+// applies to the name at that version, and is a version_mismatch otherwise.
+//
+// As usual for GraphQL, the answer is a 200 with "data" and "errors"; each
+// error carries extensions.code (and extensions.fields for validation).
+func (h *Handlers) GraphQL(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Query         string         `json:"query"`
+		Variables     map[string]any `json:"variables"`
+		OperationName string         `json:"operationName"`
+		Extensions    map[string]any `json:"extensions"` // sent by some clients; ignored
+	}
+	if !decodeJSON(w, r.Body, &req) { return }
+	if strings.TrimSpace(req.Query) == "" {
+		Unprocessable(w, []FieldError{{Field: "query", Message: "is required"}}); return
+	}
+
+	ctx, cancel := requestCtx(r, 10*time.Second)
+	defer cancel()
+	ok(w, graphql.Do(graphql.Params{
+		Schema:         h.schema,
+		RequestString:  req.Query,
+		VariableValues: req.Variables,
+		OperationName:  req.OperationName,
+		Context:        ctx,
+	}))
+}
+
+// gqlError is a resolver error with a stable code in its extensions.
+type gqlError struct {
+	msg string
+	ext map[string]any
+}
+
+func (e gqlError) Error() string              { return e.msg }
+func (e gqlError) Extensions() map[string]any { return e.ext }
+
+func gqlInvalid(errs []FieldError) error {
+	return gqlError{"validation failed", map[string]any{"code": CodeValidationFailed, "fields": errs}}
+}
+
+// gqlStoreError maps the store's sentinel errors; anything else is logged
+// and reported without detail.
+func gqlStoreError(ctx context.Context, err error) error {
+	var rej *prewrite.Rejected
+	switch {
+	case errors.As(err, &rej):
+		return gqlInvalid(rej.Fields)
+	case errors.Is(err, store.ErrNotFound):
+		return gqlError{"not found", map[string]any{"code": CodeNotFound}}
+	case errors.Is(err, store.ErrDuplicate):
+		return gqlError{"name already exists", map[string]any{"code": CodeDuplicateName}}
+	case errors.Is(err, store.ErrVersionMismatch):
+		return gqlError{"name was modified since it was read", map[string]any{"code": CodeVersionMismatch}}
+	case errors.Is(err, store.ErrHasNotes):
+		return gqlError{hasNotesDetail, map[string]any{"code": CodeNameHasNotes}}
+	case errors.Is(err, store.ErrNotOwner):
+		return gqlError{notOwnerDetail, map[string]any{"code": CodeNotOwner}}
+	}
+	slog.ErrorContext(ctx, "internal error", "err", err)
+	return gqlError{internalDetail, map[string]any{"code": CodeInternal, "request_id": requestid.FromContext(ctx)}}
+}
+
+// gqlVersion reads the version a mutation is conditional on.
+func gqlVersion(v any) (int64, error) {
+	n, _ := v.(int)
+	if n < 1 { return 0, gqlInvalid([]FieldError{{Field: "version", Message: "must be a positive integer"}}) }
+	return int64(n), nil
+}
+
+func gqlID(v any) (primitive.ObjectID, error) {
+	s, _ := v.(string)
+	oid, err := primitive.ObjectIDFromHex(s)
+	if err != nil { return oid, gqlError{"invalid id", map[string]any{"code": CodeBadRequest}} }
+	return oid, nil
+}
+
+// gqlNameInput turns a NameInput into a validated Name.
+func gqlNameInput(v any) (store.Name, error) {
+	in, _ := v.(map[string]any)
+	var n store.Name
+	n.Name, _ = in["name"].(string)
+	if tags, isList := in["tags"].([]any); isList {
+		n.Tags = make([]string, len(tags))
+		for i, t := range tags { n.Tags[i], _ = t.(string) }
+	}
+	if m := in["metadata"]; m != nil {
+		obj, isObj := m.(map[string]any)
+		if !isObj { return n, gqlInvalid([]FieldError{{Field: "metadata", Message: "must be an object"}}) }
+		n.Metadata = obj
+	}
+	if errs := validate.Name(&n); errs != nil { return n, gqlInvalid(errs) }
+	return n, nil
+}
+
+// ---- schema ----
+
+// jsonScalar carries metadata objects through unchanged.
+var jsonScalar = graphql.NewScalar(graphql.ScalarConfig{
+	Name:         "JSON",
+	Description:  "Any JSON value.",
+	Serialize:    func(v any) any { return v },
+	ParseValue:   func(v any) any { return v },
+	ParseLiteral: jsonLiteral,
+})
+
+// jsonLiteral converts an inline literal to what encoding/json would have
+// produced for the same value, so variables and literals behave alike.
+func jsonLiteral(v ast.Value) any {
+	switch v := v.(type) {
+	case *ast.ObjectValue:
+		m := make(map[string]any, len(v.Fields))
+		for _, f := range v.Fields { m[f.Name.Value] = jsonLiteral(f.Value) }
+		return m
+	case *ast.ListValue:
+		l := make([]any, len(v.Values))
+		for i, x := range v.Values { l[i] = jsonLiteral(x) }
+		return l
+	case *ast.IntValue:
+		f, _ := strconv.ParseFloat(v.Value, 64)
+		return f
+	case *ast.FloatValue:
+		f, _ := strconv.ParseFloat(v.Value, 64)
+		return f
+	case *ast.StringValue:
+		return v.Value
+	case *ast.BooleanValue:
+		return v.Value
+	}
+	return nil
+}
+
+func (h *Handlers) graphqlSchema() graphql.Schema {
+	nameType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Name",
+		Fields: graphql.Fields{
+			"id":   {Type: graphql.NewNonNull(graphql.ID), Resolve: func(p graphql.ResolveParams) (any, error) { return p.Source.(store.Name).ID.Hex(), nil }},
+			"name": {Type: graphql.NewNonNull(graphql.String)},
+			"tags": {Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(graphql.String))), Resolve: func(p graphql.ResolveParams) (any, error) {
+				if tags := p.Source.(store.Name).Tags; tags != nil { return tags, nil }
+				return []string{}, nil
+			}},
+			"metadata":  {Type: jsonScalar},
+			"version":   {Type: graphql.NewNonNull(graphql.Int)},
+			"createdAt": {Type: graphql.DateTime, Resolve: func(p graphql.ResolveParams) (any, error) { return optionalTime(p.Source.(store.Name).CreatedAt), nil }},
+			"updatedAt": {Type: graphql.DateTime, Resolve: func(p graphql.ResolveParams) (any, error) { return optionalTime(p.Source.(store.Name).UpdatedAt), nil }},
+			"deletedAt": {Type: graphql.DateTime, Resolve: func(p graphql.ResolveParams) (any, error) {
+				if d := p.Source.(store.Name).DeletedAt; d != nil { return *d, nil }
+				return nil, nil
+			}},
+		},
+	})
+	nameInput := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name: "NameInput",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"name":     {Type: graphql.NewNonNull(graphql.String)},
+			"tags":     {Type: graphql.NewList(graphql.NewNonNull(graphql.String))},
+			"metadata": {Type: jsonScalar},
+		},
+	})
+	nameFilter := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name: "NameFilter",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"namePrefix":     {Type: graphql.String},
+			"includeDeleted": {Type: graphql.Boolean},
+			"onlyDeleted":    {Type: graphql.Boolean},
+		},
+	})
+
+	query := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"names": {
+				Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(nameType))),
+				Args: graphql.FieldConfigArgument{
+					"filter": {Type: nameFilter},
+					"limit":  {Type: graphql.Int, DefaultValue: defaultPageSize},
+					"offset": {Type: graphql.Int, DefaultValue: 0},
+				},
+				Resolve: h.gqlNames,
+			},
+			"name": {
+				Type:    nameType,
+				Args:    graphql.FieldConfigArgument{"id": {Type: graphql.NewNonNull(graphql.ID)}},
+				Resolve: h.gqlName,
+			},
+		},
+	})
+	mutation := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Mutation",
+		Fields: graphql.Fields{
+			"createName": {
+				Type:    graphql.NewNonNull(nameType),
+				Args:    graphql.FieldConfigArgument{"input": {Type: graphql.NewNonNull(nameInput)}},
+				Resolve: gqlWrite(h.gqlCreateName),
+			},
+			"updateName": {
+				Type: graphql.NewNonNull(nameType),
+				Args: graphql.FieldConfigArgument{
+					"id":      {Type: graphql.NewNonNull(graphql.ID)},
+					"version": {Type: graphql.NewNonNull(graphql.Int)},
+					"input":   {Type: graphql.NewNonNull(nameInput)},
+				},
+				Resolve: gqlWrite(h.gqlUpdateName),
+			},
+			"deleteName": {
+				Type: graphql.NewNonNull(graphql.Boolean),
+				Args: graphql.FieldConfigArgument{
+					"id":      {Type: graphql.NewNonNull(graphql.ID)},
+					"version": {Type: graphql.NewNonNull(graphql.Int)},
+					"hard":    {Type: graphql.Boolean, DefaultValue: false},
+				},
+				Resolve: gqlWrite(h.gqlDeleteName),
+			},
+		},
+	})
+
+	schema, err := graphql.NewSchema(graphql.SchemaConfig{Query: query, Mutation: mutation})
+	if err != nil { panic("graphql schema: " + err.Error()) }
+	return schema
+}
+
+func optionalTime(t time.Time) any {
+	if t.IsZero() { return nil }
+	return t
+}
+
+// ---- resolvers ----
+
+func (h *Handlers) gqlNames(p graphql.ResolveParams) (any, error) {
+	limit, _ := p.Args["limit"].(int)
+	offset, _ := p.Args["offset"].(int)
+	var errs []FieldError
+	if limit < 1 || limit > maxPageSize {
+		errs = append(errs, FieldError{Field: "limit", Message: "must be an integer between 1 and " + strconv.Itoa(maxPageSize)})
+	}
+	if offset < 0 { errs = append(errs, FieldError{Field: "offset", Message: "must be a non-negative integer"}) }
+	if errs != nil { return nil, gqlInvalid(errs) }
+
+	opts := store.ListOptions{Limit: int64(limit), Offset: int64(offset), SortBy: "created_at"}
+	if f, isObj := p.Args["filter"].(map[string]any); isObj {
+		opts.NamePrefix, _ = f["namePrefix"].(string)
+		opts.IncludeDeleted, _ = f["includeDeleted"].(bool)
+		opts.OnlyDeleted, _ = f["onlyDeleted"].(bool)
+	}
+	page, err := h.names.List(p.Context, opts)
+	if err != nil { return nil, gqlStoreError(p.Context, err) }
+	return page.Items, nil
+}
+
+// name(id) is null rather than an error when nothing matches.
+func (h *Handlers) gqlName(p graphql.ResolveParams) (any, error) {
+	oid, err := gqlID(p.Args["id"])
+	if err != nil { return nil, err }
+	n, err := h.names.Get(p.Context, oid)
+	if errors.Is(err, store.ErrNotFound) { return nil, nil }
+	if err != nil { return nil, gqlStoreError(p.Context, err) }
+	return n, nil
+}
+
+func (h *Handlers) gqlCreateName(p graphql.ResolveParams) (any, error) {
+	n, err := gqlNameInput(p.Args["input"])
+	if err != nil { return nil, err }
+	if err := h.names.Create(p.Context, &n); err != nil { return nil, gqlStoreError(p.Context, err) }
+	return n, nil
+}
+
+func (h *Handlers) gqlUpdateName(p graphql.ResolveParams) (any, error) {
+	oid, err := gqlID(p.Args["id"])
+	if err != nil { return nil, err }
+	version, err := gqlVersion(p.Args["version"])
+	if err != nil { return nil, err }
+	payload, err := gqlNameInput(p.Args["input"])
+	if err != nil { return nil, err }
+	n, err := h.names.Update(p.Context, oid, payload, version)
+	if err != nil { return nil, gqlStoreError(p.Context, err) }
+	return n, nil
+}
+
+// gqlWrite guards a mutation: it needs the names:write scope, which viewers
+// and read-only API keys lack.
+func gqlWrite(resolve graphql.FieldResolveFn) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (any, error) {
+		if !auth.HasScope(p.Context, auth.ScopeWrite) {
+			return nil, gqlError{"caller lacks the " + auth.ScopeWrite + " scope", map[string]any{"code": CodeForbidden}}
+		}
+		return resolve(p)
+	}
+}
+
+func (h *Handlers) gqlDeleteName(p graphql.ResolveParams) (any, error) {
+	oid, err := gqlID(p.Args["id"])
+	if err != nil { return nil, err }
+	version, err := gqlVersion(p.Args["version"])
+	if err != nil { return nil, err }
+	del := h.names.SoftDelete
+	if hard, _ := p.Args["hard"].(bool); hard {
+		if !h.allowHardDelete { return nil, gqlError{"hard delete is disabled", map[string]any{"code": CodeForbidden}} }
+		del = h.names.HardDelete
+	}
+	if err := del(p.Context, oid, version); err != nil { return nil, gqlStoreError(p.Context, err) }
+	return true, nil
+}
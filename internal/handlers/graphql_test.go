@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"app/internal/store"
+)
+
+func graphqlDo(t *testing.T, h *Handlers, query string, vars map[string]any) map[string]any {
+	t.Helper()
+	body, _ := json.Marshal(map[string]any{"query": query, "variables": vars})
+	rec := httptest.NewRecorder()
+	h.GraphQL(rec, httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(string(body))))
+	if rec.Code != http.StatusOK { t.Fatalf("status %d: %s", rec.Code, rec.Body) }
+	var resp map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil { t.Fatal(err) }
+	return resp
+}
+
+func errorCode(resp map[string]any) string {
+	errs, _ := resp["errors"].([]any)
+	if len(errs) == 0 { return "" }
+	ext, _ := errs[0].(map[string]any)["extensions"].(map[string]any)
+	code, _ := ext["code"].(string)
+	return code
+}
+
+func TestGraphQL(t *testing.T) {
+	h := New(Deps{Names: store.NewMemoryNames()})
+
+	resp := graphqlDo(t, h, `mutation($in: NameInput!) { createName(input: $in) { id name tags metadata } }`,
+		map[string]any{"in": map[string]any{"name": " Alice ", "tags": []string{"vip"}, "metadata": map[string]any{"team": "core"}}})
+	created, _ := resp["data"].(map[string]any)["createName"].(map[string]any)
+	if created == nil || created["name"] != "Alice" || created["metadata"].(map[string]any)["team"] != "core" {
+		t.Fatalf("createName: %v", resp)
+	}
+
+	resp = graphqlDo(t, h, `mutation { createName(input: {name: "Alice", metadata: {n: 1}}) { id } }`, nil)
+	if code := errorCode(resp); code != "duplicate_name" { t.Fatalf("duplicate: code %q in %v", code, resp) }
+
+	resp = graphqlDo(t, h, `mutation { createName(input: {name: " ", tags: [""]}) { id } }`, nil)
+	if code := errorCode(resp); code != "validation_failed" { t.Fatalf("invalid: code %q in %v", code, resp) }
+
+	resp = graphqlDo(t, h, `{ names(filter: {namePrefix: "Al"}, limit: 10) { name } }`, nil)
+	if names := resp["data"].(map[string]any)["names"].([]any); len(names) != 1 { t.Fatalf("names: %v", resp) }
+
+	resp = graphqlDo(t, h, `{ names(limit: 0) { name } }`, nil)
+	if code := errorCode(resp); code != "validation_failed" { t.Fatalf("limit 0: code %q in %v", code, resp) }
+
+	resp = graphqlDo(t, h, `query($id: ID!) { name(id: $id) { name } }`, map[string]any{"id": created["id"]})
+	if got := resp["data"].(map[string]any)["name"].(map[string]any); got["name"] != "Alice" { t.Fatalf("name: %v", resp) }
+
+	resp = graphqlDo(t, h, `query($id: ID!) { name(id: $id) { name } }`, map[string]any{"id": primitive.NewObjectID().Hex()})
+	if resp["data"].(map[string]any)["name"] != nil || resp["errors"] != nil { t.Fatalf("missing name should be null: %v", resp) }
+
+	update := `mutation($id: ID!, $v: Int!) { updateName(id: $id, version: $v, input: {name: "Alice", tags: ["core"]}) { tags version } }`
+	resp = graphqlDo(t, h, update, map[string]any{"id": created["id"], "v": 2})
+	if code := errorCode(resp); code != "version_mismatch" { t.Fatalf("update at another version: code %q in %v", code, resp) }
+	resp = graphqlDo(t, h, `mutation($id: ID!) { updateName(id: $id, input: {name: "Alice"}) { version } }`, map[string]any{"id": created["id"]})
+	if resp["errors"] == nil { t.Fatalf("update without a version: %v", resp) }
+	resp = graphqlDo(t, h, update, map[string]any{"id": created["id"], "v": 1})
+	if got := resp["data"].(map[string]any)["updateName"].(map[string]any); got["version"] != 2.0 { t.Fatalf("updateName: %v", resp) }
+
+	resp = graphqlDo(t, h, `mutation($id: ID!) { deleteName(id: $id, version: 2, hard: true) }`, map[string]any{"id": created["id"]})
+	if code := errorCode(resp); code != "forbidden" { t.Fatalf("hard delete: code %q in %v", code, resp) }
+	resp = graphqlDo(t, h, `mutation($id: ID!) { deleteName(id: $id, version: 1) }`, map[string]any{"id": created["id"]})
+	if code := errorCode(resp); code != "version_mismatch" { t.Fatalf("delete at another version: code %q in %v", code, resp) }
+	resp = graphqlDo(t, h, `mutation($id: ID!) { deleteName(id: $id, version: 2) }`, map[string]any{"id": created["id"]})
+	if resp["data"].(map[string]any)["deleteName"] != true { t.Fatalf("deleteName: %v", resp) }
+}
@@ -0,0 +1,497 @@
+// Package handlers implements the HTTP endpoints on top of the store
+// interfaces. Routing and cross-cutting middleware live in package server.
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/graphql-go/graphql"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"app/internal/auth"
+	"app/internal/backup"
+	"app/internal/changes"
+	"app/internal/jobs"
+	"app/internal/leader"
+	"app/internal/normalize"
+	"app/internal/resource"
+	"app/internal/store"
+	"app/internal/shadow"
+	"app/internal/usage"
+	"app/internal/validate"
+)
+
+// PoolStatter reports connection pool statistics for GET /debug/pool and
+// GET /readyz.
+type PoolStatter interface {
+	PoolStats() store.PoolStats
+}
+
+// Deps is everything the handlers need; nil stores aren't allowed.
+type Deps struct {
+	Names    store.NameStore
+	Tx       store.Transactor // optional: POST /names/transaction answers 501 without one
+	Users    store.UserStore
+	APIKeys  store.APIKeyStore
+	Audit    store.AuditStore
+	History  store.HistoryStore
+	Stats    store.StatsStore
+	Dups     store.DuplicateStore
+	Sample   store.SampleStore
+	NameKeys store.NameKeyStore // optional: without one, /names/{id} takes only ObjectIDs
+	Notes    store.NoteStore
+	Docs     store.DocStore
+	// Revisions is optional: without it, GET /names has to list the names to
+	// tell whether the client's copy is current.
+	Revisions store.RevisionStore
+	Jobs     *jobs.Pool // optional: without one, Prefer: respond-async is ignored
+	Webhooks store.WebhookStore
+	Captures store.CaptureStore // optional: GET /admin/captures/{request_id} answers 404 without one
+	Usage    *usage.Tracker     // optional: GET /usage answers 404 without one
+	Shadow   *shadow.Names      // optional: POST /admin/shadow/check answers 404 without one
+	Changes  *changes.Names     // optional: GET /names/changes answers 404 without one
+	Tokens   *auth.Tokens
+	Pool     PoolStatter       // optional: GET /debug/pool answers 404 without one
+	Checks   map[string]Pinger // what GET /readyz pings, by name
+
+	// Resources are served by Docs at /{resource}; nil declares none.
+	Resources *resource.Registry
+	// Backups are where POST /admin/backup and /admin/restore keep dumps;
+	// both answer 404 without.
+	Backups backup.Target
+	// Normalize puts names in their canonical form; POST /admin/normalize
+	// answers 404 without it.
+	Normalize *normalize.Names
+	// Leader elects the instance running the background work; GET
+	// /admin/leader answers 404 without it.
+	Leader *leader.Elector
+
+	AllowHardDelete bool   // DELETE /names/{id}?hard=true
+	AllowSeed       bool   // POST /admin/seed, outside production
+	ImportMaxBytes  int64  // cap on POST /names/import bodies
+	ListConsistency string // of lists and searches without ?consistency=: store.Strong (if empty) or store.Eventual
+}
+
+type Handlers struct {
+	names    store.NameStore
+	tx       store.Transactor
+	users    store.UserStore
+	apiKeys  store.APIKeyStore
+	audit    store.AuditStore
+	history  store.HistoryStore
+	stats    store.StatsStore
+	dups     store.DuplicateStore
+	sample   store.SampleStore
+	nameKeys store.NameKeyStore
+	notes    store.NoteStore
+	docs     store.DocStore
+	revs     store.RevisionStore
+	jobs     *jobs.Pool
+	webhooks store.WebhookStore
+	captures store.CaptureStore
+	usage    *usage.Tracker
+	shadow   *shadow.Names
+	changes  *changes.Names
+	tokens   *auth.Tokens
+	pool     PoolStatter
+	checks   map[string]Pinger
+
+	resources *resource.Registry
+	backups   backup.Target
+	normalize *normalize.Names
+	leader    *leader.Elector
+
+	allowHardDelete bool
+	allowSeed       bool
+	importMaxBytes  int64
+	listConsistency string
+
+	schema graphql.Schema // POST /graphql
+}
+
+func New(d Deps) *Handlers {
+	h := &Handlers{
+		names: d.Names, tx: d.Tx, users: d.Users, apiKeys: d.APIKeys, audit: d.Audit, history: d.History, stats: d.Stats, dups: d.Dups, sample: d.Sample, nameKeys: d.NameKeys, notes: d.Notes, docs: d.Docs, revs: d.Revisions, jobs: d.Jobs, webhooks: d.Webhooks, captures: d.Captures, usage: d.Usage, shadow: d.Shadow, changes: d.Changes, tokens: d.Tokens, pool: d.Pool, checks: d.Checks, resources: d.Resources, backups: d.Backups, normalize: d.Normalize, leader: d.Leader,
+		allowHardDelete: d.AllowHardDelete, allowSeed: d.AllowSeed, importMaxBytes: d.ImportMaxBytes, listConsistency: d.ListConsistency,
+	}
+	if h.listConsistency == "" { h.listConsistency = store.Strong }
+	h.schema = h.graphqlSchema()
+	if h.jobs != nil { h.registerJobs() }
+	return h
+}
+
+// consistency is c, the ?consistency= of a list or search, or
+// LIST_CONSISTENCY's if it has none.
+func (h *Handlers) consistency(c string) string {
+	if c == "" { return h.listConsistency }
+	return c
+}
+
+// requestCtx derives the context for a database call from the request's, so
+// the call is abandoned when the client disconnects or the request deadline
+// passes, whichever comes before d.
+func requestCtx(r *http.Request, d time.Duration) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(r.Context(), d)
+}
+
+// ========== Handlers ==========
+
+// POST /names  { "name": "Alice", "tags": ["vip"], "metadata": {"team": "core"} }
+// With ?if_absent=true, a name that is taken answers 200 with its document.
+func (h *Handlers) CreateName(w http.ResponseWriter, r *http.Request) {
+	payload, valid := decodeName(w, r.Body)
+	if !valid { return }
+
+	ctx, cancel := requestCtx(r, 5*time.Second)
+	defer cancel()
+	n := store.Name{Name: payload.Name, Tags: payload.Tags, Metadata: payload.Metadata, ExpiresAt: payload.ExpiresAt}
+	if r.URL.Query().Get("if_absent") == "true" { h.createIfAbsent(ctx, w, n); return }
+	if err := h.names.Create(ctx, &n); err != nil {
+		if errors.Is(err, store.ErrDuplicate) { duplicateName(w); return }
+		if rejected(w, err) { return }
+		Internal(w, err); return
+	}
+	setETag(w, n)
+	created(w, n)
+}
+
+// createIfAbsent is POST /names?if_absent=true: the name as it was already
+// stored, with a 200, rather than a 409. One in the trash is still a 409.
+func (h *Handlers) createIfAbsent(ctx context.Context, w http.ResponseWriter, n store.Name) {
+	isNew, err := h.names.CreateIfAbsent(ctx, &n)
+	if errors.Is(err, store.ErrDuplicate) || err == nil && n.DeletedAt != nil { duplicateName(w); return }
+	if rejected(w, err) { return }
+	if err != nil { Internal(w, err); return }
+	setETag(w, n)
+	if isNew { created(w, n); return }
+	ok(w, n)
+}
+
+// GET /names?limit=&offset=|after=&sort=&name=&includeDeleted=  -> {"items", "total", "next"}, with a weak ETag
+// and Last-Modified that change with every write to the tenant's names; 304 if the client's copy is current.
+// The pages next to it are in the Link header.
+// GET /names?...&fields=name,created_at  -> the same, each item with only id and those fields
+// GET /names?...&mine=true  -> only the names the caller created
+// GET /names?...&consistency=eventual  -> the same, perhaps from a secondary a little behind; the
+// ETag is then of the body rather than the revision
+// GET /names?ids=a,b,c  -> see BatchGet
+// GET /names?stream=true&sort=&name=&includeDeleted=  -> every matching name as one JSON array
+// GET /names with Accept: application/x-ndjson  -> every matching name, one per line
+//
+// The last two ignore paging and stream the names as the store yields
+// them, like GET /names/export, so memory stays flat however many match.
+func (h *Handlers) ListNames(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Has("ids") { h.BatchGet(w, r); return }
+	w.Header().Add("Vary", "Accept")
+	opts, errs := parseListQuery(r.URL.Query())
+	fields, ferrs := parseFields(r.URL.Query())
+	if errs = append(errs, ferrs...); errs != nil { Unprocessable(w, errs); return }
+	opts.Fields = fields
+	opts.Consistency = h.consistency(opts.Consistency)
+	if r.URL.Query().Get("mine") == "true" {
+		uid := auth.UserIDFromContext(r.Context())
+		if uid.IsZero() { Unprocessable(w, []FieldError{{Field: "mine", Message: "needs a signed-in user"}}); return }
+		opts.CreatedBy = uid.Hex()
+	}
+	if format := StreamFormat(r); format != "" { h.streamNames(w, r, opts, format); return }
+
+	ctx, cancel := requestCtx(r, 10*time.Second)
+	defer cancel()
+	// An eventual listing may be behind the revision, which would then tag
+	// it for good; it is tagged by its body instead.
+	byRevision := h.revs != nil && opts.Consistency != store.Eventual
+	if byRevision {
+		// Read before listing: a write landing in between changes the
+		// revision, so the client comes back for it, rather than the
+		// listing going stale under the revision it already has.
+		rev, err := h.revs.Revision(ctx)
+		if err != nil { Internal(w, err); return }
+		w.Header().Set("Cache-Control", cacheControl)
+		w.Header().Set("ETag", listETag(r, rev))
+		if unchanged(w, r, w.Header().Get("ETag"), rev.At) { return }
+	}
+	page, err := h.names.List(ctx, opts)
+	if err != nil { listFailed(w, err); return }
+	body, err := projectPage(page, fields)
+	if err != nil { Internal(w, err); return }
+	pageLinks(w, r, page.Next, opts.Offset, opts.Limit)
+	if byRevision { ok(w, body); return }
+	okCached(w, r, body)
+}
+
+// GET /names/trash?limit=&offset=|after=&sort=&name=  -> soft-deleted names, same envelope as GET /names
+func (h *Handlers) Trash(w http.ResponseWriter, r *http.Request) {
+	opts, errs := parseListQuery(r.URL.Query())
+	if errs != nil { Unprocessable(w, errs); return }
+	opts.OnlyDeleted, opts.Consistency = true, h.consistency(opts.Consistency)
+
+	ctx, cancel := requestCtx(r, 10*time.Second)
+	defer cancel()
+	page, err := h.names.List(ctx, opts)
+	if err != nil { listFailed(w, err); return }
+	pageLinks(w, r, page.Next, opts.Offset, opts.Limit)
+	ok(w, page)
+}
+
+// GET /names/{id}  -> the name, with its version as ETag and updated_at as Last-Modified; 304 if
+// If-None-Match has the version, or without it if If-Modified-Since isn't before updated_at
+// GET /names/{id}?fields=name,created_at  -> the same, with only id and those fields
+// GET /names/{id}?expand=notes  -> the name with "notes", oldest first, and a weak ETag of the whole
+func (h *Handlers) GetName(w http.ResponseWriter, r *http.Request) {
+	oid, valid := h.nameID(w, r)
+	if !valid { return }
+	fields, errs := parseFields(r.URL.Query())
+	if errs != nil { Unprocessable(w, errs); return }
+	switch r.URL.Query().Get("expand") {
+	case "":
+	case "notes":
+		if fields != nil { Unprocessable(w, []FieldError{{Field: "fields", Message: "cannot be combined with expand"}}); return }
+		h.nameWithNotes(w, r, oid); return
+	default:
+		Unprocessable(w, []FieldError{{Field: "expand", Message: "must be notes"}}); return
+	}
+
+	ctx, cancel := requestCtx(r, 5*time.Second)
+	defer cancel()
+	n, err := h.names.Get(ctx, oid)
+	if errors.Is(err, store.ErrNotFound) { NotFound(w); return }
+	if err != nil { Internal(w, err); return }
+	setETag(w, n)
+	w.Header().Set("Cache-Control", cacheControl)
+	if unchanged(w, r, w.Header().Get("ETag"), n.UpdatedAt) { return }
+	body, err := project(n, fields)
+	if err != nil { Internal(w, err); return }
+	ok(w, body)
+}
+
+// PUT /names/{id}  { "name": "Bob", "tags": [...], "metadata": {...} }  (omitted tags/metadata are cleared)
+// If-Match: "<version>" is required; 412 if the name has changed since.
+func (h *Handlers) UpdateName(w http.ResponseWriter, r *http.Request) {
+	oid, valid := h.nameID(w, r)
+	if !valid { return }
+	version, valid := ifMatch(w, r)
+	if !valid { return }
+
+	payload, valid := decodeName(w, r.Body)
+	if !valid { return }
+
+	ctx, cancel := requestCtx(r, 5*time.Second)
+	defer cancel()
+	n, err := h.names.Update(ctx, oid, payload, version)
+	if errors.Is(err, store.ErrNotFound) { NotFound(w); return }
+	if errors.Is(err, store.ErrVersionMismatch) { preconditionFailed(w); return }
+	if errors.Is(err, store.ErrDuplicate) { duplicateName(w); return }
+	if errors.Is(err, store.ErrNotOwner) { notOwner(w); return }
+	if rejected(w, err) { return }
+	if err != nil { Internal(w, err); return }
+	setETag(w, n)
+	ok(w, n)
+}
+
+// PUT /names/by-name/{name}  { "tags": [...], "metadata": {...} }  (omitted tags/metadata are cleared)
+// 201 if it created the name, 200 if it updated it; a name in the trash is
+// a 409. Updating takes If-Match, as PUT /names/{id} does; without it, or
+// with If-None-Match: *, the name is only created, and if it exists the
+// answer is a 428, or a 412 for If-None-Match.
+func (h *Handlers) UpsertName(w http.ResponseWriter, r *http.Request) {
+	createOnly := r.Header.Get("If-Match") == ""
+	version := store.AnyVersion
+	if !createOnly {
+		v, valid := ifMatch(w, r)
+		if !valid { return }
+		version = v
+	}
+	var payload store.Name
+	if !decodeJSON(w, r.Body, &payload) { return }
+	name := r.PathValue("name")
+	if payload.Name != "" && payload.Name != name {
+		Unprocessable(w, []FieldError{{Field: "name", Message: "must match the name in the path"}}); return
+	}
+	payload.Name = name
+	if errs := validate.Name(&payload); errs != nil { Unprocessable(w, errs); return }
+
+	ctx, cancel := requestCtx(r, 5*time.Second)
+	defer cancel()
+	n := store.Name{Name: payload.Name, Tags: payload.Tags, Metadata: payload.Metadata, ExpiresAt: payload.ExpiresAt}
+	if createOnly {
+		done, err := h.names.CreateIfAbsent(ctx, &n)
+		if errors.Is(err, store.ErrDuplicate) { duplicateName(w); return }
+		if rejected(w, err) { return }
+		if err != nil { Internal(w, err); return }
+		switch {
+		case done:
+			setETag(w, n)
+			created(w, n)
+		case n.DeletedAt != nil:
+			duplicateName(w)
+		case r.Header.Get("If-None-Match") == "*":
+			WriteProblem(w, http.StatusPreconditionFailed, CodeVersionMismatch, "name already exists", nil)
+		default:
+			preconditionRequired(w)
+		}
+		return
+	}
+	before, n, err := h.names.Upsert(ctx, n, version)
+	if errors.Is(err, store.ErrNotFound) || errors.Is(err, store.ErrVersionMismatch) { preconditionFailed(w); return }
+	if errors.Is(err, store.ErrDuplicate) { duplicateName(w); return }
+	if errors.Is(err, store.ErrNotOwner) { notOwner(w); return }
+	if rejected(w, err) { return }
+	if err != nil { Internal(w, err); return }
+	setETag(w, n)
+	if before == nil { created(w, n); return }
+	ok(w, n)
+}
+
+// PATCH /names/{id}  { "tags": ["vip"] }  -> only the fields present are changed
+// If-Match is required, as for PUT.
+func (h *Handlers) PatchName(w http.ResponseWriter, r *http.Request) {
+	oid, valid := h.nameID(w, r)
+	if !valid { return }
+	version, valid := ifMatch(w, r)
+	if !valid { return }
+
+	var p store.NamePatch
+	if !decodeJSON(w, r.Body, &p) { return }
+	if errs := validate.Patch(&p); errs != nil { Unprocessable(w, errs); return }
+
+	ctx, cancel := requestCtx(r, 5*time.Second)
+	defer cancel()
+	n, err := h.names.Patch(ctx, oid, p, version)
+	if errors.Is(err, store.ErrNotFound) { NotFound(w); return }
+	if errors.Is(err, store.ErrVersionMismatch) { preconditionFailed(w); return }
+	if errors.Is(err, store.ErrDuplicate) { duplicateName(w); return }
+	if errors.Is(err, store.ErrNotOwner) { notOwner(w); return }
+	if rejected(w, err) { return }
+	if err != nil { Internal(w, err); return }
+	setETag(w, n)
+	ok(w, n)
+}
+
+// DELETE /names/{id}            -> soft delete (sets deleted_at)
+// DELETE /names/{id}?hard=true  -> permanent removal, only if ALLOW_HARD_DELETE=true
+// If-Match is required, as for PUT.
+func (h *Handlers) DeleteName(w http.ResponseWriter, r *http.Request) {
+	oid, valid := h.nameID(w, r)
+	if !valid { return }
+	version, valid := ifMatch(w, r)
+	if !valid { return }
+
+	ctx, cancel := requestCtx(r, 5*time.Second)
+	defer cancel()
+
+	del := h.names.SoftDelete
+	if r.URL.Query().Get("hard") == "true" {
+		if !h.allowHardDelete { Forbidden(w, "hard delete is disabled"); return }
+		del = h.names.HardDelete
+	}
+	err := del(ctx, oid, version)
+	if errors.Is(err, store.ErrNotFound) { NotFound(w); return }
+	if errors.Is(err, store.ErrVersionMismatch) { preconditionFailed(w); return }
+	if errors.Is(err, store.ErrHasNotes) { hasNotes(w); return }
+	if errors.Is(err, store.ErrNotOwner) { notOwner(w); return }
+	if err != nil { Internal(w, err); return }
+	noContent(w)
+}
+
+// POST /names/{id}/restore -> undo a soft delete
+func (h *Handlers) RestoreName(w http.ResponseWriter, r *http.Request) {
+	oid, valid := h.nameID(w, r)
+	if !valid { return }
+
+	ctx, cancel := requestCtx(r, 5*time.Second)
+	defer cancel()
+	n, err := h.names.Restore(ctx, oid)
+	if errors.Is(err, store.ErrNotFound) { NotFound(w); return }
+	if errors.Is(err, store.ErrNotOwner) { notOwner(w); return }
+	if err != nil { Internal(w, err); return }
+	setETag(w, n)
+	ok(w, n)
+}
+
+// GET /names/{id}/events -> audit history, oldest first
+func (h *Handlers) NameEvents(w http.ResponseWriter, r *http.Request) {
+	oid, valid := h.nameID(w, r)
+	if !valid { return }
+
+	ctx, cancel := requestCtx(r, 10*time.Second)
+	defer cancel()
+	events, err := h.names.Events(ctx, oid)
+	if err != nil { Internal(w, err); return }
+	ok(w, events)
+}
+
+// GET /names/{id}/history -> {"items": [...]}, every version of the name, newest first, the current one included
+func (h *Handlers) NameHistory(w http.ResponseWriter, r *http.Request) {
+	oid, valid := h.nameID(w, r)
+	if !valid { return }
+
+	ctx, cancel := requestCtx(r, 10*time.Second)
+	defer cancel()
+	versions, err := h.versions(ctx, oid)
+	if err != nil { Internal(w, err); return }
+	if len(versions) == 0 { NotFound(w); return }
+	ok(w, map[string]any{"items": versions})
+}
+
+// POST /names/{id}/revert?version=N -> the name with the name, tags and metadata of version N, as a new version
+// If-Match is required, as for PUT. A soft-deleted name must be restored first.
+func (h *Handlers) RevertName(w http.ResponseWriter, r *http.Request) {
+	oid, valid := h.nameID(w, r)
+	if !valid { return }
+	target, err := strconv.ParseInt(r.URL.Query().Get("version"), 10, 64)
+	if err != nil || target < 1 { Unprocessable(w, []FieldError{{Field: "version", Message: "must be a positive integer"}}); return }
+	version, valid := ifMatch(w, r)
+	if !valid { return }
+
+	ctx, cancel := requestCtx(r, 5*time.Second)
+	defer cancel()
+	versions, err := h.versions(ctx, oid)
+	if err != nil { Internal(w, err); return }
+	i := slices.IndexFunc(versions, func(n store.Name) bool { return n.Version == target })
+	if i < 0 { WriteProblem(w, http.StatusNotFound, CodeNotFound, "the name has no version "+strconv.FormatInt(target, 10), nil); return }
+
+	old := versions[i]
+	n, err := h.names.Update(ctx, oid, store.Name{Name: old.Name, Tags: old.Tags, Metadata: old.Metadata}, version)
+	if errors.Is(err, store.ErrNotFound) { NotFound(w); return }
+	if errors.Is(err, store.ErrVersionMismatch) { preconditionFailed(w); return }
+	if errors.Is(err, store.ErrDuplicate) { duplicateName(w); return }
+	if errors.Is(err, store.ErrNotOwner) { notOwner(w); return }
+	if rejected(w, err) { return }
+	if err != nil { Internal(w, err); return }
+	setETag(w, n)
+	ok(w, n)
+}
+
+// versions returns the current version of name id, if it still exists,
+// followed by the kept ones.
+func (h *Handlers) versions(ctx context.Context, id primitive.ObjectID) ([]store.Name, error) {
+	current, err := h.names.Lookup(ctx, []primitive.ObjectID{id})
+	if err != nil { return nil, err }
+	kept, err := h.history.Versions(ctx, id)
+	if err != nil { return nil, err }
+	if n, exists := current[id]; exists { return append([]store.Name{n}, kept...), nil }
+	return kept, nil
+}
+
+func duplicateName(w http.ResponseWriter) { conflict(w, CodeDuplicateName, "name already exists") }
+
+// hasNotesDetail explains a hard delete refused under NOTES_ON_DELETE=block.
+const hasNotesDetail = "the name has notes; delete them first or set NOTES_ON_DELETE=cascade"
+
+func hasNotes(w http.ResponseWriter) { conflict(w, CodeNameHasNotes, hasNotesDetail) }
+
+// notOwnerDetail explains a write refused because the name is another user's.
+const notOwnerDetail = "only the user who created the name, or an admin, can change it"
+
+func notOwner(w http.ResponseWriter) { WriteProblem(w, http.StatusForbidden, CodeNotOwner, notOwnerDetail, nil) }
+
+// GET /debug/pool -> pool configuration and live counters
+func (h *Handlers) PoolStats(w http.ResponseWriter, r *http.Request) {
+	if h.pool == nil { NotFound(w); return }
+	ok(w, h.pool.PoolStats())
+}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Pinger is a dependency GET /readyz checks, such as the database.
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// readyTimeout bounds each dependency ping, well inside a probe's timeout.
+const readyTimeout = 2 * time.Second
+
+type checkResult struct {
+	Status    string `json:"status"` // "up" or "down"
+	LatencyMS int64  `json:"latency_ms"`
+	Error     string `json:"error,omitempty"`
+}
+
+// GET /healthz -> 200 while the process is serving; it checks nothing else,
+// so a database outage doesn't get the pod restarted.
+func (h *Handlers) Healthz(w http.ResponseWriter, r *http.Request) {
+	ok(w, map[string]string{"status": "ok"})
+}
+
+// GET /readyz -> 200 if every dependency answers a ping, else 503; either
+// way with each check's result and the connection pool stats.
+func (h *Handlers) Readyz(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), readyTimeout)
+	defer cancel()
+
+	var (
+		mu     sync.Mutex
+		wg     sync.WaitGroup
+		checks = make(map[string]checkResult, len(h.checks))
+		ready  = true
+	)
+	for name, p := range h.checks {
+		wg.Go(func() {
+			start := time.Now()
+			err := p.Ping(ctx)
+			res := checkResult{Status: "up", LatencyMS: time.Since(start).Milliseconds()}
+			if err != nil {
+				// Probes are unauthenticated; the reason stays in the log.
+				slog.Warn("readiness check failed", "check", name, "err", err)
+				res.Status, res.Error = "down", "ping failed"
+				if errors.Is(err, context.DeadlineExceeded) { res.Error = "timeout" }
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			checks[name] = res
+			if err != nil { ready = false }
+		})
+	}
+	wg.Wait()
+
+	body := map[string]any{"status": "ready", "checks": checks}
+	if h.pool != nil { body["pool"] = h.pool.PoolStats() }
+	if !ready {
+		body["status"] = "not_ready"
+		WriteJSON(w, http.StatusServiceUnavailable, body)
+		return
+	}
+	ok(w, body)
+}
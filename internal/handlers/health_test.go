@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"app/internal/store"
+)
+
+type pingFunc func(context.Context) error
+
+func (f pingFunc) Ping(ctx context.Context) error { return f(ctx) }
+
+func TestReadyz(t *testing.T) {
+	up := pingFunc(func(context.Context) error { return nil })
+	down := pingFunc(func(context.Context) error { return errors.New("connection refused") })
+
+	for _, tc := range []struct {
+		checks map[string]Pinger
+		status int
+		want   string
+	}{
+		{nil, http.StatusOK, "ready"},
+		{map[string]Pinger{"mongo": up}, http.StatusOK, "ready"},
+		{map[string]Pinger{"mongo": down, "cache": up}, http.StatusServiceUnavailable, "not_ready"},
+	} {
+		h := New(Deps{Names: store.NewMemoryNames(), Users: store.NewMemoryUsers(), Checks: tc.checks})
+		rec := httptest.NewRecorder()
+		h.Readyz(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+		var body struct {
+			Status string                 `json:"status"`
+			Checks map[string]checkResult `json:"checks"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil { t.Fatal(err) }
+		if rec.Code != tc.status || body.Status != tc.want || len(body.Checks) != len(tc.checks) {
+			t.Errorf("%d checks: got %d %s", len(tc.checks), rec.Code, rec.Body)
+		}
+		if c, ok := body.Checks["mongo"]; ok && (c.Status == "down") != (c.Error != "") {
+			t.Errorf("mongo check %+v", c)
+		}
+	}
+}
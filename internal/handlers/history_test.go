@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"app/internal/history"
+	"app/internal/store"
+)
+
+func TestRevert(t *testing.T) {
+	ctx := context.Background()
+	hist := store.NewMemoryHistory()
+	names := history.NewNames(store.NewMemoryNames(), hist)
+	n := store.Name{Name: "alice", Tags: []string{"vip"}}
+	if err := names.Create(ctx, &n); err != nil { t.Fatal(err) }
+	if _, err := names.Update(ctx, n.ID, store.Name{Name: "alicia"}, store.AnyVersion); err != nil { t.Fatal(err) }
+	h := New(Deps{Names: names, History: hist})
+
+	call := func(handler http.HandlerFunc, method, target, ifMatch string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, target, nil)
+		r.SetPathValue("id", n.ID.Hex())
+		if ifMatch != "" { r.Header.Set("If-Match", ifMatch) }
+		rec := httptest.NewRecorder()
+		handler(rec, r)
+		return rec
+	}
+
+	if rec := call(h.RevertName, http.MethodPost, "/names/x/revert?version=1", `"1"`); rec.Code != http.StatusPreconditionFailed { t.Fatalf("stale revert: %d", rec.Code) }
+	if rec := call(h.RevertName, http.MethodPost, "/names/x/revert?version=7", `"2"`); rec.Code != http.StatusNotFound { t.Fatalf("unknown version: %d", rec.Code) }
+	if rec := call(h.RevertName, http.MethodPost, "/names/x/revert", `"2"`); rec.Code != http.StatusUnprocessableEntity { t.Fatalf("no version: %d", rec.Code) }
+
+	rec := call(h.RevertName, http.MethodPost, "/names/x/revert?version=1", `"2"`)
+	var reverted store.Name
+	_ = json.Unmarshal(rec.Body.Bytes(), &reverted)
+	if rec.Code != http.StatusOK || reverted.Name != "alice" || reverted.Tags[0] != "vip" || reverted.Version != 3 || rec.Header().Get("ETag") != `"3"` {
+		t.Fatalf("revert: %d %s", rec.Code, rec.Body)
+	}
+
+	rec = call(h.NameHistory, http.MethodGet, "/names/x/history", "")
+	var body struct{ Items []store.Name }
+	_ = json.Unmarshal(rec.Body.Bytes(), &body)
+	if len(body.Items) != 3 || body.Items[0].Version != 3 || body.Items[1].Name != "alicia" || body.Items[2].Name != "alice" { t.Fatalf("history: %s", rec.Body) }
+}
@@ -0,0 +1,326 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"app/internal/prewrite"
+	"app/internal/store"
+	"app/internal/validate"
+)
+
+const (
+	importBatchSize   = 500
+	importMaxErrors   = 100     // row errors reported back; the rest are only counted
+	importMaxLineSize = 1 << 20 // longest NDJSON line
+)
+
+type importRowError struct {
+	Line  int    `json:"line"`
+	Error string `json:"error"`
+}
+
+// importSummary accounts for every row: Skipped ones duplicate a name that
+// exists or came earlier in the upload, Errored ones are invalid or failed
+// to insert. Errors lists the first importMaxErrors of both by line.
+type importSummary struct {
+	Inserted int              `json:"inserted"`
+	Skipped  int              `json:"skipped"`
+	Errored  int              `json:"errored"`
+	Errors   []importRowError `json:"errors"`
+}
+
+func (s *importSummary) reject(line int, msg string, skipped bool) {
+	if skipped { s.Skipped++ } else { s.Errored++ }
+	if len(s.Errors) < importMaxErrors { s.Errors = append(s.Errors, importRowError{line, msg}) }
+}
+
+// importRow is one record of an upload: the name it holds, or why it has none.
+type importRow struct {
+	line int
+	name store.Name
+	err  string
+}
+
+// importReader yields the rows of an upload. next returns io.EOF after the
+// last row, and any other error once the upload can't be read any further.
+type importReader interface {
+	next() (importRow, error)
+}
+
+// POST /names/import  (text/csv or application/x-ndjson body, or
+// multipart/form-data with a "file" part of either)
+//
+// CSV: the first column of each row is the name; ?header=true skips the
+// first row, and if that header names "tags" and "metadata" columns (as
+// GET /names/export?format=csv does) they are read too. NDJSON: one
+// {"name", "tags", "metadata"} object per line; other fields are ignored.
+//
+// Rows are parsed as they arrive and inserted in unordered batches, so the
+// upload is never held in memory and one bad row doesn't stop the rest.
+// The body is capped at IMPORT_MAX_BYTES. With Prefer: respond-async the
+// upload is read whole, up to jobMaxBytes, and imported by a job instead.
+func (h *Handlers) Import(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, h.importMaxBytes)
+	src, format, err := importSource(r)
+	if err != nil { BadRequest(w, err.Error()); return }
+	header := r.URL.Query().Get("header") == "true"
+
+	if h.async(r, jobImport) {
+		input, err := io.ReadAll(io.LimitReader(src, jobMaxBytes+1))
+		if err != nil { importFailed(w, err, 0); return }
+		if len(input) > jobMaxBytes { TooLarge(w, jobMaxBytes); return }
+		params := url.Values{"format": {format}, "header": {strconv.FormatBool(header)}}
+		h.enqueue(w, r, &store.Job{Type: jobImport, Params: params.Encode(), Input: input})
+		return
+	}
+
+	ctx, cancel := requestCtx(r, 5*time.Minute)
+	defer cancel()
+	sum, readErr, err := h.importRows(ctx, importRows(src, format, header))
+	switch {
+	case err != nil:
+		Internal(w, err)
+	case readErr != nil:
+		importFailed(w, readErr, sum.Inserted)
+	default:
+		ok(w, sum)
+	}
+}
+
+// importRows reads an upload in format.
+func importRows(src io.Reader, format string, header bool) importReader {
+	if format == "csv" { return newCSVImport(src, header) }
+	return newNDJSONImport(src)
+}
+
+// importJob is Import's work as a job: the summary is its Result, and an
+// upload that breaks off fails it, the rows before staying inserted.
+func (h *Handlers) importJob(ctx context.Context, j *store.Job) error {
+	params, err := url.ParseQuery(j.Params)
+	if err != nil { return err }
+	sum, readErr, err := h.importRows(ctx, importRows(bytes.NewReader(j.Input), params.Get("format"), params.Get("header") == "true"))
+	if err != nil { return err }
+	if readErr != nil { return fmt.Errorf("after %d names were inserted: %w", sum.Inserted, readErr) }
+	j.Result, err = json.Marshal(sum)
+	return err
+}
+
+// importRows inserts the rows of an upload. It stops at the first error
+// reading it, returned as readErr, or at the store's, returned as err; the
+// rows before stay inserted either way.
+func (h *Handlers) importRows(ctx context.Context, rows importReader) (sum importSummary, readErr, err error) {
+	sum.Errors = []importRowError{}
+	seen := map[string]bool{}
+	var batch []store.Name
+	var lines []int
+	flush := func() error {
+		if len(batch) == 0 { return nil }
+		// Names that already exist are reported as duplicates, not re-inserted.
+		names := make([]string, len(batch))
+		for i, n := range batch { names[i] = n.Name }
+		exists, err := h.names.ExistingNames(ctx, names)
+		if err != nil { return err }
+		var docs []store.Name
+		var docLines []int
+		for i, n := range batch {
+			if exists[n.Name] { sum.reject(lines[i], "duplicate name", true); continue }
+			docs, docLines = append(docs, n), append(docLines, lines[i])
+		}
+		errs, err := h.names.InsertMany(ctx, docs)
+		if err != nil { return err }
+		for i, err := range errs {
+			var rej *prewrite.Rejected
+			switch {
+			case err == nil:
+				sum.Inserted++
+			case errors.Is(err, store.ErrDuplicate): // created since ExistingNames
+				sum.reject(docLines[i], "duplicate name", true)
+			case errors.As(err, &rej):
+				sum.reject(docLines[i], rej.Fields[0].Field+" "+rej.Fields[0].Message, false)
+			default:
+				slog.ErrorContext(ctx, "internal error", "err", err)
+				sum.reject(docLines[i], internalDetail, false)
+			}
+		}
+		batch, lines = batch[:0], lines[:0]
+		return nil
+	}
+
+	for {
+		row, err := rows.next()
+		if errors.Is(err, io.EOF) { break }
+		if err != nil { return sum, err, flush() }
+		if row.err != "" { sum.reject(row.line, row.err, false); continue }
+
+		n := row.name
+		if errs := validate.Name(&n); errs != nil { sum.reject(row.line, errs[0].Field+" "+errs[0].Message, false); continue }
+		if seen[n.Name] { sum.reject(row.line, "duplicate name", true); continue }
+		seen[n.Name] = true
+
+		batch, lines = append(batch, n), append(lines, row.line)
+		if len(batch) == importBatchSize {
+			if err := flush(); err != nil { return sum, nil, err }
+		}
+	}
+	if err := flush(); err != nil { return sum, nil, err }
+	// Duplicates of stored names are only found at flush time, so sort.
+	slices.SortStableFunc(sum.Errors, func(a, b importRowError) int { return a.Line - b.Line })
+	return sum, nil, nil
+}
+
+// importFailed answers an upload that broke off; the rows before it stay
+// inserted.
+func importFailed(w http.ResponseWriter, err error, inserted int) {
+	var (
+		pe  *csv.ParseError
+		mbe *http.MaxBytesError
+		tle *lineTooLongError
+	)
+	switch {
+	case errors.As(err, &pe):
+		WriteProblem(w, http.StatusBadRequest, CodeMalformedCSV, "malformed CSV: "+pe.Err.Error(), map[string]any{"line": pe.Line, "inserted": inserted})
+	case errors.As(err, &mbe):
+		WriteProblem(w, http.StatusRequestEntityTooLarge, CodeBodyTooLarge, fmt.Sprintf("upload exceeds %d bytes", mbe.Limit), map[string]any{"limit_bytes": mbe.Limit, "inserted": inserted})
+	case errors.As(err, &tle):
+		WriteProblem(w, http.StatusBadRequest, CodeLineTooLong, fmt.Sprintf("line is longer than %d bytes", importMaxLineSize), map[string]any{"line": tle.line, "inserted": inserted})
+	default:
+		WriteProblem(w, http.StatusBadRequest, CodeBadRequest, "reading upload: "+err.Error(), map[string]any{"inserted": inserted})
+	}
+}
+
+// importSource returns a reader over the payload, either the raw body or
+// the "file" part of a multipart upload, and its format: "csv" or "ndjson".
+// A part's format comes from its Content-Type, else its file extension.
+func importSource(r *http.Request) (io.Reader, string, error) {
+	const want = "Content-Type must be text/csv, application/x-ndjson or multipart/form-data"
+	mt, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil { return nil, "", errors.New(want) }
+
+	if mt == "multipart/form-data" {
+		mr, err := r.MultipartReader()
+		if err != nil { return nil, "", err }
+		for {
+			part, err := mr.NextPart()
+			if errors.Is(err, io.EOF) { return nil, "", errors.New("multipart upload has no \"file\" part") }
+			if err != nil { return nil, "", err }
+			if part.FormName() != "file" { continue }
+			pt, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+			if format := importFormat(pt); format != "" { return part, format, nil }
+			switch strings.ToLower(path.Ext(part.FileName())) {
+			case ".ndjson", ".jsonl":
+				return part, "ndjson", nil
+			default:
+				return part, "csv", nil
+			}
+		}
+	}
+	if format := importFormat(mt); format != "" { return r.Body, format, nil }
+	return nil, "", errors.New(want)
+}
+
+func importFormat(mediaType string) string {
+	switch mediaType {
+	case "text/csv":
+		return "csv"
+	case "application/x-ndjson", "application/jsonl":
+		return "ndjson"
+	}
+	return ""
+}
+
+// ---- CSV ----
+
+type csvImport struct {
+	cr     *csv.Reader
+	header bool
+	// Columns of name, tags and metadata; -1 when absent.
+	name, tags, metadata int
+}
+
+func newCSVImport(src io.Reader, header bool) *csvImport {
+	cr := csv.NewReader(src)
+	cr.FieldsPerRecord = -1
+	return &csvImport{cr: cr, header: header, tags: -1, metadata: -1}
+}
+
+func (c *csvImport) next() (importRow, error) {
+	rec, err := c.cr.Read()
+	if err != nil { return importRow{}, err }
+	if c.header {
+		c.header = false
+		if i := slices.Index(rec, "name"); i >= 0 {
+			c.name, c.tags, c.metadata = i, slices.Index(rec, "tags"), slices.Index(rec, "metadata")
+		}
+		return c.next()
+	}
+
+	line, _ := c.cr.FieldPos(0)
+	row := importRow{line: line}
+	field := func(i int) string {
+		if i < 0 || i >= len(rec) { return "" }
+		return rec[i]
+	}
+	row.name.Name = field(c.name)
+	if tags := field(c.tags); tags != "" { row.name.Tags = strings.Split(tags, ";") }
+	if md := field(c.metadata); md != "" {
+		if err := json.Unmarshal([]byte(md), &row.name.Metadata); err != nil { row.err = "metadata is not a JSON object" }
+	}
+	return row, nil
+}
+
+// ---- NDJSON ----
+
+type lineTooLongError struct{ line int }
+
+func (e *lineTooLongError) Error() string { return fmt.Sprintf("line %d is too long", e.line) }
+
+type ndjsonImport struct {
+	sc   *bufio.Scanner
+	line int
+}
+
+func newNDJSONImport(src io.Reader) *ndjsonImport {
+	sc := bufio.NewScanner(src)
+	sc.Buffer(make([]byte, 0, 64<<10), importMaxLineSize)
+	return &ndjsonImport{sc: sc}
+}
+
+func (n *ndjsonImport) next() (importRow, error) {
+	for n.sc.Scan() {
+		n.line++
+		b := n.sc.Bytes()
+		if len(strings.TrimSpace(string(b))) == 0 { continue }
+
+		var v struct {
+			Name     string         `json:"name"`
+			Tags     []string       `json:"tags"`
+			Metadata map[string]any `json:"metadata"`
+		}
+		row := importRow{line: n.line}
+		if err := json.Unmarshal(b, &v); err != nil {
+			row.err = "invalid JSON: " + err.Error()
+			return row, nil
+		}
+		row.name = store.Name{Name: v.Name, Tags: v.Tags, Metadata: v.Metadata}
+		return row, nil
+	}
+	if errors.Is(n.sc.Err(), bufio.ErrTooLong) { return importRow{}, &lineTooLongError{n.line + 1} }
+	if err := n.sc.Err(); err != nil { return importRow{}, err }
+	return importRow{}, io.EOF
+}
@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"app/internal/store"
+)
+
+func TestImport(t *testing.T) {
+	names := store.NewMemoryNames()
+	if err := names.Create(context.Background(), &store.Name{Name: "taken"}); err != nil { t.Fatal(err) }
+	h := New(Deps{Names: names, ImportMaxBytes: 1 << 20})
+
+	post := func(contentType, query string, body *bytes.Buffer) (int, importSummary) {
+		r := httptest.NewRequest(http.MethodPost, "/names/import"+query, body)
+		r.Header.Set("Content-Type", contentType)
+		rec := httptest.NewRecorder()
+		h.Import(rec, r)
+		var sum importSummary
+		_ = json.Unmarshal(rec.Body.Bytes(), &sum)
+		return rec.Code, sum
+	}
+
+	csvBody := "name,tags,metadata\nalice,a;b,\"{\"\"team\"\":\"\"core\"\"}\"\ntaken,,\n,,\nalice,,\nbob,,not json\n"
+	code, sum := post("text/csv", "?header=true", bytes.NewBufferString(csvBody))
+	if code != http.StatusOK || sum.Inserted != 1 || sum.Skipped != 2 || sum.Errored != 2 { t.Fatalf("csv: %d %+v", code, sum) }
+	for i, e := range sum.Errors {
+		if e.Line != i+3 { t.Fatalf("error lines %+v", sum.Errors) }
+	}
+	if p, _ := names.List(context.Background(), store.ListOptions{Limit: 10, NamePrefix: "alice"}); len(p.Items) != 1 || p.Items[0].Tags[1] != "b" || p.Items[0].Metadata["team"] != "core" {
+		t.Fatalf("alice %+v", p.Items)
+	}
+
+	// NDJSON as a multipart file, recognised by its extension.
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	fw, _ := mw.CreateFormFile("file", "names.ndjson")
+	_, _ = fw.Write([]byte(`{"name":"carol","tags":["x"],"id":"ignored"}` + "\n\n" + `{"name":` + "\n" + `{"name":"alice"}` + "\n"))
+	_ = mw.Close()
+	code, sum = post(mw.FormDataContentType(), "", &body)
+	if code != http.StatusOK || sum.Inserted != 1 || sum.Skipped != 1 || sum.Errored != 1 { t.Fatalf("ndjson: %d %+v", code, sum) }
+	if sum.Errors[0].Line != 3 || !strings.HasPrefix(sum.Errors[0].Error, "invalid JSON") || sum.Errors[1].Line != 4 { t.Fatalf("ndjson errors %+v", sum.Errors) }
+
+	if code, _ = post("application/json", "", bytes.NewBufferString("{}")); code != http.StatusBadRequest { t.Fatalf("json body: %d", code) }
+}
@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"app/internal/auth"
+	"app/internal/requestid"
+	"app/internal/store"
+)
+
+// The types of the jobs the handlers run, and the funcs that run them.
+const (
+	jobImport      = "import"
+	jobExport      = "export"
+	jobShadowCheck = "shadow_check"
+	jobBackup      = "backup"
+	jobRestore     = "restore"
+	jobNormalize   = "normalize"
+)
+
+// jobMaxBytes caps what a job holds: an import's upload, an export's file.
+// It leaves room for the rest of the job in a 16 MiB MongoDB document.
+const jobMaxBytes = 15 << 20
+
+func (h *Handlers) registerJobs() {
+	h.jobs.Handle(jobImport, h.importJob)
+	h.jobs.Handle(jobExport, h.exportJob)
+	if h.shadow != nil { h.jobs.Handle(jobShadowCheck, h.shadowCheckJob) }
+	if h.backups != nil {
+		h.jobs.Handle(jobBackup, h.backupJob)
+		h.jobs.Handle(jobRestore, h.restoreJob)
+	}
+	if h.normalize != nil { h.jobs.Handle(jobNormalize, h.normalizeJob) }
+}
+
+// async reports whether r is to be answered with a job of type typ: the
+// client asked with Prefer: respond-async (RFC 7240) and jobs are run.
+func (h *Handlers) async(r *http.Request, typ string) bool {
+	if h.jobs == nil || !h.jobs.Handles(typ) { return false }
+	for _, v := range r.Header.Values("Prefer") {
+		for _, pref := range strings.Split(v, ",") {
+			token, _, _ := strings.Cut(pref, ";")
+			if strings.EqualFold(strings.TrimSpace(token), "respond-async") { return true }
+		}
+	}
+	return false
+}
+
+// enqueue queues j on behalf of r's caller and answers 202 with the job,
+// pointing at where to poll it.
+func (h *Handlers) enqueue(w http.ResponseWriter, r *http.Request, j *store.Job) {
+	if uid := auth.UserIDFromContext(r.Context()); !uid.IsZero() { j.Actor = uid.Hex() }
+	j.ActorRole, _ = auth.RoleFromContext(r.Context())
+	j.RequestID = requestid.FromContext(r.Context())
+
+	ctx, cancel := requestCtx(r, 5*time.Second)
+	defer cancel()
+	if err := h.jobs.EnqueueJob(ctx, j); err != nil { Internal(w, err); return }
+	w.Header().Set("Preference-Applied", "respond-async")
+	w.Header().Set("Location", "/api/v1/jobs/"+j.ID.Hex())
+	WriteJSON(w, http.StatusAccepted, j)
+}
+
+// GET /jobs/{id} -> the job; poll until its status is succeeded or failed
+func (h *Handlers) GetJob(w http.ResponseWriter, r *http.Request) {
+	if h.jobs == nil { NotFound(w); return }
+	oid, valid := pathID(w, r)
+	if !valid { return }
+
+	ctx, cancel := requestCtx(r, 5*time.Second)
+	defer cancel()
+	j, err := h.jobs.Job(ctx, oid)
+	if errors.Is(err, store.ErrNotFound) { NotFound(w); return }
+	if err != nil { Internal(w, err); return }
+	okCached(w, r, j)
+}
+
+// GET /jobs/{id}/output -> the file a succeeded job made, such as an export
+func (h *Handlers) JobOutput(w http.ResponseWriter, r *http.Request) {
+	if h.jobs == nil { NotFound(w); return }
+	oid, valid := pathID(w, r)
+	if !valid { return }
+
+	ctx, cancel := requestCtx(r, 30*time.Second)
+	defer cancel()
+	j, err := h.jobs.Job(ctx, oid)
+	if errors.Is(err, store.ErrNotFound) { NotFound(w); return }
+	if err != nil { Internal(w, err); return }
+	if j.Status != store.JobSucceeded { conflict(w, CodeJobNotDone, "the job is "+j.Status); return }
+	if j.Type != jobExport { WriteProblem(w, http.StatusNotFound, CodeNotFound, "the job has no output", nil); return }
+
+	out, err := h.jobs.JobOutput(ctx, oid)
+	if errors.Is(err, store.ErrNotFound) { NotFound(w); return }
+	if err != nil { Internal(w, err); return }
+	params, _ := url.ParseQuery(j.Params)
+	_, format, _ := parseExportQuery(params)
+	w.Header().Set("Content-Type", exportContentType(format))
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="names-%s.%s"`, j.FinishedAt.Format("20060102T150405Z"), format))
+	_, _ = w.Write(out)
+}
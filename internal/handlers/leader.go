@@ -0,0 +1,10 @@
+package handlers
+
+import "net/http"
+
+// GET /admin/leader -> whether the instance answering leads the background work,
+// the lease as it last saw it and the work the leader runs
+func (h *Handlers) Leader(w http.ResponseWriter, r *http.Request) {
+	if h.leader == nil { WriteProblem(w, http.StatusNotFound, CodeNotFound, "there is no leader election; see LEADER_ELECTION", nil); return }
+	ok(w, h.leader.Status())
+}
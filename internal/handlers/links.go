@@ -0,0 +1,26 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// pageLinks sets the Link header (RFC 8288) of a page of a list: next, the
+// same request resuming after the page's next cursor, if there is more;
+// and prev, the page before, when paging by offset, which a cursor can't
+// go back from. The links are relative to the host, as the request's path.
+func pageLinks(w http.ResponseWriter, r *http.Request, next string, offset, limit int64) {
+	link := func(rel string, set func(q url.Values)) {
+		q := r.URL.Query()
+		set(q)
+		u := url.URL{Path: r.URL.Path, RawQuery: q.Encode()}
+		w.Header().Add("Link", "<"+u.String()+`>; rel="`+rel+`"`)
+	}
+	if next != "" { link("next", func(q url.Values) { q.Set("after", next); q.Del("offset") }) }
+	if offset > 0 && !r.URL.Query().Has("after") {
+		link("prev", func(q url.Values) {
+			if offset > limit { q.Set("offset", strconv.FormatInt(offset-limit, 10)) } else { q.Del("offset") }
+		})
+	}
+}
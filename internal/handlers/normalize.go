@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"app/internal/store"
+)
+
+// POST /admin/normalize -> 202 and a job giving the tenant's names the
+// name_normalized the NAME_* rules make of them, where theirs is missing or
+// stale; its result counts the names checked and updated
+//
+// Names written before normalization have no form, and changing the rules
+// leaves the old ones, until this is run. Without job workers it is run in
+// the request, and its result answered with 200.
+func (h *Handlers) Normalize(w http.ResponseWriter, r *http.Request) {
+	if h.normalize == nil { WriteProblem(w, http.StatusNotFound, CodeNotFound, "names aren't being normalized", nil); return }
+	if h.jobs != nil && h.jobs.Handles(jobNormalize) {
+		h.enqueue(w, r, &store.Job{Type: jobNormalize})
+		return
+	}
+	ctx, cancel := requestCtx(r, time.Minute)
+	defer cancel()
+	res, err := h.normalize.Renormalize(ctx)
+	if err != nil { Internal(w, err); return }
+	ok(w, res)
+}
+
+func (h *Handlers) normalizeJob(ctx context.Context, j *store.Job) error {
+	res, err := h.normalize.Renormalize(ctx)
+	if err != nil { return err }
+	j.Result, err = json.Marshal(res)
+	return err
+}
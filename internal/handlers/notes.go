@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"app/internal/auth"
+	"app/internal/store"
+	"app/internal/validate"
+)
+
+// POST /names/{id}/notes  { "body": "..." }  -> the note; 404 if the name doesn't exist or is soft-deleted
+func (h *Handlers) CreateNote(w http.ResponseWriter, r *http.Request) {
+	oid, valid := h.nameID(w, r)
+	if !valid { return }
+	var payload struct {
+		Body string `json:"body"`
+	}
+	if !decodeJSON(w, r.Body, &payload) { return }
+	n := store.Note{NameID: oid, Body: payload.Body}
+	if errs := validate.Note(&n); errs != nil { Unprocessable(w, errs); return }
+	if uid := auth.UserIDFromContext(r.Context()); !uid.IsZero() { n.Author = uid.Hex() }
+
+	ctx, cancel := requestCtx(r, 5*time.Second)
+	defer cancel()
+	_, err := h.names.Get(ctx, oid)
+	if errors.Is(err, store.ErrNotFound) { NotFound(w); return }
+	if err != nil { Internal(w, err); return }
+	if err = h.notes.CreateNote(ctx, &n); err != nil { Internal(w, err); return }
+	created(w, n)
+}
+
+// GET /names/{id}/notes -> {"items": [...]}, oldest first
+func (h *Handlers) ListNotes(w http.ResponseWriter, r *http.Request) {
+	oid, valid := h.nameID(w, r)
+	if !valid { return }
+
+	ctx, cancel := requestCtx(r, 10*time.Second)
+	defer cancel()
+	_, err := h.names.Get(ctx, oid)
+	if errors.Is(err, store.ErrNotFound) { NotFound(w); return }
+	if err != nil { Internal(w, err); return }
+	notes, err := h.notes.Notes(ctx, oid)
+	if err != nil { Internal(w, err); return }
+	ok(w, map[string]any{"items": notes})
+}
+
+// nameWithNotes answers GET /names/{id}?expand=notes. The name's version
+// doesn't change with its notes, so the ETag is a weak one of the body.
+func (h *Handlers) nameWithNotes(w http.ResponseWriter, r *http.Request, id primitive.ObjectID) {
+	ctx, cancel := requestCtx(r, 5*time.Second)
+	defer cancel()
+	n, err := h.notes.NameWithNotes(ctx, id)
+	if errors.Is(err, store.ErrNotFound) { NotFound(w); return }
+	if err != nil { Internal(w, err); return }
+	w.Header().Set("Cache-Control", cacheControl)
+	okCached(w, r, n)
+}
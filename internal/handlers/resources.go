@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"app/internal/resource"
+	"app/internal/store"
+)
+
+// The endpoints of the resources declared in RESOURCES_FILE. Each request
+// names its resource in the path; one that isn't declared is a 404, like
+// any unknown path.
+
+// DeclaredResource answers 404 for a resource that isn't declared, before
+// next, so that unknown paths under the API are a 404 whoever asks rather
+// than a 401 for those without credentials.
+func (h *Handlers) DeclaredResource(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if _, found := h.lookupResource(w, r); found { next(w, r) }
+	}
+}
+
+// lookupResource returns the resource named in the path, answering 404 if
+// there is none.
+func (h *Handlers) lookupResource(w http.ResponseWriter, r *http.Request) (*resource.Resource, bool) {
+	res, found := h.resources.Lookup(r.PathValue("resource"))
+	if !found { NotFound(w) }
+	return res, found
+}
+
+// decodeDoc reads a document of res from the body and validates it.
+func decodeDoc(w http.ResponseWriter, r *http.Request, res *resource.Resource) (map[string]any, bool) {
+	var doc map[string]any
+	if !decodeJSON(w, r.Body, &doc) { return nil, false }
+	fields, errs := res.Validate(doc)
+	if errs != nil { Unprocessable(w, errs); return nil, false }
+	return fields, true
+}
+
+// GET /{resource}?limit=&after=  -> {"items", "next"}, oldest first
+func (h *Handlers) ListDocs(w http.ResponseWriter, r *http.Request) {
+	res, found := h.lookupResource(w, r)
+	if !found { return }
+	q := r.URL.Query()
+	limit, after := defaultPageSize, primitive.NilObjectID
+	var errs []FieldError
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxPageSize {
+			errs = append(errs, FieldError{Field: "limit", Message: "must be an integer between 1 and " + strconv.Itoa(maxPageSize)})
+		}
+		limit = n
+	}
+	if v := q.Get("after"); v != "" {
+		id, err := primitive.ObjectIDFromHex(v)
+		if err != nil { errs = append(errs, FieldError{Field: "after", Message: "is not a valid cursor"}) }
+		after = id
+	}
+	if errs != nil { Unprocessable(w, errs); return }
+
+	ctx, cancel := requestCtx(r, 10*time.Second)
+	defer cancel()
+	page, err := h.docs.ListDocs(ctx, res.Collection, after, limit)
+	if err != nil { Internal(w, err); return }
+	pageLinks(w, r, page.Next, 0, int64(limit))
+	okCached(w, r, page)
+}
+
+// POST /{resource}  { "<field>": value, ... }
+func (h *Handlers) CreateDoc(w http.ResponseWriter, r *http.Request) {
+	res, found := h.lookupResource(w, r)
+	if !found { return }
+	fields, valid := decodeDoc(w, r, res)
+	if !valid { return }
+
+	ctx, cancel := requestCtx(r, 5*time.Second)
+	defer cancel()
+	d := store.Doc{Fields: fields}
+	if err := h.docs.CreateDoc(ctx, res.Collection, &d); err != nil { Internal(w, err); return }
+	setVersionETag(w, d.Version)
+	created(w, d)
+}
+
+// GET /{resource}/{id}  -> the document, with its version as ETag; 304 if If-None-Match has it
+func (h *Handlers) GetDoc(w http.ResponseWriter, r *http.Request) {
+	res, found := h.lookupResource(w, r)
+	if !found { return }
+	oid, valid := pathID(w, r)
+	if !valid { return }
+
+	ctx, cancel := requestCtx(r, 5*time.Second)
+	defer cancel()
+	d, err := h.docs.GetDoc(ctx, res.Collection, oid)
+	if errors.Is(err, store.ErrNotFound) { NotFound(w); return }
+	if err != nil { Internal(w, err); return }
+	setVersionETag(w, d.Version)
+	w.Header().Set("Cache-Control", cacheControl)
+	if notModified(w, r, w.Header().Get("ETag")) { return }
+	ok(w, d)
+}
+
+// PUT /{resource}/{id}  { "<field>": value, ... }  (omitted fields are cleared)
+// If-Match: "<version>" is required; 412 if the document has changed since.
+func (h *Handlers) UpdateDoc(w http.ResponseWriter, r *http.Request) {
+	res, found := h.lookupResource(w, r)
+	if !found { return }
+	oid, valid := pathID(w, r)
+	if !valid { return }
+	version, valid := ifMatch(w, r)
+	if !valid { return }
+	fields, valid := decodeDoc(w, r, res)
+	if !valid { return }
+
+	ctx, cancel := requestCtx(r, 5*time.Second)
+	defer cancel()
+	d, err := h.docs.UpdateDoc(ctx, res.Collection, oid, fields, version)
+	if errors.Is(err, store.ErrNotFound) { NotFound(w); return }
+	if errors.Is(err, store.ErrVersionMismatch) { preconditionFailed(w); return }
+	if err != nil { Internal(w, err); return }
+	setVersionETag(w, d.Version)
+	ok(w, d)
+}
+
+// DELETE /{resource}/{id}  -> permanent; If-Match is required, as for PUT.
+func (h *Handlers) DeleteDoc(w http.ResponseWriter, r *http.Request) {
+	res, found := h.lookupResource(w, r)
+	if !found { return }
+	oid, valid := pathID(w, r)
+	if !valid { return }
+	version, valid := ifMatch(w, r)
+	if !valid { return }
+
+	ctx, cancel := requestCtx(r, 5*time.Second)
+	defer cancel()
+	err := h.docs.DeleteDoc(ctx, res.Collection, oid, version)
+	if errors.Is(err, store.ErrNotFound) { NotFound(w); return }
+	if errors.Is(err, store.ErrVersionMismatch) { preconditionFailed(w); return }
+	if err != nil { Internal(w, err); return }
+	noContent(w)
+}
@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"maps"
+	"net/http"
+	"strings"
+
+	"app/internal/requestid"
+	"app/internal/validate"
+)
+
+// FieldError is one validation failure, reported to clients in a 422.
+type FieldError = validate.FieldError
+
+// Problem codes are the "code" member of every error body, and what clients
+// are expected to branch on rather than status or detail. They are part of
+// the API: never change or reuse one, only add.
+const (
+	CodeBadRequest              = "bad_request"
+	CodeInvalidJSON             = "invalid_json"
+	CodeValidationFailed        = "validation_failed"
+	CodeUnauthorized            = "unauthorized"
+	CodeForbidden               = "forbidden"
+	CodeNotFound                = "not_found"
+	CodeMethodNotAllowed        = "method_not_allowed"
+	CodeDuplicateName           = "duplicate_name"
+	CodeNameHasNotes            = "name_has_notes"
+	CodeNotOwner                = "not_owner"
+	CodeDuplicateUsername       = "duplicate_username"
+	CodeIdempotencyKeyInUse     = "idempotency_key_in_use"
+	CodeResumeExpired           = "resume_expired"
+	CodeVersionMismatch         = "version_mismatch"
+	CodePreconditionRequired    = "precondition_required"
+	CodeBodyTooLarge            = "body_too_large"
+	CodeMalformedCSV            = "malformed_csv"
+	CodeMalformedBody           = "malformed_body"
+	CodeLineTooLong             = "line_too_long"
+	CodeRateLimited             = "rate_limited"
+	CodeInternal                = "internal"
+	CodeWatchUnsupported        = "watch_unsupported"
+	CodeAuthDisabled            = "auth_disabled"
+	CodeTimeout                 = "timeout"
+	CodeRequestCanceled         = "request_canceled"
+	CodeJobNotDone              = "job_not_done"
+	CodeTransactionsUnsupported = "transactions_unsupported"
+	CodeOverloaded              = "overloaded"
+	CodeDraining                = "draining"
+	CodeQuotaExceeded           = "quota_exceeded"
+	CodeBadBackup               = "bad_backup"
+)
+
+// internalDetail is all a client learns about a 500. The error itself can
+// carry driver messages, hostnames and query fragments, so it only goes to
+// the log, under the request ID the client is given to quote.
+const internalDetail = "internal server error"
+
+// ---- response helpers ----
+// The exported ones are shared with the middleware in package server.
+func WriteJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// WriteProblem answers with an RFC 7807 application/problem+json body. Every
+// error the API returns goes through here, so clients see one shape: type
+// (always about:blank, so title is the status text), title, status, detail,
+// a stable code to branch on, the request ID, and extra members particular
+// to the error, such as "fields" for a 422.
+func WriteProblem(w http.ResponseWriter, status int, code, detail string, extra map[string]any) {
+	body := make(map[string]any, len(extra)+6)
+	maps.Copy(body, extra)
+	body["type"], body["title"], body["status"] = "about:blank", http.StatusText(status), status
+	if detail != "" { body["detail"] = detail }
+	if code != "" { body["code"] = code }
+	if id := w.Header().Get(requestid.Header); id != "" { body["request_id"] = id }
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func ok(w http.ResponseWriter, v any)                 { WriteJSON(w, http.StatusOK, v) }
+func created(w http.ResponseWriter, v any)            { WriteJSON(w, http.StatusCreated, v) }
+func BadRequest(w http.ResponseWriter, detail string) { WriteProblem(w, http.StatusBadRequest, CodeBadRequest, detail, nil) }
+func Forbidden(w http.ResponseWriter, detail string)  { WriteProblem(w, http.StatusForbidden, CodeForbidden, detail, nil) }
+func NotFound(w http.ResponseWriter)                  { WriteProblem(w, http.StatusNotFound, CodeNotFound, "", nil) }
+// Internal answers a failed call into the store. A call cut short by its
+// deadline gets a 503 rather than a 500, and one abandoned because the client
+// went away a 408, which only the access log and metrics will see.
+func Internal(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		WriteProblem(w, http.StatusServiceUnavailable, CodeTimeout, "the request took too long and was abandoned; try again", nil)
+		return
+	case errors.Is(err, context.Canceled):
+		WriteProblem(w, http.StatusRequestTimeout, CodeRequestCanceled, "the request was canceled", nil)
+		return
+	}
+	logInternal(w, err)
+	WriteProblem(w, http.StatusInternalServerError, CodeInternal, internalDetail, nil)
+}
+
+// logInternal logs err under the request ID, which requestid.Middleware has
+// already set on the response, so a client's report can be matched to it.
+func logInternal(w http.ResponseWriter, err error) {
+	slog.Error("internal error", "request_id", w.Header().Get(requestid.Header), "err", err)
+}
+func Unprocessable(w http.ResponseWriter, errs []FieldError) {
+	WriteProblem(w, http.StatusUnprocessableEntity, CodeValidationFailed, "validation failed", map[string]any{"fields": errs})
+}
+// conflict is a 409 with a stable code clients can branch on.
+func conflict(w http.ResponseWriter, code, detail string) {
+	WriteProblem(w, http.StatusConflict, code, detail, nil)
+}
+func preconditionFailed(w http.ResponseWriter) {
+	WriteProblem(w, http.StatusPreconditionFailed, CodeVersionMismatch, "name was modified since it was read", nil)
+}
+func preconditionRequired(w http.ResponseWriter) {
+	WriteProblem(w, http.StatusPreconditionRequired, CodePreconditionRequired, "If-Match header is required", nil)
+}
+func authDisabled(w http.ResponseWriter) {
+	WriteProblem(w, http.StatusServiceUnavailable, CodeAuthDisabled, "authentication is not configured", nil)
+}
+func TooLarge(w http.ResponseWriter, limit int64) {
+	WriteProblem(w, http.StatusRequestEntityTooLarge, CodeBodyTooLarge, "request body too large", map[string]any{"limit_bytes": limit})
+}
+func noContent(w http.ResponseWriter)                 { w.WriteHeader(http.StatusNoContent) }
+func MethodNotAllowed(w http.ResponseWriter, allowed ...string) {
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+	WriteProblem(w, http.StatusMethodNotAllowed, CodeMethodNotAllowed, "", map[string]any{"allow": allowed})
+}
+func Unauthorized(w http.ResponseWriter, detail string) {
+	w.Header().Set("WWW-Authenticate", `Bearer realm="names"`)
+	WriteProblem(w, http.StatusUnauthorized, CodeUnauthorized, detail, nil)
+}
@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"app/internal/requestid"
+)
+
+func TestInternalHidesError(t *testing.T) {
+	rec := httptest.NewRecorder()
+	rec.Header().Set(requestid.Header, "req-1")
+	Internal(rec, errors.New("connection to db-0.internal:27017 refused"))
+
+	if rec.Code != http.StatusInternalServerError { t.Fatalf("status %d", rec.Code) }
+	if strings.Contains(rec.Body.String(), "db-0") { t.Fatalf("body leaks the error: %s", rec.Body) }
+	var body map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil { t.Fatal(err) }
+	if body["code"] != CodeInternal || body["request_id"] != "req-1" || body["detail"] != internalDetail {
+		t.Fatalf("body %v", body)
+	}
+}
+
+func TestInternalContextErrors(t *testing.T) {
+	for _, tc := range []struct {
+		err    error
+		status int
+		code   string
+	}{
+		{fmt.Errorf("find: %w", context.DeadlineExceeded), http.StatusServiceUnavailable, CodeTimeout},
+		{fmt.Errorf("find: %w", context.Canceled), http.StatusRequestTimeout, CodeRequestCanceled},
+	} {
+		rec := httptest.NewRecorder()
+		Internal(rec, tc.err)
+		var body struct{ Code string }
+		_ = json.Unmarshal(rec.Body.Bytes(), &body)
+		if rec.Code != tc.status || body.Code != tc.code { t.Errorf("%v: %d %q, want %d %q", tc.err, rec.Code, body.Code, tc.status, tc.code) }
+	}
+}
@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+
+	"app/internal/store"
+)
+
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+	maxSearchQueryLen  = 200
+)
+
+// GET /names/search?q=ali&mode=text|prefix|regex&limit=N&consistency=strong|eventual
+//
+// text (default) uses the full-text index and ranks by relevance; prefix is
+// a case-insensitive starts-with match for type-ahead; regex matches names
+// against the expression as given.
+func (h *Handlers) SearchNames(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	opts := store.SearchOptions{Query: q.Get("q"), Mode: q.Get("mode"), Limit: defaultSearchLimit}
+	var errs []FieldError
+
+	switch {
+	case opts.Query == "":
+		errs = append(errs, FieldError{Field: "q", Message: "is required"})
+	case len(opts.Query) > maxSearchQueryLen:
+		errs = append(errs, FieldError{Field: "q", Message: "must be at most " + strconv.Itoa(maxSearchQueryLen) + " bytes"})
+	}
+	switch opts.Mode {
+	case "":
+		opts.Mode = store.SearchText
+	case store.SearchText, store.SearchPrefix:
+	case store.SearchRegex:
+		// Go's syntax is close enough to Mongo's PCRE to reject garbage early.
+		if _, err := regexp.Compile(opts.Query); err != nil { errs = append(errs, FieldError{Field: "q", Message: "is not a valid regular expression"}) }
+	default:
+		errs = append(errs, FieldError{Field: "mode", Message: "must be text, prefix or regex"})
+	}
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 1 || n > maxSearchLimit {
+			errs = append(errs, FieldError{Field: "limit", Message: "must be an integer between 1 and " + strconv.Itoa(maxSearchLimit)})
+		}
+		opts.Limit = n
+	}
+	c, cerr := parseConsistency(q)
+	opts.Consistency, errs = h.consistency(c), append(errs, cerr...)
+	if errs != nil { Unprocessable(w, errs); return }
+
+	ctx, cancel := requestCtx(r, 5*time.Second)
+	defer cancel()
+	hits, err := h.names.Search(ctx, opts)
+	if err != nil { Internal(w, err); return }
+	ok(w, map[string]any{"items": hits})
+}
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"errors"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+
+	"app/internal/auth"
+	"app/internal/prewrite"
+	"app/internal/store"
+)
+
+// Most names one POST /admin/seed makes up.
+const maxSeedCount = 10000
+
+// GET /names/random  -> one live name, picked at random; 404 if there are none
+func (h *Handlers) RandomName(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := requestCtx(r, 5*time.Second)
+	defer cancel()
+	n, err := h.sample.RandomName(ctx)
+	if errors.Is(err, store.ErrNotFound) { NotFound(w); return }
+	if err != nil { Internal(w, err); return }
+	w.Header().Set("Cache-Control", "no-store")
+	setETag(w, n)
+	ok(w, n)
+}
+
+// POST /admin/seed?count=N  -> {"created", "skipped"}, after making up N names (default 100, at most 10000)
+//
+// For demos and load tests: the names are drawn from a few lists of first
+// and last names, tagged "seed" and some of "demo", "vip" and "beta", with
+// a team in their metadata. Those the tenant already has are skipped.
+// Served outside production only (ENVIRONMENT), to callers with both the
+// admin:read and the names:write scope.
+func (h *Handlers) Seed(w http.ResponseWriter, r *http.Request) {
+	if !h.allowSeed { Forbidden(w, "seeding is disabled in production"); return }
+	if !auth.HasScope(r.Context(), auth.ScopeWrite) { Forbidden(w, "caller lacks the "+auth.ScopeWrite+" scope"); return }
+	count := 100
+	if v := r.URL.Query().Get("count"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxSeedCount {
+			Unprocessable(w, []FieldError{{Field: "count", Message: "must be an integer between 1 and " + strconv.Itoa(maxSeedCount)}}); return
+		}
+		count = n
+	}
+
+	ctx, cancel := requestCtx(r, 2*time.Minute)
+	defer cancel()
+	var summary struct {
+		Created int `json:"created"`
+		Skipped int `json:"skipped"`
+	}
+	names := fakeNames(count)
+	for len(names) > 0 {
+		batch := names[:min(len(names), maxBulkItems)]
+		names = names[len(batch):]
+		errs, err := h.names.CreateMany(ctx, batch)
+		if err != nil { Internal(w, err); return }
+		for _, err := range errs {
+			switch {
+			case err == nil:
+				summary.Created++
+			case errors.Is(err, store.ErrDuplicate), errors.As(err, new(*prewrite.Rejected)):
+				summary.Skipped++
+			default:
+				Internal(w, err); return
+			}
+		}
+	}
+	ok(w, summary)
+}
+
+var (
+	seedFirst = []string{"Ada", "Alan", "Barbara", "Claude", "Dennis", "Donald", "Edsger", "Frances", "Grace", "Hedy", "Ivan", "John", "Ken", "Katherine", "Linus", "Margaret", "Niklaus", "Radia", "Rob", "Sophie", "Tim", "Whitfield"}
+	seedLast  = []string{"Allen", "Berners-Lee", "Dijkstra", "Hamilton", "Hopper", "Johnson", "Kernighan", "Knuth", "Lamarr", "Liskov", "Lovelace", "Perlman", "Pike", "Ritchie", "Shannon", "Sutherland", "Thompson", "Torvalds", "Turing", "Wilson", "Wirth", "Diffie"}
+	seedTags  = []string{"demo", "vip", "beta"}
+	seedTeams = []string{"core", "growth", "platform", "research"}
+)
+
+// fakeNames makes up count names, all different: first and last names
+// while there are pairs left, numbered after that.
+func fakeNames(count int) []store.Name {
+	taken := make(map[string]bool, count)
+	out := make([]store.Name, 0, count)
+	for len(out) < count {
+		name := seedFirst[rand.IntN(len(seedFirst))] + " " + seedLast[rand.IntN(len(seedLast))]
+		if len(taken) >= len(seedFirst)*len(seedLast)/2 { name += " " + strconv.Itoa(rand.IntN(1_000_000)) }
+		if taken[name] { continue }
+		taken[name] = true
+		tags := []string{"seed"}
+		for _, t := range seedTags {
+			if rand.IntN(4) == 0 { tags = append(tags, t) }
+		}
+		out = append(out, store.Name{Name: name, Tags: tags, Metadata: map[string]any{"team": seedTeams[rand.IntN(len(seedTeams))]}})
+	}
+	return out
+}
@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"app/internal/store"
+)
+
+const (
+	defaultShadowDivergences = 100
+	maxShadowDivergences     = 1000
+)
+
+// POST /admin/shadow/check?limit=N -> 202 and a job comparing the tenant's names in the primary
+// and the SHADOW_STORE; its result lists up to N (default 100) of the names they disagree on
+//
+// Without job workers the check is run in the request, and its result
+// answered with 200.
+func (h *Handlers) ShadowCheck(w http.ResponseWriter, r *http.Request) {
+	if h.shadow == nil { WriteProblem(w, http.StatusNotFound, CodeNotFound, "writes aren't being copied to a shadow store; see SHADOW_STORE", nil); return }
+	limit := defaultShadowDivergences
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 || n > maxShadowDivergences {
+			Unprocessable(w, []FieldError{{Field: "limit", Message: "must be an integer between 0 and " + strconv.Itoa(maxShadowDivergences)}}); return
+		}
+		limit = n
+	}
+	if h.jobs != nil && h.jobs.Handles(jobShadowCheck) {
+		h.enqueue(w, r, &store.Job{Type: jobShadowCheck, Params: url.Values{"limit": {strconv.Itoa(limit)}}.Encode()})
+		return
+	}
+	ctx, cancel := requestCtx(r, time.Minute)
+	defer cancel()
+	report, err := h.shadow.Check(ctx, limit)
+	if err != nil { Internal(w, err); return }
+	ok(w, report)
+}
+
+func (h *Handlers) shadowCheckJob(ctx context.Context, j *store.Job) error {
+	q, err := url.ParseQuery(j.Params)
+	if err != nil { return err }
+	limit, err := strconv.Atoi(q.Get("limit"))
+	if err != nil { return err }
+	report, err := h.shadow.Check(ctx, limit)
+	if err != nil { return err }
+	j.Result, err = json.Marshal(report)
+	return err
+}
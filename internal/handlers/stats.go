@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Longest window GET /admin/stats counts creations over, in days.
+const maxStatsDays = 366
+
+// GET /admin/stats?days=N  -> name counts, creations per day over the last N days (default 30),
+// the longest and shortest names, and the collection's storage figures
+//
+// For dashboards. Bearer tokens may read it; API keys need the admin:read
+// scope.
+func (h *Handlers) AdminStats(w http.ResponseWriter, r *http.Request) {
+	days := 30
+	if v := r.URL.Query().Get("days"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxStatsDays {
+			Unprocessable(w, []FieldError{{Field: "days", Message: "must be an integer between 1 and " + strconv.Itoa(maxStatsDays)}}); return
+		}
+		days = n
+	}
+	// Whole UTC days, today being the last of them.
+	since := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, 1-days)
+
+	ctx, cancel := requestCtx(r, 10*time.Second)
+	defer cancel()
+	st, err := h.stats.NameStats(ctx, since)
+	if err != nil { Internal(w, err); return }
+	ok(w, st)
+}
@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"app/internal/requestid"
+	"app/internal/store"
+)
+
+// streamHeartbeat is how often an idle stream gets a comment line, so
+// proxies don't time the connection out.
+const streamHeartbeat = 15 * time.Second
+
+// GET /names/stream -> text/event-stream of changes to names
+//
+//	id: <resume token>
+//	event: created|updated|deleted|restored|removed
+//	data: {"type": ..., "id": ..., "name": {...}}
+//
+// To reconnect without missing anything, send the last id seen as
+// Last-Event-ID (EventSource does this itself) or as ?after=. A token that
+// is too old gets a 410 and the client has to reload. Needs a replica set;
+// on a standalone mongod the answer is a 501.
+func (h *Handlers) Stream(w http.ResponseWriter, r *http.Request) {
+	after := r.Header.Get("Last-Event-ID")
+	if v := r.URL.Query().Get("after"); v != "" { after = v }
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	cs, err := h.names.Watch(ctx, after)
+	switch {
+	case errors.Is(err, store.ErrWatchUnsupported):
+		WriteProblem(w, http.StatusNotImplemented, CodeWatchUnsupported, err.Error(), nil); return
+	case errors.Is(err, store.ErrResumeExpired):
+		WriteProblem(w, http.StatusGone, CodeResumeExpired, err.Error(), nil); return
+	case err != nil:
+		Internal(w, err); return
+	}
+
+	// Next runs in its own goroutine so heartbeats can go out meanwhile. The
+	// stream is only closed once that goroutine has stopped using it.
+	changes := make(chan store.NameChange)
+	failed := make(chan error, 1)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			c, err := cs.Next(ctx)
+			if err != nil { failed <- err; return }
+			select {
+			case changes <- c:
+			case <-ctx.Done(): return
+			}
+		}
+	}()
+	defer func() { cancel(); <-done; _ = cs.Close(context.WithoutCancel(ctx)) }()
+
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no") // nginx would otherwise buffer the stream
+	w.WriteHeader(http.StatusOK)
+	_ = rc.Flush()
+
+	tick := time.NewTicker(streamHeartbeat)
+	defer tick.Stop()
+	for {
+		select {
+		case c := <-changes:
+			data, _ := json.Marshal(c)
+			if _, err := fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", c.Token, c.Type, data); err != nil { return }
+		case <-tick.C:
+			if _, err := io.WriteString(w, ": ping\n\n"); err != nil { return }
+		case err := <-failed:
+			if ctx.Err() != nil { return }
+			// Like a failed export: the status is long sent, so log it and
+			// tell the client in-band.
+			slog.ErrorContext(ctx, "change stream aborted", "err", err)
+			data, _ := json.Marshal(map[string]string{"error": "change stream aborted", "request_id": requestid.FromContext(ctx)})
+			fmt.Fprintf(w, "event: error\ndata: %s\n\n", data)
+			_ = rc.Flush()
+			return
+		case <-ctx.Done():
+			return
+		}
+		_ = rc.Flush()
+	}
+}
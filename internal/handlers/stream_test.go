@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"app/internal/store"
+)
+
+// watchNames replays a fixed list of changes, then fails.
+type watchNames struct {
+	store.NameStore
+	after   string
+	changes []store.NameChange
+}
+
+func (w *watchNames) Watch(ctx context.Context, after string) (store.ChangeStream, error) {
+	if after == "stale" { return nil, store.ErrResumeExpired }
+	w.after = after
+	return &sliceStream{changes: w.changes}, nil
+}
+
+type sliceStream struct{ changes []store.NameChange }
+
+func (s *sliceStream) Next(ctx context.Context) (store.NameChange, error) {
+	if len(s.changes) == 0 { return store.NameChange{}, errors.New("cursor killed") }
+	c := s.changes[0]
+	s.changes = s.changes[1:]
+	return c, nil
+}
+
+func (s *sliceStream) Close(ctx context.Context) error { return nil }
+
+func TestStream(t *testing.T) {
+	id := primitive.NewObjectID()
+	names := &watchNames{changes: []store.NameChange{
+		{Token: "t1", Type: "created", ID: id, Name: &store.Name{ID: id, Name: "Alice", Version: 1}},
+		{Token: "t2", Type: "removed", ID: id},
+	}}
+	h := New(Deps{Names: names})
+
+	req := httptest.NewRequest(http.MethodGet, "/names/stream", nil)
+	req.Header.Set("Last-Event-ID", "t0")
+	rec := httptest.NewRecorder()
+	h.Stream(rec, req)
+
+	if names.after != "t0" { t.Fatalf("resumed after %q, want t0", names.after) }
+	if ct := rec.Header().Get("Content-Type"); ct != "text/event-stream" { t.Fatalf("content type %q", ct) }
+	body := rec.Body.String()
+	for _, want := range []string{
+		"id: t1\nevent: created\ndata: {\"type\":\"created\",\"id\":\"" + id.Hex() + "\",\"name\":{\"id\":\"" + id.Hex() + "\",\"name\":\"Alice\",\"version\":1}}\n\n",
+		"id: t2\nevent: removed\ndata: {\"type\":\"removed\",\"id\":\"" + id.Hex() + "\"}\n\n",
+		"event: error\ndata: {\"error\":\"change stream aborted\"",
+	} {
+		if !strings.Contains(body, want) { t.Fatalf("stream lacks %q:\n%s", want, body) }
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/names/stream?after=stale", nil)
+	rec = httptest.NewRecorder()
+	h.Stream(rec, req)
+	if rec.Code != http.StatusGone { t.Fatalf("stale token: status %d", rec.Code) }
+}
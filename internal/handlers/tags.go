@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"app/internal/store"
+	"app/internal/validate"
+)
+
+// POST /names/{id}/tags  { "tag": "vip" }  -> the name with the tag added; adding one it has changes nothing
+// If-Match is required, as for PUT; with "*" the tag is added to whatever version is current.
+func (h *Handlers) AddTag(w http.ResponseWriter, r *http.Request) {
+	var payload struct {
+		Tag string `json:"tag"`
+	}
+	if !decodeJSON(w, r.Body, &payload) { return }
+	if errs := validate.Tag(&payload.Tag); errs != nil { Unprocessable(w, errs); return }
+	h.editTags(w, r, func(tags []string) []string {
+		if slices.Contains(tags, payload.Tag) { return tags }
+		return append(tags, payload.Tag)
+	})
+}
+
+// DELETE /names/{id}/tags/{tag}  -> the name without the tag; removing one it hasn't changes nothing
+// If-Match is required, as for AddTag.
+func (h *Handlers) RemoveTag(w http.ResponseWriter, r *http.Request) {
+	tag := r.PathValue("tag")
+	h.editTags(w, r, func(tags []string) []string {
+		return slices.DeleteFunc(tags, func(t string) bool { return t == tag })
+	})
+}
+
+// editTags replaces the tags of the name in the path with edit(tags), as a
+// Patch conditional on the version read, and answers with the name. Under
+// If-Match: * a concurrent write makes it read and try again, a few times.
+func (h *Handlers) editTags(w http.ResponseWriter, r *http.Request, edit func([]string) []string) {
+	oid, valid := h.nameID(w, r)
+	if !valid { return }
+	version, valid := ifMatch(w, r)
+	if !valid { return }
+
+	ctx, cancel := requestCtx(r, 5*time.Second)
+	defer cancel()
+	for attempt := 1; ; attempt++ {
+		n, err := h.names.Get(ctx, oid)
+		if errors.Is(err, store.ErrNotFound) { NotFound(w); return }
+		if err != nil { Internal(w, err); return }
+		if version != store.AnyVersion && n.Version != version { preconditionFailed(w); return }
+
+		tags := edit(slices.Clone(n.Tags))
+		if slices.Equal(tags, n.Tags) { setETag(w, n); ok(w, n); return }
+		if len(tags) > validate.MaxTags {
+			Unprocessable(w, []FieldError{{Field: "tag", Message: "the name already has " + strconv.Itoa(validate.MaxTags) + " tags, the most allowed"}})
+			return
+		}
+		n, err = h.names.Patch(ctx, oid, store.NamePatch{Tags: &tags}, n.Version)
+		if errors.Is(err, store.ErrVersionMismatch) && version == store.AnyVersion && attempt < 3 { continue }
+		if errors.Is(err, store.ErrNotFound) { NotFound(w); return }
+		if errors.Is(err, store.ErrVersionMismatch) { preconditionFailed(w); return }
+		if errors.Is(err, store.ErrNotOwner) { notOwner(w); return }
+		if rejected(w, err) { return }
+		if err != nil { Internal(w, err); return }
+		setETag(w, n)
+		ok(w, n)
+		return
+	}
+}
@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"app/internal/prewrite"
+	"app/internal/store"
+	"app/internal/validate"
+)
+
+// Most operations a single transaction may carry.
+const maxTransactionOps = 100
+
+// txOp is one operation of POST /names/transaction.
+type txOp struct {
+	Op        string     `json:"op"` // create, update or delete
+	ID        string     `json:"id"` // of the name to update or delete
+	IfVersion *int64     `json:"if_version"`
+	Hard      bool       `json:"hard"` // delete for good
+	Data      store.Name `json:"data"` // what to create, or to replace the name with
+
+	oid primitive.ObjectID
+}
+
+type txResult struct {
+	Op   string      `json:"op"`
+	ID   string      `json:"id"`
+	Name *store.Name `json:"name,omitempty"` // as stored; none for a delete
+}
+
+// opError is the failure of the operation at index, which rolled back the
+// transaction.
+type opError struct {
+	index int
+	err   error
+}
+
+func (e *opError) Error() string { return fmt.Sprintf("operation %d: %v", e.index, e.err) }
+func (e *opError) Unwrap() error { return e.err }
+
+// POST /names/transaction  { "operations": [
+//
+//	{ "op": "create", "data": { "name": "Alice", "tags": ["vip"] } },
+//	{ "op": "update", "id": "...", "if_version": 3, "data": { "name": "Bob" } },
+//	{ "op": "delete", "id": "...", "if_version": 1, "hard": true }
+//
+// ] }  -> {"results": [...]}, one per operation, in order
+//
+// The operations apply all together, or not at all: the first one that
+// fails rolls back those before it, and is answered for with the status its
+// single-name request would have had and its index as "operation". An
+// update replaces the name as PUT does. Updates and deletes need
+// if_version, as PUT and DELETE need If-Match: without it the answer is
+// 428, for the first operation missing one. Needs MongoDB running as a
+// replica set (or the memory store): elsewhere the answer is 501.
+func (h *Handlers) Transaction(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Operations []txOp `json:"operations"`
+	}
+	if !decodeJSON(w, r.Body, &req) { return }
+	ops := req.Operations
+	if len(ops) == 0 || len(ops) > maxTransactionOps {
+		BadRequest(w, "expected between 1 and "+strconv.Itoa(maxTransactionOps)+" operations"); return
+	}
+	var errs []FieldError
+	for i := range ops {
+		errs = append(errs, checkTxOp(fmt.Sprintf("operations[%d].", i), &ops[i])...)
+	}
+	if errs != nil { Unprocessable(w, errs); return }
+	for i, op := range ops {
+		if op.Op != "create" && op.IfVersion == nil {
+			WriteProblem(w, http.StatusPreconditionRequired, CodePreconditionRequired, "if_version is required", map[string]any{"operation": i}); return
+		}
+	}
+	for _, op := range ops {
+		if op.Hard && !h.allowHardDelete { Forbidden(w, "hard delete is disabled"); return }
+	}
+	if h.tx == nil { transactionsUnsupported(w, store.ErrTransactionsUnsupported); return }
+
+	ctx, cancel := requestCtx(r, 30*time.Second)
+	defer cancel()
+	var results []txResult
+	err := h.tx.InTransaction(ctx, func(ctx context.Context) error {
+		results = make([]txResult, 0, len(ops)) // afresh if the transaction is retried
+		for i, op := range ops {
+			res, err := h.apply(ctx, op)
+			if err != nil { return &opError{i, err} }
+			results = append(results, res)
+		}
+		return nil
+	})
+	if errors.Is(err, store.ErrTransactionsUnsupported) { transactionsUnsupported(w, err); return }
+	var failed *opError
+	if !errors.As(err, &failed) {
+		if err != nil { Internal(w, err); return }
+		ok(w, map[string]any{"results": results})
+		return
+	}
+	at := map[string]any{"operation": failed.index}
+	var rej *prewrite.Rejected
+	switch {
+	case errors.As(err, &rej):
+		at["fields"] = rej.Fields
+		WriteProblem(w, http.StatusUnprocessableEntity, CodeValidationFailed, "validation failed", at)
+	case errors.Is(err, store.ErrNotFound):
+		WriteProblem(w, http.StatusNotFound, CodeNotFound, "", at)
+	case errors.Is(err, store.ErrVersionMismatch):
+		WriteProblem(w, http.StatusPreconditionFailed, CodeVersionMismatch, "name was modified since it was read", at)
+	case errors.Is(err, store.ErrDuplicate):
+		WriteProblem(w, http.StatusConflict, CodeDuplicateName, "name already exists", at)
+	case errors.Is(err, store.ErrHasNotes):
+		WriteProblem(w, http.StatusConflict, CodeNameHasNotes, hasNotesDetail, at)
+	case errors.Is(err, store.ErrNotOwner):
+		WriteProblem(w, http.StatusForbidden, CodeNotOwner, notOwnerDetail, at)
+	default:
+		Internal(w, err)
+	}
+}
+
+// checkTxOp validates op, reporting its fields under prefix, and parses
+// its ID.
+func checkTxOp(prefix string, op *txOp) []FieldError {
+	var errs []FieldError
+	if op.Op != "create" {
+		oid, err := primitive.ObjectIDFromHex(op.ID)
+		if err != nil { errs = append(errs, FieldError{Field: prefix + "id", Message: "must be the ID of a name"}) }
+		op.oid = oid
+		if op.IfVersion != nil && *op.IfVersion < 1 { errs = append(errs, FieldError{Field: prefix + "if_version", Message: "must be a positive integer"}) }
+	}
+	switch op.Op {
+	case "create", "update":
+		for _, e := range validate.Name(&op.Data) {
+			e.Field = prefix + "data." + e.Field
+			errs = append(errs, e)
+		}
+	case "delete":
+	default:
+		errs = append(errs, FieldError{Field: prefix + "op", Message: "must be create, update or delete"})
+	}
+	return errs
+}
+
+// apply runs one operation of a transaction.
+func (h *Handlers) apply(ctx context.Context, op txOp) (txResult, error) {
+	var version int64
+	if op.IfVersion != nil { version = *op.IfVersion } // Transaction turned away updates and deletes without one
+	res := txResult{Op: op.Op, ID: op.oid.Hex()}
+	switch op.Op {
+	case "create":
+		n := store.Name{Name: op.Data.Name, Tags: op.Data.Tags, Metadata: op.Data.Metadata, ExpiresAt: op.Data.ExpiresAt}
+		if err := h.names.Create(ctx, &n); err != nil { return res, err }
+		res.ID, res.Name = n.ID.Hex(), &n
+	case "update":
+		n, err := h.names.Update(ctx, op.oid, op.Data, version)
+		if err != nil { return res, err }
+		res.Name = &n
+	case "delete":
+		del := h.names.SoftDelete
+		if op.Hard { del = h.names.HardDelete }
+		if err := del(ctx, op.oid, version); err != nil { return res, err }
+	}
+	return res, nil
+}
+
+func transactionsUnsupported(w http.ResponseWriter, err error) {
+	WriteProblem(w, http.StatusNotImplemented, CodeTransactionsUnsupported, err.Error()+"; MongoDB must run as a replica set", nil)
+}
@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"net/http"
+	"slices"
+	"time"
+
+	"app/internal/auth"
+	"app/internal/store"
+	"app/internal/tenant"
+	"app/internal/usage"
+)
+
+// keyUsage is what GET /usage tells of a key.
+type keyUsage struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Prefix string `json:"prefix"`
+	usage.Report
+}
+
+// GET /usage -> {"quotas", "items": [{"id", "name", "prefix", "today", "month"}]}
+//
+// With an API key, what that key used today and this month; with a bearer
+// token, each of the caller's unrevoked keys, or an admin's of the
+// tenant's. 404 without USAGE.
+func (h *Handlers) Usage(w http.ResponseWriter, r *http.Request) {
+	if h.usage == nil { NotFound(w); return }
+	if !h.tokens.Enabled() {
+		authDisabled(w); return
+	}
+	ctx, cancel := requestCtx(r, 5*time.Second)
+	defer cancel()
+	keys, err := h.apiKeys.APIKeys(ctx, tenant.FromContext(r.Context()))
+	if err != nil { Internal(w, err); return }
+	keyID, uid := auth.APIKeyIDFromContext(r.Context()), auth.UserIDFromContext(r.Context())
+	role, _ := auth.RoleFromContext(r.Context())
+	keys = slices.DeleteFunc(keys, func(k store.APIKey) bool {
+		switch {
+		case !keyID.IsZero():
+			return k.ID != keyID
+		case k.RevokedAt != nil:
+			return true
+		}
+		return role != auth.RoleAdmin && k.UserID != uid
+	})
+
+	items := make([]keyUsage, len(keys))
+	for i, k := range keys {
+		rep, err := h.usage.Report(ctx, k.ID)
+		if err != nil { Internal(w, err); return }
+		items[i] = keyUsage{ID: k.ID.Hex(), Name: k.Name, Prefix: k.Prefix, Report: rep}
+	}
+	ok(w, map[string]any{"quotas": h.usage.Quotas(), "items": items})
+}
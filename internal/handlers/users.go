@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"app/internal/auth"
+	"app/internal/store"
+	"app/internal/tenant"
+)
+
+// GET /users -> {"items": [...]}, the caller's tenant's users by username
+func (h *Handlers) ListUsers(w http.ResponseWriter, r *http.Request) {
+	if !h.tokens.Enabled() {
+		authDisabled(w); return
+	}
+	ctx, cancel := requestCtx(r, 5*time.Second)
+	defer cancel()
+	users, err := h.users.Users(ctx, tenant.FromContext(r.Context()))
+	if err != nil { Internal(w, err); return }
+	ok(w, map[string]any{"items": users})
+}
+
+// PUT /users/{id}/role  { "role": "viewer" } -> the user
+//
+// It takes effect at the user's next login. Their API keys are lowered to
+// the new role right away; raising it leaves the keys as they were. Admins
+// can't change their own role, so a tenant always keeps one.
+func (h *Handlers) SetUserRole(w http.ResponseWriter, r *http.Request) {
+	if !h.tokens.Enabled() {
+		authDisabled(w); return
+	}
+	oid, valid := pathID(w, r)
+	if !valid { return }
+	role, valid := decodeRole(w, r)
+	if !valid { return }
+	if oid == auth.UserIDFromContext(r.Context()) { Forbidden(w, "you can't change your own role"); return }
+
+	ctx, cancel := requestCtx(r, 5*time.Second)
+	defer cancel()
+	tid := tenant.FromContext(r.Context())
+	u, err := h.users.SetUserRole(ctx, tid, oid, role)
+	if errors.Is(err, store.ErrNotFound) { NotFound(w); return }
+	if err != nil { Internal(w, err); return }
+
+	keys, err := h.apiKeys.APIKeys(ctx, tid)
+	if err != nil { Internal(w, err); return }
+	for _, k := range keys {
+		if k.UserID != oid || k.RevokedAt != nil || auth.RoleWithin(k.Role, role) { continue }
+		if _, err := h.apiKeys.SetAPIKeyRole(ctx, tid, k.ID, role); err != nil { Internal(w, err); return }
+	}
+	ok(w, u)
+}
+
+// decodeRole reads the { "role": ... } body of the role endpoints.
+func decodeRole(w http.ResponseWriter, r *http.Request) (string, bool) {
+	var req struct {
+		Role string `json:"role"`
+	}
+	if !decodeJSON(w, r.Body, &req) { return "", false }
+	if !auth.ValidRole(req.Role) {
+		Unprocessable(w, []FieldError{{Field: "role", Message: "must be one of " + strings.Join(auth.Roles, ", ")}}); return "", false
+	}
+	return req.Role, true
+}
+
@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"app/internal/ids"
+	"app/internal/prewrite"
+	"app/internal/store"
+	"app/internal/validate"
+)
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 500
+)
+
+// decodeName reads and validates a Name from a request body. It answers
+// itself on failure: 400/413 as decodeJSON, 422 if it's well-formed but the
+// content is invalid.
+func decodeName(w http.ResponseWriter, body io.Reader) (store.Name, bool) {
+	var n store.Name
+	if !decodeJSON(w, body, &n) { return n, false }
+	if errs := validate.Name(&n); errs != nil {
+		Unprocessable(w, errs); return n, false
+	}
+	return n, true
+}
+
+// rejected answers 422 if err is the PREWRITE_HOOKS_FILE hooks refusing a
+// name: a check failed, or a set made it invalid. A hook that can't be
+// evaluated is left to Internal.
+func rejected(w http.ResponseWriter, err error) bool {
+	var rej *prewrite.Rejected
+	if !errors.As(err, &rej) { return false }
+	Unprocessable(w, rej.Fields)
+	return true
+}
+
+// pathID parses the {id} path segment, answering 400 itself if it isn't an ObjectID.
+func pathID(w http.ResponseWriter, r *http.Request) (primitive.ObjectID, bool) {
+	oid, err := primitive.ObjectIDFromHex(r.PathValue("id"))
+	if err != nil { BadRequest(w, "invalid id"); return oid, false }
+	return oid, true
+}
+
+// nameID resolves the {id} path segment of a name: its ObjectID, or the
+// UUID or slug it was given (see package ids). It answers 400 itself if the
+// segment is none of those, and 404 if no name has it.
+func (h *Handlers) nameID(w http.ResponseWriter, r *http.Request) (primitive.ObjectID, bool) {
+	oid, err := primitive.ObjectIDFromHex(r.PathValue("id"))
+	if err == nil { return oid, true }
+	key, valid := ids.ParseKey(r.PathValue("id"))
+	if !valid || h.nameKeys == nil { BadRequest(w, "invalid id"); return oid, false }
+
+	ctx, cancel := requestCtx(r, 5*time.Second)
+	defer cancel()
+	oid, err = h.nameKeys.NameByKey(ctx, key)
+	if errors.Is(err, store.ErrNotFound) { NotFound(w); return oid, false }
+	if err != nil { Internal(w, err); return oid, false }
+	return oid, true
+}
+
+// parseListQuery reads the GET /names query parameters:
+//
+//	limit=N            page size (default 50, max 500)
+//	offset=N           skip N items, or
+//	after=<cursor>     resume after the "next" cursor of a previous page
+//	sort=name|created_at, "-" prefix for descending (default created_at)
+//	name=<prefix>      only names starting with prefix
+//	tag=<tag>          only names with the tag; repeatable
+//	tagMode=all|any    with all the tags given (default), or any of them
+//	locale=<locale>    sort names by this collation locale, e.g. fr or sv
+//	consistency=strong|eventual  whether a secondary, a little behind, may answer
+//	includeDeleted=true
+func parseListQuery(q url.Values) (store.ListOptions, []FieldError) {
+	opts := store.ListOptions{Limit: defaultPageSize, SortBy: "created_at"}
+	var errs []FieldError
+
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 1 || n > maxPageSize {
+			errs = append(errs, FieldError{Field: "limit", Message: "must be an integer between 1 and " + strconv.Itoa(maxPageSize)})
+		}
+		opts.Limit = n
+	}
+	if v := q.Get("offset"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 0 { errs = append(errs, FieldError{Field: "offset", Message: "must be a non-negative integer"}) }
+		opts.Offset = n
+	}
+
+	sort := q.Get("sort")
+	opts.Desc = strings.HasPrefix(sort, "-")
+	switch strings.TrimPrefix(sort, "-") {
+	case "", "created_at":
+	case "name":
+		opts.SortBy = "name"
+	default:
+		errs = append(errs, FieldError{Field: "sort", Message: "must be name or created_at, optionally prefixed with -"})
+	}
+
+	if v := q.Get("after"); v != "" {
+		c, err := store.DecodeCursor(v)
+		if err != nil { errs = append(errs, FieldError{Field: "after", Message: "is not a valid cursor"}) }
+		opts.After = c
+		if opts.Offset != 0 { errs = append(errs, FieldError{Field: "offset", Message: "cannot be combined with after"}) }
+	}
+
+	opts.NamePrefix = q.Get("name")
+	opts.Match = q.Get("match")
+	if opts.Match != "" && strings.TrimSpace(opts.Match) == "" { errs = append(errs, FieldError{Field: "match", Message: "must not be blank"}) }
+	opts.Tags = q["tag"]
+	if len(opts.Tags) > validate.MaxTags { errs = append(errs, FieldError{Field: "tag", Message: "at most " + strconv.Itoa(validate.MaxTags) + " allowed"}) }
+	switch q.Get("tagMode") {
+	case "", "all":
+	case "any":
+		opts.AnyTag = true
+	default:
+		errs = append(errs, FieldError{Field: "tagMode", Message: "must be all or any"})
+	}
+	opts.Locale = q.Get("locale")
+	if opts.Locale != "" && !store.ValidLocale(opts.Locale) { errs = append(errs, FieldError{Field: "locale", Message: "must be an ICU locale such as fr or de_AT, or simple"}) }
+	opts.IncludeDeleted = q.Get("includeDeleted") == "true"
+	c, cerr := parseConsistency(q)
+	opts.Consistency, errs = c, append(errs, cerr...)
+	return opts, errs
+}
+
+// parseConsistency reads consistency=strong|eventual, the consistency a
+// list or search asks for (see store.Eventual); "" if it asks for none.
+func parseConsistency(q url.Values) (string, []FieldError) {
+	switch c := q.Get("consistency"); c {
+	case "", store.Strong, store.Eventual:
+		return c, nil
+	}
+	return "", []FieldError{{Field: "consistency", Message: "must be strong or eventual"}}
+}
+
+// listFailed answers for a listing the store failed to run.
+func listFailed(w http.ResponseWriter, err error) {
+	if errors.Is(err, store.ErrUnsupportedLocale) { Unprocessable(w, []FieldError{{Field: "locale", Message: "is not supported by the database"}}); return }
+	Internal(w, err)
+}
@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"app/internal/store"
+	"app/internal/webhook"
+)
+
+// POST /webhooks  { "url": "https://example.com/hook", "events": ["created", "removed"], "secret": "..." }
+// -> the webhook, plus "secret" if none was given and one was made for it,
+// which is shown this once and never again
+//
+// Each delivery is signed with the secret; see package webhook.
+func (h *Handlers) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		URL    string   `json:"url"`
+		Events []string `json:"events"`
+		Secret string   `json:"secret"`
+	}
+	if !decodeJSON(w, r.Body, &req) { return }
+
+	var errs []FieldError
+	if u, err := url.Parse(req.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || len(req.URL) > 2048 {
+		errs = append(errs, FieldError{Field: "url", Message: "must be an absolute http or https URL of at most 2048 characters"})
+	}
+	if len(req.Events) == 0 {
+		errs = append(errs, FieldError{Field: "events", Message: "must list at least one of " + strings.Join(webhook.Events, ", ")})
+	}
+	for _, e := range req.Events {
+		if !slices.Contains(webhook.Events, e) { errs = append(errs, FieldError{Field: "events", Message: fmt.Sprintf("unknown event %q", e)}) }
+	}
+	if req.Secret != "" && (len(req.Secret) < 16 || len(req.Secret) > 128) {
+		errs = append(errs, FieldError{Field: "secret", Message: "must be 16 to 128 characters, or left out to have one made"})
+	}
+	if errs != nil { Unprocessable(w, errs); return }
+
+	var made string
+	if req.Secret == "" {
+		b := make([]byte, 32)
+		if _, err := rand.Read(b); err != nil { Internal(w, err); return }
+		made = hex.EncodeToString(b)
+	}
+	slices.Sort(req.Events)
+	hook := store.Webhook{URL: req.URL, Events: slices.Compact(req.Events), Secret: req.Secret + made}
+
+	ctx, cancel := requestCtx(r, 5*time.Second)
+	defer cancel()
+	if err := h.webhooks.CreateWebhook(ctx, &hook); err != nil { Internal(w, err); return }
+	created(w, struct {
+		store.Webhook
+		Secret string `json:"secret,omitempty"`
+	}{hook, made})
+}
+
+// GET /webhooks -> {"items": [...]}, the tenant's webhooks, oldest first
+func (h *Handlers) ListWebhooks(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := requestCtx(r, 5*time.Second)
+	defer cancel()
+	hooks, err := h.webhooks.Webhooks(ctx)
+	if err != nil { Internal(w, err); return }
+	ok(w, map[string]any{"items": hooks})
+}
+
+// DELETE /webhooks/{id} -> 204; deliveries not yet made are dropped
+func (h *Handlers) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	oid, valid := pathID(w, r)
+	if !valid { return }
+
+	ctx, cancel := requestCtx(r, 5*time.Second)
+	defer cancel()
+	err := h.webhooks.DeleteWebhook(ctx, oid)
+	if errors.Is(err, store.ErrNotFound) { NotFound(w); return }
+	if err != nil { Internal(w, err); return }
+	noContent(w)
+}
+
+// GET /webhooks/{id}/deliveries?limit=N -> {"items": [...]}, newest first,
+// each with the log of its attempts
+func (h *Handlers) WebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	oid, valid := pathID(w, r)
+	if !valid { return }
+	limit := defaultPageSize
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxPageSize {
+			Unprocessable(w, []FieldError{{Field: "limit", Message: "must be an integer between 1 and " + strconv.Itoa(maxPageSize)}}); return
+		}
+		limit = n
+	}
+
+	ctx, cancel := requestCtx(r, 10*time.Second)
+	defer cancel()
+	if _, err := h.webhooks.Webhook(ctx, oid); errors.Is(err, store.ErrNotFound) {
+		NotFound(w); return
+	} else if err != nil {
+		Internal(w, err); return
+	}
+	log, err := h.webhooks.Deliveries(ctx, oid, limit)
+	if err != nil { Internal(w, err); return }
+	ok(w, map[string]any{"items": log})
+}
@@ -0,0 +1,109 @@
+// Package history keeps every version of a name that a write replaces, so
+// that earlier versions can be looked at and reverted to.
+package history
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"app/internal/store"
+)
+
+// Names wraps a NameStore and, after every successful update or delete,
+// saves the version it replaced in a HistoryStore. Like audit.Names, it
+// reads that version just before the write, saves it once the write or its
+// transaction has committed, and only logs a failure to save it.
+type Names struct {
+	store.NameStore
+	history store.HistoryStore
+}
+
+func NewNames(s store.NameStore, history store.HistoryStore) *Names {
+	return &Names{NameStore: s, history: history}
+}
+
+// around runs write and, if it succeeds, keeps the versions of ids it
+// replaced; kept reports which of them write actually changed.
+func (h *Names) around(ctx context.Context, ids []primitive.ObjectID, write func() (kept func(primitive.ObjectID) bool, err error)) error {
+	before, err := h.NameStore.Lookup(ctx, ids)
+	if err != nil { slog.ErrorContext(ctx, "reading names for their history", "err", err) }
+	kept, err := write()
+	if err != nil { return err }
+
+	var replaced []store.Name
+	for _, n := range before {
+		if kept(n.ID) { replaced = append(replaced, n) }
+	}
+	h.keep(ctx, replaced)
+	return nil
+}
+
+// keep saves the versions replaced by a write that has happened, so even
+// if the client has gone away meanwhile.
+func (h *Names) keep(ctx context.Context, replaced []store.Name) {
+	if len(replaced) == 0 { return }
+	store.AfterCommit(ctx, func(ctx context.Context) {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+		defer cancel()
+		if err := h.history.SaveVersions(ctx, replaced); err != nil {
+			slog.ErrorContext(ctx, "saving replaced versions", "names", len(replaced), "err", err)
+		}
+	})
+}
+
+func all(primitive.ObjectID) bool { return true }
+
+func (h *Names) Update(ctx context.Context, id primitive.ObjectID, n store.Name, ifVersion int64) (after store.Name, err error) {
+	err = h.around(ctx, []primitive.ObjectID{id}, func() (func(primitive.ObjectID) bool, error) {
+		after, err = h.NameStore.Update(ctx, id, n, ifVersion)
+		return all, err
+	})
+	return after, err
+}
+
+func (h *Names) Patch(ctx context.Context, id primitive.ObjectID, p store.NamePatch, ifVersion int64) (after store.Name, err error) {
+	err = h.around(ctx, []primitive.ObjectID{id}, func() (func(primitive.ObjectID) bool, error) {
+		after, err = h.NameStore.Patch(ctx, id, p, ifVersion)
+		return all, err
+	})
+	return after, err
+}
+
+// Upsert keeps the version it replaced, which the store reads in the same
+// write, rather than one read ahead of it.
+func (h *Names) Upsert(ctx context.Context, n store.Name, ifVersion int64) (*store.Name, store.Name, error) {
+	before, after, err := h.NameStore.Upsert(ctx, n, ifVersion)
+	if err == nil && before != nil { h.keep(ctx, []store.Name{*before}) }
+	return before, after, err
+}
+
+func (h *Names) SoftDelete(ctx context.Context, id primitive.ObjectID, ifVersion int64) error {
+	return h.around(ctx, []primitive.ObjectID{id}, func() (func(primitive.ObjectID) bool, error) {
+		return all, h.NameStore.SoftDelete(ctx, id, ifVersion)
+	})
+}
+
+func (h *Names) HardDelete(ctx context.Context, id primitive.ObjectID, ifVersion int64) error {
+	return h.around(ctx, []primitive.ObjectID{id}, func() (func(primitive.ObjectID) bool, error) {
+		return all, h.NameStore.HardDelete(ctx, id, ifVersion)
+	})
+}
+
+func (h *Names) Restore(ctx context.Context, id primitive.ObjectID) (after store.Name, err error) {
+	err = h.around(ctx, []primitive.ObjectID{id}, func() (func(primitive.ObjectID) bool, error) {
+		after, err = h.NameStore.Restore(ctx, id)
+		return all, err
+	})
+	return after, err
+}
+
+func (h *Names) DeleteMany(ctx context.Context, ids []primitive.ObjectID, hard bool) (existed map[primitive.ObjectID]bool, err error) {
+	err = h.around(ctx, ids, func() (func(primitive.ObjectID) bool, error) {
+		existed, err = h.NameStore.DeleteMany(ctx, ids, hard)
+		return func(id primitive.ObjectID) bool { return existed[id] }, err
+	})
+	return existed, err
+}
@@ -0,0 +1,39 @@
+package history
+
+import (
+	"context"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"app/internal/store"
+)
+
+func TestNames(t *testing.T) {
+	ctx := context.Background()
+	hist := store.NewMemoryHistory()
+	s := NewNames(store.NewMemoryNames(), hist)
+	a, b := store.Name{Name: "alice"}, store.Name{Name: "bob"}
+	_ = s.Create(ctx, &a)
+	_ = s.Create(ctx, &b)
+
+	if _, err := s.Patch(ctx, a.ID, store.NamePatch{Tags: &[]string{"vip"}}, 7); err == nil { t.Fatal("stale patch applied") }
+	if err := s.SoftDelete(ctx, a.ID, store.AnyVersion); err != nil { t.Fatal(err) }
+	if _, err := s.Restore(ctx, a.ID); err != nil { t.Fatal(err) }
+	if _, err := s.DeleteMany(ctx, []primitive.ObjectID{a.ID, b.ID, primitive.NewObjectID()}, true); err != nil { t.Fatal(err) }
+
+	// alice: 1 before the soft delete, 2 before the restore, 3 before removal.
+	if got, _ := hist.Versions(ctx, a.ID); len(got) != 3 || got[0].Version != 3 || got[1].DeletedAt == nil || got[2].Version != 1 { t.Fatalf("alice: %+v", got) }
+	if got, _ := hist.Versions(ctx, b.ID); len(got) != 1 || got[0].Name != "bob" { t.Fatalf("bob: %+v", got) }
+}
+
+func TestNamesUpsert(t *testing.T) {
+	ctx := context.Background()
+	hist := store.NewMemoryHistory()
+	s := NewNames(store.NewMemoryNames(), hist)
+
+	_, a, _ := s.Upsert(ctx, store.Name{Name: "alice"}, store.AnyVersion) // nothing replaced
+	if _, _, err := s.Upsert(ctx, store.Name{Name: "alice", Tags: []string{"vip"}}, 1); err != nil { t.Fatal(err) }
+	if _, _, err := s.Upsert(ctx, store.Name{Name: "alice"}, 1); err == nil { t.Fatal("stale upsert applied") }
+	if got, _ := hist.Versions(ctx, a.ID); len(got) != 1 || got[0].Version != 1 || len(got[0].Tags) != 0 { t.Fatalf("alice: %+v", got) }
+}
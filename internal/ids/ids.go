@@ -0,0 +1,69 @@
+// Package ids gives names the keys other than their ObjectID that a
+// deployment addresses them by, as ID_STRATEGY says: a UUIDv7, or a slug
+// made from the name. Every name keeps its ObjectID, and any of its keys
+// finds it.
+package ids
+
+import (
+	"math/rand/v2"
+	"regexp"
+	"strings"
+
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"app/internal/store"
+)
+
+// The ID strategies (ID_STRATEGY): the key new names get besides their
+// ObjectID.
+const (
+	ObjectID = "objectid" // none
+	UUID     = "uuid"     // a UUIDv7, such as 0190a6c2-7f2e-7b7a-8e1c-3f4d5e6f7a8b
+	Slug     = "slug"     // from the name: "Zoë Smith" is zoe-smith, or zoe-smith-x7k2 once that's taken
+)
+
+var Strategies = []string{ObjectID, UUID, Slug}
+
+// MaxSlug is the longest a slug gets, in bytes.
+const MaxSlug = 64
+
+var slugRE = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// NewUUID returns a new UUIDv7, which sorts by the time it was made.
+func NewUUID() string { return uuid.Must(uuid.NewV7()).String() }
+
+// Slugify returns the slug of name: its letters and digits, without
+// accents and in lower case, in runs joined by dashes, short enough to
+// take a suffix. A name without any ASCII ones makes "name".
+func Slugify(name string) string {
+	words := strings.FieldsFunc(store.NormalizeName(name), func(r rune) bool { return (r < 'a' || r > 'z') && (r < '0' || r > '9') })
+	slug := strings.Join(words, "-")
+	if max := MaxSlug - len("-xxxx"); len(slug) > max { slug = strings.TrimRight(slug[:max], "-") }
+	if slug == "" { return "name" }
+	return slug
+}
+
+// suffixed is slug with a random suffix, for when slug is taken.
+func suffixed(slug string) string {
+	const alphabet = "abcdefghijklmnopqrstuvwxyz0123456789"
+	suffix := make([]byte, 4)
+	for i := range suffix { suffix[i] = alphabet[rand.IntN(len(alphabet))] }
+	return slug + "-" + string(suffix)
+}
+
+// ambiguous reports whether slug would be read as another kind of ID.
+func ambiguous(slug string) bool {
+	if _, err := primitive.ObjectIDFromHex(slug); err == nil { return true }
+	_, err := uuid.Parse(slug)
+	return err == nil
+}
+
+// ParseKey reports whether s is a UUID or a slug, which NameByKey may find,
+// and returns it as the store has it: UUIDs in lower case.
+func ParseKey(s string) (string, bool) {
+	if len(s) == 36 {
+		if u, err := uuid.Parse(s); err == nil { return u.String(), true }
+	}
+	return s, len(s) <= MaxSlug && slugRE.MatchString(s)
+}
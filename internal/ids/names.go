@@ -0,0 +1,129 @@
+package ids
+
+import (
+	"context"
+	"errors"
+
+	"app/internal/store"
+)
+
+// attempts is how many times a create is tried with another slug, should
+// the one picked be taken between the check and the write.
+const attempts = 3
+
+// Names wraps a NameStore and gives the names it creates the key of the
+// strategy, overwriting whatever the client sent, except those a restore
+// (see store.Restoring) writes back with the keys of their backup. Other
+// methods pass straight through: a renamed name keeps its slug.
+type Names struct {
+	store.NameStore
+	keys     store.NameKeyStore
+	strategy string
+}
+
+func NewNames(s store.NameStore, keys store.NameKeyStore, strategy string) *Names {
+	return &Names{NameStore: s, keys: keys, strategy: strategy}
+}
+
+func (n *Names) Create(ctx context.Context, name *store.Name) error {
+	return n.single(ctx, name, n.NameStore.Create)
+}
+
+// CreateIfAbsent and Upsert key the name whether or not it ends up created;
+// the stores only use the keys when it is.
+func (n *Names) CreateIfAbsent(ctx context.Context, name *store.Name) (created bool, err error) {
+	err = n.single(ctx, name, func(ctx context.Context, name *store.Name) (err error) {
+		created, err = n.NameStore.CreateIfAbsent(ctx, name)
+		return err
+	})
+	return created, err
+}
+
+func (n *Names) Upsert(ctx context.Context, name store.Name, ifVersion int64) (before *store.Name, after store.Name, err error) {
+	err = n.single(ctx, &name, func(ctx context.Context, name *store.Name) (err error) {
+		before, after, err = n.NameStore.Upsert(ctx, *name, ifVersion)
+		return err
+	})
+	return before, after, err
+}
+
+// single is keyed for the one name written by create.
+func (n *Names) single(ctx context.Context, name *store.Name, create func(context.Context, *store.Name) error) error {
+	ns := []store.Name{*name}
+	errs, err := n.keyed(ctx, ns, func(ctx context.Context, ns []store.Name) ([]error, error) {
+		err := create(ctx, &ns[0])
+		if errors.Is(err, store.ErrDuplicate) { return []error{err}, nil }
+		return []error{nil}, err
+	})
+	*name = ns[0]
+	if err != nil { return err }
+	return errs[0]
+}
+
+func (n *Names) CreateMany(ctx context.Context, ns []store.Name) ([]error, error) {
+	return n.keyed(ctx, ns, n.NameStore.CreateMany)
+}
+
+func (n *Names) InsertMany(ctx context.Context, ns []store.Name) ([]error, error) {
+	return n.keyed(ctx, ns, n.NameStore.InsertMany)
+}
+
+// keyed gives ns their keys and creates them, then tries again with other
+// slugs for those that failed as duplicates while their names are free.
+// Inside a transaction it can't: the failed write has ended it.
+func (n *Names) keyed(ctx context.Context, ns []store.Name, create func(context.Context, []store.Name) ([]error, error)) ([]error, error) {
+	if store.IsRestoring(ctx) { return create(ctx, ns) }
+	if err := n.assign(ctx, ns); err != nil { return nil, err }
+	errs, err := create(ctx, ns)
+	for attempt := 1; err == nil && n.strategy == Slug && attempt < attempts && !store.InTransaction(ctx); attempt++ {
+		clashed, err := n.clashed(ctx, ns, errs)
+		if err != nil { return nil, err }
+		if len(clashed) == 0 { break }
+		again := make([]store.Name, len(clashed))
+		for j, i := range clashed {
+			again[j] = ns[i]
+			again[j].Slug = suffixed(Slugify(ns[i].Name))
+		}
+		againErrs, err := create(ctx, again)
+		if err != nil { return nil, err }
+		for j, i := range clashed { ns[i], errs[i] = again[j], againErrs[j] }
+	}
+	return errs, err
+}
+
+// assign gives ns the keys of the strategy: slugs that aren't taken, nor
+// by each other, nor could be read as an ObjectID or UUID.
+func (n *Names) assign(ctx context.Context, ns []store.Name) error {
+	for i := range ns { ns[i].UUID, ns[i].Slug = "", "" }
+	switch n.strategy {
+	case UUID:
+		for i := range ns { ns[i].UUID = NewUUID() }
+	case Slug:
+		slugs := make([]string, len(ns))
+		for i := range ns { slugs[i] = Slugify(ns[i].Name) }
+		taken, err := n.keys.SlugsTaken(ctx, slugs)
+		if err != nil { return err }
+		for i, slug := range slugs {
+			if taken[slug] || ambiguous(slug) { slug = suffixed(slug) }
+			taken[slug], ns[i].Slug = true, slug
+		}
+	}
+	return nil
+}
+
+// clashed returns the indexes of the names of ns that failed as duplicates
+// although no name has theirs: their slugs were taken.
+func (n *Names) clashed(ctx context.Context, ns []store.Name, errs []error) ([]int, error) {
+	var dups []string
+	for i, err := range errs {
+		if errors.Is(err, store.ErrDuplicate) { dups = append(dups, ns[i].Name) }
+	}
+	if len(dups) == 0 { return nil, nil }
+	existing, err := n.NameStore.ExistingNames(ctx, dups)
+	if err != nil { return nil, err }
+	var clashed []int
+	for i, err := range errs {
+		if errors.Is(err, store.ErrDuplicate) && !existing[ns[i].Name] { clashed = append(clashed, i) }
+	}
+	return clashed, nil
+}
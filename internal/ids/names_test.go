@@ -0,0 +1,72 @@
+package ids
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"app/internal/store"
+)
+
+func TestSlugify(t *testing.T) {
+	for name, want := range map[string]string{
+		"Zoë Smith":              "zoe-smith",
+		"  O'Brien & Sons, Ltd":  "o-brien-sons-ltd",
+		"Straße 42":              "strasse-42",
+		"東京":                   "name",
+		strings.Repeat("ab ", 40): strings.TrimRight(strings.Repeat("ab-", 20), "-"),
+	} {
+		if got := Slugify(name); got != want { t.Errorf("Slugify(%q) = %q, want %q", name, got, want) }
+		if got := Slugify(name); len(got) > MaxSlug-5 { t.Errorf("Slugify(%q) is %d bytes", name, len(got)) }
+	}
+}
+
+func TestParseKey(t *testing.T) {
+	for s, want := range map[string]string{
+		"zoe-smith":                            "zoe-smith",
+		"0190A6C2-7F2E-7B7A-8E1C-3F4D5E6F7A8B": "0190a6c2-7f2e-7b7a-8e1c-3f4d5e6f7a8b",
+		"Zoe":                                  "",
+		"zoe--smith":                           "",
+		"-zoe":                                 "",
+		"":                                     "",
+		strings.Repeat("a", MaxSlug+1):         "",
+	} {
+		got, valid := ParseKey(s)
+		if valid != (want != "") || (valid && got != want) { t.Errorf("ParseKey(%q) = %q, %v", s, got, valid) }
+	}
+}
+
+// racing hides the slugs taken from Names, as if they were taken between
+// its check and its write.
+type racing struct{ *store.MemoryNames }
+
+func (racing) SlugsTaken(context.Context, []string) (map[string]bool, error) { return map[string]bool{}, nil }
+
+func TestNames(t *testing.T) {
+	ctx := context.Background()
+	names := store.NewMemoryNames()
+	s := NewNames(names, names, Slug)
+	zoe := store.Name{Name: "Zoë Smith", Slug: "mine", UUID: "mine"}
+	if err := s.Create(ctx, &zoe); err != nil || zoe.Slug != "zoe-smith" || zoe.UUID != "" { t.Fatalf("created %+v, %v", zoe, err) }
+	if err := s.Create(ctx, &store.Name{Name: "Zoë Smith"}); !errors.Is(err, store.ErrDuplicate) { t.Fatalf("duplicate name: %v", err) }
+
+	suffixedRE := regexp.MustCompile(`^zoe-smith-[a-z0-9]{4}$`)
+	batch := []store.Name{{Name: "zoe smith"}, {Name: "Zoe-Smith"}, {Name: "507f1f77bcf86cd799439011"}}
+	errs, err := s.CreateMany(ctx, batch)
+	if err != nil || errs[0] != nil || errs[1] != nil || errs[2] != nil { t.Fatalf("batch: %v, %v", errs, err) }
+	if !suffixedRE.MatchString(batch[0].Slug) || !suffixedRE.MatchString(batch[1].Slug) || batch[0].Slug == batch[1].Slug { t.Errorf("slugs %q, %q", batch[0].Slug, batch[1].Slug) }
+	if !strings.HasPrefix(batch[2].Slug, "507f1f77bcf86cd799439011-") { t.Errorf("slug %q reads as an ObjectID", batch[2].Slug) }
+
+	raced := store.Name{Name: "ZOE SMITH!"}
+	if err := NewNames(names, racing{names}, Slug).Create(ctx, &raced); err != nil || !suffixedRE.MatchString(raced.Slug) { t.Fatalf("raced %+v, %v", raced, err) }
+
+	u := store.Name{Name: "Ulla", Slug: "mine"}
+	if err := NewNames(names, names, UUID).Create(ctx, &u); err != nil || u.Slug != "" { t.Fatalf("created %+v, %v", u, err) }
+	if parsed, err := uuid.Parse(u.UUID); err != nil || parsed.Version() != 7 { t.Errorf("UUID %q: %v", u.UUID, err) }
+	o := store.Name{Name: "Otto", Slug: "mine"}
+	if err := NewNames(names, names, ObjectID).Create(ctx, &o); err != nil || o.Slug != "" || o.UUID != "" { t.Fatalf("created %+v, %v", o, err) }
+}
@@ -0,0 +1,188 @@
+// Package jobs runs background jobs: work too long for a request, such as
+// a big import, that clients start and then poll for with GET /jobs/{id}.
+// Jobs are queued in a store.JobStore, so any instance's workers may run
+// them, and held under a lease the worker keeps renewing. A worker that
+// dies lets its lease lapse, and the job goes to another.
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"app/internal/auth"
+	"app/internal/requestid"
+	"app/internal/store"
+	"app/internal/tenant"
+)
+
+// Func does a job of one type. It reads j's Params and Input and sets its
+// Result and Output; an error fails the job with the error's text. ctx
+// carries the tenant, user and request ID of whoever started the job, and
+// ends if the worker loses the job or shuts down.
+type Func func(ctx context.Context, j *store.Job) error
+
+// Config tunes a Pool.
+type Config struct {
+	Workers     int           // jobs run at once; 0 runs none here, only queues them
+	Lease       time.Duration // how long a job is held between renewals
+	Poll        time.Duration // how often idle workers look for jobs queued elsewhere
+	Retention   time.Duration // how long finished jobs are kept
+	MaxAttempts int           // claims before a job whose worker keeps dying fails
+}
+
+// Pool is a JobStore whose EnqueueJob also wakes the workers, and those
+// workers.
+type Pool struct {
+	store.JobStore
+	cfg   Config
+	funcs map[string]Func
+	wake  chan struct{}
+}
+
+func New(s store.JobStore, cfg Config) *Pool {
+	return &Pool{JobStore: s, cfg: cfg, funcs: map[string]Func{}, wake: make(chan struct{}, 1)}
+}
+
+// Handle makes fn run the jobs of type typ. Call it before Run.
+func (p *Pool) Handle(typ string, fn Func) { p.funcs[typ] = fn }
+
+// Handles reports whether jobs of type typ can be run.
+func (p *Pool) Handles(typ string) bool { return p.funcs[typ] != nil }
+
+// EnqueueJob queues j and wakes an idle worker.
+func (p *Pool) EnqueueJob(ctx context.Context, j *store.Job) error {
+	if err := p.JobStore.EnqueueJob(ctx, j); err != nil { return err }
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Run runs the workers, and purges old jobs, until ctx ends. Jobs still
+// running then are given back to the queue.
+func (p *Pool) Run(ctx context.Context) error {
+	if p.cfg.Workers == 0 { return nil }
+	slog.Info("running background jobs", "workers", p.cfg.Workers)
+	var wg sync.WaitGroup
+	for range p.cfg.Workers {
+		wg.Add(1)
+		go func() { defer wg.Done(); p.work(ctx) }()
+	}
+	p.purge(ctx)
+	wg.Wait()
+	return nil
+}
+
+// work claims and runs jobs one after another, waiting for a wake-up or
+// the next poll when there are none.
+func (p *Pool) work(ctx context.Context) {
+	for ctx.Err() == nil {
+		j, err := p.ClaimJob(ctx, p.cfg.Lease)
+		if err == nil { p.run(ctx, j); continue }
+		if !errors.Is(err, store.ErrNotFound) && ctx.Err() == nil { slog.ErrorContext(ctx, "claiming a job", "err", err) }
+		select {
+		case <-ctx.Done():
+		case <-p.wake:
+		case <-time.After(p.cfg.Poll):
+		}
+	}
+}
+
+// purge removes the jobs finished more than Retention ago, hourly.
+func (p *Pool) purge(ctx context.Context) {
+	tick := time.NewTicker(time.Hour)
+	defer tick.Stop()
+	for {
+		if err := p.PurgeJobs(ctx, time.Now().Add(-p.cfg.Retention)); err != nil && ctx.Err() == nil {
+			slog.ErrorContext(ctx, "purging old jobs", "err", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick.C:
+		}
+	}
+}
+
+var errLeaseLost = errors.New("lost the job's lease")
+
+// run does job j and records how it went.
+func (p *Pool) run(ctx context.Context, j store.Job) {
+	log := slog.With("job", j.ID.Hex(), "type", j.Type, "tenant", j.Tenant)
+	fn := p.funcs[j.Type]
+	var err error
+	switch {
+	case j.Attempts > p.cfg.MaxAttempts:
+		err = fmt.Errorf("abandoned by %d workers in turn", j.Attempts-1)
+	case fn == nil:
+		err = fmt.Errorf("unknown job type %q", j.Type)
+	default:
+		err = p.do(ctx, fn, &j)
+	}
+
+	switch {
+	case errors.Is(err, errLeaseLost):
+		log.Warn("job left to whoever holds it now", "err", err)
+		return
+	case err == nil:
+		j.Status = store.JobSucceeded
+		log.Info("job done", "attempt", j.Attempts)
+	case ctx.Err() != nil:
+		j.Status = store.JobQueued
+		log.Info("job given back on shutdown")
+	default:
+		j.Status, j.Error = store.JobFailed, err.Error()
+		log.Warn("job failed", "err", err)
+	}
+	// Recorded even while shutting down, with a grace of its own.
+	fctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer cancel()
+	if err := p.FinishJob(fctx, j); err != nil { log.Error("recording the job's outcome", "err", err) }
+}
+
+// do calls fn with j under the identity of whoever started it, renewing
+// the lease meanwhile. Losing the lease cancels fn.
+func (p *Pool) do(ctx context.Context, fn Func, j *store.Job) (err error) {
+	jctx, cancel := context.WithCancel(tenant.NewContext(requestid.NewContext(ctx, j.RequestID), j.Tenant))
+	defer cancel()
+	if uid, err := primitive.ObjectIDFromHex(j.Actor); err == nil { jctx = auth.WithRole(auth.WithUserID(jctx, uid), j.ActorRole) }
+
+	// A renewal that merely fails is tried again at the next tick; only
+	// someone else's claim, or the job's purge, ends fn.
+	lost, held := make(chan error, 1), *j
+	go func() {
+		tick := time.NewTicker(p.cfg.Lease / 3)
+		defer tick.Stop()
+		for {
+			select {
+			case <-jctx.Done():
+				return
+			case <-tick.C:
+				err := p.RenewJob(jctx, held, p.cfg.Lease)
+				if errors.Is(err, store.ErrVersionMismatch) || errors.Is(err, store.ErrNotFound) {
+					lost <- fmt.Errorf("%w: %w", errLeaseLost, err)
+					cancel()
+					return
+				}
+				if err != nil && jctx.Err() == nil { slog.WarnContext(jctx, "renewing a job's lease", "job", held.ID.Hex(), "err", err) }
+			}
+		}
+	}()
+	defer func() {
+		if r := recover(); r != nil { err = fmt.Errorf("panic: %v", r) }
+	}()
+	err = fn(jctx, j)
+	select {
+	case lerr := <-lost:
+		return lerr
+	default:
+		return err
+	}
+}
@@ -0,0 +1,100 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"app/internal/store"
+	"app/internal/tenant"
+)
+
+func testConfig() Config {
+	return Config{Workers: 2, Lease: time.Second, Poll: 10 * time.Millisecond, Retention: time.Hour, MaxAttempts: 2}
+}
+
+// start runs p until the test ends.
+func start(t *testing.T, p *Pool) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- p.Run(ctx) }()
+	t.Cleanup(func() { cancel(); <-done })
+}
+
+// wait polls job j until it is done.
+func wait(t *testing.T, ctx context.Context, s store.JobStore, j store.Job) store.Job {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		got, err := s.Job(ctx, j.ID)
+		if err != nil { t.Fatal(err) }
+		if got.Status == store.JobSucceeded || got.Status == store.JobFailed { return got }
+	}
+	t.Fatalf("job %s never finished", j.ID.Hex())
+	return j
+}
+
+func TestPool(t *testing.T) {
+	s := store.NewMemoryJobs()
+	p := New(s, testConfig())
+	p.Handle("echo", func(ctx context.Context, j *store.Job) error {
+		j.Result = []byte(`"` + tenant.FromContext(ctx) + `"`)
+		return nil
+	})
+	p.Handle("fail", func(context.Context, *store.Job) error { return errors.New("no luck") })
+	p.Handle("panic", func(context.Context, *store.Job) error { panic("oops") })
+	start(t, p)
+
+	ctx := tenant.NewContext(context.Background(), "team-a")
+	for _, tc := range []struct{ typ, status, result, err string }{
+		{"echo", store.JobSucceeded, `"team-a"`, ""},
+		{"fail", store.JobFailed, "", "no luck"},
+		{"panic", store.JobFailed, "", "panic: oops"},
+		{"resize", store.JobFailed, "", `unknown job type "resize"`},
+	} {
+		j := store.Job{Type: tc.typ}
+		if err := p.EnqueueJob(ctx, &j); err != nil { t.Fatal(err) }
+		got := wait(t, ctx, s, j)
+		if got.Status != tc.status || string(got.Result) != tc.result || got.Error != tc.err { t.Errorf("%s: %+v", tc.typ, got) }
+	}
+}
+
+func TestPoolShutdown(t *testing.T) {
+	s := store.NewMemoryJobs()
+	p := New(s, testConfig())
+	started := make(chan struct{})
+	p.Handle("slow", func(ctx context.Context, _ *store.Job) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- p.Run(ctx) }()
+
+	j := store.Job{Type: "slow"}
+	if err := p.EnqueueJob(context.Background(), &j); err != nil { t.Fatal(err) }
+	<-started
+	cancel()
+	if err := <-done; err != nil { t.Fatal(err) }
+
+	// The job is back in the queue, for another worker to pick up.
+	if got, err := s.Job(context.Background(), j.ID); err != nil || got.Status != store.JobQueued { t.Fatalf("after shutdown: %+v, %v", got, err) }
+}
+
+func TestPoolAbandoned(t *testing.T) {
+	s := store.NewMemoryJobs()
+	ctx := context.Background()
+	j := store.Job{Type: "echo"}
+	if err := s.EnqueueJob(ctx, &j); err != nil { t.Fatal(err) }
+	// Two workers died holding it.
+	for range 2 {
+		if _, err := s.ClaimJob(ctx, -time.Second); err != nil { t.Fatal(err) }
+	}
+
+	p := New(s, testConfig())
+	p.Handle("echo", func(context.Context, *store.Job) error { t.Error("ran an abandoned job"); return nil })
+	start(t, p)
+	if got := wait(t, ctx, s, j); got.Status != store.JobFailed || got.Error != "abandoned by 2 workers in turn" { t.Fatalf("abandoned: %+v", got) }
+}
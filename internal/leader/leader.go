@@ -0,0 +1,192 @@
+// Package leader elects, among the instances sharing a store, the one that
+// runs the background work only one of them should, such as the cleanup
+// and the outbox: the instance holding a lease in a store.LeaseStore. The
+// leader renews it every Renew; should it stop, say because it died, the
+// lease lapses after TTL and the next instance to try takes it over. A
+// leader shutting down gives it up, for another to take at once.
+//
+// A leader that can't renew its lease stops the work once the lease
+// lapses, as far as its own clock can tell: it can't be another's before
+// then, so no two instances run the work at once as long as their clocks
+// run at the same rate.
+package leader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"app/internal/metrics"
+	"app/internal/store"
+)
+
+// LeaseName is the lease the leader holds.
+const LeaseName = "leader"
+
+// Config tunes an Elector.
+type Config struct {
+	ID    string        // this instance's, told apart from the others'; default host name and process ID
+	TTL   time.Duration // how long the lease lasts unrenewed: how long the work may go undone when a leader dies
+	Renew time.Duration // how often the leader renews the lease, and the others try to take it; well under TTL
+}
+
+// Status is what an Elector knows of the leadership.
+type Status struct {
+	ID        string       `json:"id"` // this instance's
+	Leader    bool         `json:"leader"`
+	Lease     *store.Lease `json:"lease,omitempty"` // as last seen; absent until the store first answers
+	Tasks     []string     `json:"tasks"`           // the work the leader runs
+	CheckedAt time.Time    `json:"checked_at,omitzero"`
+	Error     string       `json:"error,omitempty"` // of the last try to take or renew the lease
+}
+
+// Elector campaigns for the lease and, while it holds it, runs its tasks.
+type Elector struct {
+	leases store.LeaseStore
+	cfg    Config
+	tasks  []task
+
+	mu     sync.Mutex
+	status Status
+}
+
+type task struct {
+	name string
+	run  func(context.Context) error
+}
+
+func New(leases store.LeaseStore, cfg Config) *Elector {
+	if cfg.ID == "" {
+		host, _ := os.Hostname()
+		cfg.ID = fmt.Sprintf("%s-%d", host, os.Getpid())
+	}
+	return &Elector{leases: leases, cfg: cfg, status: Status{ID: cfg.ID, Tasks: []string{}}}
+}
+
+// Lead makes run one of the tasks of the leader, run until ctx ends: when
+// the instance shuts down or stops leading. Call it before Run.
+func (e *Elector) Lead(name string, run func(context.Context) error) {
+	e.tasks = append(e.tasks, task{name, run})
+	e.status.Tasks = append(e.status.Tasks, name)
+}
+
+// Status returns what e knows of the leadership.
+func (e *Elector) Status() Status {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	s := e.status
+	s.Tasks = slices.Clone(s.Tasks)
+	if s.Lease != nil { l := *s.Lease; s.Lease = &l }
+	return s
+}
+
+// Run campaigns, and runs the tasks while leading, until ctx ends. It then
+// stops them and gives the lease up, and returns the errors they failed
+// with.
+func (e *Elector) Run(ctx context.Context) error {
+	slog.Info("campaigning to lead the background work", "id", e.cfg.ID, "tasks", e.status.Tasks, "ttl", e.cfg.TTL)
+	var (
+		leading *term            // nil while following
+		lapse   <-chan time.Time // when the lease lapses unless renewed; nil while following
+		failed  []error
+	)
+	stepDown := func(why string) {
+		failed = append(failed, leading.stop()...)
+		leading, lapse = nil, nil
+		metrics.Leading(false)
+		e.setLeader(false)
+		slog.Warn("stopped leading the background work", "id", e.cfg.ID, "why", why)
+	}
+	tick := time.NewTicker(e.cfg.Renew)
+	defer tick.Stop()
+	for {
+		asked := time.Now()
+		l, err := e.leases.AcquireLease(ctx, LeaseName, e.cfg.ID, e.cfg.TTL)
+		if ctx.Err() != nil { break }
+		switch {
+		case err != nil:
+			slog.ErrorContext(ctx, "taking or renewing the leader's lease", "err", err)
+		case l.Holder == e.cfg.ID:
+			lapse = time.After(time.Until(asked.Add(e.cfg.TTL)))
+			if leading == nil {
+				leading = e.start(ctx)
+				metrics.Leading(true)
+				slog.Info("leading the background work", "id", e.cfg.ID, "term", l.Term)
+			}
+		case leading != nil:
+			stepDown("the lease is " + l.Holder + "'s")
+		}
+		e.observe(l, err, leading != nil)
+		select {
+		case <-ctx.Done():
+		case <-tick.C:
+			continue
+		case <-lapse:
+			stepDown("the lease lapsed unrenewed")
+			continue
+		}
+		break
+	}
+	if leading != nil {
+		failed = append(failed, leading.stop()...)
+		metrics.Leading(false)
+		e.setLeader(false)
+		releaseCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+		defer cancel()
+		if err := e.leases.ReleaseLease(releaseCtx, LeaseName, e.cfg.ID); err != nil { slog.Error("giving up the leader's lease", "err", err) }
+	}
+	return errors.Join(failed...)
+}
+
+// term is the tasks running while leading.
+type term struct {
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	mu     sync.Mutex
+	failed []error
+}
+
+// start runs the tasks, each until ctx ends or the term is stopped.
+func (e *Elector) start(ctx context.Context) *term {
+	ctx, cancel := context.WithCancel(ctx)
+	t := &term{cancel: cancel}
+	for _, tk := range e.tasks {
+		t.wg.Add(1)
+		go func() {
+			defer t.wg.Done()
+			if err := tk.run(ctx); err != nil {
+				slog.Error("a leader's task failed", "task", tk.name, "err", err)
+				t.mu.Lock()
+				t.failed = append(t.failed, fmt.Errorf("%s: %w", tk.name, err))
+				t.mu.Unlock()
+			}
+		}()
+	}
+	return t
+}
+
+// stop ends the tasks, waits for them and returns their errors.
+func (t *term) stop() []error {
+	t.cancel()
+	t.wg.Wait()
+	return t.failed
+}
+
+func (e *Elector) observe(l store.Lease, err error, leader bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.status.Leader, e.status.CheckedAt, e.status.Error = leader, time.Now().UTC(), ""
+	if err != nil { e.status.Error = err.Error(); return }
+	e.status.Lease = &l
+}
+
+func (e *Elector) setLeader(leader bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.status.Leader = leader
+}
@@ -0,0 +1,101 @@
+package leader
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"app/internal/store"
+)
+
+// running counts the instances running a task at once, and the most that
+// ever did.
+type running struct{ now, most atomic.Int32 }
+
+func (r *running) run(ctx context.Context) error {
+	n := r.now.Add(1)
+	for m := r.most.Load(); n > m && !r.most.CompareAndSwap(m, n); m = r.most.Load() {
+	}
+	<-ctx.Done()
+	r.now.Add(-1)
+	return nil
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(2 * time.Second); !cond(); time.Sleep(5 * time.Millisecond) {
+		if time.Now().After(deadline) { t.Fatalf("timed out waiting for %s", what) }
+	}
+}
+
+func TestElector(t *testing.T) {
+	leases := store.NewMemoryLeases()
+	var r running
+	cfg := Config{TTL: 200 * time.Millisecond, Renew: 20 * time.Millisecond}
+	electors := make([]*Elector, 3)
+	stops := make([]context.CancelFunc, 3)
+	dones := make([]chan error, 3)
+	for i := range electors {
+		cfg.ID = string(rune('a' + i))
+		electors[i] = New(leases, cfg)
+		electors[i].Lead("work", r.run)
+		ctx, cancel := context.WithCancel(context.Background())
+		stops[i], dones[i] = cancel, make(chan error, 1)
+		go func() { dones[i] <- electors[i].Run(ctx) }()
+	}
+	leader := func() int {
+		for i, e := range electors {
+			if e.Status().Leader { return i }
+		}
+		return -1
+	}
+	waitFor(t, "a leader", func() bool {
+		for _, e := range electors {
+			if e.Status().Lease == nil { return false }
+		}
+		return leader() >= 0 && r.now.Load() == 1
+	})
+	first := leader()
+	if s := electors[(first+1)%3].Status(); s.Leader || s.Lease == nil || s.Lease.Holder != electors[first].cfg.ID || s.Tasks[0] != "work" { t.Fatalf("follower's status %+v", s) }
+
+	// The leader shutting down gives the lease up, to another at once.
+	stops[first]()
+	if err := <-dones[first]; err != nil { t.Fatal(err) }
+	waitFor(t, "another leader", func() bool { l := leader(); return l >= 0 && l != first && r.now.Load() == 1 })
+	if s := electors[leader()].Status(); s.Lease.Term != 2 { t.Fatalf("new leader's status %+v", s) }
+	for i := range electors {
+		if i != first { stops[i](); <-dones[i] }
+	}
+	if r.most.Load() != 1 || r.now.Load() != 0 { t.Fatalf("%d ran at once, %d still run", r.most.Load(), r.now.Load()) }
+}
+
+// failingLeases fails every renewal after the first acquisition.
+type failingLeases struct {
+	store.LeaseStore
+	calls atomic.Int32
+}
+
+func (f *failingLeases) AcquireLease(ctx context.Context, name, holder string, ttl time.Duration) (store.Lease, error) {
+	if f.calls.Add(1) > 1 { return store.Lease{}, errors.New("store down") }
+	return f.LeaseStore.AcquireLease(ctx, name, holder, ttl)
+}
+
+func TestElectorLapse(t *testing.T) {
+	var r running
+	leases := &failingLeases{LeaseStore: store.NewMemoryLeases()}
+	e := New(leases, Config{ID: "a", TTL: 300 * time.Millisecond, Renew: 10 * time.Millisecond})
+	e.Lead("work", r.run)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- e.Run(ctx) }()
+	waitFor(t, "leading", func() bool { return e.Status().Leader })
+	// Renewals fail, but the lease is still its own until it lapses.
+	waitFor(t, "failed renewals", func() bool { return leases.calls.Load() > 3 })
+	if s := e.Status(); !s.Leader || r.now.Load() != 1 { t.Fatalf("status %+v", s) }
+	waitFor(t, "the lease to lapse", func() bool { return r.now.Load() == 0 && !e.Status().Leader })
+	if s := e.Status(); s.Error != "store down" { t.Fatalf("status %+v", s) }
+	cancel()
+	if err := <-done; err != nil { t.Fatal(err) }
+}
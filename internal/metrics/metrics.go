@@ -0,0 +1,187 @@
+// Package metrics defines the Prometheus series the service exports at
+// /metrics. Everything is registered with the default registry, so the Go
+// runtime and process collectors come along for free.
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.mongodb.org/mongo-driver/event"
+)
+
+var (
+	requests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "HTTP requests by route pattern, method and status code.",
+	}, []string{"route", "method", "status"})
+
+	requestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "HTTP request latency by route pattern and method.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"route", "method"})
+
+	inFlight = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "http_requests_in_flight",
+		Help: "HTTP requests currently being served.",
+	})
+
+	mongoDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "mongo_command_duration_seconds",
+		Help:    "MongoDB command latency by command name, failed commands included.",
+		Buckets: []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"command"})
+
+	mongoErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "mongo_command_errors_total",
+		Help: "MongoDB commands that failed, by command name.",
+	}, []string{"command"})
+
+	cacheLookups = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "cache_lookups_total",
+		Help: "Name cache lookups by result, hit or miss.",
+	}, []string{"result"})
+
+	storeRetries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "store_retries_total",
+		Help: "Store operations retried after a transient error, by operation.",
+	}, []string{"op"})
+
+	namesPurged = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "names_purged_total",
+		Help: "Names removed by the cleanup, by reason: expired, or deleted long enough ago.",
+	}, []string{"reason"})
+
+	webhookAttempts = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "webhook_attempts_total",
+		Help: "POSTs of webhook deliveries, by outcome: delivered, retry or failed.",
+	}, []string{"outcome"})
+
+	busMessages = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "bus_messages_total",
+		Help: "Name changes published to the message bus, by outcome: ok or failed.",
+	}, []string{"outcome"})
+
+	outboxEvents = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "outbox_events_total",
+		Help: "Outbox events handed to the webhooks and the bus, by outcome: published or failed.",
+	}, []string{"outcome"})
+
+	quotaRejections = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "quota_rejections_total",
+		Help: "Requests refused because their API key used up a quota, by quota.",
+	}, []string{"quota"})
+
+	shadowWrites = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "shadow_writes_total",
+		Help: "Writes to the names copied to the shadow store, by operation and outcome: ok or failed.",
+	}, []string{"op", "outcome"})
+
+	chaosFaults = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "chaos_faults_total",
+		Help: "Faults injected on purpose (see CHAOS_* and X-Chaos), by fault: latency, error or timeout.",
+	}, []string{"fault"})
+
+	prewriteRejections = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "prewrite_rejections_total",
+		Help: "Name writes refused by a check hook of PREWRITE_HOOKS_FILE, by hook.",
+	}, []string{"hook"})
+
+	leading = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "leader",
+		Help: "1 while this instance is the elected leader running the background work (see LEADER_ELECTION), 0 otherwise.",
+	})
+)
+
+// Handler serves the metrics in the Prometheus text format.
+func Handler() http.Handler { return promhttp.Handler() }
+
+// Middleware records every request. route maps a request to the pattern it
+// matched ("" if none), which keeps label cardinality bounded no matter
+// which paths clients make up.
+func Middleware(route func(*http.Request) string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		inFlight.Inc()
+		defer inFlight.Dec()
+
+		start := time.Now()
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(sw, r)
+
+		rt := route(r)
+		if rt == "" { rt = "unmatched" }
+		requests.WithLabelValues(rt, r.Method, strconv.Itoa(sw.status)).Inc()
+		requestDuration.WithLabelValues(rt, r.Method).Observe(time.Since(start).Seconds())
+	})
+}
+
+type statusWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (s *statusWriter) WriteHeader(code int) {
+	if !s.wroteHeader { s.status, s.wroteHeader = code, true }
+	s.ResponseWriter.WriteHeader(code)
+}
+
+func (s *statusWriter) Unwrap() http.ResponseWriter { return s.ResponseWriter }
+
+// CommandMonitor times every MongoDB command; pass it to store.MongoConfig.
+func CommandMonitor() *event.CommandMonitor {
+	return &event.CommandMonitor{
+		Succeeded: func(_ context.Context, e *event.CommandSucceededEvent) {
+			mongoDuration.WithLabelValues(e.CommandName).Observe(e.Duration.Seconds())
+		},
+		Failed: func(_ context.Context, e *event.CommandFailedEvent) {
+			mongoDuration.WithLabelValues(e.CommandName).Observe(e.Duration.Seconds())
+			mongoErrors.WithLabelValues(e.CommandName).Inc()
+		},
+	}
+}
+
+// CacheLookup counts a read answered from the cache (hit) or by the store.
+func CacheLookup(hit bool) {
+	if hit { cacheLookups.WithLabelValues("hit").Inc(); return }
+	cacheLookups.WithLabelValues("miss").Inc()
+}
+
+// StoreRetry counts one more attempt at the store operation op.
+func StoreRetry(op string) { storeRetries.WithLabelValues(op).Inc() }
+
+// NamePurged counts a name the cleanup removed, for reason.
+func NamePurged(reason string) { namesPurged.WithLabelValues(reason).Inc() }
+
+// WebhookAttempt counts a POST of a webhook delivery, by its outcome.
+func WebhookAttempt(outcome string) { webhookAttempts.WithLabelValues(outcome).Inc() }
+
+// BusPublished counts n messages published to the bus, by outcome.
+func BusPublished(outcome string, n int) { busMessages.WithLabelValues(outcome).Add(float64(n)) }
+
+// OutboxPublished counts an outbox event handed on, by outcome.
+func OutboxPublished(outcome string) { outboxEvents.WithLabelValues(outcome).Inc() }
+
+// QuotaExceeded counts a request refused for using up quota.
+func QuotaExceeded(quota string) { quotaRejections.WithLabelValues(quota).Inc() }
+
+// ShadowWrite counts a write copied to the shadow store, by outcome.
+func ShadowWrite(op, outcome string) { shadowWrites.WithLabelValues(op, outcome).Inc() }
+
+// ChaosFault counts a fault injected on purpose.
+func ChaosFault(fault string) { chaosFaults.WithLabelValues(fault).Inc() }
+
+// PrewriteRejected counts a name write refused by a check hook.
+func PrewriteRejected(hook string) { prewriteRejections.WithLabelValues(hook).Inc() }
+
+// Leading records whether this instance leads.
+func Leading(yes bool) {
+	if yes { leading.Set(1); return }
+	leading.Set(0)
+}
@@ -0,0 +1,137 @@
+// Package normalize gives every name a canonical form, stored next to it as
+// name_normalized, so that a name is found however it was typed: "  aLice "
+// and "Alice" are both "Alice". The form is the name
+//
+//   - in Unicode NFC, so that "é" typed as one code point or as "e" and a
+//     combining accent is the same;
+//   - without diacritics, if the rules say so: "José" is "Jose";
+//   - trimmed, with its runs of whitespace made one space;
+//   - title-cased: the first letter of each word, and of each part of one
+//     after a hyphen or an apostrophe, upper-case and the others lower,
+//     "o'brien-SMITH" being "O'Brien-Smith"; but the particles of the
+//     rules, such as "van" or "de", stay lower-case past the first word.
+//
+// The raw name is kept as it was written; the form is only for matching.
+package normalize
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"unicode"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+
+	"app/internal/store"
+)
+
+// DefaultLowerWords are the particles of names kept lower-case by default.
+var DefaultLowerWords = []string{"da", "das", "de", "del", "della", "der", "di", "do", "dos", "du", "la", "le", "van", "von", "y"}
+
+// Rules are the choices the canonical form leaves to the operator.
+type Rules struct {
+	StripDiacritics bool
+	LowerWords      []string // in lower case
+}
+
+// Name is the canonical form of name under r.
+func (r Rules) Name(name string) string {
+	name = norm.NFC.String(name)
+	if r.StripDiacritics {
+		if s, _, err := transform.String(transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC), name); err == nil { name = s }
+	}
+	words := strings.Fields(name)
+	for i, w := range words {
+		w = strings.ToLower(w)
+		if i > 0 && slices.Contains(r.LowerWords, w) { words[i] = w; continue }
+		words[i] = title(w)
+	}
+	return norm.NFC.String(strings.Join(words, " "))
+}
+
+// title upper-cases the letters of w that start it or follow a hyphen or
+// an apostrophe.
+func title(w string) string {
+	var b strings.Builder
+	start := true
+	for _, r := range w {
+		if start && unicode.IsLetter(r) { r = unicode.ToTitle(r) }
+		b.WriteRune(r)
+		start = r == '-' || r == '\'' || r == '’'
+	}
+	return b.String()
+}
+
+// Names wraps a NameStore and gives every name it writes the canonical form
+// of its name, overwriting whatever the client sent. Lists matching a name
+// and prefix searches have their query put in the same form, so they find
+// names however either was typed.
+type Names struct {
+	store.NameStore
+	forms store.NormalizedStore
+	rules Rules
+}
+
+// NewNames normalizes by rules; forms is the store beneath, undecorated,
+// for Renormalize.
+func NewNames(s store.NameStore, forms store.NormalizedStore, rules Rules) *Names {
+	return &Names{NameStore: s, forms: forms, rules: rules}
+}
+
+func (n *Names) Create(ctx context.Context, name *store.Name) error {
+	name.NameNormalized = n.rules.Name(name.Name)
+	return n.NameStore.Create(ctx, name)
+}
+
+func (n *Names) CreateIfAbsent(ctx context.Context, name *store.Name) (bool, error) {
+	name.NameNormalized = n.rules.Name(name.Name)
+	return n.NameStore.CreateIfAbsent(ctx, name)
+}
+
+func (n *Names) Upsert(ctx context.Context, name store.Name, ifVersion int64) (*store.Name, store.Name, error) {
+	name.NameNormalized = n.rules.Name(name.Name)
+	return n.NameStore.Upsert(ctx, name, ifVersion)
+}
+
+func (n *Names) Update(ctx context.Context, id primitive.ObjectID, name store.Name, ifVersion int64) (store.Name, error) {
+	name.NameNormalized = n.rules.Name(name.Name)
+	return n.NameStore.Update(ctx, id, name, ifVersion)
+}
+
+func (n *Names) Patch(ctx context.Context, id primitive.ObjectID, p store.NamePatch, ifVersion int64) (store.Name, error) {
+	p.NameNormalized = nil
+	if p.Name != nil { form := n.rules.Name(*p.Name); p.NameNormalized = &form }
+	return n.NameStore.Patch(ctx, id, p, ifVersion)
+}
+
+func (n *Names) CreateMany(ctx context.Context, ns []store.Name) ([]error, error) {
+	n.all(ns)
+	return n.NameStore.CreateMany(ctx, ns)
+}
+
+func (n *Names) InsertMany(ctx context.Context, ns []store.Name) ([]error, error) {
+	n.all(ns)
+	return n.NameStore.InsertMany(ctx, ns)
+}
+
+func (n *Names) all(ns []store.Name) {
+	for i := range ns { ns[i].NameNormalized = n.rules.Name(ns[i].Name) }
+}
+
+func (n *Names) List(ctx context.Context, opts store.ListOptions) (store.Page, error) {
+	if opts.Match != "" { opts.Match = n.rules.Name(opts.Match) }
+	return n.NameStore.List(ctx, opts)
+}
+
+func (n *Names) Each(ctx context.Context, opts store.ListOptions, fn func(store.Name) error) error {
+	if opts.Match != "" { opts.Match = n.rules.Name(opts.Match) }
+	return n.NameStore.Each(ctx, opts, fn)
+}
+
+func (n *Names) Search(ctx context.Context, opts store.SearchOptions) ([]store.SearchHit, error) {
+	if opts.Mode == store.SearchPrefix { opts.Query = n.rules.Name(opts.Query) }
+	return n.NameStore.Search(ctx, opts)
+}
@@ -0,0 +1,72 @@
+package normalize
+
+import (
+	"context"
+	"testing"
+
+	"app/internal/store"
+)
+
+func TestName(t *testing.T) {
+	plain := Rules{LowerWords: DefaultLowerWords}
+	strip := Rules{StripDiacritics: true, LowerWords: DefaultLowerWords}
+	for _, tc := range []struct {
+		rules     Rules
+		in, want string
+	}{
+		{plain, "  aLice ", "Alice"},
+		{plain, "Alice", "Alice"},
+		{plain, "ada\t\n  LOVELACE", "Ada Lovelace"},
+		{plain, "o'brien-SMITH", "O'Brien-Smith"},
+		{plain, "d’angelo", "D’Angelo"},
+		{plain, "LUDWIG VAN BEETHOVEN", "Ludwig van Beethoven"},
+		{plain, "van morrison", "Van Morrison"},
+		{plain, "3rd street", "3rd Street"},
+		{plain, "Jose\u0301", "Jos\u00e9"}, // decomposed, made NFC
+		{plain, "josé", "José"},
+		{strip, "José  Núñez", "Jose Nunez"},
+		{strip, "ǆemal", "ǅemal"}, // the title case of a digraph
+		{Rules{}, "ludwig van beethoven", "Ludwig Van Beethoven"},
+		{plain, "", ""},
+	} {
+		if got := tc.rules.Name(tc.in); got != tc.want { t.Errorf("%+v.Name(%q) = %q, want %q", tc.rules, tc.in, got, tc.want) }
+	}
+}
+
+func TestNames(t *testing.T) {
+	ctx := context.Background()
+	mem := store.NewMemoryNames()
+	s := NewNames(mem, mem, Rules{LowerWords: DefaultLowerWords})
+
+	alice := store.Name{Name: "aLice", NameNormalized: "sent by the client"}
+	if err := s.Create(ctx, &alice); err != nil || alice.NameNormalized != "Alice" { t.Fatalf("created %+v, %v", alice, err) }
+	batch := []store.Name{{Name: "bob  MARLEY"}, {Name: "ludwig van beethoven"}}
+	if _, err := s.CreateMany(ctx, batch); err != nil || batch[0].NameNormalized != "Bob Marley" || batch[1].NameNormalized != "Ludwig van Beethoven" { t.Fatalf("batch %+v, %v", batch, err) }
+
+	page, err := s.List(ctx, store.ListOptions{Limit: 10, Match: "  ALICE "})
+	if err != nil || len(page.Items) != 1 || page.Items[0].ID != alice.ID { t.Fatalf("match: %+v, %v", page, err) }
+	hits, err := s.Search(ctx, store.SearchOptions{Query: "bob   mar", Mode: store.SearchPrefix})
+	if err != nil || len(hits) != 1 || hits[0].Name.Name != "bob  MARLEY" { t.Fatalf("prefix: %+v, %v", hits, err) }
+
+	name := "alice  COOPER"
+	patched, err := s.Patch(ctx, alice.ID, store.NamePatch{Name: &name}, store.AnyVersion)
+	if err != nil || patched.NameNormalized != "Alice Cooper" { t.Fatalf("patched %+v, %v", patched, err) }
+	tags := []string{"x"}
+	if patched, err = s.Patch(ctx, alice.ID, store.NamePatch{Tags: &tags}, store.AnyVersion); err != nil || patched.NameNormalized != "Alice Cooper" { t.Fatalf("patched tags %+v, %v", patched, err) }
+	updated, err := s.Update(ctx, alice.ID, store.Name{Name: "ALICE"}, store.AnyVersion)
+	if err != nil || updated.NameNormalized != "Alice" { t.Fatalf("updated %+v, %v", updated, err) }
+
+	// Names written beneath, as before normalization, are matched by their
+	// name until Renormalize gives them a form.
+	old := store.Name{Name: "José Núñez"}
+	if err := mem.Create(ctx, &old); err != nil { t.Fatal(err) }
+	if page, _ := s.List(ctx, store.ListOptions{Limit: 10, Match: " josé  núñez"}); len(page.Items) != 1 { t.Fatalf("by name: %+v", page.Items) }
+
+	strip := NewNames(mem, mem, Rules{StripDiacritics: true, LowerWords: DefaultLowerWords})
+	res, err := strip.Renormalize(ctx)
+	if err != nil || res.Checked != 4 || res.Updated != 1 { t.Fatalf("renormalized %+v, %v", res, err) } // only José's form changes
+	got, _ := mem.Get(ctx, old.ID)
+	if got.NameNormalized != "Jose Nunez" || got.Version != old.Version { t.Fatalf("renormalized to %+v", got) }
+	if page, _ := strip.List(ctx, store.ListOptions{Limit: 10, Match: "JOSE NUNEZ"}); len(page.Items) != 1 { t.Fatalf("after: %+v", page.Items) }
+	if res, err := strip.Renormalize(ctx); err != nil || res.Updated != 0 { t.Fatalf("again %+v, %v", res, err) }
+}
@@ -0,0 +1,44 @@
+package normalize
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"app/internal/store"
+)
+
+// batchSize is how many names Renormalize reads, and rewrites, at a time.
+const batchSize = 500
+
+// Result is what Renormalize did.
+type Result struct {
+	Checked int `json:"checked"` // names, soft-deleted ones included
+	Updated int `json:"updated"` // of them, those whose form was missing or made by other rules
+}
+
+// Renormalize gives the names of the tenant in ctx, soft-deleted ones
+// included, the form the rules make of them, where theirs differs: names
+// written before normalization or before the rules last changed. It reads
+// them a page at a time, rather than holding a cursor open while it
+// writes, and writes beneath every other layer: it isn't a change to the
+// names, so their versions stay and no one is told. A read cache may serve
+// the old forms until its entries expire.
+func (n *Names) Renormalize(ctx context.Context) (Result, error) {
+	var r Result
+	opts := store.ListOptions{Limit: batchSize, IncludeDeleted: true}
+	for {
+		page, err := n.NameStore.List(ctx, opts)
+		if err != nil { return r, err }
+		forms := map[primitive.ObjectID]string{}
+		for _, name := range page.Items {
+			if form := n.rules.Name(name.Name); form != name.NameNormalized { forms[name.ID] = form }
+		}
+		if len(forms) > 0 {
+			if err := n.forms.SetNormalized(ctx, forms); err != nil { return r, err }
+		}
+		r.Checked, r.Updated = r.Checked+len(page.Items), r.Updated+len(forms)
+		if page.Next == "" { return r, nil }
+		if opts.After, err = store.DecodeCursor(page.Next); err != nil { return r, err }
+	}
+}
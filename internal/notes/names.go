@@ -0,0 +1,82 @@
+// Package notes keeps names and the notes about them consistent: a name
+// that still has notes is either never removed, or removed with its notes,
+// whichever API removes it.
+package notes
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"app/internal/store"
+)
+
+// What happens to a name's notes when it is hard-deleted (NOTES_ON_DELETE).
+// Soft deletes leave them alone, so that a restored name has them back.
+const (
+	Block   = "block"   // the delete fails with store.ErrHasNotes
+	Cascade = "cascade" // the notes are deleted after the name
+)
+
+// Names wraps a NameStore and applies an on-delete policy to the notes of
+// the names it hard-deletes. Other methods pass straight through.
+//
+// Neither policy runs in a transaction with the delete: a note written
+// between the check, or the delete, and the removal of the notes is left
+// behind, pointing at a name that is gone.
+type Names struct {
+	store.NameStore
+	notes   store.NoteStore
+	cascade bool
+}
+
+func NewNames(s store.NameStore, notes store.NoteStore, onDelete string) *Names {
+	return &Names{NameStore: s, notes: notes, cascade: onDelete == Cascade}
+}
+
+func (n *Names) HardDelete(ctx context.Context, id primitive.ObjectID, ifVersion int64) error {
+	if err := n.check(ctx, id); err != nil { return err }
+	if err := n.NameStore.HardDelete(ctx, id, ifVersion); err != nil { return err }
+	n.removeNotes(ctx, id)
+	return nil
+}
+
+// DeleteMany refuses the whole batch under Block if any of its names has
+// notes, so that nothing is half done.
+func (n *Names) DeleteMany(ctx context.Context, ids []primitive.ObjectID, hard bool) (map[primitive.ObjectID]bool, error) {
+	if !hard { return n.NameStore.DeleteMany(ctx, ids, hard) }
+	if err := n.check(ctx, ids...); err != nil { return nil, err }
+	existed, err := n.NameStore.DeleteMany(ctx, ids, hard)
+	if err != nil { return existed, err }
+	var removed []primitive.ObjectID
+	for id, ok := range existed {
+		if ok { removed = append(removed, id) }
+	}
+	n.removeNotes(ctx, removed...)
+	return existed, nil
+}
+
+// check fails with store.ErrHasNotes under Block if any of ids has notes.
+func (n *Names) check(ctx context.Context, ids ...primitive.ObjectID) error {
+	if n.cascade { return nil }
+	has, err := n.notes.HasNotes(ctx, ids...)
+	if err != nil { return err }
+	if has { return store.ErrHasNotes }
+	return nil
+}
+
+// removeNotes deletes the notes of names that are gone, once their
+// transaction, if any, has committed. The names are gone, so a failure is
+// logged rather than returned.
+func (n *Names) removeNotes(ctx context.Context, ids ...primitive.ObjectID) {
+	if !n.cascade || len(ids) == 0 { return }
+	store.AfterCommit(ctx, func(ctx context.Context) {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+		defer cancel()
+		if err := n.notes.DeleteNotes(ctx, ids...); err != nil {
+			slog.ErrorContext(ctx, "deleting the notes of removed names", "names", len(ids), "err", err)
+		}
+	})
+}
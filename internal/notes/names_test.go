@@ -0,0 +1,43 @@
+package notes
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"app/internal/store"
+)
+
+func TestNames(t *testing.T) {
+	ctx := context.Background()
+	for _, policy := range []string{Block, Cascade} {
+		names := store.NewMemoryNames()
+		notes := store.NewMemoryNotes(names)
+		s := NewNames(names, notes, policy)
+		a, b := store.Name{Name: "alice"}, store.Name{Name: "bob"}
+		_ = s.Create(ctx, &a)
+		_ = s.Create(ctx, &b)
+		_ = notes.CreateNote(ctx, &store.Note{NameID: a.ID, Body: "hi"})
+
+		if err := s.SoftDelete(ctx, a.ID, store.AnyVersion); err != nil { t.Fatalf("%s: soft delete: %v", policy, err) }
+		if has, _ := notes.HasNotes(ctx, a.ID); !has { t.Fatalf("%s: soft delete took the notes", policy) }
+		if _, err := s.Restore(ctx, a.ID); err != nil { t.Fatal(err) }
+
+		err := s.HardDelete(ctx, a.ID, store.AnyVersion)
+		_, manyErr := s.DeleteMany(ctx, []primitive.ObjectID{a.ID, b.ID}, true)
+		has, _ := notes.HasNotes(ctx, a.ID)
+		_, getErr := names.Get(ctx, b.ID)
+		switch policy {
+		case Block:
+			if !errors.Is(err, store.ErrHasNotes) || !errors.Is(manyErr, store.ErrHasNotes) || !has || getErr != nil {
+				t.Errorf("block: %v, %v, notes kept %v, bob %v", err, manyErr, has, getErr)
+			}
+		case Cascade:
+			if err != nil || manyErr != nil || has || !errors.Is(getErr, store.ErrNotFound) {
+				t.Errorf("cascade: %v, %v, notes kept %v, bob %v", err, manyErr, has, getErr)
+			}
+		}
+	}
+}
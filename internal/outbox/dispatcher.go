@@ -0,0 +1,116 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"app/internal/metrics"
+	"app/internal/requestid"
+	"app/internal/store"
+	"app/internal/tenant"
+)
+
+// Sink takes an event from the outbox, such as the webhooks' queue or the
+// message bus. An error leaves the event to be handed on again.
+type Sink func(ctx context.Context, e store.OutboxEvent) error
+
+// Config tunes a Dispatcher.
+type Config struct {
+	Poll      time.Duration // how often an idle dispatcher looks for events
+	Lease     time.Duration // how long a claimed event is held, to be handed on, before it can be claimed again
+	Retention time.Duration // how long published events are kept
+}
+
+// Dispatcher is an OutboxStore whose AddToOutbox also wakes it once the
+// events are committed, and the loop that hands the events to its sinks.
+//
+// It hands them on one at a time, oldest first, so that each instance
+// keeps the order of the changes to a name; with several instances (but
+// for a leader elected to run it alone, see package leader), or an event
+// handed on again, consumers should go by the names' versions.
+type Dispatcher struct {
+	store.OutboxStore
+	cfg   Config
+	sinks []Sink
+	wake  chan struct{}
+}
+
+func New(s store.OutboxStore, cfg Config, sinks ...Sink) *Dispatcher {
+	return &Dispatcher{OutboxStore: s, cfg: cfg, sinks: sinks, wake: make(chan struct{}, 1)}
+}
+
+// AddToOutbox adds es and wakes the dispatcher once they are committed.
+func (d *Dispatcher) AddToOutbox(ctx context.Context, es []store.OutboxEvent) error {
+	if err := d.OutboxStore.AddToOutbox(ctx, es); err != nil { return err }
+	store.AfterCommit(ctx, func(context.Context) {
+		select {
+		case d.wake <- struct{}{}:
+		default:
+		}
+	})
+	return nil
+}
+
+// Run hands events on, and purges old ones, until ctx ends.
+func (d *Dispatcher) Run(ctx context.Context) error {
+	slog.Info("publishing name changes from the outbox", "sinks", len(d.sinks))
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() { defer wg.Done(); d.work(ctx) }()
+	d.purge(ctx)
+	wg.Wait()
+	return nil
+}
+
+// work claims and hands on one event after another, waiting for a wake-up
+// or the next poll when there is none.
+func (d *Dispatcher) work(ctx context.Context) {
+	for ctx.Err() == nil {
+		e, err := d.ClaimOutbox(ctx, d.cfg.Lease)
+		if err == nil { d.publish(ctx, e); continue }
+		if !errors.Is(err, store.ErrNotFound) && ctx.Err() == nil { slog.ErrorContext(ctx, "claiming an outbox event", "err", err) }
+		select {
+		case <-ctx.Done():
+		case <-d.wake:
+		case <-time.After(d.cfg.Poll):
+		}
+	}
+}
+
+// purge removes the events published more than Retention ago, hourly.
+func (d *Dispatcher) purge(ctx context.Context) {
+	tick := time.NewTicker(time.Hour)
+	defer tick.Stop()
+	for {
+		if err := d.PurgeOutbox(ctx, time.Now().Add(-d.cfg.Retention)); err != nil && ctx.Err() == nil {
+			slog.ErrorContext(ctx, "purging old outbox events", "err", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick.C:
+		}
+	}
+}
+
+// publish hands e to every sink, in the tenant and request it was written
+// in, and marks it published once they all have it. Otherwise it is
+// claimed again, and handed to every sink again, once the lease lapses.
+func (d *Dispatcher) publish(ctx context.Context, e store.OutboxEvent) {
+	ctx = requestid.NewContext(tenant.NewContext(ctx, e.Tenant), e.RequestID)
+	for _, sink := range d.sinks {
+		if err := sink(ctx, e); err != nil {
+			if ctx.Err() != nil { return }
+			metrics.OutboxPublished("failed")
+			slog.ErrorContext(ctx, "publishing an outbox event", "event", e.ID.Hex(), "type", e.Type, "retry_in", d.cfg.Lease.String(), "err", err)
+			return
+		}
+	}
+	metrics.OutboxPublished("published")
+	if err := d.MarkPublished(ctx, e.ID); err != nil && ctx.Err() == nil {
+		slog.ErrorContext(ctx, "marking an outbox event published", "event", e.ID.Hex(), "err", err)
+	}
+}
@@ -0,0 +1,207 @@
+// Package outbox tells the webhooks and the message bus of the writes to
+// names through a transactional outbox: each write stores an event telling
+// of it in the same transaction, and a Dispatcher hands the events to its
+// sinks afterwards, marking each published once they all have it. Unlike
+// the webhook and bus decorators, which notify just after the write, a
+// crash between the two loses nothing: the event is still in the outbox,
+// and is handed on once the lease of the dispatcher that held it lapses.
+//
+// Delivery is at least once. An event may reach a sink twice, after a crash
+// or a sink that failed after another took it, so both carry its ID for
+// consumers to drop the copies by.
+package outbox
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"app/internal/requestid"
+	"app/internal/store"
+)
+
+// Names wraps a NameStore and adds an event to the outbox for every
+// successful write to it, in the transaction of the write. Reads pass
+// straight through.
+//
+// Without transactions, as on a standalone mongod, the events are added
+// just after the write, as the webhooks' deliveries would be, and a
+// failure to add them is logged, not returned. So are those of batch
+// inserts always: an item MongoDB refuses aborts the transaction it is
+// in, and the rest of the batch with it.
+type Names struct {
+	store.NameStore
+	tx  store.Transactor // nil without transactions
+	box store.OutboxStore
+}
+
+func NewNames(s store.NameStore, tx store.Transactor, box store.OutboxStore) *Names {
+	return &Names{NameStore: s, tx: tx, box: box}
+}
+
+// write runs fn, a write returning the events it makes, and adds those to
+// the outbox, in one transaction if it can.
+func (o *Names) write(ctx context.Context, fn func(ctx context.Context) ([]store.OutboxEvent, error)) error {
+	if o.tx != nil {
+		err := o.tx.InTransaction(ctx, func(ctx context.Context) error {
+			es, err := fn(ctx)
+			if err != nil || len(es) == 0 { return err }
+			return o.box.AddToOutbox(ctx, es)
+		})
+		if !errors.Is(err, store.ErrTransactionsUnsupported) { return err }
+	}
+	es, err := fn(ctx)
+	if err == nil { o.add(ctx, es) }
+	return err
+}
+
+// add adds es to the outbox after their write, even if ctx has been
+// canceled meanwhile.
+func (o *Names) add(ctx context.Context, es []store.OutboxEvent) {
+	if len(es) == 0 { return }
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer cancel()
+	if err := o.box.AddToOutbox(ctx, es); err != nil { slog.ErrorContext(ctx, "adding to the outbox", "events", len(es), "err", err) }
+}
+
+// lookup returns the current documents of ids, soft-deleted ones included,
+// for the events; a failure costs them their name.
+func (o *Names) lookup(ctx context.Context, ids ...primitive.ObjectID) map[primitive.ObjectID]store.Name {
+	docs, err := o.NameStore.Lookup(ctx, ids)
+	if err != nil { slog.ErrorContext(ctx, "reading names for the outbox", "err", err) }
+	return docs
+}
+
+// event is the event of typ about name id, with its document, if any.
+func event(ctx context.Context, typ string, id primitive.ObjectID, n *store.Name) store.OutboxEvent {
+	return store.OutboxEvent{Type: typ, NameID: id, Name: n, At: time.Now().UTC(), RequestID: requestid.FromContext(ctx)}
+}
+
+// found returns the event of typ about id with its document in docs, if any.
+func found(ctx context.Context, typ string, docs map[primitive.ObjectID]store.Name, id primitive.ObjectID) store.OutboxEvent {
+	if n, ok := docs[id]; ok { return event(ctx, typ, id, &n) }
+	return event(ctx, typ, id, nil)
+}
+
+func (o *Names) Create(ctx context.Context, n *store.Name) error {
+	return o.write(ctx, func(ctx context.Context) ([]store.OutboxEvent, error) {
+		if err := o.NameStore.Create(ctx, n); err != nil { return nil, err }
+		after := *n
+		return []store.OutboxEvent{event(ctx, "created", n.ID, &after)}, nil
+	})
+}
+
+func (o *Names) CreateIfAbsent(ctx context.Context, n *store.Name) (bool, error) {
+	var created bool
+	err := o.write(ctx, func(ctx context.Context) (_ []store.OutboxEvent, err error) {
+		if created, err = o.NameStore.CreateIfAbsent(ctx, n); !created { return nil, err }
+		after := *n
+		return []store.OutboxEvent{event(ctx, "created", n.ID, &after)}, err
+	})
+	return created, err
+}
+
+func (o *Names) Upsert(ctx context.Context, n store.Name, ifVersion int64) (*store.Name, store.Name, error) {
+	var before *store.Name
+	var after store.Name
+	err := o.write(ctx, func(ctx context.Context) (_ []store.OutboxEvent, err error) {
+		if before, after, err = o.NameStore.Upsert(ctx, n, ifVersion); err != nil { return nil, err }
+		typ := "updated"
+		if before == nil { typ = "created" }
+		doc := after
+		return []store.OutboxEvent{event(ctx, typ, after.ID, &doc)}, nil
+	})
+	return before, after, err
+}
+
+func (o *Names) Update(ctx context.Context, id primitive.ObjectID, n store.Name, ifVersion int64) (store.Name, error) {
+	var after store.Name
+	err := o.write(ctx, func(ctx context.Context) (_ []store.OutboxEvent, err error) {
+		if after, err = o.NameStore.Update(ctx, id, n, ifVersion); err != nil { return nil, err }
+		doc := after
+		return []store.OutboxEvent{event(ctx, "updated", id, &doc)}, nil
+	})
+	return after, err
+}
+
+func (o *Names) Patch(ctx context.Context, id primitive.ObjectID, p store.NamePatch, ifVersion int64) (store.Name, error) {
+	var after store.Name
+	err := o.write(ctx, func(ctx context.Context) (_ []store.OutboxEvent, err error) {
+		if after, err = o.NameStore.Patch(ctx, id, p, ifVersion); err != nil { return nil, err }
+		doc := after
+		return []store.OutboxEvent{event(ctx, "updated", id, &doc)}, nil
+	})
+	return after, err
+}
+
+func (o *Names) SoftDelete(ctx context.Context, id primitive.ObjectID, ifVersion int64) error {
+	return o.write(ctx, func(ctx context.Context) ([]store.OutboxEvent, error) {
+		if err := o.NameStore.SoftDelete(ctx, id, ifVersion); err != nil { return nil, err }
+		return []store.OutboxEvent{found(ctx, "deleted", o.lookup(ctx, id), id)}, nil
+	})
+}
+
+func (o *Names) HardDelete(ctx context.Context, id primitive.ObjectID, ifVersion int64) error {
+	return o.write(ctx, func(ctx context.Context) ([]store.OutboxEvent, error) {
+		before := o.lookup(ctx, id)
+		if err := o.NameStore.HardDelete(ctx, id, ifVersion); err != nil { return nil, err }
+		return []store.OutboxEvent{found(ctx, "removed", before, id)}, nil
+	})
+}
+
+func (o *Names) Restore(ctx context.Context, id primitive.ObjectID) (store.Name, error) {
+	var after store.Name
+	err := o.write(ctx, func(ctx context.Context) (_ []store.OutboxEvent, err error) {
+		if after, err = o.NameStore.Restore(ctx, id); err != nil { return nil, err }
+		doc := after
+		return []store.OutboxEvent{event(ctx, "restored", id, &doc)}, nil
+	})
+	return after, err
+}
+
+func (o *Names) CreateMany(ctx context.Context, ns []store.Name) ([]error, error) {
+	errs, err := o.NameStore.CreateMany(ctx, ns)
+	if err == nil { o.add(ctx, created(ctx, ns, errs)) }
+	return errs, err
+}
+
+func (o *Names) InsertMany(ctx context.Context, ns []store.Name) ([]error, error) {
+	errs, err := o.NameStore.InsertMany(ctx, ns)
+	if err == nil { o.add(ctx, created(ctx, ns, errs)) }
+	return errs, err
+}
+
+// created returns the events of the items of a batch insert that were
+// stored.
+func created(ctx context.Context, ns []store.Name, errs []error) []store.OutboxEvent {
+	var es []store.OutboxEvent
+	for i := range ns {
+		if i < len(errs) && errs[i] == nil { n := ns[i]; es = append(es, event(ctx, "created", n.ID, &n)) }
+	}
+	return es
+}
+
+func (o *Names) DeleteMany(ctx context.Context, ids []primitive.ObjectID, hard bool) (map[primitive.ObjectID]bool, error) {
+	typ := "deleted"
+	if hard { typ = "removed" }
+	var existed map[primitive.ObjectID]bool
+	err := o.write(ctx, func(ctx context.Context) (_ []store.OutboxEvent, err error) {
+		var before map[primitive.ObjectID]store.Name
+		if hard { before = o.lookup(ctx, ids...) }
+		if existed, err = o.NameStore.DeleteMany(ctx, ids, hard); err != nil { return nil, err }
+
+		var deleted []primitive.ObjectID
+		for _, id := range ids {
+			if existed[id] && !slices.Contains(deleted, id) { deleted = append(deleted, id) }
+		}
+		if !hard { before = o.lookup(ctx, deleted...) }
+		es := make([]store.OutboxEvent, 0, len(deleted))
+		for _, id := range deleted { es = append(es, found(ctx, typ, before, id)) }
+		return es, nil
+	})
+	return existed, err
+}
@@ -0,0 +1,134 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"app/internal/bus"
+	"app/internal/store"
+	"app/internal/tenant"
+	"app/internal/webhook"
+)
+
+// failing is a NameStore whose soft deletes are applied and then fail, as
+// a write whose transaction doesn't commit would.
+type failing struct{ store.NameStore }
+
+func (f failing) SoftDelete(ctx context.Context, id primitive.ObjectID, ifVersion int64) error {
+	if err := f.NameStore.SoftDelete(ctx, id, ifVersion); err != nil { return err }
+	return errors.New("commit failed")
+}
+
+// drain claims every event in box.
+func drain(t *testing.T, box store.OutboxStore) (out []store.OutboxEvent) {
+	t.Helper()
+	for {
+		e, err := box.ClaimOutbox(context.Background(), time.Hour)
+		if errors.Is(err, store.ErrNotFound) { return out }
+		if err != nil { t.Fatal(err) }
+		out = append(out, e)
+	}
+}
+
+func TestNames(t *testing.T) {
+	ctx := tenant.NewContext(context.Background(), "team-a")
+	mem, box := store.NewMemoryNames(), store.NewMemoryOutbox()
+	names := NewNames(failing{mem}, mem, box)
+
+	n := store.Name{Name: "alice"}
+	if err := names.Create(ctx, &n); err != nil { t.Fatal(err) }
+	if _, err := names.Patch(ctx, n.ID, store.NamePatch{Tags: &[]string{"vip"}}, 9); !errors.Is(err, store.ErrVersionMismatch) { t.Fatalf("stale patch: %v", err) }
+	// The delete is rolled back with its event.
+	if err := names.SoftDelete(ctx, n.ID, store.AnyVersion); err == nil { t.Fatal("a failed delete went through") }
+	if got, _ := mem.Get(ctx, n.ID); got.DeletedAt != nil { t.Fatal("a failed delete wasn't rolled back") }
+	if errs, err := names.CreateMany(ctx, []store.Name{{Name: "bob"}, {Name: "alice"}}); err != nil || errs[0] != nil || errs[1] == nil { t.Fatal(errs, err) }
+	if err := names.HardDelete(ctx, n.ID, store.AnyVersion); err != nil { t.Fatal(err) }
+
+	got := drain(t, box)
+	if len(got) != 3 { t.Fatalf("events: %+v", got) }
+	if e := got[0]; e.Type != "created" || e.NameID != n.ID || e.Tenant != "team-a" || e.Name == nil || e.Name.Name != "alice" { t.Fatalf("created: %+v", e) }
+	if e := got[1]; e.Type != "created" || e.Name == nil || e.Name.Name != "bob" { t.Fatalf("batch: %+v", e) }
+	if e := got[2]; e.Type != "removed" || e.Name == nil || e.Name.Name != "alice" { t.Fatalf("removed: %+v", e) }
+
+	// Without transactions the events follow the writes.
+	plain := NewNames(mem, nil, box)
+	if _, err := plain.Restore(ctx, n.ID); !errors.Is(err, store.ErrNotFound) { t.Fatalf("restore of a removed name: %v", err) }
+	if err := plain.Create(ctx, &store.Name{Name: "carol"}); err != nil { t.Fatal(err) }
+	if got := drain(t, box); len(got) != 1 || got[0].Name.Name != "carol" { t.Fatalf("events without transactions: %+v", got) }
+}
+
+// recorder is a bus.Publisher that keeps what it's given, or fails.
+type recorder struct {
+	mu   sync.Mutex
+	msgs []bus.Message
+	fail atomic.Bool
+}
+
+func (r *recorder) Publish(_ context.Context, msgs ...bus.Message) error {
+	if r.fail.Load() { return errors.New("bus down") }
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.msgs = append(r.msgs, msgs...)
+	return nil
+}
+
+func (r *recorder) Close() error { return nil }
+
+func (r *recorder) events(t *testing.T) (out []bus.Event) {
+	t.Helper()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, m := range r.msgs {
+		var e bus.Event
+		if err := json.Unmarshal(m.Value, &e); err != nil { t.Fatal(err) }
+		out = append(out, e)
+	}
+	return out
+}
+
+func TestDispatcher(t *testing.T) {
+	ctx := tenant.NewContext(context.Background(), "team-a")
+	hooks, pub := webhook.New(store.NewMemoryWebhooks(), webhook.Config{}), &recorder{}
+	hook := store.Webhook{URL: "https://a.example", Events: []string{"created"}}
+	_ = hooks.CreateWebhook(ctx, &hook)
+	pub.fail.Store(true)
+
+	mem := store.NewMemoryNames()
+	d := New(store.NewMemoryOutbox(), Config{Poll: 10 * time.Millisecond, Lease: 50 * time.Millisecond, Retention: time.Hour},
+		hooks.EnqueueEvent, func(ctx context.Context, e store.OutboxEvent) error { return bus.PublishEvent(ctx, pub, bus.FormatJSON, e) })
+	runCtx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- d.Run(runCtx) }()
+	t.Cleanup(func() { cancel(); <-done })
+
+	names := NewNames(mem, mem, d)
+	n := store.Name{Name: "alice"}
+	if err := names.Create(ctx, &n); err != nil { t.Fatal(err) }
+
+	// The bus is down: the event stays in the outbox, and is handed on
+	// again once it's back, the webhook getting it twice.
+	time.Sleep(20 * time.Millisecond)
+	if got := pub.events(t); len(got) != 0 { t.Fatalf("published to a bus that's down: %+v", got) }
+	pub.fail.Store(false)
+	var sent []bus.Event
+	for deadline := time.Now().Add(5 * time.Second); len(sent) == 0 && time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) { sent = pub.events(t) }
+	if len(sent) != 1 || sent[0].Type != "created" || sent[0].NameID != n.ID || sent[0].Tenant != "team-a" || sent[0].ID == "" { t.Fatalf("published %+v", sent) }
+
+	log, err := hooks.Deliveries(ctx, hook.ID, 10)
+	if err != nil || len(log) < 2 { t.Fatalf("deliveries: %+v, %v", log, err) }
+	var first, second webhook.Payload
+	_ = json.Unmarshal(log[0].Payload, &first)
+	_ = json.Unmarshal(log[1].Payload, &second)
+	if first.ID != sent[0].ID || second.ID != first.ID || first.Event != "created" { t.Fatalf("payloads %+v and %+v", first, second) }
+
+	// Once published, it's handed on no more.
+	time.Sleep(100 * time.Millisecond)
+	if got := pub.events(t); len(got) != 1 { t.Fatalf("published again: %+v", got) }
+}
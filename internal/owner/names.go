@@ -0,0 +1,134 @@
+// Package owner makes names belong to the users who create them: after
+// that, only they or an admin may change or delete them, whichever API the
+// write comes through.
+package owner
+
+import (
+	"context"
+	"errors"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"app/internal/auth"
+	"app/internal/store"
+)
+
+// Names wraps a NameStore, stamping the names it creates with the caller's
+// user ID and refusing the writes of anyone else to them with
+// store.ErrNotOwner, unless they are an admin. Reads pass straight through.
+//
+// Names with no owner, created with no user signed in, are anyone's to
+// change; so is every name without a caller, with auth off or in the
+// server's own work such as the cleanup. The owner of a name never changes,
+// so reading it just before the write leaves no window to slip through.
+type Names struct {
+	store.NameStore
+	keys store.NameKeyStore
+}
+
+func NewNames(s store.NameStore, keys store.NameKeyStore) *Names {
+	return &Names{NameStore: s, keys: keys}
+}
+
+// caller is the user ID of the caller in ctx, "" if there is none.
+func caller(ctx context.Context) string {
+	uid := auth.UserIDFromContext(ctx)
+	if uid.IsZero() { return "" }
+	return uid.Hex()
+}
+
+// unrestricted reports whether the caller may write to any name: there is
+// none, or it's an admin (or a user from before roles, who may do all).
+func unrestricted(ctx context.Context) bool {
+	role, ok := auth.RoleFromContext(ctx)
+	return !ok || auth.RoleWithin(auth.RoleAdmin, role)
+}
+
+// own makes n the caller's, unless it is being restored (see
+// store.Restoring) and keeps the owner of its backup.
+func own(ctx context.Context, n *store.Name) {
+	if !store.IsRestoring(ctx) { n.CreatedBy = caller(ctx) }
+}
+
+func mayChange(ctx context.Context, n store.Name) bool {
+	return n.CreatedBy == "" || n.CreatedBy == caller(ctx) || unrestricted(ctx)
+}
+
+// check fails with store.ErrNotOwner if the caller may not change any of
+// ids. Those that don't exist are left for the write to report.
+func (o *Names) check(ctx context.Context, ids ...primitive.ObjectID) error {
+	if unrestricted(ctx) { return nil }
+	docs, err := o.NameStore.Lookup(ctx, ids)
+	if err != nil { return err }
+	for _, n := range docs {
+		if !mayChange(ctx, n) { return store.ErrNotOwner }
+	}
+	return nil
+}
+
+// ---- creates ----
+
+func (o *Names) Create(ctx context.Context, n *store.Name) error {
+	own(ctx, n)
+	return o.NameStore.Create(ctx, n)
+}
+
+func (o *Names) CreateIfAbsent(ctx context.Context, n *store.Name) (bool, error) {
+	own(ctx, n)
+	return o.NameStore.CreateIfAbsent(ctx, n)
+}
+
+func (o *Names) CreateMany(ctx context.Context, ns []store.Name) ([]error, error) {
+	for i := range ns { own(ctx, &ns[i]) }
+	return o.NameStore.CreateMany(ctx, ns)
+}
+
+func (o *Names) InsertMany(ctx context.Context, ns []store.Name) ([]error, error) {
+	for i := range ns { own(ctx, &ns[i]) }
+	return o.NameStore.InsertMany(ctx, ns)
+}
+
+// Upsert creates the name as the caller's, or updates it if it may.
+func (o *Names) Upsert(ctx context.Context, n store.Name, ifVersion int64) (*store.Name, store.Name, error) {
+	own(ctx, &n)
+	if !unrestricted(ctx) {
+		existing, err := o.keys.NameByName(ctx, n.Name)
+		if err == nil && !mayChange(ctx, existing) { return nil, store.Name{}, store.ErrNotOwner }
+		if err != nil && !errors.Is(err, store.ErrNotFound) { return nil, store.Name{}, err }
+	}
+	return o.NameStore.Upsert(ctx, n, ifVersion)
+}
+
+// ---- writes to existing names ----
+
+func (o *Names) Update(ctx context.Context, id primitive.ObjectID, n store.Name, ifVersion int64) (store.Name, error) {
+	if err := o.check(ctx, id); err != nil { return store.Name{}, err }
+	return o.NameStore.Update(ctx, id, n, ifVersion)
+}
+
+func (o *Names) Patch(ctx context.Context, id primitive.ObjectID, p store.NamePatch, ifVersion int64) (store.Name, error) {
+	if err := o.check(ctx, id); err != nil { return store.Name{}, err }
+	return o.NameStore.Patch(ctx, id, p, ifVersion)
+}
+
+func (o *Names) SoftDelete(ctx context.Context, id primitive.ObjectID, ifVersion int64) error {
+	if err := o.check(ctx, id); err != nil { return err }
+	return o.NameStore.SoftDelete(ctx, id, ifVersion)
+}
+
+func (o *Names) HardDelete(ctx context.Context, id primitive.ObjectID, ifVersion int64) error {
+	if err := o.check(ctx, id); err != nil { return err }
+	return o.NameStore.HardDelete(ctx, id, ifVersion)
+}
+
+func (o *Names) Restore(ctx context.Context, id primitive.ObjectID) (store.Name, error) {
+	if err := o.check(ctx, id); err != nil { return store.Name{}, err }
+	return o.NameStore.Restore(ctx, id)
+}
+
+// DeleteMany refuses the whole batch if any of its names is someone
+// else's, so that nothing is half done.
+func (o *Names) DeleteMany(ctx context.Context, ids []primitive.ObjectID, hard bool) (map[primitive.ObjectID]bool, error) {
+	if err := o.check(ctx, ids...); err != nil { return nil, err }
+	return o.NameStore.DeleteMany(ctx, ids, hard)
+}
@@ -0,0 +1,48 @@
+package owner
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"app/internal/auth"
+	"app/internal/store"
+)
+
+func as(uid primitive.ObjectID, role string) context.Context {
+	return auth.WithRole(auth.WithUserID(context.Background(), uid), role)
+}
+
+func TestNames(t *testing.T) {
+	names := store.NewMemoryNames()
+	s := NewNames(names, names)
+	alice, bob, root := primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()
+	actx, bctx, rctx := as(alice, auth.RoleEditor), as(bob, auth.RoleEditor), as(root, auth.RoleAdmin)
+
+	zoe := store.Name{Name: "Zoe", CreatedBy: bob.Hex()}
+	if err := s.Create(actx, &zoe); err != nil || zoe.CreatedBy != alice.Hex() { t.Fatalf("created %+v, %v", zoe, err) }
+	batch := []store.Name{{Name: "Yan"}, {Name: "Xia"}}
+	if errs, err := s.CreateMany(bctx, batch); err != nil || errs[0] != nil || batch[1].CreatedBy != bob.Hex() { t.Fatalf("batch: %v, %v", errs, err) }
+	anon := store.Name{Name: "Wu"}
+	if err := s.Create(context.Background(), &anon); err != nil || anon.CreatedBy != "" { t.Fatalf("created with no user: %+v, %v", anon, err) }
+
+	// bob may change neither zoe nor a batch with her in it.
+	if _, err := s.Patch(bctx, zoe.ID, store.NamePatch{Tags: &[]string{"x"}}, store.AnyVersion); !errors.Is(err, store.ErrNotOwner) { t.Fatalf("patch by bob: %v", err) }
+	if err := s.SoftDelete(bctx, zoe.ID, store.AnyVersion); !errors.Is(err, store.ErrNotOwner) { t.Fatalf("delete by bob: %v", err) }
+	if _, err := s.DeleteMany(bctx, []primitive.ObjectID{batch[0].ID, zoe.ID}, false); !errors.Is(err, store.ErrNotOwner) { t.Fatalf("batch delete by bob: %v", err) }
+	if _, _, err := s.Upsert(bctx, store.Name{Name: "Zoe"}, store.AnyVersion); !errors.Is(err, store.ErrNotOwner) { t.Fatalf("upsert by bob: %v", err) }
+	if n, _ := names.Get(actx, batch[0].ID); n.DeletedAt != nil { t.Fatal("a refused batch deleted some of it") }
+
+	// ...but his own, one without an owner, and a new one by name are his.
+	if _, err := s.Patch(bctx, batch[0].ID, store.NamePatch{Tags: &[]string{"x"}}, store.AnyVersion); err != nil { t.Fatalf("patch of his own: %v", err) }
+	if _, err := s.Patch(bctx, anon.ID, store.NamePatch{Tags: &[]string{"x"}}, store.AnyVersion); err != nil { t.Fatalf("patch of no one's: %v", err) }
+	if _, n, err := s.Upsert(bctx, store.Name{Name: "Vic"}, store.AnyVersion); err != nil || n.CreatedBy != bob.Hex() { t.Fatalf("upserted %+v, %v", n, err) }
+
+	// alice and an admin may change zoe; neither takes her over.
+	if _, err := s.Patch(actx, zoe.ID, store.NamePatch{Tags: &[]string{"a"}}, store.AnyVersion); err != nil { t.Fatalf("patch by alice: %v", err) }
+	if _, n, err := s.Upsert(rctx, store.Name{Name: "Zoe"}, store.AnyVersion); err != nil || n.CreatedBy != alice.Hex() { t.Fatalf("upsert by an admin: %+v, %v", n, err) }
+	if err := s.SoftDelete(rctx, zoe.ID, store.AnyVersion); err != nil { t.Fatalf("delete by an admin: %v", err) }
+	if _, err := s.Restore(bctx, zoe.ID); !errors.Is(err, store.ErrNotOwner) { t.Fatalf("restore by bob: %v", err) }
+}
@@ -0,0 +1,371 @@
+package prewrite
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"math"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Expressions are written in Go's expression syntax and evaluated over the
+// values JSON has: strings, float64 numbers, bools, nil, []any lists and
+// map[string]any objects. A hook sees these variables:
+//
+//	name      the name, a string
+//	tags      its tags, a list of strings
+//	metadata  its metadata, an object; metadata.team is nil if there's no team
+//	tenant    the tenant writing it
+//	op        create (any new name), update (any change to one) or upsert (PUT /names/by-name/{name} with If-Match)
+//
+// and these functions:
+//
+//	lower(s) upper(s) trim(s) replace(s, old, new) split(s, sep) join(list, sep)
+//	hasPrefix(s, p) hasSuffix(s, p) matches(s, "regexp") len(s|list|object)
+//	contains(s, sub) contains(list, v) contains(object, key)
+//	string(v)          v as text: numbers without trailing zeros, lists and objects as JSON-ish
+//	cond(c, a, b)      a if c is true, else b; only the one chosen is evaluated
+//
+// Strings compare and concatenate with the usual operators, lists
+// concatenate with +, and == compares any two values. Nothing else of Go
+// is accepted: no other calls, no composite literals, no function literals.
+var vars = []string{"name", "tags", "metadata", "tenant", "op"}
+
+type function struct {
+	args int
+	call func(args []any) (any, error)
+}
+
+var functions = map[string]function{
+	"lower":     {1, strFunc(strings.ToLower)},
+	"upper":     {1, strFunc(strings.ToUpper)},
+	"trim":      {1, strFunc(strings.TrimSpace)},
+	"replace": {3, func(a []any) (any, error) {
+		s, old, repl, err := str3(a)
+		return strings.ReplaceAll(s, old, repl), err
+	}},
+	"split": {2, func(a []any) (any, error) {
+		s, sep, err := str2(a)
+		if err != nil { return nil, err }
+		var list []any
+		for _, part := range strings.Split(s, sep) { list = append(list, part) }
+		return list, nil
+	}},
+	"join": {2, func(a []any) (any, error) {
+		list, ok := a[0].([]any)
+		sep, ok2 := a[1].(string)
+		if !ok || !ok2 { return nil, fmt.Errorf("wants a list and a string, got %s and %s", kind(a[0]), kind(a[1])) }
+		parts := make([]string, len(list))
+		for i, v := range list { parts[i] = text(v) }
+		return strings.Join(parts, sep), nil
+	}},
+	"hasPrefix": {2, strPred(strings.HasPrefix)},
+	"hasSuffix": {2, strPred(strings.HasSuffix)},
+	"len": {1, func(a []any) (any, error) {
+		switch v := a[0].(type) {
+		case string:         return float64(utf8.RuneCountInString(v)), nil
+		case []any:          return float64(len(v)), nil
+		case map[string]any: return float64(len(v)), nil
+		case nil:            return float64(0), nil
+		}
+		return nil, fmt.Errorf("can't measure a %s", kind(a[0]))
+	}},
+	"contains": {2, func(a []any) (any, error) {
+		switch v := a[0].(type) {
+		case string:
+			sub, ok := a[1].(string)
+			if !ok { return nil, fmt.Errorf("wants a string to look for in a string, got %s", kind(a[1])) }
+			return strings.Contains(v, sub), nil
+		case []any:
+			return slices.ContainsFunc(v, func(e any) bool { return equal(e, a[1]) }), nil
+		case map[string]any:
+			key, ok := a[1].(string)
+			if !ok { return nil, fmt.Errorf("wants a string key to look for in an object, got %s", kind(a[1])) }
+			_, has := v[key]
+			return has, nil
+		case nil:
+			return false, nil
+		}
+		return nil, fmt.Errorf("can't look in a %s", kind(a[0]))
+	}},
+	"string":  {1, func(a []any) (any, error) { return text(a[0]), nil }},
+	"matches": {2, nil}, // compiled with its pattern, see check
+	"cond":    {3, nil}, // lazy, see eval
+}
+
+// Expr is a checked expression, ready to be evaluated.
+type Expr struct {
+	src  string
+	root ast.Expr
+	res  map[*ast.CallExpr]*regexp.Regexp // the patterns of the matches calls
+}
+
+// Compile parses src and checks it only uses what's described above.
+// matches' pattern must be a string literal, so it is compiled here too.
+func Compile(src string) (*Expr, error) {
+	root, err := parser.ParseExpr(src)
+	if err != nil { return nil, err }
+	e := &Expr{src: src, root: root, res: map[*ast.CallExpr]*regexp.Regexp{}}
+	if err := e.check(root); err != nil { return nil, err }
+	return e, nil
+}
+
+func (e *Expr) String() string { return e.src }
+
+func (e *Expr) check(n ast.Expr) error {
+	switch n := n.(type) {
+	case *ast.BasicLit:
+		if n.Kind != token.STRING && n.Kind != token.INT && n.Kind != token.FLOAT { return fmt.Errorf("%s literals aren't supported", n.Kind) }
+		_, err := literal(n)
+		return err
+	case *ast.Ident:
+		if n.Name == "true" || n.Name == "false" || n.Name == "nil" || slices.Contains(vars, n.Name) { return nil }
+		return fmt.Errorf("unknown variable %q; there are %s", n.Name, strings.Join(vars, ", "))
+	case *ast.ParenExpr:
+		return e.check(n.X)
+	case *ast.UnaryExpr:
+		if n.Op != token.NOT && n.Op != token.SUB { return fmt.Errorf("operator %s isn't supported", n.Op) }
+		return e.check(n.X)
+	case *ast.BinaryExpr:
+		switch n.Op {
+		case token.LAND, token.LOR, token.EQL, token.NEQ, token.LSS, token.LEQ, token.GTR, token.GEQ, token.ADD, token.SUB, token.MUL, token.QUO, token.REM:
+		default:
+			return fmt.Errorf("operator %s isn't supported", n.Op)
+		}
+		return errors.Join(e.check(n.X), e.check(n.Y))
+	case *ast.SelectorExpr:
+		return e.check(n.X)
+	case *ast.IndexExpr:
+		return errors.Join(e.check(n.X), e.check(n.Index))
+	case *ast.CallExpr:
+		id, ok := n.Fun.(*ast.Ident)
+		if !ok { return errors.New("only the built-in functions can be called") }
+		f, ok := functions[id.Name]
+		if !ok { return fmt.Errorf("unknown function %q", id.Name) }
+		if len(n.Args) != f.args || n.Ellipsis.IsValid() { return fmt.Errorf("%s takes %d arguments", id.Name, f.args) }
+		if id.Name == "matches" {
+			lit, ok := n.Args[1].(*ast.BasicLit)
+			if !ok || lit.Kind != token.STRING { return errors.New("matches' pattern must be a string literal") }
+			pattern, _ := strconv.Unquote(lit.Value)
+			re, err := regexp.Compile(pattern)
+			if err != nil { return fmt.Errorf("matches: %w", err) }
+			e.res[n] = re
+		}
+		var errs []error
+		for _, arg := range n.Args { errs = append(errs, e.check(arg)) }
+		return errors.Join(errs...)
+	}
+	return fmt.Errorf("%T isn't supported", n)
+}
+
+// Eval evaluates the expression with the variables in env.
+func (e *Expr) Eval(env map[string]any) (any, error) { return e.eval(e.root, env) }
+
+func (e *Expr) eval(n ast.Expr, env map[string]any) (any, error) {
+	switch n := n.(type) {
+	case *ast.BasicLit:
+		return literal(n)
+	case *ast.Ident:
+		switch n.Name {
+		case "true":  return true, nil
+		case "false": return false, nil
+		case "nil":   return nil, nil
+		}
+		return env[n.Name], nil
+	case *ast.ParenExpr:
+		return e.eval(n.X, env)
+	case *ast.UnaryExpr:
+		x, err := e.eval(n.X, env)
+		if err != nil { return nil, err }
+		switch x := x.(type) {
+		case bool:    if n.Op == token.NOT { return !x, nil }
+		case float64: if n.Op == token.SUB { return -x, nil }
+		}
+		return nil, fmt.Errorf("%s of a %s", n.Op, kind(x))
+	case *ast.BinaryExpr:
+		return e.binary(n, env)
+	case *ast.SelectorExpr:
+		x, err := e.eval(n.X, env)
+		if err != nil { return nil, err }
+		return field(x, n.Sel.Name)
+	case *ast.IndexExpr:
+		x, err := e.eval(n.X, env)
+		if err != nil { return nil, err }
+		i, err := e.eval(n.Index, env)
+		if err != nil { return nil, err }
+		return index(x, i)
+	case *ast.CallExpr:
+		return e.call(n, env)
+	}
+	return nil, fmt.Errorf("%T isn't supported", n) // Compile has rejected it
+}
+
+func (e *Expr) binary(n *ast.BinaryExpr, env map[string]any) (any, error) {
+	x, err := e.eval(n.X, env)
+	if err != nil { return nil, err }
+	if n.Op == token.LAND || n.Op == token.LOR {
+		b, ok := x.(bool)
+		if !ok { return nil, fmt.Errorf("%s of a %s", n.Op, kind(x)) }
+		if b == (n.Op == token.LOR) { return b, nil }
+		y, err := e.eval(n.Y, env)
+		if err != nil { return nil, err }
+		if _, ok := y.(bool); !ok { return nil, fmt.Errorf("%s of a %s", n.Op, kind(y)) }
+		return y, nil
+	}
+	y, err := e.eval(n.Y, env)
+	if err != nil { return nil, err }
+	switch n.Op {
+	case token.EQL: return equal(x, y), nil
+	case token.NEQ: return !equal(x, y), nil
+	}
+	switch x := x.(type) {
+	case string:
+		if y, ok := y.(string); ok {
+			switch n.Op {
+			case token.ADD: return x + y, nil
+			case token.LSS: return x < y, nil
+			case token.LEQ: return x <= y, nil
+			case token.GTR: return x > y, nil
+			case token.GEQ: return x >= y, nil
+			}
+		}
+	case float64:
+		if y, ok := y.(float64); ok {
+			switch n.Op {
+			case token.ADD: return x + y, nil
+			case token.SUB: return x - y, nil
+			case token.MUL: return x * y, nil
+			case token.QUO, token.REM:
+				if y == 0 { return nil, errors.New("division by zero") }
+				if n.Op == token.REM { return math.Mod(x, y), nil }
+				return x / y, nil
+			case token.LSS: return x < y, nil
+			case token.LEQ: return x <= y, nil
+			case token.GTR: return x > y, nil
+			case token.GEQ: return x >= y, nil
+			}
+		}
+	case []any:
+		if y, ok := y.([]any); ok && n.Op == token.ADD { return append(slices.Clip(x), y...), nil }
+	}
+	return nil, fmt.Errorf("%s %s %s", kind(x), n.Op, kind(y))
+}
+
+func (e *Expr) call(n *ast.CallExpr, env map[string]any) (any, error) {
+	name := n.Fun.(*ast.Ident).Name
+	if name == "cond" {
+		c, err := e.eval(n.Args[0], env)
+		if err != nil { return nil, err }
+		b, ok := c.(bool)
+		if !ok { return nil, fmt.Errorf("cond: wants a bool, got %s", kind(c)) }
+		if b { return e.eval(n.Args[1], env) }
+		return e.eval(n.Args[2], env)
+	}
+	args := make([]any, len(n.Args))
+	for i, arg := range n.Args {
+		v, err := e.eval(arg, env)
+		if err != nil { return nil, err }
+		args[i] = v
+	}
+	if name == "matches" {
+		s, ok := args[0].(string)
+		if !ok { return nil, fmt.Errorf("matches: wants a string, got %s", kind(args[0])) }
+		return e.res[n].MatchString(s), nil
+	}
+	v, err := functions[name].call(args)
+	if err != nil { return nil, fmt.Errorf("%s: %w", name, err) }
+	return v, nil
+}
+
+func literal(lit *ast.BasicLit) (any, error) {
+	if lit.Kind == token.STRING { return strconv.Unquote(lit.Value) }
+	f, err := strconv.ParseFloat(strings.ReplaceAll(lit.Value, "_", ""), 64)
+	if err != nil { return nil, fmt.Errorf("bad number %s", lit.Value) }
+	return f, nil
+}
+
+// field is x.key: an object's value, nil if it has none.
+func field(x any, key string) (any, error) {
+	switch x := x.(type) {
+	case map[string]any: return x[key], nil
+	case nil:            return nil, nil
+	}
+	return nil, fmt.Errorf("field %s of a %s", key, kind(x))
+}
+
+func index(x, i any) (any, error) {
+	switch x := x.(type) {
+	case []any:
+		f, ok := i.(float64)
+		if !ok || f != math.Trunc(f) { return nil, fmt.Errorf("list index must be a whole number, got %s", kind(i)) }
+		if f < 0 || int(f) >= len(x) { return nil, fmt.Errorf("index %v out of range for a list of %d", f, len(x)) }
+		return x[int(f)], nil
+	case map[string]any, nil:
+		key, ok := i.(string)
+		if !ok { return nil, fmt.Errorf("object key must be a string, got %s", kind(i)) }
+		return field(x, key)
+	case string:
+		return nil, errors.New("strings can't be indexed")
+	}
+	return nil, fmt.Errorf("index of a %s", kind(x))
+}
+
+func equal(x, y any) bool { return reflect.DeepEqual(x, y) }
+
+// text is string(v).
+func text(v any) string {
+	switch v := v.(type) {
+	case string:  return v
+	case nil:     return ""
+	case float64: return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return fmt.Sprint(v)
+}
+
+func kind(v any) string {
+	switch v.(type) {
+	case string:         return "string"
+	case float64:        return "number"
+	case bool:           return "bool"
+	case nil:            return "nil"
+	case []any:          return "list"
+	case map[string]any: return "object"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+func strFunc(f func(string) string) func([]any) (any, error) {
+	return func(a []any) (any, error) {
+		s, ok := a[0].(string)
+		if !ok { return nil, fmt.Errorf("wants a string, got %s", kind(a[0])) }
+		return f(s), nil
+	}
+}
+
+func strPred(f func(string, string) bool) func([]any) (any, error) {
+	return func(a []any) (any, error) {
+		s, t, err := str2(a)
+		return f(s, t), err
+	}
+}
+
+func str2(a []any) (string, string, error) {
+	s, ok := a[0].(string)
+	t, ok2 := a[1].(string)
+	if !ok || !ok2 { return "", "", fmt.Errorf("wants strings, got %s and %s", kind(a[0]), kind(a[1])) }
+	return s, t, nil
+}
+
+func str3(a []any) (string, string, string, error) {
+	s, t, err := str2(a)
+	u, ok := a[2].(string)
+	if err == nil && !ok { err = fmt.Errorf("wants strings, got %s", kind(a[2])) }
+	return s, t, u, err
+}
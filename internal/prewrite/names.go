@@ -0,0 +1,141 @@
+package prewrite
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"app/internal/store"
+	"app/internal/tenant"
+	"app/internal/validate"
+)
+
+// Rejected is a name the hooks refused, or that a set hook left invalid.
+// Fields are the failures, to answer with as a 422.
+type Rejected struct {
+	Fields []validate.FieldError
+}
+
+func (e *Rejected) Error() string {
+	msgs := make([]string, len(e.Fields))
+	for i, f := range e.Fields { msgs[i] = f.Field + " " + f.Message }
+	return "rejected by the prewrite hooks: " + strings.Join(msgs, "; ")
+}
+
+// Names wraps a NameStore and runs the hooks on every name written through
+// it, whichever API the write comes from: POST, PUT and PATCH /names, the
+// bulk, transaction and import endpoints, GraphQL and gRPC alike. A name
+// they refuse fails with a *Rejected and reaches none of the layers below.
+// Reads and deletes pass straight through.
+type Names struct {
+	store.NameStore
+	hooks *Hooks
+}
+
+func NewNames(s store.NameStore, hooks *Hooks) *Names {
+	return &Names{NameStore: s, hooks: hooks}
+}
+
+// run applies the hooks to n as written in op by the tenant in ctx. A
+// restore (see store.Restoring) writes names back as they were, unhooked.
+func (n *Names) run(ctx context.Context, name *store.Name, op string) error {
+	if store.IsRestoring(ctx) { return nil }
+	errs, err := n.hooks.Apply(name, tenant.FromContext(ctx), op)
+	if err != nil { return err }
+	if errs != nil { return &Rejected{Fields: errs} }
+	return nil
+}
+
+func (n *Names) Create(ctx context.Context, name *store.Name) error {
+	if err := n.run(ctx, name, OpCreate); err != nil { return err }
+	return n.NameStore.Create(ctx, name)
+}
+
+func (n *Names) CreateIfAbsent(ctx context.Context, name *store.Name) (bool, error) {
+	if err := n.run(ctx, name, OpCreate); err != nil { return false, err }
+	return n.NameStore.CreateIfAbsent(ctx, name)
+}
+
+// Upsert runs the hooks before looking the name up, so a hook renaming it
+// upserts the new name.
+func (n *Names) Upsert(ctx context.Context, name store.Name, ifVersion int64) (*store.Name, store.Name, error) {
+	if err := n.run(ctx, &name, OpUpsert); err != nil { return nil, store.Name{}, err }
+	return n.NameStore.Upsert(ctx, name, ifVersion)
+}
+
+func (n *Names) Update(ctx context.Context, id primitive.ObjectID, name store.Name, ifVersion int64) (store.Name, error) {
+	if err := n.run(ctx, &name, OpUpdate); err != nil { return store.Name{}, err }
+	return n.NameStore.Update(ctx, id, name, ifVersion)
+}
+
+// patchAttempts bounds how often an unconditional patch starts over when
+// the name changes between reading it and writing it.
+const patchAttempts = 3
+
+// Patch runs the hooks, as an update, on the name the patch would leave,
+// and writes what they changed of it along with the patch. That is done at
+// the version they saw, so they never judge a name that has moved on; when
+// the caller gave no version, a write in between has it start over.
+func (n *Names) Patch(ctx context.Context, id primitive.ObjectID, p store.NamePatch, ifVersion int64) (store.Name, error) {
+	for attempt := 1; ; attempt++ {
+		cur, err := n.NameStore.Get(ctx, id)
+		if err != nil { return store.Name{}, err }
+		if ifVersion != store.AnyVersion && cur.Version != ifVersion { return store.Name{}, store.ErrVersionMismatch }
+
+		before := patched(cur, p)
+		after := before
+		after.Metadata = maps.Clone(before.Metadata) // the set hooks write to it
+		if err := n.run(ctx, &after, OpUpdate); err != nil { return store.Name{}, err }
+		q := p
+		if after.Name != before.Name { q.Name = &after.Name }
+		if !slices.Equal(after.Tags, before.Tags) { q.Tags = &after.Tags }
+		if !reflect.DeepEqual(after.Metadata, before.Metadata) { q.Metadata = &after.Metadata }
+
+		res, err := n.NameStore.Patch(ctx, id, q, cur.Version)
+		if errors.Is(err, store.ErrVersionMismatch) && ifVersion == store.AnyVersion && attempt < patchAttempts { continue }
+		return res, err
+	}
+}
+
+// patched is the name, tags and metadata of cur with p applied, which are
+// all the hooks see.
+func patched(cur store.Name, p store.NamePatch) store.Name {
+	n := store.Name{Name: cur.Name, Tags: cur.Tags, Metadata: cur.Metadata}
+	if p.Name != nil { n.Name = *p.Name }
+	if p.Tags != nil { n.Tags = *p.Tags }
+	if p.Metadata != nil { n.Metadata = *p.Metadata }
+	return n
+}
+
+func (n *Names) CreateMany(ctx context.Context, ns []store.Name) ([]error, error) {
+	return n.many(ctx, ns, n.NameStore.CreateMany)
+}
+
+func (n *Names) InsertMany(ctx context.Context, ns []store.Name) ([]error, error) {
+	return n.many(ctx, ns, n.NameStore.InsertMany)
+}
+
+// many runs the hooks on each of ns and writes those they let through with
+// write. The refused ones fail on their own, with a *Rejected, like any
+// other item of a batch; a hook that can't be evaluated fails them all.
+func (n *Names) many(ctx context.Context, ns []store.Name, write func(context.Context, []store.Name) ([]error, error)) ([]error, error) {
+	errs := make([]error, len(ns))
+	var passed []store.Name
+	var at []int // index in ns of each of passed
+	for i := range ns {
+		err := n.run(ctx, &ns[i], OpCreate)
+		var rej *Rejected
+		if errors.As(err, &rej) { errs[i] = err; continue }
+		if err != nil { return nil, err }
+		passed, at = append(passed, ns[i]), append(at, i)
+	}
+	written, err := write(ctx, passed)
+	if err != nil { return nil, err }
+	for j, i := range at { ns[i], errs[i] = passed[j], written[j] } // with the IDs they were given
+	return errs, nil
+}
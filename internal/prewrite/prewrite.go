@@ -0,0 +1,250 @@
+// Package prewrite runs the hooks operators declare to have a say over
+// names before they are written: normalize them, reject some by pattern,
+// or fill in fields computed from the others. Hooks are listed in a YAML
+// file (PREWRITE_HOOKS_FILE) with expressions in Go's syntax (see Compile),
+// and run in order on every name written, by Names, after the request's own
+// validation and before the store is called.
+//
+//	hooks:
+//	  - name: no-test-names
+//	    check: '!matches(lower(name), "^test\\b")'
+//	    message: test names are not allowed
+//	  - name: tags-lower
+//	    set: tags
+//	    value: split(lower(join(tags, ",")), ",")
+//	  - name: team
+//	    set: metadata.team
+//	    value: cond(metadata.team == nil, "unassigned", metadata.team)
+//
+// The expressions aren't CEL or Starlark but a small evaluator over
+// go/parser's syntax trees. Hooks need a few string and list functions,
+// which it has in a few hundred lines of the standard library, where
+// cel-go would link ANTLR and the googleapis protos into the server;
+// Starlark is a whole language, loops and all, that would need step limits.
+// With no loops and no calls but the built-in ones, an expression always
+// ends, in time bounded by its size and the name's. And a missing metadata
+// key reads as nil, so hooks can test for it with == nil, where CEL would
+// fail the evaluation unless it was guarded with has().
+package prewrite
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"gopkg.in/yaml.v3"
+
+	"app/internal/metrics"
+	"app/internal/store"
+	"app/internal/validate"
+)
+
+// Ops: which write a hook is running for.
+const (
+	OpCreate = "create"
+	OpUpdate = "update"
+	OpUpsert = "upsert"
+)
+
+// Hook is one step. A check hook rejects the write with a 422 unless its
+// expression is true; a set hook stores the value of its expression in a
+// field: name (a string), tags (a list of strings, nil for none) or
+// metadata.<key> (any value, nil to remove the key).
+type Hook struct {
+	Name    string `yaml:"name"`
+	Check   string `yaml:"check"`
+	Message string `yaml:"message"` // the 422's message when a check fails
+	Field   string `yaml:"field"`   // the field the 422 names; defaults to name
+	Set     string `yaml:"set"`
+	Value   string `yaml:"value"`
+
+	expr *Expr
+}
+
+// Hooks are the declared hooks, in the order they run.
+type Hooks struct {
+	list []Hook
+}
+
+var (
+	hookNameRE = regexp.MustCompile(`^[a-z][a-z0-9_-]{0,63}$`)
+	metaKeyRE  = regexp.MustCompile(`^metadata\.[A-Za-z0-9_-]+$`)
+)
+
+// Load reads the hooks declared in path: {"hooks": [...]}. Unknown keys
+// are rejected to catch typos.
+func Load(path string) (*Hooks, error) {
+	f, err := os.Open(path)
+	if err != nil { return nil, err }
+	defer f.Close()
+	var file struct {
+		Hooks []Hook `yaml:"hooks"`
+	}
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+	if err := dec.Decode(&file); err != nil && !errors.Is(err, io.EOF) { return nil, fmt.Errorf("%s: %w", path, err) }
+	hooks, err := New(file.Hooks)
+	if err != nil { return nil, fmt.Errorf("%s: %w", path, err) }
+	return hooks, nil
+}
+
+// New checks the hooks and compiles their expressions.
+func New(list []Hook) (*Hooks, error) {
+	var errs []error
+	bad := func(format string, args ...any) { errs = append(errs, fmt.Errorf(format, args...)) }
+	seen := map[string]bool{}
+	for i := range list {
+		h := &list[i]
+		switch {
+		case !hookNameRE.MatchString(h.Name):
+			bad("hook %q: the name must be 1 to 64 lower-case letters, digits, dashes and underscores, starting with a letter", h.Name); continue
+		case seen[h.Name]:
+			bad("hook %q is declared twice", h.Name); continue
+		case (h.Check == "") == (h.Set == ""):
+			bad("hook %q must have either a check or a set", h.Name); continue
+		case h.Check != "" && h.Value != "":
+			bad("hook %q: value goes with set, not check", h.Name); continue
+		case h.Set != "" && (h.Message != "" || h.Field != ""):
+			bad("hook %q: message and field go with check, not set", h.Name); continue
+		case h.Set != "" && h.Set != "name" && h.Set != "tags" && !metaKeyRE.MatchString(h.Set):
+			bad("hook %q: set must be name, tags or metadata.<key>, got %q", h.Name, h.Set); continue
+		case h.Set != "" && h.Value == "":
+			bad("hook %q: set needs a value", h.Name); continue
+		}
+		seen[h.Name] = true
+		src := h.Check
+		if h.Set != "" { src = h.Value }
+		expr, err := Compile(src)
+		if err != nil { bad("hook %q: %v", h.Name, err); continue }
+		h.expr = expr
+		if h.Check != "" && h.Message == "" { h.Message = "is rejected by the " + h.Name + " hook" }
+		if h.Check != "" && h.Field == "" { h.Field = "name" }
+	}
+	if err := errors.Join(errs...); err != nil { return nil, err }
+	return &Hooks{list: list}, nil
+}
+
+// Len is how many hooks there are.
+func (hs *Hooks) Len() int {
+	if hs == nil { return 0 }
+	return len(hs.list)
+}
+
+// Apply runs the hooks on n, a name that has passed validate.Name, as
+// written by tenant in op. It stops at the first check that fails and
+// returns it as the field error to answer with; otherwise it validates n
+// again, as a set hook may have made it invalid. The error is a hook
+// that couldn't be evaluated, such as one calling lower on a number, which
+// is the hook's fault rather than the client's.
+func (hs *Hooks) Apply(n *store.Name, tenant, op string) ([]validate.FieldError, error) {
+	if hs.Len() == 0 { return nil, nil }
+	for _, h := range hs.list {
+		v, err := h.expr.Eval(env(n, tenant, op))
+		if err != nil { return nil, fmt.Errorf("prewrite hook %s: %w", h.Name, err) }
+		if h.Check != "" {
+			passed, ok := v.(bool)
+			if !ok { return nil, fmt.Errorf("prewrite hook %s: the check is a %s, not a bool", h.Name, kind(v)) }
+			if !passed {
+				metrics.PrewriteRejected(h.Name)
+				return []validate.FieldError{{Field: h.Field, Message: h.Message}}, nil
+			}
+			continue
+		}
+		if err := set(n, h.Set, v); err != nil { return nil, fmt.Errorf("prewrite hook %s: %w", h.Name, err) }
+	}
+	return validate.Name(n), nil
+}
+
+// env exposes n to the expressions as JSON would: tags as a list, the
+// metadata as jsonValue has it.
+func env(n *store.Name, tenant, op string) map[string]any {
+	var tags []any
+	for _, t := range n.Tags { tags = append(tags, t) }
+	var meta any // a nil map would be an object, not nil
+	if n.Metadata != nil { meta = jsonValue(n.Metadata) }
+	return map[string]any{"name": n.Name, "tags": tags, "metadata": meta, "tenant": tenant, "op": op}
+}
+
+// jsonValue is v with the shapes a store may give metadata read back from
+// it, such as MongoDB's primitive.A and primitive.M and its integers, turned
+// into those of decoded JSON, the only ones the expressions know.
+func jsonValue(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		m := make(map[string]any, len(v))
+		for k, e := range v { m[k] = jsonValue(e) }
+		return m
+	case primitive.M:
+		return jsonValue(map[string]any(v))
+	case primitive.D:
+		m := make(map[string]any, len(v))
+		for _, e := range v { m[e.Key] = jsonValue(e.Value) }
+		return m
+	case []any:
+		list := make([]any, len(v))
+		for i, e := range v { list[i] = jsonValue(e) }
+		return list
+	case primitive.A:
+		return jsonValue([]any(v))
+	case int:
+		return float64(v)
+	case int32:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case float32:
+		return float64(v)
+	case primitive.DateTime:
+		return v.Time().UTC().Format(time.RFC3339Nano)
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case primitive.ObjectID:
+		return v.Hex()
+	}
+	return v
+}
+
+func set(n *store.Name, field string, v any) error {
+	switch field {
+	case "name":
+		s, ok := v.(string)
+		if !ok { return fmt.Errorf("name must be set to a string, got %s", kind(v)) }
+		n.Name = s
+	case "tags":
+		list, ok := v.([]any)
+		if !ok && v != nil { return fmt.Errorf("tags must be set to a list, got %s", kind(v)) }
+		tags := make([]string, 0, len(list))
+		for _, t := range list {
+			s, ok := t.(string)
+			if !ok { return fmt.Errorf("tags must be strings, got %s", kind(t)) }
+			if s = strings.TrimSpace(s); s != "" { tags = append(tags, s) } // as split leaves them
+		}
+		if len(tags) == 0 { tags = nil }
+		n.Tags = tags
+	default:
+		key := strings.TrimPrefix(field, "metadata.")
+		if v == nil { delete(n.Metadata, key); return nil }
+		if n.Metadata == nil { n.Metadata = map[string]any{} }
+		n.Metadata[key] = clone(v) // it may be the metadata itself
+	}
+	return nil
+}
+
+func clone(v any) any {
+	switch v := v.(type) {
+	case []any:
+		out := make([]any, len(v))
+		for i, e := range v { out[i] = clone(e) }
+		return out
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for k, e := range v { out[k] = clone(e) }
+		return out
+	}
+	return v
+}
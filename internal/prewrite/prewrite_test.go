@@ -0,0 +1,197 @@
+package prewrite
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"app/internal/store"
+)
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hooks.yaml")
+	yaml := `hooks:
+  - name: no-test-names
+    check: '!matches(lower(name), "^test\\b")'
+    message: test names are not allowed
+  - name: team
+    set: metadata.team
+    value: cond(metadata.team == nil, "unassigned", metadata.team)
+`
+	if err := os.WriteFile(path, []byte(yaml), 0o600); err != nil { t.Fatal(err) }
+	hooks, err := Load(path)
+	if err != nil { t.Fatal(err) }
+	if hooks.Len() != 2 { t.Fatalf("%d hooks", hooks.Len()) }
+
+	if err := os.WriteFile(path, []byte("hooks:\n  - name: a\n    chek: 'true'\n"), 0o600); err != nil { t.Fatal(err) }
+	if _, err := Load(path); err == nil || !strings.Contains(err.Error(), "chek") { t.Fatalf("typo: %v", err) }
+}
+
+func TestNewRejects(t *testing.T) {
+	for _, tc := range []struct {
+		hooks []Hook
+		want  string
+	}{
+		{[]Hook{{Name: "A", Check: "true"}}, "lower-case"},
+		{[]Hook{{Name: "a", Check: "true"}, {Name: "a", Check: "true"}}, "declared twice"},
+		{[]Hook{{Name: "a"}}, "either a check or a set"},
+		{[]Hook{{Name: "a", Check: "true", Set: "name", Value: "name"}}, "either a check or a set"},
+		{[]Hook{{Name: "a", Check: "true", Value: "name"}}, "value goes with set"},
+		{[]Hook{{Name: "a", Set: "name", Value: "name", Message: "no"}}, "go with check"},
+		{[]Hook{{Name: "a", Set: "uuid", Value: "name"}}, "set must be name, tags or metadata.<key>"},
+		{[]Hook{{Name: "a", Set: "metadata.", Value: "name"}}, "set must be name, tags or metadata.<key>"},
+		{[]Hook{{Name: "a", Set: "name"}}, "set needs a value"},
+		{[]Hook{{Name: "a", Check: "name =="}}, "expected operand"},
+		{[]Hook{{Name: "a", Check: "nme == \"x\""}}, `unknown variable "nme"`},
+		{[]Hook{{Name: "a", Check: "exec(name)"}}, `unknown function "exec"`},
+		{[]Hook{{Name: "a", Check: "strings.ToLower(name) == name"}}, "only the built-in functions"},
+		{[]Hook{{Name: "a", Check: "lower(name, tags)"}}, "takes 1 arguments"},
+		{[]Hook{{Name: "a", Check: `matches(name, "(")`}}, "matches: error parsing regexp"},
+		{[]Hook{{Name: "a", Check: "matches(name, tenant)"}}, "string literal"},
+		{[]Hook{{Name: "a", Check: "func() bool { return true }()"}}, "only the built-in functions"},
+		{[]Hook{{Name: "a", Set: "tags", Value: "[]string{name}"}}, "isn't supported"},
+		{[]Hook{{Name: "a", Check: "len(name) << 2 > 0"}}, "operator << isn't supported"},
+	} {
+		if _, err := New(tc.hooks); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("New(%+v) = %v, want error containing %q", tc.hooks, err, tc.want)
+		}
+	}
+}
+
+func TestEval(t *testing.T) {
+	env := map[string]any{
+		"name":     "Ada Lovelace",
+		"tags":     []any{"vip", "core"},
+		"metadata": map[string]any{"team": "eng", "level": 3.0},
+		"tenant":   "acme",
+		"op":       OpCreate,
+	}
+	for _, tc := range []struct {
+		src  string
+		want any
+	}{
+		{`lower(name)`, "ada lovelace"},
+		{`upper(trim("  x "))`, "X"},
+		{`replace(name, " ", "-")`, "Ada-Lovelace"},
+		{`split(name, " ")`, []any{"Ada", "Lovelace"}},
+		{`join(tags, ",")`, "vip,core"},
+		{`hasPrefix(name, "Ada") && hasSuffix(name, "lace")`, true},
+		{`matches(name, "^[A-Z][a-z]+ [A-Z]")`, true},
+		{`len(name) + len(tags) + len(metadata)`, 16.0},
+		{`contains(tags, "vip") && contains(metadata, "team") && contains(name, "Love")`, true},
+		{`contains(tags, "nope") || contains(metadata, "nope")`, false},
+		{`metadata.team + "/" + tenant`, "eng/acme"},
+		{`metadata["level"] * 2 - 1`, 5.0},
+		{`metadata.level % 2 == 1`, true},
+		{`metadata.missing == nil && metadata.missing.deeper == nil`, true},
+		{`tags[1]`, "core"},
+		{`tags + split("a", ",")`, []any{"vip", "core", "a"}},
+		{`cond(op == "create", "new", lower(3))`, "new"}, // lower(3) isn't evaluated
+		{`string(metadata.level) + string(true) + string(nil)`, "3true"},
+		{`-metadata.level < 0 && !(name == "Bob")`, true},
+		{`"b" > "a" && 1_000 >= 1e3 && 2 <= 2.5`, true},
+		{`false && lower(3)`, false},
+		{`tags == split("vip,core", ",")`, true},
+	} {
+		e, err := Compile(tc.src)
+		if err != nil { t.Errorf("Compile(%s): %v", tc.src, err); continue }
+		got, err := e.Eval(env)
+		if err != nil { t.Errorf("%s: %v", tc.src, err); continue }
+		if !reflect.DeepEqual(got, tc.want) { t.Errorf("%s = %#v, want %#v", tc.src, got, tc.want) }
+	}
+
+	for _, tc := range []struct{ src, want string }{
+		{`lower(tags)`, "lower: wants a string, got list"},
+		{`name + 1`, "string + number"},
+		{`name && true`, "&& of a string"},
+		{`tags[2]`, "out of range"},
+		{`tags["a"]`, "whole number"},
+		{`name[0]`, "can't be indexed"},
+		{`metadata.level / 0`, "division by zero"},
+		{`tenant.id`, "field id of a string"},
+		{`-name`, "- of a string"},
+		{`cond(name, 1, 2)`, "cond: wants a bool"},
+	} {
+		e, err := Compile(tc.src)
+		if err != nil { t.Errorf("Compile(%s): %v", tc.src, err); continue }
+		if _, err := e.Eval(env); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: %v, want error containing %q", tc.src, err, tc.want)
+		}
+	}
+}
+
+func TestApply(t *testing.T) {
+	hooks, err := New([]Hook{
+		{Name: "no-test", Check: `!matches(lower(name), "^test\\b")`, Message: "test names are not allowed"},
+		{Name: "no-tags-on-update", Check: `op != "update" || len(tags) == 0`, Field: "tags", Message: "can't be changed"},
+		{Name: "tags", Set: "tags", Value: `split(lower(join(tags, ",")), ",")`},
+		{Name: "team", Set: "metadata.team", Value: `cond(metadata.team == nil, "unassigned", metadata.team)`},
+		{Name: "owner", Set: "metadata.owner", Value: `tenant`},
+		{Name: "drop-legacy", Set: "metadata.legacy", Value: `nil`},
+		{Name: "squash", Set: "name", Value: `replace(name, "_", "  ")`}, // validated again, so "Ada  Lovelace" is collapsed
+	})
+	if err != nil { t.Fatal(err) }
+
+	n := store.Name{Name: "Ada_Lovelace", Tags: []string{"VIP", "Core"}, Metadata: map[string]any{"legacy": true}}
+	errs, err := hooks.Apply(&n, "acme", OpCreate)
+	if err != nil || errs != nil { t.Fatal(errs, err) }
+	want := store.Name{Name: "Ada Lovelace", Tags: []string{"vip", "core"}, Metadata: map[string]any{"team": "unassigned", "owner": "acme"}}
+	if !reflect.DeepEqual(n, want) { t.Fatalf("got %+v, want %+v", n, want) }
+
+	n = store.Name{Name: "Grace"}
+	if errs, err := hooks.Apply(&n, "acme", OpCreate); err != nil || errs != nil || n.Tags != nil { t.Fatal(n, errs, err) }
+
+	n = store.Name{Name: "Test user"}
+	errs, err = hooks.Apply(&n, "acme", OpCreate)
+	if err != nil || len(errs) != 1 || errs[0].Field != "name" || errs[0].Message != "test names are not allowed" { t.Fatal(errs, err) }
+	if n.Metadata != nil { t.Fatal("the hooks after a failed check ran") }
+
+	n = store.Name{Name: "Ada", Tags: []string{"vip"}}
+	errs, err = hooks.Apply(&n, "acme", OpUpdate)
+	if err != nil || len(errs) != 1 || errs[0].Field != "tags" { t.Fatal(errs, err) }
+
+	// A set can make the name invalid; it is validated again.
+	bad, _ := New([]Hook{{Name: "blank", Set: "name", Value: `""`}})
+	if errs, err := bad.Apply(&store.Name{Name: "Ada"}, "acme", OpCreate); err != nil || len(errs) != 1 || errs[0].Message != "is required" { t.Fatal(errs, err) }
+
+	// A hook that can't be evaluated is an error, not the client's fault.
+	bad, _ = New([]Hook{{Name: "broken", Check: `lower(metadata.n) == ""`}})
+	if _, err := bad.Apply(&store.Name{Name: "Ada", Metadata: map[string]any{"n": 1.0}}, "acme", OpCreate); err == nil || !strings.Contains(err.Error(), "prewrite hook broken: lower") { t.Fatal(err) }
+	bad, _ = New([]Hook{{Name: "not-bool", Check: `name`}})
+	if _, err := bad.Apply(&store.Name{Name: "Ada"}, "acme", OpCreate); err == nil || !strings.Contains(err.Error(), "not a bool") { t.Fatal(err) }
+	bad, _ = New([]Hook{{Name: "tags", Set: "tags", Value: `name`}})
+	if _, err := bad.Apply(&store.Name{Name: "Ada"}, "acme", OpCreate); err == nil || !strings.Contains(err.Error(), "tags must be set to a list") { t.Fatal(err) }
+
+	// Setting a key to the metadata copies it.
+	self, _ := New([]Hook{{Name: "self", Set: "metadata.copy", Value: `metadata`}})
+	n = store.Name{Name: "Ada", Metadata: map[string]any{"a": 1.0}}
+	if _, err := self.Apply(&n, "acme", OpCreate); err != nil { t.Fatal(err) }
+	if _, ok := n.Metadata["copy"].(map[string]any)["copy"]; ok { t.Fatal("the metadata holds itself") }
+
+	var none *Hooks
+	if errs, err := none.Apply(&store.Name{Name: ""}, "acme", OpCreate); errs != nil || err != nil { t.Fatal(errs, err) }
+}
+
+// TestApplyBSON runs the hooks on metadata as MongoDB reads it back, which
+// they see as they would the JSON it was written as.
+func TestApplyBSON(t *testing.T) {
+	hooks, err := New([]Hook{
+		{Name: "teams", Check: `len(metadata.teams) == 2 && contains(metadata.teams, "ops") && join(metadata.teams, ",") == "core,ops" && metadata.teams[0] == "core"`},
+		{Name: "owner", Check: `contains(metadata.owner, "level") && metadata.owner.level == 3 && metadata.seen == 2 && len(metadata.extra) == 1`},
+		{Name: "first", Set: "metadata.first", Value: `metadata.teams[1]`},
+	})
+	if err != nil { t.Fatal(err) }
+	n := store.Name{Name: "Ada", Metadata: map[string]any{
+		"teams": primitive.A{"core", "ops"},
+		"owner": primitive.M{"level": int32(3)},
+		"seen":  int64(2),
+		"extra": primitive.D{{Key: "k", Value: "v"}},
+	}}
+	errs, err := hooks.Apply(&n, "acme", OpUpdate)
+	if err != nil || errs != nil { t.Fatal(errs, err) }
+	if n.Metadata["first"] != "ops" { t.Fatalf("set: %+v", n.Metadata) }
+}
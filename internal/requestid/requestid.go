@@ -0,0 +1,66 @@
+// Package requestid tags every request with an ID that is echoed to the
+// client and attached to the logs written while serving it.
+package requestid
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+)
+
+const Header = "X-Request-ID"
+
+type ctxKey struct{}
+
+// Middleware reuses the caller's X-Request-ID (from our gateway) or mints
+// one, stores it in the request context and echoes it back.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(Header)
+		if !Valid(id) { id = New() }
+		w.Header().Set(Header, id)
+		next.ServeHTTP(w, r.WithContext(NewContext(r.Context(), id)))
+	})
+}
+
+func NewContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, ctxKey{}, id)
+}
+
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(ctxKey{}).(string)
+	return id
+}
+
+// Incoming IDs end up in our logs, so only accept short printable ASCII.
+func Valid(id string) bool {
+	if id == "" || len(id) > 128 { return false }
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e { return false }
+	}
+	return true
+}
+
+func New() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// LogHandler adds request_id to records whose context carries one.
+type LogHandler struct{ slog.Handler }
+
+func (h LogHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := FromContext(ctx); id != "" { r.AddAttrs(slog.String("request_id", id)) }
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h LogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return LogHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h LogHandler) WithGroup(name string) slog.Handler {
+	return LogHandler{h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,257 @@
+// Package resource declares the collections served next to names without
+// code of their own: each is listed in a YAML or JSON file with its fields
+// and their rules, and gets CRUD routes at /api/v1/{resource}. Names keep
+// their hand-written handlers, which do far more than CRUD.
+package resource
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/mail"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+
+	"app/internal/validate"
+)
+
+// Field types.
+const (
+	TypeString = "string"
+	TypeInt    = "int"
+	TypeNumber = "number"
+	TypeBool   = "bool"
+	TypeTime   = "time" // RFC 3339
+)
+
+var types = []string{TypeString, TypeInt, TypeNumber, TypeBool, TypeTime}
+
+// Field is one field of a resource's documents and the rules its values
+// must follow. The rules that don't apply to its type are rejected.
+type Field struct {
+	Name      string   `yaml:"name"`
+	Type      string   `yaml:"type"`
+	Required  bool     `yaml:"required"`
+	MinLength int      `yaml:"min_length"` // strings, in characters
+	MaxLength int      `yaml:"max_length"` // strings; 0 is no limit
+	Pattern   string   `yaml:"pattern"`    // strings, a regexp the whole value must match
+	Format    string   `yaml:"format"`     // strings: email or url
+	Enum      []string `yaml:"enum"`       // strings
+	Min       *float64 `yaml:"min"`        // ints and numbers
+	Max       *float64 `yaml:"max"`
+
+	re *regexp.Regexp
+}
+
+// Resource is a collection of documents made of Fields.
+type Resource struct {
+	Name       string  `yaml:"name"`       // the path segment it is served at
+	Collection string  `yaml:"collection"` // where its documents are stored; defaults to Name
+	Fields     []Field `yaml:"fields"`
+}
+
+// Reserved are the path segments of /api/v1 taken by the hand-written
+// endpoints, which resources can't be named.
+var Reserved = []string{"names", "auth", "apikeys", "usage", "users", "jobs", "webhooks", "audit", "admin", "graphql", "openapi.json", "docs"}
+
+// metaFields are set by the store on every document, so no field can be
+// named after them.
+var metaFields = []string{"id", "_id", "tenant", "created_at", "updated_at", "version"}
+
+var nameRE = regexp.MustCompile(`^[a-z][a-z0-9_-]{0,63}$`)
+
+// Registry holds the declared resources.
+type Registry struct {
+	list   []*Resource
+	byName map[string]*Resource
+}
+
+// Load reads the resources declared in path: {"resources": [...]}. Unknown
+// keys are rejected to catch typos.
+func Load(path string) (*Registry, error) {
+	f, err := os.Open(path)
+	if err != nil { return nil, err }
+	defer f.Close()
+	var file struct {
+		Resources []Resource `yaml:"resources"`
+	}
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+	if err := dec.Decode(&file); err != nil && !errors.Is(err, io.EOF) { return nil, fmt.Errorf("%s: %w", path, err) }
+	reg, err := New(file.Resources)
+	if err != nil { return nil, fmt.Errorf("%s: %w", path, err) }
+	return reg, nil
+}
+
+// New checks the declarations and fills in their defaults.
+func New(resources []Resource) (*Registry, error) {
+	reg := &Registry{byName: map[string]*Resource{}}
+	collections := map[string]bool{}
+	var errs []error
+	bad := func(format string, args ...any) { errs = append(errs, fmt.Errorf(format, args...)) }
+	for i := range resources {
+		res := &resources[i]
+		switch {
+		case !nameRE.MatchString(res.Name):
+			bad("resource %q: the name must be 1 to 64 lower-case letters, digits, dashes and underscores, starting with a letter", res.Name); continue
+		case slices.Contains(Reserved, res.Name):
+			bad("resource %q: the name is taken by a built-in endpoint", res.Name); continue
+		case reg.byName[res.Name] != nil:
+			bad("resource %q is declared twice", res.Name); continue
+		}
+		if res.Collection == "" { res.Collection = res.Name }
+		if collections[res.Collection] { bad("resource %q: collection %q is used by another resource", res.Name, res.Collection) }
+		collections[res.Collection] = true
+		if len(res.Fields) == 0 { bad("resource %q has no fields", res.Name) }
+		seen := map[string]bool{}
+		for j := range res.Fields {
+			f := &res.Fields[j]
+			if seen[f.Name] { bad("resource %q: field %q is declared twice", res.Name, f.Name) }
+			seen[f.Name] = true
+			if err := f.check(); err != nil { bad("resource %q, field %q: %v", res.Name, f.Name, err) }
+		}
+		reg.list = append(reg.list, res)
+		reg.byName[res.Name] = res
+	}
+	return reg, errors.Join(errs...)
+}
+
+func (f *Field) check() error {
+	switch {
+	case !nameRE.MatchString(f.Name) || slices.Contains(metaFields, f.Name):
+		return errors.New("the name must be lower-case letters, digits, dashes and underscores, starting with a letter, and not one of " + strings.Join(metaFields, ", "))
+	case !slices.Contains(types, f.Type):
+		return fmt.Errorf("type must be one of %s, got %q", strings.Join(types, ", "), f.Type)
+	case f.Type != TypeString && (f.MinLength != 0 || f.MaxLength != 0 || f.Pattern != "" || f.Format != "" || f.Enum != nil):
+		return errors.New("min_length, max_length, pattern, format and enum only apply to strings")
+	case f.Type != TypeInt && f.Type != TypeNumber && (f.Min != nil || f.Max != nil):
+		return errors.New("min and max only apply to ints and numbers")
+	case f.MinLength < 0 || f.MaxLength < 0 || (f.MaxLength > 0 && f.MinLength > f.MaxLength):
+		return errors.New("min_length and max_length must be >= 0, and min_length at most max_length")
+	case f.Min != nil && f.Max != nil && *f.Min > *f.Max:
+		return errors.New("min exceeds max")
+	case f.Format != "" && f.Format != "email" && f.Format != "url":
+		return fmt.Errorf("format must be email or url, got %q", f.Format)
+	}
+	if f.Pattern != "" {
+		re, err := regexp.Compile(`^(?:` + f.Pattern + `)$`)
+		if err != nil { return fmt.Errorf("pattern: %w", err) }
+		f.re = re
+	}
+	return nil
+}
+
+// Lookup returns the resource served at name.
+func (r *Registry) Lookup(name string) (*Resource, bool) {
+	if r == nil { return nil, false }
+	res, ok := r.byName[name]
+	return res, ok
+}
+
+// Resources lists the resources in the order they were declared.
+func (r *Registry) Resources() []*Resource {
+	if r == nil { return nil }
+	return r.list
+}
+
+// Collections lists where the resources' documents are stored.
+func (r *Registry) Collections() []string {
+	var cs []string
+	for _, res := range r.Resources() { cs = append(cs, res.Collection) }
+	return cs
+}
+
+// Validate checks a document decoded from JSON against the resource's
+// fields and returns its values as stored: strings trimmed, ints as int64,
+// times as UTC time.Time to the millisecond, as BSON keeps them. Nulls
+// count as absent, and unknown fields are errors.
+func (res *Resource) Validate(doc map[string]any) (map[string]any, []validate.FieldError) {
+	var errs []validate.FieldError
+	add := func(field, format string, args ...any) {
+		errs = append(errs, validate.FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+	out := make(map[string]any, len(doc))
+	for name := range doc {
+		if !slices.ContainsFunc(res.Fields, func(f Field) bool { return f.Name == name }) { add(name, "is not a field of %s", res.Name) }
+	}
+	for _, f := range res.Fields {
+		v := doc[f.Name]
+		if v == nil {
+			if f.Required { add(f.Name, "is required") }
+			continue
+		}
+		clean, msg := f.value(v)
+		if msg != "" { add(f.Name, "%s", msg); continue }
+		out[f.Name] = clean
+	}
+	slices.SortFunc(errs, func(a, b validate.FieldError) int { return strings.Compare(a.Field, b.Field) })
+	return out, errs
+}
+
+// value converts v to the field's type and checks it, returning why it
+// doesn't fit if it doesn't.
+func (f *Field) value(v any) (any, string) {
+	switch f.Type {
+	case TypeString:
+		s, ok := v.(string)
+		if !ok { return nil, "must be a string" }
+		return f.str(strings.TrimSpace(s))
+	case TypeInt:
+		n, ok := v.(float64)
+		if !ok || n != math.Trunc(n) || math.Abs(n) > 1<<53 { return nil, "must be an integer" }
+		if msg := f.bounds(n); msg != "" { return nil, msg }
+		return int64(n), ""
+	case TypeNumber:
+		n, ok := v.(float64)
+		if !ok { return nil, "must be a number" }
+		if msg := f.bounds(n); msg != "" { return nil, msg }
+		return n, ""
+	case TypeBool:
+		b, ok := v.(bool)
+		if !ok { return nil, "must be true or false" }
+		return b, ""
+	default: // TypeTime
+		s, ok := v.(string)
+		if !ok { return nil, "must be an RFC 3339 time" }
+		t, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil { return nil, "must be an RFC 3339 time" }
+		return t.UTC().Truncate(time.Millisecond), ""
+	}
+}
+
+func (f *Field) str(s string) (any, string) {
+	n := utf8.RuneCountInString(s)
+	switch {
+	case f.Required && s == "":
+		return nil, "is required"
+	case n < f.MinLength:
+		return nil, fmt.Sprintf("must be at least %d characters", f.MinLength)
+	case f.MaxLength > 0 && n > f.MaxLength:
+		return nil, fmt.Sprintf("must be at most %d characters", f.MaxLength)
+	case f.Enum != nil && !slices.Contains(f.Enum, s):
+		return nil, "must be one of " + strings.Join(f.Enum, ", ")
+	case f.re != nil && !f.re.MatchString(s):
+		return nil, "must match " + f.Pattern
+	}
+	switch f.Format {
+	case "email":
+		if a, err := mail.ParseAddress(s); err != nil || a.Address != s { return nil, "must be an email address" }
+	case "url":
+		if u, err := url.Parse(s); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" { return nil, "must be an http or https URL" }
+	}
+	return s, ""
+}
+
+func (f *Field) bounds(n float64) string {
+	if f.Min != nil && n < *f.Min { return fmt.Sprintf("must be at least %v", *f.Min) }
+	if f.Max != nil && n > *f.Max { return fmt.Sprintf("must be at most %v", *f.Max) }
+	return ""
+}
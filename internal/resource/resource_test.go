@@ -0,0 +1,91 @@
+package resource
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "resources.yaml")
+	yaml := `resources:
+  - name: emails
+    fields:
+      - {name: address, type: string, required: true, format: email}
+  - name: addresses
+    collection: postal_addresses
+    fields:
+      - {name: street, type: string, max_length: 100}
+      - {name: zip, type: string, pattern: "[0-9]{5}"}
+`
+	if err := os.WriteFile(path, []byte(yaml), 0o600); err != nil { t.Fatal(err) }
+	reg, err := Load(path)
+	if err != nil { t.Fatal(err) }
+	if cs := reg.Collections(); len(cs) != 2 || cs[0] != "emails" || cs[1] != "postal_addresses" { t.Fatalf("collections %q", cs) }
+	if _, found := reg.Lookup("addresses"); !found { t.Fatal("addresses not declared") }
+	if _, found := reg.Lookup("phones"); found { t.Fatal("phones declared") }
+
+	if err := os.WriteFile(path, []byte("resources:\n  - name: emails\n    feilds: []\n"), 0o600); err != nil { t.Fatal(err) }
+	if _, err := Load(path); err == nil || !strings.Contains(err.Error(), "feilds") { t.Fatalf("typo: %v", err) }
+}
+
+func TestNewRejects(t *testing.T) {
+	str := []Field{{Name: "a", Type: TypeString}}
+	one := 1.0
+	for _, tc := range []struct {
+		res  []Resource
+		want string
+	}{
+		{[]Resource{{Name: "Emails", Fields: str}}, "lower-case"},
+		{[]Resource{{Name: "names", Fields: str}}, "taken by a built-in endpoint"},
+		{[]Resource{{Name: "a", Fields: str}, {Name: "a", Fields: str}}, "declared twice"},
+		{[]Resource{{Name: "a", Fields: str}, {Name: "b", Collection: "a", Fields: str}}, "used by another resource"},
+		{[]Resource{{Name: "a"}}, "has no fields"},
+		{[]Resource{{Name: "a", Fields: []Field{{Name: "id", Type: TypeString}}}}, "not one of"},
+		{[]Resource{{Name: "a", Fields: []Field{{Name: "n", Type: "float"}}}}, "type must be one of"},
+		{[]Resource{{Name: "a", Fields: []Field{{Name: "n", Type: TypeInt, MaxLength: 3}}}}, "only apply to strings"},
+		{[]Resource{{Name: "a", Fields: []Field{{Name: "s", Type: TypeString, Min: &one}}}}, "only apply to ints and numbers"},
+		{[]Resource{{Name: "a", Fields: []Field{{Name: "s", Type: TypeString, Pattern: "("}}}}, "pattern"},
+		{[]Resource{{Name: "a", Fields: []Field{{Name: "s", Type: TypeString, Format: "phone"}}}}, "format must be email or url"},
+	} {
+		if _, err := New(tc.res); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("New(%+v) = %v, want error containing %q", tc.res, err, tc.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	zero, ten := 0.0, 10.0
+	reg, err := New([]Resource{{Name: "contacts", Fields: []Field{
+		{Name: "email", Type: TypeString, Required: true, Format: "email"},
+		{Name: "site", Type: TypeString, Format: "url"},
+		{Name: "kind", Type: TypeString, Enum: []string{"home", "work"}},
+		{Name: "zip", Type: TypeString, Pattern: "[0-9]{5}"},
+		{Name: "rank", Type: TypeInt, Min: &zero, Max: &ten},
+		{Name: "score", Type: TypeNumber},
+		{Name: "active", Type: TypeBool},
+		{Name: "seen_at", Type: TypeTime},
+	}}})
+	if err != nil { t.Fatal(err) }
+	res, _ := reg.Lookup("contacts")
+
+	out, errs := res.Validate(map[string]any{
+		"email": " a@example.com ", "rank": 3.0, "score": 2.5, "active": true, "seen_at": "2026-10-15T11:30:00.123456+02:00", "site": nil,
+	})
+	if errs != nil { t.Fatalf("valid document: %v", errs) }
+	if out["email"] != "a@example.com" || out["rank"] != int64(3) || out["score"] != 2.5 || out["active"] != true { t.Fatalf("cleaned %v", out) }
+	if seen := out["seen_at"].(time.Time); !seen.Equal(time.Date(2026, 10, 15, 9, 30, 0, 123e6, time.UTC)) || seen.Location() != time.UTC { t.Fatalf("seen_at %v", seen) }
+	if _, present := out["site"]; present { t.Fatal("null kept") }
+
+	_, errs = res.Validate(map[string]any{
+		"site": "ftp://example.com", "kind": "school", "zip": "1234", "rank": 2.5, "score": "high", "active": "yes", "seen_at": "yesterday", "phone": "555",
+	})
+	got := map[string]bool{}
+	for _, e := range errs { got[e.Field] = true }
+	for _, field := range []string{"email", "site", "kind", "zip", "rank", "score", "active", "seen_at", "phone"} {
+		if !got[field] { t.Errorf("no error for %s in %v", field, errs) }
+	}
+	if _, errs := res.Validate(map[string]any{"email": "a@example.com", "rank": 11.0}); len(errs) != 1 || errs[0].Field != "rank" { t.Fatalf("above max: %v", errs) }
+}
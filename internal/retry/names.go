@@ -0,0 +1,115 @@
+package retry
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"app/internal/store"
+)
+
+// Names wraps a NameStore and retries its operations under a Policy. It
+// sits directly on the store, beneath the audit log, history and cache, so
+// each retried write is still recorded once.
+type Names struct {
+	store.NameStore
+	p Policy
+}
+
+func NewNames(s store.NameStore, p Policy) *Names { return &Names{NameStore: s, p: p} }
+
+// none adapts an operation returning only an error.
+func none(err error) (struct{}, error) { return struct{}{}, err }
+
+// ---- reads ----
+
+func (r *Names) Get(ctx context.Context, id primitive.ObjectID) (store.Name, error) {
+	return read(ctx, r.p, "get", func() (store.Name, error) { return r.NameStore.Get(ctx, id) })
+}
+
+func (r *Names) Lookup(ctx context.Context, ids []primitive.ObjectID) (map[primitive.ObjectID]store.Name, error) {
+	return read(ctx, r.p, "lookup", func() (map[primitive.ObjectID]store.Name, error) { return r.NameStore.Lookup(ctx, ids) })
+}
+
+func (r *Names) List(ctx context.Context, opts store.ListOptions) (store.Page, error) {
+	return read(ctx, r.p, "list", func() (store.Page, error) { return r.NameStore.List(ctx, opts) })
+}
+
+// Each is only retried until fn has seen a name; after that a retry would
+// hand it the same names twice.
+func (r *Names) Each(ctx context.Context, opts store.ListOptions, fn func(store.Name) error) error {
+	started := false
+	_, err := do(ctx, r.p, "each", func(err error) bool { return !started && r.p.Retryable(err) }, func() (struct{}, error) {
+		return none(r.NameStore.Each(ctx, opts, func(n store.Name) error { started = true; return fn(n) }))
+	})
+	return err
+}
+
+func (r *Names) Events(ctx context.Context, id primitive.ObjectID) ([]store.NameEvent, error) {
+	return read(ctx, r.p, "events", func() ([]store.NameEvent, error) { return r.NameStore.Events(ctx, id) })
+}
+
+func (r *Names) Search(ctx context.Context, opts store.SearchOptions) ([]store.SearchHit, error) {
+	return read(ctx, r.p, "search", func() ([]store.SearchHit, error) { return r.NameStore.Search(ctx, opts) })
+}
+
+func (r *Names) ExistingNames(ctx context.Context, names []string) (map[string]bool, error) {
+	return read(ctx, r.p, "existing_names", func() (map[string]bool, error) { return r.NameStore.ExistingNames(ctx, names) })
+}
+
+func (r *Names) Watch(ctx context.Context, after string) (store.ChangeStream, error) {
+	return read(ctx, r.p, "watch", func() (store.ChangeStream, error) { return r.NameStore.Watch(ctx, after) })
+}
+
+// ---- writes ----
+
+func (r *Names) Create(ctx context.Context, n *store.Name) error {
+	_, err := write(ctx, r.p, "create", func() (struct{}, error) { return none(r.NameStore.Create(ctx, n)) })
+	return err
+}
+
+func (r *Names) CreateIfAbsent(ctx context.Context, n *store.Name) (bool, error) {
+	return write(ctx, r.p, "create_if_absent", func() (bool, error) { return r.NameStore.CreateIfAbsent(ctx, n) })
+}
+
+func (r *Names) Upsert(ctx context.Context, n store.Name, ifVersion int64) (before *store.Name, after store.Name, err error) {
+	_, err = write(ctx, r.p, "upsert", func() (struct{}, error) {
+		before, after, err = r.NameStore.Upsert(ctx, n, ifVersion)
+		return none(err)
+	})
+	return before, after, err
+}
+
+func (r *Names) Update(ctx context.Context, id primitive.ObjectID, n store.Name, ifVersion int64) (store.Name, error) {
+	return write(ctx, r.p, "update", func() (store.Name, error) { return r.NameStore.Update(ctx, id, n, ifVersion) })
+}
+
+func (r *Names) Patch(ctx context.Context, id primitive.ObjectID, p store.NamePatch, ifVersion int64) (store.Name, error) {
+	return write(ctx, r.p, "patch", func() (store.Name, error) { return r.NameStore.Patch(ctx, id, p, ifVersion) })
+}
+
+func (r *Names) SoftDelete(ctx context.Context, id primitive.ObjectID, ifVersion int64) error {
+	_, err := write(ctx, r.p, "soft_delete", func() (struct{}, error) { return none(r.NameStore.SoftDelete(ctx, id, ifVersion)) })
+	return err
+}
+
+func (r *Names) HardDelete(ctx context.Context, id primitive.ObjectID, ifVersion int64) error {
+	_, err := write(ctx, r.p, "hard_delete", func() (struct{}, error) { return none(r.NameStore.HardDelete(ctx, id, ifVersion)) })
+	return err
+}
+
+func (r *Names) Restore(ctx context.Context, id primitive.ObjectID) (store.Name, error) {
+	return write(ctx, r.p, "restore", func() (store.Name, error) { return r.NameStore.Restore(ctx, id) })
+}
+
+func (r *Names) CreateMany(ctx context.Context, ns []store.Name) ([]error, error) {
+	return write(ctx, r.p, "create_many", func() ([]error, error) { return r.NameStore.CreateMany(ctx, ns) })
+}
+
+func (r *Names) DeleteMany(ctx context.Context, ids []primitive.ObjectID, hard bool) (map[primitive.ObjectID]bool, error) {
+	return write(ctx, r.p, "delete_many", func() (map[primitive.ObjectID]bool, error) { return r.NameStore.DeleteMany(ctx, ids, hard) })
+}
+
+func (r *Names) InsertMany(ctx context.Context, ns []store.Name) ([]error, error) {
+	return write(ctx, r.p, "insert_many", func() ([]error, error) { return r.NameStore.InsertMany(ctx, ns) })
+}
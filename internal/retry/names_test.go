@@ -0,0 +1,81 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"app/internal/store"
+)
+
+var (
+	errBlip    = errors.New("connection reset") // may have been applied
+	errRefused = errors.New("not primary")      // certainly wasn't
+)
+
+// flaky fails each call with the next of its errors, then passes it on.
+type flaky struct {
+	store.NameStore
+	errs  []error
+	calls int
+}
+
+func (f *flaky) fail() error {
+	f.calls++
+	if len(f.errs) == 0 { return nil }
+	err := f.errs[0]
+	f.errs = f.errs[1:]
+	return err
+}
+
+func (f *flaky) Get(ctx context.Context, id primitive.ObjectID) (store.Name, error) {
+	if err := f.fail(); err != nil { return store.Name{}, err }
+	return f.NameStore.Get(ctx, id)
+}
+
+func (f *flaky) Create(ctx context.Context, n *store.Name) error {
+	if err := f.fail(); err != nil { return err }
+	return f.NameStore.Create(ctx, n)
+}
+
+// Each fails, if it does, after handing over the names.
+func (f *flaky) Each(ctx context.Context, opts store.ListOptions, fn func(store.Name) error) error {
+	err := f.fail()
+	if e := f.NameStore.Each(ctx, opts, fn); e != nil { return e }
+	return err
+}
+
+func TestNames(t *testing.T) {
+	ctx := context.Background()
+	f := &flaky{NameStore: store.NewMemoryNames()}
+	s := NewNames(f, Policy{
+		MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 2 * time.Millisecond,
+		Retryable: func(err error) bool { return errors.Is(err, errBlip) || errors.Is(err, errRefused) },
+		Unapplied: func(err error) bool { return errors.Is(err, errRefused) },
+	})
+	try := func(errs ...error) { f.errs, f.calls = errs, 0 }
+
+	n := store.Name{Name: "alice"}
+	if try(errRefused, errRefused); s.Create(ctx, &n) != nil || f.calls != 3 { t.Fatalf("create after refusals: %d calls", f.calls) }
+	if try(errBlip); !errors.Is(s.Create(ctx, &store.Name{Name: "bob"}), errBlip) || f.calls != 1 { t.Fatalf("create retried after an ambiguous error: %d calls", f.calls) }
+
+	if try(errBlip, errRefused); func() error { _, err := s.Get(ctx, n.ID); return err }() != nil || f.calls != 3 { t.Fatalf("get: %d calls", f.calls) }
+	if try(errBlip, errBlip, errBlip); func() error { _, err := s.Get(ctx, n.ID); return err }() == nil || f.calls != 3 { t.Fatalf("attempts not capped: %d calls", f.calls) }
+	if try(errors.New("bad query")); func() error { _, err := s.Get(ctx, n.ID); return err }() == nil || f.calls != 1 { t.Fatalf("permanent error retried: %d calls", f.calls) }
+
+	// Once fn has seen a name, the stream isn't started over.
+	seen := 0
+	if try(errBlip); !errors.Is(s.Each(ctx, store.ListOptions{}, func(store.Name) error { seen++; return nil }), errBlip) || seen != 1 || f.calls != 1 {
+		t.Fatalf("each: saw %d names in %d calls", seen, f.calls)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	p := Policy{BaseDelay: 10 * time.Millisecond, MaxDelay: 50 * time.Millisecond}
+	for n, max := range map[int]time.Duration{1: 10 * time.Millisecond, 2: 20 * time.Millisecond, 3: 40 * time.Millisecond, 4: 50 * time.Millisecond, 60: 50 * time.Millisecond} {
+		if d := p.backoff(n); d < max/2 || d > max { t.Errorf("retry %d waits %s, want %s to %s", n, d, max/2, max) }
+	}
+}
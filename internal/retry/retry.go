@@ -0,0 +1,66 @@
+// Package retry tries store operations again after transient failures, such
+// as a network blip or a replica set election, instead of answering 500.
+package retry
+
+import (
+	"context"
+	"log/slog"
+	"math/rand/v2"
+	"time"
+
+	"app/internal/metrics"
+	"app/internal/store"
+)
+
+// Policy says how often and how patiently to retry.
+type Policy struct {
+	MaxAttempts int           // the first try included; 1 or less never retries
+	BaseDelay   time.Duration // wait before the first retry, doubled for each one after
+	MaxDelay    time.Duration // cap on the wait
+	// Retryable tells which errors are worth another attempt. Reads are
+	// retried after those it accepts, writes only after those Unapplied
+	// accepts, since a write may have gone through before failing.
+	Retryable func(error) bool
+	Unapplied func(error) bool
+}
+
+// backoff returns the wait before retry n (1 for the first): exponential,
+// capped, and with jitter so clients failing together don't retry together.
+func (p Policy) backoff(n int) time.Duration {
+	d := p.MaxDelay
+	if shift := n - 1; shift < 32 && p.BaseDelay<<shift < d { d = p.BaseDelay << shift }
+	if d <= 0 { return 0 }
+	return d/2 + rand.N(d/2+1)
+}
+
+// do runs fn until it succeeds, fails with an error retryable rejects, runs
+// out of attempts or ctx ends, and returns its last result. Inside a
+// transaction it runs fn once: a failure there aborts the transaction,
+// which is retried as a whole, if at all.
+func do[T any](ctx context.Context, p Policy, op string, retryable func(error) bool, fn func() (T, error)) (T, error) {
+	for attempt := 1; ; attempt++ {
+		v, err := fn()
+		if err == nil || attempt >= p.MaxAttempts || !retryable(err) || ctx.Err() != nil || store.InTransaction(ctx) { return v, err }
+
+		wait := p.backoff(attempt)
+		slog.WarnContext(ctx, "retrying store operation", "op", op, "attempt", attempt+1, "wait", wait, "err", err)
+		t := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return v, err
+		case <-t.C:
+		}
+		metrics.StoreRetry(op)
+	}
+}
+
+// read retries fn after any retryable error, write only after one that
+// left nothing applied.
+func read[T any](ctx context.Context, p Policy, op string, fn func() (T, error)) (T, error) {
+	return do(ctx, p, op, p.Retryable, fn)
+}
+
+func write[T any](ctx context.Context, p Policy, op string, fn func() (T, error)) (T, error) {
+	return do(ctx, p, op, p.Unapplied, fn)
+}
@@ -0,0 +1,93 @@
+package revision
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"app/internal/store"
+)
+
+// Names wraps a NameStore and counts the writes to each tenant's names in
+// a RevisionStore, for GET /names to derive its ETag and Last-Modified
+// from without listing anything. Reads pass straight through.
+//
+// Like the cache's generations, the count goes up whether or not the write
+// succeeded: a failed batch may still have changed some names, and a
+// spurious bump only costs clients one full response.
+type Names struct {
+	store.NameStore
+	revs store.RevisionStore
+}
+
+func NewNames(s store.NameStore, revs store.RevisionStore) *Names {
+	return &Names{NameStore: s, revs: revs}
+}
+
+// bump counts a write to the names of the tenant in ctx, once the
+// transaction it may be part of has committed. A failure is logged: until
+// the next write, clients may be told their copy of a listing is current.
+func (n *Names) bump(ctx context.Context) {
+	store.AfterCommit(ctx, func(ctx context.Context) {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+		defer cancel()
+		if err := n.revs.Bump(ctx); err != nil { slog.ErrorContext(ctx, "counting a write to names", "err", err) }
+	})
+}
+
+func (n *Names) Create(ctx context.Context, name *store.Name) error {
+	defer n.bump(ctx)
+	return n.NameStore.Create(ctx, name)
+}
+
+func (n *Names) CreateIfAbsent(ctx context.Context, name *store.Name) (bool, error) {
+	defer n.bump(ctx)
+	return n.NameStore.CreateIfAbsent(ctx, name)
+}
+
+func (n *Names) Upsert(ctx context.Context, name store.Name, ifVersion int64) (*store.Name, store.Name, error) {
+	defer n.bump(ctx)
+	return n.NameStore.Upsert(ctx, name, ifVersion)
+}
+
+func (n *Names) Update(ctx context.Context, id primitive.ObjectID, name store.Name, ifVersion int64) (store.Name, error) {
+	defer n.bump(ctx)
+	return n.NameStore.Update(ctx, id, name, ifVersion)
+}
+
+func (n *Names) Patch(ctx context.Context, id primitive.ObjectID, p store.NamePatch, ifVersion int64) (store.Name, error) {
+	defer n.bump(ctx)
+	return n.NameStore.Patch(ctx, id, p, ifVersion)
+}
+
+func (n *Names) SoftDelete(ctx context.Context, id primitive.ObjectID, ifVersion int64) error {
+	defer n.bump(ctx)
+	return n.NameStore.SoftDelete(ctx, id, ifVersion)
+}
+
+func (n *Names) HardDelete(ctx context.Context, id primitive.ObjectID, ifVersion int64) error {
+	defer n.bump(ctx)
+	return n.NameStore.HardDelete(ctx, id, ifVersion)
+}
+
+func (n *Names) Restore(ctx context.Context, id primitive.ObjectID) (store.Name, error) {
+	defer n.bump(ctx)
+	return n.NameStore.Restore(ctx, id)
+}
+
+func (n *Names) CreateMany(ctx context.Context, ns []store.Name) ([]error, error) {
+	defer n.bump(ctx)
+	return n.NameStore.CreateMany(ctx, ns)
+}
+
+func (n *Names) InsertMany(ctx context.Context, ns []store.Name) ([]error, error) {
+	defer n.bump(ctx)
+	return n.NameStore.InsertMany(ctx, ns)
+}
+
+func (n *Names) DeleteMany(ctx context.Context, ids []primitive.ObjectID, hard bool) (map[primitive.ObjectID]bool, error) {
+	defer n.bump(ctx)
+	return n.NameStore.DeleteMany(ctx, ids, hard)
+}
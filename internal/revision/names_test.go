@@ -0,0 +1,48 @@
+package revision
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"app/internal/store"
+	"app/internal/tenant"
+)
+
+func TestNames(t *testing.T) {
+	ctx := context.Background()
+	names, revs := store.NewMemoryNames(), store.NewMemoryRevisions()
+	s := NewNames(names, revs)
+	at := func(ctx context.Context) int64 {
+		t.Helper()
+		rev, err := revs.Revision(ctx)
+		if err != nil { t.Fatal(err) }
+		return rev.N
+	}
+	if at(ctx) != 0 { t.Fatal("revision before any write") }
+
+	n := store.Name{Name: "alice"}
+	if err := s.Create(ctx, &n); err != nil { t.Fatal(err) }
+	if _, err := s.Patch(ctx, n.ID, store.NamePatch{Tags: &[]string{"vip"}}, store.AnyVersion); err != nil { t.Fatal(err) }
+	if at(ctx) != 2 { t.Fatalf("revision %d after two writes", at(ctx)) }
+	_ = s.Create(ctx, &store.Name{Name: "alice"}) // duplicate
+	if at(ctx) != 3 { t.Fatal("failed write wasn't counted") }
+	if _, _ = s.Get(ctx, n.ID); at(ctx) != 3 { t.Fatal("read was counted") }
+
+	// Inside a transaction the count waits for the commit, and a rollback
+	// leaves it alone.
+	fail := errors.New("rolled back")
+	err := names.InTransaction(ctx, func(ctx context.Context) error {
+		if err := s.SoftDelete(ctx, n.ID, store.AnyVersion); err != nil { return err }
+		if at(ctx) != 3 { t.Error("counted before the commit") }
+		return fail
+	})
+	if !errors.Is(err, fail) || at(ctx) != 3 { t.Fatalf("after rollback: %v, revision %d", err, at(ctx)) }
+	if err := names.InTransaction(ctx, func(ctx context.Context) error { return s.SoftDelete(ctx, n.ID, store.AnyVersion) }); err != nil { t.Fatal(err) }
+	if at(ctx) != 4 { t.Fatalf("revision %d after commit", at(ctx)) }
+
+	// Each tenant has a count of its own.
+	other := tenant.NewContext(ctx, "team-b")
+	_ = s.Create(other, &store.Name{Name: "bob"})
+	if at(other) != 1 || at(ctx) != 4 { t.Fatalf("revisions %d and %d", at(other), at(ctx)) }
+}
@@ -0,0 +1,1004 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"app/internal/audit"
+	"app/internal/auth"
+	"app/internal/backup"
+	"app/internal/changes"
+	"app/internal/chaos"
+	"app/internal/codec"
+	"app/internal/handlers"
+	"app/internal/history"
+	"app/internal/ids"
+	"app/internal/jobs"
+	"app/internal/leader"
+	"app/internal/normalize"
+	"app/internal/notes"
+	"app/internal/owner"
+	"app/internal/prewrite"
+	"app/internal/resource"
+	"app/internal/revision"
+	"app/internal/shadow"
+	"app/internal/store"
+	"app/internal/tenant"
+	"app/internal/usage"
+	"app/internal/webhook"
+	"app/ui"
+)
+
+// stores is the storage an API under test runs on.
+type stores struct {
+	names store.NameStore
+	users store.UserStore
+	keys  store.APIKeyStore
+	idem  store.IdempotencyStore
+	audit store.AuditStore
+	hist  store.HistoryStore
+	notes store.NoteStore
+	stats store.StatsStore
+	dups  store.DuplicateStore
+	rand  store.SampleStore
+	byKey store.NameKeyStore
+	forms store.NormalizedStore
+	docs  store.DocStore
+	jobs  store.JobStore
+	hooks store.WebhookStore
+	tx    store.Transactor
+	revs  store.RevisionStore
+	usage store.UsageStore
+	pool  handlers.PoolStatter // nil for the memory stores
+	copy  *shadow.Names        // the names store, copying writes to a shadow; nil unless memory
+	log   *changes.Names       // the names store, logging its writes
+	pre   *prewrite.Hooks      // run on every name written; nil has none
+}
+
+// changeConfig is the change log's under test: short polls, so that the
+// writes of another server, which there aren't, would be seen quickly.
+var changeConfig = changes.Config{Retention: time.Hour, MaxWait: 5 * time.Second, Poll: 50 * time.Millisecond}
+
+// testResources declares the emails resource testAPI works with.
+func testResources(t *testing.T) *resource.Registry {
+	t.Helper()
+	reg, err := resource.New([]resource.Resource{{Name: "emails", Fields: []resource.Field{
+		{Name: "address", Type: resource.TypeString, Required: true, Format: "email"},
+		{Name: "label", Type: resource.TypeString, Enum: []string{"home", "work"}},
+		{Name: "primary", Type: resource.TypeBool},
+	}}})
+	if err != nil { t.Fatal(err) }
+	return reg
+}
+
+func memoryStores() stores {
+	names, trail, hist := store.NewMemoryNames(), store.NewMemoryAudit(), store.NewMemoryHistory()
+	nts, revs := store.NewMemoryNotes(names), store.NewMemoryRevisions()
+	copied := shadow.NewNames(names, store.NewMemoryNames(), time.Second)
+	logged := changes.NewNames(owner.NewNames(notes.NewNames(revision.NewNames(audit.NewNames(history.NewNames(copied, hist), trail), revs), nts, notes.Block), names), names, store.NewMemoryChangeLog(time.Hour), changeConfig)
+	return stores{
+		names: ids.NewNames(logged, names, ids.Slug), copy: copied, log: logged, users: store.NewMemoryUsers(), keys: store.NewMemoryAPIKeys(),
+		idem: store.NewMemoryIdempotency(), audit: trail, hist: hist, notes: nts, stats: names, dups: names, rand: names, byKey: names, forms: names, docs: store.NewMemoryDocs(),
+		jobs: store.NewMemoryJobs(), hooks: store.NewMemoryWebhooks(), tx: names, revs: revs, usage: store.NewMemoryUsage(),
+	}
+}
+
+// client drives the complete HTTP stack, as Server.Handler builds it, through
+// a real listener, and notes which routes it has been asked for.
+type client struct {
+	t     *testing.T
+	url   string
+	mux   *http.ServeMux
+	routes []route
+	hit   map[string]bool // route patterns requested
+	token string          // sent as the bearer token if set
+}
+
+func newAPI(t *testing.T, st stores, cfg Config) *client {
+	t.Helper()
+	tokens := auth.NewTokens([]byte("test-secret"), time.Hour)
+	pool := jobs.New(st.jobs, jobs.Config{Workers: 1, Lease: time.Second, Poll: 10 * time.Millisecond, Retention: time.Hour, MaxAttempts: 3})
+	hooks := webhook.New(st.hooks, webhook.Config{Workers: 1, Timeout: time.Second, Poll: 10 * time.Millisecond, MaxAttempts: 3, Backoff: 10 * time.Millisecond, MaxBackoff: time.Second, Retention: time.Hour})
+	normalized := normalize.NewNames(webhook.NewNames(st.names, hooks), st.forms, normalize.Rules{LowerWords: normalize.DefaultLowerWords})
+	var names store.NameStore = normalized
+	if st.pre != nil { names = prewrite.NewNames(normalized, st.pre) }
+	elector := leader.New(store.NewMemoryLeases(), leader.Config{ID: "api-test", TTL: time.Second, Renew: 100 * time.Millisecond})
+	h := handlers.New(handlers.Deps{
+		Names: names, Tx: st.tx, Users: st.users, APIKeys: st.keys, Audit: st.audit, History: st.hist, Stats: st.stats, Dups: st.dups, Sample: st.rand, NameKeys: st.byKey, Tokens: tokens, Pool: st.pool,
+		Notes: st.notes, Docs: st.docs, Revisions: st.revs, Resources: testResources(t), Jobs: pool, Webhooks: hooks, Captures: cfg.Capture.Sink, Usage: cfg.Usage, Shadow: st.copy, Changes: st.log, Backups: backup.Dir(t.TempDir()), Normalize: normalized, Leader: elector,
+		AllowHardDelete: true, AllowSeed: true, ImportMaxBytes: 1 << 20,
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 2)
+	go func() { done <- pool.Run(ctx) }()
+	elector.Lead("webhooks", hooks.Run)
+	go func() { done <- elector.Run(ctx) }()
+	t.Cleanup(func() { cancel(); <-done; <-done })
+	if cfg.MaxBodyBytes == 0 { cfg.MaxBodyBytes = 1 << 20 }
+	if cfg.IdempotencyTTL == 0 { cfg.IdempotencyTTL = time.Hour }
+	s := New(cfg, h, tokens, st.idem, st.keys)
+	ts := httptest.NewServer(s.Handler())
+	t.Cleanup(ts.Close)
+	return &client{t: t, url: ts.URL, mux: s.routes(), routes: s.routeTable(), hit: map[string]bool{}}
+}
+
+// request builds a request for path with body, JSON-encoded unless it is a
+// string, and the given header name/value pairs.
+func (a *client) request(ctx context.Context, method, path string, body any, header ...string) *http.Request {
+	a.t.Helper()
+	var r io.Reader
+	switch b := body.(type) {
+	case nil:
+	case string:
+		r = strings.NewReader(b)
+	default:
+		j, err := json.Marshal(b)
+		if err != nil { a.t.Fatal(err) }
+		r = bytes.NewReader(j)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, a.url+path, r)
+	if err != nil { a.t.Fatal(err) }
+	if body != nil { req.Header.Set("Content-Type", "application/json") }
+	if a.token != "" { req.Header.Set("Authorization", "Bearer "+a.token) }
+	for i := 0; i+1 < len(header); i += 2 { req.Header.Set(header[i], header[i+1]) }
+	if _, pattern := a.mux.Handler(req); pattern != "" { a.hit[pattern] = true }
+	return req
+}
+
+// call sends a request and returns the response with its body read.
+func (a *client) call(method, path string, body any, header ...string) (*http.Response, []byte) {
+	a.t.Helper()
+	resp, err := http.DefaultClient.Do(a.request(context.Background(), method, path, body, header...))
+	if err != nil { a.t.Fatal(err) }
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil { a.t.Fatal(err) }
+	return resp, b
+}
+
+// expect calls and fails the test unless the answer has status; it decodes
+// a JSON body into out, if given.
+func (a *client) expect(status int, out any, method, path string, body any, header ...string) *http.Response {
+	a.t.Helper()
+	resp, b := a.call(method, path, body, header...)
+	if resp.StatusCode != status { a.t.Fatalf("%s %s: %d %s, want %d", method, path, resp.StatusCode, b, status) }
+	if out != nil {
+		if err := json.Unmarshal(b, out); err != nil { a.t.Fatalf("%s %s: decoding %s: %v", method, path, b, err) }
+	}
+	return resp
+}
+
+// job polls the job at location until it is done, and fails the test
+// unless it succeeded.
+func (a *client) job(location string) store.Job {
+	a.t.Helper()
+	var j store.Job
+	for deadline := time.Now().Add(10 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		a.expect(http.StatusOK, &j, http.MethodGet, location, nil)
+		if j.Status == store.JobSucceeded { return j }
+		if j.Status == store.JobFailed { a.t.Fatalf("job %s failed: %s", location, j.Error) }
+	}
+	a.t.Fatalf("job %s still %s", location, j.Status)
+	return j
+}
+
+// signUp registers username in the default tenant and makes its token the
+// one requests carry.
+func (a *client) signUp(username string) {
+	a.t.Helper()
+	a.token = ""
+	creds := map[string]string{"username": username, "password": "correct horse"}
+	a.expect(http.StatusCreated, nil, http.MethodPost, "/api/v1/auth/register", creds)
+	var login struct{ Token string }
+	a.expect(http.StatusOK, &login, http.MethodPost, "/api/v1/auth/login", creds)
+	a.token = login.Token
+}
+
+type problem struct{ Code string }
+
+func TestAPIMemory(t *testing.T) { testAPI(t, memoryStores()) }
+
+// testAPI walks every route over st: the happy paths, then the ways each
+// kind of request goes wrong. It fails if a route was left out.
+func testAPI(t *testing.T, st stores) {
+	a := newAPI(t, st, Config{RequestTimeout: 10 * time.Second, Usage: usage.New(st.usage, usage.Config{Flush: time.Hour, Quotas: usage.Quotas{DailyRequests: 3}})})
+
+	// ---- public ----
+	for _, path := range []string{"/health", "/healthz", "/readyz", "/api/v1/openapi.json", "/metrics"} {
+		a.expect(http.StatusOK, nil, http.MethodGet, path, nil)
+	}
+	docs := http.StatusNotFound // until go generate ./ui vendors Swagger UI
+	if _, err := fs.Stat(ui.Swagger, "swagger/swagger-ui-bundle.js"); err == nil { docs = http.StatusOK }
+	for _, path := range []string{"/api/v1/docs", "/api/v1/docs/swagger-ui.css", "/api/v1/docs/swagger-ui-bundle.js"} {
+		a.expect(docs, nil, http.MethodGet, path, nil)
+	}
+	a.expect(http.StatusNotFound, nil, http.MethodGet, "/api/v1/docs/vendor.sh", nil)
+	for path, want := range map[string]string{"/ui/": "<title>", "/ui/app.js": "/auth/login", "/ui/style.css": "table"} {
+		if resp, b := a.call(http.MethodGet, path, nil); resp.StatusCode != http.StatusOK || !strings.Contains(string(b), want) { t.Fatalf("GET %s: %d %.80s", path, resp.StatusCode, b) }
+	}
+	a.expect(http.StatusNotFound, nil, http.MethodGet, "/ui/nope.js", nil)
+	if st.pool != nil {
+		a.expect(http.StatusOK, nil, http.MethodGet, "/debug/pool", nil)
+	} else {
+		a.expect(http.StatusNotFound, nil, http.MethodGet, "/debug/pool", nil)
+	}
+
+	// ---- auth ----
+	a.expect(http.StatusUnauthorized, nil, http.MethodGet, "/api/v1/names", nil)
+	a.signUp("alice")
+	a.expect(http.StatusConflict, nil, http.MethodPost, "/api/v1/auth/register", map[string]string{"username": "alice", "password": "another one"})
+	a.expect(http.StatusUnauthorized, nil, http.MethodPost, "/api/v1/auth/login", map[string]string{"username": "alice", "password": "wrong horse"})
+	var synced changes.Delta
+	a.expect(http.StatusOK, &synced, http.MethodGet, "/api/v1/names/changes", nil)
+
+	// ---- one name, through its versions ----
+	var n store.Name
+	resp := a.expect(http.StatusCreated, &n, http.MethodPost, "/api/v1/names", map[string]any{"name": "Alice", "tags": []string{"vip"}})
+	if etag := resp.Header.Get("ETag"); etag != `"1"` { t.Fatalf("ETag %q", etag) }
+	id := "/api/v1/names/" + n.ID.Hex()
+	var bySlug store.Name
+	if a.expect(http.StatusOK, &bySlug, http.MethodGet, "/api/v1/names/alice", nil); n.Slug != "alice" || bySlug.ID != n.ID { t.Fatalf("by slug %q: %+v", n.Slug, bySlug) }
+	a.expect(http.StatusConflict, nil, http.MethodPost, "/api/v1/names", map[string]any{"name": "Alice"})
+	a.expect(http.StatusUnprocessableEntity, nil, http.MethodPost, "/api/v1/names", map[string]any{"name": ""})
+	a.expect(http.StatusUnprocessableEntity, nil, http.MethodPost, "/api/v1/names", map[string]any{"name": "Zed", "expires_at": "2020-01-01T00:00:00Z"})
+	var zed store.Name
+	a.expect(http.StatusCreated, &zed, http.MethodPost, "/api/v1/names", map[string]any{"name": "Zed", "expires_at": "2099-01-01T00:00:00Z"})
+	if zed.ExpiresAt == nil || zed.ExpiresAt.Year() != 2099 { t.Fatalf("expires_at of a new name: %v", zed.ExpiresAt) }
+	a.expect(http.StatusNoContent, nil, http.MethodDelete, "/api/v1/names/"+zed.ID.Hex()+"?hard=true", nil, "If-Match", `"1"`)
+
+	// ---- create if absent, upsert by name ----
+	var yves, same, wes store.Name
+	a.expect(http.StatusCreated, &yves, http.MethodPost, "/api/v1/names?if_absent=true", map[string]any{"name": "Yves"})
+	if a.expect(http.StatusOK, &same, http.MethodPost, "/api/v1/names?if_absent=true", map[string]any{"name": "Yves", "tags": []string{"x"}}); same.ID != yves.ID || len(same.Tags) != 0 { t.Fatalf("if absent: %+v", same) }
+	byName := "/api/v1/names/by-name/Yves"
+	var refusedPut problem
+	a.expect(http.StatusPreconditionRequired, &refusedPut, http.MethodPut, byName, map[string]any{"tags": []string{"core"}})
+	if refusedPut.Code != handlers.CodePreconditionRequired { t.Fatalf("upsert of an existing name without If-Match: %+v", refusedPut) }
+	a.expect(http.StatusPreconditionFailed, nil, http.MethodPut, byName, map[string]any{"tags": []string{"core"}}, "If-None-Match", "*")
+	if a.expect(http.StatusOK, &yves, http.MethodPut, byName, map[string]any{"tags": []string{"core"}}, "If-Match", `"1"`); yves.ID != same.ID || yves.Version != 2 || yves.Tags[0] != "core" { t.Fatalf("upserted: %+v", yves) }
+	a.expect(http.StatusPreconditionFailed, nil, http.MethodPut, byName, map[string]any{}, "If-Match", `"1"`)
+	a.expect(http.StatusOK, nil, http.MethodPut, byName, map[string]any{"name": "Yves"}, "If-Match", `"2"`)
+	a.expect(http.StatusUnprocessableEntity, nil, http.MethodPut, byName, map[string]any{"name": "Yvonne"})
+	a.expect(http.StatusPreconditionFailed, nil, http.MethodPut, "/api/v1/names/by-name/Xena", map[string]any{}, "If-Match", `"1"`)
+	resp = a.expect(http.StatusCreated, &wes, http.MethodPut, "/api/v1/names/by-name/Wes", map[string]any{"metadata": map[string]any{"team": "core"}}, "If-None-Match", "*")
+	if wes.Name != "Wes" || wes.Metadata["team"] != "core" || resp.Header.Get("ETag") != `"1"` { t.Fatalf("created by upsert: %+v", wes) }
+	a.expect(http.StatusNoContent, nil, http.MethodDelete, "/api/v1/names/"+wes.ID.Hex(), nil, "If-Match", `"1"`)
+	a.expect(http.StatusConflict, nil, http.MethodPut, "/api/v1/names/by-name/Wes", map[string]any{})
+	a.expect(http.StatusConflict, nil, http.MethodPost, "/api/v1/names?if_absent=true", map[string]any{"name": "Wes"})
+	a.expect(http.StatusNoContent, nil, http.MethodDelete, "/api/v1/names/"+wes.ID.Hex()+"?hard=true", nil, "If-Match", `"2"`)
+	a.expect(http.StatusNoContent, nil, http.MethodDelete, "/api/v1/names/"+yves.ID.Hex()+"?hard=true", nil, "If-Match", `"3"`)
+
+	// ---- syncing the changes ----
+	// Zed was created, then removed after the page1 page: it reads as
+	// removed on both.
+	var page1, page2, waited changes.Delta
+	a.expect(http.StatusOK, &page1, http.MethodGet, "/api/v1/names/changes?limit=2&since="+synced.Next, nil)
+	if len(page1.Changes) != 2 || !page1.More || page1.Changes[0].ID != n.ID || page1.Changes[0].Name.Name != "Alice" || page1.Changes[1].ID != zed.ID || page1.Changes[1].Type != "removed" { t.Fatalf("changes: %+v", page1) }
+	a.expect(http.StatusOK, &page2, http.MethodGet, "/api/v1/names/changes?since="+page1.Next, nil)
+	if len(page2.Changes) != 3 || page2.More || page2.Changes[0].ID != zed.ID || page2.Changes[1].ID != wes.ID || page2.Changes[2].ID != yves.ID || page2.Changes[2].Type != "removed" || page2.Changes[2].Name != nil { t.Fatalf("the page2 of the changes: %+v", page2) }
+	go func() { time.Sleep(50 * time.Millisecond); _ = st.names.Create(tenant.NewContext(context.Background(), tenant.Default), &store.Name{Name: "Vic"}) }()
+	a.expect(http.StatusOK, &waited, http.MethodGet, "/api/v1/names/changes?wait=5s&since="+page2.Next, nil)
+	if len(waited.Changes) != 1 || waited.Changes[0].Type != "created" || waited.Changes[0].Name.Name != "Vic" { t.Fatalf("long poll: %+v", waited) }
+	a.expect(http.StatusNoContent, nil, http.MethodDelete, "/api/v1/names/"+waited.Changes[0].ID.Hex()+"?hard=true", nil, "If-Match", `"1"`)
+	a.expect(http.StatusUnprocessableEntity, nil, http.MethodGet, "/api/v1/names/changes?wait=1h&limit=0", nil)
+	a.expect(http.StatusUnprocessableEntity, nil, http.MethodGet, "/api/v1/names/changes?since=nope", nil)
+
+	resp = a.expect(http.StatusOK, &n, http.MethodGet, id, nil)
+	a.expect(http.StatusNotModified, nil, http.MethodGet, id, nil, "If-None-Match", `"1"`)
+	a.expect(http.StatusNotModified, nil, http.MethodGet, id, nil, "If-Modified-Since", resp.Header.Get("Last-Modified"))
+	a.expect(http.StatusOK, nil, http.MethodGet, id, nil, "If-Modified-Since", n.UpdatedAt.Add(-time.Hour).Format(http.TimeFormat))
+
+	a.expect(http.StatusPreconditionRequired, nil, http.MethodPut, id, map[string]any{"name": "Alicia"})
+	a.expect(http.StatusPreconditionFailed, nil, http.MethodPut, id, map[string]any{"name": "Alicia"}, "If-Match", `"9"`)
+	a.expect(http.StatusOK, &n, http.MethodPut, id, map[string]any{"name": "Alicia"}, "If-Match", `"1"`)
+	a.expect(http.StatusOK, &n, http.MethodPatch, id, map[string]any{"tags": []string{"core"}}, "If-Match", `"2"`)
+	if n.Name != "Alicia" || len(n.Tags) != 1 || n.Tags[0] != "core" || n.Version != 3 { t.Fatalf("after patch: %+v", n) }
+
+	var versions struct{ Items []store.Name }
+	a.expect(http.StatusOK, &versions, http.MethodGet, id+"/history", nil)
+	if len(versions.Items) != 3 { t.Fatalf("history: %+v", versions.Items) }
+	a.expect(http.StatusOK, &n, http.MethodPost, id+"/revert?version=1", nil, "If-Match", `"3"`)
+	if n.Name != "Alice" || n.Version != 4 { t.Fatalf("after revert: %+v", n) }
+	var events []store.NameEvent
+	a.expect(http.StatusOK, &events, http.MethodGet, id+"/events", nil)
+	if len(events) == 0 { t.Fatal("no events") }
+
+	a.expect(http.StatusNoContent, nil, http.MethodDelete, id, nil, "If-Match", `"4"`)
+	a.expect(http.StatusNotFound, nil, http.MethodGet, id, nil)
+	a.expect(http.StatusOK, &n, http.MethodPost, id+"/restore", nil)
+
+	// ---- notes about it ----
+	var note store.Note
+	a.expect(http.StatusCreated, &note, http.MethodPost, id+"/notes", map[string]any{"body": " Met at the conference "})
+	if note.Body != "Met at the conference" || note.NameID != n.ID || note.Author == "" { t.Fatalf("note: %+v", note) }
+	a.expect(http.StatusUnprocessableEntity, nil, http.MethodPost, id+"/notes", map[string]any{"body": " "})
+	a.expect(http.StatusNotFound, nil, http.MethodPost, "/api/v1/names/665f1c2e9b1e8a3d4c5b6a79/notes", map[string]any{"body": "hi"})
+	var noteList struct{ Items []store.Note }
+	if a.expect(http.StatusOK, &noteList, http.MethodGet, id+"/notes", nil); len(noteList.Items) != 1 || noteList.Items[0].ID != note.ID { t.Fatalf("notes: %+v", noteList) }
+	var expanded store.NameWithNotes
+	a.expect(http.StatusOK, &expanded, http.MethodGet, id+"?expand=notes", nil)
+	if expanded.Name.Name != "Alice" || len(expanded.Notes) != 1 || expanded.Notes[0].Body != note.Body { t.Fatalf("expanded: %+v", expanded) }
+	a.expect(http.StatusUnprocessableEntity, nil, http.MethodGet, id+"?expand=tags", nil)
+	var refused problem
+	a.expect(http.StatusConflict, &refused, http.MethodDelete, id+"?hard=true", nil, "If-Match", "*")
+	if refused.Code != handlers.CodeNameHasNotes { t.Fatalf("hard delete with notes: %+v", refused) }
+
+	// ---- its tags ----
+	var tagged store.Name
+	resp = a.expect(http.StatusOK, &tagged, http.MethodPost, id+"/tags", map[string]any{"tag": " core "}, "If-Match", "*")
+	if strings.Join(tagged.Tags, ",") != "vip,core" || tagged.Version != n.Version+1 || resp.Header.Get("ETag") != `"`+strconv.FormatInt(tagged.Version, 10)+`"` { t.Fatalf("tag added: %+v", tagged) }
+	if a.expect(http.StatusOK, &n, http.MethodPost, id+"/tags", map[string]any{"tag": "core"}, "If-Match", "*"); n.Version != tagged.Version { t.Fatalf("tag added twice: %+v", n) }
+	a.expect(http.StatusUnprocessableEntity, nil, http.MethodPost, id+"/tags", map[string]any{"tag": ""}, "If-Match", "*")
+	a.expect(http.StatusPreconditionRequired, nil, http.MethodDelete, id+"/tags/vip", nil)
+	a.expect(http.StatusPreconditionFailed, nil, http.MethodDelete, id+"/tags/vip", nil, "If-Match", `"1"`)
+	a.expect(http.StatusOK, &n, http.MethodDelete, id+"/tags/vip", nil, "If-Match", resp.Header.Get("ETag"))
+	if len(n.Tags) != 1 || n.Tags[0] != "core" { t.Fatalf("tag removed: %+v", n) }
+	var byTag store.Page
+	if a.expect(http.StatusOK, &byTag, http.MethodGet, "/api/v1/names?tag=core&tag=vip", nil); byTag.Total != 0 { t.Fatalf("core and vip: %+v", byTag) }
+	if a.expect(http.StatusOK, &byTag, http.MethodGet, "/api/v1/names?tag=core&tag=vip&tagMode=any", nil); byTag.Total != 1 { t.Fatalf("core or vip: %+v", byTag) }
+	a.expect(http.StatusUnprocessableEntity, nil, http.MethodGet, "/api/v1/names?tag=core&tagMode=some", nil)
+
+	// ---- many names ----
+	var bulk struct {
+		Succeeded, Failed int
+		Results           []struct{ ID string }
+	}
+	a.expect(http.StatusOK, &bulk, http.MethodPost, "/api/v1/names/bulk", []map[string]any{{"name": "Bob"}, {"name": ""}})
+	if bulk.Succeeded != 1 || bulk.Failed != 1 { t.Fatalf("bulk: %+v", bulk) }
+	bob := bulk.Results[0].ID
+	var batch struct{ Items []store.Name; Missing []string }
+	a.expect(http.StatusOK, &batch, http.MethodGet, "/api/v1/names?ids="+n.ID.Hex()+","+bob, nil)
+	if len(batch.Items) != 2 || len(batch.Missing) != 0 { t.Fatalf("batch get: %+v", batch) }
+	a.expect(http.StatusOK, nil, http.MethodDelete, "/api/v1/names?ids="+bob, nil)
+	var page store.Page
+	if a.expect(http.StatusOK, &page, http.MethodGet, "/api/v1/names/trash", nil); page.Total != 1 { t.Fatalf("trash: %+v", page) }
+
+	var sum struct{ Inserted int }
+	a.expect(http.StatusOK, &sum, http.MethodPost, "/api/v1/names/import", "Carol\nDave\n", "Content-Type", "text/csv")
+	if sum.Inserted != 2 { t.Fatalf("import: %+v", sum) }
+	if a.expect(http.StatusOK, &page, http.MethodGet, "/api/v1/names?sort=name", nil); page.Total != 3 || page.Items[0].Name != "Alice" { t.Fatalf("list: %+v", page) }
+	if a.expect(http.StatusOK, &page, http.MethodGet, "/api/v1/names?sort=-name&locale=sv", nil); page.Total != 3 || page.Items[0].Name != "Dave" { t.Fatalf("list in Swedish: %+v", page) }
+	a.expect(http.StatusUnprocessableEntity, nil, http.MethodGet, "/api/v1/names?locale=Swedish", nil)
+	if a.expect(http.StatusOK, &page, http.MethodGet, "/api/v1/names?sort=name&consistency=eventual", nil); page.Total != 3 { t.Fatalf("eventual list: %+v", page) }
+	a.expect(http.StatusUnprocessableEntity, nil, http.MethodGet, "/api/v1/names?consistency=bounded", nil)
+	a.expect(http.StatusUnprocessableEntity, nil, http.MethodGet, "/api/v1/names/search?q=al&consistency=bounded", nil)
+	// Listings are revalidated against the count of writes to the tenant's
+	// names, so any write, and only a write, changes their ETag.
+	resp = a.expect(http.StatusOK, nil, http.MethodGet, "/api/v1/names?sort=name", nil)
+	listETag, listModified := resp.Header.Get("ETag"), resp.Header.Get("Last-Modified")
+	if !strings.HasPrefix(listETag, `W/"`) || listModified == "" { t.Fatalf("list ETag %q, Last-Modified %q", listETag, listModified) }
+	a.expect(http.StatusNotModified, nil, http.MethodGet, "/api/v1/names?sort=name", nil, "If-None-Match", listETag)
+	a.expect(http.StatusNotModified, nil, http.MethodGet, "/api/v1/names?sort=name", nil, "If-Modified-Since", listModified)
+	a.expect(http.StatusOK, nil, http.MethodGet, "/api/v1/names?sort=-name", nil, "If-None-Match", listETag)
+	var erin store.Name
+	a.expect(http.StatusCreated, &erin, http.MethodPost, "/api/v1/names", map[string]any{"name": "Erin"})
+	a.expect(http.StatusNoContent, nil, http.MethodDelete, "/api/v1/names/"+erin.ID.Hex()+"?hard=true", nil, "If-Match", `"1"`)
+	a.expect(http.StatusOK, nil, http.MethodGet, "/api/v1/names?sort=name", nil, "If-None-Match", listETag)
+	var slim struct {
+		Items []map[string]any
+		Next  string
+	}
+	a.expect(http.StatusOK, &slim, http.MethodGet, "/api/v1/names?sort=name&limit=2&fields=name,%20name", nil)
+	if len(slim.Items) != 2 || len(slim.Items[0]) != 2 || slim.Items[0]["name"] != "Alice" || slim.Items[0]["id"] == nil { t.Fatalf("projected list: %+v", slim) }
+	// The cursor still pages by name, though only the IDs were asked for.
+	var rest struct {
+		Items []map[string]any
+		Next  string
+	}
+	a.expect(http.StatusOK, &rest, http.MethodGet, "/api/v1/names?sort=name&limit=2&fields=id&after="+slim.Next, nil)
+	if len(rest.Items) != 1 || len(rest.Items[0]) != 1 || rest.Next != "" { t.Fatalf("projected second page: %+v", rest) }
+	var one map[string]any
+	dave := rest.Items[0]["id"].(string)
+	resp = a.expect(http.StatusOK, &one, http.MethodGet, "/api/v1/names/"+dave+"?fields=version,created_at", nil)
+	if len(one) != 3 || one["version"] != 1.0 || one["created_at"] == nil || resp.Header.Get("ETag") != `"1"` { t.Fatalf("projected name: %+v", one) }
+	a.expect(http.StatusUnprocessableEntity, nil, http.MethodGet, "/api/v1/names?fields=name,tenant", nil)
+	a.expect(http.StatusUnprocessableEntity, nil, http.MethodGet, "/api/v1/names/"+dave+"?fields=name&expand=notes", nil)
+	if _, b := a.call(http.MethodGet, "/api/v1/names/export?format=ndjson", nil); bytes.Count(b, []byte("\n")) != 3 { t.Fatalf("export: %s", b) }
+	if _, b := a.call(http.MethodGet, "/api/v1/names?sort=name&limit=1", nil, "Accept", "application/x-ndjson"); bytes.Count(b, []byte("\n")) != 3 { t.Fatalf("streamed list: %s", b) }
+	var all []store.Name
+	if a.expect(http.StatusOK, &all, http.MethodGet, "/api/v1/names?sort=-name&stream=true", nil); len(all) != 3 || all[2].Name != "Alice" { t.Fatalf("streamed list: %+v", all) }
+
+	// ---- the same, in the background ----
+	resp = a.expect(http.StatusAccepted, nil, http.MethodPost, "/api/v1/names/import", "Carol\n", "Content-Type", "text/csv", "Prefer", "respond-async")
+	if j := a.job(resp.Header.Get("Location")); j.Type != "import" || !strings.Contains(string(j.Result), `"skipped":1`) { t.Fatalf("import job: %+v %s", j, j.Result) }
+	resp = a.expect(http.StatusAccepted, nil, http.MethodGet, "/api/v1/names/export?format=ndjson", nil, "Prefer", "respond-async")
+	a.job(resp.Header.Get("Location"))
+	if resp, b := a.call(http.MethodGet, resp.Header.Get("Location")+"/output", nil); resp.Header.Get("Content-Type") != "application/x-ndjson" || bytes.Count(b, []byte("\n")) != 3 { t.Fatalf("export job output: %s", b) }
+
+	var hits struct{ Items []store.Name }
+	if a.expect(http.StatusOK, &hits, http.MethodGet, "/api/v1/names/search?q=ca&mode=prefix", nil); len(hits.Items) != 1 || hits.Items[0].Name != "Carol" { t.Fatalf("search: %+v", hits) }
+
+	var gql struct{ Data struct{ Names []struct{ Name string } } }
+	a.expect(http.StatusOK, &gql, http.MethodPost, "/api/v1/graphql", map[string]any{"query": "{ names { name } }"})
+	if len(gql.Data.Names) != 3 { t.Fatalf("graphql: %+v", gql) }
+
+	var trail store.AuditPage
+	if a.expect(http.StatusOK, &trail, http.MethodGet, "/api/v1/audit?id="+n.ID.Hex(), nil); len(trail.Items) != 8 { t.Fatalf("audit: %d entries", len(trail.Items)) }
+	var stats store.NameStats
+	if a.expect(http.StatusOK, &stats, http.MethodGet, "/api/v1/admin/stats", nil); stats.Total != 4 || stats.Deleted != 1 { t.Fatalf("stats: %+v", stats) }
+	if st.copy != nil {
+		a.expect(http.StatusUnprocessableEntity, nil, http.MethodPost, "/api/v1/admin/shadow/check?limit=-1", nil)
+		resp = a.expect(http.StatusAccepted, nil, http.MethodPost, "/api/v1/admin/shadow/check?limit=10", nil)
+		var report shadow.Report
+		if j := a.job(resp.Header.Get("Location")); json.Unmarshal(j.Result, &report) != nil || report.Checked != 4 || report.Missing+report.Extra+report.Differing != 0 { t.Fatalf("shadow check: %s", j.Result) }
+	} else {
+		a.expect(http.StatusNotFound, nil, http.MethodPost, "/api/v1/admin/shadow/check", nil)
+	}
+
+	var matched store.Page
+	if a.expect(http.StatusOK, &matched, http.MethodGet, "/api/v1/names?match=%20%20cAROL%20", nil); len(matched.Items) != 1 || matched.Items[0].NameNormalized != "Carol" { t.Fatalf("match: %+v", matched) }
+	a.expect(http.StatusUnprocessableEntity, nil, http.MethodGet, "/api/v1/names?match=%20", nil)
+	resp = a.expect(http.StatusAccepted, nil, http.MethodPost, "/api/v1/admin/normalize", nil)
+	var renormalized normalize.Result
+	if j := a.job(resp.Header.Get("Location")); json.Unmarshal(j.Result, &renormalized) != nil || renormalized.Checked != 4 || renormalized.Updated != 0 { t.Fatalf("normalize: %s", j.Result) }
+
+	var lead leader.Status
+	if a.expect(http.StatusOK, &lead, http.MethodGet, "/api/v1/admin/leader", nil); !lead.Leader || lead.Lease == nil || lead.Lease.Holder != "api-test" || len(lead.Tasks) != 1 { t.Fatalf("leader: %+v", lead) }
+
+	// ---- backup and restore: a name removed after the backup comes back ----
+	var temp store.Name
+	a.expect(http.StatusCreated, &temp, http.MethodPost, "/api/v1/names", map[string]any{"name": "Temp", "tags": []string{"restored"}})
+	a.expect(http.StatusUnprocessableEntity, nil, http.MethodPost, "/api/v1/admin/backup?format=csv", nil)
+	resp = a.expect(http.StatusAccepted, nil, http.MethodPost, "/api/v1/admin/backup?format=bson", nil)
+	var dumped backup.Result
+	if j := a.job(resp.Header.Get("Location")); json.Unmarshal(j.Result, &dumped) != nil || dumped.Names != 5 || dumped.Format != backup.BSON { t.Fatalf("backup: %s", j.Result) }
+	a.expect(http.StatusNoContent, nil, http.MethodDelete, "/api/v1/names/"+temp.ID.Hex()+"?hard=true", nil, "If-Match", `"1"`)
+	a.expect(http.StatusUnprocessableEntity, nil, http.MethodPost, "/api/v1/admin/restore?backup=../other/"+dumped.Backup+"&mode=replace&dry_run=maybe", nil)
+	var restored struct{ Inserted, Updated, Skipped, Errored int }
+	resp = a.expect(http.StatusAccepted, nil, http.MethodPost, "/api/v1/admin/restore?mode=upsert&dry_run=true&backup="+dumped.Backup, nil)
+	if j := a.job(resp.Header.Get("Location")); json.Unmarshal(j.Result, &restored) != nil || restored.Inserted != 1 || restored.Updated != 3 || restored.Skipped != 1 { t.Fatalf("dry run: %s", j.Result) }
+	a.expect(http.StatusNotFound, nil, http.MethodGet, "/api/v1/names/"+temp.ID.Hex(), nil)
+	resp = a.expect(http.StatusAccepted, nil, http.MethodPost, "/api/v1/admin/restore?backup="+dumped.Backup, nil)
+	if j := a.job(resp.Header.Get("Location")); json.Unmarshal(j.Result, &restored) != nil || restored.Inserted != 1 || restored.Skipped != 4 || restored.Errored != 0 { t.Fatalf("restore: %s", j.Result) }
+	var back store.Name
+	if a.expect(http.StatusOK, &back, http.MethodGet, "/api/v1/names/"+temp.ID.Hex(), nil); back.Name != "Temp" || back.Tags[0] != "restored" || back.CreatedBy == "" || back.CreatedBy != temp.CreatedBy || back.Slug != temp.Slug || back.UUID != temp.UUID { t.Fatalf("restored: %+v", back) }
+	a.expect(http.StatusNoContent, nil, http.MethodDelete, "/api/v1/names/"+temp.ID.Hex()+"?hard=true", nil, "If-Match", `"1"`)
+
+	testMerge(t, a)
+	testStream(t, a)
+
+	// ---- a declared resource ----
+	var email map[string]any
+	resp = a.expect(http.StatusCreated, &email, http.MethodPost, "/api/v1/emails", map[string]any{"address": " alice@example.com ", "label": "work"})
+	if email["address"] != "alice@example.com" || email["version"] != 1.0 || resp.Header.Get("ETag") != `"1"` { t.Fatalf("created email: %v", email) }
+	emailID := "/api/v1/emails/" + email["id"].(string)
+	a.expect(http.StatusUnprocessableEntity, nil, http.MethodPost, "/api/v1/emails", map[string]any{"address": "nope", "label": "school"})
+	a.expect(http.StatusUnprocessableEntity, nil, http.MethodPost, "/api/v1/emails", map[string]any{"address": "bob@example.com", "phone": "555"})
+	a.expect(http.StatusNotFound, nil, http.MethodPost, "/api/v1/phones", map[string]any{"number": "555"})
+	a.expect(http.StatusOK, &email, http.MethodGet, emailID, nil)
+	a.expect(http.StatusNotModified, nil, http.MethodGet, emailID, nil, "If-None-Match", `"1"`)
+	a.expect(http.StatusPreconditionRequired, nil, http.MethodPut, emailID, map[string]any{"address": "alice@example.org"})
+	var replaced map[string]any
+	a.expect(http.StatusOK, &replaced, http.MethodPut, emailID, map[string]any{"address": "alice@example.org", "primary": true}, "If-Match", `"1"`)
+	if replaced["label"] != nil || replaced["primary"] != true || replaced["version"] != 2.0 { t.Fatalf("replaced email: %v", replaced) }
+	a.expect(http.StatusCreated, nil, http.MethodPost, "/api/v1/emails", map[string]any{"address": "work@example.com"})
+	type emailPage struct {
+		Items []map[string]any
+		Next  string
+	}
+	var first, second emailPage
+	if a.expect(http.StatusOK, &first, http.MethodGet, "/api/v1/emails?limit=1", nil); len(first.Items) != 1 || first.Next == "" { t.Fatalf("emails: %+v", first) }
+	if a.expect(http.StatusOK, &second, http.MethodGet, "/api/v1/emails?after="+first.Next, nil); len(second.Items) != 1 || second.Next != "" { t.Fatalf("emails, page 2: %+v", second) }
+	a.expect(http.StatusPreconditionFailed, nil, http.MethodDelete, emailID, nil, "If-Match", `"1"`)
+	a.expect(http.StatusNoContent, nil, http.MethodDelete, emailID, nil, "If-Match", `"2"`)
+	a.expect(http.StatusNotFound, nil, http.MethodGet, emailID, nil)
+
+	// ---- API keys ----
+	var key struct {
+		store.APIKey
+		Key string
+	}
+	a.expect(http.StatusCreated, &key, http.MethodPost, "/api/v1/apikeys", map[string]any{"name": "reader", "scopes": []string{auth.ScopeRead}})
+	user := a.token
+	a.token = ""
+	a.expect(http.StatusOK, nil, http.MethodGet, "/api/v1/names", nil, apiKeyHeader, key.Key)
+	a.expect(http.StatusForbidden, nil, http.MethodPost, "/api/v1/names", map[string]any{"name": "Eve"}, apiKeyHeader, key.Key)
+	// The key gets 3 requests a day; the refused POST didn't count.
+	var used struct {
+		Quotas usage.Quotas
+		Items  []struct {
+			ID    string
+			Today usage.Period
+		}
+	}
+	a.expect(http.StatusOK, &used, http.MethodGet, "/api/v1/usage", nil, apiKeyHeader, key.Key)
+	if len(used.Items) != 1 || used.Items[0].ID != key.ID.Hex() || used.Items[0].Today.Requests != 1 || used.Items[0].Today.BytesOut == 0 || used.Quotas.DailyRequests != 3 { t.Fatalf("usage: %+v", used) }
+	a.expect(http.StatusOK, nil, http.MethodGet, "/api/v1/names", nil, apiKeyHeader, key.Key)
+	resp = a.expect(http.StatusTooManyRequests, &refused, http.MethodGet, "/api/v1/names", nil, apiKeyHeader, key.Key)
+	if refused.Code != handlers.CodeQuotaExceeded || resp.Header.Get("Retry-After") == "" { t.Fatalf("over quota: %+v, Retry-After %q", refused, resp.Header.Get("Retry-After")) }
+	a.token = user
+	if a.expect(http.StatusOK, &used, http.MethodGet, "/api/v1/usage", nil); len(used.Items) != 1 || used.Items[0].Today.Requests != 3 { t.Fatalf("usage of the caller's keys: %+v", used) }
+	a.expect(http.StatusNoContent, nil, http.MethodDelete, "/api/v1/apikeys/"+key.ID.Hex(), nil)
+	a.token = ""
+	a.expect(http.StatusUnauthorized, nil, http.MethodGet, "/api/v1/names", nil, apiKeyHeader, key.Key)
+	a.token = user
+
+	// ---- roles: alice founded the tenant, so she is its admin ----
+	a.signUp("bob")
+	bobToken := a.token
+	a.expect(http.StatusForbidden, nil, http.MethodGet, "/api/v1/users", nil)
+	a.expect(http.StatusCreated, &key, http.MethodPost, "/api/v1/apikeys", map[string]any{"name": "writer", "scopes": []string{auth.ScopeWrite}})
+	a.expect(http.StatusForbidden, nil, http.MethodPost, "/api/v1/apikeys", map[string]any{"name": "auditor", "scopes": []string{auth.ScopeAudit}})
+	a.token = user
+	var users struct{ Items []store.User }
+	if a.expect(http.StatusOK, &users, http.MethodGet, "/api/v1/users", nil); len(users.Items) != 2 || users.Items[0].Role != auth.RoleAdmin || users.Items[1].Role != auth.RoleEditor { t.Fatalf("users: %+v", users.Items) }
+	bobID := "/api/v1/users/" + users.Items[1].ID.Hex()
+	a.expect(http.StatusForbidden, nil, http.MethodPut, "/api/v1/users/"+users.Items[0].ID.Hex()+"/role", map[string]string{"role": auth.RoleViewer})
+	a.expect(http.StatusUnprocessableEntity, nil, http.MethodPut, bobID+"/role", map[string]string{"role": "owner"})
+	a.expect(http.StatusOK, nil, http.MethodPut, bobID+"/role", map[string]string{"role": auth.RoleViewer})
+	var keys struct{ Items []store.APIKey }
+	if a.expect(http.StatusOK, &keys, http.MethodGet, "/api/v1/apikeys", nil); len(keys.Items) != 2 || keys.Items[1].Role != auth.RoleViewer { t.Fatalf("keys: %+v", keys.Items) }
+	a.token = ""
+	a.expect(http.StatusForbidden, nil, http.MethodPost, "/api/v1/names", map[string]any{"name": "Eve"}, apiKeyHeader, key.Key)
+	a.token = user
+	a.expect(http.StatusUnprocessableEntity, nil, http.MethodPut, "/api/v1/apikeys/"+key.ID.Hex()+"/role", map[string]string{"role": auth.RoleEditor})
+	a.expect(http.StatusOK, nil, http.MethodPut, bobID+"/role", map[string]string{"role": auth.RoleEditor})
+	a.expect(http.StatusOK, nil, http.MethodPut, "/api/v1/apikeys/"+key.ID.Hex()+"/role", map[string]string{"role": auth.RoleEditor})
+	a.token = ""
+	a.expect(http.StatusCreated, nil, http.MethodPost, "/api/v1/names", map[string]any{"name": "Eve"}, apiKeyHeader, key.Key)
+	// Bob can't revoke alice's keys, and she, as admin, can revoke his.
+	var alices store.APIKey
+	a.token = user
+	a.expect(http.StatusCreated, &alices, http.MethodPost, "/api/v1/apikeys", map[string]any{"name": "alice's", "scopes": []string{auth.ScopeRead}})
+	a.token = bobToken
+	a.expect(http.StatusNotFound, nil, http.MethodDelete, "/api/v1/apikeys/"+alices.ID.Hex(), nil)
+	a.token = user
+	a.expect(http.StatusNoContent, nil, http.MethodDelete, "/api/v1/apikeys/"+key.ID.Hex(), nil)
+	a.token = ""
+	a.expect(http.StatusUnauthorized, nil, http.MethodGet, "/api/v1/names", nil, apiKeyHeader, key.Key)
+	a.token = user
+
+	// ---- ownership: bob may change his names, and only alice as admin may change anyone's ----
+	var fay, finn store.Name
+	a.expect(http.StatusCreated, &fay, http.MethodPost, "/api/v1/names", map[string]any{"name": "Fay", "created_by": "someone"})
+	if fay.CreatedBy != users.Items[0].ID.Hex() { t.Fatalf("created_by: %+v", fay) }
+	a.token = bobToken
+	a.expect(http.StatusCreated, &finn, http.MethodPost, "/api/v1/names", map[string]any{"name": "Finn"})
+	if a.expect(http.StatusOK, &page, http.MethodGet, "/api/v1/names?mine=true&sort=name", nil); page.Total != 2 || page.Items[0].Name != "Eve" || page.Items[1].Name != "Finn" { t.Fatalf("bob's names: %+v", page) }
+	a.expect(http.StatusForbidden, &refused, http.MethodPatch, "/api/v1/names/"+fay.ID.Hex(), map[string]any{"tags": []string{"bob"}}, "If-Match", `"1"`)
+	if refused.Code != handlers.CodeNotOwner { t.Fatalf("bob patching Fay: %+v", refused) }
+	a.expect(http.StatusForbidden, nil, http.MethodDelete, "/api/v1/names/"+fay.ID.Hex(), nil, "If-Match", `"1"`)
+	a.expect(http.StatusForbidden, nil, http.MethodPut, "/api/v1/names/by-name/Fay", map[string]any{"tags": []string{"bob"}}, "If-Match", `"1"`)
+	a.expect(http.StatusOK, nil, http.MethodPatch, "/api/v1/names/"+finn.ID.Hex(), map[string]any{"tags": []string{"bob"}}, "If-Match", `"1"`)
+	a.token = user
+	a.expect(http.StatusOK, nil, http.MethodPatch, "/api/v1/names/"+finn.ID.Hex(), map[string]any{"tags": []string{"alice"}}, "If-Match", `"2"`)
+	a.expect(http.StatusNoContent, nil, http.MethodDelete, "/api/v1/names/"+finn.ID.Hex()+"?hard=true", nil, "If-Match", `"3"`)
+	a.expect(http.StatusNoContent, nil, http.MethodDelete, "/api/v1/names/"+fay.ID.Hex()+"?hard=true", nil, "If-Match", `"1"`)
+
+	// ---- transactions ----
+	type txn struct {
+		Results []struct {
+			Op, ID string
+			Name   *store.Name
+		}
+	}
+	var done txn
+	a.expect(http.StatusOK, &done, http.MethodPost, "/api/v1/names/transaction", map[string]any{"operations": []map[string]any{
+		{"op": "create", "data": map[string]any{"name": "Gina"}},
+		{"op": "create", "data": map[string]any{"name": "Hank", "tags": []string{"ops"}}},
+	}})
+	if len(done.Results) != 2 || done.Results[1].Op != "create" || done.Results[1].Name.Name != "Hank" { t.Fatalf("transaction: %+v", done) }
+	gina, hank := done.Results[0].ID, done.Results[1].ID
+	// The rename applies, then the second create of a name fails, and neither sticks.
+	var failed struct {
+		Code      string
+		Operation int
+	}
+	a.expect(http.StatusConflict, &failed, http.MethodPost, "/api/v1/names/transaction", map[string]any{"operations": []map[string]any{
+		{"op": "update", "id": gina, "if_version": 1, "data": map[string]any{"name": "Georgina"}},
+		{"op": "create", "data": map[string]any{"name": "Ivy"}},
+		{"op": "create", "data": map[string]any{"name": "Ivy"}},
+	}})
+	if failed.Code != handlers.CodeDuplicateName || failed.Operation != 2 { t.Fatalf("failed transaction: %+v", failed) }
+	var still store.Name
+	if a.expect(http.StatusOK, &still, http.MethodGet, "/api/v1/names/"+gina, nil); still.Name != "Gina" || still.Version != 1 { t.Fatalf("after rollback: %+v", still) }
+	if a.expect(http.StatusOK, &page, http.MethodGet, "/api/v1/names?name=Ivy", nil); page.Total != 0 { t.Fatalf("Ivy survived the rollback: %+v", page) }
+	a.expect(http.StatusPreconditionFailed, &failed, http.MethodPost, "/api/v1/names/transaction", map[string]any{"operations": []map[string]any{
+		{"op": "delete", "id": hank, "if_version": 7},
+	}})
+	// Like PUT and DELETE without If-Match, an update or delete without if_version applies nothing.
+	a.expect(http.StatusPreconditionRequired, &failed, http.MethodPost, "/api/v1/names/transaction", map[string]any{"operations": []map[string]any{
+		{"op": "create", "data": map[string]any{"name": "Ivy"}}, {"op": "update", "id": gina, "if_version": 1, "data": map[string]any{"name": "Georgina"}}, {"op": "delete", "id": hank},
+	}})
+	if failed.Code != handlers.CodePreconditionRequired || failed.Operation != 2 { t.Fatalf("transaction without if_version: %+v", failed) }
+	a.expect(http.StatusUnprocessableEntity, nil, http.MethodPost, "/api/v1/names/transaction", map[string]any{"operations": []map[string]any{
+		{"op": "rename", "id": hank}, {"op": "update", "id": "nope", "data": map[string]any{"name": ""}},
+	}})
+	a.expect(http.StatusOK, &done, http.MethodPost, "/api/v1/names/transaction", map[string]any{"operations": []map[string]any{
+		{"op": "delete", "id": gina, "if_version": 1, "hard": true}, {"op": "delete", "id": hank, "if_version": 1, "hard": true},
+	}})
+	if a.expect(http.StatusOK, &page, http.MethodGet, "/api/v1/names?ids="+gina+","+hank, nil); page.Total != 0 { t.Fatalf("deleted in a transaction: %+v", page) }
+
+	// ---- webhooks ----
+	received := make(chan string, 10)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- r.Header.Get("X-Webhook-Event") + " " + string(body)
+	}))
+	defer receiver.Close()
+	var hook struct {
+		store.Webhook
+		Secret string
+	}
+	a.expect(http.StatusCreated, &hook, http.MethodPost, "/api/v1/webhooks", map[string]any{"url": receiver.URL, "events": []string{"created"}})
+	if len(hook.Secret) != 64 || hook.Events[0] != "created" { t.Fatalf("webhook: %+v", hook) }
+	hookID := "/api/v1/webhooks/" + hook.ID.Hex()
+	a.expect(http.StatusUnprocessableEntity, nil, http.MethodPost, "/api/v1/webhooks", map[string]any{"url": "ftp://example.com", "events": []string{"renamed"}})
+	var hooks struct{ Items []store.Webhook }
+	if a.expect(http.StatusOK, &hooks, http.MethodGet, "/api/v1/webhooks", nil); len(hooks.Items) != 1 || hooks.Items[0].ID != hook.ID { t.Fatalf("webhooks: %+v", hooks) }
+	a.expect(http.StatusCreated, nil, http.MethodPost, "/api/v1/names", map[string]any{"name": "Frank"})
+	select {
+	case got := <-received:
+		if !strings.HasPrefix(got, `created {"event":"created"`) || !strings.Contains(got, `"name":"Frank"`) { t.Fatalf("webhook received %s", got) }
+	case <-time.After(10 * time.Second):
+		t.Fatal("webhook never called")
+	}
+	var deliveries struct{ Items []store.Delivery }
+	for deadline := time.Now().Add(10 * time.Second); len(deliveries.Items) == 0 || deliveries.Items[0].Status != store.DeliveryDelivered; time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) { t.Fatalf("deliveries: %+v", deliveries) }
+		a.expect(http.StatusOK, &deliveries, http.MethodGet, hookID+"/deliveries", nil)
+	}
+	if d := deliveries.Items[0]; len(deliveries.Items) != 1 || d.Event != "created" || len(d.Attempts) != 1 || d.Attempts[0].StatusCode != http.StatusOK { t.Fatalf("delivery: %+v", d) }
+	a.expect(http.StatusNoContent, nil, http.MethodDelete, hookID, nil)
+	a.expect(http.StatusNotFound, nil, http.MethodGet, hookID+"/deliveries", nil)
+
+	// ---- demo data ----
+	var seeded struct{ Created, Skipped int }
+	if a.expect(http.StatusOK, &seeded, http.MethodPost, "/api/v1/admin/seed?count=30", nil); seeded.Created != 30 || seeded.Skipped != 0 { t.Fatalf("seeded: %+v", seeded) }
+	a.expect(http.StatusUnprocessableEntity, nil, http.MethodPost, "/api/v1/admin/seed?count=0", nil)
+	var picked store.Name
+	resp = a.expect(http.StatusOK, &picked, http.MethodGet, "/api/v1/names/random", nil)
+	if picked.ID.IsZero() || resp.Header.Get("Cache-Control") != "no-store" { t.Fatalf("random name: %+v", picked) }
+
+	// ---- malformed requests ----
+	for _, tc := range []struct {
+		method, path string
+		status       int
+		code         string
+	}{
+		{http.MethodGet, "/api/v1/names/not_an_id", http.StatusBadRequest, handlers.CodeBadRequest},
+		{http.MethodPut, "/api/v1/names/not_an_id", http.StatusBadRequest, handlers.CodeBadRequest},
+		{http.MethodGet, "/api/v1/names/nobody", http.StatusNotFound, handlers.CodeNotFound},
+		{http.MethodDelete, "/api/v1/apikeys/nope", http.StatusBadRequest, handlers.CodeBadRequest},
+		{http.MethodGet, "/api/v1/names/665f1c2e9b1e8a3d4c5b6a79", http.StatusNotFound, handlers.CodeNotFound},
+		{http.MethodGet, "/api/v1/names?ids=nope", http.StatusBadRequest, handlers.CodeBadRequest},
+		{http.MethodGet, "/api/v1/jobs/665f1c2e9b1e8a3d4c5b6a79/output", http.StatusNotFound, handlers.CodeNotFound},
+		{http.MethodGet, "/api/v1/names?limit=0", http.StatusUnprocessableEntity, handlers.CodeValidationFailed},
+		{http.MethodPost, "/api/v1/names/665f1c2e9b1e8a3d4c5b6a79", http.StatusMethodNotAllowed, handlers.CodeMethodNotAllowed},
+		{http.MethodPut, "/api/v1/names", http.StatusMethodNotAllowed, handlers.CodeMethodNotAllowed},
+		{http.MethodGet, "/api/v1/emails?after=nope", http.StatusUnprocessableEntity, handlers.CodeValidationFailed},
+		{http.MethodGet, "/api/v1/emails/nope", http.StatusBadRequest, handlers.CodeBadRequest},
+		{http.MethodGet, "/nope", http.StatusNotFound, handlers.CodeNotFound},
+		{http.MethodGet, "/api/v1/admin/captures/unrecorded", http.StatusNotFound, handlers.CodeNotFound},
+	} {
+		var p problem
+		a.expect(tc.status, &p, tc.method, tc.path, nil)
+		if p.Code != tc.code { t.Errorf("%s %s: code %q, want %q", tc.method, tc.path, p.Code, tc.code) }
+	}
+
+	// ---- drain, last: with no DRAIN_GRACE, nothing but probes is served after ----
+	var drained struct{ Status string }
+	if a.expect(http.StatusOK, &drained, http.MethodPost, "/api/v1/admin/drain", nil); drained.Status != "drained" { t.Fatalf("drain: %+v", drained) }
+	a.expect(http.StatusServiceUnavailable, nil, http.MethodGet, "/readyz", nil)
+	a.expect(http.StatusOK, nil, http.MethodGet, "/healthz", nil)
+	a.expect(http.StatusServiceUnavailable, &refused, http.MethodGet, "/api/v1/names", nil)
+	if refused.Code != handlers.CodeDraining { t.Fatalf("after the drain: %+v", refused) }
+
+	for _, rt := range a.routes {
+		if !a.hit[rt.pattern] { t.Errorf("%s is not exercised", rt.pattern) }
+	}
+}
+
+// TestAPICaptures records every request, and reads one back by its ID.
+func TestAPICaptures(t *testing.T) {
+	a := newAPI(t, memoryStores(), Config{Capture: CaptureConfig{Percent: 100, MaxBodyBytes: 40, Sink: store.NewMemoryCaptures(100)}})
+	creds := map[string]string{"username": "alice", "password": "correct horse"}
+	a.expect(http.StatusCreated, nil, http.MethodPost, "/api/v1/auth/register", creds)
+	var login struct{ Token string }
+	a.expect(http.StatusOK, &login, http.MethodPost, "/api/v1/auth/login", creds)
+	a.token = login.Token
+	resp := a.expect(http.StatusCreated, nil, http.MethodPost, "/api/v1/names", map[string]any{"name": "Alice", "metadata": map[string]any{"note": strings.Repeat("x", 100)}}, "X-Request-ID", "create-alice")
+	if resp.Header.Get("X-Request-ID") != "create-alice" { t.Fatalf("X-Request-ID %q", resp.Header.Get("X-Request-ID")) }
+
+	var c store.Capture
+	resp = a.expect(http.StatusOK, &c, http.MethodGet, "/api/v1/admin/captures/create-alice", nil)
+	if resp.Header.Get("Cache-Control") != "no-store" { t.Fatalf("Cache-Control %q", resp.Header.Get("Cache-Control")) }
+	if c.Method != http.MethodPost || c.URL != "/api/v1/names" || c.Status != http.StatusCreated || c.Tenant == "" { t.Fatalf("capture: %+v", c) }
+	if len(c.RequestBody) != 40 || !c.RequestTruncated || !strings.HasPrefix(c.RequestBody, `{"metadata":`) { t.Fatalf("request body %q, truncated %v", c.RequestBody, c.RequestTruncated) }
+	if !strings.Contains(c.ResponseBody, `"name":"Alice"`) && !c.ResponseTruncated { t.Fatalf("response body %q", c.ResponseBody) }
+	if got := c.RequestHeaders["Authorization"]; len(got) != 1 || got[0] != "[redacted]" { t.Fatalf("Authorization recorded as %q", got) }
+
+	a.expect(http.StatusCreated, nil, http.MethodPost, "/api/v1/apikeys", map[string]any{"name": "reader", "scopes": []string{auth.ScopeRead}}, "X-Request-ID", "new-key")
+	a.expect(http.StatusOK, &c, http.MethodGet, "/api/v1/admin/captures/new-key", nil)
+	if c.RequestBody != "[redacted]" || c.ResponseBody != "[redacted]" { t.Fatalf("new API key recorded: %+v", c) }
+	a.expect(http.StatusBadRequest, nil, http.MethodGet, "/api/v1/admin/captures/"+strings.Repeat("x", 129), nil)
+
+	// The admin of another tenant can't read them.
+	creds = map[string]string{"username": "bob", "password": "correct horse", "tenant": "team-b"}
+	a.token = ""
+	a.expect(http.StatusCreated, nil, http.MethodPost, "/api/v1/auth/register", creds)
+	a.expect(http.StatusOK, &login, http.MethodPost, "/api/v1/auth/login", creds)
+	a.token = login.Token
+	a.expect(http.StatusNotFound, nil, http.MethodGet, "/api/v1/admin/captures/create-alice", nil)
+}
+
+// TestAPIRegisterTenant founds a tenant and registers people into it, which
+// only its admins may do.
+func TestAPIRegisterTenant(t *testing.T) {
+	a := newAPI(t, memoryStores(), Config{})
+	login := func(creds map[string]string) {
+		var l struct{ Token string }
+		a.token = ""
+		a.expect(http.StatusOK, &l, http.MethodPost, "/api/v1/auth/login", creds)
+		a.token = l.Token
+	}
+	carol := map[string]string{"username": "carol", "password": "correct horse", "tenant": "team-c"}
+	var u store.User
+	a.expect(http.StatusCreated, &u, http.MethodPost, "/api/v1/auth/register", carol)
+	if u.Role != auth.RoleAdmin { t.Fatalf("founder: %+v", u) }
+	dave := map[string]string{"username": "dave", "password": "correct horse", "tenant": "team-c"}
+	a.expect(http.StatusForbidden, nil, http.MethodPost, "/api/v1/auth/register", dave)
+	login(carol)
+	a.expect(http.StatusCreated, &u, http.MethodPost, "/api/v1/auth/register", dave)
+	if u.Role != auth.RoleEditor { t.Fatalf("joined: %+v", u) }
+	a.expect(http.StatusOK, nil, http.MethodPut, "/api/v1/users/"+u.ID.Hex()+"/role", map[string]string{"role": auth.RoleViewer})
+
+	// Neither a viewer nor the admin of another tenant can register anyone.
+	login(dave)
+	a.expect(http.StatusForbidden, nil, http.MethodPost, "/api/v1/auth/register", map[string]string{"username": "erin", "password": "correct horse", "tenant": "team-c"})
+	a.token = ""
+	a.expect(http.StatusCreated, nil, http.MethodPost, "/api/v1/auth/register", map[string]string{"username": "frank", "password": "correct horse"})
+	login(map[string]string{"username": "frank", "password": "correct horse"})
+	a.expect(http.StatusForbidden, nil, http.MethodPost, "/api/v1/auth/register", map[string]string{"username": "erin", "password": "correct horse", "tenant": "team-c"})
+}
+
+// testMerge finds duplicates among names of its own and merges them.
+func testMerge(t *testing.T, a *client) {
+	t.Helper()
+	var zoe, dup, near store.Name
+	a.expect(http.StatusCreated, &zoe, http.MethodPost, "/api/v1/names", map[string]any{"name": "Zoë", "tags": []string{"a"}, "metadata": map[string]any{"x": 1}})
+	a.expect(http.StatusCreated, &dup, http.MethodPost, "/api/v1/names", map[string]any{"name": "zoe", "tags": []string{"b", "a"}, "metadata": map[string]any{"x": 2, "y": 3}})
+	a.expect(http.StatusCreated, &near, http.MethodPost, "/api/v1/names", map[string]any{"name": "Zoey"})
+
+	var clusters struct{ Items []store.DuplicateCluster }
+	a.expect(http.StatusOK, &clusters, http.MethodGet, "/api/v1/names/duplicates", nil)
+	if len(clusters.Items) != 1 || clusters.Items[0].Key != "zoe" || len(clusters.Items[0].Names) != 2 { t.Fatalf("duplicates: %+v", clusters) }
+	a.expect(http.StatusOK, &clusters, http.MethodGet, "/api/v1/names/duplicates?distance=1", nil)
+	if len(clusters.Items) != 1 || len(clusters.Items[0].Names) != 3 { t.Fatalf("duplicates within 1 edit: %+v", clusters) }
+	a.expect(http.StatusUnprocessableEntity, nil, http.MethodGet, "/api/v1/names/duplicates?distance=9", nil)
+
+	a.expect(http.StatusUnprocessableEntity, nil, http.MethodPost, "/api/v1/names/merge", map[string]any{"into": zoe.ID.Hex(), "from": []string{zoe.ID.Hex()}})
+	a.expect(http.StatusPreconditionRequired, nil, http.MethodPost, "/api/v1/names/merge", map[string]any{"into": zoe.ID.Hex(), "from": []string{dup.ID.Hex()}})
+	a.expect(http.StatusPreconditionFailed, nil, http.MethodPost, "/api/v1/names/merge", map[string]any{"into": zoe.ID.Hex(), "from": []string{dup.ID.Hex()}, "if_version": 2})
+	var merged store.Name
+	resp := a.expect(http.StatusOK, &merged, http.MethodPost, "/api/v1/names/merge", map[string]any{"into": zoe.ID.Hex(), "from": []string{dup.ID.Hex(), near.ID.Hex()}, "if_version": 1})
+	if !slices.Equal(merged.Tags, []string{"a", "b"}) || merged.Metadata["x"] != 1.0 || merged.Metadata["y"] != 3.0 || resp.Header.Get("ETag") != `"2"` { t.Fatalf("merged: %+v", merged) }
+	a.expect(http.StatusNotFound, nil, http.MethodGet, "/api/v1/names/"+dup.ID.Hex(), nil)
+	a.expect(http.StatusNotFound, nil, http.MethodPost, "/api/v1/names/merge", map[string]any{"into": zoe.ID.Hex(), "from": []string{near.ID.Hex()}, "if_version": 2})
+	if a.expect(http.StatusOK, &clusters, http.MethodGet, "/api/v1/names/duplicates?distance=1", nil); len(clusters.Items) != 0 { t.Fatalf("duplicates after the merge: %+v", clusters) }
+}
+
+// testStream opens GET /names/stream and waits for the change a write makes.
+func testStream(t *testing.T, a *client) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	resp, err := http.DefaultClient.Do(a.request(ctx, http.MethodGet, "/api/v1/names/stream", nil))
+	if err != nil { t.Fatal(err) }
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK { t.Fatalf("stream: %d", resp.StatusCode) }
+
+	a.expect(http.StatusCreated, nil, http.MethodPost, "/api/v1/names", map[string]any{"name": "Erin"})
+	for lines := bufio.NewScanner(resp.Body); lines.Scan(); {
+		if lines.Text() == "event: created" { return }
+	}
+	t.Fatal("stream ended without the change")
+}
+
+func TestAPILegacyPaths(t *testing.T) {
+	sunset := time.Date(2027, time.April, 15, 0, 0, 0, 0, time.UTC)
+	a := newAPI(t, memoryStores(), Config{LegacySunset: sunset})
+	a.signUp("alice")
+
+	resp := a.expect(http.StatusOK, nil, http.MethodGet, "/api/v1/names", nil)
+	if d := resp.Header.Get("Deprecation"); d != "" { t.Errorf("versioned path is deprecated: %q", d) }
+
+	var n store.Name
+	resp = a.expect(http.StatusCreated, &n, http.MethodPost, "/names", map[string]any{"name": "Alice"})
+	if d := resp.Header.Get("Deprecation"); d != "@1792022400" { t.Errorf("Deprecation %q", d) }
+	if s := resp.Header.Get("Sunset"); s != "Thu, 15 Apr 2027 00:00:00 GMT" { t.Errorf("Sunset %q", s) }
+	if l := resp.Header.Get("Link"); l != `</api/v1/names>; rel="successor-version"` { t.Errorf("Link %q", l) }
+	a.expect(http.StatusOK, nil, http.MethodGet, "/api/v1/names/"+n.ID.Hex(), nil)
+	a.expect(http.StatusOK, nil, http.MethodGet, "/names/"+n.ID.Hex(), nil)
+	a.expect(http.StatusNotFound, nil, http.MethodGet, "/api/v1/healthz", nil)
+}
+
+func TestAPICodecs(t *testing.T) {
+	a := newAPI(t, memoryStores(), Config{})
+	xmlIn := []string{"Content-Type", "application/xml"}
+	resp, b := a.call(http.MethodPost, "/api/v1/auth/register", "<register><username>alice</username><password>correct horse</password></register>", append(xmlIn, "Accept", "application/xml")...)
+	if resp.StatusCode != http.StatusCreated || resp.Header.Get("Content-Type") != "application/xml" || !strings.Contains(string(b), "<username>alice</username>") { t.Fatalf("register: %d %v %s", resp.StatusCode, resp.Header, b) }
+
+	var creds bytes.Buffer
+	if err := codec.MessagePack.Encode(&creds, codec.Object{{Key: "username", Value: "alice"}, {Key: "password", Value: "correct horse"}}); err != nil { t.Fatal(err) }
+	resp, b = a.call(http.MethodPost, "/api/v1/auth/login", creds.String(), "Content-Type", "application/msgpack", "Accept", "application/msgpack")
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "application/msgpack" { t.Fatalf("login: %d %v", resp.StatusCode, resp.Header) }
+	v, err := codec.MessagePack.Decode(bytes.NewReader(b))
+	if err != nil { t.Fatal(err) }
+	var login struct{ Token string }
+	if j, err := codec.JSON(v); err != nil || json.Unmarshal(j, &login) != nil || login.Token == "" { t.Fatalf("login: %s %v", j, err) }
+	a.token = login.Token
+
+	var n store.Name
+	a.expect(http.StatusCreated, &n, http.MethodPost, "/api/v1/names", `<name><name>Alice</name><tags type="array"><item>vip</item></tags></name>`, xmlIn...)
+	if n.Name != "Alice" || !slices.Equal(n.Tags, []string{"vip"}) { t.Fatalf("created %+v", n) }
+	resp, b = a.call(http.MethodGet, "/api/v1/names", nil, "Accept", "application/json;q=0.5, application/xml")
+	if resp.Header.Get("Content-Type") != "application/xml" || !strings.Contains(string(b), `<items type="array"><item><id>`+n.ID.Hex()+`</id><slug>alice</slug><name>Alice</name>`) { t.Fatalf("list: %v %s", resp.Header, b) }
+	resp, b = a.call(http.MethodGet, "/api/v1/names/665f1c2e9b1e8a3d4c5b6a79", nil, "Accept", "text/xml")
+	if resp.StatusCode != http.StatusNotFound || resp.Header.Get("Content-Type") != "application/problem+xml" || !strings.Contains(string(b), "<code>not_found</code>") { t.Fatalf("problem: %d %v %s", resp.StatusCode, resp.Header, b) }
+
+	// JSON stays the default, and streams aren't transcoded.
+	if resp, _ := a.call(http.MethodGet, "/api/v1/names", nil, "Accept", "*/*"); resp.Header.Get("Content-Type") != "application/json" { t.Fatalf("default: %v", resp.Header) }
+	if resp, _ := a.call(http.MethodGet, "/api/v1/names", nil, "Accept", "application/x-ndjson, application/xml;q=0.1"); resp.Header.Get("Content-Type") != "application/x-ndjson" { t.Fatalf("stream: %v", resp.Header) }
+
+	var bad problem
+	a.expect(http.StatusBadRequest, &bad, http.MethodPost, "/api/v1/names", "<name><name>Bob</name>", xmlIn...)
+	if bad.Code != handlers.CodeMalformedBody { t.Fatalf("malformed: %+v", bad) }
+	a.expect(http.StatusBadRequest, &bad, http.MethodPost, "/api/v1/names", "\xc4\x01\x00", "Content-Type", "application/msgpack")
+	if bad.Code != handlers.CodeMalformedBody { t.Fatalf("binary: %+v", bad) }
+	a.expect(http.StatusRequestEntityTooLarge, nil, http.MethodPost, "/api/v1/names", "<name>"+strings.Repeat("x", 2<<20)+"</name>", xmlIn...)
+}
+
+func TestAPIEnvelope(t *testing.T) {
+	a := newAPI(t, memoryStores(), Config{Envelope: true})
+	creds := map[string]string{"username": "alice", "password": "correct horse"}
+	a.expect(http.StatusCreated, nil, http.MethodPost, "/api/v1/auth/register", creds)
+	var login struct{ Data struct{ Token string } }
+	a.expect(http.StatusOK, &login, http.MethodPost, "/api/v1/auth/login", creds)
+	a.token = login.Data.Token
+	var n store.Name
+	for _, name := range []string{"Carol", "Alice", "Bob"} {
+		a.expect(http.StatusCreated, &struct{ Data *store.Name }{&n}, http.MethodPost, "/api/v1/names", map[string]any{"name": name})
+	}
+
+	type page struct {
+		Data  []store.Name
+		Meta  map[string]any
+		Links map[string]string
+	}
+	var first page
+	resp := a.expect(http.StatusOK, &first, http.MethodGet, "/api/v1/names?sort=name&limit=2", nil)
+	if len(first.Data) != 2 || first.Data[0].Name != "Alice" || first.Meta["total"] != 3.0 || first.Links["self"] != "/api/v1/names?sort=name&limit=2" || first.Links["next"] == "" {
+		t.Fatalf("first page: %+v", first)
+	}
+	if l := resp.Header.Get("Link"); l != "<"+first.Links["next"]+`>; rel="next"` { t.Fatalf("Link %q", l) }
+	var second page
+	a.expect(http.StatusOK, &second, http.MethodGet, first.Links["next"], nil)
+	if len(second.Data) != 1 || second.Data[0].Name != "Carol" || second.Links["next"] != "" { t.Fatalf("second page: %+v", second) }
+	var third page
+	a.expect(http.StatusOK, &third, http.MethodGet, "/api/v1/names?sort=name&limit=1&offset=2", nil)
+	if len(third.Data) != 1 || third.Links["prev"] != "/api/v1/names?limit=1&offset=1&sort=name" { t.Fatalf("by offset: %+v", third) }
+
+	// Clients that don't want it say so; everyone gets problems as they are,
+	// and the OpenAPI document, which has a shape of its own.
+	var plain store.Page
+	a.expect(http.StatusOK, &plain, http.MethodGet, "/api/v1/names?sort=name", nil, "Accept", "application/json; envelope=false")
+	if len(plain.Items) != 3 || plain.Total != 3 { t.Fatalf("not enveloped: %+v", plain) }
+	var one struct {
+		Data  store.Name
+		Links map[string]string
+	}
+	a.expect(http.StatusOK, &one, http.MethodGet, "/names/"+n.ID.Hex(), nil)
+	if one.Data.Name != "Bob" || one.Links["self"] != "/names/"+n.ID.Hex() { t.Fatalf("one name: %+v", one) }
+	var missing problem
+	a.expect(http.StatusNotFound, &missing, http.MethodGet, "/api/v1/names/665f1c2e9b1e8a3d4c5b6a79", nil)
+	if missing.Code != handlers.CodeNotFound { t.Fatalf("problem: %+v", missing) }
+	var spec map[string]any
+	if a.expect(http.StatusOK, &spec, http.MethodGet, "/api/v1/openapi.json", nil); spec["openapi"] == nil { t.Fatal("OpenAPI document enveloped") }
+}
+
+// slowNames never answers a listing before its caller gives up.
+type slowNames struct{ store.NameStore }
+
+func (slowNames) List(ctx context.Context, _ store.ListOptions) (store.Page, error) {
+	<-ctx.Done()
+	return store.Page{}, ctx.Err()
+}
+
+func TestAPITimeout(t *testing.T) {
+	st := memoryStores()
+	st.names = slowNames{st.names}
+	a := newAPI(t, st, Config{RequestTimeout: 50 * time.Millisecond})
+	a.signUp("alice")
+
+	var p problem
+	a.expect(http.StatusServiceUnavailable, &p, http.MethodGet, "/api/v1/names", nil)
+	if p.Code != handlers.CodeTimeout { t.Fatalf("code %q", p.Code) }
+}
+
+func TestAPIChaos(t *testing.T) {
+	st := memoryStores()
+	st.names = chaos.NewNames(st.names)
+	a := newAPI(t, st, Config{Chaos: ChaosConfig{Header: true}})
+	a.signUp("alice")
+
+	var p problem
+	a.expect(http.StatusServiceUnavailable, &p, http.MethodGet, "/api/v1/names", nil, chaos.Header, "timeout_rate=1")
+	if p.Code != handlers.CodeTimeout { t.Fatalf("timeout: %+v", p) }
+	a.expect(http.StatusInternalServerError, &p, http.MethodGet, "/api/v1/names", nil, chaos.Header, "error_rate=1")
+	if p.Code != handlers.CodeInternal { t.Fatalf("error: %+v", p) }
+	a.expect(http.StatusBadRequest, nil, http.MethodGet, "/api/v1/names", nil, chaos.Header, "error_rate=sometimes")
+	a.expect(http.StatusOK, nil, http.MethodGet, "/healthz", nil, chaos.Header, "error_rate=1")
+	a.expect(http.StatusOK, nil, http.MethodGet, "/api/v1/names", nil, chaos.Header, "latency=10ms, timeout_rate=0")
+}
+
+func TestAPIPrewrite(t *testing.T) {
+	st := memoryStores()
+	var err error
+	st.pre, err = prewrite.New([]prewrite.Hook{
+		{Name: "no-test", Check: `!hasPrefix(lower(name), "test")`, Message: "test names are not allowed"},
+		{Name: "tags", Set: "tags", Value: `split(lower(join(tags, ",")), ",")`},
+		{Name: "written-by", Set: "metadata.written_by", Value: `op + " in " + tenant`},
+		{Name: "broken", Check: `cond(name == "Boom", lower(tags) == "", true)`},
+	})
+	if err != nil { t.Fatal(err) }
+	a := newAPI(t, st, Config{})
+	a.signUp("alice")
+
+	var p struct{ Fields []handlers.FieldError }
+	a.expect(http.StatusUnprocessableEntity, &p, http.MethodPost, "/api/v1/names", map[string]any{"name": "Test Ada"})
+	if len(p.Fields) != 1 || p.Fields[0].Field != "name" || p.Fields[0].Message != "test names are not allowed" { t.Fatalf("rejected: %+v", p) }
+
+	var ada store.Name
+	resp := a.expect(http.StatusCreated, &ada, http.MethodPost, "/api/v1/names", map[string]any{"name": "Ada", "tags": []string{"VIP", "Core"}})
+	if !slices.Equal(ada.Tags, []string{"vip", "core"}) || ada.Metadata["written_by"] != "create in "+tenant.Default { t.Fatalf("created: %+v", ada) }
+	a.expect(http.StatusOK, &ada, http.MethodPut, "/api/v1/names/"+ada.ID.Hex(), map[string]any{"name": "Ada", "tags": []string{"ENG"}}, "If-Match", resp.Header.Get("ETag"))
+	if !slices.Equal(ada.Tags, []string{"eng"}) || ada.Metadata["written_by"] != "update in "+tenant.Default { t.Fatalf("updated: %+v", ada) }
+	a.expect(http.StatusUnprocessableEntity, nil, http.MethodPut, "/api/v1/names/by-name/testing", map[string]any{})
+	a.expect(http.StatusCreated, &ada, http.MethodPut, "/api/v1/names/by-name/Grace", map[string]any{})
+	if ada.Metadata["written_by"] != "create in "+tenant.Default { t.Fatalf("created by name: %+v", ada) }
+	a.expect(http.StatusOK, &ada, http.MethodPut, "/api/v1/names/by-name/Grace", map[string]any{}, "If-Match", `"1"`)
+	if ada.Metadata["written_by"] != "upsert in "+tenant.Default { t.Fatalf("upserted: %+v", ada) }
+
+	// The hooks see every write, whichever endpoint it comes through.
+	a.expect(http.StatusUnprocessableEntity, &p, http.MethodPatch, "/api/v1/names/"+ada.ID.Hex(), map[string]any{"name": "Testing"}, "If-Match", "*")
+	if len(p.Fields) != 1 || p.Fields[0].Message != "test names are not allowed" { t.Fatalf("patch rejected: %+v", p) }
+	a.expect(http.StatusOK, &ada, http.MethodPatch, "/api/v1/names/"+ada.ID.Hex(), map[string]any{"tags": []string{"OPS"}}, "If-Match", "*")
+	if ada.Name != "Grace" || !slices.Equal(ada.Tags, []string{"ops"}) || ada.Metadata["written_by"] != "update in "+tenant.Default { t.Fatalf("patched: %+v", ada) }
+	var bulk struct {
+		Succeeded, Failed int
+		Results           []struct {
+			Status int
+			Fields []handlers.FieldError
+		}
+	}
+	a.expect(http.StatusOK, &bulk, http.MethodPost, "/api/v1/names/bulk", []map[string]any{{"name": "Linus"}, {"name": "Test Ken"}})
+	if bulk.Succeeded != 1 || bulk.Results[1].Status != http.StatusUnprocessableEntity || len(bulk.Results[1].Fields) != 1 { t.Fatalf("bulk: %+v", bulk) }
+	var page store.Page
+	a.expect(http.StatusOK, &page, http.MethodGet, "/api/v1/names?name=Linus", nil)
+	if len(page.Items) != 1 || page.Items[0].Metadata["written_by"] != "create in "+tenant.Default { t.Fatalf("bulk created: %+v", page) }
+	linus := page.Items[0]
+	var failed struct {
+		Code      string
+		Operation int
+		Fields    []handlers.FieldError
+	}
+	a.expect(http.StatusUnprocessableEntity, &failed, http.MethodPost, "/api/v1/names/transaction", map[string]any{"operations": []map[string]any{
+		{"op": "update", "id": linus.ID.Hex(), "if_version": linus.Version, "data": map[string]any{"name": "Linus T"}},
+		{"op": "create", "data": map[string]any{"name": "Test Rob"}},
+	}})
+	if failed.Code != handlers.CodeValidationFailed || failed.Operation != 1 || len(failed.Fields) != 1 { t.Fatalf("transaction: %+v", failed) }
+
+	a.expect(http.StatusInternalServerError, nil, http.MethodPost, "/api/v1/names", map[string]any{"name": "Boom"})
+}
@@ -0,0 +1,82 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"app/internal/auth"
+	"app/internal/store"
+)
+
+func TestRequireAuthAPIKeys(t *testing.T) {
+	keys := store.NewMemoryAPIKeys()
+	owner := primitive.NewObjectID()
+	key, hash, err := auth.NewAPIKey()
+	if err != nil { t.Fatal(err) }
+	k := store.APIKey{ID: primitive.NewObjectID(), UserID: owner, Hash: hash, Scopes: []string{auth.ScopeRead}}
+	if err := keys.CreateAPIKey(context.Background(), k); err != nil { t.Fatal(err) }
+
+	s := &Server{tokens: auth.NewTokens([]byte("secret"), time.Hour), keys: keys}
+	var gotUser primitive.ObjectID
+	var canWrite bool
+	next := func(w http.ResponseWriter, r *http.Request) {
+		gotUser, canWrite = auth.UserIDFromContext(r.Context()), auth.HasScope(r.Context(), auth.ScopeWrite)
+	}
+	call := func(scope, apiKey string) int {
+		r := httptest.NewRequest(http.MethodGet, "/names", nil)
+		if apiKey != "" { r.Header.Set(apiKeyHeader, apiKey) }
+		rec := httptest.NewRecorder()
+		s.requireAuth(scope, next)(rec, r)
+		return rec.Code
+	}
+
+	if code := call(auth.ScopeRead, key); code != http.StatusOK || gotUser != owner || canWrite {
+		t.Fatalf("read with read key: %d, user %s, can write %v", code, gotUser.Hex(), canWrite)
+	}
+	if code := call(auth.ScopeWrite, key); code != http.StatusForbidden { t.Fatalf("write with read key: %d", code) }
+	if code := call(auth.ScopeRead, key+"x"); code != http.StatusUnauthorized { t.Fatalf("unknown key: %d", code) }
+	if code := call(auth.ScopeRead, ""); code != http.StatusUnauthorized { t.Fatalf("no credentials: %d", code) }
+
+	if err := keys.RevokeAPIKey(context.Background(), k.ID, primitive.NewObjectID()); err == nil { t.Fatal("revoked someone else's key") }
+	if err := keys.RevokeAPIKey(context.Background(), k.ID, owner); err != nil { t.Fatal(err) }
+	if code := call(auth.ScopeRead, key); code != http.StatusUnauthorized { t.Fatalf("revoked key: %d", code) }
+}
+
+func TestRequireRoles(t *testing.T) {
+	tokens := auth.NewTokens([]byte("secret"), time.Hour)
+	s := &Server{tokens: tokens, keys: store.NewMemoryAPIKeys()}
+	next := func(w http.ResponseWriter, r *http.Request) {}
+	call := func(h http.HandlerFunc, role string) int {
+		t.Helper()
+		tok, err := tokens.Issue(primitive.NewObjectID(), "", role)
+		if err != nil { t.Fatal(err) }
+		r := httptest.NewRequest(http.MethodGet, "/names", nil)
+		r.Header.Set("Authorization", "Bearer "+tok)
+		rec := httptest.NewRecorder()
+		h(rec, r)
+		return rec.Code
+	}
+
+	for _, tc := range []struct {
+		name   string
+		h      http.HandlerFunc
+		role   string
+		status int
+	}{
+		{"viewer reads", s.requireAuth(auth.ScopeRead, next), auth.RoleViewer, http.StatusOK},
+		{"viewer writes", s.requireAuth(auth.ScopeWrite, next), auth.RoleViewer, http.StatusForbidden},
+		{"editor writes", s.requireAuth(auth.ScopeWrite, next), auth.RoleEditor, http.StatusOK},
+		{"editor audits", s.requireAuth(auth.ScopeAudit, next), auth.RoleEditor, http.StatusForbidden},
+		{"admin audits", s.requireAuth(auth.ScopeAudit, next), auth.RoleAdmin, http.StatusOK},
+		{"token from before roles", s.requireAuth(auth.ScopeAdmin, next), "", http.StatusOK},
+		{"editor manages users", s.requireRole(auth.RoleAdmin, next), auth.RoleEditor, http.StatusForbidden},
+		{"admin manages users", s.requireRole(auth.RoleAdmin, next), auth.RoleAdmin, http.StatusOK},
+	} {
+		if code := call(tc.h, tc.role); code != tc.status { t.Errorf("%s: %d, want %d", tc.name, code, tc.status) }
+	}
+}
@@ -0,0 +1,144 @@
+package server
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+
+	"app/internal/requestid"
+	"app/internal/store"
+	"app/internal/tenant"
+)
+
+// CaptureConfig records a sample of the requests in full, their responses
+// too, so that what a client reports can be replayed.
+type CaptureConfig struct {
+	Percent      float64 // of requests recorded; <= 0 disables
+	MaxBodyBytes int     // of each body, kept
+	Sink         store.CaptureStore
+}
+
+// capturedSecretHeaders never have their values recorded.
+var capturedSecretHeaders = []string{"Authorization", "Cookie", "Set-Cookie", "X-Api-Key", "Proxy-Authorization"}
+
+// Paths, relative to apiV1, whose bodies carry passwords, tokens or keys,
+// and are never recorded.
+var captureSecretBodies = map[string]bool{
+	"/auth/register": true,
+	"/auth/login":    true,
+	"/apikeys":       true,
+}
+
+type captureKey struct{}
+
+// noteCaller tells the capture of the request in ctx, if it is being
+// recorded, which tenant the caller signed in to: only that tenant's
+// admins may read it.
+func noteCaller(ctx context.Context, tid string) {
+	if caller, found := ctx.Value(captureKey{}).(*atomic.Value); found { caller.Store(tid) }
+}
+
+// captureMiddleware records the sampled requests and responses in the
+// sink once they are done. It sits inside the compression, so bodies are
+// recorded as the handlers wrote them. Probes, metrics and event streams
+// aren't recorded.
+func (s *Server) captureMiddleware(next http.Handler) http.Handler {
+	cfg := s.cfg.Capture
+	if cfg.Percent <= 0 || cfg.Sink == nil { return next }
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := strings.TrimPrefix(r.URL.Path, apiV1)
+		if rateLimitExempt[r.URL.Path] || concurrencyExempt[path] || rand.Float64()*100 >= cfg.Percent { next.ServeHTTP(w, r); return }
+
+		c := store.Capture{RequestID: requestid.FromContext(r.Context()), At: time.Now().UTC(), Method: r.Method, URL: r.URL.RequestURI(), RequestHeaders: redactHeaders(r.Header)}
+		var caller atomic.Value
+		body := &recordingReader{ReadCloser: r.Body, max: cfg.MaxBodyBytes}
+		r.Body = body
+		cw := &recordingWriter{ResponseWriter: w, max: cfg.MaxBodyBytes}
+		next.ServeHTTP(cw, r.WithContext(context.WithValue(r.Context(), captureKey{}, &caller)))
+
+		c.DurationMS = float64(time.Since(c.At).Microseconds()) / 1000
+		c.Status, c.ResponseHeaders = cw.status, redactHeaders(w.Header())
+		if c.Status == 0 { c.Status = http.StatusOK }
+		if captureSecretBodies[path] {
+			c.RequestBody, c.ResponseBody = "[redacted]", "[redacted]"
+		} else {
+			c.RequestBody, c.RequestTruncated = bodyText(body.buf, body.truncated)
+			c.ResponseBody, c.ResponseTruncated = bodyText(cw.buf, cw.truncated)
+		}
+		c.Tenant, _ = caller.Load().(string)
+		if c.Tenant == "" && !s.tokens.Enabled() { c.Tenant = tenant.Default }
+
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(r.Context()), 2*time.Second)
+		defer cancel()
+		if err := cfg.Sink.SaveCapture(ctx, &c); err != nil { slog.WarnContext(ctx, "request not recorded", "err", err) }
+	})
+}
+
+// redactHeaders copies h without the values of capturedSecretHeaders.
+func redactHeaders(h http.Header) map[string][]string {
+	out := h.Clone()
+	for _, k := range capturedSecretHeaders {
+		if _, found := out[k]; found { out[k] = []string{"[redacted]"} }
+	}
+	return out
+}
+
+// bodyText is b as recorded: text, or a placeholder if it isn't any.
+func bodyText(b []byte, truncated bool) (string, bool) {
+	// Cutting b short may have split its last character.
+	for i := 0; truncated && i < utf8.UTFMax-1 && len(b) > 0 && !utf8.Valid(b); i++ { b = b[:len(b)-1] }
+	if !utf8.Valid(b) { return "[" + strconv.Itoa(len(b)) + " bytes of binary]", truncated }
+	return string(b), truncated
+}
+
+// recordingReader keeps the first max bytes the handler reads of a body.
+type recordingReader struct {
+	io.ReadCloser
+	max       int
+	buf       []byte
+	truncated bool
+}
+
+func (c *recordingReader) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	c.buf, c.truncated = keep(c.buf, p[:n], c.max, c.truncated)
+	return n, err
+}
+
+// recordingWriter keeps the status and the first max bytes of a response.
+type recordingWriter struct {
+	http.ResponseWriter
+	max       int
+	status    int
+	buf       []byte
+	truncated bool
+}
+
+func (c *recordingWriter) WriteHeader(code int) {
+	if c.status == 0 && code >= 200 { c.status = code }
+	c.ResponseWriter.WriteHeader(code)
+}
+
+func (c *recordingWriter) Write(b []byte) (int, error) {
+	if c.status == 0 { c.status = http.StatusOK }
+	c.buf, c.truncated = keep(c.buf, b, c.max, c.truncated)
+	return c.ResponseWriter.Write(b)
+}
+
+func (c *recordingWriter) Unwrap() http.ResponseWriter { return c.ResponseWriter }
+
+// keep appends to buf what of b fits in max bytes, and reports whether
+// any didn't.
+func keep(buf, b []byte, max int, truncated bool) ([]byte, bool) {
+	room := max - len(buf)
+	if len(b) > room { return append(buf, b[:room]...), true }
+	return append(buf, b...), truncated
+}
@@ -0,0 +1,59 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"app/internal/chaos"
+	"app/internal/handlers"
+	"app/internal/metrics"
+)
+
+// ChaosConfig injects faults into requests on purpose, for clients to test
+// their retries and operators their alerts against; see package chaos.
+type ChaosConfig struct {
+	Faults chaos.Faults // injected into every request
+	Header bool         // let a request ask for its own with X-Chaos; never in production
+}
+
+// Paths, relative to apiV1, that are spared: the probes and metrics that
+// would otherwise report the faults as an outage of their own.
+var chaosExempt = map[string]bool{
+	"/health":     true,
+	"/healthz":    true,
+	"/readyz":     true,
+	"/metrics":    true,
+	"/debug/pool": true,
+}
+
+// chaosMiddleware delays a request, answers it with a 500, or leaves the
+// store to time out under it, at the rates of cfg.Faults or of its X-Chaos
+// header, which replaces them. A malformed header gets a 400.
+func chaosMiddleware(cfg ChaosConfig, next http.Handler) http.Handler {
+	if !cfg.Faults.Any() && !cfg.Header { return next }
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if chaosExempt[strings.TrimPrefix(r.URL.Path, apiV1)] { next.ServeHTTP(w, r); return }
+		f := cfg.Faults
+		if header := r.Header.Get(chaos.Header); cfg.Header && header != "" {
+			var err error
+			if f, err = chaos.Parse(header); err != nil { handlers.BadRequest(w, "invalid "+chaos.Header+" header: "+err.Error()); return }
+		}
+		if !f.Any() { next.ServeHTTP(w, r); return }
+		if d := f.Delay(); d > 0 {
+			metrics.ChaosFault("latency")
+			t := time.NewTimer(d)
+			select {
+			case <-t.C:
+			case <-r.Context().Done():
+				t.Stop(); return
+			}
+		}
+		if f.Fail() {
+			metrics.ChaosFault("error")
+			handlers.WriteProblem(w, http.StatusInternalServerError, handlers.CodeInternal, "a fault injected on purpose (chaos mode)", nil)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(chaos.NewContext(r.Context(), f)))
+	})
+}
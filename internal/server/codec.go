@@ -0,0 +1,121 @@
+package server
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"app/internal/codec"
+	"app/internal/handlers"
+)
+
+// codecMiddleware lets clients speak the formats of the codec registry
+// instead of JSON. A request body whose Content-Type is one of them is
+// transcoded to JSON before the handlers read it, and a JSON response,
+// problems included, is transcoded to the format Accept prefers over JSON.
+// Streams, downloads and anything else that isn't JSON pass through.
+func (s *Server) codecMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		out := codec.Negotiate(r.Header.Get("Accept"))
+		if !slices.Contains(w.Header().Values("Vary"), "Accept") { w.Header().Add("Vary", "Accept") }
+		if out != nil {
+			cw := &codecWriter{ResponseWriter: w, codec: out}
+			defer cw.close()
+			w = cw
+		}
+		if in := codec.Lookup(r.Header.Get("Content-Type")); in != nil && r.Body != nil && r.Body != http.NoBody {
+			if !s.decodeBody(w, r, in) { return }
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// decodeBody replaces the body of r with its JSON equivalent. It answers
+// itself on failure: 413 past the body limit, 400 for a body that doesn't
+// parse.
+func (s *Server) decodeBody(w http.ResponseWriter, r *http.Request, c codec.Codec) bool {
+	body := r.Body
+	if !bodyLimitExempt[strings.TrimPrefix(r.URL.Path, apiV1)] { body = http.MaxBytesReader(w, body, s.cfg.MaxBodyBytes) }
+	v, err := c.Decode(body)
+	_ = r.Body.Close()
+	var data []byte
+	if err == nil { data, err = codec.JSON(v) }
+	if err != nil {
+		var mbe *http.MaxBytesError
+		switch {
+		case errors.As(err, &mbe):
+			handlers.TooLarge(w, mbe.Limit)
+		case errors.Is(err, io.EOF):
+			handlers.WriteProblem(w, http.StatusBadRequest, handlers.CodeMalformedBody, "request body is empty", nil)
+		default:
+			mt, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+			handlers.WriteProblem(w, http.StatusBadRequest, handlers.CodeMalformedBody, "invalid "+mt+" body: "+err.Error(), nil)
+		}
+		return false
+	}
+	r.Body, r.ContentLength = io.NopCloser(bytes.NewReader(data)), int64(len(data))
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("Content-Length", strconv.Itoa(len(data)))
+	return true
+}
+
+// codecWriter holds back a JSON body until the handler is done, to write
+// it in another format; any other goes straight out.
+type codecWriter struct {
+	http.ResponseWriter
+	codec   codec.Codec
+	status  int
+	hold    bool // the body is held back in buf
+	problem bool // the held-back body is an RFC 7807 problem
+	decided bool // the status is known, and the header written unless held
+	buf     bytes.Buffer
+}
+
+func (c *codecWriter) WriteHeader(code int) {
+	if c.decided { return }
+	if code < 200 { c.ResponseWriter.WriteHeader(code); return }
+	c.status, c.decided = code, true
+	h := c.Header()
+	mt, _, _ := mime.ParseMediaType(h.Get("Content-Type"))
+	c.problem = mt == "application/problem+json"
+	c.hold = (mt == "application/json" || c.problem) && h.Get("Content-Disposition") == ""
+	if !c.hold { c.ResponseWriter.WriteHeader(code) }
+}
+
+func (c *codecWriter) Write(b []byte) (int, error) {
+	if !c.decided { c.WriteHeader(http.StatusOK) }
+	if c.hold { return c.buf.Write(b) }
+	return c.ResponseWriter.Write(b)
+}
+
+// FlushError only reaches the client for bodies that aren't held back.
+func (c *codecWriter) FlushError() error {
+	if c.hold { return nil }
+	return http.NewResponseController(c.ResponseWriter).Flush()
+}
+
+func (c *codecWriter) Flush() { _ = c.FlushError() }
+
+func (c *codecWriter) Unwrap() http.ResponseWriter { return c.ResponseWriter }
+
+// close writes the held-back body in the negotiated format. One that isn't
+// JSON after all goes out as it is.
+func (c *codecWriter) close() {
+	if !c.hold { return }
+	body := c.buf.Bytes()
+	if v, err := codec.Parse(body); err == nil {
+		var enc bytes.Buffer
+		if err := c.codec.Encode(&enc, v); err == nil {
+			body = enc.Bytes()
+			c.Header().Set("Content-Type", c.codec.ContentType(c.problem))
+		}
+	}
+	c.Header().Del("Content-Length")
+	c.ResponseWriter.WriteHeader(c.status)
+	_, _ = c.ResponseWriter.Write(body)
+}
@@ -0,0 +1,195 @@
+package server
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// CompressionConfig says when responses are compressed.
+type CompressionConfig struct {
+	// MinBytes is the smallest body worth compressing; smaller ones go out
+	// as they are. <= 0 disables compression.
+	MinBytes int
+	Level    int  // gzip and deflate level, 1 (fastest) to 9 (smallest)
+	Zstd     bool // also offer zstd, which wins ties with the others
+}
+
+// encoder is what gzip, zlib and zstd writers have in common.
+type encoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(io.Writer)
+}
+
+// newEncoders returns a constructor per content coding offered, most
+// preferred first.
+func newEncoders(cfg CompressionConfig) ([]string, map[string]func() encoder) {
+	codings := map[string]func() encoder{
+		"gzip":    func() encoder { w, _ := gzip.NewWriterLevel(nil, cfg.Level); return w },
+		"deflate": func() encoder { w, _ := zlib.NewWriterLevel(nil, cfg.Level); return w }, // HTTP's deflate is zlib
+	}
+	if !cfg.Zstd { return []string{"gzip", "deflate"}, codings }
+	codings["zstd"] = func() encoder {
+		// One goroutine per encoder, as each serves a single response, and
+		// the 8 MiB window browsers accept.
+		w, _ := zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1), zstd.WithWindowSize(8<<20))
+		return w
+	}
+	return []string{"zstd", "gzip", "deflate"}, codings
+}
+
+// compressMiddleware compresses responses with the best coding the client's
+// Accept-Encoding allows, once the body reaches cfg.MinBytes. Only content
+// that compresses well is: JSON, NDJSON, CSV, HTML, XML, MessagePack. Bodies
+// a handler already encoded pass through, and so do event streams, which are flushed event by
+// event.
+func compressMiddleware(cfg CompressionConfig, next http.Handler) http.Handler {
+	if cfg.MinBytes <= 0 { return next }
+	offered, codings := newEncoders(cfg)
+	pools := make(map[string]*sync.Pool, len(codings))
+	for name, newEnc := range codings { pools[name] = &sync.Pool{New: func() any { return newEnc() }} }
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+		coding := negotiateEncoding(r.Header.Get("Accept-Encoding"), offered)
+		if coding == "" || r.Method == http.MethodHead { next.ServeHTTP(w, r); return }
+		cw := &compressWriter{ResponseWriter: w, coding: coding, pool: pools[coding], minBytes: cfg.MinBytes}
+		defer cw.close()
+		next.ServeHTTP(cw, r)
+	})
+}
+
+// negotiateEncoding picks the coding of offered the client rates highest
+// (RFC 9110, section 12.5.3), or "" for none. Ties go to the one offered
+// first.
+func negotiateEncoding(accept string, offered []string) string {
+	if accept == "" { return "" }
+	q := map[string]float64{}
+	for _, part := range strings.Split(accept, ",") {
+		name, params, _ := strings.Cut(part, ";")
+		weight := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			var err error
+			if weight, err = strconv.ParseFloat(v, 64); err != nil { continue }
+		}
+		q[strings.ToLower(strings.TrimSpace(name))] = weight
+	}
+	best, bestQ := "", 0.0
+	for _, coding := range offered {
+		weight, ok := q[coding]
+		if !ok { weight = q["*"] }
+		if weight > bestQ { best, bestQ = coding, weight }
+	}
+	return best
+}
+
+// compressible reports whether a response of contentType is worth
+// compressing.
+func compressible(contentType string) bool {
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil { return false }
+	switch {
+	case mt == "text/event-stream":
+		return false
+	case strings.HasPrefix(mt, "text/"), mt == "application/json", strings.HasSuffix(mt, "+json"), mt == "application/x-ndjson", mt == "application/javascript",
+		mt == "application/xml", strings.HasSuffix(mt, "+xml"), mt == "application/msgpack":
+		return true
+	}
+	return false
+}
+
+// compressWriter holds the start of the body back until it knows whether to
+// compress it: as soon as it reaches minBytes, or when the handler flushes
+// or returns before then, in which case it goes out as it is.
+type compressWriter struct {
+	http.ResponseWriter
+	coding   string
+	pool     *sync.Pool
+	minBytes int
+
+	status  int
+	buf     []byte
+	decided bool    // the header is written
+	enc     encoder // set once compressing
+}
+
+func (c *compressWriter) WriteHeader(code int) {
+	if c.decided || c.status != 0 { return }
+	if code < 200 { c.ResponseWriter.WriteHeader(code); return } // informational, sent right away
+	c.status = code
+}
+
+func (c *compressWriter) Write(b []byte) (int, error) {
+	if c.status == 0 { c.status = http.StatusOK }
+	if !c.decided {
+		if !c.eligible() { return len(b), c.commit(false, b) }
+		c.buf = append(c.buf, b...)
+		if len(c.buf) < c.minBytes { return len(b), nil }
+		return len(b), c.commit(true, nil)
+	}
+	if c.enc != nil { return c.enc.Write(b) }
+	return c.ResponseWriter.Write(b)
+}
+
+// eligible reports whether the response, going by its status and headers,
+// can be compressed.
+func (c *compressWriter) eligible() bool {
+	h := c.Header()
+	if c.status == http.StatusNoContent || c.status == http.StatusNotModified || h.Get("Content-Encoding") != "" { return false }
+	if n, err := strconv.Atoi(h.Get("Content-Length")); err == nil && n < c.minBytes { return false }
+	return compressible(h.Get("Content-Type"))
+}
+
+// commit writes the header, compressing the body from here on or not, and
+// then what was held back followed by more.
+func (c *compressWriter) commit(compress bool, more []byte) error {
+	c.decided = true
+	if compress {
+		h := c.Header()
+		h.Del("Content-Length")
+		h.Set("Content-Encoding", c.coding)
+		c.enc = c.pool.Get().(encoder)
+		c.enc.Reset(c.ResponseWriter)
+	}
+	c.ResponseWriter.WriteHeader(c.status)
+	body := append(c.buf, more...)
+	c.buf = nil
+	if len(body) == 0 { return nil }
+	if c.enc != nil { _, err := c.enc.Write(body); return err }
+	_, err := c.ResponseWriter.Write(body)
+	return err
+}
+
+// FlushError sends what the handler wrote so far, compressed if it was
+// already large enough. http.ResponseController calls it.
+func (c *compressWriter) FlushError() error {
+	if !c.decided {
+		if c.status == 0 { c.status = http.StatusOK }
+		if err := c.commit(false, nil); err != nil { return err }
+	}
+	if c.enc != nil {
+		if err := c.enc.Flush(); err != nil { return err }
+	}
+	return http.NewResponseController(c.ResponseWriter).Flush()
+}
+
+func (c *compressWriter) Flush() { _ = c.FlushError() }
+
+func (c *compressWriter) close() {
+	if !c.decided && c.status != 0 { _ = c.commit(false, nil) }
+	if c.enc == nil { return }
+	_ = c.enc.Close()
+	c.enc.Reset(io.Discard)
+	c.pool.Put(c.enc)
+	c.enc = nil
+}
+
+func (c *compressWriter) Unwrap() http.ResponseWriter { return c.ResponseWriter }
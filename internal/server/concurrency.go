@@ -0,0 +1,112 @@
+package server
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"app/internal/handlers"
+)
+
+// ConcurrencyConfig caps the requests served at once, so that a spike waits
+// in line here rather than for a connection from MongoDB's pool. A request
+// over a cap waits up to QueueTimeout for a slot, then gets a 503.
+type ConcurrencyConfig struct {
+	MaxInFlight      int            // across all routes; <= 0 disables
+	RouteMaxInFlight int            // per route; <= 0 disables
+	Routes           map[string]int // per route pattern, such as "GET /api/v1/names/export", overriding RouteMaxInFlight
+	QueueTimeout     time.Duration
+}
+
+func (c ConcurrencyConfig) enabled() bool {
+	return c.MaxInFlight > 0 || c.RouteMaxInFlight > 0 || len(c.Routes) > 0
+}
+
+// Paths, relative to apiV1, that take no slot: event streams stay open for
+// as long as their clients listen, and long polls as they wait, mostly idle.
+var concurrencyExempt = map[string]bool{
+	"/names/stream":  true,
+	"/names/changes": true,
+}
+
+// slots are the semaphores of a ConcurrencyConfig: one for the server, and
+// one per route pattern, made as the route is first requested.
+type slots struct {
+	cfg    ConcurrencyConfig
+	global chan struct{} // nil without a global cap
+
+	mu     sync.Mutex
+	routes map[string]chan struct{} // nil for routes without a cap
+}
+
+func newSlots(cfg ConcurrencyConfig) *slots {
+	s := &slots{cfg: cfg, routes: map[string]chan struct{}{}}
+	if cfg.MaxInFlight > 0 { s.global = make(chan struct{}, cfg.MaxInFlight) }
+	return s
+}
+
+// route returns the semaphore of pattern, or nil if it has no cap.
+func (s *slots) route(pattern string) chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sem, found := s.routes[pattern]
+	if found { return sem }
+	n, override := s.cfg.Routes[pattern]
+	if !override { n = s.cfg.RouteMaxInFlight }
+	if n > 0 { sem = make(chan struct{}, n) }
+	s.routes[pattern] = sem
+	return sem
+}
+
+// take waits for a slot in sem until expired fires or the request ends. A
+// nil sem has room for everyone.
+func take(r *http.Request, sem chan struct{}, expired <-chan time.Time) bool {
+	if sem == nil { return true }
+	select {
+	case sem <- struct{}{}:
+		return true
+	default:
+	}
+	select {
+	case sem <- struct{}{}:
+		return true
+	case <-expired:
+	case <-r.Context().Done():
+	}
+	return false
+}
+
+func release(sem chan struct{}) {
+	if sem != nil { <-sem }
+}
+
+// concurrencyMiddleware admits a request once there's a slot for it on its
+// route, named by pattern, and then on the server, in that order so that a
+// busy route doesn't hold slots others could use. Probes, metrics and
+// event streams go straight through.
+func concurrencyMiddleware(cfg ConcurrencyConfig, pattern func(*http.Request) string, next http.Handler) http.Handler {
+	if !cfg.enabled() { return next }
+	s := newSlots(cfg)
+	retryAfter := strconv.Itoa(max(1, int(math.Ceil(cfg.QueueTimeout.Seconds()))))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if rateLimitExempt[r.URL.Path] || concurrencyExempt[strings.TrimPrefix(r.URL.Path, apiV1)] { next.ServeHTTP(w, r); return }
+
+		timer := time.NewTimer(cfg.QueueTimeout)
+		defer timer.Stop()
+		route := s.route(pattern(r))
+		if !take(r, route, timer.C) { overloaded(w, retryAfter); return }
+		defer release(route)
+		if !take(r, s.global, timer.C) { overloaded(w, retryAfter); return }
+		defer release(s.global)
+		next.ServeHTTP(w, r)
+	})
+}
+
+func overloaded(w http.ResponseWriter, retryAfter string) {
+	w.Header().Set("Retry-After", retryAfter)
+	handlers.WriteProblem(w, http.StatusServiceUnavailable, handlers.CodeOverloaded, "the server is busy; try again shortly", nil)
+}
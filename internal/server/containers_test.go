@@ -0,0 +1,204 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"app/internal/audit"
+	"app/internal/changes"
+	"app/internal/history"
+	"app/internal/ids"
+	"app/internal/notes"
+	"app/internal/owner"
+	"app/internal/revision"
+	"app/internal/store"
+)
+
+// TestAPIMongo runs the API suite against MongoDB: the deployment at
+// MONGO_TEST_URI if set, which must be a replica set for change streams,
+// or else a single-node replica set in a container. Without either, it is
+// skipped.
+func TestAPIMongo(t *testing.T) {
+	if testing.Short() { t.Skip("starts MongoDB") }
+	uri := os.Getenv("MONGO_TEST_URI")
+	if uri == "" { uri = startMongo(t) }
+	testAPI(t, mongoStores(t, uri))
+}
+
+// TestMongoCollation checks that the configured collation decides which
+// names are duplicates, and that the unique index follows it when it
+// changes.
+func TestMongoCollation(t *testing.T) {
+	if testing.Short() { t.Skip("starts MongoDB") }
+	uri := os.Getenv("MONGO_TEST_URI")
+	if uri == "" { uri = startMongo(t) }
+	ctx := context.Background()
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+	database := "collationtest_" + hex.EncodeToString(suffix)
+	open := func(c store.Collation) *store.MongoNames {
+		t.Helper()
+		db, err := store.Connect(ctx, store.MongoConfig{URI: uri, Database: database, MaxPoolSize: 2, Collation: c})
+		if err != nil { t.Fatal(err) }
+		t.Cleanup(func() {
+			_ = db.DB.Drop(ctx)
+			_ = db.Disconnect(ctx)
+		})
+		names, err := store.NewMongoNames(ctx, db, "names", "name_events")
+		if err != nil { t.Fatal(err) }
+		return names
+	}
+
+	names := open(store.Collation{Locale: "fr", Strength: 1})
+	for _, name := range []string{"José", "Zoë", "éclair"} {
+		if err := names.Create(ctx, &store.Name{Name: name}); err != nil { t.Fatal(err) }
+	}
+	if err := names.Create(ctx, &store.Name{Name: "jose"}); !errors.Is(err, store.ErrDuplicate) { t.Fatalf("jose next to José: %v", err) }
+	page, err := names.List(ctx, store.ListOptions{Limit: 10, SortBy: "name"})
+	if err != nil || len(page.Items) != 3 || page.Items[0].Name != "éclair" || page.Items[2].Name != "Zoë" { t.Fatalf("sorted: %+v, %v", page.Items, err) }
+	if _, err := names.List(ctx, store.ListOptions{Limit: 10, Locale: "xx"}); !errors.Is(err, store.ErrUnsupportedLocale) { t.Fatalf("locale xx: %v", err) }
+
+	// Without a collation the index is rebuilt to compare code points.
+	names = open(store.Collation{})
+	if err := names.Create(ctx, &store.Name{Name: "jose"}); err != nil { t.Fatalf("jose without a collation: %v", err) }
+	if page, err = names.List(ctx, store.ListOptions{Limit: 10, SortBy: "name"}); err != nil || page.Items[0].Name != "José" { t.Fatalf("code point order: %+v, %v", page.Items, err) }
+	if page, err = names.List(ctx, store.ListOptions{Limit: 10, SortBy: "name", Locale: "fr"}); err != nil || page.Items[0].Name != "éclair" { t.Fatalf("locale fr: %+v, %v", page.Items, err) }
+}
+
+// TestMongoMigrations brings documents from before tenants and timestamps
+// up to date, and takes the migrations that can be undone back down.
+func TestMongoMigrations(t *testing.T) {
+	if testing.Short() { t.Skip("starts MongoDB") }
+	uri := os.Getenv("MONGO_TEST_URI")
+	if uri == "" { uri = startMongo(t) }
+	ctx := context.Background()
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+	db, err := store.Connect(ctx, store.MongoConfig{URI: uri, Database: "migratetest_" + hex.EncodeToString(suffix), MaxPoolSize: 2})
+	if err != nil { t.Fatal(err) }
+	t.Cleanup(func() {
+		_ = db.DB.Drop(ctx)
+		_ = db.Disconnect(ctx)
+	})
+	cols := store.MongoCollections{Names: "names", Events: "name_events"}
+	old := db.Collection("names")
+	if _, err := old.InsertOne(ctx, bson.M{"name": "Ada"}); err != nil { t.Fatal(err) }
+
+	if from, to, err := db.Migrate(ctx, cols, -1); err != nil || from != 0 || to != store.LatestMongoMigration() { t.Fatalf("migrated %d to %d: %v", from, to, err) }
+	var ada store.Name
+	if err := old.FindOne(ctx, bson.M{"name": "Ada"}).Decode(&ada); err != nil { t.Fatal(err) }
+	if ada.Tenant != "default" || ada.CreatedAt.IsZero() || !ada.UpdatedAt.Equal(ada.CreatedAt) || !ada.CreatedAt.Equal(ada.ID.Timestamp()) { t.Fatalf("migrated %+v", ada) }
+	if from, to, err := db.Migrate(ctx, cols, -1); err != nil || from != to { t.Fatalf("migrated again %d to %d: %v", from, to, err) }
+
+	// Down to 1: the backfilled dates and the created_by index go.
+	if _, to, err := db.Migrate(ctx, cols, 1); err != nil || to != 1 { t.Fatalf("undone to %d: %v", to, err) }
+	ada = store.Name{}
+	if err := old.FindOne(ctx, bson.M{"name": "Ada"}).Decode(&ada); err != nil || !ada.CreatedAt.IsZero() { t.Fatalf("undone %+v, %v", ada, err) }
+	specs, err := old.Indexes().ListSpecifications(ctx)
+	if err != nil { t.Fatal(err) }
+	for _, s := range specs {
+		if s.Name == "tenant_created_by" { t.Fatal("the created_by index survived its undoing") }
+	}
+	if v, err := db.MigrationVersion(ctx); err != nil || v != 1 { t.Fatalf("version %d, %v", v, err) }
+	if _, _, err := db.Migrate(ctx, cols, 0); err == nil { t.Fatal("undid the move to tenants") }
+}
+
+// startMongo runs mongo:7 as a one-member replica set and returns its URI.
+func startMongo(t *testing.T) string {
+	t.Helper()
+	testcontainers.SkipIfProviderIsNotHealthy(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	c, err := testcontainers.Run(ctx, "mongo:7",
+		testcontainers.WithExposedPorts("27017/tcp"),
+		testcontainers.WithCmd("--replSet", "rs0", "--bind_ip_all"),
+		testcontainers.WithWaitStrategy(wait.ForListeningPort("27017/tcp")),
+	)
+	testcontainers.CleanupContainer(t, c)
+	if err != nil { t.Fatal(err) }
+	endpoint, err := c.PortEndpoint(ctx, "27017/tcp", "mongodb")
+	if err != nil { t.Fatal(err) }
+	uri := endpoint + "/?directConnection=true"
+
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	if err != nil { t.Fatal(err) }
+	defer client.Disconnect(ctx)
+	admin := client.Database("admin")
+	// The member is named by its address inside the container; connecting
+	// directly, the driver never has to reach it by that name.
+	cfg := bson.M{"_id": "rs0", "members": bson.A{bson.M{"_id": 0, "host": "localhost:27017"}}}
+	if err := admin.RunCommand(ctx, bson.D{{Key: "replSetInitiate", Value: cfg}}).Err(); err != nil { t.Fatal(err) }
+	for {
+		var hello struct{ IsWritablePrimary bool `bson:"isWritablePrimary"` }
+		if err := admin.RunCommand(ctx, bson.D{{Key: "hello", Value: 1}}).Decode(&hello); err != nil { t.Fatal(err) }
+		if hello.IsWritablePrimary { return uri }
+		select {
+		case <-ctx.Done():
+			t.Fatal("replica set never elected a primary")
+		case <-time.After(200 * time.Millisecond):
+		}
+	}
+}
+
+// mongoStores builds the stores over a database of their own, dropped when
+// the test ends.
+func mongoStores(t *testing.T, uri string) stores {
+	t.Helper()
+	ctx := context.Background()
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+	db, err := store.Connect(ctx, store.MongoConfig{URI: uri, Database: "apitest_" + hex.EncodeToString(suffix), MaxPoolSize: 10})
+	if err != nil { t.Fatal(err) }
+	t.Cleanup(func() {
+		_ = db.DB.Drop(ctx)
+		_ = db.Disconnect(ctx)
+	})
+
+	must := func(err error) {
+		t.Helper()
+		if err != nil { t.Fatal(err) }
+	}
+	_, _, err = db.Migrate(ctx, store.MongoCollections{Names: "names", Events: "name_events"}, -1)
+	must(err)
+	names, err := store.NewMongoNames(ctx, db, "names", "name_events")
+	must(err)
+	trail, err := store.NewMongoAudit(ctx, db, "audit")
+	must(err)
+	hist, err := store.NewMongoHistory(ctx, db, "name_history")
+	must(err)
+	st := stores{audit: trail, hist: hist, stats: names, dups: names, rand: names, byKey: names, forms: names, tx: names, pool: db}
+	st.notes, err = store.NewMongoNotes(ctx, db, "notes", "names")
+	must(err)
+	st.revs = store.NewMongoRevisions(db, "name_revisions")
+	changeLog, err := store.NewMongoChangeLog(ctx, db, "changes", time.Hour)
+	must(err)
+	st.log = changes.NewNames(owner.NewNames(notes.NewNames(revision.NewNames(audit.NewNames(history.NewNames(names, hist), trail), st.revs), st.notes, notes.Block), names), names, changeLog, changeConfig)
+	st.names = ids.NewNames(st.log, names, ids.Slug)
+	st.users, err = store.NewMongoUsers(ctx, db, "users")
+	must(err)
+	st.keys, err = store.NewMongoAPIKeys(ctx, db, "api_keys")
+	must(err)
+	st.usage, err = store.NewMongoUsage(ctx, db, "usage")
+	must(err)
+	st.idem, err = store.NewMongoIdempotency(ctx, db, "idempotency")
+	must(err)
+	st.docs, err = store.NewMongoDocs(ctx, db, testResources(t).Collections())
+	must(err)
+	st.jobs, err = store.NewMongoJobs(ctx, db, "jobs")
+	must(err)
+	st.hooks, err = store.NewMongoWebhooks(ctx, db, "webhooks", "webhook_deliveries")
+	must(err)
+	return st
+}
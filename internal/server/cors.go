@@ -0,0 +1,92 @@
+package server
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"app/internal/handlers"
+)
+
+// CORSConfig says which browser origins may call the API and how.
+type CORSConfig struct {
+	AllowedOrigins []string // scheme://host[:port], or "*" for any
+	AllowedMethods []string
+	AllowedHeaders []string // matched case-insensitively
+	// AllowCredentials lets browsers send cookies and Authorization along.
+	// It can't be combined with the "*" origin.
+	AllowCredentials bool
+	MaxAge           time.Duration // how long a preflight may be cached; 0 leaves it to the browser
+}
+
+// Response headers browsers may read from cross-origin responses.
+const corsExposedHeaders = "X-Request-ID, Idempotent-Replayed, ETag, Retry-After, Deprecation, Sunset, Link"
+
+// corsMiddleware answers preflight requests and marks the responses to
+// allowed origins as readable. Requests without an Origin header, which
+// don't come from a browser context, pass through untouched; so do requests
+// from origins that aren't allowed, which the browser then refuses to hand
+// to the page.
+func corsMiddleware(cfg CORSConfig, next http.Handler) http.Handler {
+	anyOrigin := slices.Contains(cfg.AllowedOrigins, "*")
+	echo := !anyOrigin || cfg.AllowCredentials
+	headers := make(map[string]bool, len(cfg.AllowedHeaders))
+	for _, h := range cfg.AllowedHeaders { headers[http.CanonicalHeaderKey(h)] = true }
+	methods := strings.Join(cfg.AllowedMethods, ", ")
+	allowHeaders := strings.Join(cfg.AllowedHeaders, ", ")
+
+	allowed := func(origin string) bool {
+		return anyOrigin || slices.ContainsFunc(cfg.AllowedOrigins, func(o string) bool { return strings.EqualFold(o, origin) })
+	}
+	// A specific origin is echoed back, which makes responses vary by it;
+	// browsers refuse credentials with the wildcard, so it's echoed then too.
+	allowOrigin := func(h http.Header, origin string) {
+		if echo {
+			h.Set("Access-Control-Allow-Origin", origin)
+		} else {
+			h.Set("Access-Control-Allow-Origin", "*")
+		}
+		if cfg.AllowCredentials { h.Set("Access-Control-Allow-Credentials", "true") }
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if echo { w.Header().Add("Vary", "Origin") }
+		origin := r.Header.Get("Origin")
+		if origin == "" { next.ServeHTTP(w, r); return }
+
+		reqMethod := r.Header.Get("Access-Control-Request-Method")
+		if r.Method != http.MethodOptions || reqMethod == "" {
+			if allowed(origin) {
+				allowOrigin(w.Header(), origin)
+				w.Header().Set("Access-Control-Expose-Headers", corsExposedHeaders)
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// Preflight: the browser asks before sending the real request.
+		w.Header().Add("Vary", "Access-Control-Request-Method")
+		w.Header().Add("Vary", "Access-Control-Request-Headers")
+		if !allowed(origin) {
+			handlers.WriteProblem(w, http.StatusForbidden, handlers.CodeForbidden, "origin "+origin+" is not allowed", nil)
+			return
+		}
+		if !slices.Contains(cfg.AllowedMethods, reqMethod) {
+			handlers.WriteProblem(w, http.StatusForbidden, handlers.CodeForbidden, "method "+reqMethod+" is not allowed cross-origin", nil)
+			return
+		}
+		for _, h := range strings.Split(r.Header.Get("Access-Control-Request-Headers"), ",") {
+			if h = strings.TrimSpace(h); h != "" && !headers[http.CanonicalHeaderKey(h)] {
+				handlers.WriteProblem(w, http.StatusForbidden, handlers.CodeForbidden, "header "+h+" is not allowed cross-origin", nil)
+				return
+			}
+		}
+		allowOrigin(w.Header(), origin)
+		w.Header().Set("Access-Control-Allow-Methods", methods)
+		if allowHeaders != "" { w.Header().Set("Access-Control-Allow-Headers", allowHeaders) }
+		if cfg.MaxAge > 0 { w.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(cfg.MaxAge.Seconds()))) }
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
@@ -0,0 +1,125 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"app/internal/handlers"
+)
+
+// A drain takes the server out of a load balancer's rotation ahead of a
+// shutdown, so that a rolling deploy drops no request: GET /readyz fails at
+// once, new requests are still served for DrainGrace, while the balancer
+// notices, then refused, and POST /admin/drain answers once those already
+// in flight are done. There is no undrain: the process is on its way out.
+//
+// Probes and metrics are always served, and event streams aren't waited
+// for, as they stay open for as long as their clients listen; shutting
+// down ends them.
+type drainer struct {
+	grace time.Duration
+
+	mu       sync.Mutex
+	since    time.Time     // when the drain began; zero while serving
+	inFlight int           // requests admitted and not yet done
+	idle     chan struct{} // closed once inFlight drops to 0 after the grace, for those waiting
+}
+
+// start begins the drain, unless it already has, and returns when it began.
+func (d *drainer) start() time.Time {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.since.IsZero() { d.since = time.Now() }
+	return d.since
+}
+
+func (d *drainer) draining() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return !d.since.IsZero()
+}
+
+// enter admits a request, unless the grace of a drain is over.
+func (d *drainer) enter() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !d.since.IsZero() && time.Since(d.since) >= d.grace { return false }
+	d.inFlight++
+	return true
+}
+
+func (d *drainer) leave() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.inFlight--
+	if d.inFlight == 0 && d.idle != nil { close(d.idle); d.idle = nil }
+}
+
+// wait returns once the grace is over and the requests admitted meanwhile
+// are done, or ctx ends first; either way with how many are still in
+// flight. Past the grace none is admitted, so 0 stays 0.
+func (d *drainer) wait(ctx context.Context) int {
+	select {
+	case <-time.After(time.Until(d.start().Add(d.grace))):
+	case <-ctx.Done():
+	}
+	for {
+		d.mu.Lock()
+		if d.inFlight == 0 || ctx.Err() != nil {
+			n := d.inFlight
+			d.mu.Unlock()
+			return n
+		}
+		if d.idle == nil { d.idle = make(chan struct{}) }
+		idle := d.idle
+		d.mu.Unlock()
+		select {
+		case <-idle:
+		case <-ctx.Done():
+		}
+	}
+}
+
+// drainMiddleware fails GET /readyz while draining, and counts the
+// requests in flight so that a drain can wait them out, or refuses them
+// once its grace is over. Connection: close sends the client's next
+// request through the load balancer again, to a server still serving.
+func (s *Server) drainMiddleware(next http.Handler) http.Handler {
+	d := s.drain
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := strings.TrimPrefix(r.URL.Path, apiV1)
+		switch {
+		case r.URL.Path == "/readyz" && d.draining():
+			handlers.WriteJSON(w, http.StatusServiceUnavailable, map[string]string{"status": "draining"})
+		case rateLimitExempt[r.URL.Path] || concurrencyExempt[path] || path == "/admin/drain":
+			next.ServeHTTP(w, r)
+		case !d.enter():
+			w.Header().Set("Connection", "close")
+			w.Header().Set("Retry-After", "1")
+			handlers.WriteProblem(w, http.StatusServiceUnavailable, handlers.CodeDraining, "the server is shutting down; try again", nil)
+		default:
+			defer d.leave()
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+// POST /admin/drain -> 200 {"status": "drained"} once the server serves no
+// more requests and those it did are done. That takes DrainGrace, then at
+// most ShutdownGrace for the requests in flight; 503 if some still are,
+// with how many. Asking again while draining joins the same drain.
+func (s *Server) Drain(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), s.cfg.DrainGrace+s.cfg.ShutdownGrace)
+	defer cancel()
+	slog.Info("draining", "grace", s.cfg.DrainGrace.String())
+	if n := s.drain.wait(ctx); n > 0 {
+		handlers.WriteProblem(w, http.StatusServiceUnavailable, handlers.CodeTimeout, strconv.Itoa(n)+" requests are still in flight", map[string]any{"in_flight": n})
+		return
+	}
+	handlers.WriteJSON(w, http.StatusOK, map[string]string{"status": "drained"})
+}
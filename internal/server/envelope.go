@@ -0,0 +1,110 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"app/internal/handlers"
+)
+
+// envelope is the shape of every JSON body of the API when enveloped. Data
+// is the body as it would be otherwise, or just its items for a list, whose
+// other members (total, next...) go in Meta. Links has self, the request,
+// and the next and prev of the Link header handlers set on lists.
+type envelope struct {
+	Data  json.RawMessage            `json:"data"`
+	Meta  map[string]json.RawMessage `json:"meta,omitempty"`
+	Links map[string]string          `json:"links"`
+}
+
+// unenveloped are the routes of v1 whose bodies have a shape of their own
+// that clients rely on: GraphQL's, and the OpenAPI document.
+var unenveloped = map[string]bool{"POST /graphql": true, "GET /openapi.json": true}
+
+// wantsEnvelope reports whether the response to r is enveloped: as the
+// envelope parameter of application/json in Accept says, such as
+// "application/json; envelope=true", and otherwise as configured.
+func wantsEnvelope(r *http.Request, byDefault bool) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		mt, params, err := mime.ParseMediaType(part)
+		if err != nil || mt != "application/json" { continue }
+		if v, err := strconv.ParseBool(params["envelope"]); err == nil { return v }
+	}
+	return byDefault
+}
+
+// enveloped wraps the successful JSON bodies of next in an envelope when
+// the client or the configuration asks for it. Problems stay RFC 7807
+// documents, and streams and downloads pass through as they are.
+func (s *Server) enveloped(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept")
+		if !wantsEnvelope(r, s.cfg.Envelope) || handlers.StreamFormat(r) != "" { next(w, r); return }
+		ew := &envelopeWriter{ResponseWriter: w}
+		next(ew, r)
+		ew.close(r)
+	}
+}
+
+// envelopeWriter holds back a body to be enveloped until the handler is
+// done; any other goes straight out.
+type envelopeWriter struct {
+	http.ResponseWriter
+	status  int
+	hold    bool // the body is held back in buf
+	decided bool // the status is known, and the header written unless held
+	buf     bytes.Buffer
+}
+
+func (e *envelopeWriter) WriteHeader(code int) {
+	if e.decided { return }
+	if code < 200 { e.ResponseWriter.WriteHeader(code); return }
+	e.status, e.decided = code, true
+	h := e.Header()
+	mt, _, _ := mime.ParseMediaType(h.Get("Content-Type"))
+	e.hold = code < 300 && code != http.StatusNoContent && mt == "application/json" && h.Get("Content-Disposition") == ""
+	if !e.hold { e.ResponseWriter.WriteHeader(code) }
+}
+
+func (e *envelopeWriter) Write(b []byte) (int, error) {
+	if !e.decided { e.WriteHeader(http.StatusOK) }
+	if e.hold { return e.buf.Write(b) }
+	return e.ResponseWriter.Write(b)
+}
+
+// FlushError only reaches the client for bodies that aren't held back.
+func (e *envelopeWriter) FlushError() error {
+	if e.hold { return nil }
+	return http.NewResponseController(e.ResponseWriter).Flush()
+}
+
+func (e *envelopeWriter) Flush() { _ = e.FlushError() }
+
+func (e *envelopeWriter) Unwrap() http.ResponseWriter { return e.ResponseWriter }
+
+// close writes the held-back body, enveloped. One that isn't JSON after
+// all goes out as it is.
+func (e *envelopeWriter) close(r *http.Request) {
+	if !e.hold { return }
+	body := e.buf.Bytes()
+	env := envelope{Data: bytes.TrimSpace(body), Links: map[string]string{"self": r.URL.RequestURI()}}
+	var list map[string]json.RawMessage
+	if json.Unmarshal(body, &list) == nil && list["items"] != nil {
+		env.Data = list["items"]
+		delete(list, "items")
+		if len(list) > 0 { env.Meta = list }
+	}
+	for _, link := range e.Header().Values("Link") {
+		target, params, found := strings.Cut(link, ";")
+		rel := strings.Trim(strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(params), "rel=")), `"`)
+		if found && (rel == "next" || rel == "prev") { env.Links[rel] = strings.Trim(strings.TrimSpace(target), "<>") }
+	}
+	if enc, err := json.Marshal(env); err == nil { body = append(enc, '\n') }
+	e.Header().Del("Content-Length")
+	e.ResponseWriter.WriteHeader(e.status)
+	_, _ = e.ResponseWriter.Write(body)
+}
@@ -0,0 +1,127 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"app/internal/handlers"
+	"app/internal/store"
+	"app/internal/tenant"
+)
+
+const idempotencyKeyHeader = "Idempotency-Key"
+
+// replayedHeaders are the response headers stored with the body; a replay
+// restores them.
+var replayedHeaders = []string{"Content-Type", "ETag", "Location"}
+
+// How long a duplicate waits for the original request to finish before giving up with a 409.
+const idempotencyWait = 5 * time.Second
+
+// idempotent makes a handler safe to retry: the first request carrying an
+// Idempotency-Key runs normally and its response is stored; repeats within
+// IdempotencyTTL get that response replayed instead of running again.
+//
+// Concurrent duplicates are serialised by the store: only one request can
+// claim the key, the others wait for it to complete. A key reused with a
+// different request body is rejected with 422.
+func (s *Server) idempotent(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(idempotencyKeyHeader)
+		if key == "" { next(w, r); return }
+		if len(key) > 255 { handlers.BadRequest(w, idempotencyKeyHeader+" must be at most 255 characters"); return }
+
+		body, err := io.ReadAll(r.Body)
+		var mbe *http.MaxBytesError
+		if errors.As(err, &mbe) { handlers.TooLarge(w, mbe.Limit); return }
+		if err != nil { handlers.BadRequest(w, "reading body: "+err.Error()); return }
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		sum := sha256.Sum256(append([]byte(r.Method+" "+r.URL.Path+"\n"), body...))
+		hash := hex.EncodeToString(sum[:])
+
+		// Keys are the client's to choose, so two tenants may well pick the
+		// same one; each gets its own.
+		key = tenant.FromContext(r.Context()) + "/" + key
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(r.Context()), idempotencyWait+5*time.Second)
+		defer cancel()
+		prev, err := s.claimIdempotencyKey(ctx, key, hash)
+		if err != nil { handlers.Internal(w, err); return }
+		if prev != nil {
+			switch {
+			case prev.RequestHash != hash:
+				handlers.Unprocessable(w, []handlers.FieldError{{Field: idempotencyKeyHeader, Message: "was already used with a different request"}})
+			case prev.Status == 0:
+				w.Header().Set("Retry-After", "1")
+				handlers.WriteProblem(w, http.StatusConflict, handlers.CodeIdempotencyKeyInUse, "a request with this "+idempotencyKeyHeader+" is still in progress", nil)
+			default:
+				if prev.ContentType != "" { w.Header().Set("Content-Type", prev.ContentType) }
+				for k, v := range prev.Headers { w.Header().Set(k, v) }
+				w.Header().Set("Idempotent-Replayed", "true")
+				w.WriteHeader(prev.Status)
+				_, _ = w.Write(prev.Body)
+			}
+			return
+		}
+
+		cw := &captureWriter{ResponseWriter: w, status: http.StatusOK}
+		next(cw, r)
+
+		// Server errors release the key so the client's retry gets a real
+		// second attempt; anything else is final and will be replayed.
+		if cw.status >= 500 {
+			err = s.idem.Release(ctx, key)
+		} else {
+			headers := map[string]string{}
+			for _, k := range replayedHeaders {
+				if v := cw.Header().Get(k); v != "" { headers[k] = v }
+			}
+			err = s.idem.Complete(ctx, key, cw.status, headers, cw.buf.Bytes())
+		}
+		if err != nil { slog.ErrorContext(ctx, "recording idempotent result", "key", key, "err", err) }
+	}
+}
+
+// claimIdempotencyKey tries to take ownership of key. It returns nil if the
+// caller now owns it, or the existing record otherwise. When that record is
+// still pending and belongs to the same request, it waits (bounded) for the
+// owner to finish so the duplicate can be answered with the real response.
+func (s *Server) claimIdempotencyKey(ctx context.Context, key, hash string) (*store.IdempotencyRecord, error) {
+	deadline := time.Now().Add(idempotencyWait)
+	for {
+		rec, err := s.idem.Claim(ctx, key, hash, s.cfg.IdempotencyTTL)
+		if err != nil || rec == nil { return nil, err }
+		if rec.Status != 0 || rec.RequestHash != hash || time.Now().After(deadline) { return rec, nil }
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+// captureWriter passes a response through while keeping a copy of it.
+type captureWriter struct {
+	http.ResponseWriter
+	status int
+	buf    bytes.Buffer
+}
+
+func (c *captureWriter) WriteHeader(code int) {
+	c.status = code
+	c.ResponseWriter.WriteHeader(code)
+}
+
+func (c *captureWriter) Write(b []byte) (int, error) {
+	c.buf.Write(b)
+	return c.ResponseWriter.Write(b)
+}
+
+func (c *captureWriter) Unwrap() http.ResponseWriter { return c.ResponseWriter }
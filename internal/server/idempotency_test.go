@@ -0,0 +1,72 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"app/internal/store"
+)
+
+type memIdempotency struct {
+	mu   sync.Mutex
+	recs map[string]store.IdempotencyRecord
+}
+
+func (m *memIdempotency) Claim(ctx context.Context, key, hash string, ttl time.Duration) (*store.IdempotencyRecord, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if rec, ok := m.recs[key]; ok { return &rec, nil }
+	m.recs[key] = store.IdempotencyRecord{Key: key, RequestHash: hash, ExpiresAt: time.Now().Add(ttl)}
+	return nil, nil
+}
+
+func (m *memIdempotency) Complete(ctx context.Context, key string, status int, headers map[string]string, body []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	rec := m.recs[key]
+	rec.Status, rec.Headers, rec.Body = status, headers, body
+	m.recs[key] = rec
+	return nil
+}
+
+func (m *memIdempotency) Release(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.recs, key)
+	return nil
+}
+
+func TestIdempotentReplay(t *testing.T) {
+	s := &Server{cfg: Config{IdempotencyTTL: time.Hour}, idem: &memIdempotency{recs: map[string]store.IdempotencyRecord{}}}
+	calls := 0
+	h := s.idempotent(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("ETag", `"1"`)
+		w.Header().Set("X-Not-Replayed", "x")
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"name":"Alice"}`))
+	})
+	post := func(body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/names", strings.NewReader(body))
+		r.Header.Set(idempotencyKeyHeader, "k1")
+		rec := httptest.NewRecorder()
+		h(rec, r)
+		return rec
+	}
+
+	post(`{"name":"Alice"}`)
+	rec := post(`{"name":"Alice"}`)
+	if calls != 1 { t.Fatalf("handler ran %d times", calls) }
+	if rec.Code != http.StatusCreated || rec.Body.String() != `{"name":"Alice"}` { t.Fatalf("replay: %d %s", rec.Code, rec.Body) }
+	for k, want := range map[string]string{"Idempotent-Replayed": "true", "ETag": `"1"`, "Content-Type": "application/json", "X-Not-Replayed": ""} {
+		if got := rec.Header().Get(k); got != want { t.Errorf("replayed %s = %q, want %q", k, got, want) }
+	}
+
+	if rec := post(`{"name":"Bob"}`); rec.Code != http.StatusUnprocessableEntity { t.Fatalf("reused key: status %d", rec.Code) }
+}
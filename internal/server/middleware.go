@@ -0,0 +1,211 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"app/internal/auth"
+	"app/internal/handlers"
+	"app/internal/store"
+	"app/internal/tenant"
+)
+
+// Paths, relative to apiV1, that stream or enforce their own, longer deadline.
+var timeoutExempt = map[string]bool{
+	"/names/stream":  true,
+	"/names/changes": true, // bounds its own wait
+	"/names/export":  true,
+	"/names/import":  true,
+	"/admin/drain":   true, // waits out the requests in flight
+	"/admin/backup":  true, // without job workers, dumps in the request
+	"/admin/restore": true,
+}
+
+// timeoutMiddleware gives each request a deadline of d, derived from its
+// context so that it also ends when the client disconnects. The handlers pass
+// that context to the store and turn its expiry into a 503. d <= 0 disables
+// the deadline, as do the timeoutExempt paths and streamed listings, which
+// are also spared the server's read and write timeouts.
+func timeoutMiddleware(d time.Duration, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := strings.TrimPrefix(r.URL.Path, apiV1)
+		if timeoutExempt[path] || path == "/names" && r.Method == http.MethodGet && handlers.StreamFormat(r) != "" { liftDeadlines(w); next.ServeHTTP(w, r); return }
+		if d <= 0 { next.ServeHTTP(w, r); return }
+		ctx, cancel := context.WithTimeout(r.Context(), d)
+		defer cancel()
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// Paths, relative to apiV1, that enforce their own, larger body limit.
+var bodyLimitExempt = map[string]bool{
+	"/names/import": true,
+}
+
+// bodyLimitMiddleware caps request bodies at limit bytes. Reads past it fail
+// with *http.MaxBytesError, which the JSON decoding turns into a 413.
+func bodyLimitMiddleware(limit int64, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !bodyLimitExempt[strings.TrimPrefix(r.URL.Path, apiV1)] { r.Body = http.MaxBytesReader(w, r.Body, limit) }
+		next.ServeHTTP(w, r)
+	})
+}
+
+const apiKeyHeader = "X-API-Key"
+
+// requireAuth admits requests with a bearer token (see requireUser) or an
+// X-API-Key, whose role and scopes must grant scope, if not "". It stores
+// the caller's user ID, tenant, role and scopes, and the key, in the request
+// context, and holds keys to their quotas (see trackUsage). It is a no-op
+// when no JWT secret is configured.
+func (s *Server) requireAuth(scope string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		raw := r.Header.Get(apiKeyHeader)
+		if !s.tokens.Enabled() || raw == "" {
+			s.requireUser(func(w http.ResponseWriter, r *http.Request) {
+				if scope != "" && !auth.HasScope(r.Context(), scope) {
+					role, _ := auth.RoleFromContext(r.Context())
+					handlers.Forbidden(w, "the "+role+" role lacks the "+scope+" scope"); return
+				}
+				next(w, r)
+			})(w, r)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+		hash := auth.HashAPIKey(raw)
+		k, err := s.keys.APIKeyByHash(ctx, hash)
+		cancel()
+		if errors.Is(err, store.ErrNotFound) { handlers.Unauthorized(w, "invalid API key"); return }
+		if err != nil { handlers.Internal(w, err); return }
+		noteValidKey(r.Context(), hash)
+		// A key can do no more than its role, which is lowered with its owner's.
+		scopes := auth.GrantedScopes(k.Role, k.Scopes)
+		noteCaller(r.Context(), k.Tenant)
+		if scope != "" && !slices.Contains(scopes, scope) { handlers.Forbidden(w, "API key lacks the "+scope+" scope"); return }
+
+		ctx = auth.WithRole(auth.WithUserID(tenant.NewContext(r.Context(), k.Tenant), k.UserID), k.Role)
+		s.trackUsage(k, w, r.WithContext(auth.WithAPIKeyID(auth.WithScopes(ctx, scopes), k.ID)), next)
+	}
+}
+
+// requireUser rejects requests without a valid "Authorization: Bearer <jwt>"
+// and stores the caller's user ID, tenant, role and the scopes it grants in
+// the request context. It is a no-op when no JWT secret is configured.
+func (s *Server) requireUser(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !s.tokens.Enabled() { next(w, r); return }
+
+		raw, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !found { handlers.Unauthorized(w, "missing bearer token"); return }
+		uid, tid, role, err := s.tokens.Verify(strings.TrimSpace(raw))
+		if err != nil { handlers.Unauthorized(w, "invalid token"); return }
+		noteCaller(r.Context(), tid)
+
+		ctx := auth.WithRole(auth.WithUserID(tenant.NewContext(r.Context(), tid), uid), role)
+		next(w, r.WithContext(auth.WithScopes(ctx, auth.RoleScopes(role))))
+	}
+}
+
+// requireRole admits bearer tokens of role or a higher one (see requireUser).
+func (s *Server) requireRole(role string, next http.HandlerFunc) http.HandlerFunc {
+	return s.requireUser(func(w http.ResponseWriter, r *http.Request) {
+		if got, ok := auth.RoleFromContext(r.Context()); ok && !auth.RoleWithin(role, got) {
+			handlers.Forbidden(w, "requires the "+role+" role"); return
+		}
+		next(w, r)
+	})
+}
+
+// legacyDeprecated is when the unversioned paths were deprecated, by the
+// introduction of /api/v1.
+var legacyDeprecated = time.Date(2026, time.October, 15, 0, 0, 0, 0, time.UTC)
+
+// deprecated marks the responses of a legacy alias as such (RFC 9745 and
+// RFC 8594), pointing to the same path under prefix as its successor, and
+// logs the call so that the clients still making it can be chased.
+func (s *Server) deprecated(prefix string, next http.HandlerFunc) http.HandlerFunc {
+	deprecation := "@" + strconv.FormatInt(legacyDeprecated.Unix(), 10)
+	return func(w http.ResponseWriter, r *http.Request) {
+		h := w.Header()
+		h.Set("Deprecation", deprecation)
+		if !s.cfg.LegacySunset.IsZero() { h.Set("Sunset", s.cfg.LegacySunset.UTC().Format(http.TimeFormat)) }
+		h.Add("Link", "<"+prefix+r.URL.Path+`>; rel="successor-version"`)
+		slog.DebugContext(r.Context(), "deprecated path", "path", r.URL.Path, "user_agent", r.UserAgent())
+		next(w, r)
+	}
+}
+
+// loggingMiddleware emits one structured line per request once it completes.
+func loggingMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		sw := &statusWriter{ResponseWriter: w}
+		next.ServeHTTP(sw, r)
+		if sw.status == 0 { sw.status = http.StatusOK }
+
+		level := slog.LevelInfo
+		if sw.status >= 500 { level = slog.LevelError }
+		slog.LogAttrs(r.Context(), level, "request",
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.Int("status", sw.status),
+			slog.Float64("latency_ms", float64(time.Since(start).Microseconds())/1000),
+			slog.Int64("bytes", sw.bytes),
+			slog.String("remote_addr", r.RemoteAddr),
+		)
+	})
+}
+
+// statusWriter records the status code and body size of a response.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (s *statusWriter) WriteHeader(code int) {
+	if s.status == 0 { s.status = code }
+	s.ResponseWriter.WriteHeader(code)
+}
+
+func (s *statusWriter) Write(b []byte) (int, error) {
+	if s.status == 0 { s.status = http.StatusOK }
+	n, err := s.ResponseWriter.Write(b)
+	s.bytes += int64(n)
+	return n, err
+}
+
+func (s *statusWriter) Unwrap() http.ResponseWriter { return s.ResponseWriter }
+
+// jsonMuxErrors replaces the mux's plain-text 404/405 replies with our JSON
+// ones. The mux has already computed the Allow header for a 405, so we run its
+// fallback handler against a throwaway writer and reuse that.
+func jsonMuxErrors(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h, pattern := mux.Handler(r)
+		if pattern != "" { mux.ServeHTTP(w, r); return }
+
+		c := &discardWriter{header: http.Header{}}
+		h.ServeHTTP(c, r)
+		if c.code == http.StatusMethodNotAllowed {
+			handlers.MethodNotAllowed(w, strings.Split(c.header.Get("Allow"), ", ")...); return
+		}
+		handlers.NotFound(w)
+	})
+}
+
+type discardWriter struct {
+	header http.Header
+	code   int
+}
+
+func (d *discardWriter) Header() http.Header         { return d.header }
+func (d *discardWriter) Write(b []byte) (int, error) { return len(b), nil }
+func (d *discardWriter) WriteHeader(code int)        { d.code = code }
@@ -0,0 +1,363 @@
+package server
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+
+	"app/internal/auth"
+	"app/internal/chaos"
+	"app/internal/handlers"
+	"app/internal/store"
+)
+
+func TestMuxErrors(t *testing.T) {
+	tokens := auth.NewTokens(nil, time.Hour) // disabled: no credentials needed
+	s := &Server{tokens: tokens, keys: store.NewMemoryAPIKeys(), h: handlers.New(handlers.Deps{
+		Names: store.NewMemoryNames(), Users: store.NewMemoryUsers(), APIKeys: store.NewMemoryAPIKeys(), Tokens: tokens,
+	})}
+	h := jsonMuxErrors(s.routes())
+
+	for _, tc := range []struct {
+		method, path string
+		status       int
+		code, allow  string
+	}{
+		{http.MethodGet, "/nope", http.StatusNotFound, handlers.CodeNotFound, ""},
+		{http.MethodGet, "/names/665f1c2e9b1e8a3d4c5b6a79/nope", http.StatusNotFound, handlers.CodeNotFound, ""},
+		{http.MethodPost, "/names/665f1c2e9b1e8a3d4c5b6a79", http.StatusMethodNotAllowed, handlers.CodeMethodNotAllowed, "DELETE, GET, HEAD, PATCH, PUT"},
+		{http.MethodDelete, "/healthz", http.StatusMethodNotAllowed, handlers.CodeMethodNotAllowed, "GET, HEAD"},
+		{http.MethodGet, "/names/not-an-id", http.StatusBadRequest, handlers.CodeBadRequest, ""},
+	} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(tc.method, tc.path, nil))
+		var body struct{ Code string }
+		_ = json.Unmarshal(rec.Body.Bytes(), &body)
+		if rec.Code != tc.status || body.Code != tc.code || rec.Header().Get("Allow") != tc.allow {
+			t.Errorf("%s %s: %d %q, Allow %q; want %d %q, Allow %q", tc.method, tc.path, rec.Code, body.Code, rec.Header().Get("Allow"), tc.status, tc.code, tc.allow)
+		}
+		if ct := rec.Header().Get("Content-Type"); ct != "application/problem+json" { t.Errorf("%s %s: Content-Type %q", tc.method, tc.path, ct) }
+	}
+}
+
+func TestCORS(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
+	h := corsMiddleware(CORSConfig{
+		AllowedOrigins: []string{"https://app.example.com"}, AllowedMethods: []string{"GET", "PUT"},
+		AllowedHeaders: []string{"Content-Type", "If-Match"}, AllowCredentials: true, MaxAge: time.Minute,
+	}, ok)
+
+	for _, tc := range []struct {
+		name, method, origin, reqMethod, reqHeaders string
+		status                                      int
+		allowOrigin, maxAge                         string
+	}{
+		{"no origin", http.MethodGet, "", "", "", http.StatusOK, "", ""},
+		{"allowed", http.MethodGet, "https://app.example.com", "", "", http.StatusOK, "https://app.example.com", ""},
+		{"other origin", http.MethodGet, "https://evil.example", "", "", http.StatusOK, "", ""},
+		{"preflight", http.MethodOptions, "https://app.example.com", "PUT", "content-type, if-match", http.StatusNoContent, "https://app.example.com", "60"},
+		{"preflight, other origin", http.MethodOptions, "https://evil.example", "PUT", "", http.StatusForbidden, "", ""},
+		{"preflight, method", http.MethodOptions, "https://app.example.com", "DELETE", "", http.StatusForbidden, "", ""},
+		{"preflight, header", http.MethodOptions, "https://app.example.com", "PUT", "X-Secret", http.StatusForbidden, "", ""},
+	} {
+		r := httptest.NewRequest(tc.method, "/names", nil)
+		if tc.origin != "" { r.Header.Set("Origin", tc.origin) }
+		if tc.reqMethod != "" { r.Header.Set("Access-Control-Request-Method", tc.reqMethod) }
+		if tc.reqHeaders != "" { r.Header.Set("Access-Control-Request-Headers", tc.reqHeaders) }
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, r)
+		got := rec.Header()
+		if rec.Code != tc.status || got.Get("Access-Control-Allow-Origin") != tc.allowOrigin || got.Get("Access-Control-Max-Age") != tc.maxAge {
+			t.Errorf("%s: %d, origin %q, max age %q; want %d, %q, %q", tc.name, rec.Code, got.Get("Access-Control-Allow-Origin"), got.Get("Access-Control-Max-Age"), tc.status, tc.allowOrigin, tc.maxAge)
+		}
+		if got.Get("Vary") != "Origin" { t.Errorf("%s: Vary %q", tc.name, got.Values("Vary")) }
+		if tc.allowOrigin != "" && got.Get("Access-Control-Allow-Credentials") != "true" { t.Errorf("%s: credentials not allowed", tc.name) }
+	}
+
+	// With any origin and no credentials, the wildcard is sent and nothing varies.
+	rec := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/names", nil)
+	r.Header.Set("Origin", "https://anywhere.example")
+	corsMiddleware(CORSConfig{AllowedOrigins: []string{"*"}}, ok).ServeHTTP(rec, r)
+	if rec.Header().Get("Access-Control-Allow-Origin") != "*" || rec.Header().Get("Vary") != "" { t.Errorf("wildcard: %v", rec.Header()) }
+}
+
+// TestRateLimitAPIKeys gives a valid key a bucket of its own, and counts
+// made-up ones against their IP's.
+func TestRateLimitAPIKeys(t *testing.T) {
+	h := rateLimitMiddleware(RateLimitConfig{RPS: 0.001, Burst: 1, MaxClients: 10, APIKeyHeader: apiKeyHeader}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if raw := r.Header.Get(apiKeyHeader); raw == "good" { noteValidKey(r.Context(), auth.HashAPIKey(raw)) }
+	}))
+	for i, tc := range []struct {
+		key    string
+		status int
+	}{
+		{"good", http.StatusOK},                 // the IP's one token, and the key is now known
+		{"good", http.StatusOK},                 // the key's own token
+		{"made-up", http.StatusTooManyRequests}, // back on the IP's bucket
+		{"made-up-too", http.StatusTooManyRequests},
+		{"good", http.StatusTooManyRequests},
+	} {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/names", nil)
+		req.Header.Set(apiKeyHeader, tc.key)
+		h.ServeHTTP(rec, req)
+		if rec.Code != tc.status { t.Fatalf("request %d with %q: %d, want %d", i, tc.key, rec.Code, tc.status) }
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	h := timeoutMiddleware(10*time.Millisecond, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.Context().Deadline(); !ok { w.WriteHeader(http.StatusOK); return }
+		<-r.Context().Done()
+		handlers.Internal(w, r.Context().Err())
+	}))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/names", nil))
+	if rec.Code != http.StatusServiceUnavailable { t.Fatalf("slow request: %d %s", rec.Code, rec.Body) }
+
+	for _, path := range []string{"/names/stream", "/api/v1/names/stream"} {
+		rec = httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != http.StatusOK { t.Fatalf("%s got a deadline: %d", path, rec.Code) }
+	}
+}
+
+func TestSecurityHeaders(t *testing.T) {
+	h := securityHeaders(HTTPConfig{HSTSMaxAge: 24 * time.Hour}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/ui/" { w.Header().Set("Content-Security-Policy", "default-src 'self'") }
+	}))
+	for _, tc := range []struct {
+		path, proto, csp, hsts string
+	}{
+		{"/api/v1/names", "", apiCSP, ""},
+		{"/api/v1/names", "https", apiCSP, "max-age=86400"},
+		{"/ui/", "https", "default-src 'self'", "max-age=86400"},
+	} {
+		rec := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, tc.path, nil)
+		if tc.proto != "" { r.Header.Set("X-Forwarded-Proto", tc.proto) }
+		h.ServeHTTP(rec, r)
+		got := rec.Header()
+		if got.Get("X-Content-Type-Options") != "nosniff" || got.Get("X-Frame-Options") != "DENY" || got.Get("Content-Security-Policy") != tc.csp || got.Get("Strict-Transport-Security") != tc.hsts {
+			t.Errorf("%s over %q: %v", tc.path, tc.proto, got)
+		}
+	}
+}
+
+func TestChaos(t *testing.T) {
+	var seen chaos.Faults
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { seen = chaos.FromContext(r.Context()) })
+	for _, tc := range []struct {
+		cfg          ChaosConfig
+		path, header string
+		status       int
+		faults       chaos.Faults
+	}{
+		{ChaosConfig{Faults: chaos.Faults{ErrorRate: 1}}, "/api/v1/names", "", http.StatusInternalServerError, chaos.Faults{}},
+		{ChaosConfig{Faults: chaos.Faults{ErrorRate: 1}}, "/healthz", "", http.StatusOK, chaos.Faults{}},
+		{ChaosConfig{Faults: chaos.Faults{ErrorRate: 1}}, "/api/v1/names", "error_rate=0", http.StatusInternalServerError, chaos.Faults{}}, // headers not allowed
+		{ChaosConfig{Header: true}, "/api/v1/names", "error_rate=1", http.StatusInternalServerError, chaos.Faults{}},
+		{ChaosConfig{Header: true}, "/api/v1/names", "timeout_rate=0.5", http.StatusOK, chaos.Faults{TimeoutRate: 0.5}},
+		{ChaosConfig{Header: true}, "/api/v1/names", "latency=20ms", http.StatusOK, chaos.Faults{Latency: 20 * time.Millisecond, LatencyRate: 1}},
+		{ChaosConfig{Header: true}, "/api/v1/names", "error_rate=often", http.StatusBadRequest, chaos.Faults{}},
+	} {
+		seen = chaos.Faults{}
+		rec := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, tc.path, nil)
+		if tc.header != "" { r.Header.Set(chaos.Header, tc.header) }
+		start := time.Now()
+		chaosMiddleware(tc.cfg, next).ServeHTTP(rec, r)
+		if rec.Code != tc.status || seen != tc.faults { t.Errorf("%s with %q: %d, %+v; want %d, %+v", tc.path, tc.header, rec.Code, seen, tc.status, tc.faults) }
+		if took := time.Since(start); took < tc.faults.Latency { t.Errorf("%s with %q took %s", tc.path, tc.header, took) }
+	}
+}
+
+// TestServerTimeouts runs a real server, as its WriteTimeout only bites on a
+// connection: it cuts off slow responses, but not streams.
+func TestServerTimeouts(t *testing.T) {
+	h := compressMiddleware(CompressionConfig{MinBytes: 1, Level: 1}, timeoutMiddleware(0, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+		_, _ = io.WriteString(w, "done")
+	})))
+	ts := httptest.NewUnstartedServer(h)
+	ts.Config = HTTPConfig{ReadHeaderTimeout: time.Second, WriteTimeout: 50 * time.Millisecond, MaxHeaderBytes: 4096}.httpServer("", h)
+	ts.Start()
+	defer ts.Close()
+
+	get := func(path string) (string, error) {
+		resp, err := http.Get(ts.URL + path)
+		if err != nil { return "", err }
+		defer resp.Body.Close()
+		b, err := io.ReadAll(resp.Body)
+		return string(b), err
+	}
+	if body, err := get("/api/v1/names/export"); err != nil || body != "done" { t.Fatalf("stream: %q, %v", body, err) }
+	if body, err := get("/api/v1/names"); err == nil && body == "done" { t.Fatal("slow response outlived the write timeout") }
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+"/api/v1/names", nil)
+	req.Header.Set("X-Big", strings.Repeat("x", 16<<10))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil { t.Fatal(err) }
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusRequestHeaderFieldsTooLarge { t.Fatalf("big headers: %d", resp.StatusCode) }
+}
+
+func TestDrain(t *testing.T) {
+	const grace = 50 * time.Millisecond
+	s := &Server{cfg: Config{DrainGrace: grace, ShutdownGrace: time.Second}, drain: &drainer{grace: grace}}
+	release, started := make(chan struct{}), make(chan struct{})
+	h := s.drainMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/slow" { return }
+		started <- struct{}{}
+		<-release
+	}))
+	call := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	go call("/slow")
+	<-started
+	if rec := call("/readyz"); rec.Code != http.StatusOK { t.Fatalf("ready before the drain: %d", rec.Code) }
+	s.drain.start()
+	drained := make(chan *httptest.ResponseRecorder)
+	go func() {
+		rec := httptest.NewRecorder()
+		s.Drain(rec, httptest.NewRequest(http.MethodPost, "/api/v1/admin/drain", nil))
+		drained <- rec
+	}()
+
+	// Within the grace, only the probe fails.
+	if rec := call("/readyz"); rec.Code != http.StatusServiceUnavailable { t.Fatalf("readyz while draining: %d", rec.Code) }
+	if rec := call("/names"); rec.Code != http.StatusOK { t.Fatalf("request within the grace: %d", rec.Code) }
+	time.Sleep(grace + 10*time.Millisecond)
+	rec := call("/api/v1/names")
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Connection") != "close" || !strings.Contains(rec.Body.String(), handlers.CodeDraining) { t.Fatalf("request after the grace: %d %v %s", rec.Code, rec.Header(), rec.Body) }
+	if rec := call("/healthz"); rec.Code != http.StatusOK { t.Fatalf("healthz while draining: %d", rec.Code) }
+
+	// The drain answers once the request in flight is done.
+	select {
+	case rec := <-drained:
+		t.Fatalf("drained with a request in flight: %d %s", rec.Code, rec.Body)
+	case <-time.After(20 * time.Millisecond):
+	}
+	close(release)
+	if rec := <-drained; rec.Code != http.StatusOK { t.Fatalf("drain: %d %s", rec.Code, rec.Body) }
+}
+
+func TestConcurrency(t *testing.T) {
+	release, started := make(chan struct{}), make(chan struct{})
+	h := concurrencyMiddleware(ConcurrencyConfig{MaxInFlight: 3, RouteMaxInFlight: 2, Routes: map[string]int{"GET /export": 1}, QueueTimeout: 100 * time.Millisecond},
+		func(r *http.Request) string { return r.Method + " " + r.URL.Path },
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/healthz" { return }
+			started <- struct{}{}
+			<-release
+		}))
+	call := func(path string) int {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code == http.StatusServiceUnavailable && rec.Header().Get("Retry-After") != "1" { t.Errorf("%s: Retry-After %q", path, rec.Header().Get("Retry-After")) }
+		return rec.Code
+	}
+
+	// Fill /export's one slot and /names's two, which are all the server has.
+	var wg sync.WaitGroup
+	for _, path := range []string{"/export", "/names", "/names"} {
+		wg.Add(1)
+		go func() { defer wg.Done(); call(path) }()
+		<-started
+	}
+	for _, path := range []string{"/export", "/names", "/trash"} { // /trash has room, the server hasn't
+		if code := call(path); code != http.StatusServiceUnavailable { t.Errorf("%s over the cap: %d", path, code) }
+	}
+	if code := call("/healthz"); code != http.StatusOK { t.Errorf("probe: %d", code) }
+
+	// A request in line gets the first slot to come free.
+	queued := make(chan int)
+	go func() { queued <- call("/trash") }()
+	time.Sleep(20 * time.Millisecond)
+	release <- struct{}{}
+	<-started
+	close(release)
+	if code := <-queued; code != http.StatusOK { t.Errorf("queued request: %d", code) }
+	wg.Wait()
+}
+
+func TestNegotiateEncoding(t *testing.T) {
+	offered := []string{"zstd", "gzip", "deflate"}
+	for accept, want := range map[string]string{
+		"":                          "",
+		"identity":                  "",
+		"gzip":                      "gzip",
+		"gzip, deflate, br, zstd":   "zstd",
+		"GZIP;q=0.5, deflate;q=0.8": "deflate",
+		"zstd;q=0, gzip":            "gzip",
+		"*":                         "zstd",
+		"*;q=0.1, gzip;q=0":         "zstd",
+		"gzip;q=nope":               "",
+	} {
+		if got := negotiateEncoding(accept, offered); got != want { t.Errorf("%q: %q, want %q", accept, got, want) }
+	}
+}
+
+func TestCompression(t *testing.T) {
+	big := strings.Repeat(`{"name":"Alice"},`, 100)
+	h := compressMiddleware(CompressionConfig{MinBytes: 256, Level: 6, Zstd: true}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := big
+		switch r.URL.Path {
+		case "/small":
+			body = `{"name":"Alice"}`
+		case "/encoded":
+			w.Header().Set("Content-Encoding", "br")
+		case "/stream":
+			w.Header().Set("Content-Type", "text/event-stream")
+		case "/flushed":
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			io.WriteString(w, big)
+			http.NewResponseController(w).Flush()
+			io.WriteString(w, big)
+			return
+		}
+		if w.Header().Get("Content-Type") == "" { w.Header().Set("Content-Type", "application/json") }
+		w.WriteHeader(http.StatusCreated)
+		io.WriteString(w, body)
+	}))
+	call := func(path, accept string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		r.Header.Set("Accept-Encoding", accept)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, r)
+		return rec
+	}
+
+	rec := call("/big", "gzip")
+	if rec.Code != http.StatusCreated || rec.Header().Get("Content-Encoding") != "gzip" || rec.Header().Get("Vary") != "Accept-Encoding" { t.Fatalf("gzip: %d %v", rec.Code, rec.Header()) }
+	zr, err := gzip.NewReader(rec.Body)
+	if err != nil { t.Fatal(err) }
+	if b, _ := io.ReadAll(zr); string(b) != big { t.Fatalf("gunzipped %q", b) }
+
+	rec = call("/flushed", "zstd, gzip")
+	if rec.Header().Get("Content-Encoding") != "zstd" || !rec.Flushed { t.Fatalf("zstd: %v", rec.Header()) }
+	zd, err := zstd.NewReader(rec.Body)
+	if err != nil { t.Fatal(err) }
+	if b, _ := io.ReadAll(zd); string(b) != big+big { t.Fatalf("unzstded %d bytes", len(b)) }
+	zd.Close()
+
+	for path, accept := range map[string]string{"/big": "", "/small": "gzip", "/encoded": "gzip", "/stream": "gzip"} {
+		rec := call(path, accept)
+		if enc := rec.Header().Get("Content-Encoding"); rec.Code != http.StatusCreated || (enc != "" && path != "/encoded") || rec.Body.Len() == 0 {
+			t.Errorf("%s, Accept-Encoding %q: %d, Content-Encoding %q", path, accept, rec.Code, enc)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package server
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"app/api"
+	"app/internal/handlers"
+)
+
+// The spec is hand-written, so make sure it can't silently fall behind the router.
+func TestOpenAPICoversRoutes(t *testing.T) {
+	var spec struct {
+		Paths map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	if err := json.Unmarshal(api.OpenAPI, &spec); err != nil { t.Fatalf("openapi.json: %v", err) }
+
+	s := &Server{h: &handlers.Handlers{}}
+	served := map[string]bool{}
+	for _, rt := range s.routeTable() {
+		served[rt.pattern] = true
+		method, path, _ := strings.Cut(rt.pattern, " ")
+		if _, ok := spec.Paths[path][strings.ToLower(method)]; !ok {
+			t.Errorf("route %q is missing from api/openapi.json", rt.pattern)
+		}
+	}
+	for path, ops := range spec.Paths {
+		for method := range ops {
+			if method == "parameters" { continue }
+			if pattern := strings.ToUpper(method) + " " + path; !served[pattern] {
+				t.Errorf("api/openapi.json documents %q, which the router doesn't serve", pattern)
+			}
+		}
+	}
+}
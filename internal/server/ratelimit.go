@@ -0,0 +1,164 @@
+package server
+
+import (
+	"container/list"
+	"context"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/time/rate"
+
+	"app/internal/auth"
+	"app/internal/handlers"
+)
+
+// RateLimitConfig sets the per-client token bucket. RPS <= 0 disables
+// rate limiting.
+type RateLimitConfig struct {
+	RPS        float64
+	Burst      int
+	MaxClients int  // clients tracked at once; the least recently seen is evicted
+	TrustProxy bool // key on X-Forwarded-For instead of the peer address
+	// APIKeyHeader, if set, buckets requests carrying a valid API key in
+	// that header by the key instead of by IP, so clients sharing an address
+	// don't share a limit (see bucket).
+	APIKeyHeader string
+}
+
+// Paths that are never rate limited (load balancer / k8s probes, Prometheus).
+var rateLimitExempt = map[string]bool{
+	"/health":  true,
+	"/healthz": true,
+	"/readyz":  true,
+	"/metrics": true,
+}
+
+// clientLimiter hands out one token bucket per client (IP or API key). The
+// set of tracked clients is an LRU bounded by max so a flood of distinct
+// clients can't grow memory without limit; the least recently seen is
+// evicted first.
+type clientLimiter struct {
+	mu      sync.Mutex
+	rps     rate.Limit
+	burst   int
+	max     int
+	order   *list.List               // front = most recently seen
+	entries map[string]*list.Element // key -> element holding *clientEntry
+}
+
+type clientEntry struct {
+	key string
+	lim *rate.Limiter
+}
+
+func newClientLimiter(rps float64, burst, max int) *clientLimiter {
+	return &clientLimiter{
+		rps:     rate.Limit(rps),
+		burst:   burst,
+		max:     max,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+func (l *clientLimiter) get(key string) *rate.Limiter {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if el, found := l.entries[key]; found {
+		l.order.MoveToFront(el)
+		return el.Value.(*clientEntry).lim
+	}
+	return l.add(key)
+}
+
+// lookup is get for clients already tracked, which it doesn't add to.
+func (l *clientLimiter) lookup(key string) (*rate.Limiter, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	el, found := l.entries[key]
+	if !found { return nil, false }
+	l.order.MoveToFront(el)
+	return el.Value.(*clientEntry).lim, true
+}
+
+// add tracks a new client, evicting as needed. l.mu must be held.
+func (l *clientLimiter) add(key string) *rate.Limiter {
+	for l.order.Len() >= l.max {
+		oldest := l.order.Back()
+		l.order.Remove(oldest)
+		delete(l.entries, oldest.Value.(*clientEntry).key)
+	}
+	e := &clientEntry{key: key, lim: rate.NewLimiter(l.rps, l.burst)}
+	l.entries[key] = l.order.PushFront(e)
+	return e.lim
+}
+
+// rateLimitMiddleware rejects requests with 429 once a client exceeds its
+// token bucket.
+func rateLimitMiddleware(cfg RateLimitConfig, next http.Handler) http.Handler {
+	if cfg.RPS <= 0 { return next }
+	limiter := newClientLimiter(cfg.RPS, cfg.Burst, cfg.MaxClients)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if rateLimitExempt[r.URL.Path] { next.ServeHTTP(w, r); return }
+
+		lim, admit := limiter.bucket(r, cfg)
+		res := lim.Reserve()
+		if delay := res.Delay(); delay > 0 {
+			res.Cancel()
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(delay.Seconds()))))
+			handlers.WriteProblem(w, http.StatusTooManyRequests, handlers.CodeRateLimited, "rate limit exceeded", nil)
+			return
+		}
+		if admit != nil { r = r.WithContext(context.WithValue(r.Context(), validKeyKey{}, admit)) }
+		next.ServeHTTP(w, r)
+	})
+}
+
+// bucket picks the bucket for r: its API key's, if cfg.APIKeyHeader has one
+// that a request before it proved valid, and its IP's otherwise. Keys only
+// get buckets once requireAuth has looked them up, so made-up ones can't
+// mint fresh buckets, nor a lookup each past their IP's limit; and they are
+// known by their hash, so the secrets aren't kept. admit, if not nil, gives
+// the key its bucket once it proves valid (see noteValidKey). The prefixes
+// keep an API key from ever colliding with an IP.
+func (l *clientLimiter) bucket(r *http.Request, cfg RateLimitConfig) (lim *rate.Limiter, admit func(hash string)) {
+	ip := "ip:" + clientIP(r, cfg.TrustProxy)
+	raw := ""
+	if cfg.APIKeyHeader != "" { raw = r.Header.Get(cfg.APIKeyHeader) }
+	if raw == "" { return l.get(ip), nil }
+	hash := auth.HashAPIKey(raw)
+	if lim, found := l.lookup("key:" + hash); found { return lim, nil }
+	return l.get(ip), func(valid string) {
+		if valid == hash { l.get("key:" + hash) }
+	}
+}
+
+type validKeyKey struct{}
+
+// noteValidKey tells the rate limiter, if it buckets requests by API key,
+// that the request in ctx carries the key of this hash and that it's valid.
+func noteValidKey(ctx context.Context, hash string) {
+	if admit, found := ctx.Value(validKeyKey{}).(func(string)); found { admit(hash) }
+}
+
+// clientIP returns the caller's IP. When trustProxy is set we're behind our
+// own proxy, which appends the address it saw to X-Forwarded-For; the
+// rightmost entry is therefore the only one we can trust.
+func clientIP(r *http.Request, trustProxy bool) string {
+	if trustProxy {
+		if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
+			parts := strings.Split(xff, ",")
+			if ip := strings.TrimSpace(parts[len(parts)-1]); ip != "" { return ip }
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil { return r.RemoteAddr }
+	return host
+}
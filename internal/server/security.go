@@ -0,0 +1,64 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// HTTPConfig hardens the http.Servers: a timeout of 0 is none. The routes
+// that stream, or take big uploads, lift ReadTimeout and WriteTimeout for
+// themselves (see timeoutMiddleware).
+type HTTPConfig struct {
+	ReadHeaderTimeout time.Duration
+	ReadTimeout       time.Duration // to read the whole request, body included
+	WriteTimeout      time.Duration // from the end of the request's headers to the end of the response
+	IdleTimeout       time.Duration // between the requests of a kept-alive connection
+	MaxHeaderBytes    int
+	HSTSMaxAge        time.Duration // Strict-Transport-Security of HTTPS responses; 0 sends none
+}
+
+// httpServer is an http.Server for addr configured as cfg says.
+func (cfg HTTPConfig) httpServer(addr string, h http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           h,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		ReadTimeout:       cfg.ReadTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+		MaxHeaderBytes:    cfg.MaxHeaderBytes,
+	}
+}
+
+// apiCSP forbids a response from loading or framing anything: the API's
+// are data, not pages. The handlers of the pages (the docs and the UI) set
+// their own.
+const apiCSP = "default-src 'none'; frame-ancestors 'none'"
+
+// securityHeaders sets the headers that keep browsers from sniffing,
+// framing or downgrading responses. Strict-Transport-Security is only sent
+// over HTTPS, as the spec has browsers ignore it otherwise: served here, or
+// by a proxy in front that says so in X-Forwarded-Proto.
+func securityHeaders(cfg HTTPConfig, next http.Handler) http.Handler {
+	hsts := ""
+	if cfg.HSTSMaxAge > 0 { hsts = "max-age=" + strconv.FormatInt(int64(cfg.HSTSMaxAge/time.Second), 10) }
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h := w.Header()
+		h.Set("X-Content-Type-Options", "nosniff")
+		h.Set("X-Frame-Options", "DENY")
+		h.Set("Referrer-Policy", "no-referrer")
+		h.Set("Content-Security-Policy", apiCSP)
+		if hsts != "" && (r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https") { h.Set("Strict-Transport-Security", hsts) }
+		next.ServeHTTP(w, r)
+	})
+}
+
+// liftDeadlines clears the connection's read and write deadlines for a
+// request that streams or takes its time, which ReadTimeout and
+// WriteTimeout would cut off.
+func liftDeadlines(w http.ResponseWriter) {
+	rc := http.NewResponseController(w)
+	_ = rc.SetReadDeadline(time.Time{})
+	_ = rc.SetWriteDeadline(time.Time{})
+}
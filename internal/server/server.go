@@ -0,0 +1,318 @@
+// Package server wires the handlers into a router behind the shared
+// middleware and runs the HTTP server.
+package server
+
+import (
+	"cmp"
+	"context"
+	"crypto/tls"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/acme/autocert"
+
+	"app/internal/auth"
+	"app/internal/handlers"
+	"app/internal/metrics"
+	"app/internal/requestid"
+	"app/internal/store"
+	"app/internal/tracing"
+	"app/internal/usage"
+)
+
+type Config struct {
+	Addr           string
+	ShutdownGrace  time.Duration // how long in-flight requests get on shutdown, and after a drain
+	DrainGrace     time.Duration // how long a drain keeps serving new requests; see drain.go
+	IdempotencyTTL time.Duration
+	MaxBodyBytes   int64 // request bodies beyond this get a 413; CSV imports have their own cap
+	RequestTimeout time.Duration // deadline for each request, streams and imports excepted; <= 0 disables
+	HTTP           HTTPConfig    // the http.Server's timeouts and limits, and HSTS
+	LegacySunset   time.Time     // when the unversioned aliases of /api/v1 go away; zero if undecided
+	Envelope       bool          // wrap JSON bodies in {data, meta, links} unless the client's Accept says envelope=false
+	RateLimit      RateLimitConfig
+	TLS            TLSConfig
+	CORS           CORSConfig
+	Compression    CompressionConfig
+	Concurrency    ConcurrencyConfig
+	Capture        CaptureConfig
+	Chaos          ChaosConfig
+	Usage          *usage.Tracker // counts API keys' requests and holds them to quotas; nil doesn't
+}
+
+// TLSConfig makes Addr serve HTTPS, and HTTP/2 with it, from either a
+// certificate and key on disk or certificates Let's Encrypt issues for
+// AutocertDomains. With neither, Addr serves plain HTTP.
+type TLSConfig struct {
+	CertFile, KeyFile string
+	AutocertDomains   []string
+	AutocertCacheDir  string // where issued certificates are kept across restarts
+	AutocertEmail     string // optional contact for Let's Encrypt
+	// RedirectAddr, if set, serves plain HTTP that redirects to HTTPS. With
+	// autocert it also answers the HTTP-01 challenges, which come in on port
+	// 80, so it defaults to ":80" there.
+	RedirectAddr string
+}
+
+func (t TLSConfig) enabled() bool { return t.CertFile != "" || len(t.AutocertDomains) > 0 }
+
+type Server struct {
+	cfg    Config
+	h      *handlers.Handlers
+	tokens *auth.Tokens
+	idem   store.IdempotencyStore
+	keys   store.APIKeyStore
+	drain  *drainer
+	srv    *http.Server
+}
+
+func New(cfg Config, h *handlers.Handlers, tokens *auth.Tokens, idem store.IdempotencyStore, keys store.APIKeyStore) *Server {
+	s := &Server{cfg: cfg, h: h, tokens: tokens, idem: idem, keys: keys, drain: &drainer{grace: cfg.DrainGrace}}
+	s.srv = cfg.HTTP.httpServer(cfg.Addr, s.Handler())
+	return s
+}
+
+// Handler is the complete HTTP stack: middleware around the router.
+func (s *Server) Handler() http.Handler {
+	mux := s.routes()
+	// Metrics and spans are labelled with the matched pattern's path, not
+	// the raw URL; concurrency is capped per pattern.
+	pattern := func(r *http.Request) string {
+		_, p := mux.Handler(r)
+		return p
+	}
+	route := func(r *http.Request) string {
+		_, path, _ := strings.Cut(pattern(r), " ")
+		return path
+	}
+	s.checkRouteLimits()
+	return tracing.Middleware(route, requestid.Middleware(securityHeaders(s.cfg.HTTP, loggingMiddleware(metrics.Middleware(route, compressMiddleware(s.cfg.Compression,
+		s.codecMiddleware(s.drainMiddleware(s.captureMiddleware(corsMiddleware(s.cfg.CORS, rateLimitMiddleware(s.cfg.RateLimit, concurrencyMiddleware(s.cfg.Concurrency, pattern,
+			chaosMiddleware(s.cfg.Chaos, timeoutMiddleware(s.cfg.RequestTimeout, bodyLimitMiddleware(s.cfg.MaxBodyBytes, jsonMuxErrors(mux))))))))))))))))
+}
+
+// checkRouteLimits warns of the per-route concurrency caps naming no route,
+// which would otherwise go unnoticed.
+func (s *Server) checkRouteLimits() {
+	known := map[string]bool{}
+	for _, rt := range s.routeTable() { known[rt.pattern] = true }
+	for _, rt := range s.v1Routes() { known[rt.pattern] = true }
+	for pattern := range s.cfg.Concurrency.Routes {
+		if !known[pattern] { slog.Warn("concurrency cap for a route that doesn't exist", "route", pattern) }
+	}
+}
+
+// ---- HTTP routes ----
+type route struct {
+	pattern string
+	handler http.HandlerFunc
+}
+
+// apiV1 prefixes every path of version 1 of the API. A version with
+// different schemas gets a prefix and a route table of its own, served
+// alongside this one until its clients have moved on.
+const apiV1 = "/api/v1"
+
+// routeTable lists every endpoint under the path it's served at, legacy
+// aliases aside. api/openapi.json must document each of them;
+// TestOpenAPICoversRoutes enforces that.
+func (s *Server) routeTable() []route {
+	rts := s.opsRoutes()
+	for _, rt := range s.v1Routes() {
+		method, path, _ := strings.Cut(rt.pattern, " ")
+		rts = append(rts, route{method + " " + apiV1 + path, s.envelopedRoute(rt)})
+	}
+	for _, rt := range s.resourceRoutes() { rts = append(rts, route{rt.pattern, s.enveloped(rt.handler)}) }
+	return rts
+}
+
+// envelopedRoute is the handler of a v1 route, enveloped unless its body
+// has a shape of its own.
+func (s *Server) envelopedRoute(rt route) http.HandlerFunc {
+	if unenveloped[rt.pattern] { return rt.handler }
+	return s.enveloped(rt.handler)
+}
+
+// opsRoutes are for probes, scrapers and operators, and the admin UI. They
+// are no part of the API clients program against, so they aren't versioned.
+func (s *Server) opsRoutes() []route {
+	h := s.h
+	return []route{
+		{"GET /health", h.Healthz}, // kept for existing probes
+		{"GET /healthz", h.Healthz},
+		{"GET /readyz", h.Readyz},
+		{"GET /debug/pool", h.PoolStats},
+		{"GET /metrics", metrics.Handler().ServeHTTP},
+		{"GET /ui/", h.UI}, // GET /ui redirects here
+	}
+}
+
+// v1Routes lists the endpoints of version 1, relative to apiV1.
+func (s *Server) v1Routes() []route {
+	h := s.h
+	return []route{
+		{"POST /auth/register", h.Register},
+		{"POST /auth/login", h.Login},
+		{"POST /apikeys", s.requireUser(h.CreateAPIKey)}, // keys can't mint keys
+		{"DELETE /apikeys/{id}", s.requireUser(h.RevokeAPIKey)},
+		{"GET /apikeys", s.requireRole(auth.RoleAdmin, h.ListAPIKeys)},
+		{"PUT /apikeys/{id}/role", s.requireRole(auth.RoleAdmin, h.SetAPIKeyRole)},
+		{"GET /usage", s.requireAuth("", h.Usage)}, // any key may see its own
+		{"GET /users", s.requireRole(auth.RoleAdmin, h.ListUsers)},
+		{"PUT /users/{id}/role", s.requireRole(auth.RoleAdmin, h.SetUserRole)},
+		{"GET /names", s.requireAuth(auth.ScopeRead, h.ListNames)},
+		{"POST /names", s.requireAuth(auth.ScopeWrite, s.idempotent(h.CreateName))},
+		{"DELETE /names", s.requireAuth(auth.ScopeWrite, h.BulkDelete)},
+		{"POST /names/bulk", s.requireAuth(auth.ScopeWrite, s.idempotent(h.BulkCreate))},
+		{"POST /names/transaction", s.requireAuth(auth.ScopeWrite, s.idempotent(h.Transaction))},
+		{"GET /names/trash", s.requireAuth(auth.ScopeRead, h.Trash)},
+		{"GET /names/stream", s.requireAuth(auth.ScopeRead, h.Stream)}, // SSE
+		{"GET /names/changes", s.requireAuth(auth.ScopeRead, h.NameChanges)}, // long polls
+		{"GET /names/search", s.requireAuth(auth.ScopeRead, h.SearchNames)},
+		{"GET /names/duplicates", s.requireAuth(auth.ScopeRead, h.Duplicates)},
+		{"POST /names/merge", s.requireAuth(auth.ScopeWrite, s.idempotent(h.MergeNames))},
+		{"GET /names/random", s.requireAuth(auth.ScopeRead, h.RandomName)},
+		{"GET /names/export", s.requireAuth(auth.ScopeRead, h.Export)}, // NDJSON or CSV stream
+		{"POST /names/import", s.requireAuth(auth.ScopeWrite, h.Import)}, // CSV or NDJSON
+		{"PUT /names/by-name/{name}", s.requireAuth(auth.ScopeWrite, h.UpsertName)},
+		{"GET /names/{id}", s.requireAuth(auth.ScopeRead, h.GetName)},
+		{"PUT /names/{id}", s.requireAuth(auth.ScopeWrite, h.UpdateName)},
+		{"PATCH /names/{id}", s.requireAuth(auth.ScopeWrite, h.PatchName)},
+		{"DELETE /names/{id}", s.requireAuth(auth.ScopeWrite, h.DeleteName)},
+		{"POST /names/{id}/restore", s.requireAuth(auth.ScopeWrite, h.RestoreName)},
+		{"GET /names/{id}/events", s.requireAuth(auth.ScopeRead, h.NameEvents)},
+		{"GET /names/{id}/history", s.requireAuth(auth.ScopeRead, h.NameHistory)},
+		{"GET /names/{id}/notes", s.requireAuth(auth.ScopeRead, h.ListNotes)},
+		{"POST /names/{id}/notes", s.requireAuth(auth.ScopeWrite, h.CreateNote)},
+		{"POST /names/{id}/tags", s.requireAuth(auth.ScopeWrite, h.AddTag)},
+		{"DELETE /names/{id}/tags/{tag}", s.requireAuth(auth.ScopeWrite, h.RemoveTag)},
+		{"POST /names/{id}/revert", s.requireAuth(auth.ScopeWrite, h.RevertName)},
+		{"GET /jobs/{id}", s.requireAuth(auth.ScopeRead, h.GetJob)},
+		{"GET /jobs/{id}/output", s.requireAuth(auth.ScopeRead, h.JobOutput)},
+		{"POST /webhooks", s.requireRole(auth.RoleAdmin, h.CreateWebhook)},
+		{"GET /webhooks", s.requireRole(auth.RoleAdmin, h.ListWebhooks)},
+		{"DELETE /webhooks/{id}", s.requireRole(auth.RoleAdmin, h.DeleteWebhook)},
+		{"GET /webhooks/{id}/deliveries", s.requireRole(auth.RoleAdmin, h.WebhookDeliveries)},
+		{"GET /audit", s.requireAuth(auth.ScopeAudit, h.Audit)},
+		{"GET /admin/stats", s.requireAuth(auth.ScopeAdmin, h.AdminStats)},
+		{"GET /admin/captures/{request_id}", s.requireAuth(auth.ScopeAdmin, h.GetCapture)},
+		{"POST /admin/seed", s.requireAuth(auth.ScopeAdmin, h.Seed)}, // checks names:write too; off in production
+		{"POST /admin/drain", s.requireAuth(auth.ScopeAdmin, s.Drain)},
+		{"POST /admin/shadow/check", s.requireAuth(auth.ScopeAdmin, h.ShadowCheck)},
+		{"POST /admin/backup", s.requireAuth(auth.ScopeAdmin, h.Backup)},
+		{"POST /admin/restore", s.requireAuth(auth.ScopeAdmin, h.Restore)},
+		{"POST /admin/normalize", s.requireAuth(auth.ScopeAdmin, h.Normalize)},
+		{"GET /admin/leader", s.requireAuth(auth.ScopeAdmin, h.Leader)},
+		{"POST /graphql", s.requireAuth(auth.ScopeRead, h.GraphQL)}, // mutations check names:write
+		{"GET /openapi.json", h.OpenAPI},
+		{"GET /docs", h.Docs},
+		{"GET /docs/{file}", h.DocsAsset},
+	}
+}
+
+// resourceRoutes serve the resources declared in RESOURCES_FILE. They came
+// after versioning, so they have no legacy aliases at the root, and their
+// paths are only as specific as a wildcard: the literal ones above win.
+func (s *Server) resourceRoutes() []route {
+	h := s.h
+	return []route{
+		{"GET " + apiV1 + "/{resource}", h.DeclaredResource(s.requireAuth(auth.ScopeRead, h.ListDocs))},
+		{"POST " + apiV1 + "/{resource}", h.DeclaredResource(s.requireAuth(auth.ScopeWrite, s.idempotent(h.CreateDoc)))},
+		{"GET " + apiV1 + "/{resource}/{id}", h.DeclaredResource(s.requireAuth(auth.ScopeRead, h.GetDoc))},
+		{"PUT " + apiV1 + "/{resource}/{id}", h.DeclaredResource(s.requireAuth(auth.ScopeWrite, h.UpdateDoc))},
+		{"DELETE " + apiV1 + "/{resource}/{id}", h.DeclaredResource(s.requireAuth(auth.ScopeWrite, h.DeleteDoc))},
+	}
+}
+
+func (s *Server) routes() *http.ServeMux {
+	mux := http.NewServeMux()
+	for _, rt := range s.routeTable() { mux.HandleFunc(rt.pattern, rt.handler) }
+	// Version 1 was served at the root before the API was versioned; it
+	// still is there, deprecated, until LegacySunset.
+	for _, rt := range s.v1Routes() { mux.HandleFunc(rt.pattern, s.deprecated(apiV1, s.envelopedRoute(rt))) }
+	return mux
+}
+
+// Run serves until ctx is cancelled, then stops accepting connections and
+// lets in-flight requests finish; whatever is still running after
+// ShutdownGrace is cut off. If either the API or the redirect listener
+// fails, both stop.
+func (s *Server) Run(ctx context.Context) error {
+	serve, redirect := s.srv.ListenAndServe, (*http.Server)(nil)
+	tc := s.cfg.TLS
+	switch {
+	case len(tc.AutocertDomains) > 0:
+		m := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(tc.AutocertDomains...),
+			Cache:      autocert.DirCache(tc.AutocertCacheDir),
+			Email:      tc.AutocertEmail,
+		}
+		s.srv.TLSConfig = m.TLSConfig()
+		serve = func() error { return s.srv.ListenAndServeTLS("", "") }
+		redirect = s.cfg.HTTP.httpServer(cmp.Or(tc.RedirectAddr, ":80"), m.HTTPHandler(redirectToHTTPS(s.cfg.Addr)))
+	case tc.CertFile != "":
+		s.srv.TLSConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+		serve = func() error { return s.srv.ListenAndServeTLS(tc.CertFile, tc.KeyFile) }
+		if tc.RedirectAddr != "" { redirect = s.cfg.HTTP.httpServer(tc.RedirectAddr, redirectToHTTPS(s.cfg.Addr)) }
+	}
+
+	servers := []*http.Server{s.srv}
+	serveErr := make(chan error, 2)
+	go func() {
+		slog.Info("serving", "addr", s.srv.Addr, "tls", tc.enabled())
+		serveErr <- serve()
+	}()
+	if redirect != nil {
+		servers = append(servers, redirect)
+		go func() {
+			slog.Info("redirecting plain HTTP to HTTPS", "addr", redirect.Addr)
+			serveErr <- redirect.ListenAndServe()
+		}()
+	}
+
+	running, failed := len(servers), error(nil)
+	select {
+	case failed = <-serveErr:
+		running--
+	case <-ctx.Done():
+	}
+
+	slog.Info("shutting down", "grace", s.cfg.ShutdownGrace.String())
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), s.cfg.ShutdownGrace)
+	defer cancel()
+	for _, srv := range servers {
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			slog.Warn("grace period expired, closing remaining connections", "addr", srv.Addr, "err", err)
+			_ = srv.Close()
+		}
+	}
+	errs := []error{failed}
+	for ; running > 0; running-- {
+		if err := <-serveErr; !errors.Is(err, http.ErrServerClosed) { errs = append(errs, err) }
+	}
+	return errors.Join(errs...)
+}
+
+// redirectToHTTPS sends every request to the same URL over HTTPS, on the
+// port of httpsAddr. 308 rather than 301 keeps clients from turning a POST
+// into a GET.
+func redirectToHTTPS(httpsAddr string) http.Handler {
+	_, port, _ := net.SplitHostPort(httpsAddr)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil { host = h }
+		host = strings.Trim(host, "[]")
+		switch {
+		case port != "" && port != "443":
+			host = net.JoinHostPort(host, port)
+		case strings.Contains(host, ":"):
+			host = "[" + host + "]"
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusPermanentRedirect)
+	})
+}
@@ -0,0 +1,43 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"app/internal/handlers"
+	"app/internal/resource"
+)
+
+// A resource named like a built-in endpoint would be shadowed by it, so
+// resource.Reserved has to keep up with the route table.
+func TestReservedResourceNames(t *testing.T) {
+	s := &Server{h: &handlers.Handlers{}}
+	for _, rt := range s.v1Routes() {
+		_, path, _ := strings.Cut(rt.pattern, " ")
+		first, _, _ := strings.Cut(strings.TrimPrefix(path, "/"), "/")
+		if !slices.Contains(resource.Reserved, first) { t.Errorf("%q is served at /%s, which resource.Reserved leaves out", rt.pattern, first) }
+	}
+}
+
+func TestRedirectToHTTPS(t *testing.T) {
+	for _, tc := range []struct {
+		httpsAddr, host, want string
+	}{
+		{":443", "api.example.com", "https://api.example.com/names?limit=5"},
+		{":443", "api.example.com:80", "https://api.example.com/names?limit=5"},
+		{":8443", "api.example.com:8080", "https://api.example.com:8443/names?limit=5"},
+		{":443", "[::1]:80", "https://[::1]/names?limit=5"},
+		{":8443", "[::1]", "https://[::1]:8443/names?limit=5"},
+	} {
+		r := httptest.NewRequest(http.MethodPost, "/names?limit=5", nil)
+		r.Host = tc.host
+		rec := httptest.NewRecorder()
+		redirectToHTTPS(tc.httpsAddr).ServeHTTP(rec, r)
+		if rec.Code != http.StatusPermanentRedirect || rec.Header().Get("Location") != tc.want {
+			t.Errorf("%s via %s: %d to %q, want %q", tc.host, tc.httpsAddr, rec.Code, rec.Header().Get("Location"), tc.want)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package server
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"app/internal/handlers"
+	"app/internal/store"
+	"app/internal/usage"
+)
+
+// trackUsage serves r, which came with API key k, unless k has used up a
+// quota, when it is a 429 until the quota resets. The request and the
+// bytes of its bodies, as the handler read and wrote them, count toward
+// k's usage. A store that can't tell k's usage lets the request through:
+// quotas aren't worth an outage.
+func (s *Server) trackUsage(k store.APIKey, w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	t := s.cfg.Usage
+	if t == nil { next(w, r); return }
+
+	var exceeded *usage.ExceededError
+	if err := t.Allow(r.Context(), k.ID); errors.As(err, &exceeded) {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(time.Until(exceeded.Resets).Seconds()))))
+		handlers.WriteProblem(w, http.StatusTooManyRequests, handlers.CodeQuotaExceeded, exceeded.Error(), nil)
+		return
+	} else if err != nil {
+		slog.WarnContext(r.Context(), "reading API key usage; letting the request through", "key", k.ID.Hex(), "err", err)
+	}
+
+	in := &countingReader{ReadCloser: r.Body}
+	out := &countingWriter{ResponseWriter: w}
+	r.Body = in
+	defer func() { t.Record(k.ID, k.Tenant, in.n, out.n) }()
+	next(out, r)
+}
+
+// countingReader counts the bytes read of a body.
+type countingReader struct {
+	io.ReadCloser
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// countingWriter counts the bytes written of a response body.
+type countingWriter struct {
+	http.ResponseWriter
+	n int64
+}
+
+func (c *countingWriter) Write(b []byte) (int, error) {
+	n, err := c.ResponseWriter.Write(b)
+	c.n += int64(n)
+	return n, err
+}
+
+func (c *countingWriter) Unwrap() http.ResponseWriter { return c.ResponseWriter }
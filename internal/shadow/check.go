@@ -0,0 +1,123 @@
+package shadow
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"app/internal/store"
+)
+
+// Divergence is a name the two stores disagree on: Missing from the
+// shadow, Extra in the shadow, or stored with different Fields.
+type Divergence struct {
+	ID     primitive.ObjectID `json:"id"`
+	Name   string             `json:"name"`
+	Kind   string             `json:"kind"` // missing, extra or differs
+	Fields []string           `json:"fields,omitempty"`
+}
+
+// The kinds of Divergence.
+const (
+	Missing = "missing"
+	Extra   = "extra"
+	Differs = "differs"
+)
+
+// Report is what Check found. Divergences lists the first of them, up to
+// the limit Check was given; the counts are of all.
+type Report struct {
+	Checked     int          `json:"checked"` // names of the primary compared
+	Missing     int          `json:"missing"`
+	Extra       int          `json:"extra"`
+	Differing   int          `json:"differing"`
+	Divergences []Divergence `json:"divergences"`
+}
+
+// checkBatch is how many names Check looks up in the other store at once.
+const checkBatch = 500
+
+// Check compares the names of the tenant in ctx, soft-deleted ones
+// included, in the primary and the shadow, and reports where they diverge.
+// Timestamps the stores set themselves aren't compared, but the versions
+// are, so a write the shadow missed shows. Names written while it runs may
+// show as divergences that are gone by the time it is done.
+func (s *Names) Check(ctx context.Context, limit int) (Report, error) {
+	r := Report{Divergences: []Divergence{}}
+	add := func(d Divergence) {
+		if len(r.Divergences) < limit { r.Divergences = append(r.Divergences, d) }
+	}
+	err := batches(ctx, s.NameStore, func(ns []store.Name) error {
+		other, err := s.shadow.Lookup(ctx, ids(ns))
+		if err != nil { return err }
+		r.Checked += len(ns)
+		for _, n := range ns {
+			sn, ok := other[n.ID]
+			if !ok { r.Missing++; add(Divergence{ID: n.ID, Name: n.Name, Kind: Missing}); continue }
+			if fields := differences(n, sn); len(fields) > 0 { r.Differing++; add(Divergence{ID: n.ID, Name: n.Name, Kind: Differs, Fields: fields}) }
+		}
+		return nil
+	})
+	if err != nil { return r, err }
+	err = batches(ctx, s.shadow, func(ns []store.Name) error {
+		other, err := s.NameStore.Lookup(ctx, ids(ns))
+		if err != nil { return err }
+		for _, n := range ns {
+			if _, ok := other[n.ID]; !ok { r.Extra++; add(Divergence{ID: n.ID, Name: n.Name, Kind: Extra}) }
+		}
+		return nil
+	})
+	return r, err
+}
+
+// batches calls fn with every name of s, soft-deleted ones included,
+// checkBatch at a time.
+func batches(ctx context.Context, s store.NameStore, fn func([]store.Name) error) error {
+	batch := make([]store.Name, 0, checkBatch)
+	err := s.Each(ctx, store.ListOptions{IncludeDeleted: true}, func(n store.Name) error {
+		if batch = append(batch, n); len(batch) < checkBatch { return nil }
+		err := fn(batch)
+		batch = batch[:0]
+		return err
+	})
+	if err != nil || len(batch) == 0 { return err }
+	return fn(batch)
+}
+
+func ids(ns []store.Name) []primitive.ObjectID {
+	out := make([]primitive.ObjectID, len(ns))
+	for i, n := range ns { out[i] = n.ID }
+	return out
+}
+
+// differences names the fields, by their JSON names, in which a and b
+// differ. Metadata is compared as JSON, as stores decode numbers to
+// different types, and expiries to the millisecond MongoDB keeps.
+func differences(a, b store.Name) []string {
+	var out []string
+	if a.UUID != b.UUID { out = append(out, "uuid") }
+	if a.Slug != b.Slug { out = append(out, "slug") }
+	if a.Name != b.Name { out = append(out, "name") }
+	if !slices.Equal(a.Tags, b.Tags) && len(a.Tags)+len(b.Tags) > 0 { out = append(out, "tags") }
+	if !sameJSON(a.Metadata, b.Metadata) { out = append(out, "metadata") }
+	if a.CreatedBy != b.CreatedBy { out = append(out, "created_by") }
+	if (a.DeletedAt == nil) != (b.DeletedAt == nil) { out = append(out, "deleted_at") }
+	if !sameTime(a.ExpiresAt, b.ExpiresAt) { out = append(out, "expires_at") }
+	if a.Version != b.Version { out = append(out, "version") }
+	return out
+}
+
+func sameJSON(a, b map[string]any) bool {
+	if len(a) == 0 || len(b) == 0 { return len(a) == len(b) }
+	ja, errA := json.Marshal(a)
+	jb, errB := json.Marshal(b)
+	return errA == nil && errB == nil && string(ja) == string(jb)
+}
+
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil { return a == b }
+	return a.Truncate(time.Millisecond).Equal(b.Truncate(time.Millisecond))
+}
@@ -0,0 +1,156 @@
+// Package shadow copies the writes to the names onto a second store, so
+// that they can move to another database without downtime: run with the
+// new one as the shadow, copy the names written before, check that the two
+// agree (see Check), then make the new one the primary.
+//
+// The primary store decides every write and serves every read. A write it
+// makes is repeated on the shadow once it has committed, with the same IDs
+// and unconditionally, as the primary has already checked its version. One
+// the shadow refuses is logged and counted, not failed: Check finds it.
+package shadow
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"app/internal/metrics"
+	"app/internal/store"
+	"app/internal/tenant"
+)
+
+// Names wraps the primary NameStore and copies its successful writes to a
+// shadow. It sits right above the retries, beneath every layer that adds a
+// write of its own, so the shadow is asked what the primary was.
+type Names struct {
+	store.NameStore
+	shadow  store.NameStore
+	timeout time.Duration
+}
+
+func NewNames(primary, shadow store.NameStore, timeout time.Duration) *Names {
+	return &Names{NameStore: primary, shadow: shadow, timeout: timeout}
+}
+
+// Primary and Shadow are the stores wrapped, for Check.
+func (s *Names) Primary() store.NameStore { return s.NameStore }
+func (s *Names) Shadow() store.NameStore  { return s.shadow }
+
+// copy runs write on the shadow once the transaction ctx may be part of has
+// committed, for at most the timeout, even if ctx has been canceled
+// meanwhile. It gets a context of its own, with only the tenant of ctx: the
+// primary's session means nothing to the shadow.
+func (s *Names) copy(ctx context.Context, op string, write func(ctx context.Context) error) {
+	store.AfterCommit(ctx, func(ctx context.Context) {
+		sctx, cancel := context.WithTimeout(tenant.NewContext(context.Background(), tenant.FromContext(ctx)), s.timeout)
+		defer cancel()
+		if err := write(sctx); err != nil {
+			metrics.ShadowWrite(op, "failed")
+			slog.WarnContext(ctx, "copying a write to the shadow store", "op", op, "err", err)
+			return
+		}
+		metrics.ShadowWrite(op, "ok")
+	})
+}
+
+func (s *Names) Create(ctx context.Context, n *store.Name) error {
+	if err := s.NameStore.Create(ctx, n); err != nil { return err }
+	c := *n
+	s.copy(ctx, "create", func(ctx context.Context) error { return s.shadow.Create(ctx, &c) })
+	return nil
+}
+
+func (s *Names) CreateIfAbsent(ctx context.Context, n *store.Name) (bool, error) {
+	created, err := s.NameStore.CreateIfAbsent(ctx, n)
+	if created {
+		c := *n
+		s.copy(ctx, "create", func(ctx context.Context) error { return s.shadow.Create(ctx, &c) })
+	}
+	return created, err
+}
+
+// Upsert creates on the shadow with the ID the primary gave, if it created.
+func (s *Names) Upsert(ctx context.Context, n store.Name, ifVersion int64) (*store.Name, store.Name, error) {
+	before, after, err := s.NameStore.Upsert(ctx, n, ifVersion)
+	if err != nil { return before, after, err }
+	n.ID = after.ID
+	s.copy(ctx, "upsert", func(ctx context.Context) error { _, _, err := s.shadow.Upsert(ctx, n, store.AnyVersion); return err })
+	return before, after, nil
+}
+
+func (s *Names) Update(ctx context.Context, id primitive.ObjectID, n store.Name, ifVersion int64) (store.Name, error) {
+	after, err := s.NameStore.Update(ctx, id, n, ifVersion)
+	if err == nil { s.copy(ctx, "update", func(ctx context.Context) error { _, err := s.shadow.Update(ctx, id, n, store.AnyVersion); return err }) }
+	return after, err
+}
+
+func (s *Names) Patch(ctx context.Context, id primitive.ObjectID, p store.NamePatch, ifVersion int64) (store.Name, error) {
+	after, err := s.NameStore.Patch(ctx, id, p, ifVersion)
+	if err == nil { s.copy(ctx, "patch", func(ctx context.Context) error { _, err := s.shadow.Patch(ctx, id, p, store.AnyVersion); return err }) }
+	return after, err
+}
+
+func (s *Names) SoftDelete(ctx context.Context, id primitive.ObjectID, ifVersion int64) error {
+	if err := s.NameStore.SoftDelete(ctx, id, ifVersion); err != nil { return err }
+	s.copy(ctx, "soft_delete", func(ctx context.Context) error { return s.shadow.SoftDelete(ctx, id, store.AnyVersion) })
+	return nil
+}
+
+func (s *Names) HardDelete(ctx context.Context, id primitive.ObjectID, ifVersion int64) error {
+	if err := s.NameStore.HardDelete(ctx, id, ifVersion); err != nil { return err }
+	s.copy(ctx, "hard_delete", func(ctx context.Context) error { return s.shadow.HardDelete(ctx, id, store.AnyVersion) })
+	return nil
+}
+
+func (s *Names) Restore(ctx context.Context, id primitive.ObjectID) (store.Name, error) {
+	after, err := s.NameStore.Restore(ctx, id)
+	if err == nil { s.copy(ctx, "restore", func(ctx context.Context) error { _, err := s.shadow.Restore(ctx, id); return err }) }
+	return after, err
+}
+
+func (s *Names) CreateMany(ctx context.Context, ns []store.Name) ([]error, error) {
+	return s.many(ctx, "create_many", ns, s.NameStore.CreateMany, s.shadow.CreateMany)
+}
+
+func (s *Names) InsertMany(ctx context.Context, ns []store.Name) ([]error, error) {
+	return s.many(ctx, "insert_many", ns, s.NameStore.InsertMany, s.shadow.InsertMany)
+}
+
+// many gives the items of a batch insert their IDs beforehand, so that both
+// stores file them under the same, and copies those the primary stored.
+func (s *Names) many(ctx context.Context, op string, ns []store.Name, primary, shadow func(context.Context, []store.Name) ([]error, error)) ([]error, error) {
+	for i := range ns {
+		if ns[i].ID.IsZero() { ns[i].ID = primitive.NewObjectID() }
+	}
+	errs, err := primary(ctx, ns)
+	if err != nil { return errs, err }
+	var stored []store.Name
+	for i := range ns {
+		if i < len(errs) && errs[i] == nil { stored = append(stored, ns[i]) }
+	}
+	if len(stored) > 0 {
+		s.copy(ctx, op, func(ctx context.Context) error {
+			errs, err := shadow(ctx, stored)
+			for _, e := range errs {
+				if err == nil { err = e }
+			}
+			return err
+		})
+	}
+	return errs, nil
+}
+
+func (s *Names) DeleteMany(ctx context.Context, ids []primitive.ObjectID, hard bool) (map[primitive.ObjectID]bool, error) {
+	existed, err := s.NameStore.DeleteMany(ctx, ids, hard)
+	if err != nil { return existed, err }
+	var deleted []primitive.ObjectID
+	for id, ok := range existed {
+		if ok { deleted = append(deleted, id) }
+	}
+	if len(deleted) > 0 {
+		s.copy(ctx, "delete_many", func(ctx context.Context) error { _, err := s.shadow.DeleteMany(ctx, deleted, hard); return err })
+	}
+	return existed, nil
+}
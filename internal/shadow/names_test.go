@@ -0,0 +1,57 @@
+package shadow
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"app/internal/store"
+	"app/internal/tenant"
+)
+
+func TestNames(t *testing.T) {
+	ctx := tenant.NewContext(context.Background(), "team-a")
+	primary, second := store.NewMemoryNames(), store.NewMemoryNames()
+	names := NewNames(primary, second, time.Second)
+
+	a := store.Name{Name: "alice", Metadata: map[string]any{"n": 1}}
+	if err := names.Create(ctx, &a); err != nil { t.Fatal(err) }
+	if _, err := names.Patch(ctx, a.ID, store.NamePatch{Tags: &[]string{"vip"}}, 1); err != nil { t.Fatal(err) }
+	if _, err := names.Patch(ctx, a.ID, store.NamePatch{Tags: &[]string{"x"}}, 1); err == nil { t.Fatal("stale patch went through") }
+	if errs, err := names.CreateMany(ctx, []store.Name{{Name: "bob"}, {Name: "alice"}, {Name: "carol"}}); err != nil || errs[0] != nil || errs[1] == nil || errs[2] != nil { t.Fatal(errs, err) }
+	_, d, err := names.Upsert(ctx, store.Name{Name: "dave", Tags: []string{"new"}}, store.AnyVersion)
+	if err != nil { t.Fatal(err) }
+	if err := names.SoftDelete(ctx, a.ID, store.AnyVersion); err != nil { t.Fatal(err) }
+	// Writes in a transaction are only copied once it commits.
+	_ = primary.InTransaction(ctx, func(ctx context.Context) error {
+		if err := names.Create(ctx, &store.Name{Name: "rolled back"}); err != nil { return err }
+		return errors.New("roll back")
+	})
+	if err := primary.InTransaction(ctx, func(ctx context.Context) error { return names.Create(ctx, &store.Name{Name: "erin"}) }); err != nil { t.Fatal(err) }
+
+	r, err := names.Check(ctx, 10)
+	if err != nil || r.Checked != 5 || r.Missing+r.Extra+r.Differing != 0 { t.Fatalf("in step: %+v, %v", r, err) }
+	got, err := second.Lookup(ctx, []primitive.ObjectID{a.ID})
+	if err != nil || got[a.ID].Version != 3 || got[a.ID].DeletedAt == nil || !slices.Equal(got[a.ID].Tags, []string{"vip"}) { t.Fatalf("shadow copy: %+v, %v", got, err) }
+
+	// What the shadow misses, or has of its own, shows.
+	if err := primary.Create(ctx, &store.Name{Name: "frank"}); err != nil { t.Fatal(err) }
+	if err := second.Create(ctx, &store.Name{Name: "gina"}); err != nil { t.Fatal(err) }
+	if _, err := second.Patch(ctx, d.ID, store.NamePatch{Name: ptr("david")}, store.AnyVersion); err != nil { t.Fatal(err) }
+	if r, err = names.Check(ctx, 2); err != nil { t.Fatal(err) }
+	if r.Checked != 6 || r.Missing != 1 || r.Extra != 1 || r.Differing != 1 || len(r.Divergences) != 2 { t.Fatalf("diverged: %+v", r) }
+	if d := r.Divergences; d[0].Name != "dave" || d[0].Kind != Differs || !slices.Equal(d[0].Fields, []string{"name", "version"}) || d[1].Kind != Missing || d[1].Name != "frank" { t.Fatalf("divergences: %+v", d) }
+
+	// A write the shadow refuses doesn't fail.
+	if err := names.HardDelete(ctx, a.ID, store.AnyVersion); err != nil { t.Fatal(err) }
+	if err := second.HardDelete(ctx, a.ID, store.AnyVersion); err == nil { t.Fatal("hard delete wasn't copied") }
+	if _, err := names.Restore(ctx, a.ID); err == nil { t.Fatal("restored a removed name") }
+	other := tenant.NewContext(context.Background(), "team-b")
+	if r, err = names.Check(other, 10); err != nil || r.Checked != 0 { t.Fatalf("other tenant: %+v, %v", r, err) }
+}
+
+func ptr[T any](v T) *T { return &v }
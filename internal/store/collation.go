@@ -0,0 +1,84 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"golang.org/x/text/collate"
+	"golang.org/x/text/language"
+)
+
+// ErrUnsupportedLocale: the database has no collation for the locale asked
+// for.
+var ErrUnsupportedLocale = errors.New("unsupported collation locale")
+
+// Collation is how names compare when sorted and checked for duplicates:
+// by the rules of Locale, an ICU locale such as "fr", "de_AT" or
+// "de@collation=phonebook", at Strength 1 (base letters only, so "José"
+// equals "jose"), 2 (accents count too), 3 (and case; the default), 4 or 5
+// (identical). The zero value, like Locale "simple", compares code points.
+type Collation struct {
+	Locale   string
+	Strength int
+}
+
+// localeRE is the shape of an ICU locale as MongoDB takes it.
+var localeRE = regexp.MustCompile(`^[a-z]{2,3}(_[A-Za-z0-9]{2,8})*(@collation=[a-z]+)?$`)
+
+// ValidLocale reports whether locale is "simple" or looks like an ICU
+// locale. Whether the database supports it is only known once it's used.
+func ValidLocale(locale string) bool {
+	if locale == "simple" { return true }
+	if !localeRE.MatchString(locale) { return false }
+	tag, _, _ := strings.Cut(locale, "@")
+	_, err := language.Parse(strings.ReplaceAll(tag, "_", "-"))
+	return err == nil
+}
+
+func (c Collation) Validate() error {
+	if c.Locale != "" && !ValidLocale(c.Locale) { return fmt.Errorf("collation locale %q is not an ICU locale such as fr or de_AT", c.Locale) }
+	if c.Strength < 0 || c.Strength > 5 { return fmt.Errorf("collation strength must be between 1 and 5, or 0 for the default, got %d", c.Strength) }
+	return nil
+}
+
+// with returns c with its locale replaced by locale, unless that's empty.
+func (c Collation) with(locale string) Collation {
+	if locale != "" { c.Locale = locale }
+	return c
+}
+
+// mongo is c as a MongoDB collation; nil for code point order.
+func (c Collation) mongo() *options.Collation {
+	if c.Locale == "" || c.Locale == "simple" { return nil }
+	return &options.Collation{Locale: c.Locale, Strength: c.Strength}
+}
+
+// folding is c at strength 1, where strings equal but for case and accents
+// compare equal; in English if c has no locale.
+func (c Collation) folding() *options.Collation {
+	locale := c.Locale
+	if locale == "" || locale == "simple" { locale = "en" }
+	return &options.Collation{Locale: locale, Strength: 1}
+}
+
+// compare returns a function ordering strings by c, much as MongoDB does.
+// The functions aren't safe for concurrent use.
+func (c Collation) compare() func(a, b string) int {
+	if c.Locale == "" || c.Locale == "simple" { return strings.Compare }
+	tag, _, _ := strings.Cut(c.Locale, "@")
+	var opts []collate.Option
+	switch c.Strength {
+	case 1:
+		opts = append(opts, collate.IgnoreDiacritics, collate.IgnoreCase, collate.IgnoreWidth)
+	case 2:
+		opts = append(opts, collate.IgnoreCase, collate.IgnoreWidth)
+	}
+	coll := collate.New(language.Make(strings.ReplaceAll(tag, "_", "-")), opts...)
+	return func(a, b string) int {
+		if d := coll.CompareString(a, b); d != 0 || c.Strength < 4 { return d }
+		return strings.Compare(a, b)
+	}
+}
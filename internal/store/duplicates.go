@@ -0,0 +1,104 @@
+package store
+
+import (
+	"bytes"
+	"cmp"
+	"errors"
+	"maps"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// ErrTooManyNames: the tenant has more distinct names than Duplicates
+// compares edit by edit.
+var ErrTooManyNames = errors.New("too many names to compare")
+
+// MaxFuzzyKeys is the most distinct normalized names Duplicates compares
+// with a distance, each with every other of about its length.
+const MaxFuzzyKeys = 2000
+
+// NormalizeName is name as duplicates are told apart: without accents, its
+// case folded and its runs of spaces made one, so "José  Núñez" is
+// "jose nunez".
+func NormalizeName(name string) string {
+	s, _, err := transform.String(transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC), name)
+	if err != nil { s = name }
+	return strings.Join(strings.Fields(cases.Fold().String(s)), " ")
+}
+
+// clusters turns groups, names by their normalized form, into the clusters
+// q asks for. It takes groups over.
+func clusters(groups map[string][]Name, q DuplicateQuery) ([]DuplicateCluster, error) {
+	if q.Distance > 0 {
+		if len(groups) > MaxFuzzyKeys { return nil, ErrTooManyNames }
+		joinNear(groups, q.Distance)
+	}
+	out := []DuplicateCluster{}
+	for _, key := range slices.Sorted(maps.Keys(groups)) {
+		names := groups[key]
+		if len(names) < 2 { continue }
+		slices.SortFunc(names, func(a, b Name) int { return cmp.Or(strings.Compare(a.Name, b.Name), bytes.Compare(a.ID[:], b.ID[:])) })
+		out = append(out, DuplicateCluster{Key: key, Names: names})
+		if q.Limit > 0 && len(out) == q.Limit { break }
+	}
+	return out, nil
+}
+
+// joinNear merges the groups whose keys are within distance edits of each
+// other, directly or through others, into the group of the least key.
+func joinNear(groups map[string][]Name, distance int) {
+	keys := slices.Collect(maps.Keys(groups))
+	// By length, so that each key need only be compared with the few after
+	// it that are at most distance longer.
+	slices.SortFunc(keys, func(a, b string) int { return cmp.Or(cmp.Compare(utf8.RuneCountInString(a), utf8.RuneCountInString(b)), strings.Compare(a, b)) })
+	rs := make([][]rune, len(keys))
+	parent := make([]int, len(keys))
+	for i, k := range keys { rs[i], parent[i] = []rune(k), i }
+	root := func(i int) int {
+		for parent[i] != i { parent[i], i = parent[parent[i]], parent[i] }
+		return i
+	}
+	for i := range keys {
+		for j := i + 1; j < len(keys) && len(rs[j])-len(rs[i]) <= distance; j++ {
+			ri, rj := root(i), root(j)
+			if ri != rj && withinEdits(rs[i], rs[j], distance) { parent[max(ri, rj)] = min(ri, rj) }
+		}
+	}
+	members := map[int][]string{}
+	for i, k := range keys { members[root(i)] = append(members[root(i)], k) }
+	for _, ks := range members {
+		least := slices.Min(ks)
+		for _, k := range ks {
+			if k != least { groups[least] = append(groups[least], groups[k]...); delete(groups, k) }
+		}
+	}
+}
+
+// withinEdits reports whether the Levenshtein distance between a and b is
+// at most n. It gives up as soon as every prefix of b is further than n
+// from the prefix of a so far.
+func withinEdits(a, b []rune, n int) bool {
+	if len(a)-len(b) > n || len(b)-len(a) > n { return false }
+	prev, cur := make([]int, len(b)+1), make([]int, len(b)+1)
+	for j := range prev { prev[j] = j }
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		least := i
+		for j := 1; j <= len(b); j++ {
+			sub := prev[j-1]
+			if a[i-1] != b[j-1] { sub++ }
+			cur[j] = min(sub, prev[j]+1, cur[j-1]+1)
+			least = min(least, cur[j])
+		}
+		if least > n { return false }
+		prev, cur = cur, prev
+	}
+	return prev[len(b)] <= n
+}
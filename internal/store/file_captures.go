@@ -0,0 +1,113 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"app/internal/tenant"
+)
+
+// FileCaptures is the CaptureStore on disk: one JSON capture per line in
+// captures.ndjson, rotated to captures.1.ndjson, captures.2.ndjson... once it
+// reaches its size, the oldest file going.
+type FileCaptures struct {
+	dir      string
+	maxBytes int64 // of a file, about
+	maxFiles int   // rotated ones, the current one aside
+
+	mu   sync.Mutex
+	f    *os.File
+	size int64
+}
+
+// NewFileCaptures appends to the current file in dir, creating dir if need
+// be.
+func NewFileCaptures(dir string, maxBytes int64, maxFiles int) (*FileCaptures, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil { return nil, err }
+	s := &FileCaptures{dir: dir, maxBytes: maxBytes, maxFiles: maxFiles}
+	return s, s.open()
+}
+
+func (s *FileCaptures) path(i int) string {
+	if i == 0 { return filepath.Join(s.dir, "captures.ndjson") }
+	return filepath.Join(s.dir, fmt.Sprintf("captures.%d.ndjson", i))
+}
+
+func (s *FileCaptures) open() error {
+	f, err := os.OpenFile(s.path(0), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil { return err }
+	st, err := f.Stat()
+	if err != nil { f.Close(); return err }
+	s.f, s.size = f, st.Size()
+	return nil
+}
+
+// rotate shifts every file one number up, dropping the last, and starts a
+// new current one.
+func (s *FileCaptures) rotate() error {
+	if err := s.f.Close(); err != nil { return err }
+	if err := os.Remove(s.path(s.maxFiles)); err != nil && !errors.Is(err, fs.ErrNotExist) { return err }
+	for i := s.maxFiles - 1; i >= 0; i-- {
+		if err := os.Rename(s.path(i), s.path(i+1)); err != nil && !errors.Is(err, fs.ErrNotExist) { return err }
+	}
+	return s.open()
+}
+
+func (s *FileCaptures) SaveCapture(ctx context.Context, c *Capture) error {
+	line, err := json.Marshal(c)
+	if err != nil { return err }
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.size > 0 && s.size+int64(len(line))+1 > s.maxBytes {
+		if err := s.rotate(); err != nil { return err }
+	}
+	n, err := s.f.Write(append(line, '\n'))
+	s.size += int64(n)
+	return err
+}
+
+// Capture reads the files newest first, each to its end, keeping the last
+// match: fine for the odd lookup while debugging.
+func (s *FileCaptures) Capture(ctx context.Context, requestID string) (Capture, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tid := tenant.FromContext(ctx)
+	for i := 0; i <= s.maxFiles; i++ {
+		c, found, err := s.find(s.path(i), requestID, tid)
+		if found || err != nil { return c, err }
+	}
+	return Capture{}, ErrNotFound
+}
+
+func (s *FileCaptures) find(path, requestID, tid string) (Capture, bool, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) { return Capture{}, false, nil }
+	if err != nil { return Capture{}, false, err }
+	defer f.Close()
+	var last Capture
+	found := false
+	lines := bufio.NewScanner(f)
+	lines.Buffer(nil, 64<<20)
+	for lines.Scan() {
+		if !bytes.Contains(lines.Bytes(), []byte(requestID)) { continue }
+		var c Capture
+		if json.Unmarshal(lines.Bytes(), &c) != nil || c.RequestID != requestID || c.Tenant != tid { continue }
+		last, found = c, true
+	}
+	return last, found, lines.Err()
+}
+
+// Close closes the current file.
+func (s *FileCaptures) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.f.Close()
+}
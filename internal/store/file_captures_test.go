@@ -0,0 +1,22 @@
+package store
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileCaptures(t *testing.T) {
+	dir := t.TempDir()
+	// Two captures or so a file, and two rotated files kept.
+	s, err := NewFileCaptures(dir, 400, 2)
+	if err != nil { t.Fatal(err) }
+	t.Cleanup(func() { s.Close() })
+	testCaptures(t, s, 8)
+
+	files, _ := filepath.Glob(filepath.Join(dir, "captures*.ndjson"))
+	if len(files) != 3 { t.Errorf("files %v", files) }
+	for _, f := range files {
+		if st, _ := os.Stat(f); st.Size() > 400 { t.Errorf("%s has %d bytes", f, st.Size()) }
+	}
+}
@@ -0,0 +1,84 @@
+package store
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MemoryAPIKeys is the in-memory APIKeyStore.
+type MemoryAPIKeys struct {
+	mu   sync.RWMutex
+	keys map[primitive.ObjectID]APIKey
+}
+
+func NewMemoryAPIKeys() *MemoryAPIKeys { return &MemoryAPIKeys{keys: map[primitive.ObjectID]APIKey{}} }
+
+func (s *MemoryAPIKeys) CreateAPIKey(ctx context.Context, k APIKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, other := range s.keys {
+		if other.Hash == k.Hash { return ErrDuplicate }
+	}
+	k.Scopes = slices.Clone(k.Scopes)
+	s.keys[k.ID] = k
+	return nil
+}
+
+func (s *MemoryAPIKeys) APIKeyByHash(ctx context.Context, hash string) (APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, k := range s.keys {
+		if k.Hash == hash && k.RevokedAt == nil { k.Scopes = slices.Clone(k.Scopes); return k, nil }
+	}
+	return APIKey{}, ErrNotFound
+}
+
+func (s *MemoryAPIKeys) RevokeAPIKey(ctx context.Context, id, userID primitive.ObjectID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	k, ok := s.keys[id]
+	if !ok || k.UserID != userID || k.RevokedAt != nil { return ErrNotFound }
+	now := time.Now().UTC()
+	k.RevokedAt = &now
+	s.keys[id] = k
+	return nil
+}
+
+func (s *MemoryAPIKeys) RevokeTenantAPIKey(ctx context.Context, tenant string, id primitive.ObjectID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	k, ok := s.keys[id]
+	if !ok || k.Tenant != tenant || k.RevokedAt != nil { return ErrNotFound }
+	now := time.Now().UTC()
+	k.RevokedAt = &now
+	s.keys[id] = k
+	return nil
+}
+
+func (s *MemoryAPIKeys) APIKeys(ctx context.Context, tenant string) ([]APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	keys := []APIKey{}
+	for _, k := range s.keys {
+		if k.Tenant == tenant { k.Scopes = slices.Clone(k.Scopes); keys = append(keys, k) }
+	}
+	slices.SortFunc(keys, func(a, b APIKey) int { return cmp.Or(a.CreatedAt.Compare(b.CreatedAt), strings.Compare(a.ID.Hex(), b.ID.Hex())) })
+	return keys, nil
+}
+
+func (s *MemoryAPIKeys) SetAPIKeyRole(ctx context.Context, tenant string, id primitive.ObjectID, role string) (APIKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	k, ok := s.keys[id]
+	if !ok || k.Tenant != tenant { return APIKey{}, ErrNotFound }
+	k.Role = role
+	s.keys[id] = k
+	k.Scopes = slices.Clone(k.Scopes)
+	return k, nil
+}
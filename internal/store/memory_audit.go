@@ -0,0 +1,54 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"sync"
+
+	"app/internal/tenant"
+)
+
+// MemoryAudit is the in-memory AuditStore. It grows without bound, which
+// is fine for what the memory backend is for.
+type MemoryAudit struct {
+	mu      sync.RWMutex
+	entries []AuditEntry
+}
+
+func NewMemoryAudit() *MemoryAudit { return &MemoryAudit{} }
+
+func (s *MemoryAudit) RecordAudit(ctx context.Context, entries []AuditEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tid := tenant.FromContext(ctx)
+	for _, e := range entries {
+		e.Tenant = tid
+		s.entries = append(s.entries, e)
+	}
+	return nil
+}
+
+func (s *MemoryAudit) ListAudit(ctx context.Context, q AuditQuery) (AuditPage, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	tid, matched := tenant.FromContext(ctx), []AuditEntry{}
+	for i := len(s.entries) - 1; i >= 0 && int64(len(matched)) <= q.Limit; i-- {
+		e := s.entries[i]
+		switch {
+		case e.Tenant != tid,
+			q.Entity != "" && e.Entity != q.Entity,
+			!q.EntityID.IsZero() && e.EntityID != q.EntityID,
+			!q.Before.IsZero() && bytes.Compare(e.ID[:], q.Before[:]) >= 0:
+			continue
+		}
+		matched = append(matched, e)
+	}
+	return auditPage(matched, q.Limit), nil
+}
+
+// auditPage trims the Limit+1 entries a store fetched to a page, the extra
+// one telling whether there is a next.
+func auditPage(entries []AuditEntry, limit int64) AuditPage {
+	if int64(len(entries)) <= limit { return AuditPage{Items: entries} }
+	return AuditPage{Items: entries[:limit], Next: entries[limit-1].ID.Hex()}
+}
@@ -0,0 +1,47 @@
+package store
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"app/internal/tenant"
+)
+
+func TestMemoryAudit(t *testing.T) { testAudit(t, NewMemoryAudit()) }
+
+// testAudit checks that s filters, pages and keeps tenants apart.
+func testAudit(t *testing.T, s AuditStore) {
+	t.Helper()
+	ctx := context.Background()
+	a, b := primitive.NewObjectID(), primitive.NewObjectID()
+	var entries []AuditEntry
+	for i, id := range []primitive.ObjectID{a, b, a, a} {
+		e := AuditEntry{ID: primitive.NewObjectID(), Entity: "names", EntityID: id, Action: "updated", Actor: "u1", RequestID: "r" + strconv.Itoa(i), At: time.Now().UTC().Truncate(time.Millisecond)}
+		if i == 0 { e.Action, e.After = "created", &Name{ID: a, Name: "alice", Tags: []string{"vip"}, Version: 1} }
+		entries = append(entries, e)
+	}
+	if err := s.RecordAudit(ctx, entries); err != nil { t.Fatal(err) }
+	if err := s.RecordAudit(tenant.NewContext(ctx, "team-b"), []AuditEntry{{ID: primitive.NewObjectID(), Entity: "names", EntityID: a, Action: "removed"}}); err != nil { t.Fatal(err) }
+
+	// a's entries, newest first, two at a time.
+	var got []string
+	q := AuditQuery{Entity: "names", EntityID: a, Limit: 2}
+	for {
+		page, err := s.ListAudit(ctx, q)
+		if err != nil { t.Fatal(err) }
+		for _, e := range page.Items { got = append(got, e.RequestID) }
+		if page.Next == "" { break }
+		if q.Before, err = primitive.ObjectIDFromHex(page.Next); err != nil { t.Fatal(err) }
+	}
+	if len(got) != 3 || got[0] != "r3" || got[2] != "r0" { t.Fatalf("paged %v", got) }
+
+	page, err := s.ListAudit(ctx, AuditQuery{Limit: 10})
+	if err != nil || len(page.Items) != 4 { t.Fatalf("whole tenant: %d entries, %v", len(page.Items), err) }
+	first := page.Items[3]
+	if first.Action != "created" || first.Before != nil || first.After == nil || first.After.Tags[0] != "vip" || first.Actor != "u1" { t.Fatalf("first entry %+v", first) }
+	if page, _ := s.ListAudit(tenant.NewContext(ctx, "team-b"), AuditQuery{Limit: 10}); len(page.Items) != 1 { t.Fatalf("other tenant sees %d entries", len(page.Items)) }
+}
@@ -0,0 +1,42 @@
+package store
+
+import (
+	"context"
+	"sync"
+
+	"app/internal/tenant"
+)
+
+// MemoryCaptures is the in-memory CaptureStore: a ring of the last few
+// captures.
+type MemoryCaptures struct {
+	mu   sync.Mutex
+	ring []Capture
+	next int // where the next capture goes
+	full bool
+}
+
+// NewMemoryCaptures keeps the last max captures.
+func NewMemoryCaptures(max int) *MemoryCaptures { return &MemoryCaptures{ring: make([]Capture, max)} }
+
+func (s *MemoryCaptures) SaveCapture(ctx context.Context, c *Capture) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ring[s.next] = *c
+	s.next = (s.next + 1) % len(s.ring)
+	s.full = s.full || s.next == 0
+	return nil
+}
+
+func (s *MemoryCaptures) Capture(ctx context.Context, requestID string) (Capture, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tid, n := tenant.FromContext(ctx), s.next
+	if s.full { n = len(s.ring) }
+	// Newest first: back from next, wrapping around.
+	for i := 1; i <= n; i++ {
+		c := s.ring[(s.next-i+len(s.ring))%len(s.ring)]
+		if c.RequestID == requestID && c.Tenant == tid { return c, nil }
+	}
+	return Capture{}, ErrNotFound
+}
@@ -0,0 +1,36 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+
+	"app/internal/tenant"
+)
+
+func TestMemoryCaptures(t *testing.T) { testCaptures(t, NewMemoryCaptures(10), 10) }
+
+// testCaptures checks that s finds the latest capture of a request ID in
+// the caller's tenant, and only keeps about keep of them.
+func testCaptures(t *testing.T, s CaptureStore, keep int) {
+	t.Helper()
+	ctx := context.Background()
+	save := func(c Capture) {
+		t.Helper()
+		if err := s.SaveCapture(ctx, &c); err != nil { t.Fatal(err) }
+	}
+	save(Capture{RequestID: "r1", Tenant: tenant.Default, Status: 500})
+	save(Capture{RequestID: "r1", Tenant: tenant.Default, Status: 200, ResponseBody: `{"ok":true}`})
+	save(Capture{RequestID: "r2", Tenant: "team-b", Status: 201})
+	save(Capture{RequestID: "r3", Status: 401})
+
+	if c, err := s.Capture(ctx, "r1"); err != nil || c.Status != 200 || c.ResponseBody != `{"ok":true}` { t.Fatalf("latest r1: %+v, %v", c, err) }
+	if _, err := s.Capture(ctx, "r2"); !errors.Is(err, ErrNotFound) { t.Errorf("another tenant's capture: %v", err) }
+	if c, err := s.Capture(tenant.NewContext(ctx, "team-b"), "r2"); err != nil || c.Status != 201 { t.Errorf("own capture: %+v, %v", c, err) }
+	if _, err := s.Capture(ctx, "r3"); !errors.Is(err, ErrNotFound) { t.Errorf("anonymous capture: %v", err) }
+
+	for i := range keep + 5 { save(Capture{RequestID: "x" + strconv.Itoa(i), Tenant: tenant.Default}) }
+	if _, err := s.Capture(ctx, "r1"); !errors.Is(err, ErrNotFound) { t.Errorf("r1 kept past %d more captures: %v", keep+5, err) }
+	if _, err := s.Capture(ctx, "x"+strconv.Itoa(keep+4)); err != nil { t.Errorf("the latest capture: %v", err) }
+}
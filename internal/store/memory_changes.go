@@ -0,0 +1,59 @@
+package store
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"app/internal/tenant"
+)
+
+// MemoryChangeLog is the in-memory ChangeLogStore. Inside a transaction of
+// MemoryNames, the changes are numbered and added once it commits, which
+// transactions do one at a time, and dropped with it.
+type MemoryChangeLog struct {
+	retention time.Duration
+
+	mu      sync.Mutex
+	changes map[string][]Change // per tenant, oldest first
+	last    map[string]int64    // per tenant
+}
+
+func NewMemoryChangeLog(retention time.Duration) *MemoryChangeLog {
+	return &MemoryChangeLog{retention: retention, changes: map[string][]Change{}, last: map[string]int64{}}
+}
+
+func (s *MemoryChangeLog) AppendChanges(ctx context.Context, cs []Change) error {
+	if len(cs) == 0 { return nil }
+	tid := tenant.FromContext(ctx)
+	added := slices.Clone(cs)
+	AfterCommit(ctx, func(context.Context) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		now := time.Now().UTC()
+		for i := range added {
+			s.last[tid]++
+			added[i].Seq, added[i].Tenant, added[i].At = s.last[tid], tid, added[i].At.UTC().Truncate(time.Millisecond)
+		}
+		changes := s.changes[tid]
+		expired := slices.IndexFunc(changes, func(c Change) bool { return now.Sub(c.At) < s.retention })
+		if expired < 0 { expired = len(changes) }
+		s.changes[tid] = append(changes[expired:], added...)
+	})
+	return nil
+}
+
+func (s *MemoryChangeLog) Changes(ctx context.Context, since int64, limit int) ([]Change, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	changes := s.changes[tenant.FromContext(ctx)]
+	i, _ := slices.BinarySearchFunc(changes, since+1, func(c Change, seq int64) int { return int(c.Seq - seq) })
+	return slices.Clone(changes[i:min(i+limit, len(changes))]), nil
+}
+
+func (s *MemoryChangeLog) LastChange(ctx context.Context) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.last[tenant.FromContext(ctx)], nil
+}
@@ -0,0 +1,45 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"app/internal/tenant"
+)
+
+func TestMemoryChangeLog(t *testing.T) {
+	s, names := NewMemoryChangeLog(3 * time.Hour), NewMemoryNames()
+	ctx, other := tenant.NewContext(context.Background(), "team-a"), tenant.NewContext(context.Background(), "team-b")
+	a, b := primitive.NewObjectID(), primitive.NewObjectID()
+	now := time.Now().Add(-2 * time.Hour)
+
+	if err := s.AppendChanges(ctx, []Change{{NameID: a, Type: "created", At: now}, {NameID: b, Type: "created", At: now}}); err != nil { t.Fatal(err) }
+	if err := s.AppendChanges(other, []Change{{NameID: b, Type: "created", At: now}}); err != nil { t.Fatal(err) }
+	// Changes are numbered as their transactions commit; rolled back, never.
+	err := names.InTransaction(ctx, func(ctx context.Context) error {
+		return s.AppendChanges(ctx, []Change{{NameID: a, Type: "deleted", At: now}})
+	})
+	if err != nil { t.Fatal(err) }
+	err = names.InTransaction(ctx, func(ctx context.Context) error {
+		if err := s.AppendChanges(ctx, []Change{{NameID: a, Type: "restored", At: now}}); err != nil { return err }
+		return errors.New("rolled back")
+	})
+	if err == nil { t.Fatal("the transaction committed") }
+
+	cs, err := s.Changes(ctx, 0, 10)
+	if err != nil { t.Fatal(err) }
+	if len(cs) != 3 || cs[0].Seq != 1 || cs[1].NameID != b || cs[2].Seq != 3 || cs[2].Type != "deleted" || cs[2].Tenant != "team-a" { t.Fatalf("changes: %+v", cs) }
+	if cs, _ := s.Changes(ctx, 1, 1); len(cs) != 1 || cs[0].Seq != 2 { t.Fatalf("after 1: %+v", cs) }
+	if cs, _ := s.Changes(ctx, 3, 10); len(cs) != 0 { t.Fatalf("after the last: %+v", cs) }
+	if last, _ := s.LastChange(ctx); last != 3 { t.Fatalf("last: %d", last) }
+	if last, _ := s.LastChange(other); last != 1 { t.Fatalf("the other tenant's last: %d", last) }
+
+	// The changes past their retention are dropped, but not their numbers.
+	s.retention = time.Hour
+	if err := s.AppendChanges(ctx, []Change{{NameID: b, Type: "updated", At: time.Now()}}); err != nil { t.Fatal(err) }
+	if cs, _ := s.Changes(ctx, 0, 10); len(cs) != 1 || cs[0].Seq != 4 { t.Fatalf("after the retention: %+v", cs) }
+}
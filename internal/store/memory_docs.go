@@ -0,0 +1,86 @@
+package store
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"app/internal/tenant"
+)
+
+// MemoryDocs is the in-memory DocStore.
+type MemoryDocs struct {
+	mu   sync.RWMutex
+	docs map[string]map[primitive.ObjectID]Doc // by collection, then ID
+}
+
+func NewMemoryDocs() *MemoryDocs { return &MemoryDocs{docs: map[string]map[primitive.ObjectID]Doc{}} }
+
+func cloneDoc(d Doc) Doc { d.Fields = maps.Clone(d.Fields); return d }
+
+func (s *MemoryDocs) CreateDoc(ctx context.Context, collection string, d *Doc) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	d.ID, d.Tenant, d.CreatedAt, d.UpdatedAt, d.Version = primitive.NewObjectID(), tenant.FromContext(ctx), now, now, 1
+	if s.docs[collection] == nil { s.docs[collection] = map[primitive.ObjectID]Doc{} }
+	s.docs[collection][d.ID] = cloneDoc(*d)
+	return nil
+}
+
+// get returns document id of the tenant in ctx; s.mu must be held.
+func (s *MemoryDocs) get(ctx context.Context, collection string, id primitive.ObjectID) (Doc, bool) {
+	d, ok := s.docs[collection][id]
+	return d, ok && d.Tenant == tenant.FromContext(ctx)
+}
+
+func (s *MemoryDocs) GetDoc(ctx context.Context, collection string, id primitive.ObjectID) (Doc, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	d, ok := s.get(ctx, collection, id)
+	if !ok { return Doc{}, ErrNotFound }
+	return cloneDoc(d), nil
+}
+
+func (s *MemoryDocs) ListDocs(ctx context.Context, collection string, after primitive.ObjectID, limit int) (DocPage, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	tid := tenant.FromContext(ctx)
+	var docs []Doc
+	for _, d := range s.docs[collection] {
+		if d.Tenant == tid && strings.Compare(d.ID.Hex(), after.Hex()) > 0 { docs = append(docs, d) }
+	}
+	slices.SortFunc(docs, func(a, b Doc) int { return strings.Compare(a.ID.Hex(), b.ID.Hex()) })
+	page := DocPage{Items: []Doc{}}
+	for i, d := range docs {
+		if i == limit { page.Next = page.Items[i-1].ID.Hex(); break }
+		page.Items = append(page.Items, cloneDoc(d))
+	}
+	return page, nil
+}
+
+func (s *MemoryDocs) UpdateDoc(ctx context.Context, collection string, id primitive.ObjectID, fields map[string]any, ifVersion int64) (Doc, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d, ok := s.get(ctx, collection, id)
+	if !ok { return Doc{}, ErrNotFound }
+	if ifVersion != AnyVersion && d.Version != ifVersion { return Doc{}, ErrVersionMismatch }
+	d.Fields, d.UpdatedAt, d.Version = maps.Clone(fields), time.Now().UTC().Truncate(time.Millisecond), d.Version+1
+	s.docs[collection][id] = d
+	return cloneDoc(d), nil
+}
+
+func (s *MemoryDocs) DeleteDoc(ctx context.Context, collection string, id primitive.ObjectID, ifVersion int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d, ok := s.get(ctx, collection, id)
+	if !ok { return ErrNotFound }
+	if ifVersion != AnyVersion && d.Version != ifVersion { return ErrVersionMismatch }
+	delete(s.docs[collection], id)
+	return nil
+}
@@ -0,0 +1,53 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"app/internal/tenant"
+)
+
+func TestMemoryDocs(t *testing.T) { testDocs(t, NewMemoryDocs()) }
+
+// testDocs checks a resource's documents round-trip, page in creation
+// order and stay apart by collection and tenant.
+func testDocs(t *testing.T, s DocStore) {
+	t.Helper()
+	a, b := tenant.NewContext(context.Background(), "team-a"), tenant.NewContext(context.Background(), "team-b")
+	at := time.Date(2026, 10, 15, 9, 30, 0, 0, time.UTC)
+	d := Doc{Fields: map[string]any{"address": "bob@example.com", "primary": true, "rank": int64(2), "verified_at": at}}
+	if err := s.CreateDoc(a, "emails", &d); err != nil { t.Fatal(err) }
+	if d.ID.IsZero() || d.Version != 1 || d.Tenant != "team-a" || d.CreatedAt.IsZero() { t.Fatalf("created %+v", d) }
+
+	got, err := s.GetDoc(a, "emails", d.ID)
+	if err != nil { t.Fatal(err) }
+	want, _ := json.Marshal(d)
+	if raw, _ := json.Marshal(got); string(raw) != string(want) { t.Fatalf("got %s, want %s", raw, want) }
+	if _, err := s.GetDoc(b, "emails", d.ID); !errors.Is(err, ErrNotFound) { t.Fatalf("other tenant: %v", err) }
+	if _, err := s.GetDoc(a, "addresses", d.ID); !errors.Is(err, ErrNotFound) { t.Fatalf("other collection: %v", err) }
+
+	for _, addr := range []string{"b@example.com", "c@example.com"} {
+		if err := s.CreateDoc(a, "emails", &Doc{Fields: map[string]any{"address": addr}}); err != nil { t.Fatal(err) }
+	}
+	if err := s.CreateDoc(b, "emails", &Doc{Fields: map[string]any{"address": "x@example.com"}}); err != nil { t.Fatal(err) }
+	page, err := s.ListDocs(a, "emails", primitive.NilObjectID, 2)
+	if err != nil || len(page.Items) != 2 || page.Items[0].ID != d.ID || page.Next != page.Items[1].ID.Hex() { t.Fatalf("first page %+v, %v", page, err) }
+	next, _ := primitive.ObjectIDFromHex(page.Next)
+	page, err = s.ListDocs(a, "emails", next, 2)
+	if err != nil || len(page.Items) != 1 || page.Items[0].Fields["address"] != "c@example.com" || page.Next != "" { t.Fatalf("last page %+v, %v", page, err) }
+
+	up, err := s.UpdateDoc(a, "emails", d.ID, map[string]any{"address": "bob@example.org"}, 1)
+	if err != nil || up.Version != 2 || up.Fields["address"] != "bob@example.org" || up.Fields["primary"] != nil || !up.CreatedAt.Equal(d.CreatedAt) { t.Fatalf("update %+v, %v", up, err) }
+	if _, err := s.UpdateDoc(a, "emails", d.ID, map[string]any{}, 1); !errors.Is(err, ErrVersionMismatch) { t.Fatalf("stale update: %v", err) }
+	if _, err := s.UpdateDoc(b, "emails", d.ID, map[string]any{}, AnyVersion); !errors.Is(err, ErrNotFound) { t.Fatalf("other tenant's update: %v", err) }
+
+	if err := s.DeleteDoc(a, "emails", d.ID, 1); !errors.Is(err, ErrVersionMismatch) { t.Fatalf("stale delete: %v", err) }
+	if err := s.DeleteDoc(b, "emails", d.ID, AnyVersion); !errors.Is(err, ErrNotFound) { t.Fatalf("other tenant's delete: %v", err) }
+	if err := s.DeleteDoc(a, "emails", d.ID, 2); err != nil { t.Fatal(err) }
+	if _, err := s.GetDoc(a, "emails", d.ID); !errors.Is(err, ErrNotFound) { t.Fatalf("deleted: %v", err) }
+}
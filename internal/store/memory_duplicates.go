@@ -0,0 +1,19 @@
+package store
+
+import (
+	"context"
+
+	"app/internal/tenant"
+)
+
+func (s *MemoryNames) Duplicates(ctx context.Context, q DuplicateQuery) ([]DuplicateCluster, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	tid, groups := tenant.FromContext(ctx), map[string][]Name{}
+	for _, n := range s.names {
+		if n.Tenant != tid || n.DeletedAt != nil { continue }
+		key := NormalizeName(n.Name)
+		groups[key] = append(groups[key], clone(n))
+	}
+	return clusters(groups, q)
+}
@@ -0,0 +1,80 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+
+	"app/internal/tenant"
+)
+
+func TestMemoryDuplicates(t *testing.T) { testDuplicates(t, NewMemoryNames()) }
+
+// testDuplicates checks the clusters s finds among one tenant's names.
+func testDuplicates(t *testing.T, s interface {
+	NameStore
+	DuplicateStore
+}) {
+	t.Helper()
+	ctx := context.Background()
+	ids := map[string]string{}
+	for _, name := range []string{"José Núñez", "jose  nunez", "JOSE NUNEZ", "Jon", "John", "Joan", "Zed", "Ada", "Ada Lovelace"} {
+		n := Name{Name: name}
+		if err := s.Create(ctx, &n); err != nil { t.Fatal(err) }
+		ids[name] = n.ID.Hex()
+	}
+	if err := s.Create(tenant.NewContext(ctx, "team-b"), &Name{Name: "ZED"}); err != nil { t.Fatal(err) }
+	page, _ := s.List(ctx, ListOptions{Limit: 10, NamePrefix: "JOSE"})
+	if err := s.SoftDelete(ctx, page.Items[0].ID, AnyVersion); err != nil { t.Fatal(err) }
+
+	names := func(cs []DuplicateCluster) []string {
+		var out []string
+		for _, c := range cs {
+			var ns []string
+			for _, n := range c.Names { ns = append(ns, n.Name) }
+			out = append(out, fmt.Sprintf("%s=%q", c.Key, ns))
+		}
+		return out
+	}
+	cs, err := s.Duplicates(ctx, DuplicateQuery{})
+	if err != nil { t.Fatal(err) }
+	if got, want := names(cs), []string{`jose nunez=["José Núñez" "jose  nunez"]`}; !slices.Equal(got, want) { t.Errorf("exact: %v, want %v", got, want) }
+
+	cs, err = s.Duplicates(ctx, DuplicateQuery{Distance: 1})
+	if err != nil { t.Fatal(err) }
+	want := []string{`joan=["Joan" "John" "Jon"]`, `jose nunez=["José Núñez" "jose  nunez"]`}
+	if got := names(cs); !slices.Equal(got, want) { t.Errorf("distance 1: %v, want %v", got, want) }
+	if cs, _ := s.Duplicates(ctx, DuplicateQuery{Distance: 1, Limit: 1}); len(cs) != 1 || cs[0].Key != "joan" { t.Errorf("limit 1: %v", names(cs)) }
+	if cs, _ := s.Duplicates(tenant.NewContext(ctx, "team-c"), DuplicateQuery{Distance: 2}); len(cs) != 0 { t.Errorf("empty tenant: %v", names(cs)) }
+}
+
+func TestNormalizeName(t *testing.T) {
+	for in, want := range map[string]string{"José  Núñez": "jose nunez", " Straße ": "strasse", "ÅSA": "asa", "Zoë": "zoe"} {
+		if got := NormalizeName(in); got != want { t.Errorf("NormalizeName(%q) = %q, want %q", in, got, want) }
+	}
+}
+
+func TestWithinEdits(t *testing.T) {
+	for _, c := range []struct {
+		a, b string
+		n    int
+		want bool
+	}{
+		{"kitten", "sitting", 3, true},
+		{"kitten", "sitting", 2, false},
+		{"", "abc", 3, true},
+		{"abc", "", 2, false},
+		{"same", "same", 0, true},
+		{"ab", "ba", 1, false},
+	} {
+		if got := withinEdits([]rune(c.a), []rune(c.b), c.n); got != c.want { t.Errorf("withinEdits(%q, %q, %d) = %v", c.a, c.b, c.n, got) }
+	}
+}
+
+func TestDuplicatesTooMany(t *testing.T) {
+	groups := map[string][]Name{}
+	for i := range MaxFuzzyKeys + 1 { groups[fmt.Sprint(i)] = []Name{{}} }
+	if _, err := clusters(groups, DuplicateQuery{Distance: 1}); !errors.Is(err, ErrTooManyNames) { t.Fatalf("got %v", err) }
+}
@@ -0,0 +1,46 @@
+package store
+
+import (
+	"context"
+	"slices"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"app/internal/tenant"
+)
+
+// MemoryHistory is the in-memory HistoryStore.
+type MemoryHistory struct {
+	mu       sync.RWMutex
+	versions map[primitive.ObjectID][]Name // by name ID, in any order
+}
+
+func NewMemoryHistory() *MemoryHistory {
+	return &MemoryHistory{versions: map[primitive.ObjectID][]Name{}}
+}
+
+func (s *MemoryHistory) SaveVersions(ctx context.Context, ns []Name) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tid := tenant.FromContext(ctx)
+	for _, n := range ns {
+		kept := s.versions[n.ID]
+		if slices.ContainsFunc(kept, func(k Name) bool { return k.Tenant == tid && k.Version == n.Version }) { continue }
+		n = clone(n)
+		n.Tenant = tid
+		s.versions[n.ID] = append(kept, n)
+	}
+	return nil
+}
+
+func (s *MemoryHistory) Versions(ctx context.Context, id primitive.ObjectID) ([]Name, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	tid, out := tenant.FromContext(ctx), []Name{}
+	for _, n := range s.versions[id] {
+		if n.Tenant == tid { out = append(out, clone(n)) }
+	}
+	slices.SortFunc(out, func(a, b Name) int { return int(b.Version - a.Version) })
+	return out, nil
+}
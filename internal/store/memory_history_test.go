@@ -0,0 +1,28 @@
+package store
+
+import (
+	"context"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"app/internal/tenant"
+)
+
+func TestMemoryHistory(t *testing.T) { testHistory(t, NewMemoryHistory()) }
+
+// testHistory checks that s keeps each version once and tenants apart.
+func testHistory(t *testing.T, s HistoryStore) {
+	t.Helper()
+	ctx := context.Background()
+	id := primitive.NewObjectID()
+	v1, v2 := Name{ID: id, Name: "alice", Version: 1}, Name{ID: id, Name: "alicia", Tags: []string{"vip"}, Version: 2}
+	if err := s.SaveVersions(ctx, []Name{v1}); err != nil { t.Fatal(err) }
+	if err := s.SaveVersions(ctx, []Name{v2, {ID: id, Name: "changed", Version: 1}}); err != nil { t.Fatalf("saving a kept version again: %v", err) }
+	if err := s.SaveVersions(tenant.NewContext(ctx, "team-b"), []Name{{ID: id, Name: "other", Version: 3}}); err != nil { t.Fatal(err) }
+
+	got, err := s.Versions(ctx, id)
+	if err != nil { t.Fatal(err) }
+	if len(got) != 2 || got[0].Name != "alicia" || got[0].Tags[0] != "vip" || got[1].Name != "alice" { t.Fatalf("versions %+v", got) }
+	if got, _ := s.Versions(ctx, primitive.NewObjectID()); len(got) != 0 { t.Fatalf("unknown name has %+v", got) }
+}
@@ -0,0 +1,44 @@
+package store
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryIdempotency is the in-memory IdempotencyStore. Expired keys are
+// dropped when they are next claimed.
+type MemoryIdempotency struct {
+	mu   sync.Mutex
+	keys map[string]IdempotencyRecord
+}
+
+func NewMemoryIdempotency() *MemoryIdempotency {
+	return &MemoryIdempotency{keys: map[string]IdempotencyRecord{}}
+}
+
+func (s *MemoryIdempotency) Claim(ctx context.Context, key, requestHash string, ttl time.Duration) (*IdempotencyRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now().UTC()
+	if rec, ok := s.keys[key]; ok && !rec.ExpiresAt.Before(now) { return &rec, nil }
+	s.keys[key] = IdempotencyRecord{Key: key, RequestHash: requestHash, ExpiresAt: now.Add(ttl)}
+	return nil, nil
+}
+
+func (s *MemoryIdempotency) Complete(ctx context.Context, key string, status int, headers map[string]string, body []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rec, ok := s.keys[key]
+	if !ok { return nil }
+	rec.Status, rec.Headers, rec.Body = status, headers, body
+	s.keys[key] = rec
+	return nil
+}
+
+func (s *MemoryIdempotency) Release(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.keys, key)
+	return nil
+}
@@ -0,0 +1,116 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"app/internal/tenant"
+)
+
+// MemoryJobs is the in-memory JobStore.
+type MemoryJobs struct {
+	mu   sync.Mutex
+	jobs []Job // oldest first
+}
+
+func NewMemoryJobs() *MemoryJobs { return &MemoryJobs{} }
+
+func (s *MemoryJobs) EnqueueJob(ctx context.Context, j *Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j.ID, j.Tenant, j.Status, j.CreatedAt = primitive.NewObjectID(), tenant.FromContext(ctx), JobQueued, time.Now().UTC().Truncate(time.Millisecond)
+	s.jobs = append(s.jobs, cloneJob(*j))
+	return nil
+}
+
+// find returns the index of job id, of tenant tid unless it is empty, or
+// -1. Callers hold mu.
+func (s *MemoryJobs) find(tid string, id primitive.ObjectID) int {
+	return slices.IndexFunc(s.jobs, func(j Job) bool { return j.ID == id && (tid == "" || j.Tenant == tid) })
+}
+
+func (s *MemoryJobs) Job(ctx context.Context, id primitive.ObjectID) (Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.find(tenant.FromContext(ctx), id)
+	if i < 0 { return Job{}, ErrNotFound }
+	j := cloneJob(s.jobs[i])
+	j.Input, j.Output = nil, nil
+	return j, nil
+}
+
+func (s *MemoryJobs) JobOutput(ctx context.Context, id primitive.ObjectID) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := s.find(tenant.FromContext(ctx), id)
+	if i < 0 { return nil, ErrNotFound }
+	return bytes.Clone(s.jobs[i].Output), nil
+}
+
+func (s *MemoryJobs) ClaimJob(ctx context.Context, lease time.Duration) (Job, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	i := slices.IndexFunc(s.jobs, func(j Job) bool { return claimable(j, now) })
+	if i < 0 { return Job{}, ErrNotFound }
+	j := &s.jobs[i]
+	until := now.Add(lease)
+	j.Status, j.LeaseUntil, j.StartedAt = JobRunning, &until, &now
+	j.Attempts++
+	return cloneJob(*j), nil
+}
+
+// claimable is ClaimJob's filter.
+func claimable(j Job, now time.Time) bool {
+	return j.Status == JobQueued || (j.Status == JobRunning && j.LeaseUntil != nil && j.LeaseUntil.Before(now))
+}
+
+// held returns the index of job j if no one has claimed it since j was.
+// Callers hold mu.
+func (s *MemoryJobs) held(j Job) (int, error) {
+	i := s.find("", j.ID)
+	if i < 0 { return i, ErrNotFound }
+	if s.jobs[i].Attempts != j.Attempts || s.jobs[i].Status != JobRunning { return i, ErrVersionMismatch }
+	return i, nil
+}
+
+func (s *MemoryJobs) RenewJob(ctx context.Context, j Job, lease time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i, err := s.held(j)
+	if err != nil { return err }
+	until := time.Now().UTC().Truncate(time.Millisecond).Add(lease)
+	s.jobs[i].LeaseUntil = &until
+	return nil
+}
+
+func (s *MemoryJobs) FinishJob(ctx context.Context, j Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i, err := s.held(j)
+	if err != nil { return err }
+	stored := &s.jobs[i]
+	stored.Status, stored.LeaseUntil = j.Status, nil
+	if j.Status == JobQueued { return nil }
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	stored.Result, stored.Output, stored.Error, stored.FinishedAt = bytes.Clone(j.Result), bytes.Clone(j.Output), j.Error, &now
+	return nil
+}
+
+func (s *MemoryJobs) PurgeJobs(ctx context.Context, before time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jobs = slices.DeleteFunc(s.jobs, func(j Job) bool { return j.FinishedAt != nil && j.FinishedAt.Before(before) })
+	return nil
+}
+
+// cloneJob copies j so callers and the store never share its bytes.
+func cloneJob(j Job) Job {
+	j.Input, j.Output, j.Result = bytes.Clone(j.Input), bytes.Clone(j.Output), bytes.Clone(j.Result)
+	return j
+}
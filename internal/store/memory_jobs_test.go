@@ -0,0 +1,60 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"app/internal/tenant"
+)
+
+func TestMemoryJobs(t *testing.T) { testJobs(t, NewMemoryJobs()) }
+
+// testJobs walks jobs through the queue: claimed in order, leases that
+// lapse, stale workers that can't finish, and the purge.
+func testJobs(t *testing.T, s JobStore) {
+	t.Helper()
+	ctx := context.Background()
+	a, b := tenant.NewContext(ctx, "team-a"), tenant.NewContext(ctx, "team-b")
+	first, second := Job{Type: "import", Params: "format=csv", Input: []byte("Alice\n")}, Job{Type: "export"}
+	if err := s.EnqueueJob(a, &first); err != nil { t.Fatal(err) }
+	if err := s.EnqueueJob(b, &second); err != nil { t.Fatal(err) }
+	if first.Status != JobQueued || first.Tenant != "team-a" { t.Fatalf("enqueued %+v", first) }
+
+	if got, err := s.Job(a, first.ID); err != nil || got.Status != JobQueued || got.Params != "format=csv" || got.Input != nil { t.Fatalf("job: %+v, %v", got, err) }
+	if _, err := s.Job(b, first.ID); !errors.Is(err, ErrNotFound) { t.Fatalf("job across tenants: %v", err) }
+
+	// Claims come oldest first, from any tenant.
+	claimed, err := s.ClaimJob(ctx, time.Hour)
+	if err != nil || claimed.ID != first.ID || claimed.Status != JobRunning || claimed.Attempts != 1 || string(claimed.Input) != "Alice\n" || claimed.Tenant != "team-a" { t.Fatalf("claim: %+v, %v", claimed, err) }
+	other, err := s.ClaimJob(ctx, -time.Second) // a lease that has already lapsed
+	if err != nil || other.ID != second.ID { t.Fatalf("second claim: %+v, %v", other, err) }
+	if _, err := s.ClaimJob(ctx, time.Hour); err != nil { t.Fatalf("claim after the lease lapsed: %v", err) }
+	if _, err := s.ClaimJob(ctx, time.Hour); !errors.Is(err, ErrNotFound) { t.Fatalf("claim from an empty queue: %v", err) }
+
+	// The worker whose lease lapsed has lost the job.
+	if err := s.RenewJob(ctx, other, time.Hour); !errors.Is(err, ErrVersionMismatch) { t.Fatalf("stale renew: %v", err) }
+	other.Status = JobSucceeded
+	if err := s.FinishJob(ctx, other); !errors.Is(err, ErrVersionMismatch) { t.Fatalf("stale finish: %v", err) }
+
+	if err := s.RenewJob(ctx, claimed, time.Hour); err != nil { t.Fatal(err) }
+	claimed.Status, claimed.Result, claimed.Output = JobSucceeded, []byte(`{"inserted":1}`), []byte("file")
+	if err := s.FinishJob(ctx, claimed); err != nil { t.Fatal(err) }
+	got, err := s.Job(a, first.ID)
+	if err != nil || got.Status != JobSucceeded || string(got.Result) != `{"inserted":1}` || got.FinishedAt == nil || got.LeaseUntil != nil || got.Output != nil { t.Fatalf("finished: %+v, %v", got, err) }
+	if out, err := s.JobOutput(a, first.ID); err != nil || string(out) != "file" { t.Fatalf("output: %q, %v", out, err) }
+	if err := s.FinishJob(ctx, claimed); !errors.Is(err, ErrVersionMismatch) { t.Fatalf("finish twice: %v", err) }
+
+	// Given back, a job is claimed again.
+	third := Job{Type: "export"}
+	_ = s.EnqueueJob(a, &third)
+	c, _ := s.ClaimJob(ctx, time.Hour)
+	c.Status = JobQueued
+	if err := s.FinishJob(ctx, c); err != nil { t.Fatal(err) }
+	if c, err = s.ClaimJob(ctx, time.Hour); err != nil || c.ID != third.ID || c.Attempts != 2 { t.Fatalf("claim after release: %+v, %v", c, err) }
+
+	if err := s.PurgeJobs(ctx, time.Now().Add(time.Minute)); err != nil { t.Fatal(err) }
+	if _, err := s.Job(a, first.ID); !errors.Is(err, ErrNotFound) { t.Fatalf("purged job: %v", err) }
+	if _, err := s.Job(a, third.ID); err != nil { t.Fatalf("unfinished job purged: %v", err) }
+}
@@ -0,0 +1,48 @@
+package store
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"app/internal/tenant"
+)
+
+// keyTaken reports whether another document of tid than except already has
+// n's UUID or slug. Callers hold mu.
+func (s *MemoryNames) keyTaken(tid string, n *Name, except primitive.ObjectID) bool {
+	for id, other := range s.names {
+		if other.Tenant != tid || id == except { continue }
+		if (n.UUID != "" && other.UUID == n.UUID) || (n.Slug != "" && other.Slug == n.Slug) { return true }
+	}
+	return false
+}
+
+func (s *MemoryNames) NameByKey(ctx context.Context, key string) (primitive.ObjectID, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	tid := tenant.FromContext(ctx)
+	for id, n := range s.names {
+		if n.Tenant == tid && key != "" && (n.UUID == key || n.Slug == key) { return id, nil }
+	}
+	return primitive.NilObjectID, ErrNotFound
+}
+
+func (s *MemoryNames) NameByName(ctx context.Context, name string) (Name, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	n, ok := s.named(tenant.FromContext(ctx), name)
+	if !ok { return Name{}, ErrNotFound }
+	return clone(n), nil
+}
+
+func (s *MemoryNames) SlugsTaken(ctx context.Context, slugs []string) (map[string]bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	tid, want, out := tenant.FromContext(ctx), map[string]bool{}, map[string]bool{}
+	for _, slug := range slugs { want[slug] = true }
+	for _, n := range s.names {
+		if n.Tenant == tid && n.Slug != "" && want[n.Slug] { out[n.Slug] = true }
+	}
+	return out, nil
+}
@@ -0,0 +1,50 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"slices"
+	"testing"
+
+	"app/internal/tenant"
+)
+
+func TestMemoryNameKeys(t *testing.T) { testNameKeys(t, NewMemoryNames()) }
+
+// testNameKeys checks that s finds names by their UUID, slug or name,
+// deleted or not, within the tenant, and keeps the keys unique there.
+func testNameKeys(t *testing.T, s interface {
+	NameStore
+	NameKeyStore
+}) {
+	t.Helper()
+	ctx, theirs := context.Background(), tenant.NewContext(context.Background(), "team-b")
+	alice := Name{Name: "Alice", Slug: "alice"}
+	bob := Name{Name: "Bob", UUID: "0190a6c2-7f2e-7b7a-8e1c-3f4d5e6f7a8b"}
+	for _, n := range []*Name{&alice, &bob, {Name: "Carol"}} {
+		if err := s.Create(ctx, n); err != nil { t.Fatal(err) }
+	}
+	if err := s.Create(theirs, &Name{Name: "Alice", Slug: "alice"}); err != nil { t.Fatalf("slug of another tenant: %v", err) }
+	if err := s.Create(ctx, &Name{Name: "Alicia", Slug: "alice"}); !errors.Is(err, ErrDuplicate) { t.Fatalf("taken slug: %v", err) }
+	if errs, err := s.CreateMany(ctx, []Name{{Name: "Bobby", UUID: bob.UUID}, {Name: "Dave"}}); err != nil || !errors.Is(errs[0], ErrDuplicate) || errs[1] != nil { t.Fatalf("taken UUID in a batch: %v, %v", errs, err) }
+
+	if got, _ := s.Get(ctx, alice.ID); got.Slug != "alice" { t.Fatalf("stored %+v", got) }
+	if err := s.SoftDelete(ctx, bob.ID, AnyVersion); err != nil { t.Fatal(err) }
+	for key, want := range map[string]Name{"alice": alice, bob.UUID: bob} {
+		if id, err := s.NameByKey(ctx, key); err != nil || id != want.ID { t.Errorf("NameByKey(%q) = %v, %v; want %v", key, id, err, want.ID) }
+	}
+	for _, key := range []string{"carol", "", "Alice"} {
+		if _, err := s.NameByKey(ctx, key); !errors.Is(err, ErrNotFound) { t.Errorf("NameByKey(%q): %v", key, err) }
+	}
+	if got, err := s.NameByName(ctx, "Bob"); err != nil || got.ID != bob.ID || got.DeletedAt == nil { t.Errorf("NameByName of a deleted name: %+v, %v", got, err) }
+	if _, err := s.NameByName(theirs, "Carol"); !errors.Is(err, ErrNotFound) { t.Errorf("NameByName in another tenant: %v", err) }
+	renamed := "Alicia"
+	if _, err := s.Patch(ctx, alice.ID, NamePatch{Name: &renamed}, AnyVersion); err != nil { t.Fatal(err) }
+	if got, _ := s.Get(ctx, alice.ID); got.Slug != "alice" { t.Errorf("slug after a rename: %q", got.Slug) }
+
+	taken, err := s.SlugsTaken(ctx, []string{"alice", "carol", "nope"})
+	if err != nil { t.Fatal(err) }
+	if got := slices.Collect(maps.Keys(taken)); !slices.Equal(got, []string{"alice"}) { t.Errorf("taken %v", got) }
+	if taken, _ := s.SlugsTaken(theirs, []string{"carol"}); len(taken) != 0 { t.Errorf("taken in another tenant %v", taken) }
+}
@@ -0,0 +1,38 @@
+package store
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryLeases is the in-memory LeaseStore, shared by the electors of one
+// process only.
+type MemoryLeases struct {
+	mu     sync.Mutex
+	leases map[string]Lease
+}
+
+func NewMemoryLeases() *MemoryLeases { return &MemoryLeases{leases: map[string]Lease{}} }
+
+func (s *MemoryLeases) AcquireLease(ctx context.Context, name, holder string, ttl time.Duration) (Lease, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	l, ok := s.leases[name]
+	if ok && l.Holder != holder && now.Before(l.ExpiresAt) { return l, nil }
+	if !ok || l.Holder != holder { l = Lease{Name: name, Holder: holder, Term: l.Term + 1, AcquiredAt: now} }
+	l.RenewedAt, l.ExpiresAt = now, now.Add(ttl)
+	s.leases[name] = l
+	return l, nil
+}
+
+func (s *MemoryLeases) ReleaseLease(ctx context.Context, name, holder string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if l, ok := s.leases[name]; ok && l.Holder == holder {
+		l.Holder, l.ExpiresAt = "", time.Now().UTC().Truncate(time.Millisecond)
+		s.leases[name] = l
+	}
+	return nil
+}
@@ -0,0 +1,25 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryLeases(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryLeases()
+	l, err := s.AcquireLease(ctx, "leader", "a", time.Minute)
+	if err != nil || l.Holder != "a" || l.Term != 1 || !l.ExpiresAt.After(time.Now()) { t.Fatalf("acquired %+v, %v", l, err) }
+	if l, _ = s.AcquireLease(ctx, "leader", "b", time.Minute); l.Holder != "a" { t.Fatalf("taken while held: %+v", l) }
+	renewed, _ := s.AcquireLease(ctx, "leader", "a", time.Minute)
+	if renewed.Term != 1 || !renewed.AcquiredAt.Equal(l.AcquiredAt) || renewed.ExpiresAt.Before(l.ExpiresAt) { t.Fatalf("renewed %+v", renewed) }
+	if other, _ := s.AcquireLease(ctx, "other", "b", time.Minute); other.Holder != "b" { t.Fatalf("another lease: %+v", other) }
+
+	if err := s.ReleaseLease(ctx, "leader", "b"); err != nil { t.Fatal(err) }
+	if l, _ = s.AcquireLease(ctx, "leader", "b", time.Minute); l.Holder != "a" { t.Fatalf("released by another: %+v", l) }
+	if err := s.ReleaseLease(ctx, "leader", "a"); err != nil { t.Fatal(err) }
+	if l, _ = s.AcquireLease(ctx, "leader", "b", time.Millisecond); l.Holder != "b" || l.Term != 2 { t.Fatalf("after release: %+v", l) }
+	time.Sleep(5 * time.Millisecond)
+	if l, _ = s.AcquireLease(ctx, "leader", "a", time.Minute); l.Holder != "a" || l.Term != 3 { t.Fatalf("after expiry: %+v", l) }
+}
@@ -0,0 +1,555 @@
+package store
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"maps"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"app/internal/tenant"
+)
+
+// memoryChangeLog is how many changes MemoryNames keeps for Watch to resume
+// from; older tokens get ErrResumeExpired, like a rolled-over oplog.
+const memoryChangeLog = 1000
+
+// MemoryNames is a NameStore held in process memory, for demos, CI and
+// tests. It follows the MongoDB store's semantics, but everything is lost
+// on restart and text search is a plain word match without stemming.
+type MemoryNames struct {
+	// gate is held by a transaction for its whole run, and by every other
+	// write for its own: they wait for each other.
+	gate sync.RWMutex
+
+	mu     sync.RWMutex
+	names  map[primitive.ObjectID]Name
+	events []NameEvent
+
+	// changes are the latest changes for Watch, oldest first; seq numbers
+	// them and notify is closed (and replaced) whenever one is added.
+	changes []memoryChange
+	seq     int64
+	notify  chan struct{}
+
+	// inTx is set while a transaction runs; its changes wait in pending
+	// until it commits.
+	inTx    bool
+	pending []memoryChange
+}
+
+type memoryChange struct {
+	seq    int64
+	tenant string
+	NameChange
+}
+
+func NewMemoryNames() *MemoryNames {
+	return &MemoryNames{names: map[primitive.ObjectID]Name{}, notify: make(chan struct{})}
+}
+
+// clone copies n so callers and the store never share tags or metadata.
+func clone(n Name) Name {
+	n.Tags = slices.Clone(n.Tags)
+	n.Metadata = maps.Clone(n.Metadata)
+	if n.DeletedAt != nil { d := *n.DeletedAt; n.DeletedAt = &d }
+	if n.ExpiresAt != nil { e := *n.ExpiresAt; n.ExpiresAt = &e }
+	return n
+}
+
+// taken reports whether another document of tid already uses name. Callers
+// hold mu.
+func (s *MemoryNames) taken(tid, name string, except primitive.ObjectID) bool {
+	for id, n := range s.names {
+		if n.Tenant == tid && n.Name == name && id != except { return true }
+	}
+	return false
+}
+
+// find returns document id if it belongs to tid. Callers hold mu.
+func (s *MemoryNames) find(tid string, id primitive.ObjectID) (Name, bool) {
+	n, ok := s.names[id]
+	return n, ok && n.Tenant == tid
+}
+
+// record logs a change to a document of tid for Watch, or holds it until
+// the transaction underway commits. Callers hold mu for writing.
+func (s *MemoryNames) record(tid, typ string, id primitive.ObjectID, n *Name) {
+	c := NameChange{Type: typ, ID: id}
+	if n != nil { doc := clone(*n); c.Name = &doc }
+	if s.inTx { s.pending = append(s.pending, memoryChange{tenant: tid, NameChange: c}); return }
+	s.publish(tid, c)
+}
+
+// publish numbers c and adds it to the log. Callers hold mu for writing.
+func (s *MemoryNames) publish(tid string, c NameChange) {
+	s.seq++
+	c.Token = strconv.FormatInt(s.seq, 10)
+	s.changes = append(s.changes, memoryChange{s.seq, tid, c})
+	// Reslice rather than shift: streams may be reading the old slice.
+	if len(s.changes) > memoryChangeLog { s.changes = s.changes[len(s.changes)-memoryChangeLog:] }
+	close(s.notify)
+	s.notify = make(chan struct{})
+}
+
+// insert stores a new document of tid. Callers hold mu for writing.
+func (s *MemoryNames) insert(tid string, n *Name, now time.Time) error {
+	if s.taken(tid, n.Name, primitive.NilObjectID) || s.keyTaken(tid, n, primitive.NilObjectID) { return ErrDuplicate }
+	stamp(n, tid, now)
+	s.names[n.ID] = clone(*n)
+	s.record(tid, "created", n.ID, n)
+	return nil
+}
+
+// admit lets a write in: at once if it belongs to the transaction underway,
+// once there is none otherwise. The write calls the func returned when done.
+func (s *MemoryNames) admit(ctx context.Context) func() {
+	if t := txFrom(ctx); t != nil && t.owner == s { return func() {} }
+	s.gate.RLock()
+	return s.gate.RUnlock
+}
+
+// InTransaction runs fn with the store to itself: other writes wait until
+// it's done, and if it fails the names and events are put back as they
+// were. Reads aren't held back, so they may see writes that end up rolled
+// back; Watch streams only get them once committed.
+func (s *MemoryNames) InTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if InTransaction(ctx) { return fn(ctx) }
+	s.gate.Lock()
+	defer s.gate.Unlock()
+
+	s.mu.Lock()
+	names, events := maps.Clone(s.names), len(s.events) // documents are replaced, never changed in place
+	s.inTx = true
+	s.mu.Unlock()
+
+	t := &tx{owner: s}
+	err := fn(withTx(ctx, t))
+
+	s.mu.Lock()
+	pending := s.pending
+	s.inTx, s.pending = false, nil
+	if err != nil {
+		s.names, s.events = names, s.events[:events]
+	} else {
+		for _, c := range pending { s.publish(c.tenant, c.NameChange) }
+	}
+	s.mu.Unlock()
+	if err == nil { t.committed(ctx) }
+	return err
+}
+
+func (s *MemoryNames) Create(ctx context.Context, n *Name) error {
+	defer s.admit(ctx)()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.create(tenant.FromContext(ctx), n)
+}
+
+// create inserts n with its "created" event. Callers hold mu for writing.
+func (s *MemoryNames) create(tid string, n *Name) error {
+	now := time.Now().UTC()
+	if err := s.insert(tid, n, now); err != nil { return err }
+	s.events = append(s.events, NameEvent{ID: primitive.NewObjectID(), NameID: n.ID, Tenant: tid, Type: "created", Name: n.Name, At: now})
+	return nil
+}
+
+// named returns the document of tid called name, soft-deleted or not.
+// Callers hold mu.
+func (s *MemoryNames) named(tid, name string) (Name, bool) {
+	for _, n := range s.names {
+		if n.Tenant == tid && n.Name == name { return n, true }
+	}
+	return Name{}, false
+}
+
+func (s *MemoryNames) CreateIfAbsent(ctx context.Context, n *Name) (bool, error) {
+	defer s.admit(ctx)()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tid := tenant.FromContext(ctx)
+	if existing, ok := s.named(tid, n.Name); ok { *n = clone(existing); return false, nil }
+	return true, s.create(tid, n)
+}
+
+func (s *MemoryNames) Upsert(ctx context.Context, n Name, ifVersion int64) (*Name, Name, error) {
+	defer s.admit(ctx)()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tid := tenant.FromContext(ctx)
+	existing, ok := s.named(tid, n.Name)
+	switch {
+	case !ok && ifVersion != AnyVersion:
+		return nil, Name{}, ErrNotFound
+	case !ok:
+		if err := s.create(tid, &n); err != nil { return nil, Name{}, err }
+		return nil, n, nil
+	case existing.DeletedAt != nil:
+		return nil, Name{}, ErrDuplicate
+	}
+	after, err := s.patch(tid, existing.ID, replacing(n), ifVersion)
+	if err != nil { return nil, Name{}, err }
+	before := clone(existing)
+	return &before, after, nil
+}
+
+func (s *MemoryNames) Get(ctx context.Context, id primitive.ObjectID) (Name, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	n, ok := s.find(tenant.FromContext(ctx), id)
+	if !ok || n.DeletedAt != nil { return Name{}, ErrNotFound }
+	return clone(n), nil
+}
+
+func (s *MemoryNames) Lookup(ctx context.Context, ids []primitive.ObjectID) (map[primitive.ObjectID]Name, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	tid, out := tenant.FromContext(ctx), map[primitive.ObjectID]Name{}
+	for _, id := range ids {
+		if n, ok := s.find(tid, id); ok { out[id] = clone(n) }
+	}
+	return out, nil
+}
+
+// matching returns what listFilter would match in tid, in opts' sort
+// order. Callers hold mu.
+func (s *MemoryNames) matching(tid string, opts ListOptions) []Name {
+	var out []Name
+	for _, n := range s.names {
+		switch {
+		case n.Tenant != tid:
+			continue
+		case opts.OnlyDeleted && n.DeletedAt == nil:
+			continue
+		case !opts.OnlyDeleted && !opts.IncludeDeleted && n.DeletedAt != nil:
+			continue
+		case !strings.HasPrefix(n.Name, opts.NamePrefix):
+			continue
+		case opts.Match != "" && cmp.Or(n.NameNormalized, n.Name) != opts.Match:
+			continue
+		case opts.CreatedBy != "" && n.CreatedBy != opts.CreatedBy:
+			continue
+		case !hasTags(opts, n):
+			continue
+		}
+		out = append(out, clone(n))
+	}
+	order := Collation{Locale: opts.Locale}.compare()
+	slices.SortFunc(out, func(a, b Name) int {
+		c := compareAt(opts, order, a, Cursor{Value: b.Name, ID: b.ID})
+		if opts.Desc { c = -c }
+		return c
+	})
+	return out
+}
+
+// compareAt orders n against a cursor position in ascending sort order,
+// comparing names with order.
+func compareAt(opts ListOptions, order func(a, b string) int, n Name, c Cursor) int {
+	if opts.SortBy == "name" {
+		if d := order(n.Name, c.Value); d != 0 { return d }
+	}
+	return bytes.Compare(n.ID[:], c.ID[:])
+}
+
+func (s *MemoryNames) List(ctx context.Context, opts ListOptions) (Page, error) {
+	s.mu.RLock()
+	all := s.matching(tenant.FromContext(ctx), opts)
+	s.mu.RUnlock()
+
+	page := Page{Items: []Name{}, Total: int64(len(all))}
+	if opts.After != nil {
+		order := Collation{Locale: opts.Locale}.compare()
+		all = slices.DeleteFunc(all, func(n Name) bool {
+			c := compareAt(opts, order, n, *opts.After)
+			return (!opts.Desc && c <= 0) || (opts.Desc && c >= 0)
+		})
+	}
+	all = all[min(int64(len(all)), opts.Offset):]
+	if int64(len(all)) > opts.Limit {
+		page.Items = append(page.Items, all[:opts.Limit]...)
+		page.Next = cursorFor(opts, page.Items[opts.Limit-1])
+	} else {
+		page.Items = append(page.Items, all...)
+	}
+	return page, nil
+}
+
+func (s *MemoryNames) Each(ctx context.Context, opts ListOptions, fn func(Name) error) error {
+	s.mu.RLock()
+	all := s.matching(tenant.FromContext(ctx), ListOptions{SortBy: opts.SortBy, Desc: opts.Desc, NamePrefix: opts.NamePrefix, Match: opts.Match, Tags: opts.Tags, AnyTag: opts.AnyTag, IncludeDeleted: opts.IncludeDeleted, OnlyDeleted: opts.OnlyDeleted, Locale: opts.Locale})
+	s.mu.RUnlock()
+
+	for _, n := range all {
+		if err := ctx.Err(); err != nil { return err }
+		if err := fn(n); err != nil { return err }
+	}
+	return nil
+}
+
+func (s *MemoryNames) Update(ctx context.Context, id primitive.ObjectID, n Name, ifVersion int64) (Name, error) {
+	return s.Patch(ctx, id, NamePatch{Name: &n.Name, Tags: &n.Tags, Metadata: &n.Metadata, ExpiresAt: Expiry{Set: true, At: n.ExpiresAt}, NameNormalized: &n.NameNormalized}, ifVersion)
+}
+
+// live returns the non-deleted document id of tid if it is at ifVersion.
+// Callers hold mu.
+func (s *MemoryNames) live(tid string, id primitive.ObjectID, ifVersion int64) (Name, error) {
+	n, ok := s.find(tid, id)
+	if !ok || n.DeletedAt != nil { return n, ErrNotFound }
+	if ifVersion != AnyVersion && n.Version != ifVersion { return n, ErrVersionMismatch }
+	return n, nil
+}
+
+func (s *MemoryNames) Patch(ctx context.Context, id primitive.ObjectID, p NamePatch, ifVersion int64) (Name, error) {
+	defer s.admit(ctx)()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.patch(tenant.FromContext(ctx), id, p, ifVersion)
+}
+
+// patch applies p to document id of tid. Callers hold mu for writing.
+func (s *MemoryNames) patch(tid string, id primitive.ObjectID, p NamePatch, ifVersion int64) (Name, error) {
+	n, err := s.live(tid, id, ifVersion)
+	if err != nil { return Name{}, err }
+
+	if p.Name != nil {
+		if s.taken(tid, *p.Name, id) { return Name{}, ErrDuplicate }
+		n.Name = *p.Name
+	}
+	if p.NameNormalized != nil { n.NameNormalized = *p.NameNormalized }
+	if p.UUID != nil || p.Slug != nil {
+		keys := Name{UUID: n.UUID, Slug: n.Slug}
+		if p.UUID != nil { keys.UUID = *p.UUID }
+		if p.Slug != nil { keys.Slug = *p.Slug }
+		if s.keyTaken(tid, &keys, id) { return Name{}, ErrDuplicate }
+		n.UUID, n.Slug = keys.UUID, keys.Slug
+	}
+	if p.CreatedBy != nil { n.CreatedBy = *p.CreatedBy }
+	if p.Tags != nil {
+		n.Tags = nil
+		if len(*p.Tags) > 0 { n.Tags = slices.Clone(*p.Tags) }
+	}
+	if p.Metadata != nil {
+		n.Metadata = nil
+		if len(*p.Metadata) > 0 { n.Metadata = maps.Clone(*p.Metadata) }
+	}
+	if p.ExpiresAt.Set {
+		n.ExpiresAt = nil
+		if p.ExpiresAt.At != nil { e := *p.ExpiresAt.At; n.ExpiresAt = &e }
+	}
+	n.UpdatedAt = time.Now().UTC().Truncate(time.Millisecond)
+	n.Version++
+	s.names[id] = n
+	s.record(tid, "updated", id, &n)
+	return clone(n), nil
+}
+
+func (s *MemoryNames) SoftDelete(ctx context.Context, id primitive.ObjectID, ifVersion int64) error {
+	defer s.admit(ctx)()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tid := tenant.FromContext(ctx)
+	n, err := s.live(tid, id, ifVersion)
+	if err != nil { return err }
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	n.DeletedAt = &now
+	n.Version++
+	s.names[id] = n
+	s.record(tid, "deleted", id, &n)
+	return nil
+}
+
+func (s *MemoryNames) HardDelete(ctx context.Context, id primitive.ObjectID, ifVersion int64) error {
+	defer s.admit(ctx)()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tid := tenant.FromContext(ctx)
+	n, ok := s.find(tid, id)
+	if !ok { return ErrNotFound }
+	if ifVersion != AnyVersion && n.Version != ifVersion { return ErrVersionMismatch }
+	delete(s.names, id)
+	s.record(tid, "removed", id, nil)
+	return nil
+}
+
+func (s *MemoryNames) Restore(ctx context.Context, id primitive.ObjectID) (Name, error) {
+	defer s.admit(ctx)()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tid := tenant.FromContext(ctx)
+	n, ok := s.find(tid, id)
+	if !ok || n.DeletedAt == nil { return Name{}, ErrNotFound }
+	n.DeletedAt = nil
+	n.Version++
+	s.names[id] = n
+	s.record(tid, "restored", id, &n)
+	return clone(n), nil
+}
+
+func (s *MemoryNames) Events(ctx context.Context, id primitive.ObjectID) ([]NameEvent, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	tid, out := tenant.FromContext(ctx), []NameEvent{}
+	for _, e := range s.events {
+		if e.NameID == id && e.Tenant == tid { out = append(out, e) }
+	}
+	return out, nil
+}
+
+// Search approximates MongoDB's text index with textScore.
+func (s *MemoryNames) Search(ctx context.Context, opts SearchOptions) ([]SearchHit, error) {
+	var match func(n Name) (float64, bool)
+	switch opts.Mode {
+	case SearchPrefix:
+		prefix := strings.ToLower(opts.Query)
+		match = func(n Name) (float64, bool) { return 0, strings.HasPrefix(strings.ToLower(cmp.Or(n.NameNormalized, n.Name)), prefix) }
+	case SearchRegex:
+		re, err := regexp.Compile(opts.Query)
+		if err != nil { return nil, err }
+		match = func(n Name) (float64, bool) { return 0, re.MatchString(n.Name) }
+	default:
+		terms := words(opts.Query)
+		match = func(n Name) (float64, bool) { score := textScore(terms, n); return score, score > 0 }
+	}
+
+	tid := tenant.FromContext(ctx)
+	s.mu.RLock()
+	out := []SearchHit{}
+	for _, n := range s.names {
+		if n.Tenant != tid || n.DeletedAt != nil { continue }
+		if score, ok := match(n); ok { out = append(out, SearchHit{Name: clone(n), Score: score}) }
+	}
+	s.mu.RUnlock()
+
+	return rankHits(out, opts.Limit), nil
+}
+
+func (s *MemoryNames) SetNormalized(ctx context.Context, forms map[primitive.ObjectID]string) error {
+	defer s.admit(ctx)()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tid := tenant.FromContext(ctx)
+	for id, form := range forms {
+		if n, ok := s.find(tid, id); ok { n.NameNormalized = form; s.names[id] = n }
+	}
+	return nil
+}
+
+func (s *MemoryNames) CreateMany(ctx context.Context, ns []Name) ([]error, error) {
+	defer s.admit(ctx)()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	errs := make([]error, len(ns))
+	now, tid := time.Now().UTC(), tenant.FromContext(ctx)
+	for i := range ns {
+		if errs[i] = s.insert(tid, &ns[i], now); errs[i] != nil { continue }
+		s.events = append(s.events, NameEvent{ID: primitive.NewObjectID(), NameID: ns[i].ID, Tenant: tid, Type: "created", Name: ns[i].Name, At: now})
+	}
+	return errs, nil
+}
+
+func (s *MemoryNames) DeleteMany(ctx context.Context, ids []primitive.ObjectID, hard bool) (map[primitive.ObjectID]bool, error) {
+	defer s.admit(ctx)()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	existed := map[primitive.ObjectID]bool{}
+	now, tid := time.Now().UTC().Truncate(time.Millisecond), tenant.FromContext(ctx)
+	for _, id := range ids {
+		n, ok := s.find(tid, id)
+		if !ok || existed[id] || (!hard && n.DeletedAt != nil) { continue }
+		existed[id] = true
+		if hard {
+			delete(s.names, id)
+			s.record(tid, "removed", id, nil)
+			continue
+		}
+		n.DeletedAt = &now
+		n.Version++
+		s.names[id] = n
+		s.record(tid, "deleted", id, &n)
+	}
+	return existed, nil
+}
+
+func (s *MemoryNames) ExistingNames(ctx context.Context, names []string) (map[string]bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	tid, out := tenant.FromContext(ctx), map[string]bool{}
+	for _, n := range s.names {
+		if n.Tenant == tid && slices.Contains(names, n.Name) { out[n.Name] = true }
+	}
+	return out, nil
+}
+
+func (s *MemoryNames) InsertMany(ctx context.Context, ns []Name) ([]error, error) {
+	defer s.admit(ctx)()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	errs := make([]error, len(ns))
+	now, tid := time.Now().UTC(), tenant.FromContext(ctx)
+	for i := range ns { errs[i] = s.insert(tid, &ns[i], now) }
+	return errs, nil
+}
+
+// Watch resumes from the change log. Tokens are sequence numbers, shared by
+// all tenants; a stream skips the changes of the others.
+func (s *MemoryNames) Watch(ctx context.Context, after string) (ChangeStream, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	tid := tenant.FromContext(ctx)
+	if after == "" { return &memoryChangeStream{s: s, tenant: tid, pos: s.seq}, nil }
+
+	pos, err := strconv.ParseInt(after, 10, 64)
+	oldest := s.seq + 1
+	if len(s.changes) > 0 { oldest = s.changes[0].seq }
+	if err != nil || pos > s.seq || pos < oldest-1 { return nil, ErrResumeExpired }
+	return &memoryChangeStream{s: s, tenant: tid, pos: pos}, nil
+}
+
+type memoryChangeStream struct {
+	s      *MemoryNames
+	tenant string
+	pos    int64 // seq of the last change seen
+}
+
+func (m *memoryChangeStream) Next(ctx context.Context) (NameChange, error) {
+	for {
+		m.s.mu.RLock()
+		changes, notify := m.s.changes, m.s.notify
+		m.s.mu.RUnlock()
+
+		i, _ := slices.BinarySearchFunc(changes, m.pos+1, func(c memoryChange, seq int64) int { return int(c.seq - seq) })
+		if i < len(changes) {
+			if changes[i].seq != m.pos+1 { return NameChange{}, ErrResumeExpired } // fell behind the log
+			m.pos = changes[i].seq
+			if changes[i].tenant != m.tenant { continue }
+			return changes[i].NameChange, nil
+		}
+		select {
+		case <-notify:
+		case <-ctx.Done():
+			return NameChange{}, ctx.Err()
+		}
+	}
+}
+
+func (m *memoryChangeStream) Close(ctx context.Context) error { return nil }
+
+func (s *MemoryNames) DueNames(ctx context.Context, q DueQuery) ([]Name, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var out []Name
+	for _, n := range s.names {
+		if bytes.Compare(n.ID[:], q.After[:]) > 0 && q.due(n) { out = append(out, clone(n)) }
+	}
+	slices.SortFunc(out, func(a, b Name) int { return bytes.Compare(a.ID[:], b.ID[:]) })
+	if len(out) > q.Limit { out = out[:q.Limit] }
+	return out, nil
+}
@@ -0,0 +1,371 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"app/internal/tenant"
+)
+
+func TestMemoryNamesList(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryNames()
+	for _, name := range []string{"carol", "alice", "bob", "dave"} {
+		if err := s.Create(ctx, &Name{Name: name}); err != nil { t.Fatal(err) }
+	}
+	if err := s.Create(ctx, &Name{Name: "bob"}); !errors.Is(err, ErrDuplicate) { t.Fatalf("duplicate: %v", err) }
+
+	// Walk the name-sorted list two at a time with cursors.
+	opts := ListOptions{Limit: 2, SortBy: "name"}
+	var got []string
+	for {
+		page, err := s.List(ctx, opts)
+		if err != nil { t.Fatal(err) }
+		if page.Total != 4 { t.Fatalf("total %d", page.Total) }
+		for _, n := range page.Items { got = append(got, n.Name) }
+		if page.Next == "" { break }
+		if opts.After, err = DecodeCursor(page.Next); err != nil { t.Fatal(err) }
+	}
+	if want := "alice bob carol dave"; strings.Join(got, " ") != want { t.Fatalf("paged %q, want %q", strings.Join(got, " "), want) }
+
+	page, _ := s.List(ctx, ListOptions{Limit: 10, SortBy: "name", Desc: true, Offset: 1})
+	if got := names(page.Items); got != "carol bob alice" { t.Fatalf("desc with offset: %q", got) }
+}
+
+func TestMemoryNamesVersions(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryNames()
+	n := Name{Name: "alice"}
+	if err := s.Create(ctx, &n); err != nil { t.Fatal(err) }
+	if n.Version != 1 { t.Fatalf("new version %d", n.Version) }
+
+	if _, err := s.Update(ctx, n.ID, Name{Name: "alicia"}, 2); !errors.Is(err, ErrVersionMismatch) { t.Fatalf("stale update: %v", err) }
+	n, err := s.Update(ctx, n.ID, Name{Name: "alicia"}, 1)
+	if err != nil || n.Version != 2 { t.Fatalf("update: %v, version %d", err, n.Version) }
+
+	if err := s.SoftDelete(ctx, n.ID, 1); !errors.Is(err, ErrVersionMismatch) { t.Fatalf("stale delete: %v", err) }
+	if err := s.SoftDelete(ctx, n.ID, AnyVersion); err != nil { t.Fatal(err) }
+	if _, err := s.Get(ctx, n.ID); !errors.Is(err, ErrNotFound) { t.Fatalf("get deleted: %v", err) }
+	if trash, _ := s.List(ctx, ListOptions{Limit: 10, OnlyDeleted: true}); len(trash.Items) != 1 { t.Fatalf("trash %v", trash.Items) }
+	if n, err = s.Restore(ctx, n.ID); err != nil || n.Version != 4 { t.Fatalf("restore: %v, version %d", err, n.Version) }
+}
+
+func TestMemoryNamesWatch(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	s := NewMemoryNames()
+	n := Name{Name: "alice"}
+	_ = s.Create(ctx, &n)
+
+	cs, err := s.Watch(ctx, "")
+	if err != nil { t.Fatal(err) }
+	_, _ = s.Patch(ctx, n.ID, NamePatch{Tags: &[]string{"vip"}}, AnyVersion)
+	_ = s.HardDelete(ctx, n.ID, AnyVersion)
+
+	c1, err := cs.Next(ctx)
+	if err != nil || c1.Type != "updated" || c1.Name.Tags[0] != "vip" { t.Fatalf("first change: %+v, %v", c1, err) }
+	c2, err := cs.Next(ctx)
+	if err != nil || c2.Type != "removed" || c2.Name != nil { t.Fatalf("second change: %+v, %v", c2, err) }
+
+	// Resuming after the first change replays the second.
+	cs, err = s.Watch(ctx, c1.Token)
+	if err != nil { t.Fatal(err) }
+	if c, err := cs.Next(ctx); err != nil || c.Token != c2.Token { t.Fatalf("resumed: %+v, %v", c, err) }
+
+	if _, err := s.Watch(ctx, "99"); !errors.Is(err, ErrResumeExpired) { t.Fatalf("future token: %v", err) }
+}
+
+func TestMemoryNamesTransaction(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	s := NewMemoryNames()
+	n := Name{Name: "alice"}
+	_ = s.Create(ctx, &n)
+	cs, err := s.Watch(ctx, "")
+	if err != nil { t.Fatal(err) }
+
+	// A failure rolls back the writes before it, and what waited for the commit.
+	after := 0
+	err = s.InTransaction(ctx, func(ctx context.Context) error {
+		if !InTransaction(ctx) { t.Fatal("not in the transaction") }
+		if _, err := s.Patch(ctx, n.ID, NamePatch{Tags: &[]string{"vip"}}, 1); err != nil { return err }
+		AfterCommit(ctx, func(context.Context) { after++ })
+		return s.Create(ctx, &Name{Name: "alice"})
+	})
+	if !errors.Is(err, ErrDuplicate) { t.Fatalf("transaction: %v", err) }
+	if got, _ := s.Get(ctx, n.ID); got.Version != 1 || got.Tags != nil { t.Fatalf("rolled back to %+v", got) }
+	if evs, _ := s.Events(ctx, n.ID); len(evs) != 1 { t.Fatalf("events after rollback: %+v", evs) }
+
+	bob := Name{Name: "bob"}
+	err = s.InTransaction(ctx, func(ctx context.Context) error {
+		AfterCommit(ctx, func(context.Context) { after++ })
+		if err := s.Create(ctx, &bob); err != nil { return err }
+		return s.SoftDelete(ctx, n.ID, 1)
+	})
+	if err != nil || after != 1 { t.Fatalf("transaction: %v, %d after commit", err, after) }
+	if _, err := s.Get(ctx, n.ID); !errors.Is(err, ErrNotFound) { t.Fatalf("alice: %v", err) }
+
+	// Watch sees only the committed changes.
+	c1, err := cs.Next(ctx)
+	if err != nil || c1.Type != "created" || c1.Name.Name != "bob" { t.Fatalf("first change: %+v, %v", c1, err) }
+	c2, err := cs.Next(ctx)
+	if err != nil || c2.Type != "deleted" || c2.ID != n.ID { t.Fatalf("second change: %+v, %v", c2, err) }
+}
+
+func TestMemoryNamesLocale(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryNames()
+	for _, name := range []string{"öl", "Zebra", "apple", "Émile", "eve"} {
+		if err := s.Create(ctx, &Name{Name: name}); err != nil { t.Fatal(err) }
+	}
+	list := func(opts ListOptions) string {
+		opts.SortBy = "name"
+		var got []string
+		for {
+			page, err := s.List(ctx, opts)
+			if err != nil { t.Fatal(err) }
+			got = append(got, names(page.Items))
+			if page.Next == "" { return strings.Join(got, " ") }
+			if opts.After, err = DecodeCursor(page.Next); err != nil { t.Fatal(err) }
+		}
+	}
+	for locale, want := range map[string]string{
+		"":       "Zebra apple eve Émile öl",
+		"simple": "Zebra apple eve Émile öl",
+		"en":     "apple Émile eve öl Zebra",
+		"sv":     "apple Émile eve Zebra öl", // ö comes after z in Swedish
+	} {
+		if got := list(ListOptions{Limit: 2, Locale: locale}); got != want { t.Errorf("locale %q: %q, want %q", locale, got, want) }
+	}
+}
+
+func TestCollation(t *testing.T) {
+	for _, l := range []string{"simple", "fr", "de_AT", "zh_Hant", "de@collation=phonebook"} {
+		if !ValidLocale(l) { t.Errorf("%q is a locale", l) }
+	}
+	for _, l := range []string{"", "French", "fr-CA", "de@phonebook", "xx_$"} {
+		if ValidLocale(l) { t.Errorf("%q is no locale", l) }
+	}
+	for strength, want := range map[int]int{1: 0, 2: -1, 3: -1} {
+		cmp := Collation{Locale: "fr", Strength: strength}.compare()
+		if got := cmp("jose", "José"); got != want { t.Errorf("strength %d: jose vs José = %d, want %d", strength, got, want) }
+	}
+	if got := (Collation{Locale: "fr", Strength: 2}).compare()("jose", "Jose"); got != 0 { t.Errorf("strength 2 told jose from Jose") }
+}
+
+func TestMemoryNamesTenants(t *testing.T) { testTenants(t, NewMemoryNames()) }
+
+func TestMemoryNamesTagFilter(t *testing.T) { testTagFilter(t, NewMemoryNames()) }
+
+// testTagFilter checks listing by tag in both modes.
+func testTagFilter(t *testing.T, s NameStore) {
+	t.Helper()
+	ctx := context.Background()
+	for name, tags := range map[string][]string{"alice": {"vip", "core"}, "bob": {"vip"}, "carol": {"core"}, "dave": nil} {
+		if err := s.Create(ctx, &Name{Name: name, Tags: tags}); err != nil { t.Fatal(err) }
+	}
+	list := func(opts ListOptions) string {
+		t.Helper()
+		opts.Limit, opts.SortBy = 10, "name"
+		page, err := s.List(ctx, opts)
+		if err != nil { t.Fatal(err) }
+		if page.Total != int64(len(page.Items)) { t.Fatalf("total %d of %d", page.Total, len(page.Items)) }
+		return names(page.Items)
+	}
+	if got := list(ListOptions{Tags: []string{"vip"}}); got != "alice bob" { t.Fatalf("vip: %q", got) }
+	if got := list(ListOptions{Tags: []string{"vip", "core"}}); got != "alice" { t.Fatalf("vip and core: %q", got) }
+	if got := list(ListOptions{Tags: []string{"vip", "core"}, AnyTag: true}); got != "alice bob carol" { t.Fatalf("vip or core: %q", got) }
+	if got := list(ListOptions{Tags: []string{"VIP"}}); got != "" { t.Fatalf("tags are case-sensitive: %q", got) }
+
+	var each []string
+	_ = s.Each(ctx, ListOptions{SortBy: "name", Tags: []string{"core"}}, func(n Name) error { each = append(each, n.Name); return nil })
+	if got := strings.Join(each, " "); got != "alice carol" { t.Fatalf("each core: %q", got) }
+}
+
+func TestMemoryNamesDue(t *testing.T) { testDueNames(t, NewMemoryNames()) }
+
+// testDueNames sets, clears and keeps expiries through the writes, and
+// checks which names DueNames finds, across tenants and in batches.
+func testDueNames(t *testing.T, s interface{ NameStore; ExpiryStore }) {
+	t.Helper()
+	a, b := tenant.NewContext(context.Background(), "team-a"), tenant.NewContext(context.Background(), "team-b")
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	past, future := now.Add(-time.Hour), now.Add(time.Hour)
+	gone, later, kept, cleared := Name{Name: "gone", ExpiresAt: &past}, Name{Name: "later", ExpiresAt: &future}, Name{Name: "kept"}, Name{Name: "cleared", ExpiresAt: &past}
+	for _, n := range []*Name{&gone, &later, &kept} {
+		if err := s.Create(a, n); err != nil { t.Fatal(err) }
+	}
+	if err := s.Create(b, &cleared); err != nil { t.Fatal(err) }
+	if got, _ := s.Get(a, gone.ID); got.ExpiresAt == nil || !got.ExpiresAt.Equal(past) { t.Fatalf("stored expiry %v", got.ExpiresAt) }
+
+	// Patches leave the expiry alone unless they set it; null clears it.
+	if n, err := s.Patch(b, cleared.ID, NamePatch{Tags: &[]string{"x"}}, AnyVersion); err != nil || n.ExpiresAt == nil { t.Fatalf("patch without expiry: %+v, %v", n, err) }
+	if n, err := s.Patch(b, cleared.ID, NamePatch{ExpiresAt: Expiry{Set: true}}, AnyVersion); err != nil || n.ExpiresAt != nil { t.Fatalf("clearing patch: %+v, %v", n, err) }
+	if err := s.SoftDelete(a, kept.ID, AnyVersion); err != nil { t.Fatal(err) }
+
+	due := func(q DueQuery) string {
+		t.Helper()
+		q.ExpiredBy, q.Limit = now, 10
+		got, err := s.DueNames(context.Background(), q)
+		if err != nil { t.Fatal(err) }
+		return names(got)
+	}
+	if got := due(DueQuery{}); got != "gone" { t.Fatalf("expired: %q", got) }
+	if got := due(DueQuery{DeletedBefore: now.Add(time.Minute)}); got != "gone kept" { t.Fatalf("expired or deleted: %q", got) }
+	if got := due(DueQuery{DeletedBefore: now.Add(-time.Minute)}); got != "gone" { t.Fatalf("deleted too recently: %q", got) }
+	if got := due(DueQuery{DeletedBefore: now.Add(time.Minute), After: gone.ID}); got != "kept" { t.Fatalf("next batch: %q", got) }
+	if got, _ := s.DueNames(context.Background(), DueQuery{ExpiredBy: now, DeletedBefore: now.Add(time.Minute), Limit: 1}); names(got) != "gone" { t.Fatalf("limit: %q", names(got)) }
+}
+
+// testTenants checks that s keeps two tenants' names apart.
+func testTenants(t *testing.T, s NameStore) {
+	t.Helper()
+	a, b := tenant.NewContext(context.Background(), "team-a"), tenant.NewContext(context.Background(), "team-b")
+	na, nb := Name{Name: "alice"}, Name{Name: "alice", CreatedBy: "bob"}
+	if err := s.Create(a, &na); err != nil { t.Fatal(err) }
+	if err := s.Create(b, &nb); err != nil { t.Fatalf("same name in another tenant: %v", err) }
+
+	if _, err := s.Get(b, na.ID); !errors.Is(err, ErrNotFound) { t.Fatalf("get across tenants: %v", err) }
+	if docs, _ := s.Lookup(b, []primitive.ObjectID{na.ID, nb.ID}); len(docs) != 1 { t.Fatalf("lookup across tenants: %v", docs) }
+	if _, err := s.Patch(b, na.ID, NamePatch{Tags: &[]string{"x"}}, AnyVersion); !errors.Is(err, ErrNotFound) { t.Fatalf("patch across tenants: %v", err) }
+	if err := s.HardDelete(b, na.ID, AnyVersion); !errors.Is(err, ErrNotFound) { t.Fatalf("delete across tenants: %v", err) }
+	if existed, _ := s.DeleteMany(b, []primitive.ObjectID{na.ID}, false); existed[na.ID] { t.Fatal("bulk delete across tenants") }
+	if events, _ := s.Events(b, na.ID); len(events) != 0 { t.Fatalf("events across tenants: %+v", events) }
+
+	page, err := s.List(a, ListOptions{Limit: 10})
+	if err != nil || page.Total != 1 || page.Items[0].ID != na.ID { t.Fatalf("list: %+v, %v", page, err) }
+	if hits, _ := s.Search(a, SearchOptions{Query: "alice", Limit: 10}); len(hits) != 1 || hits[0].ID != na.ID { t.Fatalf("search: %+v", hits) }
+	if taken, _ := s.ExistingNames(b, []string{"alice", "bob"}); !taken["alice"] || taken["bob"] { t.Fatalf("existing: %v", taken) }
+	if page, _ := s.List(context.Background(), ListOptions{Limit: 10}); page.Total != 0 { t.Fatalf("default tenant sees %d names", page.Total) }
+	if page, _ := s.List(b, ListOptions{Limit: 10, CreatedBy: "bob"}); page.Total != 1 || page.Items[0].CreatedBy != "bob" { t.Fatalf("bob's: %+v", page) }
+	if page, _ := s.List(a, ListOptions{Limit: 10, CreatedBy: "bob"}); page.Total != 0 { t.Fatalf("bob's in another tenant: %+v", page) }
+}
+
+func TestMemoryNamesUpsert(t *testing.T) { testUpsert(t, NewMemoryNames()) }
+
+// testUpsert checks CreateIfAbsent and Upsert: that they create once, even
+// racing, and otherwise answer with or update the name already there.
+func testUpsert(t *testing.T, s NameStore) {
+	t.Helper()
+	ctx := context.Background()
+	const racers = 8
+	var wg sync.WaitGroup
+	results := make(chan Name, racers)
+	var createdCount atomic.Int32
+	for range racers {
+		wg.Go(func() {
+			n := Name{Name: "alice", Tags: []string{"first"}}
+			created, err := s.CreateIfAbsent(ctx, &n)
+			if err != nil { t.Error(err); return }
+			if created { createdCount.Add(1) }
+			results <- n
+		})
+	}
+	wg.Wait()
+	close(results)
+	if createdCount.Load() != 1 { t.Fatalf("created %d times", createdCount.Load()) }
+	var alice Name
+	for n := range results {
+		if alice.ID.IsZero() { alice = n }
+		if n.ID != alice.ID || n.Version != 1 { t.Fatalf("racers got %+v and %+v", alice, n) }
+	}
+	if events, _ := s.Events(ctx, alice.ID); len(events) != 1 { t.Fatalf("events %+v", events) }
+
+	expires := time.Now().UTC().Add(time.Hour).Truncate(time.Millisecond)
+	before, after, err := s.Upsert(ctx, Name{Name: "alice", Metadata: map[string]any{"team": "core"}, ExpiresAt: &expires}, AnyVersion)
+	if err != nil || before == nil || !slices.Equal(before.Tags, []string{"first"}) { t.Fatalf("before %+v, %v", before, err) }
+	if after.ID != alice.ID || after.Version != 2 || after.Tags != nil || after.Metadata["team"] != "core" || !after.ExpiresAt.Equal(expires) { t.Fatalf("after %+v", after) }
+	if got, _ := s.Get(ctx, alice.ID); got.Version != 2 || got.Tags != nil || got.Metadata["team"] != "core" { t.Fatalf("stored %+v", got) }
+
+	if _, _, err := s.Upsert(ctx, Name{Name: "alice"}, 1); !errors.Is(err, ErrVersionMismatch) { t.Fatalf("stale version: %v", err) }
+	if _, after, err := s.Upsert(ctx, Name{Name: "alice", Tags: []string{"x"}}, 2); err != nil || after.Version != 3 { t.Fatalf("current version: %+v, %v", after, err) }
+	if _, _, err := s.Upsert(ctx, Name{Name: "bob"}, 1); !errors.Is(err, ErrNotFound) { t.Fatalf("missing with a version: %v", err) }
+
+	before, bob, err := s.Upsert(ctx, Name{Name: "bob", Tags: []string{"new"}}, AnyVersion)
+	if err != nil || before != nil || bob.ID.IsZero() || bob.Version != 1 || !slices.Equal(bob.Tags, []string{"new"}) { t.Fatalf("created %+v, %+v, %v", before, bob, err) }
+	if got, err := s.Get(ctx, bob.ID); err != nil || got.Version != 1 || got.CreatedAt.IsZero() { t.Fatalf("stored %+v, %v", got, err) }
+	if events, _ := s.Events(ctx, bob.ID); len(events) != 1 || events[0].Type != "created" { t.Fatalf("events %+v", events) }
+
+	// A name in the trash is neither brought back nor shadowed.
+	if err := s.SoftDelete(ctx, bob.ID, AnyVersion); err != nil { t.Fatal(err) }
+	if _, _, err := s.Upsert(ctx, Name{Name: "bob"}, AnyVersion); !errors.Is(err, ErrDuplicate) { t.Fatalf("upsert of a deleted name: %v", err) }
+	n := Name{Name: "bob"}
+	if created, err := s.CreateIfAbsent(ctx, &n); err != nil || created || n.ID != bob.ID || n.DeletedAt == nil { t.Fatalf("create of a deleted name: %v, %+v, %v", created, n, err) }
+
+	theirs := Name{Name: "alice"}
+	if created, err := s.CreateIfAbsent(tenant.NewContext(ctx, "team-b"), &theirs); err != nil || !created || theirs.ID == alice.ID { t.Fatalf("another tenant: %v, %v", created, err) }
+}
+
+func TestMemoryNamesNormalized(t *testing.T) { testNormalized(t, NewMemoryNames()) }
+
+// testNormalized checks that s keeps the normalized form it is given
+// through writes, matches and prefix-searches on it, falling back to the
+// name of those without one, and sets it without a new version.
+func testNormalized(t *testing.T, s interface {
+	NameStore
+	NormalizedStore
+}) {
+	t.Helper()
+	ctx := context.Background()
+	alice, bob := Name{Name: "aLICE", NameNormalized: "Alice"}, Name{Name: "Bob"}
+	for _, n := range []*Name{&alice, &bob} {
+		if err := s.Create(ctx, n); err != nil { t.Fatal(err) }
+	}
+	for match, want := range map[string]string{"Alice": "aLICE", "Bob": "Bob", "aLICE": ""} {
+		if page, err := s.List(ctx, ListOptions{Limit: 10, Match: match}); err != nil || names(page.Items) != want { t.Errorf("match %q: %q, %v", match, names(page.Items), err) }
+	}
+	if hits, err := s.Search(ctx, SearchOptions{Query: "al", Mode: SearchPrefix}); err != nil || len(hits) != 1 || hits[0].Name.ID != alice.ID { t.Fatalf("prefix: %+v, %v", hits, err) }
+
+	form := "Alicia"
+	if n, err := s.Patch(ctx, alice.ID, NamePatch{Name: &[]string{"alicia"}[0], NameNormalized: &form}, AnyVersion); err != nil || n.NameNormalized != "Alicia" { t.Fatalf("patched %+v, %v", n, err) }
+	if n, err := s.Update(ctx, alice.ID, Name{Name: "ALICIA"}, AnyVersion); err != nil || n.NameNormalized != "" { t.Fatalf("updated without a form: %+v, %v", n, err) }
+
+	if err := s.SetNormalized(ctx, map[primitive.ObjectID]string{alice.ID: "Alicia", bob.ID: "Bob"}); err != nil { t.Fatal(err) }
+	if got, _ := s.Get(ctx, alice.ID); got.NameNormalized != "Alicia" || got.Version != 3 { t.Fatalf("set %+v", got) }
+	if page, _ := s.List(ctx, ListOptions{Limit: 10, Match: "Alicia"}); names(page.Items) != "ALICIA" { t.Fatalf("match after set: %q", names(page.Items)) }
+	if err := s.SetNormalized(tenant.NewContext(ctx, "team-b"), map[primitive.ObjectID]string{bob.ID: "Robert"}); err != nil { t.Fatal(err) }
+	if got, _ := s.Get(ctx, bob.ID); got.NameNormalized != "Bob" { t.Fatalf("set by another tenant: %+v", got) }
+}
+
+func TestMemoryNamesPatchKeys(t *testing.T) { testPatchKeys(t, NewMemoryNames()) }
+
+// testPatchKeys checks that s writes the owner and keys a restore patches
+// in, and keeps the keys unique.
+func testPatchKeys(t *testing.T, s NameStore) {
+	t.Helper()
+	ctx := context.Background()
+	alice, bob := Name{Name: "Alice", Slug: "alice"}, Name{Name: "Bob", Slug: "bob"}
+	for _, n := range []*Name{&alice, &bob} {
+		if err := s.Create(ctx, n); err != nil { t.Fatal(err) }
+	}
+	owner, id, slug := "u1", "0190a6c2-7f2e-7b7a-8e1c-3f4d5e6f7a8b", "alice-smith"
+	if n, err := s.Patch(ctx, alice.ID, NamePatch{CreatedBy: &owner, UUID: &id, Slug: &slug}, AnyVersion); err != nil || n.CreatedBy != owner || n.UUID != id || n.Slug != slug { t.Fatalf("patched %+v, %v", n, err) }
+	if _, err := s.Patch(ctx, bob.ID, NamePatch{Slug: &slug}, AnyVersion); err != ErrDuplicate { t.Fatalf("taken slug: %v", err) }
+	none := ""
+	if n, err := s.Patch(ctx, alice.ID, NamePatch{UUID: &none, CreatedBy: &none}, AnyVersion); err != nil || n.UUID != "" || n.CreatedBy != "" || n.Slug != slug { t.Fatalf("cleared %+v, %v", n, err) }
+}
+
+func TestMemoryNamesWatchTenant(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	s := NewMemoryNames()
+	cs, err := s.Watch(tenant.NewContext(ctx, "team-a"), "")
+	if err != nil { t.Fatal(err) }
+	_ = s.Create(tenant.NewContext(ctx, "team-b"), &Name{Name: "bob"})
+	_ = s.Create(tenant.NewContext(ctx, "team-a"), &Name{Name: "alice"})
+	if c, err := cs.Next(ctx); err != nil || c.Name.Name != "alice" { t.Fatalf("change: %+v, %v", c, err) }
+}
+
+func names(ns []Name) string {
+	var out []string
+	for _, n := range ns { out = append(out, n.Name) }
+	return strings.Join(out, " ")
+}
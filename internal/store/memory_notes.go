@@ -0,0 +1,77 @@
+package store
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"app/internal/tenant"
+)
+
+// MemoryNotes is the in-memory NoteStore. It joins notes to the names of a
+// MemoryNames.
+type MemoryNotes struct {
+	names *MemoryNames
+
+	mu    sync.RWMutex
+	notes map[primitive.ObjectID][]Note // by name ID, oldest first
+}
+
+func NewMemoryNotes(names *MemoryNames) *MemoryNotes {
+	return &MemoryNotes{names: names, notes: map[primitive.ObjectID][]Note{}}
+}
+
+func (s *MemoryNotes) CreateNote(ctx context.Context, n *Note) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n.ID, n.Tenant, n.CreatedAt = primitive.NewObjectID(), tenant.FromContext(ctx), time.Now().UTC().Truncate(time.Millisecond)
+	s.notes[n.NameID] = append(s.notes[n.NameID], *n)
+	return nil
+}
+
+func (s *MemoryNotes) Notes(ctx context.Context, nameID primitive.ObjectID) ([]Note, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.of(tenant.FromContext(ctx), nameID), nil
+}
+
+// of returns the notes of tenant tid about nameID; s.mu must be held.
+func (s *MemoryNotes) of(tid string, nameID primitive.ObjectID) []Note {
+	notes := []Note{}
+	for _, n := range s.notes[nameID] {
+		if n.Tenant == tid { notes = append(notes, n) }
+	}
+	return notes
+}
+
+func (s *MemoryNotes) HasNotes(ctx context.Context, nameIDs ...primitive.ObjectID) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	tid := tenant.FromContext(ctx)
+	for _, id := range nameIDs {
+		if len(s.of(tid, id)) > 0 { return true, nil }
+	}
+	return false, nil
+}
+
+func (s *MemoryNotes) DeleteNotes(ctx context.Context, nameIDs ...primitive.ObjectID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tid := tenant.FromContext(ctx)
+	for _, id := range nameIDs {
+		s.notes[id] = slices.DeleteFunc(s.notes[id], func(n Note) bool { return n.Tenant == tid })
+		if len(s.notes[id]) == 0 { delete(s.notes, id) }
+	}
+	return nil
+}
+
+func (s *MemoryNotes) NameWithNotes(ctx context.Context, id primitive.ObjectID) (NameWithNotes, error) {
+	n, err := s.names.Get(ctx, id)
+	if err != nil { return NameWithNotes{}, err }
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return NameWithNotes{Name: n, Notes: s.of(n.Tenant, id)}, nil
+}
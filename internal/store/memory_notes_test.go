@@ -0,0 +1,46 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"app/internal/tenant"
+)
+
+func TestMemoryNotes(t *testing.T) {
+	names := NewMemoryNames()
+	testNotes(t, names, NewMemoryNotes(names))
+}
+
+// testNotes checks notes are listed and joined to their name, oldest first,
+// and kept apart by tenant.
+func testNotes(t *testing.T, names NameStore, s NoteStore) {
+	t.Helper()
+	a, b := tenant.NewContext(context.Background(), "team-a"), tenant.NewContext(context.Background(), "team-b")
+	alice, bob := Name{Name: "Alice"}, Name{Name: "Bob"}
+	for _, n := range []*Name{&alice, &bob} {
+		if err := names.Create(a, n); err != nil { t.Fatal(err) }
+	}
+	for _, body := range []string{"first", "second"} {
+		if err := s.CreateNote(a, &Note{NameID: alice.ID, Body: body, Author: "u1"}); err != nil { t.Fatal(err) }
+	}
+	if err := s.CreateNote(b, &Note{NameID: alice.ID, Body: "not team-a's"}); err != nil { t.Fatal(err) }
+
+	notes, err := s.Notes(a, alice.ID)
+	if err != nil || len(notes) != 2 || notes[0].Body != "first" || notes[1].Author != "u1" || notes[0].NameID != alice.ID || notes[0].CreatedAt.IsZero() { t.Fatalf("notes %+v, %v", notes, err) }
+	if has, err := s.HasNotes(a, bob.ID, alice.ID); err != nil || !has { t.Fatalf("has notes: %v, %v", has, err) }
+	if has, err := s.HasNotes(a, bob.ID); err != nil || has { t.Fatalf("bob has notes: %v, %v", has, err) }
+
+	got, err := s.NameWithNotes(a, alice.ID)
+	if err != nil || got.Name.Name != "Alice" || got.Version != 1 || len(got.Notes) != 2 || got.Notes[1].Body != "second" || got.Notes[0].ID != notes[0].ID { t.Fatalf("expanded %+v, %v", got, err) }
+	if got, err := s.NameWithNotes(a, bob.ID); err != nil || got.Notes == nil || len(got.Notes) != 0 { t.Fatalf("expanded without notes %+v, %v", got, err) }
+	if _, err := s.NameWithNotes(b, alice.ID); !errors.Is(err, ErrNotFound) { t.Fatalf("other tenant's name: %v", err) }
+	if _, err := s.NameWithNotes(a, primitive.NewObjectID()); !errors.Is(err, ErrNotFound) { t.Fatalf("missing name: %v", err) }
+
+	if err := s.DeleteNotes(a, alice.ID); err != nil { t.Fatal(err) }
+	if has, _ := s.HasNotes(a, alice.ID); has { t.Fatal("notes left after delete") }
+	if notes, _ := s.Notes(b, alice.ID); len(notes) != 1 { t.Fatalf("other tenant's notes deleted: %+v", notes) }
+}
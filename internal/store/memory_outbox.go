@@ -0,0 +1,71 @@
+package store
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"app/internal/tenant"
+)
+
+// MemoryOutbox is the in-memory OutboxStore. Inside a transaction of
+// MemoryNames, the events are added once it commits, and dropped with it.
+type MemoryOutbox struct {
+	mu     sync.Mutex
+	events []OutboxEvent // oldest first
+}
+
+func NewMemoryOutbox() *MemoryOutbox { return &MemoryOutbox{} }
+
+func (s *MemoryOutbox) AddToOutbox(ctx context.Context, es []OutboxEvent) error {
+	tid := tenant.FromContext(ctx)
+	added := make([]OutboxEvent, len(es))
+	for i := range es {
+		e := &es[i]
+		e.ID, e.Tenant, e.At = primitive.NewObjectID(), tid, e.At.UTC().Truncate(time.Millisecond)
+		e.LeaseUntil = e.At
+		added[i] = cloneOutboxEvent(*e)
+	}
+	AfterCommit(ctx, func(context.Context) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.events = append(s.events, added...)
+	})
+	return nil
+}
+
+func (s *MemoryOutbox) ClaimOutbox(ctx context.Context, lease time.Duration) (OutboxEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	i := slices.IndexFunc(s.events, func(e OutboxEvent) bool { return e.PublishedAt == nil && !e.LeaseUntil.After(now) })
+	if i < 0 { return OutboxEvent{}, ErrNotFound }
+	s.events[i].LeaseUntil = now.Add(lease)
+	return cloneOutboxEvent(s.events[i]), nil
+}
+
+func (s *MemoryOutbox) MarkPublished(ctx context.Context, id primitive.ObjectID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := slices.IndexFunc(s.events, func(e OutboxEvent) bool { return e.ID == id })
+	if i < 0 { return ErrNotFound }
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	s.events[i].PublishedAt = &now
+	return nil
+}
+
+func (s *MemoryOutbox) PurgeOutbox(ctx context.Context, before time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = slices.DeleteFunc(s.events, func(e OutboxEvent) bool { return e.PublishedAt != nil && e.PublishedAt.Before(before) })
+	return nil
+}
+
+// cloneOutboxEvent copies e so callers and the store never share its name.
+func cloneOutboxEvent(e OutboxEvent) OutboxEvent {
+	if e.Name != nil { n := clone(*e.Name); e.Name = &n }
+	return e
+}
@@ -0,0 +1,65 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"app/internal/tenant"
+)
+
+func TestMemoryOutbox(t *testing.T) {
+	s := NewMemoryOutbox()
+	testOutbox(t, s)
+
+	// Events added in a transaction wait for it, and go with it.
+	names, ctx := NewMemoryNames(), context.Background()
+	boom := errors.New("boom")
+	err := names.InTransaction(ctx, func(ctx context.Context) error {
+		if err := s.AddToOutbox(ctx, []OutboxEvent{{Type: "created", At: time.Now()}}); err != nil { return err }
+		if _, err := s.ClaimOutbox(ctx, time.Hour); !errors.Is(err, ErrNotFound) { t.Errorf("claimed before the commit: %v", err) }
+		return boom
+	})
+	if !errors.Is(err, boom) { t.Fatal(err) }
+	if e, err := s.ClaimOutbox(ctx, time.Hour); !errors.Is(err, ErrNotFound) { t.Fatalf("claimed a rolled back event: %+v, %v", e, err) }
+	if err := names.InTransaction(ctx, func(ctx context.Context) error { return s.AddToOutbox(ctx, []OutboxEvent{{Type: "updated", At: time.Now()}}) }); err != nil { t.Fatal(err) }
+	if e, err := s.ClaimOutbox(ctx, time.Hour); err != nil || e.Type != "updated" { t.Fatalf("claimed %+v, %v", e, err) }
+}
+
+// testOutbox walks events through the outbox: claimed oldest first from any
+// tenant, held for the lease, claimed again once it lapses unpublished,
+// and purged once published.
+func testOutbox(t *testing.T, s OutboxStore) {
+	t.Helper()
+	ctx := context.Background()
+	a, b := tenant.NewContext(ctx, "team-a"), tenant.NewContext(ctx, "team-b")
+	now := time.Now().UTC()
+	zoe := Name{ID: primitive.NewObjectID(), Name: "Zoe", Tags: []string{"x"}}
+	es := []OutboxEvent{{Type: "created", NameID: zoe.ID, Name: &zoe, At: now, RequestID: "req-1"}, {Type: "removed", NameID: zoe.ID, At: now}}
+	if err := s.AddToOutbox(a, es); err != nil { t.Fatal(err) }
+	if es[0].ID.IsZero() || es[0].Tenant != "team-a" { t.Fatalf("added %+v", es[0]) }
+	later := []OutboxEvent{{Type: "updated", NameID: primitive.NewObjectID(), At: now}}
+	if err := s.AddToOutbox(b, later); err != nil { t.Fatal(err) }
+
+	first, err := s.ClaimOutbox(ctx, time.Hour)
+	if err != nil || first.ID != es[0].ID || first.Tenant != "team-a" || first.Name == nil || first.Name.Name != "Zoe" || first.RequestID != "req-1" { t.Fatalf("claim: %+v, %v", first, err) }
+	second, err := s.ClaimOutbox(ctx, -time.Second) // a lease that has already lapsed
+	if err != nil || second.ID != es[1].ID || second.Name != nil { t.Fatalf("second claim: %+v, %v", second, err) }
+	if again, err := s.ClaimOutbox(ctx, time.Hour); err != nil || again.ID != es[1].ID { t.Fatalf("claim after the lease lapsed: %+v, %v", again, err) }
+	third, err := s.ClaimOutbox(ctx, time.Hour)
+	if err != nil || third.ID != later[0].ID || third.Tenant != "team-b" { t.Fatalf("third claim: %+v, %v", third, err) }
+	if _, err := s.ClaimOutbox(ctx, time.Hour); !errors.Is(err, ErrNotFound) { t.Fatalf("claim with all held: %v", err) }
+
+	for _, id := range []primitive.ObjectID{first.ID, third.ID} {
+		if err := s.MarkPublished(ctx, id); err != nil { t.Fatal(err) }
+	}
+	if err := s.MarkPublished(ctx, primitive.NewObjectID()); !errors.Is(err, ErrNotFound) { t.Fatalf("mark an unknown event: %v", err) }
+
+	// The purge keeps the event still to publish.
+	if err := s.PurgeOutbox(ctx, time.Now().Add(time.Minute)); err != nil { t.Fatal(err) }
+	if err := s.MarkPublished(ctx, first.ID); !errors.Is(err, ErrNotFound) { t.Fatalf("a published event survived the purge: %v", err) }
+	if err := s.MarkPublished(ctx, second.ID); err != nil { t.Fatalf("the purge took an unpublished event: %v", err) }
+}
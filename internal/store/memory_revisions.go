@@ -0,0 +1,34 @@
+package store
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"app/internal/tenant"
+)
+
+// MemoryRevisions is the in-memory RevisionStore.
+type MemoryRevisions struct {
+	mu   sync.Mutex
+	revs map[string]Revision
+}
+
+func NewMemoryRevisions() *MemoryRevisions { return &MemoryRevisions{revs: map[string]Revision{}} }
+
+func (s *MemoryRevisions) Bump(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tid := tenant.FromContext(ctx)
+	rev := s.revs[tid]
+	rev.N++
+	rev.At = time.Now().UTC()
+	s.revs[tid] = rev
+	return nil
+}
+
+func (s *MemoryRevisions) Revision(ctx context.Context) (Revision, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.revs[tenant.FromContext(ctx)], nil
+}
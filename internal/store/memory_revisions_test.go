@@ -0,0 +1,30 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"app/internal/tenant"
+)
+
+func TestMemoryRevisions(t *testing.T) { testRevisions(t, NewMemoryRevisions()) }
+
+// testRevisions counts writes per tenant, from zero for one never written.
+func testRevisions(t *testing.T, s RevisionStore) {
+	t.Helper()
+	ctx := context.Background()
+	if rev, err := s.Revision(ctx); err != nil || rev != (Revision{}) { t.Fatalf("before any write: %+v, %v", rev, err) }
+
+	before := time.Now().Add(-time.Second)
+	for range 3 {
+		if err := s.Bump(ctx); err != nil { t.Fatal(err) }
+	}
+	rev, err := s.Revision(ctx)
+	if err != nil || rev.N != 3 || rev.At.Before(before) { t.Fatalf("after three writes: %+v, %v", rev, err) }
+
+	other := tenant.NewContext(ctx, "team-b")
+	if err := s.Bump(other); err != nil { t.Fatal(err) }
+	if rev, _ := s.Revision(other); rev.N != 1 { t.Fatalf("other tenant: %+v", rev) }
+	if rev, _ := s.Revision(ctx); rev.N != 3 { t.Fatalf("after the other tenant's write: %+v", rev) }
+}
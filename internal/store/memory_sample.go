@@ -0,0 +1,20 @@
+package store
+
+import (
+	"context"
+	"math/rand/v2"
+
+	"app/internal/tenant"
+)
+
+func (s *MemoryNames) RandomName(ctx context.Context) (Name, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	tid := tenant.FromContext(ctx)
+	var live []Name
+	for _, n := range s.names {
+		if n.Tenant == tid && n.DeletedAt == nil { live = append(live, n) }
+	}
+	if len(live) == 0 { return Name{}, ErrNotFound }
+	return clone(live[rand.IntN(len(live))]), nil
+}
@@ -0,0 +1,36 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"app/internal/tenant"
+)
+
+func TestMemoryRandomName(t *testing.T) { testRandomName(t, NewMemoryNames()) }
+
+// testRandomName checks that s picks every live name of the tenant, and
+// none other.
+func testRandomName(t *testing.T, s interface {
+	NameStore
+	SampleStore
+}) {
+	t.Helper()
+	ctx := context.Background()
+	if _, err := s.RandomName(ctx); !errors.Is(err, ErrNotFound) { t.Fatalf("no names: %v", err) }
+	for _, name := range []string{"a", "b", "gone"} {
+		if err := s.Create(ctx, &Name{Name: name}); err != nil { t.Fatal(err) }
+	}
+	if err := s.Create(tenant.NewContext(ctx, "team-b"), &Name{Name: "theirs"}); err != nil { t.Fatal(err) }
+	page, _ := s.List(ctx, ListOptions{Limit: 10, NamePrefix: "gone"})
+	if err := s.SoftDelete(ctx, page.Items[0].ID, AnyVersion); err != nil { t.Fatal(err) }
+
+	seen := map[string]int{}
+	for range 200 {
+		n, err := s.RandomName(ctx)
+		if err != nil { t.Fatal(err) }
+		seen[n.Name]++
+	}
+	if len(seen) != 2 || seen["a"] == 0 || seen["b"] == 0 { t.Errorf("picked %v", seen) }
+}
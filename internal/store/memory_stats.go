@@ -0,0 +1,41 @@
+package store
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"maps"
+	"slices"
+	"time"
+	"unicode/utf8"
+
+	"app/internal/tenant"
+)
+
+func (s *MemoryNames) NameStats(ctx context.Context, since time.Time) (NameStats, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	tid, st := tenant.FromContext(ctx), NameStats{CreatedPerDay: []DayCount{}}
+	perDay, live := map[string]int64{}, []Name{}
+	for _, n := range s.names {
+		if n.Tenant != tid { continue }
+		st.Total++
+		if !n.CreatedAt.IsZero() && !n.CreatedAt.Before(since) { perDay[n.CreatedAt.UTC().Format(time.DateOnly)]++ }
+		if n.DeletedAt != nil { st.Deleted++ } else { live = append(live, n) }
+	}
+	for _, day := range slices.Sorted(maps.Keys(perDay)) { st.CreatedPerDay = append(st.CreatedPerDay, DayCount{Day: day, Count: perDay[day]}) }
+	if len(live) > 0 {
+		longest, shortest := clone(slices.MinFunc(live, byLength(-1))), clone(slices.MinFunc(live, byLength(1)))
+		st.Longest, st.Shortest = &longest, &shortest
+	}
+	return st, nil
+}
+
+// byLength orders names by their length in characters, ascending for dir 1
+// and descending for -1, then by ID like the other stores.
+func byLength(dir int) func(a, b Name) int {
+	return func(a, b Name) int {
+		if c := cmp.Compare(utf8.RuneCountInString(a.Name), utf8.RuneCountInString(b.Name)); c != 0 { return c * dir }
+		return bytes.Compare(a.ID[:], b.ID[:])
+	}
+}
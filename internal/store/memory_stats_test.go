@@ -0,0 +1,36 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"app/internal/tenant"
+)
+
+func TestMemoryNameStats(t *testing.T) { testNameStats(t, NewMemoryNames()) }
+
+// testNameStats checks the figures of s over one tenant's names.
+func testNameStats(t *testing.T, s interface {
+	NameStore
+	StatsStore
+}) {
+	t.Helper()
+	ctx := context.Background()
+	for _, name := range []string{"al", "alice", "bartholomew", "bo"} {
+		if err := s.Create(ctx, &Name{Name: name}); err != nil { t.Fatal(err) }
+	}
+	if err := s.Create(tenant.NewContext(ctx, "team-b"), &Name{Name: "maximilianus"}); err != nil { t.Fatal(err) }
+	page, _ := s.List(ctx, ListOptions{Limit: 10, NamePrefix: "bar"})
+	if err := s.SoftDelete(ctx, page.Items[0].ID, AnyVersion); err != nil { t.Fatal(err) }
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	st, err := s.NameStats(ctx, today)
+	if err != nil { t.Fatal(err) }
+	if st.Total != 4 || st.Deleted != 1 { t.Errorf("total %d, deleted %d", st.Total, st.Deleted) }
+	if len(st.CreatedPerDay) != 1 || st.CreatedPerDay[0] != (DayCount{Day: today.Format(time.DateOnly), Count: 4}) { t.Errorf("per day %+v", st.CreatedPerDay) }
+	if st.Longest == nil || st.Longest.Name != "alice" || st.Shortest == nil || st.Shortest.Name != "al" { t.Errorf("longest %+v, shortest %+v", st.Longest, st.Shortest) }
+
+	if st, _ := s.NameStats(ctx, today.AddDate(0, 0, 1)); len(st.CreatedPerDay) != 0 { t.Errorf("future window %+v", st.CreatedPerDay) }
+	if st, _ := s.NameStats(tenant.NewContext(ctx, "team-c"), today); st.Total != 0 || st.Longest != nil { t.Errorf("empty tenant %+v", st) }
+}
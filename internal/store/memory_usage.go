@@ -0,0 +1,42 @@
+package store
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MemoryUsage is the in-memory UsageStore.
+type MemoryUsage struct {
+	mu   sync.Mutex
+	days map[primitive.ObjectID][]Usage // per key, oldest first
+}
+
+func NewMemoryUsage() *MemoryUsage { return &MemoryUsage{days: map[primitive.ObjectID][]Usage{}} }
+
+func (s *MemoryUsage) AddUsage(ctx context.Context, us []Usage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, u := range us {
+		days := s.days[u.KeyID]
+		i, found := slices.BinarySearchFunc(days, u.Day, func(d Usage, day string) int { return strings.Compare(d.Day, day) })
+		if !found {
+			s.days[u.KeyID] = slices.Insert(days, i, u)
+			continue
+		}
+		d := &days[i]
+		d.Requests, d.BytesIn, d.BytesOut = d.Requests+u.Requests, d.BytesIn+u.BytesIn, d.BytesOut+u.BytesOut
+	}
+	return nil
+}
+
+func (s *MemoryUsage) Usage(ctx context.Context, keyID primitive.ObjectID, since string) ([]Usage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	days := s.days[keyID]
+	i, _ := slices.BinarySearchFunc(days, since, func(d Usage, day string) int { return strings.Compare(d.Day, day) })
+	return slices.Clone(days[i:]), nil
+}
@@ -0,0 +1,32 @@
+package store
+
+import (
+	"context"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestMemoryUsage(t *testing.T) { testUsage(t, NewMemoryUsage()) }
+
+// testUsage adds to a key's days out of order and reads them back summed
+// per day, oldest first, from a day on.
+func testUsage(t *testing.T, s UsageStore) {
+	t.Helper()
+	ctx := context.Background()
+	key, other := primitive.NewObjectID(), primitive.NewObjectID()
+	for _, us := range [][]Usage{
+		{{KeyID: key, Tenant: "team-a", Day: "2026-10-02", Requests: 2, BytesIn: 10, BytesOut: 100}},
+		{{KeyID: key, Tenant: "team-a", Day: "2026-09-30", Requests: 1, BytesOut: 5}, {KeyID: other, Day: "2026-10-02", Requests: 7}},
+		{{KeyID: key, Tenant: "team-a", Day: "2026-10-02", Requests: 3, BytesIn: 1, BytesOut: 1}, {KeyID: key, Tenant: "team-a", Day: "2026-10-01", Requests: 1}},
+	} {
+		if err := s.AddUsage(ctx, us); err != nil { t.Fatal(err) }
+	}
+
+	days, err := s.Usage(ctx, key, "2026-10-01")
+	if err != nil { t.Fatal(err) }
+	if len(days) != 2 || days[0].Day != "2026-10-01" || days[0].Requests != 1 { t.Fatalf("usage: %+v", days) }
+	if d := days[1]; d.Day != "2026-10-02" || d.Requests != 5 || d.BytesIn != 11 || d.BytesOut != 101 || d.Tenant != "team-a" { t.Fatalf("2 October: %+v", d) }
+	if days, err := s.Usage(ctx, key, "2026-09-01"); err != nil || len(days) != 3 { t.Fatalf("since September: %+v, %v", days, err) }
+	if days, err := s.Usage(ctx, primitive.NewObjectID(), "2026-09-01"); err != nil || len(days) != 0 { t.Fatalf("unknown key: %+v, %v", days, err) }
+}
@@ -0,0 +1,68 @@
+package store
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MemoryUsers is the in-memory UserStore.
+type MemoryUsers struct {
+	mu    sync.RWMutex
+	users map[string]User // by username
+}
+
+func NewMemoryUsers() *MemoryUsers { return &MemoryUsers{users: map[string]User{}} }
+
+func (s *MemoryUsers) CreateUser(ctx context.Context, u User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.users[u.Username]; ok { return ErrDuplicate }
+	s.users[u.Username] = u
+	return nil
+}
+
+func (s *MemoryUsers) UserByUsername(ctx context.Context, username string) (User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	u, ok := s.users[username]
+	if !ok { return u, ErrNotFound }
+	return u, nil
+}
+
+func (s *MemoryUsers) FoundTenant(ctx context.Context, u User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, other := range s.users {
+		if other.Tenant == u.Tenant { return ErrTenantExists }
+	}
+	if _, ok := s.users[u.Username]; ok { return ErrDuplicate }
+	s.users[u.Username] = u
+	return nil
+}
+
+func (s *MemoryUsers) Users(ctx context.Context, tenant string) ([]User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	users := []User{}
+	for _, u := range s.users {
+		if u.Tenant == tenant { users = append(users, u) }
+	}
+	slices.SortFunc(users, func(a, b User) int { return strings.Compare(a.Username, b.Username) })
+	return users, nil
+}
+
+func (s *MemoryUsers) SetUserRole(ctx context.Context, tenant string, id primitive.ObjectID, role string) (User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for name, u := range s.users {
+		if u.ID != id || u.Tenant != tenant { continue }
+		u.Role = role
+		s.users[name] = u
+		return u, nil
+	}
+	return User{}, ErrNotFound
+}
@@ -0,0 +1,78 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestMemoryRoles(t *testing.T) { testRoles(t, NewMemoryUsers(), NewMemoryAPIKeys()) }
+
+func TestMemoryFoundTenant(t *testing.T) { testFoundTenant(t, NewMemoryUsers()) }
+
+// testFoundTenant checks that of many users founding a tenant at once, one
+// does, and that a taken username founds nothing.
+func testFoundTenant(t *testing.T, users UserStore) {
+	t.Helper()
+	ctx := context.Background()
+	user := func(name, tid string) User {
+		return User{ID: primitive.NewObjectID(), Username: name, Tenant: tid, Role: "admin", PasswordHash: []byte("x"), CreatedAt: time.Now().UTC()}
+	}
+	if err := users.CreateUser(ctx, user("alice", "default")); err != nil { t.Fatal(err) }
+	if err := users.FoundTenant(ctx, user("bob", "default")); !errors.Is(err, ErrTenantExists) { t.Fatalf("joined tenant: %v", err) }
+	if err := users.FoundTenant(ctx, user("alice", "team-a")); !errors.Is(err, ErrDuplicate) { t.Fatalf("taken username: %v", err) }
+
+	var wg sync.WaitGroup
+	errs := make([]error, 8)
+	for i := range errs {
+		wg.Add(1)
+		go func() { defer wg.Done(); errs[i] = users.FoundTenant(ctx, user("user"+strconv.Itoa(i), "team-a")) }()
+	}
+	wg.Wait()
+	founded := 0
+	for _, err := range errs {
+		if err == nil { founded++; continue }
+		if !errors.Is(err, ErrTenantExists) { t.Fatal(err) }
+	}
+	if founded != 1 { t.Fatalf("%d founders", founded) }
+	if got, err := users.Users(ctx, "team-a"); err != nil || len(got) != 1 { t.Fatalf("users %+v, %v", got, err) }
+}
+
+// testRoles checks listing one tenant's users and keys and changing their roles.
+func testRoles(t *testing.T, users UserStore, keys APIKeyStore) {
+	t.Helper()
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	bob := User{ID: primitive.NewObjectID(), Username: "bob", Tenant: "team-a", Role: "editor", PasswordHash: []byte("x"), CreatedAt: now}
+	for _, u := range []User{bob, {ID: primitive.NewObjectID(), Username: "alice", Tenant: "team-a", Role: "admin", PasswordHash: []byte("x"), CreatedAt: now},
+		{ID: primitive.NewObjectID(), Username: "carol", Tenant: "team-b", Role: "admin", PasswordHash: []byte("x"), CreatedAt: now}} {
+		if err := users.CreateUser(ctx, u); err != nil { t.Fatal(err) }
+	}
+	got, err := users.Users(ctx, "team-a")
+	if err != nil || len(got) != 2 || got[0].Username != "alice" || got[1].Role != "editor" { t.Fatalf("users %+v, %v", got, err) }
+
+	if u, err := users.SetUserRole(ctx, "team-a", bob.ID, "viewer"); err != nil || u.Role != "viewer" || u.Username != "bob" { t.Fatalf("set role: %+v, %v", u, err) }
+	if u, _ := users.UserByUsername(ctx, "bob"); u.Role != "viewer" { t.Fatalf("stored role %q", u.Role) }
+	if _, err := users.SetUserRole(ctx, "team-b", bob.ID, "admin"); !errors.Is(err, ErrNotFound) { t.Fatalf("other tenant: %v", err) }
+
+	k := APIKey{ID: primitive.NewObjectID(), UserID: bob.ID, Tenant: "team-a", Name: "ci", Prefix: "p1", Hash: "h1", Scopes: []string{"read", "write"}, Role: "editor", CreatedAt: now}
+	for _, k := range []APIKey{k, {ID: primitive.NewObjectID(), UserID: bob.ID, Tenant: "team-a", Name: "old", Prefix: "p2", Hash: "h2", Scopes: []string{"read"}, CreatedAt: now.Add(time.Second)}} {
+		if err := keys.CreateAPIKey(ctx, k); err != nil { t.Fatal(err) }
+	}
+	if err := keys.RevokeAPIKey(ctx, k.ID, primitive.NewObjectID()); !errors.Is(err, ErrNotFound) { t.Fatalf("revoked by another user: %v", err) }
+	if err := keys.RevokeTenantAPIKey(ctx, "team-b", k.ID); !errors.Is(err, ErrNotFound) { t.Fatalf("revoked by another tenant: %v", err) }
+	if err := keys.RevokeTenantAPIKey(ctx, "team-a", k.ID); err != nil { t.Fatal(err) }
+	if err := keys.RevokeAPIKey(ctx, k.ID, bob.ID); !errors.Is(err, ErrNotFound) { t.Fatalf("revoked twice: %v", err) }
+	list, err := keys.APIKeys(ctx, "team-a")
+	if err != nil || len(list) != 2 || list[0].Name != "ci" || list[0].RevokedAt == nil || list[0].Role != "editor" || len(list[0].Scopes) != 2 { t.Fatalf("keys %+v, %v", list, err) }
+	if list, _ := keys.APIKeys(ctx, "team-b"); len(list) != 0 { t.Fatalf("other tenant's keys %+v", list) }
+
+	if got, err := keys.SetAPIKeyRole(ctx, "team-a", list[1].ID, "viewer"); err != nil || got.Role != "viewer" || got.Name != "old" { t.Fatalf("set key role: %+v, %v", got, err) }
+	if got, _ := keys.APIKeyByHash(ctx, "h2"); got.Role != "viewer" { t.Fatalf("stored key role %q", got.Role) }
+	if _, err := keys.SetAPIKeyRole(ctx, "team-b", list[1].ID, "admin"); !errors.Is(err, ErrNotFound) { t.Fatalf("other tenant's key: %v", err) }
+}
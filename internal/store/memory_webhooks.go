@@ -0,0 +1,136 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"app/internal/tenant"
+)
+
+// MemoryWebhooks is the in-memory WebhookStore.
+type MemoryWebhooks struct {
+	mu         sync.Mutex
+	hooks      []Webhook  // oldest first
+	deliveries []Delivery // oldest first
+}
+
+func NewMemoryWebhooks() *MemoryWebhooks { return &MemoryWebhooks{} }
+
+func (s *MemoryWebhooks) CreateWebhook(ctx context.Context, w *Webhook) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	w.ID, w.Tenant, w.CreatedAt = primitive.NewObjectID(), tenant.FromContext(ctx), time.Now().UTC().Truncate(time.Millisecond)
+	w.Events = slices.Clone(w.Events)
+	s.hooks = append(s.hooks, *w)
+	return nil
+}
+
+func (s *MemoryWebhooks) Webhook(ctx context.Context, id primitive.ObjectID) (Webhook, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tid := tenant.FromContext(ctx)
+	i := slices.IndexFunc(s.hooks, func(w Webhook) bool { return w.ID == id && w.Tenant == tid })
+	if i < 0 { return Webhook{}, ErrNotFound }
+	w := s.hooks[i]
+	w.Events = slices.Clone(w.Events)
+	return w, nil
+}
+
+func (s *MemoryWebhooks) Webhooks(ctx context.Context) ([]Webhook, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tid := tenant.FromContext(ctx)
+	out := []Webhook{}
+	for _, w := range s.hooks {
+		if w.Tenant != tid { continue }
+		w.Events = slices.Clone(w.Events)
+		out = append(out, w)
+	}
+	return out, nil
+}
+
+func (s *MemoryWebhooks) DeleteWebhook(ctx context.Context, id primitive.ObjectID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tid := tenant.FromContext(ctx)
+	i := slices.IndexFunc(s.hooks, func(w Webhook) bool { return w.ID == id && w.Tenant == tid })
+	if i < 0 { return ErrNotFound }
+	s.hooks = slices.Delete(s.hooks, i, i+1)
+	s.deliveries = slices.DeleteFunc(s.deliveries, func(d Delivery) bool { return d.WebhookID == id })
+	return nil
+}
+
+func (s *MemoryWebhooks) EnqueueDeliveries(ctx context.Context, ds []Delivery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	for i := range ds {
+		d := &ds[i]
+		d.ID, d.Tenant, d.Status, d.NextAttemptAt, d.CreatedAt = primitive.NewObjectID(), tenant.FromContext(ctx), DeliveryPending, &now, now
+		s.deliveries = append(s.deliveries, cloneDelivery(*d))
+	}
+	return nil
+}
+
+func (s *MemoryWebhooks) Deliveries(ctx context.Context, webhookID primitive.ObjectID, limit int) ([]Delivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tid := tenant.FromContext(ctx)
+	out := []Delivery{}
+	for i := len(s.deliveries) - 1; i >= 0 && len(out) < limit; i-- {
+		if d := s.deliveries[i]; d.WebhookID == webhookID && d.Tenant == tid { out = append(out, cloneDelivery(d)) }
+	}
+	return out, nil
+}
+
+func (s *MemoryWebhooks) ClaimDelivery(ctx context.Context, lease time.Duration) (Delivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	i := -1
+	for j, d := range s.deliveries {
+		if d.Status != DeliveryPending || d.NextAttemptAt.After(now) { continue }
+		if i < 0 || d.NextAttemptAt.Before(*s.deliveries[i].NextAttemptAt) { i = j }
+	}
+	if i < 0 { return Delivery{}, ErrNotFound }
+	d := &s.deliveries[i]
+	until := now.Add(lease)
+	d.NextAttemptAt = &until
+	d.Claims++
+	return cloneDelivery(*d), nil
+}
+
+func (s *MemoryWebhooks) FinishDelivery(ctx context.Context, d Delivery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := slices.IndexFunc(s.deliveries, func(x Delivery) bool { return x.ID == d.ID })
+	if i < 0 { return ErrNotFound }
+	stored := &s.deliveries[i]
+	if stored.Claims != d.Claims || stored.Status != DeliveryPending { return ErrVersionMismatch }
+	d = cloneDelivery(d)
+	stored.Status, stored.Attempts, stored.NextAttemptAt = d.Status, d.Attempts, d.NextAttemptAt
+	if d.Status != DeliveryPending {
+		now := time.Now().UTC().Truncate(time.Millisecond)
+		stored.NextAttemptAt, stored.FinishedAt = nil, &now
+	}
+	return nil
+}
+
+func (s *MemoryWebhooks) PurgeDeliveries(ctx context.Context, before time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.deliveries = slices.DeleteFunc(s.deliveries, func(d Delivery) bool { return d.FinishedAt != nil && d.FinishedAt.Before(before) })
+	return nil
+}
+
+// cloneDelivery copies d so callers and the store never share its slices.
+func cloneDelivery(d Delivery) Delivery {
+	d.Payload, d.Attempts = bytes.Clone(d.Payload), slices.Clone(d.Attempts)
+	if d.NextAttemptAt != nil { t := *d.NextAttemptAt; d.NextAttemptAt = &t }
+	return d
+}
@@ -0,0 +1,65 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"app/internal/tenant"
+)
+
+func TestMemoryWebhooks(t *testing.T) { testWebhooks(t, NewMemoryWebhooks()) }
+
+// testWebhooks keeps webhooks per tenant and walks deliveries through the
+// queue: claimed when due, retried later, stale workers that can't finish,
+// the log, and the purge.
+func testWebhooks(t *testing.T, s WebhookStore) {
+	t.Helper()
+	ctx := context.Background()
+	a, b := tenant.NewContext(ctx, "team-a"), tenant.NewContext(ctx, "team-b")
+	hook := Webhook{URL: "https://example.com/hook", Events: []string{"created", "removed"}, Secret: "s3cret"}
+	if err := s.CreateWebhook(a, &hook); err != nil { t.Fatal(err) }
+	if got, err := s.Webhook(a, hook.ID); err != nil || got.URL != hook.URL || len(got.Events) != 2 || got.Secret != "s3cret" || got.Tenant != "team-a" { t.Fatalf("webhook: %+v, %v", got, err) }
+	if _, err := s.Webhook(b, hook.ID); !errors.Is(err, ErrNotFound) { t.Fatalf("webhook across tenants: %v", err) }
+	if hooks, _ := s.Webhooks(b); len(hooks) != 0 { t.Fatalf("webhooks across tenants: %+v", hooks) }
+
+	ds := []Delivery{{WebhookID: hook.ID, Event: "created", Payload: []byte(`{"n":1}`)}, {WebhookID: hook.ID, Event: "removed", Payload: []byte(`{"n":2}`)}}
+	if err := s.EnqueueDeliveries(a, ds); err != nil { t.Fatal(err) }
+	if ds[0].ID.IsZero() || ds[0].Status != DeliveryPending || ds[0].Tenant != "team-a" { t.Fatalf("enqueued %+v", ds[0]) }
+
+	// Claims come in order, from any tenant, and push the delivery back.
+	first, err := s.ClaimDelivery(ctx, time.Hour)
+	if err != nil || first.ID != ds[0].ID || first.Claims != 1 || string(first.Payload) != `{"n":1}` || first.Tenant != "team-a" { t.Fatalf("claim: %+v, %v", first, err) }
+	second, err := s.ClaimDelivery(ctx, -time.Second) // a lease that has already lapsed
+	if err != nil || second.ID != ds[1].ID { t.Fatalf("second claim: %+v, %v", second, err) }
+	if _, err := s.ClaimDelivery(ctx, time.Hour); err != nil { t.Fatalf("claim after the lease lapsed: %v", err) }
+	if _, err := s.ClaimDelivery(ctx, time.Hour); !errors.Is(err, ErrNotFound) { t.Fatalf("claim with none due: %v", err) }
+	second.Status = DeliveryDelivered
+	if err := s.FinishDelivery(ctx, second); !errors.Is(err, ErrVersionMismatch) { t.Fatalf("stale finish: %v", err) }
+
+	// A failed attempt is retried when due again.
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	first.Attempts = []DeliveryAttempt{{At: now, StatusCode: 500, DurationMS: 12}}
+	first.NextAttemptAt = &now
+	if err := s.FinishDelivery(ctx, first); err != nil { t.Fatal(err) }
+	retry, err := s.ClaimDelivery(ctx, time.Hour)
+	if err != nil || retry.ID != first.ID || retry.Claims != 2 || len(retry.Attempts) != 1 || retry.Attempts[0].StatusCode != 500 { t.Fatalf("retry: %+v, %v", retry, err) }
+	retry.Status, retry.Attempts = DeliveryDelivered, append(retry.Attempts, DeliveryAttempt{At: now, StatusCode: 204, DurationMS: 3})
+	if err := s.FinishDelivery(ctx, retry); err != nil { t.Fatal(err) }
+	if err := s.FinishDelivery(ctx, retry); !errors.Is(err, ErrVersionMismatch) { t.Fatalf("finish twice: %v", err) }
+
+	log, err := s.Deliveries(a, hook.ID, 10)
+	if err != nil || len(log) != 2 || log[0].ID != ds[1].ID { t.Fatalf("deliveries: %+v, %v", log, err) }
+	if d := log[1]; d.Status != DeliveryDelivered || len(d.Attempts) != 2 || d.Attempts[1].StatusCode != 204 || d.FinishedAt == nil || d.NextAttemptAt != nil { t.Fatalf("delivered: %+v", d) }
+	if log, _ := s.Deliveries(a, hook.ID, 1); len(log) != 1 { t.Fatalf("limit: %+v", log) }
+	if log, _ := s.Deliveries(b, hook.ID, 10); len(log) != 0 { t.Fatalf("deliveries across tenants: %+v", log) }
+
+	if err := s.PurgeDeliveries(ctx, time.Now().Add(time.Minute)); err != nil { t.Fatal(err) }
+	if log, _ := s.Deliveries(a, hook.ID, 10); len(log) != 1 || log[0].ID != ds[1].ID { t.Fatalf("after purge: %+v", log) }
+
+	if err := s.DeleteWebhook(b, hook.ID); !errors.Is(err, ErrNotFound) { t.Fatalf("delete across tenants: %v", err) }
+	if err := s.DeleteWebhook(a, hook.ID); err != nil { t.Fatal(err) }
+	if _, err := s.Webhook(a, hook.ID); !errors.Is(err, ErrNotFound) { t.Fatalf("deleted webhook: %v", err) }
+	if _, err := s.ClaimDelivery(ctx, -time.Second); !errors.Is(err, ErrNotFound) { t.Fatalf("deliveries outlived their webhook: %v", err) }
+}
@@ -0,0 +1,403 @@
+package store
+
+import (
+	"encoding/json"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type Name struct {
+	ID primitive.ObjectID `json:"id,omitempty" bson:"_id,omitempty"`
+	// UUID and Slug are the other keys a name can be addressed by, unique
+	// per tenant. It gets one of them when created, as ID_STRATEGY says
+	// (see package ids), and keeps it when renamed.
+	UUID string `json:"uuid,omitempty" bson:"uuid,omitempty"`
+	Slug string `json:"slug,omitempty" bson:"slug,omitempty"`
+	// Tenant is set by the store from the context; names are unique per
+	// tenant and invisible to every other one.
+	Tenant string `json:"-" bson:"tenant"`
+	Name   string `json:"name" bson:"name"`
+	// NameNormalized is Name in the canonical form package normalize makes
+	// of it, for finding names however they were typed. The server sets it
+	// on every write; clients can't. Names written before it existed have
+	// none until POST /admin/normalize gives them theirs.
+	NameNormalized string         `json:"name_normalized,omitempty" bson:"name_normalized,omitempty"`
+	Tags           []string       `json:"tags,omitempty" bson:"tags,omitempty"`
+	Metadata       map[string]any `json:"metadata,omitempty" bson:"metadata,omitempty"`
+	// CreatedAt and UpdatedAt are maintained by the store; clients can't set
+	// them. Names stored before they existed have neither.
+	CreatedAt time.Time `json:"created_at,omitzero" bson:"created_at,omitempty"`
+	UpdatedAt time.Time `json:"updated_at,omitzero" bson:"updated_at,omitempty"`
+	// CreatedBy is the ID of the user who created the name, and so owns it
+	// (see package owner); set by the server, never by clients. Names
+	// created with no user signed in have none.
+	CreatedBy string `json:"created_by,omitempty" bson:"created_by,omitempty"`
+	// DeletedAt is set by a soft delete; soft-deleted names are hidden from
+	// reads until restored.
+	DeletedAt *time.Time `json:"deleted_at,omitempty" bson:"deleted_at,omitempty"`
+	// ExpiresAt, if set, is when the name is due to be removed; the cleanup
+	// (see package cleanup) removes it on its first run after that. Until
+	// then it reads like any other name.
+	ExpiresAt *time.Time `json:"expires_at,omitempty" bson:"expires_at,omitempty"`
+	// Version counts the changes made to the document, starting at 1. It is
+	// the ETag of the HTTP API. Names stored before it existed have 0.
+	Version int64 `json:"version" bson:"version"`
+}
+
+// NamePatch is a partial update: nil fields are left alone. Empty tags or
+// metadata clear the field.
+type NamePatch struct {
+	Name      *string         `json:"name"`
+	Tags      *[]string       `json:"tags"`
+	Metadata  *map[string]any `json:"metadata"`
+	ExpiresAt Expiry          `json:"expires_at"`
+	// NameNormalized goes with Name, set by the server (see
+	// Name.NameNormalized).
+	NameNormalized *string `json:"-"`
+	// CreatedBy, UUID and Slug never change but in a restore, which gives a
+	// name back those of its backup. An empty one removes the field.
+	CreatedBy, UUID, Slug *string `json:"-"`
+}
+
+// Expiry is NamePatch's expires_at, which unlike the other fields can be
+// cleared: Set reports whether the patch has it at all, and a nil At
+// (null in JSON) clears it.
+type Expiry struct {
+	Set bool
+	At  *time.Time
+}
+
+func (e *Expiry) UnmarshalJSON(b []byte) error {
+	e.Set = true
+	if string(b) == "null" { e.At = nil; return nil }
+	return json.Unmarshal(b, &e.At)
+}
+
+// replacing is the patch that gives a name the tags, metadata and expiry
+// of n, as Upsert does.
+func replacing(n Name) NamePatch {
+	return NamePatch{Tags: &n.Tags, Metadata: &n.Metadata, ExpiresAt: Expiry{Set: true, At: n.ExpiresAt}}
+}
+
+// NameEvent is an audit record written alongside a change to a Name.
+type NameEvent struct {
+	ID     primitive.ObjectID `json:"id,omitempty" bson:"_id,omitempty"`
+	NameID primitive.ObjectID `json:"name_id" bson:"name_id"`
+	Tenant string             `json:"-" bson:"tenant"`
+	Type   string             `json:"type" bson:"type"`
+	Name   string             `json:"name" bson:"name"`
+	At     time.Time          `json:"at" bson:"at"`
+}
+
+// NameChange is one change to a Name seen by Watch. Type is created,
+// updated, deleted (soft), restored or removed (hard delete); Name is the
+// document after the change and is absent for removed.
+type NameChange struct {
+	Token string             `json:"-"` // resume token for Watch
+	Type  string             `json:"type"`
+	ID    primitive.ObjectID `json:"id"`
+	Name  *Name              `json:"name,omitempty"`
+}
+
+// AuditEntry records one write: who made it, when, in which request, and
+// the entity before and after. Action is one of NameChange's types; Before
+// is absent for created, After for removed.
+type AuditEntry struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id"`
+	Tenant    string             `json:"-" bson:"tenant"`
+	Entity    string             `json:"entity" bson:"entity"` // the collection: "names"
+	EntityID  primitive.ObjectID `json:"entity_id" bson:"entity_id"`
+	Action    string             `json:"action" bson:"action"`
+	Actor     string             `json:"actor,omitempty" bson:"actor,omitempty"` // user ID; empty with auth disabled
+	RequestID string             `json:"request_id,omitempty" bson:"request_id,omitempty"`
+	At        time.Time          `json:"at" bson:"at"`
+	Before    *Name              `json:"before,omitempty" bson:"before,omitempty"`
+	After     *Name              `json:"after,omitempty" bson:"after,omitempty"`
+}
+
+// DuplicateQuery selects the clusters DuplicateStore.Duplicates returns.
+type DuplicateQuery struct {
+	Distance int // edits allowed between normalized names; 0 for equal ones only
+	Limit    int // clusters at most; 0 for all of them
+}
+
+// DuplicateCluster is a set of live names likely to be one. Key is the
+// normalized form they share, or with a distance the least of theirs.
+type DuplicateCluster struct {
+	Key   string `json:"key"`
+	Names []Name `json:"names"` // by name, then ID
+}
+
+// NameStats sums up a tenant's names for dashboards.
+type NameStats struct {
+	Total   int64 `json:"total"` // soft-deleted names included
+	Deleted int64 `json:"deleted"`
+	// CreatedPerDay counts the names created on each UTC day of the window
+	// asked for, oldest first. Days without any are left out.
+	CreatedPerDay []DayCount `json:"created_per_day"`
+	// Longest and Shortest are the names with the most and the fewest
+	// characters, soft-deleted ones aside; absent if there are none.
+	Longest  *Name `json:"longest,omitempty"`
+	Shortest *Name `json:"shortest,omitempty"`
+	// Storage describes the whole collection, every tenant's names in it,
+	// and is absent where the store can't tell.
+	Storage *StorageStats `json:"storage,omitempty"`
+}
+
+type DayCount struct {
+	Day   string `json:"day"` // 2006-01-02
+	Count int64  `json:"count"`
+}
+
+// StorageStats is what MongoDB's collStats reports about a collection.
+type StorageStats struct {
+	Documents    int64 `json:"documents"`
+	SizeBytes    int64 `json:"size_bytes"`    // uncompressed data
+	StorageBytes int64 `json:"storage_bytes"` // allocated on disk
+	IndexBytes   int64 `json:"index_bytes"`
+	Indexes      int64 `json:"indexes"`
+}
+
+// User is an account that can log in and call the protected routes. Its
+// tokens act for its tenant; accounts from before tenants existed have none
+// and belong to the default one.
+type User struct {
+	ID           primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	Username     string             `json:"username" bson:"username"`
+	Tenant       string             `json:"tenant" bson:"tenant,omitempty"`
+	Role         string             `json:"role" bson:"role,omitempty"` // see auth.Roles; empty for users from before roles
+	PasswordHash []byte             `json:"-" bson:"password_hash"`
+	CreatedAt    time.Time          `json:"created_at" bson:"created_at"`
+}
+
+// APIKey lets a program call the API on a user's behalf, limited to its
+// scopes. Only the key's hash is stored; the key itself is shown once, when
+// it's minted.
+type APIKey struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	UserID    primitive.ObjectID `json:"user_id" bson:"user_id"`
+	Tenant    string             `json:"tenant" bson:"tenant,omitempty"` // the owner's
+	Name      string             `json:"name" bson:"name"`
+	Prefix    string             `json:"prefix" bson:"prefix"` // start of the key, to tell keys apart
+	Hash      string             `json:"-" bson:"hash"`
+	Scopes    []string           `json:"scopes" bson:"scopes"`
+	Role      string             `json:"role" bson:"role,omitempty"` // limits Scopes; the owner's when minted
+	CreatedAt time.Time          `json:"created_at" bson:"created_at"`
+	RevokedAt *time.Time         `json:"revoked_at,omitempty" bson:"revoked_at,omitempty"`
+}
+
+// IdempotencyRecord is stored under a client's Idempotency-Key. It starts
+// out pending (Status == 0) when the first request claims the key and is
+// filled in with the response once that request finishes.
+type IdempotencyRecord struct {
+	Key         string    `bson:"_id"`
+	RequestHash string    `bson:"request_hash"`
+	Status      int               `bson:"status"`
+	Headers     map[string]string `bson:"headers,omitempty"`
+	ContentType string            `bson:"content_type,omitempty"` // only in records written before Headers
+	Body        []byte            `bson:"body,omitempty"`
+	ExpiresAt   time.Time         `bson:"expires_at"`
+}
+
+// Capture is a request and its response as recorded for debugging (see
+// CaptureStore). Bodies are cut short at the configured size, and replaced
+// by a placeholder when they aren't text or carry secrets.
+type Capture struct {
+	RequestID         string              `json:"request_id" bson:"request_id"`
+	Tenant            string              `json:"tenant,omitempty" bson:"tenant,omitempty"` // of the caller, if it signed in
+	At                time.Time           `json:"at" bson:"at"`
+	DurationMS        float64             `json:"duration_ms" bson:"duration_ms"`
+	Method            string              `json:"method" bson:"method"`
+	URL               string              `json:"url" bson:"url"` // path and query
+	RequestHeaders    map[string][]string `json:"request_headers" bson:"request_headers"`
+	RequestBody       string              `json:"request_body,omitempty" bson:"request_body,omitempty"`
+	RequestTruncated  bool                `json:"request_truncated,omitempty" bson:"request_truncated,omitempty"`
+	Status            int                 `json:"status" bson:"status"`
+	ResponseHeaders   map[string][]string `json:"response_headers" bson:"response_headers"`
+	ResponseBody      string              `json:"response_body,omitempty" bson:"response_body,omitempty"`
+	ResponseTruncated bool                `json:"response_truncated,omitempty" bson:"response_truncated,omitempty"`
+}
+
+// Note is a remark about a name, kept apart from it and pointing at it by
+// NameID.
+type Note struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id"`
+	NameID    primitive.ObjectID `json:"name_id" bson:"name_id"`
+	Tenant    string             `json:"-" bson:"tenant"`
+	Body      string             `json:"body" bson:"body"`
+	Author    string             `json:"author,omitempty" bson:"author,omitempty"` // user ID; empty with auth disabled
+	CreatedAt time.Time          `json:"created_at" bson:"created_at"`
+}
+
+// NameWithNotes is a name with its notes joined in, oldest first:
+// GET /names/{id}?expand=notes.
+type NameWithNotes struct {
+	Name  `bson:",inline"`
+	Notes []Note `json:"notes" bson:"notes"`
+}
+
+// Doc is a document of one of the resources declared in RESOURCES_FILE
+// (see package resource): its fields and what the store keeps about it.
+type Doc struct {
+	ID        primitive.ObjectID
+	Tenant    string
+	Fields    map[string]any
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Version   int64
+}
+
+// MarshalJSON puts the fields next to id, created_at, updated_at and
+// version, which no field can be named.
+func (d Doc) MarshalJSON() ([]byte, error) {
+	m := make(map[string]any, len(d.Fields)+4)
+	for k, v := range d.Fields { m[k] = v }
+	m["id"], m["created_at"], m["updated_at"], m["version"] = d.ID, d.CreatedAt, d.UpdatedAt, d.Version
+	return json.Marshal(m)
+}
+
+// DocPage is one page of a resource's documents, oldest first.
+type DocPage struct {
+	Items []Doc  `json:"items"`
+	Next  string `json:"next,omitempty"` // the ID to pass as After for the next page
+}
+
+// Job statuses. A job is queued until a worker claims it, running while the
+// worker holds its lease, and then succeeded or failed for good.
+const (
+	JobQueued    = "queued"
+	JobRunning   = "running"
+	JobSucceeded = "succeeded"
+	JobFailed    = "failed"
+)
+
+// Job is a long-running task, such as an import, done in the background
+// by a worker (see package jobs) while clients poll GET /jobs/{id}.
+type Job struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id"`
+	Tenant    string             `json:"-" bson:"tenant"`
+	Type      string             `json:"type" bson:"type"`
+	Status    string             `json:"status" bson:"status"`
+	Actor     string             `json:"actor,omitempty" bson:"actor,omitempty"` // user ID of whoever started it
+	ActorRole string             `json:"-" bson:"actor_role,omitempty"`          // and their role, which the job runs with
+	RequestID string             `json:"request_id,omitempty" bson:"request_id,omitempty"`
+	Params    string             `json:"params,omitempty" bson:"params,omitempty"` // URL-encoded, as the job type defines them
+	Input     []byte             `json:"-" bson:"input,omitempty"`
+	Output    []byte             `json:"-" bson:"output,omitempty"` // a file to download, for jobs that make one
+	Result    json.RawMessage    `json:"result,omitempty" bson:"result,omitempty"`
+	Error     string             `json:"error,omitempty" bson:"error,omitempty"`
+	// Attempts counts the claims; it also tells a worker whether the job
+	// is still its own.
+	Attempts   int        `json:"attempts" bson:"attempts"`
+	LeaseUntil *time.Time `json:"-" bson:"lease_until,omitempty"`
+	CreatedAt  time.Time  `json:"created_at" bson:"created_at"`
+	StartedAt  *time.Time `json:"started_at,omitempty" bson:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty" bson:"finished_at,omitempty"`
+}
+
+// Webhook asks for the changes to a tenant's names to be POSTed to URL as
+// they happen (see package webhook). Events are the NameChange types it
+// wants; Secret signs each delivery.
+type Webhook struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id"`
+	Tenant    string             `json:"-" bson:"tenant"`
+	URL       string             `json:"url" bson:"url"`
+	Events    []string           `json:"events" bson:"events"`
+	Secret    string             `json:"-" bson:"secret"`
+	CreatedAt time.Time          `json:"created_at" bson:"created_at"`
+}
+
+// Delivery statuses. A delivery is pending until its webhook answers 2xx,
+// or until it has been tried too often and failed for good.
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed"
+)
+
+// Delivery is one event on its way to a webhook, with the log of the
+// attempts made so far.
+type Delivery struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id"`
+	Tenant    string             `json:"-" bson:"tenant"`
+	WebhookID primitive.ObjectID `json:"webhook_id" bson:"webhook_id"`
+	Event     string             `json:"event" bson:"event"`
+	Payload   json.RawMessage    `json:"payload" bson:"payload"`
+	Status    string             `json:"status" bson:"status"`
+	Attempts  []DeliveryAttempt  `json:"attempts" bson:"attempts"`
+	// Claims counts the workers that took the delivery; like Job.Attempts,
+	// it tells a worker whether the delivery is still its own.
+	Claims int `json:"-" bson:"claims"`
+	// NextAttemptAt is when a pending delivery is due; a worker taking it
+	// pushes it back by its lease.
+	NextAttemptAt *time.Time `json:"next_attempt_at,omitempty" bson:"next_attempt_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at" bson:"created_at"`
+	FinishedAt    *time.Time `json:"finished_at,omitempty" bson:"finished_at,omitempty"`
+}
+
+// OutboxEvent is a write to a name waiting in the outbox to be published.
+// Name is the document after the change, or before it for removed.
+type OutboxEvent struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id"`
+	Tenant    string             `json:"tenant" bson:"tenant"`
+	Type      string             `json:"type" bson:"type"` // created, updated, deleted, restored or removed
+	NameID    primitive.ObjectID `json:"name_id" bson:"name_id"`
+	Name      *Name              `json:"name,omitempty" bson:"name,omitempty"`
+	At        time.Time          `json:"at" bson:"at"`
+	RequestID string             `json:"request_id,omitempty" bson:"request_id,omitempty"`
+	// LeaseUntil is when an unpublished event may be claimed: when it was
+	// added, then when the dispatcher that claimed it gives it up.
+	LeaseUntil  time.Time  `json:"-" bson:"lease_until"`
+	PublishedAt *time.Time `json:"-" bson:"published_at,omitempty"`
+}
+
+// Change is an entry of the change log: name NameID was written, the
+// Seq'th write to the names of the tenant. Type is one of NameChange's.
+type Change struct {
+	Seq    int64              `json:"seq" bson:"seq"`
+	Tenant string             `json:"-" bson:"tenant"`
+	NameID primitive.ObjectID `json:"name_id" bson:"name_id"`
+	Type   string             `json:"type" bson:"type"`
+	At     time.Time          `json:"at" bson:"at"`
+}
+
+// Usage is what an API key used in a day (UTC) or, summed, a longer
+// period: the requests it made and the bytes of their bodies.
+type Usage struct {
+	KeyID    primitive.ObjectID `json:"-" bson:"key_id"`
+	Tenant   string             `json:"-" bson:"tenant"` // the key's
+	Day      string             `json:"-" bson:"day"`    // YYYY-MM-DD
+	Requests int64              `json:"requests" bson:"requests"`
+	BytesIn  int64              `json:"bytes_in" bson:"bytes_in"`
+	BytesOut int64              `json:"bytes_out" bson:"bytes_out"`
+}
+
+// DeliveryAttempt is one POST of a delivery: the status the webhook
+// answered with, or why there was no answer.
+type DeliveryAttempt struct {
+	At         time.Time `json:"at" bson:"at"`
+	StatusCode int       `json:"status_code,omitempty" bson:"status_code,omitempty"`
+	Error      string    `json:"error,omitempty" bson:"error,omitempty"`
+	DurationMS int64     `json:"duration_ms" bson:"duration_ms"`
+}
+
+// Revision is how many writes a tenant's names have had, and when the last
+// one was.
+type Revision struct {
+	N  int64     `bson:"n"`
+	At time.Time `bson:"at"`
+}
+
+// Lease is a claim on something that one instance at a time is to hold,
+// such as the leadership of the background work (see package leader). It
+// is the holder's until ExpiresAt unless renewed; Term counts the holders
+// it has had, so that a new one can be told from the old one renewing.
+type Lease struct {
+	Name       string    `json:"name" bson:"_id"`
+	Holder     string    `json:"holder" bson:"holder"` // empty once given up
+	Term       int64     `json:"term" bson:"term"`
+	AcquiredAt time.Time `json:"acquired_at" bson:"acquired_at"` // by the holder
+	RenewedAt  time.Time `json:"renewed_at" bson:"renewed_at"`
+	ExpiresAt  time.Time `json:"expires_at" bson:"expires_at"`
+}
@@ -0,0 +1,295 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"go.mongodb.org/mongo-driver/event"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readconcern"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"go.mongodb.org/mongo-driver/mongo/writeconcern"
+	"go.mongodb.org/mongo-driver/x/mongo/driver/topology"
+)
+
+// MongoConfig describes how to reach MongoDB and tune its connection pool.
+type MongoConfig struct {
+	URI             string
+	Database        string
+	MaxPoolSize     uint64
+	MinPoolSize     uint64
+	MaxConnIdleTime time.Duration
+	// ServerSelectionTimeout bounds the wait for a suitable server; zero
+	// keeps the driver's 30s.
+	ServerSelectionTimeout time.Duration
+	// ConnectRetry is how long Connect keeps trying to reach the deployment
+	// before giving up; zero gives up after the first failed ping.
+	ConnectRetry time.Duration
+	// ReadPref and WriteConcern apply to the name and event collections;
+	// empty keeps the driver (or URI) defaults. See CollectionOptions. Lists
+	// and searches of names read from the primary instead, unless they
+	// ask for Eventual consistency.
+	ReadPref     string
+	WriteConcern string
+	// ListReadPref and ListReadConcern apply to the lists and searches of
+	// names that ask for Eventual consistency; empty keeps ReadPref and the
+	// driver's read concern. See ListReadOptions.
+	ListReadPref    string
+	ListReadConcern string
+	// Collation orders the names and decides which are duplicates; the
+	// zero value compares code points.
+	Collation Collation
+	// Monitors each observe every command sent to the server.
+	Monitors []*event.CommandMonitor
+}
+
+func (c MongoConfig) Validate() error {
+	switch {
+	case c.URI == "":
+		return fmt.Errorf("MONGO_URI is required")
+	case c.MaxPoolSize == 0:
+		return fmt.Errorf("MONGO_MAX_POOL_SIZE must be > 0")
+	case c.MinPoolSize > c.MaxPoolSize:
+		return fmt.Errorf("MONGO_MIN_POOL_SIZE (%d) exceeds MONGO_MAX_POOL_SIZE (%d)", c.MinPoolSize, c.MaxPoolSize)
+	case c.MaxConnIdleTime < 0:
+		return fmt.Errorf("MONGO_MAX_CONN_IDLE_TIME must be >= 0, got %s", c.MaxConnIdleTime)
+	case c.ServerSelectionTimeout < 0:
+		return fmt.Errorf("MONGO_SERVER_SELECTION_TIMEOUT must be >= 0, got %s", c.ServerSelectionTimeout)
+	}
+	if err := c.Collation.Validate(); err != nil { return err }
+	if _, err := CollectionOptions(c.ReadPref, c.WriteConcern); err != nil { return err }
+	_, err := ListReadOptions(c.ListReadPref, c.ListReadConcern)
+	return err
+}
+
+// Mongo is a connected client plus the pool counters gathered for it.
+type Mongo struct {
+	Client  *mongo.Client
+	DB      *mongo.Database
+	cfg     MongoConfig
+	pool    poolCounters
+	colOpt  *options.CollectionOptions
+	listOpt *options.CollectionOptions // over colOpt, for Eventual reads
+}
+
+// Connect dials MongoDB and pings it. If the ping fails, it tries again with
+// growing pauses for up to cfg.ConnectRetry, so that the service outlives a
+// database that starts a little after it.
+func Connect(ctx context.Context, cfg MongoConfig) (*Mongo, error) {
+	if err := cfg.Validate(); err != nil { return nil, err }
+	m := &Mongo{cfg: cfg}
+	m.colOpt, _ = CollectionOptions(cfg.ReadPref, cfg.WriteConcern)
+	m.listOpt, _ = ListReadOptions(cfg.ListReadPref, cfg.ListReadConcern)
+
+	// DefaultDocumentM: nested metadata decodes as maps, not bson.D key/value pairs.
+	opts := options.Client().ApplyURI(cfg.URI).
+		SetBSONOptions(&options.BSONOptions{DefaultDocumentM: true}).
+		SetMaxPoolSize(cfg.MaxPoolSize).SetMinPoolSize(cfg.MinPoolSize).SetMaxConnIdleTime(cfg.MaxConnIdleTime).
+		SetPoolMonitor(&event.PoolMonitor{Event: m.pool.record})
+	if cfg.ServerSelectionTimeout > 0 { opts.SetServerSelectionTimeout(cfg.ServerSelectionTimeout) }
+	if len(cfg.Monitors) > 0 { opts.SetMonitor(fanOut(cfg.Monitors)) }
+	client, err := mongo.Connect(ctx, opts)
+	if err != nil { return nil, err }
+	if err := pingUntil(ctx, client, time.Now().Add(cfg.ConnectRetry)); err != nil {
+		_ = client.Disconnect(ctx)
+		return nil, err
+	}
+	m.Client, m.DB = client, client.Database(cfg.Database)
+	return m, nil
+}
+
+// pingUntil pings client until it answers, backing off from half a second
+// to ten between attempts, and gives up once the next one would start after
+// deadline.
+func pingUntil(ctx context.Context, client *mongo.Client, deadline time.Time) error {
+	wait := 500 * time.Millisecond
+	for attempt := 1; ; attempt++ {
+		err := client.Ping(ctx, nil)
+		if err == nil { return nil }
+		if time.Now().Add(wait).After(deadline) { return fmt.Errorf("MongoDB unreachable after %d attempts: %w", attempt, err) }
+		slog.WarnContext(ctx, "MongoDB is unreachable, retrying", "attempt", attempt, "wait", wait, "err", err)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(wait):
+		}
+		wait = min(2*wait, 10*time.Second)
+	}
+}
+
+// fanOut merges monitors into the one the driver takes, calling each in
+// order for every event it handles.
+func fanOut(monitors []*event.CommandMonitor) *event.CommandMonitor {
+	return &event.CommandMonitor{
+		Started: func(ctx context.Context, e *event.CommandStartedEvent) {
+			for _, m := range monitors { if m.Started != nil { m.Started(ctx, e) } }
+		},
+		Succeeded: func(ctx context.Context, e *event.CommandSucceededEvent) {
+			for _, m := range monitors { if m.Succeeded != nil { m.Succeeded(ctx, e) } }
+		},
+		Failed: func(ctx context.Context, e *event.CommandFailedEvent) {
+			for _, m := range monitors { if m.Failed != nil { m.Failed(ctx, e) } }
+		},
+	}
+}
+
+// Collection returns a handle using the configured read preference and
+// write concern.
+func (m *Mongo) Collection(name string) *mongo.Collection {
+	return m.DB.Collection(name, m.colOpt)
+}
+
+// readers returns handles on collection name for reads of each
+// consistency: strong ones from the primary, whatever the read preference,
+// and eventual ones as ListReadPref and ListReadConcern say.
+func (m *Mongo) readers(name string) (strong, eventual *mongo.Collection) {
+	return m.DB.Collection(name, m.colOpt, options.Collection().SetReadPreference(readpref.Primary())), m.DB.Collection(name, m.colOpt, m.listOpt)
+}
+
+// Ping checks the deployment answers, for readiness probes.
+func (m *Mongo) Ping(ctx context.Context) error { return m.Client.Ping(ctx, nil) }
+
+func (m *Mongo) Disconnect(ctx context.Context) error { return m.Client.Disconnect(ctx) }
+
+// CollectionOptions builds the read preference / write concern applied to
+// collection handles. Empty values keep the driver (or URI) defaults.
+//
+//	readPref:     primary | primaryPreferred | secondary | secondaryPreferred | nearest
+//	writeConcern: majority | <n> (number of acknowledging nodes, 0 = unacknowledged)
+func CollectionOptions(readPref, writeConcern string) (*options.CollectionOptions, error) {
+	opts := options.Collection()
+	if readPref != "" {
+		mode, err := readpref.ModeFromString(readPref)
+		if err != nil { return nil, fmt.Errorf("READ_PREF: %w", err) }
+		rp, err := readpref.New(mode)
+		if err != nil { return nil, fmt.Errorf("READ_PREF: %w", err) }
+		opts.SetReadPreference(rp)
+	}
+	switch {
+	case writeConcern == "":
+	case strings.EqualFold(writeConcern, "majority"):
+		opts.SetWriteConcern(writeconcern.Majority())
+	default:
+		n, err := strconv.Atoi(writeConcern)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("WRITE_CONCERN: want \"majority\" or a non-negative integer, got %q", writeConcern)
+		}
+		opts.SetWriteConcern(&writeconcern.WriteConcern{W: n})
+	}
+	return opts, nil
+}
+
+// ListReadOptions builds the read preference and read concern of reads
+// with Eventual consistency. Empty values keep those of CollectionOptions.
+//
+//	readPref:    as for CollectionOptions
+//	readConcern: local | available | majority
+func ListReadOptions(readPref, readConcern string) (*options.CollectionOptions, error) {
+	opts := options.Collection()
+	if readPref != "" {
+		mode, err := readpref.ModeFromString(readPref)
+		if err != nil { return nil, fmt.Errorf("LIST_READ_PREF: %w", err) }
+		rp, err := readpref.New(mode)
+		if err != nil { return nil, fmt.Errorf("LIST_READ_PREF: %w", err) }
+		opts.SetReadPreference(rp)
+	}
+	switch readConcern {
+	case "":
+	case "local":
+		opts.SetReadConcern(readconcern.Local())
+	case "available":
+		opts.SetReadConcern(readconcern.Available())
+	case "majority":
+		opts.SetReadConcern(readconcern.Majority())
+	default:
+		return nil, fmt.Errorf("LIST_READ_CONCERN: want local, available or majority, got %q", readConcern)
+	}
+	return opts, nil
+}
+
+// PoolStats is a snapshot of the connection pool.
+type PoolStats struct {
+	Config struct {
+		MaxPoolSize     uint64        `json:"max_pool_size"`
+		MinPoolSize     uint64        `json:"min_pool_size"`
+		MaxConnIdleTime time.Duration `json:"max_conn_idle_time_ns"`
+	} `json:"config"`
+	Open             int64   `json:"open"`
+	InUse            int64   `json:"in_use"`
+	Idle             int64   `json:"idle"`
+	Saturation       float64 `json:"saturation"`
+	CreatedTotal     int64   `json:"created_total"`
+	ClosedTotal      int64   `json:"closed_total"`
+	CheckedOutTotal  int64   `json:"checked_out_total"`
+	CheckoutFailures int64   `json:"checkout_failures"`
+	PoolCleared      int64   `json:"pool_cleared"`
+}
+
+func (m *Mongo) PoolStats() PoolStats {
+	var s PoolStats
+	s.Config.MaxPoolSize, s.Config.MinPoolSize, s.Config.MaxConnIdleTime = m.cfg.MaxPoolSize, m.cfg.MinPoolSize, m.cfg.MaxConnIdleTime
+	s.Open, s.InUse = m.pool.open.Load(), m.pool.inUse.Load()
+	s.Idle = s.Open - s.InUse
+	s.Saturation = float64(s.InUse) / float64(m.cfg.MaxPoolSize)
+	s.CreatedTotal, s.ClosedTotal = m.pool.created.Load(), m.pool.closed.Load()
+	s.CheckedOutTotal, s.CheckoutFailures = m.pool.checkedOut.Load(), m.pool.checkoutFailures.Load()
+	s.PoolCleared = m.pool.cleared.Load()
+	return s
+}
+
+// poolCounters aggregates driver pool events across all servers.
+type poolCounters struct {
+	open, inUse                  atomic.Int64
+	created, closed              atomic.Int64
+	checkedOut, checkoutFailures atomic.Int64
+	cleared                      atomic.Int64
+}
+
+func (p *poolCounters) record(e *event.PoolEvent) {
+	switch e.Type {
+	case event.ConnectionCreated:
+		p.created.Add(1); p.open.Add(1)
+	case event.ConnectionClosed:
+		p.closed.Add(1); p.open.Add(-1)
+	case event.GetSucceeded:
+		p.checkedOut.Add(1); p.inUse.Add(1)
+	case event.ConnectionReturned:
+		p.inUse.Add(-1)
+	case event.GetFailed:
+		p.checkoutFailures.Add(1)
+	case event.PoolCleared:
+		p.cleared.Add(1)
+	}
+}
+
+// Codes of commands refused by a member that isn't, or is about to stop
+// being, the primary: NotWritablePrimary, NotPrimaryNoSecondaryOk,
+// NotPrimaryOrSecondary, InterruptedDueToReplStateChange,
+// PrimarySteppedDown, ShutdownInProgress and InterruptedAtShutdown.
+var notPrimaryCodes = []int{10107, 13435, 13436, 11602, 189, 91, 11600}
+
+// TransientMongo reports whether err is a MongoDB failure that trying again
+// may well get past: a network error, a timeout, or one of Unapplied's.
+// Network errors and timeouts can strike after a write was applied, so only
+// reads may be retried after those.
+func TransientMongo(err error) bool {
+	return mongo.IsNetworkError(err) || mongo.IsTimeout(err) || UnappliedMongo(err)
+}
+
+// UnappliedMongo reports whether err is a transient MongoDB failure that
+// guarantees the operation didn't run, so even a write may be retried: no
+// server could be selected, or the one reached wasn't the primary.
+func UnappliedMongo(err error) bool {
+	var sel topology.ServerSelectionError
+	if errors.As(err, &sel) { return true }
+	var se mongo.ServerError
+	return errors.As(err, &se) && slices.ContainsFunc(notPrimaryCodes, se.HasErrorCode)
+}
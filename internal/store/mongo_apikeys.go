@@ -0,0 +1,74 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoAPIKeys is the MongoDB APIKeyStore. Revoked keys are kept, marked
+// with revoked_at, so it stays visible which key a request used.
+type MongoAPIKeys struct {
+	keys *mongo.Collection
+}
+
+// NewMongoAPIKeys also creates the unique index keys are looked up by.
+func NewMongoAPIKeys(ctx context.Context, m *Mongo, collection string) (*MongoAPIKeys, error) {
+	s := &MongoAPIKeys{keys: m.DB.Collection(collection)}
+	_, err := s.keys.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "hash", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	return s, err
+}
+
+func (s *MongoAPIKeys) CreateAPIKey(ctx context.Context, k APIKey) error {
+	_, err := s.keys.InsertOne(ctx, k)
+	if mongo.IsDuplicateKeyError(err) { return ErrDuplicate }
+	return err
+}
+
+func (s *MongoAPIKeys) APIKeyByHash(ctx context.Context, hash string) (APIKey, error) {
+	var k APIKey
+	err := s.keys.FindOne(ctx, bson.M{"hash": hash, "revoked_at": nil}).Decode(&k)
+	if errors.Is(err, mongo.ErrNoDocuments) { return k, ErrNotFound }
+	return k, err
+}
+
+func (s *MongoAPIKeys) RevokeAPIKey(ctx context.Context, id, userID primitive.ObjectID) error {
+	res, err := s.keys.UpdateOne(ctx,
+		bson.M{"_id": id, "user_id": userID, "revoked_at": nil},
+		bson.M{"$set": bson.M{"revoked_at": time.Now().UTC()}})
+	if err != nil { return err }
+	if res.MatchedCount == 0 { return ErrNotFound }
+	return nil
+}
+
+func (s *MongoAPIKeys) RevokeTenantAPIKey(ctx context.Context, tid string, id primitive.ObjectID) error {
+	res, err := s.keys.UpdateOne(ctx,
+		bson.M{"_id": id, "tenant": tenantFilter(tid), "revoked_at": nil},
+		bson.M{"$set": bson.M{"revoked_at": time.Now().UTC()}})
+	if err != nil { return err }
+	if res.MatchedCount == 0 { return ErrNotFound }
+	return nil
+}
+
+func (s *MongoAPIKeys) APIKeys(ctx context.Context, tid string) ([]APIKey, error) {
+	cur, err := s.keys.Find(ctx, bson.M{"tenant": tenantFilter(tid)}, options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}}))
+	if err != nil { return nil, err }
+	keys := []APIKey{}
+	return keys, cur.All(ctx, &keys)
+}
+
+func (s *MongoAPIKeys) SetAPIKeyRole(ctx context.Context, tid string, id primitive.ObjectID, role string) (APIKey, error) {
+	var k APIKey
+	err := s.keys.FindOneAndUpdate(ctx, bson.M{"_id": id, "tenant": tenantFilter(tid)}, bson.M{"$set": bson.M{"role": role}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&k)
+	if errors.Is(err, mongo.ErrNoDocuments) { return k, ErrNotFound }
+	return k, err
+}
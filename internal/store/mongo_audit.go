@@ -0,0 +1,51 @@
+package store
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"app/internal/tenant"
+)
+
+// MongoAudit is the MongoDB AuditStore. Entries are never updated or
+// removed by the service.
+type MongoAudit struct {
+	audit *mongo.Collection
+}
+
+// NewMongoAudit also creates the indexes ListAudit walks: one for the
+// history of a single entity, one for a whole tenant's.
+func NewMongoAudit(ctx context.Context, m *Mongo, collection string) (*MongoAudit, error) {
+	s := &MongoAudit{audit: m.Collection(collection)}
+	_, err := s.audit.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "tenant", Value: 1}, {Key: "entity", Value: 1}, {Key: "entity_id", Value: 1}, {Key: "_id", Value: -1}}},
+		{Keys: bson.D{{Key: "tenant", Value: 1}, {Key: "_id", Value: -1}}},
+	})
+	return s, err
+}
+
+func (s *MongoAudit) RecordAudit(ctx context.Context, entries []AuditEntry) error {
+	if len(entries) == 0 { return nil }
+	docs, tid := make([]any, len(entries)), tenant.FromContext(ctx)
+	for i, e := range entries {
+		e.Tenant = tid
+		docs[i] = e
+	}
+	_, err := s.audit.InsertMany(ctx, docs)
+	return err
+}
+
+func (s *MongoAudit) ListAudit(ctx context.Context, q AuditQuery) (AuditPage, error) {
+	filter := bson.M{"tenant": tenant.FromContext(ctx)}
+	if q.Entity != "" { filter["entity"] = q.Entity }
+	if !q.EntityID.IsZero() { filter["entity_id"] = q.EntityID }
+	if !q.Before.IsZero() { filter["_id"] = bson.M{"$lt": q.Before} }
+	cur, err := s.audit.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "_id", Value: -1}}).SetLimit(q.Limit+1))
+	if err != nil { return AuditPage{}, err }
+	entries := []AuditEntry{}
+	if err := cur.All(ctx, &entries); err != nil { return AuditPage{}, err }
+	return auditPage(entries, q.Limit), nil
+}
@@ -0,0 +1,44 @@
+package store
+
+import (
+	"context"
+	"errors"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"app/internal/tenant"
+)
+
+// MongoCaptures is the MongoDB CaptureStore: a capped collection, which
+// drops its oldest documents itself once it reaches its size.
+type MongoCaptures struct {
+	captures *mongo.Collection
+}
+
+// NewMongoCaptures creates the collection capped at maxBytes, unless it
+// exists already: a capped collection keeps the size it was made with, so
+// changing it means dropping the collection.
+func NewMongoCaptures(ctx context.Context, m *Mongo, collection string, maxBytes int64) (*MongoCaptures, error) {
+	err := m.DB.CreateCollection(ctx, collection, options.CreateCollection().SetCapped(true).SetSizeInBytes(maxBytes))
+	var cmdErr mongo.CommandError
+	if err != nil && !(errors.As(err, &cmdErr) && cmdErr.Name == "NamespaceExists") { return nil, err }
+	s := &MongoCaptures{captures: m.DB.Collection(collection)}
+	_, err = s.captures.Indexes().CreateOne(ctx, mongo.IndexModel{Keys: bson.D{{Key: "request_id", Value: 1}, {Key: "tenant", Value: 1}}})
+	return s, err
+}
+
+func (s *MongoCaptures) SaveCapture(ctx context.Context, c *Capture) error {
+	_, err := s.captures.InsertOne(ctx, c)
+	return err
+}
+
+func (s *MongoCaptures) Capture(ctx context.Context, requestID string) (Capture, error) {
+	var c Capture
+	// A capped collection keeps insertion order: the last in $natural order
+	// is the latest.
+	err := s.captures.FindOne(ctx, bson.M{"request_id": requestID, "tenant": tenant.FromContext(ctx)}, options.FindOne().SetSort(bson.M{"$natural": -1})).Decode(&c)
+	if errors.Is(err, mongo.ErrNoDocuments) { return c, ErrNotFound }
+	return c, err
+}
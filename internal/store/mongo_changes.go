@@ -0,0 +1,80 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"app/internal/tenant"
+)
+
+// MongoChangeLog is the MongoDB ChangeLogStore: a document per change, and
+// one per tenant holding the number of its latest, which AppendChanges
+// $incs. Inside a MongoNames transaction both commit with the writes, and
+// two transactions appending for a tenant conflict on the latter, so one
+// waits out the other: the numbers follow the order the writes commit in.
+// Without transactions a change can be stored after one numbered later.
+type MongoChangeLog struct {
+	log       *mongo.Collection
+	retention time.Duration
+}
+
+// changeDoc is a change as stored, with the time MongoDB drops it.
+type changeDoc struct {
+	Change   `bson:",inline"`
+	ExpireAt time.Time `bson:"expire_at"`
+}
+
+// NewMongoChangeLog also creates the collection, which a transaction can't
+// on older servers, and the indexes: tenant and number, unique, which
+// Changes goes by, and expire_at, which drops the changes retention old.
+// The tenants' counters have neither field, so neither index holds them.
+func NewMongoChangeLog(ctx context.Context, m *Mongo, name string, retention time.Duration) (*MongoChangeLog, error) {
+	s := &MongoChangeLog{log: m.Collection(name), retention: retention}
+	_, err := s.log.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "tenant", Value: 1}, {Key: "seq", Value: 1}}, Options: options.Index().SetName("tenant_seq").SetUnique(true).SetSparse(true)},
+		{Keys: bson.D{{Key: "expire_at", Value: 1}}, Options: options.Index().SetName("expire_at").SetExpireAfterSeconds(0)},
+	})
+	return s, err
+}
+
+// counter is the _id of the document counting tid's changes.
+func counter(tid string) string { return "last:" + tid }
+
+func (s *MongoChangeLog) AppendChanges(ctx context.Context, cs []Change) error {
+	if len(cs) == 0 { return nil }
+	tid := tenant.FromContext(ctx)
+	var last struct{ Last int64 `bson:"last"` }
+	err := s.log.FindOneAndUpdate(ctx, bson.M{"_id": counter(tid)}, bson.M{"$inc": bson.M{"last": len(cs)}},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&last)
+	if err != nil { return err }
+	docs := make([]any, len(cs))
+	for i := range cs {
+		c := &cs[i]
+		c.Seq, c.Tenant, c.At = last.Last-int64(len(cs)-1-i), tid, c.At.UTC().Truncate(time.Millisecond)
+		docs[i] = changeDoc{Change: *c, ExpireAt: c.At.Add(s.retention)}
+	}
+	_, err = s.log.InsertMany(ctx, docs)
+	return err
+}
+
+func (s *MongoChangeLog) Changes(ctx context.Context, since int64, limit int) ([]Change, error) {
+	cur, err := s.log.Find(ctx, bson.M{"tenant": tenant.FromContext(ctx), "seq": bson.M{"$gt": since}},
+		options.Find().SetSort(bson.D{{Key: "seq", Value: 1}}).SetLimit(int64(limit)))
+	if err != nil { return nil, err }
+	cs := []Change{}
+	if err := cur.All(ctx, &cs); err != nil { return nil, err }
+	return cs, nil
+}
+
+func (s *MongoChangeLog) LastChange(ctx context.Context) (int64, error) {
+	var last struct{ Last int64 `bson:"last"` }
+	err := s.log.FindOne(ctx, bson.M{"_id": counter(tenant.FromContext(ctx))}).Decode(&last)
+	if errors.Is(err, mongo.ErrNoDocuments) { return 0, nil }
+	return last.Last, err
+}
@@ -0,0 +1,142 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"slices"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"app/internal/tenant"
+)
+
+// MongoDocs is the MongoDB DocStore: a collection per resource, holding the
+// fields at the top level of each document next to _id, tenant, created_at,
+// updated_at and version.
+type MongoDocs struct {
+	m *Mongo
+}
+
+// NewMongoDocs creates the index documents are listed by in each of
+// collections.
+func NewMongoDocs(ctx context.Context, m *Mongo, collections []string) (*MongoDocs, error) {
+	s := &MongoDocs{m: m}
+	for _, c := range collections {
+		_, err := m.Collection(c).Indexes().CreateOne(ctx, mongo.IndexModel{
+			Keys:    bson.D{{Key: "tenant", Value: 1}, {Key: "_id", Value: 1}},
+			Options: options.Index().SetName("tenant_id"),
+		})
+		if err != nil { return nil, err }
+	}
+	return s, nil
+}
+
+var docMeta = []string{"_id", "tenant", "created_at", "updated_at", "version"}
+
+func docToBSON(d Doc) bson.M {
+	m := maps.Clone(d.Fields)
+	if m == nil { m = bson.M{} }
+	m["_id"], m["tenant"], m["created_at"], m["updated_at"], m["version"] = d.ID, d.Tenant, d.CreatedAt, d.UpdatedAt, d.Version
+	return m
+}
+
+func docFromBSON(m bson.M) Doc {
+	d := Doc{Fields: map[string]any{}}
+	d.ID, _ = m["_id"].(primitive.ObjectID)
+	d.Tenant, _ = m["tenant"].(string)
+	d.CreatedAt, d.UpdatedAt = bsonTime(m["created_at"]), bsonTime(m["updated_at"])
+	d.Version, _ = m["version"].(int64)
+	for k, v := range m {
+		if !slices.Contains(docMeta, k) { d.Fields[k] = bsonValue(v) }
+	}
+	return d
+}
+
+func bsonTime(v any) time.Time {
+	dt, _ := v.(primitive.DateTime)
+	return dt.Time().UTC()
+}
+
+// bsonValue turns what the driver decodes into the types Validate returns.
+func bsonValue(v any) any {
+	switch v := v.(type) {
+	case primitive.DateTime:
+		return v.Time().UTC()
+	case int32:
+		return int64(v)
+	}
+	return v
+}
+
+func (s *MongoDocs) CreateDoc(ctx context.Context, collection string, d *Doc) error {
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	d.ID, d.Tenant, d.CreatedAt, d.UpdatedAt, d.Version = primitive.NewObjectID(), tenant.FromContext(ctx), now, now, 1
+	_, err := s.m.Collection(collection).InsertOne(ctx, docToBSON(*d))
+	return err
+}
+
+func (s *MongoDocs) GetDoc(ctx context.Context, collection string, id primitive.ObjectID) (Doc, error) {
+	var m bson.M
+	err := s.m.Collection(collection).FindOne(ctx, bson.M{"tenant": tenant.FromContext(ctx), "_id": id}).Decode(&m)
+	if errors.Is(err, mongo.ErrNoDocuments) { return Doc{}, ErrNotFound }
+	if err != nil { return Doc{}, err }
+	return docFromBSON(m), nil
+}
+
+func (s *MongoDocs) ListDocs(ctx context.Context, collection string, after primitive.ObjectID, limit int) (DocPage, error) {
+	filter := bson.M{"tenant": tenant.FromContext(ctx), "_id": bson.M{"$gt": after}}
+	// One more than asked for tells whether there is a next page.
+	cur, err := s.m.Collection(collection).Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}).SetLimit(int64(limit)+1))
+	if err != nil { return DocPage{}, err }
+	var ms []bson.M
+	if err := cur.All(ctx, &ms); err != nil { return DocPage{}, err }
+	page := DocPage{Items: []Doc{}}
+	for i, m := range ms {
+		if i == limit { page.Next = page.Items[i-1].ID.Hex(); break }
+		page.Items = append(page.Items, docFromBSON(m))
+	}
+	return page, nil
+}
+
+func (s *MongoDocs) UpdateDoc(ctx context.Context, collection string, id primitive.ObjectID, fields map[string]any, ifVersion int64) (Doc, error) {
+	c := s.m.Collection(collection)
+	filter := bson.M{"tenant": tenant.FromContext(ctx), "_id": id}
+	var old bson.M
+	if err := c.FindOne(ctx, filter).Decode(&old); errors.Is(err, mongo.ErrNoDocuments) {
+		return Doc{}, ErrNotFound
+	} else if err != nil {
+		return Doc{}, err
+	}
+	d := docFromBSON(old)
+	if ifVersion != AnyVersion && d.Version != ifVersion { return Doc{}, ErrVersionMismatch }
+	// Replacing the whole document drops the fields left out; the version
+	// in the filter catches a write that got in between.
+	d.Fields, d.UpdatedAt, d.Version = fields, time.Now().UTC().Truncate(time.Millisecond), d.Version+1
+	res, err := c.ReplaceOne(ctx, withVersion(filter, d.Version-1), docToBSON(d))
+	if err != nil { return Doc{}, err }
+	if res.MatchedCount == 0 { return Doc{}, missedDoc(ctx, c, filter) }
+	return d, nil
+}
+
+func (s *MongoDocs) DeleteDoc(ctx context.Context, collection string, id primitive.ObjectID, ifVersion int64) error {
+	c := s.m.Collection(collection)
+	filter := bson.M{"tenant": tenant.FromContext(ctx), "_id": id}
+	res, err := c.DeleteOne(ctx, withVersion(filter, ifVersion))
+	if err != nil { return err }
+	if res.DeletedCount > 0 { return nil }
+	if ifVersion == AnyVersion { return ErrNotFound }
+	return missedDoc(ctx, c, filter)
+}
+
+// missedDoc is MongoNames.missed for documents.
+func missedDoc(ctx context.Context, c *mongo.Collection, filter bson.M) error {
+	n, err := c.CountDocuments(ctx, filter, options.Count().SetLimit(1))
+	if err != nil { return err }
+	if n > 0 { return ErrVersionMismatch }
+	return ErrNotFound
+}
@@ -0,0 +1,41 @@
+package store
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"app/internal/tenant"
+)
+
+// Duplicates groups the tenant's live names in an aggregation at collation
+// strength 1, where names equal but for case and accents are one key. For
+// equal names alone, only the groups of more than one leave the database;
+// with a distance, every group does, to be compared here.
+func (s *MongoNames) Duplicates(ctx context.Context, q DuplicateQuery) ([]DuplicateCluster, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"tenant": tenant.FromContext(ctx), "deleted_at": nil}}},
+		{{Key: "$group", Value: bson.M{"_id": "$name", "names": bson.M{"$push": "$$ROOT"}}}},
+	}
+	if q.Distance == 0 { pipeline = append(pipeline, bson.D{{Key: "$match", Value: bson.M{"names.1": bson.M{"$exists": true}}}}) }
+	cur, err := s.names.Aggregate(ctx, pipeline, options.Aggregate().SetCollation(s.collation.folding()).SetAllowDiskUse(true))
+	if err != nil { return nil, err }
+	defer cur.Close(ctx)
+
+	groups := map[string][]Name{}
+	for cur.Next(ctx) {
+		var g struct {
+			Names []Name `bson:"names"`
+		}
+		if err := cur.Decode(&g); err != nil { return nil, err }
+		// The collation's idea of equal and NormalizeName's may differ at
+		// the edges; groups meeting under one key here are merged.
+		key := NormalizeName(g.Names[0].Name)
+		groups[key] = append(groups[key], g.Names...)
+		if q.Distance > 0 && len(groups) > MaxFuzzyKeys { return nil, ErrTooManyNames }
+	}
+	if err := cur.Err(); err != nil { return nil, err }
+	return clusters(groups, q)
+}
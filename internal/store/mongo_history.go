@@ -0,0 +1,65 @@
+package store
+
+import (
+	"context"
+	"errors"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"app/internal/tenant"
+)
+
+// MongoHistory is the MongoDB HistoryStore: one document per kept version,
+// holding the name as it was.
+type MongoHistory struct {
+	history *mongo.Collection
+}
+
+type historyDoc struct {
+	ID      primitive.ObjectID `bson:"_id"`
+	Tenant  string             `bson:"tenant"`
+	NameID  primitive.ObjectID `bson:"name_id"`
+	Version int64              `bson:"version"`
+	Name    Name               `bson:"name"`
+}
+
+// NewMongoHistory also creates the unique index that keeps a version from
+// being saved twice, which Versions reads along.
+func NewMongoHistory(ctx context.Context, m *Mongo, collection string) (*MongoHistory, error) {
+	s := &MongoHistory{history: m.Collection(collection)}
+	_, err := s.history.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "tenant", Value: 1}, {Key: "name_id", Value: 1}, {Key: "version", Value: -1}},
+		Options: options.Index().SetUnique(true),
+	})
+	return s, err
+}
+
+func (s *MongoHistory) SaveVersions(ctx context.Context, ns []Name) error {
+	if len(ns) == 0 { return nil }
+	docs, tid := make([]any, len(ns)), tenant.FromContext(ctx)
+	for i, n := range ns {
+		n.Tenant = tid
+		docs[i] = historyDoc{ID: primitive.NewObjectID(), Tenant: tid, NameID: n.ID, Version: n.Version, Name: n}
+	}
+	// Versions already kept fail on the unique index; only other errors count.
+	_, err := s.history.InsertMany(ctx, docs, options.InsertMany().SetOrdered(false))
+	var bwe mongo.BulkWriteException
+	if err == nil || !errors.As(err, &bwe) || bwe.WriteConcernError != nil { return err }
+	for _, we := range bwe.WriteErrors {
+		if !mongo.IsDuplicateKeyError(we.WriteError) { return err }
+	}
+	return nil
+}
+
+func (s *MongoHistory) Versions(ctx context.Context, id primitive.ObjectID) ([]Name, error) {
+	cur, err := s.history.Find(ctx, bson.M{"tenant": tenant.FromContext(ctx), "name_id": id}, options.Find().SetSort(bson.D{{Key: "version", Value: -1}}))
+	if err != nil { return nil, err }
+	var docs []historyDoc
+	if err := cur.All(ctx, &docs); err != nil { return nil, err }
+	out := make([]Name, len(docs))
+	for i, d := range docs { out[i] = d.Name }
+	return out, nil
+}
@@ -0,0 +1,59 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoIdempotency is the MongoDB IdempotencyStore. The unique _id is what
+// serialises concurrent requests carrying the same key.
+type MongoIdempotency struct {
+	keys *mongo.Collection
+}
+
+// NewMongoIdempotency also lets Mongo purge expired keys. Expiry is stored
+// per document, so changing the TTL never requires rebuilding the index.
+func NewMongoIdempotency(ctx context.Context, m *Mongo, collection string) (*MongoIdempotency, error) {
+	s := &MongoIdempotency{keys: m.DB.Collection(collection)}
+	_, err := s.keys.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expires_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	return s, err
+}
+
+func (s *MongoIdempotency) Claim(ctx context.Context, key, requestHash string, ttl time.Duration) (*IdempotencyRecord, error) {
+	for {
+		now := time.Now().UTC()
+		_, err := s.keys.InsertOne(ctx, IdempotencyRecord{Key: key, RequestHash: requestHash, ExpiresAt: now.Add(ttl)})
+		if err == nil { return nil, nil }
+		if !mongo.IsDuplicateKeyError(err) { return nil, err }
+
+		var rec IdempotencyRecord
+		err = s.keys.FindOne(ctx, bson.M{"_id": key}).Decode(&rec)
+		if errors.Is(err, mongo.ErrNoDocuments) { continue } // released or purged meanwhile; try again
+		if err != nil { return nil, err }
+
+		// The TTL monitor only runs once a minute; treat stale records as gone.
+		if rec.ExpiresAt.Before(now) {
+			if _, err := s.keys.DeleteOne(ctx, bson.M{"_id": key, "expires_at": rec.ExpiresAt}); err != nil { return nil, err }
+			continue
+		}
+		return &rec, nil
+	}
+}
+
+func (s *MongoIdempotency) Complete(ctx context.Context, key string, status int, headers map[string]string, body []byte) error {
+	_, err := s.keys.UpdateByID(ctx, key, bson.M{"$set": bson.M{"status": status, "headers": headers, "body": body}})
+	return err
+}
+
+func (s *MongoIdempotency) Release(ctx context.Context, key string) error {
+	_, err := s.keys.DeleteOne(ctx, bson.M{"_id": key})
+	return err
+}
@@ -0,0 +1,95 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"app/internal/tenant"
+)
+
+// MongoJobs is the MongoDB JobStore. Claims are a findAndModify, so two
+// workers, in this process or another, never get the same job.
+type MongoJobs struct {
+	jobs *mongo.Collection
+}
+
+// NewMongoJobs also creates the indexes: one for claiming, by status in
+// queue order, and one for purging.
+func NewMongoJobs(ctx context.Context, m *Mongo, collection string) (*MongoJobs, error) {
+	s := &MongoJobs{jobs: m.Collection(collection)}
+	_, err := s.jobs.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "_id", Value: 1}}, Options: options.Index().SetName("status_id")},
+		{Keys: bson.D{{Key: "finished_at", Value: 1}}, Options: options.Index().SetName("finished_at").SetSparse(true)},
+	})
+	return s, err
+}
+
+func (s *MongoJobs) EnqueueJob(ctx context.Context, j *Job) error {
+	j.ID, j.Tenant, j.Status, j.CreatedAt = primitive.NewObjectID(), tenant.FromContext(ctx), JobQueued, time.Now().UTC().Truncate(time.Millisecond)
+	_, err := s.jobs.InsertOne(ctx, j)
+	return err
+}
+
+func (s *MongoJobs) Job(ctx context.Context, id primitive.ObjectID) (Job, error) {
+	var j Job
+	err := s.jobs.FindOne(ctx, bson.M{"_id": id, "tenant": tenant.FromContext(ctx)}, options.FindOne().SetProjection(bson.M{"input": 0, "output": 0})).Decode(&j)
+	if errors.Is(err, mongo.ErrNoDocuments) { return j, ErrNotFound }
+	return j, err
+}
+
+func (s *MongoJobs) JobOutput(ctx context.Context, id primitive.ObjectID) ([]byte, error) {
+	var j Job
+	err := s.jobs.FindOne(ctx, bson.M{"_id": id, "tenant": tenant.FromContext(ctx)}, options.FindOne().SetProjection(bson.M{"output": 1})).Decode(&j)
+	if errors.Is(err, mongo.ErrNoDocuments) { return nil, ErrNotFound }
+	return j.Output, err
+}
+
+func (s *MongoJobs) ClaimJob(ctx context.Context, lease time.Duration) (Job, error) {
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	filter := bson.M{"$or": bson.A{
+		bson.M{"status": JobQueued},
+		bson.M{"status": JobRunning, "lease_until": bson.M{"$lt": now}},
+	}}
+	update := bson.M{"$set": bson.M{"status": JobRunning, "lease_until": now.Add(lease), "started_at": now}, "$inc": bson.M{"attempts": 1}}
+	var j Job
+	err := s.jobs.FindOneAndUpdate(ctx, filter, update, options.FindOneAndUpdate().SetSort(bson.D{{Key: "_id", Value: 1}}).SetReturnDocument(options.After)).Decode(&j)
+	if errors.Is(err, mongo.ErrNoDocuments) { return j, ErrNotFound }
+	return j, err
+}
+
+// update applies u to job j if no one has claimed it since j was.
+func (s *MongoJobs) update(ctx context.Context, j Job, u bson.M) error {
+	res, err := s.jobs.UpdateOne(ctx, bson.M{"_id": j.ID, "status": JobRunning, "attempts": j.Attempts}, u)
+	if err != nil { return err }
+	if res.MatchedCount == 0 {
+		if n, err := s.jobs.CountDocuments(ctx, bson.M{"_id": j.ID}); err != nil || n == 0 { return errors.Join(ErrNotFound, err) }
+		return ErrVersionMismatch
+	}
+	return nil
+}
+
+func (s *MongoJobs) RenewJob(ctx context.Context, j Job, lease time.Duration) error {
+	return s.update(ctx, j, bson.M{"$set": bson.M{"lease_until": time.Now().UTC().Truncate(time.Millisecond).Add(lease)}})
+}
+
+func (s *MongoJobs) FinishJob(ctx context.Context, j Job) error {
+	if j.Status == JobQueued {
+		return s.update(ctx, j, bson.M{"$set": bson.M{"status": JobQueued}, "$unset": bson.M{"lease_until": ""}})
+	}
+	set := bson.M{"status": j.Status, "finished_at": time.Now().UTC().Truncate(time.Millisecond)}
+	if j.Result != nil { set["result"] = j.Result }
+	if j.Output != nil { set["output"] = j.Output }
+	if j.Error != "" { set["error"] = j.Error }
+	return s.update(ctx, j, bson.M{"$set": set, "$unset": bson.M{"lease_until": ""}})
+}
+
+func (s *MongoJobs) PurgeJobs(ctx context.Context, before time.Time) error {
+	_, err := s.jobs.DeleteMany(ctx, bson.M{"finished_at": bson.M{"$lt": before}})
+	return err
+}
@@ -0,0 +1,39 @@
+package store
+
+import (
+	"context"
+	"errors"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"app/internal/tenant"
+)
+
+// NameByKey looks in both unique indexes, one per branch of the $or.
+func (s *MongoNames) NameByKey(ctx context.Context, key string) (primitive.ObjectID, error) {
+	var doc struct {
+		ID primitive.ObjectID `bson:"_id"`
+	}
+	filter := bson.M{"tenant": tenant.FromContext(ctx), "$or": bson.A{bson.M{"uuid": key}, bson.M{"slug": key}}}
+	err := s.names.FindOne(ctx, filter, options.FindOne().SetProjection(bson.M{"_id": 1})).Decode(&doc)
+	if errors.Is(err, mongo.ErrNoDocuments) { return doc.ID, ErrNotFound }
+	return doc.ID, err
+}
+
+func (s *MongoNames) NameByName(ctx context.Context, name string) (Name, error) {
+	return s.named(ctx, name)
+}
+
+func (s *MongoNames) SlugsTaken(ctx context.Context, slugs []string) (map[string]bool, error) {
+	out := map[string]bool{}
+	if len(slugs) == 0 { return out, nil }
+	found, err := s.names.Distinct(ctx, "slug", bson.M{"tenant": tenant.FromContext(ctx), "slug": bson.M{"$in": slugs}})
+	if err != nil { return nil, err }
+	for _, v := range found {
+		if slug, ok := v.(string); ok { out[slug] = true }
+	}
+	return out, nil
+}
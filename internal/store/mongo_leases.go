@@ -0,0 +1,53 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// MongoLeases is the MongoDB LeaseStore: a document per lease, taken and
+// renewed in one conditional upsert. Expiry goes by the server's clock,
+// $$NOW, so that the instances' clocks needn't agree.
+type MongoLeases struct {
+	leases *mongo.Collection
+}
+
+func NewMongoLeases(m *Mongo, collection string) *MongoLeases {
+	return &MongoLeases{leases: m.DB.Collection(collection)}
+}
+
+func (s *MongoLeases) AcquireLease(ctx context.Context, name, holder string, ttl time.Duration) (Lease, error) {
+	// The filter matches the lease if holder may have it; if it doesn't,
+	// the upsert collides with the lease another holds. The new term and
+	// acquired_at go by the holder before the update.
+	filter := bson.M{"_id": name, "$or": bson.A{
+		bson.M{"holder": holder},
+		bson.M{"$expr": bson.M{"$lte": bson.A{"$expires_at", "$$NOW"}}},
+	}}
+	renewing := bson.M{"$eq": bson.A{"$holder", holder}}
+	update := mongo.Pipeline{{{Key: "$set", Value: bson.M{
+		"holder":      holder,
+		"term":        bson.M{"$cond": bson.A{renewing, "$term", bson.M{"$add": bson.A{bson.M{"$ifNull": bson.A{"$term", 0}}, 1}}}},
+		"acquired_at": bson.M{"$cond": bson.A{renewing, "$acquired_at", "$$NOW"}},
+		"renewed_at":  "$$NOW",
+		"expires_at":  bson.M{"$add": bson.A{"$$NOW", ttl.Milliseconds()}},
+	}}}}
+	for {
+		var l Lease
+		err := s.leases.FindOneAndUpdate(ctx, filter, update, options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)).Decode(&l)
+		if !mongo.IsDuplicateKeyError(err) { return l, err }
+		err = s.leases.FindOne(ctx, bson.M{"_id": name}).Decode(&l)
+		if errors.Is(err, mongo.ErrNoDocuments) { continue } // removed meanwhile; try again
+		return l, err
+	}
+}
+
+func (s *MongoLeases) ReleaseLease(ctx context.Context, name, holder string) error {
+	_, err := s.leases.UpdateOne(ctx, bson.M{"_id": name, "holder": holder}, mongo.Pipeline{{{Key: "$set", Value: bson.M{"holder": "", "expires_at": "$$NOW"}}}})
+	return err
+}
@@ -0,0 +1,163 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"app/internal/tenant"
+)
+
+// MongoCollections names the collections the migrations change, as
+// configured.
+type MongoCollections struct {
+	Names, Events string
+}
+
+// mongoMigration is one change to the shape of the documents in MongoDB.
+// up must be safe to run on documents it has already changed: deployments
+// from before migrations start at version 0 with some done ad hoc, and a
+// crash between a migration and its record runs it again. down undoes it;
+// nil if it can't be.
+type mongoMigration struct {
+	name     string
+	up, down func(ctx context.Context, m *Mongo, cols MongoCollections) error
+}
+
+// mongoMigrations are applied in order and recorded in schema_migrations,
+// like the SQL store's. Never edit one that has shipped; append. Indexes
+// that only ever grow are left to the stores that use them, which create
+// them as they open; a migration is for documents to rewrite, and for
+// indexes to drop, replace or build over data that is already there.
+var mongoMigrations = []mongoMigration{
+	{ // 1
+		name: "move names and events from before tenants to the default tenant",
+		up: func(ctx context.Context, m *Mongo, cols MongoCollections) error {
+			untenanted := bson.M{"tenant": bson.M{"$exists": false}}
+			for _, c := range []string{cols.Names, cols.Events} {
+				if _, err := m.Collection(c).UpdateMany(ctx, untenanted, bson.M{"$set": bson.M{"tenant": tenant.Default}}); err != nil {
+					return fmt.Errorf("moving %s to the default tenant: %w", c, err)
+				}
+			}
+			// The indexes from before tenants, which NewMongoNames replaces.
+			for _, name := range []string{"name_tags_text", "name_unique"} {
+				if err := dropIndex(ctx, m.Collection(cols.Names), name); err != nil { return err }
+			}
+			return nil
+		},
+	},
+	{ // 2
+		name: "date names from before timestamps by their ObjectID",
+		up: func(ctx context.Context, m *Mongo, cols MongoCollections) error {
+			born := bson.M{"$toDate": "$_id"}
+			_, err := m.Collection(cols.Names).UpdateMany(ctx, bson.M{"created_at": bson.M{"$exists": false}},
+				bson.A{bson.M{"$set": bson.M{"created_at": born, "updated_at": bson.M{"$ifNull": bson.A{"$updated_at", born}}}}})
+			return err
+		},
+		// Undoing takes the dates off every name whose dates are still the
+		// ObjectID's: the backfilled ones, and any created on the second
+		// exactly and never changed since.
+		down: func(ctx context.Context, m *Mongo, cols MongoCollections) error {
+			backfilled := bson.M{"$expr": bson.M{"$and": bson.A{
+				bson.M{"$eq": bson.A{"$created_at", bson.M{"$toDate": "$_id"}}},
+				bson.M{"$eq": bson.A{"$updated_at", "$created_at"}},
+			}}}
+			_, err := m.Collection(cols.Names).UpdateMany(ctx, backfilled, bson.M{"$unset": bson.M{"created_at": "", "updated_at": ""}})
+			return err
+		},
+	},
+	{ // 3
+		name: "index names by creator, for ?mine=true",
+		up: func(ctx context.Context, m *Mongo, cols MongoCollections) error {
+			_, err := m.Collection(cols.Names).Indexes().CreateOne(ctx, mongo.IndexModel{
+				Keys:    bson.D{{Key: "tenant", Value: 1}, {Key: "created_by", Value: 1}, {Key: "_id", Value: 1}},
+				Options: options.Index().SetName("tenant_created_by").SetSparse(true),
+			})
+			return err
+		},
+		down: func(ctx context.Context, m *Mongo, cols MongoCollections) error {
+			return dropIndex(ctx, m.Collection(cols.Names), "tenant_created_by")
+		},
+	},
+}
+
+// LatestMongoMigration is the version the migrations of this build bring
+// MongoDB to.
+func LatestMongoMigration() int { return len(mongoMigrations) }
+
+const migrationsCollection = "schema_migrations"
+
+// migrations is where each applied migration is recorded, as
+// {_id: version, name, applied_at}, next to the {_id: "lock"} document.
+// It skips the configured read preference: a secondary may be behind.
+func (m *Mongo) migrations() *mongo.Collection { return m.DB.Collection(migrationsCollection) }
+
+// MigrationVersion returns the last migration applied to MongoDB; 0 if none.
+func (m *Mongo) MigrationVersion(ctx context.Context) (int, error) {
+	var last struct {
+		Version int `bson:"_id"`
+	}
+	err := m.migrations().FindOne(ctx, bson.M{"_id": bson.M{"$type": "number"}}, options.FindOne().SetSort(bson.D{{Key: "_id", Value: -1}})).Decode(&last)
+	if errors.Is(err, mongo.ErrNoDocuments) { return 0, nil }
+	return last.Version, err
+}
+
+// Migrate brings MongoDB to version target, applying the migrations after
+// the current version or undoing those after target, and returns the
+// versions it went from and to; a negative target is the latest. Instances
+// starting together take turns: the first migrates, the others find nothing
+// left to do. A database newer than this build is left alone.
+func (m *Mongo) Migrate(ctx context.Context, cols MongoCollections, target int) (from, to int, err error) {
+	latest := LatestMongoMigration()
+	if target < 0 { target = latest }
+	if target > latest { return 0, 0, fmt.Errorf("there is no migration %d; the latest is %d", target, latest) }
+	release, err := m.lockMigrations(ctx)
+	if err != nil { return 0, 0, err }
+	defer release()
+
+	if from, err = m.MigrationVersion(ctx); err != nil { return 0, 0, err }
+	if from > latest {
+		slog.WarnContext(ctx, "MongoDB was migrated by a newer build", "version", from, "latest", latest)
+		return from, from, nil
+	}
+	for to = from; to < target; to++ {
+		mig := mongoMigrations[to]
+		slog.InfoContext(ctx, "applying MongoDB migration", "version", to+1, "name", mig.name)
+		if err := mig.up(ctx, m, cols); err != nil { return from, to, fmt.Errorf("migration %d (%s): %w", to+1, mig.name, err) }
+		if _, err := m.migrations().InsertOne(ctx, bson.M{"_id": to + 1, "name": mig.name, "applied_at": time.Now().UTC()}); err != nil { return from, to, err }
+	}
+	for ; to > target; to-- {
+		mig := mongoMigrations[to-1]
+		if mig.down == nil { return from, to, fmt.Errorf("migration %d (%s) can't be undone", to, mig.name) }
+		slog.InfoContext(ctx, "undoing MongoDB migration", "version", to, "name", mig.name)
+		if err := mig.down(ctx, m, cols); err != nil { return from, to, fmt.Errorf("undoing migration %d (%s): %w", to, mig.name, err) }
+		if _, err := m.migrations().DeleteOne(ctx, bson.M{"_id": to}); err != nil { return from, to, err }
+	}
+	return from, to, nil
+}
+
+// lockMigrations takes the lock on the migrations, waiting while another
+// instance holds it, and returns its release. The lock lapses after ten
+// minutes, should its holder die midway.
+func (m *Mongo) lockMigrations(ctx context.Context) (func(), error) {
+	for {
+		now := time.Now().UTC()
+		_, err := m.migrations().UpdateOne(ctx, bson.M{"_id": "lock", "until": bson.M{"$lt": now}}, bson.M{"$set": bson.M{"until": now.Add(10 * time.Minute)}}, options.Update().SetUpsert(true))
+		if err == nil {
+			return func() { _, _ = m.migrations().DeleteOne(context.WithoutCancel(ctx), bson.M{"_id": "lock"}) }, nil
+		}
+		if !mongo.IsDuplicateKeyError(err) { return nil, err }
+		slog.InfoContext(ctx, "waiting for another instance to finish migrating MongoDB")
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(time.Second):
+		}
+	}
+}
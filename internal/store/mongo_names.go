@@ -0,0 +1,674 @@
+package store
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"app/internal/tenant"
+)
+
+// MongoNames is the MongoDB NameStore: names in one collection, their audit
+// events in another.
+type MongoNames struct {
+	client *mongo.Client
+	names  *mongo.Collection
+	events *mongo.Collection
+	// strong and eventual are the handles lists and searches of names read
+	// through (see reader).
+	strong, eventual *mongo.Collection
+	// collation orders names, and the unique index on them uses it.
+	collation Collation
+
+	// txUnsupported is set once we learn the deployment is a standalone
+	// mongod, so we stop paying for a doomed transaction on every insert.
+	txUnsupported atomic.Bool
+}
+
+// NewMongoNames also prepares the collections, once Migrate has brought
+// their documents up to date. The indexes are created, each led by
+// tenant: the text index Search relies on, a unique one on name and one
+// each on uuid and slug for the names that have them, one on the
+// normalized names for ?match=, one for listing in creation order and a
+// multikey one on tags for filtering by tag; then two
+// across tenants, on expires_at and deleted_at, for the cleanup to find
+// the names due. The unique index is rebuilt when the configured collation
+// changes. It finally turns on the pre-images Watch needs to tell whose
+// hard-deleted name it was.
+func NewMongoNames(ctx context.Context, m *Mongo, namesCollection, eventsCollection string) (*MongoNames, error) {
+	s := &MongoNames{client: m.Client, names: m.Collection(namesCollection), events: m.Collection(eventsCollection), collation: m.cfg.Collation}
+	s.strong, s.eventual = m.readers(namesCollection)
+	if same, err := s.uniqueCollated(ctx); err != nil {
+		return nil, err
+	} else if !same {
+		if err := dropIndex(ctx, s.names, "tenant_name_unique"); err != nil { return nil, err }
+	}
+
+	_, err := s.names.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "tenant", Value: 1}, {Key: "name", Value: "text"}, {Key: "tags", Value: "text"}},
+			Options: options.Index().SetName("tenant_name_tags_text").SetWeights(bson.D{{Key: "name", Value: 10}, {Key: "tags", Value: 1}}),
+		},
+		// Soft-deleted names keep their name reserved until hard-deleted, so
+		// a restore can never collide.
+		{Keys: bson.D{{Key: "tenant", Value: 1}, {Key: "name", Value: 1}}, Options: options.Index().SetName("tenant_name_unique").SetUnique(true).SetCollation(s.collation.mongo())},
+		{Keys: bson.D{{Key: "tenant", Value: 1}, {Key: "uuid", Value: 1}}, Options: options.Index().SetName("tenant_uuid_unique").SetUnique(true).SetPartialFilterExpression(bson.M{"uuid": bson.M{"$exists": true}})},
+		{Keys: bson.D{{Key: "tenant", Value: 1}, {Key: "slug", Value: 1}}, Options: options.Index().SetName("tenant_slug_unique").SetUnique(true).SetPartialFilterExpression(bson.M{"slug": bson.M{"$exists": true}})},
+		{Keys: bson.D{{Key: "tenant", Value: 1}, {Key: "name_normalized", Value: 1}}, Options: options.Index().SetName("tenant_name_normalized")},
+		{Keys: bson.D{{Key: "tenant", Value: 1}, {Key: "_id", Value: 1}}, Options: options.Index().SetName("tenant_id")},
+		{Keys: bson.D{{Key: "tenant", Value: 1}, {Key: "tags", Value: 1}, {Key: "_id", Value: 1}}, Options: options.Index().SetName("tenant_tags")},
+		{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetName("expires_at").SetSparse(true)},
+		{Keys: bson.D{{Key: "deleted_at", Value: 1}}, Options: options.Index().SetName("deleted_at").SetSparse(true)},
+	})
+	if mongo.IsDuplicateKeyError(err) {
+		err = fmt.Errorf("creating unique index on %s.name: the collection already holds duplicate names, remove them first: %w", namesCollection, err)
+	}
+	if err != nil { return nil, err }
+
+	// Pre-images need MongoDB 6.0; without them Watch can't deliver removals.
+	err = m.DB.RunCommand(ctx, bson.D{{Key: "collMod", Value: namesCollection}, {Key: "changeStreamPreAndPostImages", Value: bson.M{"enabled": true}}}).Err()
+	if err != nil { slog.WarnContext(ctx, "could not enable change stream pre-images, hard deletes won't be streamed", "err", err) }
+	return s, nil
+}
+
+// uniqueCollated reports whether the unique index on name, if there is one,
+// has the configured collation.
+func (s *MongoNames) uniqueCollated(ctx context.Context) (bool, error) {
+	cur, err := s.names.Indexes().List(ctx)
+	if err != nil { return false, fmt.Errorf("listing the indexes of %s: %w", s.names.Name(), err) }
+	var specs []struct {
+		Name      string
+		Collation *struct {
+			Locale   string
+			Strength int
+		}
+	}
+	if err := cur.All(ctx, &specs); err != nil { return false, err }
+	want := s.collation.mongo()
+	for _, spec := range specs {
+		if spec.Name != "tenant_name_unique" { continue }
+		if spec.Collation == nil || want == nil { return spec.Collation == nil && want == nil, nil }
+		// The server records the default strength, 3, when none was given.
+		return spec.Collation.Locale == want.Locale && spec.Collation.Strength == cmp.Or(want.Strength, 3), nil
+	}
+	return true, nil
+}
+
+// dropIndex drops the index called name, if there is one.
+func dropIndex(ctx context.Context, c *mongo.Collection, name string) error {
+	_, err := c.Indexes().DropOne(ctx, name)
+	var ce mongo.CommandError
+	if errors.As(err, &ce) && (ce.Code == codeIndexNotFound || ce.Code == codeNamespaceNotFound) { return nil }
+	if err != nil { return fmt.Errorf("dropping index %s.%s: %w", c.Name(), name, err) }
+	return nil
+}
+
+// localeErr maps the server's rejection of a collation to
+// ErrUnsupportedLocale.
+func localeErr(err error) error {
+	var ce mongo.CommandError
+	if errors.As(err, &ce) && ce.Code == codeBadValue && strings.Contains(ce.Message, "locale") { return fmt.Errorf("%w: %s", ErrUnsupportedLocale, ce.Message) }
+	return err
+}
+
+const (
+	codeBadValue          = 2
+	codeNamespaceNotFound = 26
+	codeIndexNotFound     = 27
+)
+
+// Create inserts n and its "created" event in one transaction, or in the
+// one ctx is inside. Standalone servers can't run transactions; there we
+// fall back to two sequential writes and accept that the event may be lost
+// on failure.
+func (s *MongoNames) Create(ctx context.Context, n *Name) error {
+	stamp(n, tenant.FromContext(ctx), time.Now().UTC())
+	write := func(ctx context.Context) error {
+		if _, err := s.names.InsertOne(ctx, n); err != nil { return err }
+		_, err := s.events.InsertOne(ctx, NameEvent{NameID: n.ID, Tenant: n.Tenant, Type: "created", Name: n.Name, At: time.Now().UTC()})
+		return err
+	}
+	if s.txUnsupported.Load() || mongo.SessionFromContext(ctx) != nil { return dupToErr(write(ctx)) }
+
+	sess, err := s.client.StartSession()
+	if err != nil { return err }
+	defer sess.EndSession(ctx)
+
+	_, err = sess.WithTransaction(ctx, func(sc mongo.SessionContext) (any, error) {
+		return nil, write(sc)
+	})
+	if isTransactionsUnsupported(err) {
+		s.txUnsupported.Store(true)
+		slog.WarnContext(ctx, "transactions not supported by this deployment, falling back to sequential writes", "err", err)
+		err = write(ctx)
+	}
+	return dupToErr(err)
+}
+
+// CreateIfAbsent upserts n on the tenant and name, under the collation of
+// the unique index, setting the rest of it only on insert: a racing call
+// either finds the document this one made or fails on the index and reads
+// it back. Like Create outside a transaction, the event is written after.
+func (s *MongoNames) CreateIfAbsent(ctx context.Context, n *Name) (bool, error) {
+	tid := tenant.FromContext(ctx)
+	stamp(n, tid, time.Now().UTC())
+	insert, err := onInsert(n)
+	if err != nil { return false, err }
+
+	var existing Name
+	filter := bson.M{"tenant": tid, "name": n.Name}
+	err = s.names.FindOneAndUpdate(ctx, filter, bson.M{"$setOnInsert": insert},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.Before).SetCollation(s.collation.mongo()),
+	).Decode(&existing)
+	if mongo.IsDuplicateKeyError(err) {
+		existing, err = s.named(ctx, n.Name)
+		if errors.Is(err, ErrNotFound) { return false, ErrDuplicate } // its UUID or slug is taken
+	}
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		_, err = s.events.InsertOne(ctx, NameEvent{NameID: n.ID, Tenant: tid, Type: "created", Name: n.Name, At: time.Now().UTC()})
+		return true, err
+	}
+	if err != nil { return false, err }
+	*n = existing
+	return false, nil
+}
+
+// onInsert is n as the fields an upsert sets on insert: all but those its
+// filter has, tenant and name.
+func onInsert(n *Name) (bson.M, error) {
+	b, err := bson.Marshal(n)
+	if err != nil { return nil, err }
+	var doc bson.M
+	if err := bson.Unmarshal(b, &doc); err != nil { return nil, err }
+	delete(doc, "tenant")
+	delete(doc, "name")
+	return doc, nil
+}
+
+// named returns the document of the tenant called name, soft-deleted or
+// not, as the unique index compares names.
+func (s *MongoNames) named(ctx context.Context, name string) (Name, error) {
+	var n Name
+	err := s.names.FindOne(ctx, bson.M{"tenant": tenant.FromContext(ctx), "name": name}, options.FindOne().SetCollation(s.collation.mongo())).Decode(&n)
+	if errors.Is(err, mongo.ErrNoDocuments) { return n, ErrNotFound }
+	return n, err
+}
+
+// Upsert is one FindOneAndUpdate on the live document of that name, which
+// inserts it unless a version is asked for. The fields only a new document
+// needs are set on insert; the version is incremented from nothing to 1.
+func (s *MongoNames) Upsert(ctx context.Context, n Name, ifVersion int64) (*Name, Name, error) {
+	tid := tenant.FromContext(ctx)
+	stamp(&n, tid, time.Now().UTC())
+	set, unset := bson.M{"updated_at": n.UpdatedAt}, bson.M{}
+	if len(n.Tags) > 0 { set["tags"] = n.Tags } else { unset["tags"] = ""; n.Tags = nil }
+	if len(n.Metadata) > 0 { set["metadata"] = n.Metadata } else { unset["metadata"] = ""; n.Metadata = nil }
+	if n.ExpiresAt != nil { set["expires_at"] = *n.ExpiresAt } else { unset["expires_at"] = "" }
+	insert := bson.M{"_id": n.ID, "created_at": n.CreatedAt}
+	if n.UUID != "" { insert["uuid"] = n.UUID }
+	if n.Slug != "" { insert["slug"] = n.Slug }
+	if n.NameNormalized != "" { insert["name_normalized"] = n.NameNormalized }
+	if n.CreatedBy != "" { insert["created_by"] = n.CreatedBy }
+	update := bson.M{"$set": set, "$setOnInsert": insert, "$inc": bson.M{"version": 1}}
+	if len(unset) > 0 { update["$unset"] = unset }
+
+	var before Name
+	filter := withVersion(bson.M{"tenant": tid, "name": n.Name, "deleted_at": bson.M{"$exists": false}}, ifVersion)
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.Before).SetCollation(s.collation.mongo())
+	err := s.names.FindOneAndUpdate(ctx, filter, update, opts.SetUpsert(ifVersion == AnyVersion)).Decode(&before)
+	if errors.Is(err, mongo.ErrNoDocuments) && ifVersion == AnyVersion {
+		_, err = s.events.InsertOne(ctx, NameEvent{NameID: n.ID, Tenant: tid, Type: "created", Name: n.Name, At: time.Now().UTC()})
+		if err != nil { return nil, Name{}, err }
+		return nil, n, nil
+	}
+	if mongo.IsDuplicateKeyError(err) {
+		// Another call created the name first, or it's soft-deleted, or n's
+		// UUID or slug is taken: only the first can still be updated.
+		err = s.names.FindOneAndUpdate(ctx, filter, update, opts.SetUpsert(false)).Decode(&before)
+		if errors.Is(err, mongo.ErrNoDocuments) { return nil, Name{}, ErrDuplicate }
+	}
+	if errors.Is(err, mongo.ErrNoDocuments) { return nil, Name{}, s.missedName(ctx, n.Name) }
+	if err != nil { return nil, Name{}, err }
+
+	after := before
+	after.Tags, after.Metadata, after.ExpiresAt = n.Tags, n.Metadata, n.ExpiresAt
+	after.UpdatedAt, after.Version = n.UpdatedAt, before.Version+1
+	return &before, after, nil
+}
+
+// missedName explains a conditional Upsert that matched nothing: the name
+// is missing, soft-deleted or at another version.
+func (s *MongoNames) missedName(ctx context.Context, name string) error {
+	n, err := s.named(ctx, name)
+	if err != nil { return err }
+	if n.DeletedAt != nil { return ErrDuplicate }
+	return ErrVersionMismatch
+}
+
+// InTransaction runs fn in a session transaction, which the driver retries
+// as a whole after a transient error. Every collection of the same client
+// written to with fn's context, not only the names, takes part.
+func (s *MongoNames) InTransaction(ctx context.Context, fn func(ctx context.Context) error) error {
+	if InTransaction(ctx) { return fn(ctx) }
+	if s.txUnsupported.Load() { return ErrTransactionsUnsupported }
+
+	sess, err := s.client.StartSession()
+	if err != nil { return err }
+	defer sess.EndSession(ctx)
+
+	var t *tx
+	_, err = sess.WithTransaction(ctx, func(sc mongo.SessionContext) (any, error) {
+		t = &tx{owner: s} // what an attempt before left for after the commit is dropped
+		return nil, fn(withTx(sc, t))
+	})
+	if isTransactionsUnsupported(err) {
+		s.txUnsupported.Store(true)
+		slog.WarnContext(ctx, "transactions not supported by this deployment", "err", err)
+		return ErrTransactionsUnsupported
+	}
+	if err != nil { return err }
+	t.committed(ctx)
+	return nil
+}
+
+// dupToErr maps a unique index violation to ErrDuplicate.
+func dupToErr(err error) error {
+	if mongo.IsDuplicateKeyError(err) { return ErrDuplicate }
+	return err
+}
+
+// stamp prepares a new document of tenant tid: an ID if it has none, and
+// both timestamps at the millisecond precision BSON stores.
+func stamp(n *Name, tid string, now time.Time) {
+	if n.ID.IsZero() { n.ID = primitive.NewObjectID() }
+	n.Tenant = tid
+	now = now.Truncate(time.Millisecond)
+	n.CreatedAt, n.UpdatedAt = now, now
+	n.Version = 1
+}
+
+// IllegalOperation (20) is what a standalone mongod answers to a transaction.
+func isTransactionsUnsupported(err error) bool {
+	var ce mongo.CommandError
+	if !errors.As(err, &ce) { return false }
+	return ce.Code == 20 || strings.Contains(ce.Message, "Transaction numbers are only allowed")
+}
+
+func (s *MongoNames) Get(ctx context.Context, id primitive.ObjectID) (Name, error) {
+	var n Name
+	err := s.names.FindOne(ctx, bson.M{"tenant": tenant.FromContext(ctx), "_id": id, "deleted_at": nil}).Decode(&n)
+	if errors.Is(err, mongo.ErrNoDocuments) { return n, ErrNotFound }
+	return n, err
+}
+
+func (s *MongoNames) Lookup(ctx context.Context, ids []primitive.ObjectID) (map[primitive.ObjectID]Name, error) {
+	out := map[primitive.ObjectID]Name{}
+	if len(ids) == 0 { return out, nil }
+	cur, err := s.names.Find(ctx, bson.M{"tenant": tenant.FromContext(ctx), "_id": bson.M{"$in": ids}})
+	if err != nil { return nil, err }
+	defer cur.Close(ctx)
+	for cur.Next(ctx) {
+		var n Name
+		if err := cur.Decode(&n); err != nil { return nil, err }
+		out[n.ID] = n
+	}
+	return out, cur.Err()
+}
+
+// reader returns the handle a list or search of consistency c reads
+// through. A transaction reads what it wrote, so from the primary.
+func (s *MongoNames) reader(ctx context.Context, c string) *mongo.Collection {
+	if c == Eventual && !InTransaction(ctx) { return s.eventual }
+	return s.strong
+}
+
+func (s *MongoNames) List(ctx context.Context, opts ListOptions) (Page, error) {
+	page := Page{Items: []Name{}}
+	tid := tenant.FromContext(ctx)
+	coll := s.collation.with(opts.Locale).mongo()
+	names := s.reader(ctx, opts.Consistency)
+	total, err := names.CountDocuments(ctx, listFilter(tid, opts), options.Count().SetCollation(coll))
+	if err != nil { return page, localeErr(err) }
+	page.Total = total
+
+	cur, err := names.Find(ctx, pageFilter(tid, opts), findOptions(opts).SetCollation(coll))
+	if err != nil { return page, localeErr(err) }
+	defer cur.Close(ctx)
+	if err := cur.All(ctx, &page.Items); err != nil { return page, err }
+
+	// findOptions asked for one extra item to tell whether there's a next page.
+	if int64(len(page.Items)) > opts.Limit {
+		page.Items = page.Items[:opts.Limit]
+		page.Next = cursorFor(opts, page.Items[opts.Limit-1])
+	}
+	return page, nil
+}
+
+func (s *MongoNames) Each(ctx context.Context, opts ListOptions, fn func(Name) error) error {
+	find := options.Find().SetSort(listSort(opts)).SetBatchSize(500).SetCollation(s.collation.with(opts.Locale).mongo()).SetProjection(listProjection(opts))
+	cur, err := s.reader(ctx, opts.Consistency).Find(ctx, listFilter(tenant.FromContext(ctx), opts), find)
+	if err != nil { return localeErr(err) }
+	defer cur.Close(context.WithoutCancel(ctx))
+
+	for cur.Next(ctx) {
+		var n Name
+		if err := cur.Decode(&n); err != nil { return err }
+		if err := fn(n); err != nil { return err }
+	}
+	return cur.Err()
+}
+
+func (s *MongoNames) Update(ctx context.Context, id primitive.ObjectID, n Name, ifVersion int64) (Name, error) {
+	return s.Patch(ctx, id, NamePatch{Name: &n.Name, Tags: &n.Tags, Metadata: &n.Metadata, ExpiresAt: Expiry{Set: true, At: n.ExpiresAt}, NameNormalized: &n.NameNormalized}, ifVersion)
+}
+
+func (s *MongoNames) Patch(ctx context.Context, id primitive.ObjectID, p NamePatch, ifVersion int64) (Name, error) {
+	set, unset := bson.M{"updated_at": time.Now().UTC()}, bson.M{}
+	if p.Name != nil { set["name"] = *p.Name }
+	if p.NameNormalized != nil {
+		if *p.NameNormalized != "" { set["name_normalized"] = *p.NameNormalized } else { unset["name_normalized"] = "" }
+	}
+	for field, v := range map[string]*string{"created_by": p.CreatedBy, "uuid": p.UUID, "slug": p.Slug} {
+		if v == nil { continue }
+		if *v != "" { set[field] = *v } else { unset[field] = "" }
+	}
+	if p.Tags != nil {
+		if len(*p.Tags) > 0 { set["tags"] = *p.Tags } else { unset["tags"] = "" }
+	}
+	if p.Metadata != nil {
+		if len(*p.Metadata) > 0 { set["metadata"] = *p.Metadata } else { unset["metadata"] = "" }
+	}
+	if p.ExpiresAt.Set {
+		if p.ExpiresAt.At != nil { set["expires_at"] = *p.ExpiresAt.At } else { unset["expires_at"] = "" }
+	}
+	update := bson.M{"$set": set, "$inc": bson.M{"version": 1}}
+	if len(unset) > 0 { update["$unset"] = unset }
+
+	var n Name
+	filter := bson.M{"tenant": tenant.FromContext(ctx), "_id": id, "deleted_at": nil}
+	err := s.names.FindOneAndUpdate(ctx, withVersion(filter, ifVersion), update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&n)
+	if errors.Is(err, mongo.ErrNoDocuments) { return n, s.missed(ctx, filter, ifVersion) }
+	return n, dupToErr(err)
+}
+
+func (s *MongoNames) SoftDelete(ctx context.Context, id primitive.ObjectID, ifVersion int64) error {
+	filter := bson.M{"tenant": tenant.FromContext(ctx), "_id": id, "deleted_at": nil}
+	res, err := s.names.UpdateOne(ctx, withVersion(filter, ifVersion), bson.M{"$set": bson.M{"deleted_at": time.Now().UTC()}, "$inc": bson.M{"version": 1}})
+	if err != nil { return err }
+	if res.MatchedCount == 0 { return s.missed(ctx, filter, ifVersion) }
+	return nil
+}
+
+func (s *MongoNames) HardDelete(ctx context.Context, id primitive.ObjectID, ifVersion int64) error {
+	filter := bson.M{"tenant": tenant.FromContext(ctx), "_id": id}
+	res, err := s.names.DeleteOne(ctx, withVersion(filter, ifVersion))
+	if err != nil { return err }
+	if res.DeletedCount == 0 { return s.missed(ctx, filter, ifVersion) }
+	return nil
+}
+
+// withVersion returns filter narrowed to documents at version v. Documents
+// stored before versions existed have none, which counts as 0.
+func withVersion(filter bson.M, v int64) bson.M {
+	if v == AnyVersion { return filter }
+	out := bson.M{"version": v}
+	if v == 0 { out["version"] = bson.M{"$in": bson.A{0, nil}} }
+	for k, val := range filter { out[k] = val }
+	return out
+}
+
+// missed explains a conditional write that matched nothing: either the
+// document is gone or it is at another version.
+func (s *MongoNames) missed(ctx context.Context, filter bson.M, ifVersion int64) error {
+	if ifVersion == AnyVersion { return ErrNotFound }
+	n, err := s.names.CountDocuments(ctx, filter, options.Count().SetLimit(1))
+	if err != nil { return err }
+	if n > 0 { return ErrVersionMismatch }
+	return ErrNotFound
+}
+
+func (s *MongoNames) Restore(ctx context.Context, id primitive.ObjectID) (Name, error) {
+	var n Name
+	err := s.names.FindOneAndUpdate(ctx,
+		bson.M{"tenant": tenant.FromContext(ctx), "_id": id, "deleted_at": bson.M{"$ne": nil}},
+		bson.M{"$unset": bson.M{"deleted_at": ""}, "$inc": bson.M{"version": 1}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&n)
+	if errors.Is(err, mongo.ErrNoDocuments) { return n, ErrNotFound }
+	return n, err
+}
+
+// Events returns the audit history of id, oldest first.
+func (s *MongoNames) Events(ctx context.Context, id primitive.ObjectID) ([]NameEvent, error) {
+	cur, err := s.events.Find(ctx, bson.M{"tenant": tenant.FromContext(ctx), "name_id": id}, options.Find().SetSort(bson.D{{Key: "at", Value: 1}}))
+	if err != nil { return nil, err }
+	defer cur.Close(ctx)
+
+	out := []NameEvent{}
+	err = cur.All(ctx, &out)
+	return out, err
+}
+
+func (s *MongoNames) Search(ctx context.Context, opts SearchOptions) ([]SearchHit, error) {
+	// The text index is led by tenant, so $text needs the equality match.
+	filter := bson.M{"tenant": tenant.FromContext(ctx), "deleted_at": nil}
+	find := options.Find().SetLimit(opts.Limit)
+	switch opts.Mode {
+	case SearchPrefix:
+		prefix := bson.M{"$regex": "^" + regexp.QuoteMeta(opts.Query), "$options": "i"}
+		filter["$or"] = normalizedOr(prefix)
+		find.SetSort(bson.D{{Key: "name", Value: 1}})
+	case SearchRegex:
+		filter["name"] = bson.M{"$regex": opts.Query}
+		find.SetSort(bson.D{{Key: "name", Value: 1}})
+	default:
+		score := bson.M{"$meta": "textScore"}
+		filter["$text"] = bson.M{"$search": opts.Query}
+		find.SetProjection(bson.M{"score": score}).SetSort(bson.D{{Key: "score", Value: score}})
+	}
+
+	cur, err := s.reader(ctx, opts.Consistency).Find(ctx, filter, find)
+	if err != nil { return nil, err }
+	defer cur.Close(ctx)
+
+	out := []SearchHit{}
+	err = cur.All(ctx, &out)
+	return out, err
+}
+
+// CreateMany is unordered, so one bad item doesn't stop the rest. The events
+// are written afterwards without a transaction: the batch may be larger than
+// a transaction comfortably holds, and a lost event is only an audit gap.
+func (s *MongoNames) CreateMany(ctx context.Context, ns []Name) ([]error, error) {
+	now := time.Now().UTC()
+	errs, err := s.insertUnordered(ctx, ns, now)
+	if err != nil { return nil, err }
+
+	var events []any
+	for i, n := range ns {
+		if errs[i] == nil { events = append(events, NameEvent{NameID: n.ID, Tenant: n.Tenant, Type: "created", Name: n.Name, At: now}) }
+	}
+	if len(events) > 0 {
+		if _, err := s.events.InsertMany(ctx, events, options.InsertMany().SetOrdered(false)); err != nil {
+			slog.WarnContext(ctx, "writing bulk create events", "err", err)
+		}
+	}
+	return errs, nil
+}
+
+func (s *MongoNames) DeleteMany(ctx context.Context, ids []primitive.ObjectID, hard bool) (map[primitive.ObjectID]bool, error) {
+	filter := bson.M{"tenant": tenant.FromContext(ctx), "_id": bson.M{"$in": ids}}
+	if !hard { filter["deleted_at"] = nil }
+	found, err := s.names.Distinct(ctx, "_id", filter)
+	if err != nil { return nil, err }
+
+	existed := make(map[primitive.ObjectID]bool, len(found))
+	matched := make([]primitive.ObjectID, 0, len(found))
+	for _, v := range found {
+		if id, ok := v.(primitive.ObjectID); ok { existed[id] = true; matched = append(matched, id) }
+	}
+	if len(matched) == 0 { return existed, nil }
+
+	filter["_id"] = bson.M{"$in": matched}
+	if hard {
+		_, err = s.names.DeleteMany(ctx, filter)
+	} else {
+		_, err = s.names.UpdateMany(ctx, filter, bson.M{"$set": bson.M{"deleted_at": time.Now().UTC()}, "$inc": bson.M{"version": 1}})
+	}
+	return existed, err
+}
+
+func (s *MongoNames) ExistingNames(ctx context.Context, names []string) (map[string]bool, error) {
+	existing, err := s.names.Distinct(ctx, "name", bson.M{"tenant": tenant.FromContext(ctx), "name": bson.M{"$in": names}})
+	if err != nil { return nil, err }
+	out := make(map[string]bool, len(existing))
+	for _, v := range existing {
+		if s, ok := v.(string); ok { out[s] = true }
+	}
+	return out, nil
+}
+
+func (s *MongoNames) SetNormalized(ctx context.Context, forms map[primitive.ObjectID]string) error {
+	if len(forms) == 0 { return nil }
+	tid := tenant.FromContext(ctx)
+	models := make([]mongo.WriteModel, 0, len(forms))
+	for id, form := range forms {
+		models = append(models, mongo.NewUpdateOneModel().SetFilter(bson.M{"tenant": tid, "_id": id}).SetUpdate(bson.M{"$set": bson.M{"name_normalized": form}}))
+	}
+	_, err := s.names.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+	return err
+}
+
+func (s *MongoNames) InsertMany(ctx context.Context, ns []Name) ([]error, error) {
+	return s.insertUnordered(ctx, ns, time.Now().UTC())
+}
+
+// insertUnordered stamps and inserts ns in one unordered bulk write and
+// returns each item's error, ErrDuplicate for a taken name.
+func (s *MongoNames) insertUnordered(ctx context.Context, ns []Name, now time.Time) ([]error, error) {
+	errs := make([]error, len(ns))
+	if len(ns) == 0 { return errs, nil }
+	docs, tid := make([]any, len(ns)), tenant.FromContext(ctx)
+	for i := range ns {
+		stamp(&ns[i], tid, now)
+		docs[i] = ns[i]
+	}
+
+	_, err := s.names.InsertMany(ctx, docs, options.InsertMany().SetOrdered(false))
+	var bwe mongo.BulkWriteException
+	switch {
+	case err == nil:
+	case errors.As(err, &bwe) && bwe.WriteConcernError == nil:
+		for _, we := range bwe.WriteErrors {
+			errs[we.Index] = we.WriteError
+			if mongo.IsDuplicateKeyError(we.WriteError) { errs[we.Index] = ErrDuplicate }
+		}
+	default:
+		return nil, err
+	}
+	return errs, nil
+}
+
+// ---- list query building ----
+
+// sortField maps the API's sort key to a document field; ObjectIDs are
+// creation-ordered, so created_at sorts by _id.
+func sortField(opts ListOptions) string {
+	if opts.SortBy == "name" { return "name" }
+	return "_id"
+}
+
+// listFilter matches everything of tid that opts selects, ignoring
+// pagination; it is also what Page.Total counts.
+func listFilter(tid string, opts ListOptions) bson.M {
+	f := bson.M{"tenant": tid}
+	switch {
+	case opts.OnlyDeleted:
+		f["deleted_at"] = bson.M{"$ne": nil}
+	case !opts.IncludeDeleted:
+		f["deleted_at"] = nil
+	}
+	if opts.NamePrefix != "" { f["name"] = bson.M{"$regex": "^" + regexp.QuoteMeta(opts.NamePrefix)} }
+	if opts.Match != "" { f["$or"] = normalizedOr(opts.Match) }
+	if opts.CreatedBy != "" { f["created_by"] = opts.CreatedBy }
+	if len(opts.Tags) > 0 {
+		op := "$all"
+		if opts.AnyTag { op = "$in" }
+		f["tags"] = bson.M{op: opts.Tags}
+	}
+	return f
+}
+
+// normalizedOr matches the names whose normalized form matches cond, and
+// those without one whose name does.
+func normalizedOr(cond any) bson.A {
+	return bson.A{bson.M{"name_normalized": cond}, bson.M{"name_normalized": bson.M{"$exists": false}, "name": cond}}
+}
+
+// pageFilter narrows listFilter to the items after the cursor.
+func pageFilter(tid string, opts ListOptions) bson.M {
+	f := listFilter(tid, opts)
+	if opts.After == nil { return f }
+
+	op := "$gt"
+	if opts.Desc { op = "$lt" }
+	field := sortField(opts)
+	if field == "_id" {
+		f["_id"] = bson.M{op: opts.After.ID}
+		return f
+	}
+	return bson.M{"$and": bson.A{f, bson.M{"$or": bson.A{
+		bson.M{field: bson.M{op: opts.After.Value}},
+		bson.M{field: opts.After.Value, "_id": bson.M{op: opts.After.ID}},
+	}}}}
+}
+
+// listSort orders by the sort field, then _id as the tie-breaker.
+func listSort(opts ListOptions) bson.D {
+	dir := 1
+	if opts.Desc { dir = -1 }
+	field := sortField(opts)
+	sort := bson.D{{Key: field, Value: dir}}
+	if field != "_id" { sort = append(sort, bson.E{Key: "_id", Value: dir}) }
+	return sort
+}
+
+// findOptions fetches one extra item so List knows whether there's a next page.
+func findOptions(opts ListOptions) *options.FindOptions {
+	return options.Find().SetSort(listSort(opts)).SetSkip(opts.Offset).SetLimit(opts.Limit + 1).SetProjection(listProjection(opts))
+}
+
+// listProjection reads opts.Fields, the sort field and the ID; nil, rather
+// than an empty projection, if all fields are wanted.
+func listProjection(opts ListOptions) any {
+	if opts.Fields == nil { return nil }
+	p := bson.M{"_id": 1, sortField(opts): 1}
+	for _, f := range opts.Fields {
+		if f != "id" { p[f] = 1 }
+	}
+	return p
+}
+
+func (s *MongoNames) DueNames(ctx context.Context, q DueQuery) ([]Name, error) {
+	due := bson.A{bson.M{"expires_at": bson.M{"$lte": q.ExpiredBy}}}
+	if !q.DeletedBefore.IsZero() { due = append(due, bson.M{"deleted_at": bson.M{"$lt": q.DeletedBefore}}) }
+	cur, err := s.names.Find(ctx, bson.M{"_id": bson.M{"$gt": q.After}, "$or": due},
+		options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}).SetLimit(int64(q.Limit)))
+	if err != nil { return nil, err }
+	var out []Name
+	err = cur.All(ctx, &out)
+	return out, err
+}
@@ -0,0 +1,75 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"app/internal/tenant"
+)
+
+// MongoNotes is the MongoDB NoteStore: notes in a collection of their own,
+// pointing at the names collection's documents by name_id.
+type MongoNotes struct {
+	notes *mongo.Collection
+	names *mongo.Collection
+}
+
+// NewMongoNotes also creates the index a name's notes are found by.
+func NewMongoNotes(ctx context.Context, m *Mongo, notesCollection, namesCollection string) (*MongoNotes, error) {
+	s := &MongoNotes{notes: m.Collection(notesCollection), names: m.Collection(namesCollection)}
+	_, err := s.notes.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "tenant", Value: 1}, {Key: "name_id", Value: 1}, {Key: "_id", Value: 1}},
+		Options: options.Index().SetName("tenant_name_id"),
+	})
+	return s, err
+}
+
+func (s *MongoNotes) CreateNote(ctx context.Context, n *Note) error {
+	n.ID, n.Tenant, n.CreatedAt = primitive.NewObjectID(), tenant.FromContext(ctx), time.Now().UTC().Truncate(time.Millisecond)
+	_, err := s.notes.InsertOne(ctx, n)
+	return err
+}
+
+func (s *MongoNotes) Notes(ctx context.Context, nameID primitive.ObjectID) ([]Note, error) {
+	cur, err := s.notes.Find(ctx, bson.M{"tenant": tenant.FromContext(ctx), "name_id": nameID}, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil { return nil, err }
+	notes := []Note{}
+	return notes, cur.All(ctx, &notes)
+}
+
+func (s *MongoNotes) HasNotes(ctx context.Context, nameIDs ...primitive.ObjectID) (bool, error) {
+	n, err := s.notes.CountDocuments(ctx, bson.M{"tenant": tenant.FromContext(ctx), "name_id": bson.M{"$in": nameIDs}}, options.Count().SetLimit(1))
+	return n > 0, err
+}
+
+func (s *MongoNotes) DeleteNotes(ctx context.Context, nameIDs ...primitive.ObjectID) error {
+	_, err := s.notes.DeleteMany(ctx, bson.M{"tenant": tenant.FromContext(ctx), "name_id": bson.M{"$in": nameIDs}})
+	return err
+}
+
+// NameWithNotes reads the name and its notes in one aggregation, joining
+// them with $lookup.
+func (s *MongoNotes) NameWithNotes(ctx context.Context, id primitive.ObjectID) (NameWithNotes, error) {
+	tid := tenant.FromContext(ctx)
+	cur, err := s.names.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"tenant": tid, "_id": id, "deleted_at": nil}}},
+		{{Key: "$lookup", Value: bson.M{
+			"from":         s.notes.Name(),
+			"localField":   "_id",
+			"foreignField": "name_id",
+			"pipeline":     bson.A{bson.M{"$match": bson.M{"tenant": tid}}, bson.M{"$sort": bson.M{"_id": 1}}},
+			"as":           "notes",
+		}}},
+	})
+	if err != nil { return NameWithNotes{}, err }
+	var out []NameWithNotes
+	if err := cur.All(ctx, &out); err != nil { return NameWithNotes{}, err }
+	if len(out) == 0 { return NameWithNotes{}, ErrNotFound }
+	if out[0].Notes == nil { out[0].Notes = []Note{} }
+	return out[0], nil
+}
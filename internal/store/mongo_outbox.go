@@ -0,0 +1,78 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"app/internal/tenant"
+)
+
+// MongoOutbox is the MongoDB OutboxStore. AddToOutbox given the context of
+// a MongoNames transaction inserts in its session, so the events commit
+// with the writes. Like ClaimDelivery, ClaimOutbox is a findAndModify, so
+// no two dispatchers get the same event while it is held.
+type MongoOutbox struct {
+	events *mongo.Collection
+}
+
+// NewMongoOutbox also creates the collection, which a transaction can't
+// on older servers, and the indexes: the unpublished events for claiming,
+// oldest first, and the published ones for purging.
+func NewMongoOutbox(ctx context.Context, m *Mongo, name string) (*MongoOutbox, error) {
+	s := &MongoOutbox{events: m.Collection(name)}
+	_, err := s.events.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "_id", Value: 1}}, Options: options.Index().SetName("unpublished").SetPartialFilterExpression(bson.M{"published": false})},
+		{Keys: bson.D{{Key: "published_at", Value: 1}}, Options: options.Index().SetName("published_at").SetSparse(true)},
+	})
+	return s, err
+}
+
+// outboxDoc is an event as stored: published is what the partial index of
+// those still to claim filters on.
+type outboxDoc struct {
+	OutboxEvent `bson:",inline"`
+	Published   bool `bson:"published"`
+}
+
+func (s *MongoOutbox) AddToOutbox(ctx context.Context, es []OutboxEvent) error {
+	if len(es) == 0 { return nil }
+	tid := tenant.FromContext(ctx)
+	docs := make([]any, len(es))
+	for i := range es {
+		e := &es[i]
+		e.ID, e.Tenant, e.At = primitive.NewObjectID(), tid, e.At.UTC().Truncate(time.Millisecond)
+		e.LeaseUntil = e.At
+		docs[i] = outboxDoc{OutboxEvent: *e}
+	}
+	_, err := s.events.InsertMany(ctx, docs)
+	return err
+}
+
+func (s *MongoOutbox) ClaimOutbox(ctx context.Context, lease time.Duration) (OutboxEvent, error) {
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	var d outboxDoc
+	err := s.events.FindOneAndUpdate(ctx,
+		bson.M{"published": false, "lease_until": bson.M{"$lte": now}},
+		bson.M{"$set": bson.M{"lease_until": now.Add(lease)}},
+		options.FindOneAndUpdate().SetSort(bson.D{{Key: "_id", Value: 1}}).SetReturnDocument(options.After)).Decode(&d)
+	if errors.Is(err, mongo.ErrNoDocuments) { return OutboxEvent{}, ErrNotFound }
+	return d.OutboxEvent, err
+}
+
+func (s *MongoOutbox) MarkPublished(ctx context.Context, id primitive.ObjectID) error {
+	res, err := s.events.UpdateOne(ctx, bson.M{"_id": id}, bson.M{"$set": bson.M{"published": true, "published_at": time.Now().UTC().Truncate(time.Millisecond)}})
+	if err != nil { return err }
+	if res.MatchedCount == 0 { return ErrNotFound }
+	return nil
+}
+
+func (s *MongoOutbox) PurgeOutbox(ctx context.Context, before time.Time) error {
+	_, err := s.events.DeleteMany(ctx, bson.M{"published_at": bson.M{"$lt": before}})
+	return err
+}
@@ -0,0 +1,37 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"app/internal/tenant"
+)
+
+// MongoRevisions is the MongoDB RevisionStore: a document per tenant, keyed
+// by the tenant.
+type MongoRevisions struct {
+	revs *mongo.Collection
+}
+
+func NewMongoRevisions(m *Mongo, collection string) *MongoRevisions {
+	return &MongoRevisions{revs: m.DB.Collection(collection)}
+}
+
+func (s *MongoRevisions) Bump(ctx context.Context) error {
+	_, err := s.revs.UpdateByID(ctx, tenant.FromContext(ctx),
+		bson.M{"$inc": bson.M{"n": 1}, "$set": bson.M{"at": time.Now().UTC()}},
+		options.Update().SetUpsert(true))
+	return err
+}
+
+func (s *MongoRevisions) Revision(ctx context.Context) (Revision, error) {
+	var rev Revision
+	err := s.revs.FindOne(ctx, bson.M{"_id": tenant.FromContext(ctx)}).Decode(&rev)
+	if errors.Is(err, mongo.ErrNoDocuments) { return Revision{}, nil }
+	return rev, err
+}
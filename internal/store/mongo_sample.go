@@ -0,0 +1,25 @@
+package store
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"app/internal/tenant"
+)
+
+// RandomName lets $sample pick, which reads no more than it must once the
+// tenant's live names are a small part of the collection.
+func (s *MongoNames) RandomName(ctx context.Context) (Name, error) {
+	cur, err := s.names.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"tenant": tenant.FromContext(ctx), "deleted_at": nil}}},
+		{{Key: "$sample", Value: bson.M{"size": 1}}},
+	})
+	if err != nil { return Name{}, err }
+	defer cur.Close(ctx)
+	var picked []Name
+	if err := cur.All(ctx, &picked); err != nil { return Name{}, err }
+	if len(picked) == 0 { return Name{}, ErrNotFound }
+	return picked[0], nil
+}
@@ -0,0 +1,78 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"app/internal/tenant"
+)
+
+// NameStats runs one aggregation over the tenant's names, a $facet per
+// figure, then collStats for the collection.
+func (s *MongoNames) NameStats(ctx context.Context, since time.Time) (NameStats, error) {
+	live := bson.M{"$match": bson.M{"deleted_at": nil}}
+	byLength := func(dir int) bson.A {
+		return bson.A{
+			live,
+			bson.M{"$addFields": bson.M{"_len": bson.M{"$strLenCP": "$name"}}},
+			bson.M{"$sort": bson.D{{Key: "_len", Value: dir}, {Key: "_id", Value: 1}}},
+			bson.M{"$limit": 1},
+			bson.M{"$project": bson.M{"_len": 0}},
+		}
+	}
+	cur, err := s.names.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"tenant": tenant.FromContext(ctx)}}},
+		{{Key: "$facet", Value: bson.M{
+			"counts": bson.A{bson.M{"$group": bson.M{
+				"_id":     nil,
+				"total":   bson.M{"$sum": 1},
+				"deleted": bson.M{"$sum": bson.M{"$cond": bson.A{bson.M{"$ifNull": bson.A{"$deleted_at", false}}, 1, 0}}},
+			}}},
+			"per_day": bson.A{
+				bson.M{"$match": bson.M{"created_at": bson.M{"$gte": since}}},
+				bson.M{"$group": bson.M{"_id": bson.M{"$dateToString": bson.M{"format": "%Y-%m-%d", "date": "$created_at"}}, "count": bson.M{"$sum": 1}}},
+				bson.M{"$sort": bson.M{"_id": 1}},
+			},
+			"longest":  byLength(-1),
+			"shortest": byLength(1),
+		}}},
+	})
+	if err != nil { return NameStats{}, err }
+	defer cur.Close(ctx)
+
+	var facets []struct {
+		Counts []struct {
+			Total   int64 `bson:"total"`
+			Deleted int64 `bson:"deleted"`
+		} `bson:"counts"`
+		PerDay []struct {
+			Day   string `bson:"_id"`
+			Count int64  `bson:"count"`
+		} `bson:"per_day"`
+		Longest  []Name `bson:"longest"`
+		Shortest []Name `bson:"shortest"`
+	}
+	if err := cur.All(ctx, &facets); err != nil { return NameStats{}, err }
+
+	st := NameStats{CreatedPerDay: []DayCount{}}
+	if len(facets) == 1 {
+		f := facets[0]
+		if len(f.Counts) == 1 { st.Total, st.Deleted = f.Counts[0].Total, f.Counts[0].Deleted }
+		for _, d := range f.PerDay { st.CreatedPerDay = append(st.CreatedPerDay, DayCount{Day: d.Day, Count: d.Count}) }
+		if len(f.Longest) == 1 { st.Longest, st.Shortest = &f.Longest[0], &f.Shortest[0] }
+	}
+
+	var cs struct {
+		Count          int64 `bson:"count"`
+		Size           int64 `bson:"size"`
+		StorageSize    int64 `bson:"storageSize"`
+		TotalIndexSize int64 `bson:"totalIndexSize"`
+		Nindexes       int64 `bson:"nindexes"`
+	}
+	if err := s.names.Database().RunCommand(ctx, bson.D{{Key: "collStats", Value: s.names.Name()}}).Decode(&cs); err != nil { return NameStats{}, err }
+	st.Storage = &StorageStats{Documents: cs.Count, SizeBytes: cs.Size, StorageBytes: cs.StorageSize, IndexBytes: cs.TotalIndexSize, Indexes: cs.Nindexes}
+	return st, nil
+}
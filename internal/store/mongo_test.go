@@ -0,0 +1,37 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/x/mongo/driver/topology"
+)
+
+func TestMongoErrorClasses(t *testing.T) {
+	for _, tc := range []struct {
+		err                  error
+		transient, unapplied bool
+	}{
+		{fmt.Errorf("update: %w", mongo.CommandError{Code: 10107, Name: "NotWritablePrimary"}), true, true},
+		{topology.ServerSelectionError{Wrapped: errors.New("no primary")}, true, true},
+		{mongo.CommandError{Code: 11000, Name: "DuplicateKey"}, false, false},
+		{errors.New("bad query"), false, false},
+	} {
+		if got := TransientMongo(tc.err); got != tc.transient { t.Errorf("TransientMongo(%v) = %v", tc.err, got) }
+		if got := UnappliedMongo(tc.err); got != tc.unapplied { t.Errorf("UnappliedMongo(%v) = %v", tc.err, got) }
+	}
+}
+
+func TestConnectRetries(t *testing.T) {
+	start := time.Now()
+	_, err := Connect(context.Background(), MongoConfig{
+		URI: "mongodb://127.0.0.1:1", MaxPoolSize: 1, ServerSelectionTimeout: 50 * time.Millisecond, ConnectRetry: 700 * time.Millisecond,
+	})
+	if err == nil || !strings.Contains(err.Error(), "after 2 attempts") { t.Fatalf("Connect = %v", err) }
+	if took := time.Since(start); took < 500*time.Millisecond { t.Fatalf("gave up after %s", took) }
+}
@@ -0,0 +1,59 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// usageRetention is how long MongoDB keeps a day of usage: past the
+// longest quota, which is a month, with a year to look back on.
+const usageRetention = 400 * 24 * time.Hour
+
+// MongoUsage is the MongoDB UsageStore: a document per key and day, which
+// AddUsage $incs in one bulk write.
+type MongoUsage struct {
+	days *mongo.Collection
+}
+
+// NewMongoUsage also creates the indexes: key and day, unique, which the
+// upserts and Usage go by, and expire_at, which drops the days
+// usageRetention old.
+func NewMongoUsage(ctx context.Context, m *Mongo, name string) (*MongoUsage, error) {
+	s := &MongoUsage{days: m.Collection(name)}
+	_, err := s.days.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "key_id", Value: 1}, {Key: "day", Value: 1}}, Options: options.Index().SetName("key_day").SetUnique(true)},
+		{Keys: bson.D{{Key: "expire_at", Value: 1}}, Options: options.Index().SetName("expire_at").SetExpireAfterSeconds(0)},
+	})
+	return s, err
+}
+
+func (s *MongoUsage) AddUsage(ctx context.Context, us []Usage) error {
+	if len(us) == 0 { return nil }
+	models := make([]mongo.WriteModel, len(us))
+	for i, u := range us {
+		day, err := time.Parse(time.DateOnly, u.Day)
+		if err != nil { return err }
+		models[i] = mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"key_id": u.KeyID, "day": u.Day}).
+			SetUpdate(bson.M{
+				"$inc":         bson.M{"requests": u.Requests, "bytes_in": u.BytesIn, "bytes_out": u.BytesOut},
+				"$setOnInsert": bson.M{"tenant": u.Tenant, "expire_at": day.Add(usageRetention)},
+			}).
+			SetUpsert(true)
+	}
+	_, err := s.days.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+	return err
+}
+
+func (s *MongoUsage) Usage(ctx context.Context, keyID primitive.ObjectID, since string) ([]Usage, error) {
+	cur, err := s.days.Find(ctx, bson.M{"key_id": keyID, "day": bson.M{"$gte": since}}, options.Find().SetSort(bson.D{{Key: "day", Value: 1}}))
+	if err != nil { return nil, err }
+	us := []Usage{}
+	if err := cur.All(ctx, &us); err != nil { return nil, err }
+	return us, nil
+}
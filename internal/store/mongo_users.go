@@ -0,0 +1,89 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"app/internal/tenant"
+)
+
+// MongoUsers is the MongoDB UserStore.
+type MongoUsers struct {
+	users *mongo.Collection
+}
+
+// NewMongoUsers also makes usernames unique (they're stored lower-cased),
+// indexes tenant, and lets each tenant have one user marked as its founder.
+func NewMongoUsers(ctx context.Context, m *Mongo, collection string) (*MongoUsers, error) {
+	s := &MongoUsers{users: m.DB.Collection(collection)}
+	_, err := s.users.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "username", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "tenant", Value: 1}}},
+		{Keys: bson.D{{Key: "tenant", Value: 1}}, Options: options.Index().SetName(founderIndex).SetUnique(true).SetPartialFilterExpression(bson.M{"founder": true})},
+	})
+	return s, err
+}
+
+func (s *MongoUsers) CreateUser(ctx context.Context, u User) error {
+	_, err := s.users.InsertOne(ctx, u)
+	if mongo.IsDuplicateKeyError(err) { return ErrDuplicate }
+	return err
+}
+
+func (s *MongoUsers) UserByUsername(ctx context.Context, username string) (User, error) {
+	var u User
+	err := s.users.FindOne(ctx, bson.M{"username": username}).Decode(&u)
+	if errors.Is(err, mongo.ErrNoDocuments) { return u, ErrNotFound }
+	return u, err
+}
+
+const founderIndex = "tenant_founder"
+
+// FoundTenant inserts u marked as the founder of its tenant, which the
+// founderIndex lets happen once. Tenants from before the mark, or joined
+// with auth off, have users and no founder, so those are looked for first.
+func (s *MongoUsers) FoundTenant(ctx context.Context, u User) error {
+	n, err := s.users.CountDocuments(ctx, bson.M{"tenant": tenantFilter(u.Tenant)}, options.Count().SetLimit(1))
+	if err != nil { return err }
+	if n > 0 { return ErrTenantExists }
+	_, err = s.users.InsertOne(ctx, struct {
+		User    `bson:",inline"`
+		Founder bool `bson:"founder"`
+	}{u, true})
+	var we mongo.WriteException
+	if errors.As(err, &we) {
+		for _, e := range we.WriteErrors {
+			if mongo.IsDuplicateKeyError(e) && strings.Contains(e.Message, founderIndex) { return ErrTenantExists }
+		}
+	}
+	if mongo.IsDuplicateKeyError(err) { return ErrDuplicate }
+	return err
+}
+
+// tenantFilter matches the documents of tid, counting those from before
+// tenants existed as the default tenant's.
+func tenantFilter(tid string) any {
+	if tid == tenant.Default { return bson.M{"$in": bson.A{tid, nil}} }
+	return tid
+}
+
+func (s *MongoUsers) Users(ctx context.Context, tid string) ([]User, error) {
+	cur, err := s.users.Find(ctx, bson.M{"tenant": tenantFilter(tid)}, options.Find().SetSort(bson.D{{Key: "username", Value: 1}}))
+	if err != nil { return nil, err }
+	users := []User{}
+	return users, cur.All(ctx, &users)
+}
+
+func (s *MongoUsers) SetUserRole(ctx context.Context, tid string, id primitive.ObjectID, role string) (User, error) {
+	var u User
+	err := s.users.FindOneAndUpdate(ctx, bson.M{"_id": id, "tenant": tenantFilter(tid)}, bson.M{"$set": bson.M{"role": role}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&u)
+	if errors.Is(err, mongo.ErrNoDocuments) { return u, ErrNotFound }
+	return u, err
+}
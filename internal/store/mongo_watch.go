@@ -0,0 +1,99 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"slices"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"app/internal/tenant"
+)
+
+// Server error codes for change streams that can't be opened.
+const (
+	codeChangeStreamsNotSupported = 40573
+	codeInvalidResumeToken        = 260
+	codeChangeStreamFatalError    = 280
+	codeChangeStreamHistoryLost   = 286
+)
+
+// Watch opens a change stream on the names collection, narrowed to the
+// tenant in ctx. Updates are delivered with the full document as it is when
+// the change is read. A delete leaves no document to tell the tenant by, so
+// removals are matched on their pre-image and are only delivered where
+// NewMongoNames could enable pre-images (MongoDB 6.0 and later).
+func (s *MongoNames) Watch(ctx context.Context, after string) (ChangeStream, error) {
+	tid := tenant.FromContext(ctx)
+	pipeline := mongo.Pipeline{{{Key: "$match", Value: bson.M{
+		"operationType": bson.M{"$in": bson.A{"insert", "update", "replace", "delete"}},
+		"$or": bson.A{bson.M{"fullDocument.tenant": tid}, bson.M{"fullDocumentBeforeChange.tenant": tid}},
+	}}}}
+	opts := options.ChangeStream().SetFullDocument(options.UpdateLookup).SetFullDocumentBeforeChange(options.WhenAvailable)
+	if after != "" { opts.SetResumeAfter(bson.M{"_data": after}) }
+
+	cs, err := s.names.Watch(ctx, pipeline, opts)
+	if err != nil { return nil, watchErr(err) }
+	return &mongoChangeStream{cs: cs}, nil
+}
+
+func watchErr(err error) error {
+	var se mongo.ServerError
+	if !errors.As(err, &se) { return err }
+	switch {
+	case se.HasErrorCode(codeChangeStreamsNotSupported):
+		return ErrWatchUnsupported
+	case se.HasErrorCode(codeInvalidResumeToken), se.HasErrorCode(codeChangeStreamFatalError), se.HasErrorCode(codeChangeStreamHistoryLost):
+		return ErrResumeExpired
+	}
+	return err
+}
+
+type mongoChangeStream struct {
+	cs *mongo.ChangeStream
+}
+
+// changeEvent is the part of a change event document we use.
+type changeEvent struct {
+	ID            struct{ Data string `bson:"_data"` } `bson:"_id"`
+	OperationType string                              `bson:"operationType"`
+	DocumentKey   struct{ ID primitive.ObjectID `bson:"_id"` } `bson:"documentKey"`
+	FullDocument  *Name                               `bson:"fullDocument"`
+	Update        struct {
+		UpdatedFields bson.M   `bson:"updatedFields"`
+		RemovedFields []string `bson:"removedFields"`
+	} `bson:"updateDescription"`
+}
+
+func (m *mongoChangeStream) Next(ctx context.Context) (NameChange, error) {
+	if !m.cs.Next(ctx) {
+		if err := m.cs.Err(); err != nil { return NameChange{}, watchErr(err) }
+		return NameChange{}, ctx.Err()
+	}
+	var ev changeEvent
+	if err := m.cs.Decode(&ev); err != nil { return NameChange{}, err }
+
+	c := NameChange{Token: ev.ID.Data, ID: ev.DocumentKey.ID, Name: ev.FullDocument}
+	switch ev.OperationType {
+	case "insert":
+		c.Type = "created"
+	case "delete":
+		c.Type = "removed"
+	default:
+		_, deleted := ev.Update.UpdatedFields["deleted_at"]
+		switch {
+		case deleted:
+			c.Type = "deleted"
+		case slices.Contains(ev.Update.RemovedFields, "deleted_at"):
+			c.Type = "restored"
+		default:
+			c.Type = "updated"
+		}
+	}
+	return c, nil
+}
+
+func (m *mongoChangeStream) Close(ctx context.Context) error { return m.cs.Close(ctx) }
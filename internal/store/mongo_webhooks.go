@@ -0,0 +1,118 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"app/internal/tenant"
+)
+
+// MongoWebhooks is the MongoDB WebhookStore. Like ClaimJob, ClaimDelivery
+// is a findAndModify, so no two workers get the same delivery.
+type MongoWebhooks struct {
+	hooks, deliveries *mongo.Collection
+}
+
+// NewMongoWebhooks also creates the indexes: the webhooks by tenant, and
+// the deliveries for claiming, for each webhook's log, and for purging.
+func NewMongoWebhooks(ctx context.Context, m *Mongo, hooks, deliveries string) (*MongoWebhooks, error) {
+	s := &MongoWebhooks{hooks: m.Collection(hooks), deliveries: m.Collection(deliveries)}
+	if _, err := s.hooks.Indexes().CreateOne(ctx, mongo.IndexModel{Keys: bson.D{{Key: "tenant", Value: 1}, {Key: "_id", Value: 1}}, Options: options.Index().SetName("tenant_id")}); err != nil { return s, err }
+	_, err := s.deliveries.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "next_attempt_at", Value: 1}}, Options: options.Index().SetName("status_next_attempt_at")},
+		{Keys: bson.D{{Key: "webhook_id", Value: 1}, {Key: "_id", Value: -1}}, Options: options.Index().SetName("webhook_id")},
+		{Keys: bson.D{{Key: "finished_at", Value: 1}}, Options: options.Index().SetName("finished_at").SetSparse(true)},
+	})
+	return s, err
+}
+
+func (s *MongoWebhooks) CreateWebhook(ctx context.Context, w *Webhook) error {
+	w.ID, w.Tenant, w.CreatedAt = primitive.NewObjectID(), tenant.FromContext(ctx), time.Now().UTC().Truncate(time.Millisecond)
+	_, err := s.hooks.InsertOne(ctx, w)
+	return err
+}
+
+func (s *MongoWebhooks) Webhook(ctx context.Context, id primitive.ObjectID) (Webhook, error) {
+	var w Webhook
+	err := s.hooks.FindOne(ctx, bson.M{"_id": id, "tenant": tenant.FromContext(ctx)}).Decode(&w)
+	if errors.Is(err, mongo.ErrNoDocuments) { return w, ErrNotFound }
+	return w, err
+}
+
+func (s *MongoWebhooks) Webhooks(ctx context.Context) ([]Webhook, error) {
+	cur, err := s.hooks.Find(ctx, bson.M{"tenant": tenant.FromContext(ctx)}, options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil { return nil, err }
+	out := []Webhook{}
+	return out, cur.All(ctx, &out)
+}
+
+func (s *MongoWebhooks) DeleteWebhook(ctx context.Context, id primitive.ObjectID) error {
+	res, err := s.hooks.DeleteOne(ctx, bson.M{"_id": id, "tenant": tenant.FromContext(ctx)})
+	if err != nil { return err }
+	if res.DeletedCount == 0 { return ErrNotFound }
+	_, err = s.deliveries.DeleteMany(ctx, bson.M{"webhook_id": id})
+	return err
+}
+
+func (s *MongoWebhooks) EnqueueDeliveries(ctx context.Context, ds []Delivery) error {
+	if len(ds) == 0 { return nil }
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	docs := make([]any, len(ds))
+	for i := range ds {
+		d := &ds[i]
+		d.ID, d.Tenant, d.Status, d.NextAttemptAt, d.CreatedAt = primitive.NewObjectID(), tenant.FromContext(ctx), DeliveryPending, &now, now
+		if d.Attempts == nil { d.Attempts = []DeliveryAttempt{} }
+		docs[i] = d
+	}
+	_, err := s.deliveries.InsertMany(ctx, docs)
+	return err
+}
+
+func (s *MongoWebhooks) Deliveries(ctx context.Context, webhookID primitive.ObjectID, limit int) ([]Delivery, error) {
+	cur, err := s.deliveries.Find(ctx, bson.M{"webhook_id": webhookID, "tenant": tenant.FromContext(ctx)},
+		options.Find().SetSort(bson.D{{Key: "_id", Value: -1}}).SetLimit(int64(limit)))
+	if err != nil { return nil, err }
+	out := []Delivery{}
+	return out, cur.All(ctx, &out)
+}
+
+func (s *MongoWebhooks) ClaimDelivery(ctx context.Context, lease time.Duration) (Delivery, error) {
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	filter := bson.M{"status": DeliveryPending, "next_attempt_at": bson.M{"$lte": now}}
+	update := bson.M{"$set": bson.M{"next_attempt_at": now.Add(lease)}, "$inc": bson.M{"claims": 1}}
+	var d Delivery
+	err := s.deliveries.FindOneAndUpdate(ctx, filter, update,
+		options.FindOneAndUpdate().SetSort(bson.D{{Key: "next_attempt_at", Value: 1}, {Key: "_id", Value: 1}}).SetReturnDocument(options.After)).Decode(&d)
+	if errors.Is(err, mongo.ErrNoDocuments) { return d, ErrNotFound }
+	return d, err
+}
+
+func (s *MongoWebhooks) FinishDelivery(ctx context.Context, d Delivery) error {
+	set, unset := bson.M{"status": d.Status, "attempts": d.Attempts}, bson.M{}
+	switch {
+	case d.Status != DeliveryPending:
+		set["finished_at"], unset["next_attempt_at"] = time.Now().UTC().Truncate(time.Millisecond), ""
+	case d.NextAttemptAt != nil:
+		set["next_attempt_at"] = *d.NextAttemptAt
+	}
+	u := bson.M{"$set": set}
+	if len(unset) > 0 { u["$unset"] = unset }
+	res, err := s.deliveries.UpdateOne(ctx, bson.M{"_id": d.ID, "status": DeliveryPending, "claims": d.Claims}, u)
+	if err != nil { return err }
+	if res.MatchedCount == 0 {
+		if n, err := s.deliveries.CountDocuments(ctx, bson.M{"_id": d.ID}); err != nil || n == 0 { return errors.Join(ErrNotFound, err) }
+		return ErrVersionMismatch
+	}
+	return nil
+}
+
+func (s *MongoWebhooks) PurgeDeliveries(ctx context.Context, before time.Time) error {
+	_, err := s.deliveries.DeleteMany(ctx, bson.M{"finished_at": bson.M{"$lt": before}})
+	return err
+}
@@ -0,0 +1,17 @@
+package store
+
+import "context"
+
+type restoreKey struct{}
+
+// Restoring returns ctx for writing back names from a backup. The layers
+// that stamp the names they create with what they decide, the owner and the
+// keys of packages owner and ids, and the prewrite hooks, leave the names
+// written in it as the backup has them.
+func Restoring(ctx context.Context) context.Context { return context.WithValue(ctx, restoreKey{}, true) }
+
+// IsRestoring reports whether ctx is one of Restoring.
+func IsRestoring(ctx context.Context) bool {
+	restoring, _ := ctx.Value(restoreKey{}).(bool)
+	return restoring
+}
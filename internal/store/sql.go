@@ -0,0 +1,324 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "github.com/jackc/pgx/v5/stdlib" // registers "pgx"
+	_ "modernc.org/sqlite"             // registers "sqlite"
+)
+
+// SQL is the database behind the SQL stores, for deployments without
+// MongoDB: SQLite as a single file next to the binary, or Postgres.
+type SQL struct {
+	DB       *sql.DB
+	postgres bool
+}
+
+// OpenSQL connects to url, either "postgres://..." or "sqlite:<path>", and
+// migrates the schema to the current version.
+func OpenSQL(ctx context.Context, url string) (*SQL, error) {
+	s := &SQL{}
+	var err error
+	switch {
+	case strings.HasPrefix(url, "postgres://"), strings.HasPrefix(url, "postgresql://"):
+		s.postgres = true
+		s.DB, err = sql.Open("pgx", url)
+	case strings.HasPrefix(url, "sqlite:"):
+		path := strings.TrimPrefix(url, "sqlite:")
+		s.DB, err = sql.Open("sqlite", "file:"+path+"?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)")
+		// Every connection to :memory: would get its own empty database.
+		if strings.Contains(path, ":memory:") { s.DB.SetMaxOpenConns(1) }
+	default:
+		return nil, errors.New("database URL must start with postgres://, postgresql:// or sqlite:")
+	}
+	if err != nil { return nil, err }
+
+	if err := s.DB.PingContext(ctx); err != nil { s.DB.Close(); return nil, err }
+	if err := s.migrate(ctx); err != nil { s.DB.Close(); return nil, fmt.Errorf("migrating schema: %w", err) }
+	return s, nil
+}
+
+func (s *SQL) Ping(ctx context.Context) error { return s.DB.PingContext(ctx) }
+
+func (s *SQL) Close(ctx context.Context) error { return s.DB.Close() }
+
+// migrations are applied in order, each in its own transaction, and
+// recorded in schema_migrations. Never edit one that has shipped; append.
+// {{blob}} is the binary column type of the dialect.
+var migrations = [][]string{
+	{ // 1: initial schema
+		`CREATE TABLE names (
+			id         TEXT PRIMARY KEY,
+			name       TEXT NOT NULL UNIQUE,
+			tags       TEXT,
+			metadata   TEXT,
+			created_at BIGINT NOT NULL,
+			updated_at BIGINT NOT NULL,
+			deleted_at BIGINT,
+			version    BIGINT NOT NULL DEFAULT 1
+		)`,
+		`CREATE TABLE name_events (
+			id      TEXT PRIMARY KEY,
+			name_id TEXT NOT NULL,
+			type    TEXT NOT NULL,
+			name    TEXT NOT NULL,
+			at      BIGINT NOT NULL
+		)`,
+		`CREATE INDEX name_events_name_id ON name_events (name_id, at)`,
+		`CREATE TABLE users (
+			id            TEXT PRIMARY KEY,
+			username      TEXT NOT NULL UNIQUE,
+			password_hash {{blob}} NOT NULL,
+			created_at    BIGINT NOT NULL
+		)`,
+		`CREATE TABLE idempotency_keys (
+			idem_key     TEXT PRIMARY KEY,
+			request_hash TEXT NOT NULL,
+			status       INTEGER NOT NULL DEFAULT 0,
+			headers      TEXT,
+			body         {{blob}},
+			expires_at   BIGINT NOT NULL
+		)`,
+	},
+	{ // 2: API keys
+		`CREATE TABLE apikeys (
+			id         TEXT PRIMARY KEY,
+			user_id    TEXT NOT NULL,
+			name       TEXT NOT NULL,
+			prefix     TEXT NOT NULL,
+			hash       TEXT NOT NULL UNIQUE,
+			scopes     TEXT NOT NULL,
+			created_at BIGINT NOT NULL,
+			revoked_at BIGINT
+		)`,
+	},
+	{ // 3: tenants. Names become unique per tenant, which takes a new table
+		// since SQLite can't drop a constraint.
+		`CREATE TABLE names_v3 (
+			id         TEXT PRIMARY KEY,
+			tenant     TEXT NOT NULL,
+			name       TEXT NOT NULL,
+			tags       TEXT,
+			metadata   TEXT,
+			created_at BIGINT NOT NULL,
+			updated_at BIGINT NOT NULL,
+			deleted_at BIGINT,
+			version    BIGINT NOT NULL DEFAULT 1,
+			UNIQUE (tenant, name)
+		)`,
+		`INSERT INTO names_v3 (id, tenant, name, tags, metadata, created_at, updated_at, deleted_at, version)
+			SELECT id, 'default', name, tags, metadata, created_at, updated_at, deleted_at, version FROM names`,
+		`DROP TABLE names`,
+		`ALTER TABLE names_v3 RENAME TO names`,
+		`CREATE INDEX names_tenant_id ON names (tenant, id)`,
+		`ALTER TABLE name_events ADD COLUMN tenant TEXT NOT NULL DEFAULT 'default'`,
+		`ALTER TABLE users ADD COLUMN tenant TEXT NOT NULL DEFAULT 'default'`,
+		`ALTER TABLE apikeys ADD COLUMN tenant TEXT NOT NULL DEFAULT 'default'`,
+	},
+	{ // 4: audit log
+		`CREATE TABLE audit (
+			id         TEXT PRIMARY KEY,
+			tenant     TEXT NOT NULL,
+			entity     TEXT NOT NULL,
+			entity_id  TEXT NOT NULL,
+			action     TEXT NOT NULL,
+			actor      TEXT NOT NULL,
+			request_id TEXT NOT NULL,
+			at         BIGINT NOT NULL,
+			before_doc TEXT,
+			after_doc  TEXT
+		)`,
+		`CREATE INDEX audit_entity ON audit (tenant, entity, entity_id, id)`,
+	},
+	{ // 5: past versions of names
+		`CREATE TABLE name_history (
+			tenant  TEXT NOT NULL,
+			name_id TEXT NOT NULL,
+			version BIGINT NOT NULL,
+			doc     TEXT NOT NULL,
+			PRIMARY KEY (tenant, name_id, version)
+		)`,
+	},
+	{ // 6: roles
+		`ALTER TABLE users ADD COLUMN role TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE apikeys ADD COLUMN role TEXT NOT NULL DEFAULT ''`,
+	},
+	{ // 7: documents of the declared resources, all in one table
+		`CREATE TABLE docs (
+			collection TEXT NOT NULL,
+			tenant     TEXT NOT NULL,
+			id         TEXT NOT NULL,
+			fields     TEXT NOT NULL,
+			created_at BIGINT NOT NULL,
+			updated_at BIGINT NOT NULL,
+			version    BIGINT NOT NULL DEFAULT 1,
+			PRIMARY KEY (collection, id)
+		)`,
+		`CREATE INDEX docs_tenant_id ON docs (collection, tenant, id)`,
+	},
+	{ // 8: notes about names
+		`CREATE TABLE notes (
+			id         TEXT PRIMARY KEY,
+			tenant     TEXT NOT NULL,
+			name_id    TEXT NOT NULL,
+			body       TEXT NOT NULL,
+			author     TEXT NOT NULL,
+			created_at BIGINT NOT NULL
+		)`,
+		`CREATE INDEX notes_name_id ON notes (tenant, name_id, id)`,
+	},
+	{ // 9: background jobs
+		`CREATE TABLE jobs (
+			id          TEXT PRIMARY KEY,
+			tenant      TEXT NOT NULL,
+			type        TEXT NOT NULL,
+			status      TEXT NOT NULL,
+			actor       TEXT NOT NULL,
+			request_id  TEXT NOT NULL,
+			params      TEXT NOT NULL,
+			input       {{blob}},
+			output      {{blob}},
+			result      TEXT,
+			error       TEXT NOT NULL DEFAULT '',
+			attempts    INTEGER NOT NULL DEFAULT 0,
+			lease_until BIGINT,
+			created_at  BIGINT NOT NULL,
+			started_at  BIGINT,
+			finished_at BIGINT
+		)`,
+		`CREATE INDEX jobs_status_id ON jobs (status, id)`,
+	},
+	{ // 10: names that expire, and the indexes the cleanup finds them by
+		`ALTER TABLE names ADD COLUMN expires_at BIGINT`,
+		`CREATE INDEX names_expires_at ON names (expires_at)`,
+		`CREATE INDEX names_deleted_at ON names (deleted_at)`,
+	},
+	{ // 11: webhooks, and the deliveries to them with their logs as JSON
+		`CREATE TABLE webhooks (
+			id         TEXT PRIMARY KEY,
+			tenant     TEXT NOT NULL,
+			url        TEXT NOT NULL,
+			events     TEXT NOT NULL,
+			secret     TEXT NOT NULL,
+			created_at BIGINT NOT NULL
+		)`,
+		`CREATE INDEX webhooks_tenant_id ON webhooks (tenant, id)`,
+		`CREATE TABLE webhook_deliveries (
+			id              TEXT PRIMARY KEY,
+			tenant          TEXT NOT NULL,
+			webhook_id      TEXT NOT NULL,
+			event           TEXT NOT NULL,
+			payload         TEXT NOT NULL,
+			status          TEXT NOT NULL,
+			attempts        TEXT NOT NULL,
+			claims          INTEGER NOT NULL DEFAULT 0,
+			next_attempt_at BIGINT,
+			created_at      BIGINT NOT NULL,
+			finished_at     BIGINT
+		)`,
+		`CREATE INDEX webhook_deliveries_due ON webhook_deliveries (status, next_attempt_at)`,
+		`CREATE INDEX webhook_deliveries_webhook ON webhook_deliveries (webhook_id, id)`,
+	},
+	{ // 12: the count of writes to each tenant's names
+		`CREATE TABLE name_revisions (
+			tenant TEXT PRIMARY KEY,
+			n      BIGINT NOT NULL,
+			at     BIGINT NOT NULL
+		)`,
+	},
+	{ // 13: the other keys of names; NULLs don't collide
+		`ALTER TABLE names ADD COLUMN uuid TEXT`,
+		`ALTER TABLE names ADD COLUMN slug TEXT`,
+		`CREATE UNIQUE INDEX names_tenant_uuid ON names (tenant, uuid)`,
+		`CREATE UNIQUE INDEX names_tenant_slug ON names (tenant, slug)`,
+	},
+	{ // 14: who created each name, for ?mine=true, and the role jobs run with
+		`ALTER TABLE names ADD COLUMN created_by TEXT`,
+		`CREATE INDEX names_tenant_created_by ON names (tenant, created_by)`,
+		`ALTER TABLE jobs ADD COLUMN actor_role TEXT NOT NULL DEFAULT ''`,
+	},
+	{ // 15: the normalized names, for ?match=; NULL until POST /admin/normalize
+		`ALTER TABLE names ADD COLUMN name_normalized TEXT`,
+		`CREATE INDEX names_tenant_name_normalized ON names (tenant, name_normalized)`,
+	},
+	{ // 16: who founded each tenant; the key lets only one user do it
+		`CREATE TABLE tenant_founders (
+			tenant  TEXT PRIMARY KEY,
+			user_id TEXT NOT NULL
+		)`,
+		`INSERT INTO tenant_founders (tenant, user_id) SELECT tenant, MIN(id) FROM users GROUP BY tenant`,
+	},
+}
+
+func (s *SQL) migrate(ctx context.Context) error {
+	if _, err := s.DB.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER PRIMARY KEY)`); err != nil { return err }
+	var current int
+	if err := s.DB.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&current); err != nil { return err }
+
+	blob := "BLOB"
+	if s.postgres { blob = "BYTEA" }
+	for v := current + 1; v <= len(migrations); v++ {
+		err := s.tx(ctx, func(tx *sql.Tx) error {
+			for _, stmt := range migrations[v-1] {
+				if _, err := tx.ExecContext(ctx, strings.ReplaceAll(stmt, "{{blob}}", blob)); err != nil { return err }
+			}
+			_, err := tx.ExecContext(ctx, s.rebind(`INSERT INTO schema_migrations (version) VALUES (?)`), v)
+			return err
+		})
+		if err != nil { return fmt.Errorf("version %d: %w", v, err) }
+	}
+	return nil
+}
+
+// tx runs fn in a transaction, committing if it returns nil.
+func (s *SQL) tx(ctx context.Context, fn func(*sql.Tx) error) error {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil { return err }
+	if err := fn(tx); err != nil { _ = tx.Rollback(); return err }
+	return tx.Commit()
+}
+
+// rebind turns the ? placeholders queries are written with into Postgres'
+// $1, $2, ...
+func (s *SQL) rebind(query string) string {
+	if !s.postgres { return query }
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// isUniqueViolation recognises both drivers' unique constraint errors.
+func isUniqueViolation(err error) bool {
+	var pg interface{ SQLState() string }
+	if errors.As(err, &pg) { return pg.SQLState() == "23505" }
+	return err != nil && strings.Contains(err.Error(), "UNIQUE constraint failed")
+}
+
+// Times are stored as Unix milliseconds, the precision MongoDB has too.
+func toMillis(t time.Time) int64 { return t.UnixMilli() }
+func fromMillis(ms int64) time.Time { return time.UnixMilli(ms).UTC() }
+
+// nullMillis is toMillis for an optional time, NULL if t is nil.
+func nullMillis(t *time.Time) sql.NullInt64 {
+	if t == nil { return sql.NullInt64{} }
+	return sql.NullInt64{Int64: toMillis(*t), Valid: true}
+}
+
+// nullString is s, NULL if it is empty.
+func nullString(s string) sql.NullString { return sql.NullString{String: s, Valid: s != ""} }
+
+func placeholders(n int) string { return strings.TrimSuffix(strings.Repeat("?, ", n), ", ") }
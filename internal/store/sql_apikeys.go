@@ -0,0 +1,90 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"app/internal/tenant"
+)
+
+// SQLAPIKeys is the APIKeyStore on SQLite or Postgres.
+type SQLAPIKeys struct {
+	db *SQL
+}
+
+func NewSQLAPIKeys(db *SQL) *SQLAPIKeys { return &SQLAPIKeys{db: db} }
+
+const apiKeyColumns = "id, user_id, tenant, name, prefix, hash, scopes, role, created_at, revoked_at"
+
+func (s *SQLAPIKeys) CreateAPIKey(ctx context.Context, k APIKey) error {
+	if k.ID.IsZero() { k.ID = primitive.NewObjectID() }
+	scopes, err := json.Marshal(k.Scopes)
+	if err != nil { return err }
+	if k.Tenant == "" { k.Tenant = tenant.Default }
+	_, err = s.db.DB.ExecContext(ctx, s.db.rebind(`INSERT INTO apikeys (id, user_id, tenant, name, prefix, hash, scopes, role, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`),
+		k.ID.Hex(), k.UserID.Hex(), k.Tenant, k.Name, k.Prefix, k.Hash, string(scopes), k.Role, toMillis(k.CreatedAt))
+	if isUniqueViolation(err) { return ErrDuplicate }
+	return err
+}
+
+func (s *SQLAPIKeys) APIKeyByHash(ctx context.Context, hash string) (APIKey, error) {
+	k, err := scanAPIKey(s.db.DB.QueryRowContext(ctx, s.db.rebind(`SELECT `+apiKeyColumns+` FROM apikeys WHERE hash = ? AND revoked_at IS NULL`), hash))
+	if errors.Is(err, sql.ErrNoRows) { return k, ErrNotFound }
+	return k, err
+}
+
+func (s *SQLAPIKeys) RevokeAPIKey(ctx context.Context, id, userID primitive.ObjectID) error {
+	res, err := s.db.DB.ExecContext(ctx, s.db.rebind(`UPDATE apikeys SET revoked_at = ? WHERE id = ? AND user_id = ? AND revoked_at IS NULL`),
+		toMillis(time.Now().UTC()), id.Hex(), userID.Hex())
+	if err != nil { return err }
+	if n, err := res.RowsAffected(); err != nil || n > 0 { return err }
+	return ErrNotFound
+}
+
+func (s *SQLAPIKeys) RevokeTenantAPIKey(ctx context.Context, tenant string, id primitive.ObjectID) error {
+	res, err := s.db.DB.ExecContext(ctx, s.db.rebind(`UPDATE apikeys SET revoked_at = ? WHERE id = ? AND tenant = ? AND revoked_at IS NULL`),
+		toMillis(time.Now().UTC()), id.Hex(), tenant)
+	if err != nil { return err }
+	if n, err := res.RowsAffected(); err != nil || n > 0 { return err }
+	return ErrNotFound
+}
+
+func (s *SQLAPIKeys) APIKeys(ctx context.Context, tenant string) ([]APIKey, error) {
+	rows, err := s.db.DB.QueryContext(ctx, s.db.rebind(`SELECT `+apiKeyColumns+` FROM apikeys WHERE tenant = ? ORDER BY created_at, id`), tenant)
+	if err != nil { return nil, err }
+	defer rows.Close()
+	keys := []APIKey{}
+	for rows.Next() {
+		k, err := scanAPIKey(rows)
+		if err != nil { return nil, err }
+		keys = append(keys, k)
+	}
+	return keys, rows.Err()
+}
+
+func (s *SQLAPIKeys) SetAPIKeyRole(ctx context.Context, tenant string, id primitive.ObjectID, role string) (APIKey, error) {
+	k, err := scanAPIKey(s.db.DB.QueryRowContext(ctx, s.db.rebind(`UPDATE apikeys SET role = ? WHERE id = ? AND tenant = ? RETURNING `+apiKeyColumns), role, id.Hex(), tenant))
+	if errors.Is(err, sql.ErrNoRows) { return k, ErrNotFound }
+	return k, err
+}
+
+func scanAPIKey(row scanner) (APIKey, error) {
+	var (
+		k               APIKey
+		id, uid, scopes string
+		created         int64
+		revoked         sql.NullInt64
+	)
+	if err := row.Scan(&id, &uid, &k.Tenant, &k.Name, &k.Prefix, &k.Hash, &scopes, &k.Role, &created, &revoked); err != nil { return k, err }
+	var err error
+	if k.ID, err = primitive.ObjectIDFromHex(id); err != nil { return k, err }
+	if k.UserID, err = primitive.ObjectIDFromHex(uid); err != nil { return k, err }
+	k.CreatedAt = fromMillis(created)
+	if revoked.Valid { r := fromMillis(revoked.Int64); k.RevokedAt = &r }
+	return k, json.Unmarshal([]byte(scopes), &k.Scopes)
+}
@@ -0,0 +1,67 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"app/internal/tenant"
+)
+
+// SQLAudit is the AuditStore on SQLite or Postgres. The before and after
+// documents are stored as JSON.
+type SQLAudit struct {
+	db *SQL
+}
+
+func NewSQLAudit(db *SQL) *SQLAudit { return &SQLAudit{db: db} }
+
+func (s *SQLAudit) RecordAudit(ctx context.Context, entries []AuditEntry) error {
+	if len(entries) == 0 { return nil }
+	tid := tenant.FromContext(ctx)
+	return s.db.tx(ctx, func(tx *sql.Tx) error {
+		stmt, err := tx.PrepareContext(ctx, s.db.rebind(`INSERT INTO audit (id, tenant, entity, entity_id, action, actor, request_id, at, before_doc, after_doc) VALUES (`+placeholders(10)+`)`))
+		if err != nil { return err }
+		defer stmt.Close()
+		for _, e := range entries {
+			before, err := jsonColumn(e.Before, e.Before == nil)
+			if err != nil { return err }
+			after, err := jsonColumn(e.After, e.After == nil)
+			if err != nil { return err }
+			if _, err := stmt.ExecContext(ctx, e.ID.Hex(), tid, e.Entity, e.EntityID.Hex(), e.Action, e.Actor, e.RequestID, toMillis(e.At), before, after); err != nil { return err }
+		}
+		return nil
+	})
+}
+
+// ListAudit relies on hex ObjectIDs sorting like the ObjectIDs themselves.
+func (s *SQLAudit) ListAudit(ctx context.Context, q AuditQuery) (AuditPage, error) {
+	where, args := []string{"tenant = ?"}, []any{tenant.FromContext(ctx)}
+	if q.Entity != "" { where, args = append(where, "entity = ?"), append(args, q.Entity) }
+	if !q.EntityID.IsZero() { where, args = append(where, "entity_id = ?"), append(args, q.EntityID.Hex()) }
+	if !q.Before.IsZero() { where, args = append(where, "id < ?"), append(args, q.Before.Hex()) }
+	rows, err := s.db.DB.QueryContext(ctx, s.db.rebind(`SELECT id, entity, entity_id, action, actor, request_id, at, before_doc, after_doc FROM audit WHERE `+strings.Join(where, " AND ")+` ORDER BY id DESC LIMIT ?`), append(args, q.Limit+1)...)
+	if err != nil { return AuditPage{}, err }
+	defer rows.Close()
+
+	entries := []AuditEntry{}
+	for rows.Next() {
+		var (
+			e             AuditEntry
+			id, entityID  string
+			at            int64
+			before, after sql.NullString
+		)
+		if err := rows.Scan(&id, &e.Entity, &entityID, &e.Action, &e.Actor, &e.RequestID, &at, &before, &after); err != nil { return AuditPage{}, err }
+		if e.ID, err = primitive.ObjectIDFromHex(id); err != nil { return AuditPage{}, err }
+		if e.EntityID, err = primitive.ObjectIDFromHex(entityID); err != nil { return AuditPage{}, err }
+		e.At = fromMillis(at)
+		if before.Valid { if err := json.Unmarshal([]byte(before.String), &e.Before); err != nil { return AuditPage{}, err } }
+		if after.Valid { if err := json.Unmarshal([]byte(after.String), &e.After); err != nil { return AuditPage{}, err } }
+		entries = append(entries, e)
+	}
+	return auditPage(entries, q.Limit), rows.Err()
+}
@@ -0,0 +1,102 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"app/internal/tenant"
+)
+
+// SQLDocs is the DocStore on SQLite or Postgres: every resource's documents
+// in one table, their fields as JSON. Read back, numbers are float64 and
+// times strings, which encode to the same JSON.
+type SQLDocs struct {
+	db *SQL
+}
+
+func NewSQLDocs(db *SQL) *SQLDocs { return &SQLDocs{db: db} }
+
+const docColumns = "id, tenant, fields, created_at, updated_at, version"
+
+func (s *SQLDocs) CreateDoc(ctx context.Context, collection string, d *Doc) error {
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	d.ID, d.Tenant, d.CreatedAt, d.UpdatedAt, d.Version = primitive.NewObjectID(), tenant.FromContext(ctx), now, now, 1
+	fields, err := json.Marshal(d.Fields)
+	if err != nil { return err }
+	_, err = s.db.DB.ExecContext(ctx, s.db.rebind(`INSERT INTO docs (collection, `+docColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?)`),
+		collection, d.ID.Hex(), d.Tenant, string(fields), toMillis(now), toMillis(now), d.Version)
+	return err
+}
+
+func (s *SQLDocs) GetDoc(ctx context.Context, collection string, id primitive.ObjectID) (Doc, error) {
+	d, err := scanDoc(s.db.DB.QueryRowContext(ctx, s.db.rebind(`SELECT `+docColumns+` FROM docs WHERE collection = ? AND tenant = ? AND id = ?`),
+		collection, tenant.FromContext(ctx), id.Hex()))
+	if errors.Is(err, sql.ErrNoRows) { return d, ErrNotFound }
+	return d, err
+}
+
+func (s *SQLDocs) ListDocs(ctx context.Context, collection string, after primitive.ObjectID, limit int) (DocPage, error) {
+	// One more than asked for tells whether there is a next page.
+	rows, err := s.db.DB.QueryContext(ctx, s.db.rebind(`SELECT `+docColumns+` FROM docs WHERE collection = ? AND tenant = ? AND id > ? ORDER BY id LIMIT ?`),
+		collection, tenant.FromContext(ctx), after.Hex(), limit+1)
+	if err != nil { return DocPage{}, err }
+	defer rows.Close()
+	page := DocPage{Items: []Doc{}}
+	for rows.Next() {
+		if len(page.Items) == limit { page.Next = page.Items[limit-1].ID.Hex(); break }
+		d, err := scanDoc(rows)
+		if err != nil { return DocPage{}, err }
+		page.Items = append(page.Items, d)
+	}
+	return page, rows.Err()
+}
+
+func (s *SQLDocs) UpdateDoc(ctx context.Context, collection string, id primitive.ObjectID, fields map[string]any, ifVersion int64) (Doc, error) {
+	raw, err := json.Marshal(fields)
+	if err != nil { return Doc{}, err }
+	query := `UPDATE docs SET fields = ?, updated_at = ?, version = version + 1 WHERE collection = ? AND tenant = ? AND id = ?`
+	args := []any{string(raw), toMillis(time.Now().UTC()), collection, tenant.FromContext(ctx), id.Hex()}
+	if ifVersion != AnyVersion { query += " AND version = ?"; args = append(args, ifVersion) }
+	d, err := scanDoc(s.db.DB.QueryRowContext(ctx, s.db.rebind(query+` RETURNING `+docColumns), args...))
+	if errors.Is(err, sql.ErrNoRows) { return d, s.missed(ctx, collection, id) }
+	return d, err
+}
+
+func (s *SQLDocs) DeleteDoc(ctx context.Context, collection string, id primitive.ObjectID, ifVersion int64) error {
+	query := `DELETE FROM docs WHERE collection = ? AND tenant = ? AND id = ?`
+	args := []any{collection, tenant.FromContext(ctx), id.Hex()}
+	if ifVersion != AnyVersion { query += " AND version = ?"; args = append(args, ifVersion) }
+	res, err := s.db.DB.ExecContext(ctx, s.db.rebind(query), args...)
+	if err != nil { return err }
+	if n, err := res.RowsAffected(); err != nil || n > 0 { return err }
+	return s.missed(ctx, collection, id)
+}
+
+// missed explains a conditional write that matched nothing: either the
+// document is gone or it is at another version.
+func (s *SQLDocs) missed(ctx context.Context, collection string, id primitive.ObjectID) error {
+	var exists int
+	err := s.db.DB.QueryRowContext(ctx, s.db.rebind(`SELECT 1 FROM docs WHERE collection = ? AND tenant = ? AND id = ?`),
+		collection, tenant.FromContext(ctx), id.Hex()).Scan(&exists)
+	if errors.Is(err, sql.ErrNoRows) { return ErrNotFound }
+	if err != nil { return err }
+	return ErrVersionMismatch
+}
+
+func scanDoc(row scanner) (Doc, error) {
+	var (
+		d                Doc
+		id, fields       string
+		created, updated int64
+	)
+	if err := row.Scan(&id, &d.Tenant, &fields, &created, &updated, &d.Version); err != nil { return d, err }
+	var err error
+	if d.ID, err = primitive.ObjectIDFromHex(id); err != nil { return d, err }
+	d.CreatedAt, d.UpdatedAt = fromMillis(created), fromMillis(updated)
+	return d, json.Unmarshal([]byte(fields), &d.Fields)
+}
@@ -0,0 +1,17 @@
+package store
+
+import "context"
+
+// Duplicates groups the live names as it reads them: SQL has no
+// comparison folding accents on every database this store runs on.
+func (s *SQLNames) Duplicates(ctx context.Context, q DuplicateQuery) ([]DuplicateCluster, error) {
+	groups := map[string][]Name{}
+	err := s.Each(ctx, ListOptions{}, func(n Name) error {
+		key := NormalizeName(n.Name)
+		groups[key] = append(groups[key], n)
+		if q.Distance > 0 && len(groups) > MaxFuzzyKeys { return ErrTooManyNames }
+		return nil
+	})
+	if err != nil { return nil, err }
+	return clusters(groups, q)
+}
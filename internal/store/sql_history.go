@@ -0,0 +1,52 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"app/internal/tenant"
+)
+
+// SQLHistory is the HistoryStore on SQLite or Postgres, each version kept
+// as the JSON of the name.
+type SQLHistory struct {
+	db *SQL
+}
+
+func NewSQLHistory(db *SQL) *SQLHistory { return &SQLHistory{db: db} }
+
+func (s *SQLHistory) SaveVersions(ctx context.Context, ns []Name) error {
+	if len(ns) == 0 { return nil }
+	tid := tenant.FromContext(ctx)
+	return s.db.tx(ctx, func(tx *sql.Tx) error {
+		stmt, err := tx.PrepareContext(ctx, s.db.rebind(`INSERT INTO name_history (tenant, name_id, version, doc) VALUES (?, ?, ?, ?) ON CONFLICT DO NOTHING`))
+		if err != nil { return err }
+		defer stmt.Close()
+		for _, n := range ns {
+			doc, err := json.Marshal(n)
+			if err != nil { return err }
+			if _, err := stmt.ExecContext(ctx, tid, n.ID.Hex(), n.Version, string(doc)); err != nil { return err }
+		}
+		return nil
+	})
+}
+
+func (s *SQLHistory) Versions(ctx context.Context, id primitive.ObjectID) ([]Name, error) {
+	rows, err := s.db.DB.QueryContext(ctx, s.db.rebind(`SELECT doc FROM name_history WHERE tenant = ? AND name_id = ? ORDER BY version DESC`), tenant.FromContext(ctx), id.Hex())
+	if err != nil { return nil, err }
+	defer rows.Close()
+	out := []Name{}
+	for rows.Next() {
+		var (
+			doc string
+			n   Name
+		)
+		if err := rows.Scan(&doc); err != nil { return nil, err }
+		if err := json.Unmarshal([]byte(doc), &n); err != nil { return nil, err }
+		out = append(out, n)
+	}
+	return out, rows.Err()
+}
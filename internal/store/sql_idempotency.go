@@ -0,0 +1,61 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// SQLIdempotency is the IdempotencyStore on SQLite or Postgres. Expired
+// keys are deleted when they are next claimed.
+type SQLIdempotency struct {
+	db *SQL
+}
+
+func NewSQLIdempotency(db *SQL) *SQLIdempotency { return &SQLIdempotency{db: db} }
+
+func (s *SQLIdempotency) Claim(ctx context.Context, key, requestHash string, ttl time.Duration) (*IdempotencyRecord, error) {
+	for {
+		now := time.Now().UTC()
+		_, err := s.db.DB.ExecContext(ctx, s.db.rebind(`INSERT INTO idempotency_keys (idem_key, request_hash, expires_at) VALUES (?, ?, ?)`),
+			key, requestHash, toMillis(now.Add(ttl)))
+		if err == nil { return nil, nil }
+		if !isUniqueViolation(err) { return nil, err }
+
+		rec, err := s.get(ctx, key)
+		if errors.Is(err, sql.ErrNoRows) { continue } // released meanwhile
+		if err != nil { return nil, err }
+		if !rec.ExpiresAt.Before(now) { return rec, nil }
+		// Only delete the record we saw expire, not one a racing claim made.
+		_, err = s.db.DB.ExecContext(ctx, s.db.rebind(`DELETE FROM idempotency_keys WHERE idem_key = ? AND expires_at = ?`), key, toMillis(rec.ExpiresAt))
+		if err != nil { return nil, err }
+	}
+}
+
+func (s *SQLIdempotency) get(ctx context.Context, key string) (*IdempotencyRecord, error) {
+	var (
+		rec     = IdempotencyRecord{Key: key}
+		headers sql.NullString
+		expires int64
+	)
+	err := s.db.DB.QueryRowContext(ctx, s.db.rebind(`SELECT request_hash, status, headers, body, expires_at FROM idempotency_keys WHERE idem_key = ?`), key).
+		Scan(&rec.RequestHash, &rec.Status, &headers, &rec.Body, &expires)
+	if err != nil { return nil, err }
+	if headers.Valid { if err := json.Unmarshal([]byte(headers.String), &rec.Headers); err != nil { return nil, err } }
+	rec.ExpiresAt = fromMillis(expires)
+	return &rec, nil
+}
+
+func (s *SQLIdempotency) Complete(ctx context.Context, key string, status int, headers map[string]string, body []byte) error {
+	h, err := jsonColumn(headers, len(headers) == 0)
+	if err != nil { return err }
+	_, err = s.db.DB.ExecContext(ctx, s.db.rebind(`UPDATE idempotency_keys SET status = ?, headers = ?, body = ? WHERE idem_key = ?`), status, h, body, key)
+	return err
+}
+
+func (s *SQLIdempotency) Release(ctx context.Context, key string) error {
+	_, err := s.db.DB.ExecContext(ctx, s.db.rebind(`DELETE FROM idempotency_keys WHERE idem_key = ?`), key)
+	return err
+}
@@ -0,0 +1,110 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"app/internal/tenant"
+)
+
+// SQLJobs is the JobStore on SQLite or Postgres.
+type SQLJobs struct {
+	db *SQL
+}
+
+func NewSQLJobs(db *SQL) *SQLJobs { return &SQLJobs{db: db} }
+
+// jobColumns leave out input and output, which only some reads want.
+const jobColumns = "id, tenant, type, status, actor, actor_role, request_id, params, result, error, attempts, lease_until, created_at, started_at, finished_at"
+
+// claimableWhere is ClaimJob's filter; its one argument is now.
+const claimableWhere = `(status = 'queued' OR (status = 'running' AND lease_until < ?))`
+
+func (s *SQLJobs) EnqueueJob(ctx context.Context, j *Job) error {
+	j.ID, j.Tenant, j.Status, j.CreatedAt = primitive.NewObjectID(), tenant.FromContext(ctx), JobQueued, time.Now().UTC().Truncate(time.Millisecond)
+	_, err := s.db.DB.ExecContext(ctx, s.db.rebind(`INSERT INTO jobs (id, tenant, type, status, actor, actor_role, request_id, params, input, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`),
+		j.ID.Hex(), j.Tenant, j.Type, j.Status, j.Actor, j.ActorRole, j.RequestID, j.Params, j.Input, toMillis(j.CreatedAt))
+	return err
+}
+
+func (s *SQLJobs) Job(ctx context.Context, id primitive.ObjectID) (Job, error) {
+	j, err := scanJob(s.db.DB.QueryRowContext(ctx, s.db.rebind(`SELECT `+jobColumns+` FROM jobs WHERE id = ? AND tenant = ?`), id.Hex(), tenant.FromContext(ctx)), false)
+	if errors.Is(err, sql.ErrNoRows) { return j, ErrNotFound }
+	return j, err
+}
+
+func (s *SQLJobs) JobOutput(ctx context.Context, id primitive.ObjectID) ([]byte, error) {
+	var out []byte
+	err := s.db.DB.QueryRowContext(ctx, s.db.rebind(`SELECT output FROM jobs WHERE id = ? AND tenant = ?`), id.Hex(), tenant.FromContext(ctx)).Scan(&out)
+	if errors.Is(err, sql.ErrNoRows) { return nil, ErrNotFound }
+	return out, err
+}
+
+// ClaimJob picks the job and takes it in one UPDATE. The filter is repeated
+// on the row taken: should another worker take it first, Postgres re-checks
+// it and updates nothing, and the claim comes back empty.
+func (s *SQLJobs) ClaimJob(ctx context.Context, lease time.Duration) (Job, error) {
+	now := toMillis(time.Now().UTC())
+	row := s.db.DB.QueryRowContext(ctx, s.db.rebind(`UPDATE jobs SET status = 'running', lease_until = ?, started_at = ?, attempts = attempts + 1
+		WHERE id = (SELECT id FROM jobs WHERE `+claimableWhere+` ORDER BY id LIMIT 1) AND `+claimableWhere+`
+		RETURNING `+jobColumns+`, input`), now+lease.Milliseconds(), now, now, now)
+	j, err := scanJob(row, true)
+	if errors.Is(err, sql.ErrNoRows) { return j, ErrNotFound }
+	return j, err
+}
+
+// update runs set on job j if no one has claimed it since j was.
+func (s *SQLJobs) update(ctx context.Context, j Job, set string, args ...any) error {
+	args = append(args, j.ID.Hex(), j.Attempts)
+	res, err := s.db.DB.ExecContext(ctx, s.db.rebind(`UPDATE jobs SET `+set+` WHERE id = ? AND attempts = ? AND status = 'running'`), args...)
+	if err != nil { return err }
+	if n, err := res.RowsAffected(); err != nil || n > 0 { return err }
+	var exists int
+	err = s.db.DB.QueryRowContext(ctx, s.db.rebind(`SELECT 1 FROM jobs WHERE id = ?`), j.ID.Hex()).Scan(&exists)
+	if errors.Is(err, sql.ErrNoRows) { return ErrNotFound }
+	if err != nil { return err }
+	return ErrVersionMismatch
+}
+
+func (s *SQLJobs) RenewJob(ctx context.Context, j Job, lease time.Duration) error {
+	return s.update(ctx, j, `lease_until = ?`, toMillis(time.Now().UTC().Add(lease)))
+}
+
+func (s *SQLJobs) FinishJob(ctx context.Context, j Job) error {
+	if j.Status == JobQueued { return s.update(ctx, j, `status = ?, lease_until = NULL`, j.Status) }
+	var result sql.NullString
+	if j.Result != nil { result = sql.NullString{String: string(j.Result), Valid: true} }
+	return s.update(ctx, j, `status = ?, result = ?, output = ?, error = ?, lease_until = NULL, finished_at = ?`,
+		j.Status, result, j.Output, j.Error, toMillis(time.Now().UTC()))
+}
+
+func (s *SQLJobs) PurgeJobs(ctx context.Context, before time.Time) error {
+	_, err := s.db.DB.ExecContext(ctx, s.db.rebind(`DELETE FROM jobs WHERE finished_at < ?`), toMillis(before))
+	return err
+}
+
+// scanJob reads the jobColumns, followed by input unless withInput is false.
+func scanJob(row scanner, withInput bool) (Job, error) {
+	var (
+		j                        Job
+		id                       string
+		result                   sql.NullString
+		created                  int64
+		lease, started, finished sql.NullInt64
+	)
+	dest := []any{&id, &j.Tenant, &j.Type, &j.Status, &j.Actor, &j.ActorRole, &j.RequestID, &j.Params, &result, &j.Error, &j.Attempts, &lease, &created, &started, &finished}
+	if withInput { dest = append(dest, &j.Input) }
+	if err := row.Scan(dest...); err != nil { return j, err }
+	var err error
+	if j.ID, err = primitive.ObjectIDFromHex(id); err != nil { return j, err }
+	if result.Valid { j.Result = []byte(result.String) }
+	j.CreatedAt = fromMillis(created)
+	if lease.Valid { t := fromMillis(lease.Int64); j.LeaseUntil = &t }
+	if started.Valid { t := fromMillis(started.Int64); j.StartedAt = &t }
+	if finished.Valid { t := fromMillis(finished.Int64); j.FinishedAt = &t }
+	return j, nil
+}
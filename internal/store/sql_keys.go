@@ -0,0 +1,39 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"app/internal/tenant"
+)
+
+func (s *SQLNames) NameByKey(ctx context.Context, key string) (primitive.ObjectID, error) {
+	var id string
+	err := s.db.DB.QueryRowContext(ctx, s.db.rebind(`SELECT id FROM names WHERE tenant = ? AND (uuid = ? OR slug = ?)`), tenant.FromContext(ctx), key, key).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) { return primitive.NilObjectID, ErrNotFound }
+	if err != nil { return primitive.NilObjectID, err }
+	return primitive.ObjectIDFromHex(id)
+}
+
+func (s *SQLNames) NameByName(ctx context.Context, name string) (Name, error) {
+	return s.named(ctx, s.db.DB, name)
+}
+
+func (s *SQLNames) SlugsTaken(ctx context.Context, slugs []string) (map[string]bool, error) {
+	out := map[string]bool{}
+	if len(slugs) == 0 { return out, nil }
+	args := []any{tenant.FromContext(ctx)}
+	for _, slug := range slugs { args = append(args, slug) }
+	rows, err := s.db.DB.QueryContext(ctx, s.db.rebind(`SELECT slug FROM names WHERE tenant = ? AND slug IN (`+placeholders(len(slugs))+`)`), args...)
+	if err != nil { return nil, err }
+	defer rows.Close()
+	for rows.Next() {
+		var slug string
+		if err := rows.Scan(&slug); err != nil { return nil, err }
+		out[slug] = true
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,499 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"app/internal/tenant"
+)
+
+// SQLNames is the NameStore on SQLite or Postgres. IDs are still ObjectIDs,
+// stored as hex, so clients can't tell the backends apart. There are no
+// change streams: Watch fails with ErrWatchUnsupported.
+type SQLNames struct {
+	db *SQL
+}
+
+func NewSQLNames(db *SQL) *SQLNames { return &SQLNames{db: db} }
+
+const nameColumns = "id, tenant, name, tags, metadata, created_at, updated_at, deleted_at, version, expires_at, uuid, slug, created_by, name_normalized"
+
+type scanner interface{ Scan(dest ...any) error }
+
+// querier is a *sql.DB or a *sql.Tx.
+type querier interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+func scanName(row scanner) (Name, error) {
+	var (
+		n                    Name
+		id                   string
+		tags, metadata       sql.NullString
+		created, updated     int64
+		deleted, expires     sql.NullInt64
+		uuid, slug, creator  sql.NullString
+		normalized           sql.NullString
+	)
+	if err := row.Scan(&id, &n.Tenant, &n.Name, &tags, &metadata, &created, &updated, &deleted, &n.Version, &expires, &uuid, &slug, &creator, &normalized); err != nil { return n, err }
+	var err error
+	if n.ID, err = primitive.ObjectIDFromHex(id); err != nil { return n, err }
+	if tags.Valid { if err := json.Unmarshal([]byte(tags.String), &n.Tags); err != nil { return n, err } }
+	if metadata.Valid { if err := json.Unmarshal([]byte(metadata.String), &n.Metadata); err != nil { return n, err } }
+	n.CreatedAt, n.UpdatedAt = fromMillis(created), fromMillis(updated)
+	if deleted.Valid { d := fromMillis(deleted.Int64); n.DeletedAt = &d }
+	if expires.Valid { e := fromMillis(expires.Int64); n.ExpiresAt = &e }
+	n.UUID, n.Slug, n.CreatedBy, n.NameNormalized = uuid.String, slug.String, creator.String, normalized.String
+	return n, nil
+}
+
+// jsonColumn encodes tags or metadata; empty values are stored as NULL.
+func jsonColumn[T any](v T, empty bool) (sql.NullString, error) {
+	if empty { return sql.NullString{}, nil }
+	b, err := json.Marshal(v)
+	return sql.NullString{String: string(b), Valid: true}, err
+}
+
+// insertName stores n in the tenant of ctx and, if withEvent, its "created"
+// event.
+func (s *SQLNames) insertName(ctx context.Context, tx *sql.Tx, n *Name, now time.Time, withEvent bool) error {
+	stamp(n, tenant.FromContext(ctx), now)
+	tags, err := jsonColumn(n.Tags, len(n.Tags) == 0)
+	if err != nil { return err }
+	metadata, err := jsonColumn(n.Metadata, len(n.Metadata) == 0)
+	if err != nil { return err }
+
+	_, err = tx.ExecContext(ctx, s.db.rebind(`INSERT INTO names (`+nameColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, NULL, ?, ?, ?, ?, ?, ?)`),
+		n.ID.Hex(), n.Tenant, n.Name, tags, metadata, toMillis(n.CreatedAt), toMillis(n.UpdatedAt), n.Version, nullMillis(n.ExpiresAt), nullString(n.UUID), nullString(n.Slug), nullString(n.CreatedBy), nullString(n.NameNormalized))
+	if isUniqueViolation(err) { return ErrDuplicate }
+	if err != nil || !withEvent { return err }
+	_, err = tx.ExecContext(ctx, s.db.rebind(`INSERT INTO name_events (id, name_id, tenant, type, name, at) VALUES (?, ?, ?, ?, ?, ?)`),
+		primitive.NewObjectID().Hex(), n.ID.Hex(), n.Tenant, "created", n.Name, toMillis(now))
+	return err
+}
+
+func (s *SQLNames) Create(ctx context.Context, n *Name) error {
+	return s.db.tx(ctx, func(tx *sql.Tx) error { return s.insertName(ctx, tx, n, time.Now().UTC(), true) })
+}
+
+// CreateIfAbsent leans on the unique index on tenant and name: an insert
+// that fails on it reads back the row that was there first.
+func (s *SQLNames) CreateIfAbsent(ctx context.Context, n *Name) (bool, error) {
+	err := s.Create(ctx, n)
+	if !errors.Is(err, ErrDuplicate) { return err == nil, err }
+	existing, err := s.named(ctx, s.db.DB, n.Name)
+	if errors.Is(err, ErrNotFound) { return false, ErrDuplicate } // its UUID or slug is taken
+	if err != nil { return false, err }
+	*n = existing
+	return false, nil
+}
+
+// named returns the row of the tenant called name, soft-deleted or not.
+func (s *SQLNames) named(ctx context.Context, q querier, name string) (Name, error) {
+	n, err := scanName(q.QueryRowContext(ctx, s.db.rebind(`SELECT `+nameColumns+` FROM names WHERE tenant = ? AND name = ?`), tenant.FromContext(ctx), name))
+	if errors.Is(err, sql.ErrNoRows) { return n, ErrNotFound }
+	return n, err
+}
+
+// Upsert inserts as CreateIfAbsent does when it may create, and otherwise,
+// or if the name was there already, updates that row in one transaction.
+func (s *SQLNames) Upsert(ctx context.Context, n Name, ifVersion int64) (*Name, Name, error) {
+	want := n
+	if ifVersion == AnyVersion {
+		created, err := s.CreateIfAbsent(ctx, &n)
+		if err != nil || created { return nil, n, err }
+	}
+	var before *Name
+	var after Name
+	err := s.db.tx(ctx, func(tx *sql.Tx) error {
+		existing, err := s.named(ctx, tx, want.Name)
+		if err != nil { return err }
+		if existing.DeletedAt != nil { return ErrDuplicate }
+		before = &existing
+		after, err = s.patch(ctx, tx, existing.ID, replacing(want), ifVersion)
+		return err
+	})
+	if err != nil { return nil, Name{}, err }
+	return before, after, nil
+}
+
+func (s *SQLNames) Get(ctx context.Context, id primitive.ObjectID) (Name, error) {
+	n, err := scanName(s.db.DB.QueryRowContext(ctx, s.db.rebind(`SELECT `+nameColumns+` FROM names WHERE tenant = ? AND id = ? AND deleted_at IS NULL`), tenant.FromContext(ctx), id.Hex()))
+	if errors.Is(err, sql.ErrNoRows) { return n, ErrNotFound }
+	return n, err
+}
+
+func (s *SQLNames) Lookup(ctx context.Context, ids []primitive.ObjectID) (map[primitive.ObjectID]Name, error) {
+	out := map[primitive.ObjectID]Name{}
+	if len(ids) == 0 { return out, nil }
+	args := []any{tenant.FromContext(ctx)}
+	for _, id := range ids { args = append(args, id.Hex()) }
+	rows, err := s.db.DB.QueryContext(ctx, s.db.rebind(`SELECT `+nameColumns+` FROM names WHERE tenant = ? AND id IN (`+placeholders(len(ids))+`)`), args...)
+	if err != nil { return nil, err }
+	defer rows.Close()
+	for rows.Next() {
+		n, err := scanName(rows)
+		if err != nil { return nil, err }
+		out[n.ID] = n
+	}
+	return out, rows.Err()
+}
+
+// listWhere is listFilter for SQL.
+func (s *SQLNames) listWhere(tid string, opts ListOptions) (string, []any) {
+	conds, args := []string{"tenant = ?"}, []any{tid}
+	switch {
+	case opts.OnlyDeleted:
+		conds = append(conds, "deleted_at IS NOT NULL")
+	case !opts.IncludeDeleted:
+		conds = append(conds, "deleted_at IS NULL")
+	}
+	if opts.NamePrefix != "" {
+		// substr rather than LIKE: SQLite's LIKE ignores case.
+		conds = append(conds, "substr(name, 1, ?) = ?")
+		args = append(args, utf8.RuneCountInString(opts.NamePrefix), opts.NamePrefix)
+	}
+	if opts.Match != "" {
+		conds = append(conds, "(name_normalized = ? OR (name_normalized IS NULL AND name = ?))")
+		args = append(args, opts.Match, opts.Match)
+	}
+	if opts.CreatedBy != "" { conds, args = append(conds, "created_by = ?"), append(args, opts.CreatedBy) }
+	if len(opts.Tags) > 0 {
+		cond, targs := s.tagsWhere(opts)
+		conds, args = append(conds, cond), append(args, targs...)
+	}
+	return " WHERE " + strings.Join(conds, " AND "), args
+}
+
+// tagsWhere matches the names with opts' tags. Tags are stored as a JSON
+// array: Postgres tests containment in it, SQLite looks through json_each.
+// Neither has an index to help, unlike MongoDB's multikey one.
+func (s *SQLNames) tagsWhere(opts ListOptions) (string, []any) {
+	var conds []string
+	var args []any
+	if s.db.postgres {
+		if !opts.AnyTag {
+			b, _ := json.Marshal(opts.Tags)
+			return "tags::jsonb @> ?::jsonb", []any{string(b)}
+		}
+		for _, t := range opts.Tags {
+			b, _ := json.Marshal([]string{t})
+			conds, args = append(conds, "tags::jsonb @> ?::jsonb"), append(args, string(b))
+		}
+		return "(" + strings.Join(conds, " OR ") + ")", args
+	}
+	if opts.AnyTag {
+		for _, t := range opts.Tags { args = append(args, t) }
+		return "EXISTS (SELECT 1 FROM json_each(tags) WHERE value IN (?" + strings.Repeat(", ?", len(args)-1) + "))", args
+	}
+	for _, t := range opts.Tags {
+		conds, args = append(conds, "EXISTS (SELECT 1 FROM json_each(tags) WHERE value = ?)"), append(args, t)
+	}
+	return strings.Join(conds, " AND "), args
+}
+
+// listOrder sorts by opts' sort field, then id as the tie-breaker. IDs are
+// ObjectIDs, so id order is creation order. Names sort by the database's
+// collation; opts.Locale is ignored.
+func listOrder(opts ListOptions) string {
+	dir := " ASC"
+	if opts.Desc { dir = " DESC" }
+	if opts.SortBy == "name" { return " ORDER BY name" + dir + ", id" + dir }
+	return " ORDER BY id" + dir
+}
+
+func (s *SQLNames) List(ctx context.Context, opts ListOptions) (Page, error) {
+	page := Page{Items: []Name{}}
+	where, args := s.listWhere(tenant.FromContext(ctx), opts)
+	if err := s.db.DB.QueryRowContext(ctx, s.db.rebind(`SELECT COUNT(*) FROM names`+where), args...).Scan(&page.Total); err != nil { return page, err }
+
+	op := ">"
+	if opts.Desc { op = "<" }
+	if c := opts.After; c != nil {
+		cond := "id " + op + " ?"
+		cargs := []any{c.ID.Hex()}
+		if opts.SortBy == "name" {
+			cond = "(name " + op + " ? OR (name = ? AND id " + op + " ?))"
+			cargs = []any{c.Value, c.Value, c.ID.Hex()}
+		}
+		where += " AND " + cond
+		args = append(args, cargs...)
+	}
+
+	rows, err := s.db.DB.QueryContext(ctx, s.db.rebind(`SELECT `+nameColumns+` FROM names`+where+listOrder(opts)+` LIMIT ? OFFSET ?`),
+		append(args, opts.Limit+1, opts.Offset)...)
+	if err != nil { return page, err }
+	defer rows.Close()
+	for rows.Next() {
+		n, err := scanName(rows)
+		if err != nil { return page, err }
+		page.Items = append(page.Items, n)
+	}
+	if err := rows.Err(); err != nil { return page, err }
+
+	// Like the Mongo store, one extra row tells whether there's a next page.
+	if int64(len(page.Items)) > opts.Limit {
+		page.Items = page.Items[:opts.Limit]
+		page.Next = cursorFor(opts, page.Items[opts.Limit-1])
+	}
+	return page, nil
+}
+
+func (s *SQLNames) Each(ctx context.Context, opts ListOptions, fn func(Name) error) error {
+	where, args := s.listWhere(tenant.FromContext(ctx), opts)
+	rows, err := s.db.DB.QueryContext(ctx, s.db.rebind(`SELECT `+nameColumns+` FROM names`+where+listOrder(opts)), args...)
+	if err != nil { return err }
+	defer rows.Close()
+	for rows.Next() {
+		n, err := scanName(rows)
+		if err != nil { return err }
+		if err := fn(n); err != nil { return err }
+	}
+	return rows.Err()
+}
+
+func (s *SQLNames) Update(ctx context.Context, id primitive.ObjectID, n Name, ifVersion int64) (Name, error) {
+	return s.Patch(ctx, id, NamePatch{Name: &n.Name, Tags: &n.Tags, Metadata: &n.Metadata, ExpiresAt: Expiry{Set: true, At: n.ExpiresAt}, NameNormalized: &n.NameNormalized}, ifVersion)
+}
+
+// conditional runs an UPDATE or DELETE whose WHERE clause ends with the
+// row's tenant and id and, unless ifVersion is AnyVersion, its version; if
+// no row was affected it works out whether the row is missing or at another
+// version.
+func (s *SQLNames) conditional(ctx context.Context, tx *sql.Tx, stmt, existsWhere string, id primitive.ObjectID, ifVersion int64, args ...any) error {
+	tid := tenant.FromContext(ctx)
+	query := stmt + " AND tenant = ? AND id = ?"
+	args = append(args, tid, id.Hex())
+	if ifVersion != AnyVersion {
+		query += " AND version = ?"
+		args = append(args, ifVersion)
+	}
+	res, err := tx.ExecContext(ctx, s.db.rebind(query), args...)
+	if isUniqueViolation(err) { return ErrDuplicate }
+	if err != nil { return err }
+	if affected, err := res.RowsAffected(); err != nil || affected > 0 { return err }
+
+	if ifVersion == AnyVersion { return ErrNotFound }
+	var exists int
+	err = tx.QueryRowContext(ctx, s.db.rebind(`SELECT 1 FROM names WHERE tenant = ? AND id = ?`+existsWhere), tid, id.Hex()).Scan(&exists)
+	if errors.Is(err, sql.ErrNoRows) { return ErrNotFound }
+	if err != nil { return err }
+	return ErrVersionMismatch
+}
+
+func (s *SQLNames) Patch(ctx context.Context, id primitive.ObjectID, p NamePatch, ifVersion int64) (Name, error) {
+	var n Name
+	err := s.db.tx(ctx, func(tx *sql.Tx) error {
+		var err error
+		n, err = s.patch(ctx, tx, id, p, ifVersion)
+		return err
+	})
+	return n, err
+}
+
+// patch is Patch within tx.
+func (s *SQLNames) patch(ctx context.Context, tx *sql.Tx, id primitive.ObjectID, p NamePatch, ifVersion int64) (Name, error) {
+	sets := []string{"updated_at = ?", "version = version + 1"}
+	args := []any{toMillis(time.Now().UTC())}
+	if p.Name != nil { sets = append(sets, "name = ?"); args = append(args, *p.Name) }
+	if p.NameNormalized != nil { sets = append(sets, "name_normalized = ?"); args = append(args, nullString(*p.NameNormalized)) }
+	if p.CreatedBy != nil { sets = append(sets, "created_by = ?"); args = append(args, nullString(*p.CreatedBy)) }
+	if p.UUID != nil { sets = append(sets, "uuid = ?"); args = append(args, nullString(*p.UUID)) }
+	if p.Slug != nil { sets = append(sets, "slug = ?"); args = append(args, nullString(*p.Slug)) }
+	if p.Tags != nil {
+		tags, err := jsonColumn(*p.Tags, len(*p.Tags) == 0)
+		if err != nil { return Name{}, err }
+		sets = append(sets, "tags = ?"); args = append(args, tags)
+	}
+	if p.Metadata != nil {
+		metadata, err := jsonColumn(*p.Metadata, len(*p.Metadata) == 0)
+		if err != nil { return Name{}, err }
+		sets = append(sets, "metadata = ?"); args = append(args, metadata)
+	}
+	if p.ExpiresAt.Set { sets = append(sets, "expires_at = ?"); args = append(args, nullMillis(p.ExpiresAt.At)) }
+
+	err := s.conditional(ctx, tx, `UPDATE names SET `+strings.Join(sets, ", ")+` WHERE deleted_at IS NULL`, " AND deleted_at IS NULL", id, ifVersion, args...)
+	if err != nil { return Name{}, err }
+	return scanName(tx.QueryRowContext(ctx, s.db.rebind(`SELECT `+nameColumns+` FROM names WHERE id = ?`), id.Hex()))
+}
+
+func (s *SQLNames) SoftDelete(ctx context.Context, id primitive.ObjectID, ifVersion int64) error {
+	return s.db.tx(ctx, func(tx *sql.Tx) error {
+		return s.conditional(ctx, tx, `UPDATE names SET deleted_at = ?, version = version + 1 WHERE deleted_at IS NULL`, " AND deleted_at IS NULL",
+			id, ifVersion, toMillis(time.Now().UTC()))
+	})
+}
+
+func (s *SQLNames) HardDelete(ctx context.Context, id primitive.ObjectID, ifVersion int64) error {
+	return s.db.tx(ctx, func(tx *sql.Tx) error {
+		return s.conditional(ctx, tx, `DELETE FROM names WHERE 1 = 1`, "", id, ifVersion)
+	})
+}
+
+func (s *SQLNames) Restore(ctx context.Context, id primitive.ObjectID) (Name, error) {
+	var n Name
+	err := s.db.tx(ctx, func(tx *sql.Tx) error {
+		err := s.conditional(ctx, tx, `UPDATE names SET deleted_at = NULL, version = version + 1 WHERE deleted_at IS NOT NULL`, "", id, AnyVersion)
+		if err != nil { return err }
+		n, err = scanName(tx.QueryRowContext(ctx, s.db.rebind(`SELECT `+nameColumns+` FROM names WHERE id = ?`), id.Hex()))
+		return err
+	})
+	return n, err
+}
+
+func (s *SQLNames) Events(ctx context.Context, id primitive.ObjectID) ([]NameEvent, error) {
+	rows, err := s.db.DB.QueryContext(ctx, s.db.rebind(`SELECT id, type, name, at FROM name_events WHERE tenant = ? AND name_id = ? ORDER BY at, id`),
+		tenant.FromContext(ctx), id.Hex())
+	if err != nil { return nil, err }
+	defer rows.Close()
+
+	out := []NameEvent{}
+	for rows.Next() {
+		var (
+			evID string
+			at   int64
+		)
+		e := NameEvent{NameID: id, Tenant: tenant.FromContext(ctx)}
+		if err := rows.Scan(&evID, &e.Type, &e.Name, &at); err != nil { return nil, err }
+		if e.ID, err = primitive.ObjectIDFromHex(evID); err != nil { return nil, err }
+		e.At = fromMillis(at)
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
+
+// Search narrows the candidates in SQL where it can and ranks in Go, with
+// the same text scoring as the memory store. Regex mode scans every live
+// name, as neither SQLite nor portable SQL has regular expressions.
+func (s *SQLNames) Search(ctx context.Context, opts SearchOptions) ([]SearchHit, error) {
+	query := `SELECT ` + nameColumns + ` FROM names WHERE tenant = ? AND deleted_at IS NULL`
+	args := []any{tenant.FromContext(ctx)}
+	var match func(Name) (float64, bool)
+	switch opts.Mode {
+	case SearchPrefix:
+		query += ` AND lower(substr(COALESCE(name_normalized, name), 1, ?)) = lower(?)`
+		args = append(args, utf8.RuneCountInString(opts.Query), opts.Query)
+		match = func(Name) (float64, bool) { return 0, true }
+	case SearchRegex:
+		re, err := regexp.Compile(opts.Query)
+		if err != nil { return nil, err }
+		match = func(n Name) (float64, bool) { return 0, re.MatchString(n.Name) }
+	default:
+		terms := words(opts.Query)
+		if len(terms) == 0 { return []SearchHit{}, nil }
+		var likes []string
+		for _, t := range terms {
+			likes = append(likes, "lower(name) LIKE ? OR lower(tags) LIKE ?")
+			pattern := "%" + t + "%"
+			args = append(args, pattern, pattern)
+		}
+		query += ` AND (` + strings.Join(likes, " OR ") + `)`
+		match = func(n Name) (float64, bool) { score := textScore(terms, n); return score, score > 0 }
+	}
+
+	rows, err := s.db.DB.QueryContext(ctx, s.db.rebind(query), args...)
+	if err != nil { return nil, err }
+	defer rows.Close()
+	out := []SearchHit{}
+	for rows.Next() {
+		n, err := scanName(rows)
+		if err != nil { return nil, err }
+		if score, ok := match(n); ok { out = append(out, SearchHit{Name: n, Score: score}) }
+	}
+	if err := rows.Err(); err != nil { return nil, err }
+	return rankHits(out, opts.Limit), nil
+}
+
+// CreateMany inserts the items one by one so they fail independently.
+func (s *SQLNames) CreateMany(ctx context.Context, ns []Name) ([]error, error) {
+	return s.insertEach(ctx, ns, true)
+}
+
+func (s *SQLNames) InsertMany(ctx context.Context, ns []Name) ([]error, error) {
+	return s.insertEach(ctx, ns, false)
+}
+
+func (s *SQLNames) insertEach(ctx context.Context, ns []Name, withEvents bool) ([]error, error) {
+	errs := make([]error, len(ns))
+	now := time.Now().UTC()
+	for i := range ns {
+		err := s.db.tx(ctx, func(tx *sql.Tx) error { return s.insertName(ctx, tx, &ns[i], now, withEvents) })
+		if err != nil && !errors.Is(err, ErrDuplicate) { return nil, err }
+		errs[i] = err
+	}
+	return errs, nil
+}
+
+func (s *SQLNames) SetNormalized(ctx context.Context, forms map[primitive.ObjectID]string) error {
+	tid := tenant.FromContext(ctx)
+	return s.db.tx(ctx, func(tx *sql.Tx) error {
+		for id, form := range forms {
+			if _, err := tx.ExecContext(ctx, s.db.rebind(`UPDATE names SET name_normalized = ? WHERE tenant = ? AND id = ?`), form, tid, id.Hex()); err != nil { return err }
+		}
+		return nil
+	})
+}
+
+func (s *SQLNames) DeleteMany(ctx context.Context, ids []primitive.ObjectID, hard bool) (map[primitive.ObjectID]bool, error) {
+	existed := map[primitive.ObjectID]bool{}
+	now, tid := toMillis(time.Now().UTC()), tenant.FromContext(ctx)
+	err := s.db.tx(ctx, func(tx *sql.Tx) error {
+		for _, id := range ids {
+			if existed[id] { continue }
+			var res sql.Result
+			var err error
+			if hard {
+				res, err = tx.ExecContext(ctx, s.db.rebind(`DELETE FROM names WHERE tenant = ? AND id = ?`), tid, id.Hex())
+			} else {
+				res, err = tx.ExecContext(ctx, s.db.rebind(`UPDATE names SET deleted_at = ?, version = version + 1 WHERE tenant = ? AND id = ? AND deleted_at IS NULL`), now, tid, id.Hex())
+			}
+			if err != nil { return err }
+			if n, err := res.RowsAffected(); err != nil { return err } else if n > 0 { existed[id] = true }
+		}
+		return nil
+	})
+	return existed, err
+}
+
+func (s *SQLNames) ExistingNames(ctx context.Context, names []string) (map[string]bool, error) {
+	out := map[string]bool{}
+	if len(names) == 0 { return out, nil }
+	args := []any{tenant.FromContext(ctx)}
+	for _, n := range names { args = append(args, n) }
+	rows, err := s.db.DB.QueryContext(ctx, s.db.rebind(`SELECT name FROM names WHERE tenant = ? AND name IN (`+placeholders(len(names))+`)`), args...)
+	if err != nil { return nil, err }
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil { return nil, err }
+		out[name] = true
+	}
+	return out, rows.Err()
+}
+
+func (s *SQLNames) Watch(ctx context.Context, after string) (ChangeStream, error) {
+	return nil, ErrWatchUnsupported
+}
+
+func (s *SQLNames) DueNames(ctx context.Context, q DueQuery) ([]Name, error) {
+	due, args := "expires_at <= ?", []any{q.After.Hex(), toMillis(q.ExpiredBy)}
+	if !q.DeletedBefore.IsZero() { due, args = due+" OR deleted_at < ?", append(args, toMillis(q.DeletedBefore)) }
+	rows, err := s.db.DB.QueryContext(ctx, s.db.rebind(`SELECT `+nameColumns+` FROM names WHERE id > ? AND (`+due+`) ORDER BY id LIMIT ?`), append(args, q.Limit)...)
+	if err != nil { return nil, err }
+	defer rows.Close()
+	var out []Name
+	for rows.Next() {
+		n, err := scanName(rows)
+		if err != nil { return nil, err }
+		out = append(out, n)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,108 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"app/internal/tenant"
+)
+
+// SQLNotes is the NoteStore on SQLite or Postgres.
+type SQLNotes struct {
+	db *SQL
+}
+
+func NewSQLNotes(db *SQL) *SQLNotes { return &SQLNotes{db: db} }
+
+const noteColumns = "id, tenant, name_id, body, author, created_at"
+
+func (s *SQLNotes) CreateNote(ctx context.Context, n *Note) error {
+	n.ID, n.Tenant, n.CreatedAt = primitive.NewObjectID(), tenant.FromContext(ctx), time.Now().UTC().Truncate(time.Millisecond)
+	_, err := s.db.DB.ExecContext(ctx, s.db.rebind(`INSERT INTO notes (`+noteColumns+`) VALUES (?, ?, ?, ?, ?, ?)`),
+		n.ID.Hex(), n.Tenant, n.NameID.Hex(), n.Body, n.Author, toMillis(n.CreatedAt))
+	return err
+}
+
+func (s *SQLNotes) Notes(ctx context.Context, nameID primitive.ObjectID) ([]Note, error) {
+	rows, err := s.db.DB.QueryContext(ctx, s.db.rebind(`SELECT `+noteColumns+` FROM notes WHERE tenant = ? AND name_id = ? ORDER BY id`), tenant.FromContext(ctx), nameID.Hex())
+	if err != nil { return nil, err }
+	defer rows.Close()
+	notes := []Note{}
+	for rows.Next() {
+		var (
+			n        Note
+			id, name string
+			created  int64
+		)
+		if err := rows.Scan(&id, &n.Tenant, &name, &n.Body, &n.Author, &created); err != nil { return nil, err }
+		if n.ID, err = primitive.ObjectIDFromHex(id); err != nil { return nil, err }
+		if n.NameID, err = primitive.ObjectIDFromHex(name); err != nil { return nil, err }
+		n.CreatedAt = fromMillis(created)
+		notes = append(notes, n)
+	}
+	return notes, rows.Err()
+}
+
+func (s *SQLNotes) HasNotes(ctx context.Context, nameIDs ...primitive.ObjectID) (bool, error) {
+	if len(nameIDs) == 0 { return false, nil }
+	var one int
+	err := s.db.DB.QueryRowContext(ctx, s.db.rebind(`SELECT 1 FROM notes WHERE tenant = ? AND name_id IN (`+placeholders(len(nameIDs))+`) LIMIT 1`), idArgs(ctx, nameIDs)...).Scan(&one)
+	if errors.Is(err, sql.ErrNoRows) { return false, nil }
+	return err == nil, err
+}
+
+func (s *SQLNotes) DeleteNotes(ctx context.Context, nameIDs ...primitive.ObjectID) error {
+	if len(nameIDs) == 0 { return nil }
+	_, err := s.db.DB.ExecContext(ctx, s.db.rebind(`DELETE FROM notes WHERE tenant = ? AND name_id IN (`+placeholders(len(nameIDs))+`)`), idArgs(ctx, nameIDs)...)
+	return err
+}
+
+// idArgs are the arguments of a query on the tenant in ctx and ids.
+func idArgs(ctx context.Context, ids []primitive.ObjectID) []any {
+	args := []any{tenant.FromContext(ctx)}
+	for _, id := range ids { args = append(args, id.Hex()) }
+	return args
+}
+
+// NameWithNotes reads the name and its notes in one query: a row per note,
+// or a single one with NULL note columns if it has none.
+func (s *SQLNotes) NameWithNotes(ctx context.Context, id primitive.ObjectID) (NameWithNotes, error) {
+	rows, err := s.db.DB.QueryContext(ctx, s.db.rebind(`SELECT n.id, n.tenant, n.name, n.tags, n.metadata, n.created_at, n.updated_at, n.deleted_at, n.version, n.expires_at, n.uuid, n.slug, n.created_by, n.name_normalized,
+			o.id, o.body, o.author, o.created_at
+		FROM names n LEFT JOIN notes o ON o.tenant = n.tenant AND o.name_id = n.id
+		WHERE n.tenant = ? AND n.id = ? AND n.deleted_at IS NULL
+		ORDER BY o.id`), tenant.FromContext(ctx), id.Hex())
+	if err != nil { return NameWithNotes{}, err }
+	defer rows.Close()
+	out := NameWithNotes{Notes: []Note{}}
+	found := false
+	for rows.Next() {
+		var (
+			noteID, body, author sql.NullString
+			created              sql.NullInt64
+		)
+		n, err := scanName(joined{rows, []any{&noteID, &body, &author, &created}})
+		if err != nil { return out, err }
+		out.Name, found = n, true
+		if !noteID.Valid { continue }
+		note := Note{NameID: n.ID, Tenant: n.Tenant, Body: body.String, Author: author.String, CreatedAt: fromMillis(created.Int64)}
+		if note.ID, err = primitive.ObjectIDFromHex(noteID.String); err != nil { return out, err }
+		out.Notes = append(out.Notes, note)
+	}
+	if err := rows.Err(); err != nil { return out, err }
+	if !found { return out, ErrNotFound }
+	return out, nil
+}
+
+// joined lets scanName read the leading columns of a row that carries
+// more, scanned into extra.
+type joined struct {
+	row   scanner
+	extra []any
+}
+
+func (j joined) Scan(dest ...any) error { return j.row.Scan(append(dest, j.extra...)...) }
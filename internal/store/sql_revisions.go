@@ -0,0 +1,36 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"app/internal/tenant"
+)
+
+// SQLRevisions is the RevisionStore on SQLite or Postgres.
+type SQLRevisions struct {
+	db *SQL
+}
+
+func NewSQLRevisions(db *SQL) *SQLRevisions { return &SQLRevisions{db: db} }
+
+func (s *SQLRevisions) Bump(ctx context.Context) error {
+	now := toMillis(time.Now().UTC())
+	_, err := s.db.DB.ExecContext(ctx, s.db.rebind(`INSERT INTO name_revisions (tenant, n, at) VALUES (?, 1, ?)
+		ON CONFLICT (tenant) DO UPDATE SET n = name_revisions.n + 1, at = ?`), tenant.FromContext(ctx), now, now)
+	return err
+}
+
+func (s *SQLRevisions) Revision(ctx context.Context) (Revision, error) {
+	var (
+		rev Revision
+		at  int64
+	)
+	err := s.db.DB.QueryRowContext(ctx, s.db.rebind(`SELECT n, at FROM name_revisions WHERE tenant = ?`), tenant.FromContext(ctx)).Scan(&rev.N, &at)
+	if errors.Is(err, sql.ErrNoRows) { return Revision{}, nil }
+	if err != nil { return Revision{}, err }
+	rev.At = fromMillis(at)
+	return rev, nil
+}
@@ -0,0 +1,17 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"app/internal/tenant"
+)
+
+// RandomName orders the tenant's live names by RANDOM(), which SQLite and
+// PostgreSQL both have; fine for the few thousand names of a demo.
+func (s *SQLNames) RandomName(ctx context.Context) (Name, error) {
+	n, err := scanName(s.db.DB.QueryRowContext(ctx, s.db.rebind(`SELECT `+nameColumns+` FROM names WHERE tenant = ? AND deleted_at IS NULL ORDER BY RANDOM() LIMIT 1`), tenant.FromContext(ctx)))
+	if errors.Is(err, sql.ErrNoRows) { return n, ErrNotFound }
+	return n, err
+}
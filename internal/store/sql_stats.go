@@ -0,0 +1,41 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+	"time"
+
+	"app/internal/tenant"
+)
+
+const dayMillis = int64(24 * time.Hour / time.Millisecond)
+
+// NameStats leaves Storage out: neither database reports it per table in a
+// way worth the dialect-specific queries.
+func (s *SQLNames) NameStats(ctx context.Context, since time.Time) (NameStats, error) {
+	tid, st := tenant.FromContext(ctx), NameStats{CreatedPerDay: []DayCount{}}
+	err := s.db.DB.QueryRowContext(ctx, s.db.rebind(`SELECT COUNT(*), COALESCE(SUM(CASE WHEN deleted_at IS NULL THEN 0 ELSE 1 END), 0) FROM names WHERE tenant = ?`), tid).
+		Scan(&st.Total, &st.Deleted)
+	if err != nil { return st, err }
+
+	rows, err := s.db.DB.QueryContext(ctx, s.db.rebind(`SELECT created_at / `+strconv.FormatInt(dayMillis, 10)+`, COUNT(*) FROM names WHERE tenant = ? AND created_at >= ? GROUP BY 1 ORDER BY 1`),
+		tid, toMillis(since))
+	if err != nil { return st, err }
+	defer rows.Close()
+	for rows.Next() {
+		var day, count int64
+		if err := rows.Scan(&day, &count); err != nil { return st, err }
+		st.CreatedPerDay = append(st.CreatedPerDay, DayCount{Day: fromMillis(day * dayMillis).Format(time.DateOnly), Count: count})
+	}
+	if err := rows.Err(); err != nil { return st, err }
+
+	for dir, dst := range map[string]**Name{"DESC": &st.Longest, "ASC": &st.Shortest} {
+		n, err := scanName(s.db.DB.QueryRowContext(ctx, s.db.rebind(`SELECT `+nameColumns+` FROM names WHERE tenant = ? AND deleted_at IS NULL ORDER BY length(name) `+dir+`, id LIMIT 1`), tid))
+		if errors.Is(err, sql.ErrNoRows) { break }
+		if err != nil { return st, err }
+		*dst = &n
+	}
+	return st, nil
+}
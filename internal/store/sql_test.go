@@ -0,0 +1,142 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func openTestSQL(t *testing.T) *SQL {
+	t.Helper()
+	db, err := OpenSQL(context.Background(), "sqlite:"+filepath.Join(t.TempDir(), "test.db"))
+	if err != nil { t.Fatal(err) }
+	t.Cleanup(func() { db.Close(context.Background()) })
+	return db
+}
+
+func TestSQLNames(t *testing.T) {
+	ctx := context.Background()
+	s := NewSQLNames(openTestSQL(t))
+	for _, name := range []string{"carol", "alice", "bob", "dave"} {
+		if err := s.Create(ctx, &Name{Name: name, Tags: []string{"team " + name}}); err != nil { t.Fatal(err) }
+	}
+	if err := s.Create(ctx, &Name{Name: "bob"}); !errors.Is(err, ErrDuplicate) { t.Fatalf("duplicate: %v", err) }
+
+	opts := ListOptions{Limit: 3, SortBy: "name", Desc: true}
+	page, err := s.List(ctx, opts)
+	if err != nil || page.Total != 4 || names(page.Items) != "dave carol bob" { t.Fatalf("first page: %q of %d, %v", names(page.Items), page.Total, err) }
+	if opts.After, err = DecodeCursor(page.Next); err != nil { t.Fatal(err) }
+	if page, _ = s.List(ctx, opts); names(page.Items) != "alice" || page.Next != "" { t.Fatalf("second page: %q, next %q", names(page.Items), page.Next) }
+
+	alice := page.Items[0]
+	if alice.Tags[0] != "team alice" || alice.Version != 1 { t.Fatalf("stored %+v", alice) }
+	if _, err := s.Patch(ctx, alice.ID, NamePatch{Name: &[]string{"bob"}[0]}, AnyVersion); !errors.Is(err, ErrDuplicate) { t.Fatalf("rename to taken: %v", err) }
+	if _, err := s.Update(ctx, alice.ID, Name{Name: "alicia"}, 2); !errors.Is(err, ErrVersionMismatch) { t.Fatalf("stale update: %v", err) }
+	n, err := s.Update(ctx, alice.ID, Name{Name: "alicia"}, 1)
+	if err != nil || n.Version != 2 || n.Tags != nil { t.Fatalf("update: %+v, %v", n, err) }
+
+	if err := s.SoftDelete(ctx, n.ID, 1); !errors.Is(err, ErrVersionMismatch) { t.Fatalf("stale delete: %v", err) }
+	if err := s.SoftDelete(ctx, n.ID, 2); err != nil { t.Fatal(err) }
+	if err := s.SoftDelete(ctx, n.ID, AnyVersion); !errors.Is(err, ErrNotFound) { t.Fatalf("delete twice: %v", err) }
+	if trash, _ := s.List(ctx, ListOptions{Limit: 10, OnlyDeleted: true}); names(trash.Items) != "alicia" { t.Fatalf("trash %q", names(trash.Items)) }
+	if n, err = s.Restore(ctx, n.ID); err != nil || n.Version != 4 || n.DeletedAt != nil { t.Fatalf("restore: %+v, %v", n, err) }
+
+	hits, err := s.Search(ctx, SearchOptions{Query: "Carol"})
+	if err != nil || len(hits) != 1 || hits[0].Name.Name != "carol" || hits[0].Score != 11 { t.Fatalf("text search: %+v, %v", hits, err) }
+	if hits, _ = s.Search(ctx, SearchOptions{Query: "ALI", Mode: SearchPrefix}); len(hits) != 1 { t.Fatalf("prefix search: %+v", hits) }
+
+	if err := s.HardDelete(ctx, n.ID, AnyVersion); err != nil { t.Fatal(err) }
+	if _, err := s.Get(ctx, n.ID); !errors.Is(err, ErrNotFound) { t.Fatalf("get removed: %v", err) }
+	if events, _ := s.Events(ctx, n.ID); len(events) != 1 || events[0].Type != "created" { t.Fatalf("events %+v", events) }
+	if _, err := s.Watch(ctx, ""); !errors.Is(err, ErrWatchUnsupported) { t.Fatalf("watch: %v", err) }
+}
+
+func TestSQLNamesTenants(t *testing.T) { testTenants(t, NewSQLNames(openTestSQL(t))) }
+
+func TestSQLNamesTagFilter(t *testing.T) { testTagFilter(t, NewSQLNames(openTestSQL(t))) }
+
+func TestSQLNamesUpsert(t *testing.T) { testUpsert(t, NewSQLNames(openTestSQL(t))) }
+
+func TestSQLNamesDue(t *testing.T) { testDueNames(t, NewSQLNames(openTestSQL(t))) }
+
+func TestSQLNamesNormalized(t *testing.T) { testNormalized(t, NewSQLNames(openTestSQL(t))) }
+
+func TestSQLNamesPatchKeys(t *testing.T) { testPatchKeys(t, NewSQLNames(openTestSQL(t))) }
+
+func TestSQLAudit(t *testing.T) { testAudit(t, NewSQLAudit(openTestSQL(t))) }
+
+func TestSQLHistory(t *testing.T) { testHistory(t, NewSQLHistory(openTestSQL(t))) }
+
+func TestSQLDocs(t *testing.T) { testDocs(t, NewSQLDocs(openTestSQL(t))) }
+
+func TestSQLNotes(t *testing.T) {
+	db := openTestSQL(t)
+	testNotes(t, NewSQLNames(db), NewSQLNotes(db))
+}
+
+func TestSQLJobs(t *testing.T) { testJobs(t, NewSQLJobs(openTestSQL(t))) }
+
+func TestSQLWebhooks(t *testing.T) { testWebhooks(t, NewSQLWebhooks(openTestSQL(t))) }
+
+func TestSQLNameStats(t *testing.T) { testNameStats(t, NewSQLNames(openTestSQL(t))) }
+
+func TestSQLDuplicates(t *testing.T) { testDuplicates(t, NewSQLNames(openTestSQL(t))) }
+
+func TestSQLRandomName(t *testing.T) { testRandomName(t, NewSQLNames(openTestSQL(t))) }
+
+func TestSQLNameKeys(t *testing.T) { testNameKeys(t, NewSQLNames(openTestSQL(t))) }
+
+func TestSQLRevisions(t *testing.T) { testRevisions(t, NewSQLRevisions(openTestSQL(t))) }
+
+func TestSQLFoundTenant(t *testing.T) { testFoundTenant(t, NewSQLUsers(openTestSQL(t))) }
+
+func TestSQLRoles(t *testing.T) {
+	db := openTestSQL(t)
+	testRoles(t, NewSQLUsers(db), NewSQLAPIKeys(db))
+}
+
+func TestSQLNamesBulk(t *testing.T) {
+	ctx := context.Background()
+	s := NewSQLNames(openTestSQL(t))
+	errs, err := s.CreateMany(ctx, []Name{{Name: "a"}, {Name: "a"}, {Name: "b"}})
+	if err != nil || errs[0] != nil || !errors.Is(errs[1], ErrDuplicate) || errs[2] != nil { t.Fatalf("create many: %v, %v", errs, err) }
+	errs, err = s.InsertMany(ctx, []Name{{Name: "c"}, {Name: "b"}})
+	if err != nil || errs[0] != nil || !errors.Is(errs[1], ErrDuplicate) { t.Fatalf("insert many: %v, %v", errs, err) }
+
+	taken, err := s.ExistingNames(ctx, []string{"a", "c", "z"})
+	if err != nil || len(taken) != 2 || !taken["a"] || !taken["c"] { t.Fatalf("existing: %v, %v", taken, err) }
+
+	var all []Name
+	_ = s.Each(ctx, ListOptions{}, func(n Name) error { all = append(all, n); return nil })
+	existed, err := s.DeleteMany(ctx, []primitive.ObjectID{all[0].ID, all[0].ID, primitive.NewObjectID()}, false)
+	if err != nil || len(existed) != 1 || !existed[all[0].ID] { t.Fatalf("delete many: %v, %v", existed, err) }
+	if page, _ := s.List(ctx, ListOptions{Limit: 10}); page.Total != 2 { t.Fatalf("left %q", names(page.Items)) }
+}
+
+func TestSQLIdempotency(t *testing.T) {
+	ctx := context.Background()
+	s := NewSQLIdempotency(openTestSQL(t))
+	if rec, err := s.Claim(ctx, "k", "h", time.Minute); rec != nil || err != nil { t.Fatalf("first claim: %+v, %v", rec, err) }
+	if err := s.Complete(ctx, "k", 201, map[string]string{"ETag": `"1"`}, []byte("{}")); err != nil { t.Fatal(err) }
+	rec, err := s.Claim(ctx, "k", "h", time.Minute)
+	if err != nil || rec == nil || rec.Status != 201 || rec.Headers["ETag"] != `"1"` || string(rec.Body) != "{}" { t.Fatalf("second claim: %+v, %v", rec, err) }
+
+	// An expired key can be claimed again.
+	if _, err := s.Claim(ctx, "old", "h", -time.Second); err != nil { t.Fatal(err) }
+	if rec, err := s.Claim(ctx, "old", "h2", time.Minute); rec != nil || err != nil { t.Fatalf("reclaim: %+v, %v", rec, err) }
+}
+
+func TestSQLMigrateTwice(t *testing.T) {
+	path := "sqlite:" + filepath.Join(t.TempDir(), "test.db")
+	for range 2 {
+		db, err := OpenSQL(context.Background(), path)
+		if err != nil { t.Fatal(err) }
+		db.Close(context.Background())
+	}
+	if _, err := OpenSQL(context.Background(), "mysql://x"); err == nil || !strings.Contains(err.Error(), "sqlite:") { t.Fatalf("unknown scheme: %v", err) }
+}
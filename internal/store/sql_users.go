@@ -0,0 +1,88 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"app/internal/tenant"
+)
+
+// SQLUsers is the UserStore on SQLite or Postgres.
+type SQLUsers struct {
+	db *SQL
+}
+
+func NewSQLUsers(db *SQL) *SQLUsers { return &SQLUsers{db: db} }
+
+const userColumns = "id, username, tenant, role, password_hash, created_at"
+
+func (s *SQLUsers) CreateUser(ctx context.Context, u User) error {
+	if u.ID.IsZero() { u.ID = primitive.NewObjectID() }
+	if u.Tenant == "" { u.Tenant = tenant.Default }
+	_, err := s.db.DB.ExecContext(ctx, s.db.rebind(`INSERT INTO users (`+userColumns+`) VALUES (?, ?, ?, ?, ?, ?)`),
+		u.ID.Hex(), u.Username, u.Tenant, u.Role, u.PasswordHash, toMillis(u.CreatedAt))
+	if isUniqueViolation(err) { return ErrDuplicate }
+	return err
+}
+
+func (s *SQLUsers) UserByUsername(ctx context.Context, username string) (User, error) {
+	u, err := scanUser(s.db.DB.QueryRowContext(ctx, s.db.rebind(`SELECT `+userColumns+` FROM users WHERE username = ?`), username))
+	if errors.Is(err, sql.ErrNoRows) { return u, ErrNotFound }
+	return u, err
+}
+
+// FoundTenant claims the tenant in tenant_founders, whose primary key lets
+// one claim through, and creates u in the same transaction.
+func (s *SQLUsers) FoundTenant(ctx context.Context, u User) error {
+	if u.ID.IsZero() { u.ID = primitive.NewObjectID() }
+	if u.Tenant == "" { u.Tenant = tenant.Default }
+	return s.db.tx(ctx, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, s.db.rebind(`INSERT INTO tenant_founders (tenant, user_id) VALUES (?, ?)`), u.Tenant, u.ID.Hex())
+		if isUniqueViolation(err) { return ErrTenantExists }
+		if err != nil { return err }
+		// Users who joined while founding wasn't claimed (with auth off) count too.
+		var one int
+		err = tx.QueryRowContext(ctx, s.db.rebind(`SELECT 1 FROM users WHERE tenant = ? LIMIT 1`), u.Tenant).Scan(&one)
+		if err == nil { return ErrTenantExists }
+		if !errors.Is(err, sql.ErrNoRows) { return err }
+		_, err = tx.ExecContext(ctx, s.db.rebind(`INSERT INTO users (`+userColumns+`) VALUES (?, ?, ?, ?, ?, ?)`),
+			u.ID.Hex(), u.Username, u.Tenant, u.Role, u.PasswordHash, toMillis(u.CreatedAt))
+		if isUniqueViolation(err) { return ErrDuplicate }
+		return err
+	})
+}
+
+func (s *SQLUsers) Users(ctx context.Context, tenant string) ([]User, error) {
+	rows, err := s.db.DB.QueryContext(ctx, s.db.rebind(`SELECT `+userColumns+` FROM users WHERE tenant = ? ORDER BY username`), tenant)
+	if err != nil { return nil, err }
+	defer rows.Close()
+	users := []User{}
+	for rows.Next() {
+		u, err := scanUser(rows)
+		if err != nil { return nil, err }
+		users = append(users, u)
+	}
+	return users, rows.Err()
+}
+
+func (s *SQLUsers) SetUserRole(ctx context.Context, tenant string, id primitive.ObjectID, role string) (User, error) {
+	u, err := scanUser(s.db.DB.QueryRowContext(ctx, s.db.rebind(`UPDATE users SET role = ? WHERE id = ? AND tenant = ? RETURNING `+userColumns), role, id.Hex(), tenant))
+	if errors.Is(err, sql.ErrNoRows) { return u, ErrNotFound }
+	return u, err
+}
+
+func scanUser(row scanner) (User, error) {
+	var (
+		u       User
+		id      string
+		created int64
+	)
+	if err := row.Scan(&id, &u.Username, &u.Tenant, &u.Role, &u.PasswordHash, &created); err != nil { return u, err }
+	u.CreatedAt = fromMillis(created)
+	var err error
+	u.ID, err = primitive.ObjectIDFromHex(id)
+	return u, err
+}
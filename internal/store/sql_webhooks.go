@@ -0,0 +1,155 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"app/internal/tenant"
+)
+
+// SQLWebhooks is the WebhookStore on SQLite or Postgres.
+type SQLWebhooks struct {
+	db *SQL
+}
+
+func NewSQLWebhooks(db *SQL) *SQLWebhooks { return &SQLWebhooks{db: db} }
+
+const (
+	webhookColumns  = "id, tenant, url, events, secret, created_at"
+	deliveryColumns = "id, tenant, webhook_id, event, payload, status, attempts, claims, next_attempt_at, created_at, finished_at"
+)
+
+func (s *SQLWebhooks) CreateWebhook(ctx context.Context, w *Webhook) error {
+	w.ID, w.Tenant, w.CreatedAt = primitive.NewObjectID(), tenant.FromContext(ctx), time.Now().UTC().Truncate(time.Millisecond)
+	events, err := json.Marshal(w.Events)
+	if err != nil { return err }
+	_, err = s.db.DB.ExecContext(ctx, s.db.rebind(`INSERT INTO webhooks (`+webhookColumns+`) VALUES (?, ?, ?, ?, ?, ?)`),
+		w.ID.Hex(), w.Tenant, w.URL, string(events), w.Secret, toMillis(w.CreatedAt))
+	return err
+}
+
+func (s *SQLWebhooks) Webhook(ctx context.Context, id primitive.ObjectID) (Webhook, error) {
+	w, err := scanWebhook(s.db.DB.QueryRowContext(ctx, s.db.rebind(`SELECT `+webhookColumns+` FROM webhooks WHERE id = ? AND tenant = ?`), id.Hex(), tenant.FromContext(ctx)))
+	if errors.Is(err, sql.ErrNoRows) { return w, ErrNotFound }
+	return w, err
+}
+
+func (s *SQLWebhooks) Webhooks(ctx context.Context) ([]Webhook, error) {
+	rows, err := s.db.DB.QueryContext(ctx, s.db.rebind(`SELECT `+webhookColumns+` FROM webhooks WHERE tenant = ? ORDER BY id`), tenant.FromContext(ctx))
+	if err != nil { return nil, err }
+	defer rows.Close()
+	out := []Webhook{}
+	for rows.Next() {
+		w, err := scanWebhook(rows)
+		if err != nil { return nil, err }
+		out = append(out, w)
+	}
+	return out, rows.Err()
+}
+
+func (s *SQLWebhooks) DeleteWebhook(ctx context.Context, id primitive.ObjectID) error {
+	return s.db.tx(ctx, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx, s.db.rebind(`DELETE FROM webhooks WHERE id = ? AND tenant = ?`), id.Hex(), tenant.FromContext(ctx))
+		if err != nil { return err }
+		if n, err := res.RowsAffected(); err != nil || n == 0 { return errors.Join(ErrNotFound, err) }
+		_, err = tx.ExecContext(ctx, s.db.rebind(`DELETE FROM webhook_deliveries WHERE webhook_id = ?`), id.Hex())
+		return err
+	})
+}
+
+func (s *SQLWebhooks) EnqueueDeliveries(ctx context.Context, ds []Delivery) error {
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	return s.db.tx(ctx, func(tx *sql.Tx) error {
+		for i := range ds {
+			d := &ds[i]
+			d.ID, d.Tenant, d.Status, d.NextAttemptAt, d.CreatedAt = primitive.NewObjectID(), tenant.FromContext(ctx), DeliveryPending, &now, now
+			_, err := tx.ExecContext(ctx, s.db.rebind(`INSERT INTO webhook_deliveries (id, tenant, webhook_id, event, payload, status, attempts, next_attempt_at, created_at) VALUES (?, ?, ?, ?, ?, ?, '[]', ?, ?)`),
+				d.ID.Hex(), d.Tenant, d.WebhookID.Hex(), d.Event, string(d.Payload), d.Status, toMillis(now), toMillis(now))
+			if err != nil { return err }
+		}
+		return nil
+	})
+}
+
+func (s *SQLWebhooks) Deliveries(ctx context.Context, webhookID primitive.ObjectID, limit int) ([]Delivery, error) {
+	rows, err := s.db.DB.QueryContext(ctx, s.db.rebind(`SELECT `+deliveryColumns+` FROM webhook_deliveries WHERE webhook_id = ? AND tenant = ? ORDER BY id DESC LIMIT ?`),
+		webhookID.Hex(), tenant.FromContext(ctx), limit)
+	if err != nil { return nil, err }
+	defer rows.Close()
+	out := []Delivery{}
+	for rows.Next() {
+		d, err := scanDelivery(rows)
+		if err != nil { return nil, err }
+		out = append(out, d)
+	}
+	return out, rows.Err()
+}
+
+// ClaimDelivery picks and takes the delivery in one UPDATE, repeating the
+// filter on the row taken as ClaimJob does.
+func (s *SQLWebhooks) ClaimDelivery(ctx context.Context, lease time.Duration) (Delivery, error) {
+	now := toMillis(time.Now().UTC())
+	const due = `status = 'pending' AND next_attempt_at <= ?`
+	row := s.db.DB.QueryRowContext(ctx, s.db.rebind(`UPDATE webhook_deliveries SET next_attempt_at = ?, claims = claims + 1
+		WHERE id = (SELECT id FROM webhook_deliveries WHERE `+due+` ORDER BY next_attempt_at, id LIMIT 1) AND `+due+`
+		RETURNING `+deliveryColumns), now+lease.Milliseconds(), now, now)
+	d, err := scanDelivery(row)
+	if errors.Is(err, sql.ErrNoRows) { return d, ErrNotFound }
+	return d, err
+}
+
+func (s *SQLWebhooks) FinishDelivery(ctx context.Context, d Delivery) error {
+	attempts, err := json.Marshal(d.Attempts)
+	if err != nil { return err }
+	next, finished := nullMillis(d.NextAttemptAt), sql.NullInt64{}
+	if d.Status != DeliveryPending { next, finished = sql.NullInt64{}, sql.NullInt64{Int64: toMillis(time.Now().UTC()), Valid: true} }
+	res, err := s.db.DB.ExecContext(ctx, s.db.rebind(`UPDATE webhook_deliveries SET status = ?, attempts = ?, next_attempt_at = ?, finished_at = ? WHERE id = ? AND claims = ? AND status = 'pending'`),
+		d.Status, string(attempts), next, finished, d.ID.Hex(), d.Claims)
+	if err != nil { return err }
+	if n, err := res.RowsAffected(); err != nil || n > 0 { return err }
+	var exists int
+	err = s.db.DB.QueryRowContext(ctx, s.db.rebind(`SELECT 1 FROM webhook_deliveries WHERE id = ?`), d.ID.Hex()).Scan(&exists)
+	if errors.Is(err, sql.ErrNoRows) { return ErrNotFound }
+	if err != nil { return err }
+	return ErrVersionMismatch
+}
+
+func (s *SQLWebhooks) PurgeDeliveries(ctx context.Context, before time.Time) error {
+	_, err := s.db.DB.ExecContext(ctx, s.db.rebind(`DELETE FROM webhook_deliveries WHERE finished_at < ?`), toMillis(before))
+	return err
+}
+
+func scanWebhook(row scanner) (Webhook, error) {
+	var (
+		w          Webhook
+		id, events string
+		created    int64
+	)
+	if err := row.Scan(&id, &w.Tenant, &w.URL, &events, &w.Secret, &created); err != nil { return w, err }
+	var err error
+	if w.ID, err = primitive.ObjectIDFromHex(id); err != nil { return w, err }
+	w.CreatedAt = fromMillis(created)
+	return w, json.Unmarshal([]byte(events), &w.Events)
+}
+
+func scanDelivery(row scanner) (Delivery, error) {
+	var (
+		d                           Delivery
+		id, hook, payload, attempts string
+		created                     int64
+		next, finished              sql.NullInt64
+	)
+	if err := row.Scan(&id, &d.Tenant, &hook, &d.Event, &payload, &d.Status, &attempts, &d.Claims, &next, &created, &finished); err != nil { return d, err }
+	var err error
+	if d.ID, err = primitive.ObjectIDFromHex(id); err != nil { return d, err }
+	if d.WebhookID, err = primitive.ObjectIDFromHex(hook); err != nil { return d, err }
+	d.Payload, d.CreatedAt = json.RawMessage(payload), fromMillis(created)
+	if next.Valid { t := fromMillis(next.Int64); d.NextAttemptAt = &t }
+	if finished.Valid { t := fromMillis(finished.Int64); d.FinishedAt = &t }
+	return d, json.Unmarshal([]byte(attempts), &d.Attempts)
+}
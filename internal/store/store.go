@@ -0,0 +1,517 @@
+// Package store defines the persistence interfaces used by the HTTP layer
+// and their MongoDB implementations.
+package store
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"slices"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	ErrNotFound  = errors.New("not found")
+	ErrDuplicate = errors.New("duplicate")
+	// ErrVersionMismatch: a conditional write found the document at another
+	// version than the caller expected.
+	ErrVersionMismatch = errors.New("version mismatch")
+	// ErrHasNotes: a hard delete was refused because the name still has
+	// notes (see package notes).
+	ErrHasNotes = errors.New("the name has notes")
+	// ErrNotOwner: a write to a name was refused because someone else
+	// created it, and the caller isn't an admin.
+	ErrNotOwner = errors.New("not the owner of the name")
+	// ErrTenantExists: a user was to found a tenant that already has users.
+	ErrTenantExists = errors.New("tenant exists")
+
+	// ErrWatchUnsupported: the deployment can't stream changes (a standalone
+	// mongod has no oplog).
+	ErrWatchUnsupported = errors.New("change streams are not supported by this deployment")
+	// ErrResumeExpired: the resume token is malformed or has fallen off the
+	// oplog, so the changes since then can't be replayed.
+	ErrResumeExpired = errors.New("resume token is no longer valid")
+)
+
+// NameStore persists names and their audit events. Every method acts on the
+// tenant in ctx (see package tenant) alone: other tenants' names are as good
+// as missing. Reads and updates never see soft-deleted names unless stated
+// otherwise. Names are unique within a tenant, soft-deleted ones included:
+// writes that would duplicate one fail with ErrDuplicate.
+type NameStore interface {
+	// Create stores n (assigning an ID and timestamps) together with its
+	// "created" event.
+	Create(ctx context.Context, n *Name) error
+	// CreateIfAbsent is Create unless the tenant already has a name called
+	// n.Name, soft-deleted or not: then it stores nothing, fills n with
+	// that document and returns false. Two racing calls never both create.
+	CreateIfAbsent(ctx context.Context, n *Name) (bool, error)
+	Get(ctx context.Context, id primitive.ObjectID) (Name, error)
+	// Lookup returns those of ids that exist, soft-deleted ones included.
+	Lookup(ctx context.Context, ids []primitive.ObjectID) (map[primitive.ObjectID]Name, error)
+	List(ctx context.Context, opts ListOptions) (Page, error)
+	// Each calls fn for every name matching opts, in its sort order but
+	// ignoring its paging fields, without holding the whole result in memory.
+	// It stops at fn's first error.
+	Each(ctx context.Context, opts ListOptions, fn func(Name) error) error
+	// Update replaces name, tags and metadata; empty tags/metadata are removed.
+	// Like Patch, it returns the document as stored afterwards.
+	//
+	// The writes below only apply if the document is at version ifVersion,
+	// failing with ErrVersionMismatch otherwise; AnyVersion skips the check.
+	// Every change bumps the version.
+	Update(ctx context.Context, id primitive.ObjectID, n Name, ifVersion int64) (Name, error)
+	Patch(ctx context.Context, id primitive.ObjectID, p NamePatch, ifVersion int64) (Name, error)
+	// Upsert gives the name called n.Name the tags, metadata and expiry of
+	// n, creating n (with its "created" event) if there is none and
+	// ifVersion is AnyVersion. It returns the document before, nil if it
+	// was created, and after. A soft-deleted name of that name is
+	// ErrDuplicate; given a version, a missing one is ErrNotFound.
+	Upsert(ctx context.Context, n Name, ifVersion int64) (*Name, Name, error)
+	SoftDelete(ctx context.Context, id primitive.ObjectID, ifVersion int64) error
+	HardDelete(ctx context.Context, id primitive.ObjectID, ifVersion int64) error
+	// Restore undoes a soft delete; ErrNotFound if id isn't soft-deleted.
+	Restore(ctx context.Context, id primitive.ObjectID) (Name, error)
+	Events(ctx context.Context, id primitive.ObjectID) ([]NameEvent, error)
+	Search(ctx context.Context, opts SearchOptions) ([]SearchHit, error)
+
+	// CreateMany stores ns (assigning IDs) and their "created" events. It is
+	// not one round trip: Mongo inserts the names in one batch and the events
+	// in a second, best effort, and SQL writes each name with its event in a
+	// transaction of its own. Items fail independently: the returned slice
+	// holds each item's error (ErrDuplicate for a taken name), nil for those
+	// stored.
+	CreateMany(ctx context.Context, ns []Name) ([]error, error)
+	// DeleteMany soft-deletes (or, if hard, removes) the given names and
+	// reports which of them existed.
+	DeleteMany(ctx context.Context, ids []primitive.ObjectID, hard bool) (map[primitive.ObjectID]bool, error)
+
+	// ExistingNames reports which of names are already taken.
+	ExistingNames(ctx context.Context, names []string) (map[string]bool, error)
+	// InsertMany is CreateMany without the events, for bulk loads.
+	InsertMany(ctx context.Context, ns []Name) ([]error, error)
+
+	// Watch streams changes to names as they happen, starting after the
+	// change with resume token after, or from now if it is empty.
+	Watch(ctx context.Context, after string) (ChangeStream, error)
+}
+
+// ChangeStream yields NameChanges in order. Next blocks until there is one or
+// ctx ends.
+type ChangeStream interface {
+	Next(ctx context.Context) (NameChange, error)
+	Close(ctx context.Context) error
+}
+
+// AnyVersion makes a write unconditional.
+const AnyVersion int64 = -1
+
+// UserStore persists user accounts. Usernames are unique across tenants.
+type UserStore interface {
+	// CreateUser returns ErrDuplicate if the username is taken.
+	CreateUser(ctx context.Context, u User) error
+	UserByUsername(ctx context.Context, username string) (User, error)
+	// FoundTenant creates u as the first user of u.Tenant. It returns
+	// ErrTenantExists if the tenant has users already, checked and written
+	// as one step so that two users can't both found it, and ErrDuplicate if
+	// the username is taken.
+	FoundTenant(ctx context.Context, u User) error
+	// Users lists the users of tenant by username.
+	Users(ctx context.Context, tenant string) ([]User, error)
+	// SetUserRole returns ErrNotFound unless user id belongs to tenant.
+	SetUserRole(ctx context.Context, tenant string, id primitive.ObjectID, role string) (User, error)
+}
+
+// APIKeyStore persists API keys. Hashes are unique.
+type APIKeyStore interface {
+	CreateAPIKey(ctx context.Context, k APIKey) error
+	// APIKeyByHash returns ErrNotFound for unknown and revoked keys alike.
+	APIKeyByHash(ctx context.Context, hash string) (APIKey, error)
+	// RevokeAPIKey returns ErrNotFound unless userID owns the unrevoked key id.
+	RevokeAPIKey(ctx context.Context, id, userID primitive.ObjectID) error
+	// RevokeTenantAPIKey, for admins, returns ErrNotFound unless the
+	// unrevoked key id belongs to tenant, whoever owns it.
+	RevokeTenantAPIKey(ctx context.Context, tenant string, id primitive.ObjectID) error
+	// APIKeys lists the keys of tenant, revoked ones included, oldest first.
+	APIKeys(ctx context.Context, tenant string) ([]APIKey, error)
+	// SetAPIKeyRole returns ErrNotFound unless key id belongs to tenant.
+	SetAPIKeyRole(ctx context.Context, tenant string, id primitive.ObjectID, role string) (APIKey, error)
+}
+
+// AuditStore keeps the audit trail of writes. Like NameStore, it acts on
+// the tenant in ctx alone.
+type AuditStore interface {
+	// RecordAudit stores entries, stamped with the tenant of ctx.
+	RecordAudit(ctx context.Context, entries []AuditEntry) error
+	// ListAudit returns entries newest first.
+	ListAudit(ctx context.Context, q AuditQuery) (AuditPage, error)
+}
+
+// HistoryStore keeps the past versions of names, per tenant like
+// NameStore. A version, once kept, never changes.
+type HistoryStore interface {
+	// SaveVersions keeps each of ns as its Version; versions already kept
+	// are left alone.
+	SaveVersions(ctx context.Context, ns []Name) error
+	// Versions returns the kept versions of name id, newest first.
+	Versions(ctx context.Context, id primitive.ObjectID) ([]Name, error)
+}
+
+// NoteStore keeps the notes about names. Like NameStore, it acts on the
+// tenant in ctx alone. It doesn't check that the names exist: that is up to
+// the caller.
+type NoteStore interface {
+	// CreateNote stores n, assigning its ID, tenant and time.
+	CreateNote(ctx context.Context, n *Note) error
+	// Notes lists the notes about name nameID, oldest first.
+	Notes(ctx context.Context, nameID primitive.ObjectID) ([]Note, error)
+	// HasNotes reports whether any of nameIDs has notes.
+	HasNotes(ctx context.Context, nameIDs ...primitive.ObjectID) (bool, error)
+	// DeleteNotes removes every note about nameIDs.
+	DeleteNotes(ctx context.Context, nameIDs ...primitive.ObjectID) error
+	// NameWithNotes is NameStore.Get with the name's notes, joined by the
+	// database where there is one: a $lookup on MongoDB, a LEFT JOIN in SQL.
+	NameWithNotes(ctx context.Context, id primitive.ObjectID) (NameWithNotes, error)
+}
+
+// JobStore is the queue of background jobs. Clients see the jobs of the
+// tenant in ctx alone; workers claim those of every tenant.
+type JobStore interface {
+	// EnqueueJob stores j as queued, stamping its ID, tenant and time.
+	EnqueueJob(ctx context.Context, j *Job) error
+	// Job returns job id without its Input and Output.
+	Job(ctx context.Context, id primitive.ObjectID) (Job, error)
+	// JobOutput returns the Output of job id.
+	JobOutput(ctx context.Context, id primitive.ObjectID) ([]byte, error)
+	// ClaimJob hands out the oldest job that is queued, or running with an
+	// expired lease, as running under a lease for the next lease, one more
+	// attempt. ErrNotFound means there is none.
+	ClaimJob(ctx context.Context, lease time.Duration) (Job, error)
+	// RenewJob extends the lease of job j.
+	RenewJob(ctx context.Context, j Job, lease time.Duration) error
+	// FinishJob records j's Status, Result, Output and Error; JobQueued
+	// gives the job back to be claimed again. Like RenewJob, it fails with
+	// ErrVersionMismatch once the job has been claimed after j was.
+	FinishJob(ctx context.Context, j Job) error
+	// PurgeJobs removes the jobs that finished before before.
+	PurgeJobs(ctx context.Context, before time.Time) error
+}
+
+// WebhookStore keeps the webhooks of each tenant and the queue of
+// deliveries to them. Like JobStore, clients see their tenant's alone and
+// workers claim the deliveries of every tenant.
+type WebhookStore interface {
+	// CreateWebhook stamps w with an ID, the tenant and the time.
+	CreateWebhook(ctx context.Context, w *Webhook) error
+	Webhook(ctx context.Context, id primitive.ObjectID) (Webhook, error)
+	// Webhooks returns the tenant's webhooks, oldest first.
+	Webhooks(ctx context.Context) ([]Webhook, error)
+	// DeleteWebhook removes webhook id and its deliveries.
+	DeleteWebhook(ctx context.Context, id primitive.ObjectID) error
+	// EnqueueDeliveries stores ds as pending and due now, stamping their
+	// IDs, tenant and time.
+	EnqueueDeliveries(ctx context.Context, ds []Delivery) error
+	// Deliveries returns up to limit of webhook id's deliveries, newest
+	// first.
+	Deliveries(ctx context.Context, webhookID primitive.ObjectID, limit int) ([]Delivery, error)
+	// ClaimDelivery hands out the pending delivery that has been due the
+	// longest, pushing NextAttemptAt back by lease and counting the claim.
+	// ErrNotFound means none is due.
+	ClaimDelivery(ctx context.Context, lease time.Duration) (Delivery, error)
+	// FinishDelivery records d's Status, Attempts and NextAttemptAt. It
+	// fails with ErrVersionMismatch once d has been claimed again.
+	FinishDelivery(ctx context.Context, d Delivery) error
+	// PurgeDeliveries removes the deliveries that finished before before.
+	PurgeDeliveries(ctx context.Context, before time.Time) error
+}
+
+// OutboxStore is the transactional outbox of the writes to names. An event
+// is added in the transaction of the write it tells of, so that the two are
+// stored or lost together, and kept until a dispatcher has published it.
+// Like WebhookStore, dispatchers claim the events of every tenant.
+type OutboxStore interface {
+	// AddToOutbox stores es, stamping their IDs and tenant, to be claimed
+	// from their At on; inside a transaction, as part of it.
+	AddToOutbox(ctx context.Context, es []OutboxEvent) error
+	// ClaimOutbox hands out the oldest unpublished event that no dispatcher
+	// holds, holding it for lease. ErrNotFound means there is none.
+	ClaimOutbox(ctx context.Context, lease time.Duration) (OutboxEvent, error)
+	// MarkPublished records event id as published.
+	MarkPublished(ctx context.Context, id primitive.ObjectID) error
+	// PurgeOutbox removes the events published before before.
+	PurgeOutbox(ctx context.Context, before time.Time) error
+}
+
+// UsageStore counts what each API key uses, a day at a time. Counts are
+// added to, never set, so any number of servers can add theirs.
+type UsageStore interface {
+	// AddUsage adds the requests and bytes of each of us to its key's Day.
+	AddUsage(ctx context.Context, us []Usage) error
+	// Usage returns the days of key from since (YYYY-MM-DD) on, oldest
+	// first; the days it made no requests are missing.
+	Usage(ctx context.Context, keyID primitive.ObjectID, since string) ([]Usage, error)
+}
+
+// ChangeLogStore numbers each tenant's writes to names in the order they
+// commit, for clients to sync by asking for those after the last they saw
+// (see package changes). Like NameStore, it acts on the tenant in ctx
+// alone. Changes are forgotten once older than the store's retention.
+type ChangeLogStore interface {
+	// AppendChanges gives cs the tenant's next numbers and stores them;
+	// inside a transaction, as part of it.
+	AppendChanges(ctx context.Context, cs []Change) error
+	// Changes returns up to limit of the changes numbered after since,
+	// oldest first.
+	Changes(ctx context.Context, since int64, limit int) ([]Change, error)
+	// LastChange returns the number of the tenant's latest change; 0 if
+	// it has made none.
+	LastChange(ctx context.Context) (int64, error)
+}
+
+// DocStore keeps the documents of the declared resources, one collection
+// each, per tenant like NameStore. Writes bump Version and are conditional
+// on it as for names.
+type DocStore interface {
+	// CreateDoc stamps d with an ID, the tenant and times, and version 1.
+	CreateDoc(ctx context.Context, collection string, d *Doc) error
+	GetDoc(ctx context.Context, collection string, id primitive.ObjectID) (Doc, error)
+	// ListDocs returns up to limit documents created after the one with ID
+	// after (any, if zero).
+	ListDocs(ctx context.Context, collection string, after primitive.ObjectID, limit int) (DocPage, error)
+	// UpdateDoc replaces the fields of document id.
+	UpdateDoc(ctx context.Context, collection string, id primitive.ObjectID, fields map[string]any, ifVersion int64) (Doc, error)
+	DeleteDoc(ctx context.Context, collection string, id primitive.ObjectID, ifVersion int64) error
+}
+
+// StatsStore computes NameStats for the tenant in ctx, counting the
+// creations since since.
+type StatsStore interface {
+	NameStats(ctx context.Context, since time.Time) (NameStats, error)
+}
+
+// DuplicateStore finds the live names of the tenant in ctx that are likely
+// one name entered more than once.
+type DuplicateStore interface {
+	// Duplicates returns the clusters of names whose normalized forms (see
+	// NormalizeName) are equal, or with q.Distance above zero within that
+	// many edits of each other, ordered by key. ErrTooManyNames if there are
+	// too many names to compare with a distance.
+	Duplicates(ctx context.Context, q DuplicateQuery) ([]DuplicateCluster, error)
+}
+
+// SampleStore picks names at random, for demos and load tests.
+type SampleStore interface {
+	// RandomName returns one live name of the tenant in ctx, each as likely
+	// as the others; ErrNotFound if it has none.
+	RandomName(ctx context.Context) (Name, error)
+}
+
+// NameKeyStore finds names by their UUID or slug, or by name. Keys are
+// unique per tenant: creating a name with one taken fails with
+// ErrDuplicate.
+type NameKeyStore interface {
+	// NameByKey returns the ID of the name of the tenant in ctx whose UUID
+	// or slug is key, soft-deleted or not; ErrNotFound if there is none.
+	NameByKey(ctx context.Context, key string) (primitive.ObjectID, error)
+	// NameByName returns the name of the tenant in ctx called name,
+	// soft-deleted or not, as Upsert would find it; ErrNotFound if none.
+	NameByName(ctx context.Context, name string) (Name, error)
+	// SlugsTaken reports which of slugs the tenant's names already have.
+	SlugsTaken(ctx context.Context, slugs []string) (map[string]bool, error)
+}
+
+// NormalizedStore rewrites the normalized forms of names (see
+// Name.NameNormalized), for when the rules they were made by change.
+type NormalizedStore interface {
+	// SetNormalized gives each name of the tenant in ctx in forms, by ID,
+	// its normalized form, soft-deleted names included. It isn't a change
+	// to the name: its version and updated_at stay, and no event is
+	// recorded. IDs that aren't there are ignored.
+	SetNormalized(ctx context.Context, forms map[primitive.ObjectID]string) error
+}
+
+// ExpiryStore finds the names due for removal in every tenant: those whose
+// ExpiresAt has passed and those soft-deleted long enough ago. The cleanup
+// removes them through the NameStore, in their own tenant, so the audit log
+// and the history see them go.
+type ExpiryStore interface {
+	// DueNames returns up to q.Limit due names, in ID order after q.After.
+	DueNames(ctx context.Context, q DueQuery) ([]Name, error)
+}
+
+// LeaseStore hands out leases, by name, to one holder at a time. Leases
+// aren't a tenant's: they are shared by every instance using the store.
+type LeaseStore interface {
+	// AcquireLease gives lease name to holder for ttl, if it is free, has
+	// expired or is already holder's, whose lease it then renews. It
+	// returns the lease as it stands: another's if it is held.
+	AcquireLease(ctx context.Context, name, holder string, ttl time.Duration) (Lease, error)
+	// ReleaseLease gives lease name up, if holder has it, for another to
+	// take at once rather than once it expires.
+	ReleaseLease(ctx context.Context, name, holder string) error
+}
+
+// IdempotencyStore keeps Idempotency-Key records until they expire.
+type IdempotencyStore interface {
+	// Claim inserts a pending record for key. It returns the existing,
+	// unexpired record instead if the key is already taken.
+	Claim(ctx context.Context, key, requestHash string, ttl time.Duration) (*IdempotencyRecord, error)
+	Complete(ctx context.Context, key string, status int, headers map[string]string, body []byte) error
+	Release(ctx context.Context, key string) error
+}
+
+// CaptureStore keeps the requests and responses recorded for debugging,
+// dropping the oldest once it is full.
+type CaptureStore interface {
+	SaveCapture(ctx context.Context, c *Capture) error
+	// Capture returns the latest capture of the request with ID requestID
+	// made by the tenant in ctx; ErrNotFound if there is none.
+	Capture(ctx context.Context, requestID string) (Capture, error)
+}
+
+// RevisionStore counts the writes to each tenant's names, so a client can
+// tell whether a listing changed without anyone reading it again.
+type RevisionStore interface {
+	// Bump counts one more write to the names of the tenant in ctx.
+	Bump(ctx context.Context) error
+	// Revision returns the count for the tenant in ctx; zero before the
+	// first write.
+	Revision(ctx context.Context) (Revision, error)
+}
+
+// ListOptions selects and pages names.
+type ListOptions struct {
+	Limit, Offset  int64
+	After          *Cursor
+	SortBy         string // "name" or "created_at" (default)
+	Desc           bool
+	NamePrefix     string
+	Match          string   // only names whose NameNormalized is this; those without one by their Name
+	CreatedBy      string   // only names created by this user ID
+	Tags           []string // only names with all of these tags, or
+	AnyTag         bool     // with any one of them
+	IncludeDeleted bool
+	OnlyDeleted    bool   // the trash: soft-deleted names only
+	Locale         string // sort names by this collation locale rather than the store's
+	Consistency    string // Strong (the default) or Eventual
+	// Fields, of NameFields, are the ones to read; nil reads them all. The
+	// ID and the sort field come regardless. Stores that can't project
+	// return whole names.
+	Fields []string
+}
+
+// The consistencies a list or search may ask for. Strong sees every write
+// acknowledged before it; Eventual may be served by a MongoDB secondary, to
+// spare the primary, and so lag a little behind. Stores that keep a single
+// copy of the names serve both alike.
+const (
+	Strong   = "strong"
+	Eventual = "eventual"
+)
+
+// NameFields are the fields of a Name, by their JSON names, that
+// ListOptions.Fields can ask for.
+var NameFields = []string{"id", "name", "name_normalized", "tags", "metadata", "created_at", "updated_at", "created_by", "deleted_at", "expires_at", "version"}
+
+// hasTags reports whether n has the tags opts asks for.
+func hasTags(opts ListOptions, n Name) bool {
+	if len(opts.Tags) == 0 { return true }
+	has := func(t string) bool { return slices.Contains(n.Tags, t) }
+	if opts.AnyTag { return slices.ContainsFunc(opts.Tags, has) }
+	for _, t := range opts.Tags {
+		if !has(t) { return false }
+	}
+	return true
+}
+
+// DueQuery selects the names DueNames returns.
+type DueQuery struct {
+	ExpiredBy     time.Time          // names expiring at or before it are due,
+	DeletedBefore time.Time          // and those soft-deleted before it unless it is zero
+	After         primitive.ObjectID // the last name of the previous batch; zero for the first
+	Limit         int
+}
+
+// due reports whether q selects n, paging aside.
+func (q DueQuery) due(n Name) bool {
+	if n.ExpiresAt != nil && !n.ExpiresAt.After(q.ExpiredBy) { return true }
+	return !q.DeletedBefore.IsZero() && n.DeletedAt != nil && n.DeletedAt.Before(q.DeletedBefore)
+}
+
+// Search modes: full-text (relevance-ranked), case-insensitive prefix for
+// type-ahead, or a regular expression. Prefixes are matched against the
+// normalized names, and the names of those without one.
+const (
+	SearchText   = "text"
+	SearchPrefix = "prefix"
+	SearchRegex  = "regex"
+)
+
+type SearchOptions struct {
+	Query string
+	Mode  string // SearchText (default), SearchPrefix or SearchRegex
+	Limit int64
+	// Consistency is Strong (the default) or Eventual, as for ListOptions.
+	Consistency string
+}
+
+// SearchHit is a matching name; Score is the text relevance, set only in
+// SearchText mode. Hits come best first, or by name outside SearchText.
+type SearchHit struct {
+	Name  `bson:",inline"`
+	Score float64 `json:"score,omitempty" bson:"score,omitempty"`
+}
+
+// AuditQuery selects audit entries: those of Entity (all if empty) and,
+// unless it is zero, EntityID, older than Before if it isn't zero.
+type AuditQuery struct {
+	Entity   string
+	EntityID primitive.ObjectID
+	Before   primitive.ObjectID
+	Limit    int64
+}
+
+// AuditPage is one page of ListAudit results. Next, the ID of the last
+// entry, is the Before of the next page and empty on the last one.
+type AuditPage struct {
+	Items []AuditEntry `json:"items"`
+	Next  string       `json:"next,omitempty"`
+}
+
+// Page is one page of List results. Next is empty on the last page.
+type Page struct {
+	Items []Name `json:"items"`
+	Total int64  `json:"total"`
+	Next  string `json:"next,omitempty"`
+}
+
+// Cursor is the position of the last item of a page: its sort value and its
+// id as a tie-breaker. Clients only ever see it encoded.
+type Cursor struct {
+	Value string             `json:"v,omitempty"`
+	ID    primitive.ObjectID `json:"id"`
+}
+
+func (c Cursor) Encode() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func DecodeCursor(s string) (*Cursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil { return nil, err }
+	var c Cursor
+	if err := json.Unmarshal(b, &c); err != nil { return nil, err }
+	if c.ID.IsZero() { return nil, errors.New("cursor without id") }
+	return &c, nil
+}
+
+// cursorFor returns the cursor positioned at n under opts' sort order.
+func cursorFor(opts ListOptions, n Name) string {
+	c := Cursor{ID: n.ID}
+	if opts.SortBy == "name" { c.Value = n.Name }
+	return c.Encode()
+}
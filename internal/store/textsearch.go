@@ -0,0 +1,42 @@
+package store
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// The stores without a text index approximate MongoDB's: a name matches if
+// one query word appears in its name (weight 10) or tags (weight 1),
+// compared case-insensitively and without stemming.
+
+func words(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) })
+}
+
+// textScore is n's relevance for the query words terms; 0 is no match.
+func textScore(terms []string, n Name) float64 {
+	var score float64
+	for _, w := range words(n.Name) {
+		if slices.Contains(terms, w) { score += 10 }
+	}
+	for _, t := range n.Tags {
+		for _, w := range words(t) {
+			if slices.Contains(terms, w) { score++ }
+		}
+	}
+	return score
+}
+
+// rankHits orders hits best first, then by name, and keeps the first limit.
+func rankHits(hits []SearchHit, limit int64) []SearchHit {
+	slices.SortFunc(hits, func(a, b SearchHit) int {
+		if a.Score != b.Score {
+			if a.Score > b.Score { return -1 }
+			return 1
+		}
+		return strings.Compare(a.Name.Name, b.Name.Name)
+	})
+	if limit > 0 && int64(len(hits)) > limit { hits = hits[:limit] }
+	return hits
+}
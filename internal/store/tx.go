@@ -0,0 +1,64 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrTransactionsUnsupported: the deployment can't run transactions (a
+// standalone mongod, or the SQL store).
+var ErrTransactionsUnsupported = errors.New("transactions are not supported by this deployment")
+
+// Transactor runs several writes to names as one: all of them apply, or
+// none do.
+type Transactor interface {
+	// InTransaction calls fn with a context whose writes commit together if
+	// fn returns nil and are rolled back if it returns an error, which
+	// InTransaction then returns. fn may be called again after a transient
+	// failure, so what it does besides writing, such as telling others of
+	// the writes, must wait for AfterCommit.
+	InTransaction(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+type txKey struct{}
+
+// tx is the state of a transaction underway, carried in its context.
+type tx struct {
+	owner any // the store running it
+
+	mu    sync.Mutex
+	after []func(context.Context)
+}
+
+// withTx returns ctx inside t.
+func withTx(ctx context.Context, t *tx) context.Context { return context.WithValue(ctx, txKey{}, t) }
+
+// txFrom returns the transaction ctx is inside, if any.
+func txFrom(ctx context.Context) *tx {
+	t, _ := ctx.Value(txKey{}).(*tx)
+	return t
+}
+
+// InTransaction reports whether ctx is inside a transaction.
+func InTransaction(ctx context.Context) bool { return txFrom(ctx) != nil }
+
+// AfterCommit calls fn once the transaction ctx is inside has committed,
+// not at all if it is rolled back, or at once outside of one. fn gets the
+// context the transaction was started with.
+func AfterCommit(ctx context.Context, fn func(ctx context.Context)) {
+	t := txFrom(ctx)
+	if t == nil { fn(ctx); return }
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.after = append(t.after, fn)
+}
+
+// committed runs what was left for after the commit, with ctx.
+func (t *tx) committed(ctx context.Context) {
+	t.mu.Lock()
+	after := t.after
+	t.after = nil
+	t.mu.Unlock()
+	for _, fn := range after { fn(ctx) }
+}
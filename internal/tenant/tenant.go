@@ -0,0 +1,34 @@
+// Package tenant carries the tenant a request acts for. Every name belongs
+// to exactly one tenant, and the stores read and write only the tenant in
+// the context they are given, so teams sharing a deployment never see each
+// other's data.
+package tenant
+
+import "context"
+
+// Default is the tenant of requests that name none: everything when auth
+// is disabled, and accounts and data from before tenants existed.
+const Default = "default"
+
+type ctxKey struct{}
+
+func NewContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, ctxKey{}, id)
+}
+
+// FromContext returns the request's tenant, Default if it has none.
+func FromContext(ctx context.Context) string {
+	if id, _ := ctx.Value(ctxKey{}).(string); id != "" { return id }
+	return Default
+}
+
+// Valid reports whether id is usable as a tenant: 1 to 64 lower-case ASCII
+// letters, digits and dashes, not starting with a dash.
+func Valid(id string) bool {
+	if id == "" || len(id) > 64 || id[0] == '-' { return false }
+	for i := 0; i < len(id); i++ {
+		c := id[i]
+		if (c < 'a' || c > 'z') && (c < '0' || c > '9') && c != '-' { return false }
+	}
+	return true
+}
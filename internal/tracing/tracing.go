@@ -0,0 +1,106 @@
+// Package tracing sets up OpenTelemetry: a span for every HTTP request and
+// every MongoDB command under it, exported over OTLP to Jaeger, Tempo or a
+// collector.
+//
+// The exporter is configured with the standard OTEL_* environment variables
+// (OTEL_EXPORTER_OTLP_ENDPOINT, OTEL_EXPORTER_OTLP_PROTOCOL,
+// OTEL_SERVICE_NAME, OTEL_TRACES_SAMPLER, ...). Tracing is off unless an
+// OTLP endpoint is set, in which case spans are still created but go nowhere.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+
+	"go.mongodb.org/mongo-driver/event"
+	"go.opentelemetry.io/contrib/instrumentation/go.mongodb.org/mongo-driver/mongo/otelmongo"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const serviceName = "names-api" // unless OTEL_SERVICE_NAME says otherwise
+
+// Enabled reports whether the environment asks for spans to be exported.
+func Enabled() bool {
+	if os.Getenv("OTEL_SDK_DISABLED") == "true" { return false }
+	return os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" || os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") != ""
+}
+
+// Setup installs the global tracer provider and W3C trace context
+// propagation. The returned shutdown flushes buffered spans; call it after
+// the servers have stopped. When tracing is not Enabled it does nothing.
+func Setup(ctx context.Context) (shutdown func(context.Context) error, err error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	if !Enabled() { return func(context.Context) error { return nil }, nil }
+
+	var exp sdktrace.SpanExporter
+	switch protocol() {
+	case "grpc":
+		exp, err = otlptracegrpc.New(ctx)
+	case "http/protobuf":
+		exp, err = otlptracehttp.New(ctx)
+	default:
+		return nil, fmt.Errorf("OTEL_EXPORTER_OTLP_PROTOCOL must be grpc or http/protobuf, got %q", protocol())
+	}
+	if err != nil { return nil, fmt.Errorf("creating OTLP exporter: %w", err) }
+
+	// Later sources win, so OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES
+	// override the default name.
+	res, err := resource.New(ctx,
+		resource.WithAttributes(semconv.ServiceName(serviceName)),
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+	)
+	if err != nil { return nil, fmt.Errorf("building trace resource: %w", err) }
+
+	// The sampler is left to OTEL_TRACES_SAMPLER; the SDK default is to
+	// sample everything, honouring the caller's decision.
+	tp := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exp), sdktrace.WithResource(res))
+	otel.SetTracerProvider(tp)
+	return tp.Shutdown, nil
+}
+
+func protocol() string {
+	if p := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_PROTOCOL"); p != "" { return p }
+	if p := os.Getenv("OTEL_EXPORTER_OTLP_PROTOCOL"); p != "" { return p }
+	return "http/protobuf"
+}
+
+// Middleware starts a server span for every request, continuing the
+// caller's trace if it sent a traceparent header. Like metrics.Middleware,
+// it names the span after the route pattern the request matched, so
+// "GET /names/{id}" is one operation however many IDs there are.
+// Probes and scrapes are not traced.
+func Middleware(route func(*http.Request) string, next http.Handler) http.Handler {
+	named := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r)
+		if rt := route(r); rt != "" {
+			span := trace.SpanFromContext(r.Context())
+			span.SetName(r.Method + " " + rt)
+			span.SetAttributes(semconv.HTTPRoute(rt))
+		}
+	})
+	return otelhttp.NewHandler(named, "http",
+		otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string { return r.Method }),
+		otelhttp.WithFilter(func(r *http.Request) bool {
+			switch r.URL.Path {
+			case "/health", "/healthz", "/readyz", "/metrics":
+				return false
+			}
+			return true
+		}),
+	)
+}
+
+// CommandMonitor creates a client span for every MongoDB command, a child
+// of the request's span when the command runs under its context.
+func CommandMonitor() *event.CommandMonitor { return otelmongo.NewMonitor() }
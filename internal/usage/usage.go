@@ -0,0 +1,234 @@
+// Package usage counts the requests each API key makes and the bytes of
+// their bodies, a day at a time, and holds the keys to their daily and
+// monthly QUOTA_* quotas.
+//
+// Requests never wait on the count: a Tracker adds them up in memory and
+// adds those to the store every USAGE_FLUSH. What other servers counted it
+// rereads as often, so with several servers a key can go over a quota by
+// what they let through in that time.
+package usage
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"app/internal/metrics"
+	"app/internal/store"
+)
+
+// Quotas caps what an API key may use a day (UTC) and a calendar month;
+// 0 leaves a quota off. Bytes are those of the request and response
+// bodies together.
+type Quotas struct {
+	DailyRequests   int64 `json:"daily_requests,omitempty"`
+	MonthlyRequests int64 `json:"monthly_requests,omitempty"`
+	DailyBytes      int64 `json:"daily_bytes,omitempty"`
+	MonthlyBytes    int64 `json:"monthly_bytes,omitempty"`
+}
+
+// Config tunes a Tracker.
+type Config struct {
+	Flush  time.Duration // how often counts are added to the store, and the store's reread
+	Quotas Quotas
+}
+
+// Totals is what a key used in a period.
+type Totals struct {
+	Requests int64 `json:"requests"`
+	BytesIn  int64 `json:"bytes_in"`
+	BytesOut int64 `json:"bytes_out"`
+}
+
+func (t *Totals) add(u store.Usage) {
+	t.Requests, t.BytesIn, t.BytesOut = t.Requests+u.Requests, t.BytesIn+u.BytesIn, t.BytesOut+u.BytesOut
+}
+
+func (t Totals) bytes() int64 { return t.BytesIn + t.BytesOut }
+
+// Period is what a key used so far in a day or month, and when the next
+// one starts.
+type Period struct {
+	Totals
+	Resets time.Time `json:"resets"`
+}
+
+// Report is what a key used today and this month.
+type Report struct {
+	Today Period `json:"today"`
+	Month Period `json:"month"`
+}
+
+// ExceededError is Allow's when a key has used up a quota.
+type ExceededError struct {
+	Quota  string // daily_requests, monthly_requests, daily_bytes or monthly_bytes
+	Limit  int64
+	Resets time.Time
+}
+
+func (e *ExceededError) Error() string {
+	return fmt.Sprintf("the %s quota of %d is used up until %s", e.Quota, e.Limit, e.Resets.Format(time.RFC3339))
+}
+
+// Tracker counts what API keys use and holds them to Quotas.
+type Tracker struct {
+	s   store.UsageStore
+	cfg Config
+	now func() time.Time
+
+	mu      sync.Mutex
+	pending map[dayKey]store.Usage // counted here but not yet in the store
+	keys    map[primitive.ObjectID]*keyTotals
+}
+
+type dayKey struct {
+	id  primitive.ObjectID
+	day string
+}
+
+// keyTotals is what a key used as the store had it at loaded, plus what
+// was counted here since.
+type keyTotals struct {
+	loaded       time.Time
+	day          string
+	today, month Totals
+}
+
+func New(s store.UsageStore, cfg Config) *Tracker {
+	return &Tracker{s: s, cfg: cfg, now: time.Now, pending: map[dayKey]store.Usage{}, keys: map[primitive.ObjectID]*keyTotals{}}
+}
+
+// Quotas returns the quotas t holds keys to.
+func (t *Tracker) Quotas() Quotas { return t.cfg.Quotas }
+
+// Allow returns an *ExceededError if key has used up a quota; the monthly
+// ones come first, as their reset is the later.
+func (t *Tracker) Allow(ctx context.Context, keyID primitive.ObjectID) error {
+	q := t.cfg.Quotas
+	if q == (Quotas{}) { return nil }
+	r, err := t.Report(ctx, keyID)
+	if err != nil { return err }
+	for _, c := range []struct {
+		quota       string
+		used, limit int64
+		resets      time.Time
+	}{
+		{"monthly_requests", r.Month.Requests, q.MonthlyRequests, r.Month.Resets},
+		{"monthly_bytes", r.Month.bytes(), q.MonthlyBytes, r.Month.Resets},
+		{"daily_requests", r.Today.Requests, q.DailyRequests, r.Today.Resets},
+		{"daily_bytes", r.Today.bytes(), q.DailyBytes, r.Today.Resets},
+	} {
+		if c.limit > 0 && c.used >= c.limit {
+			metrics.QuotaExceeded(c.quota)
+			return &ExceededError{Quota: c.quota, Limit: c.limit, Resets: c.resets}
+		}
+	}
+	return nil
+}
+
+// Record counts a request of key, of tenant, that read in and wrote out
+// bytes of body.
+func (t *Tracker) Record(keyID primitive.ObjectID, tenant string, in, out int64) {
+	u := store.Usage{KeyID: keyID, Tenant: tenant, Day: t.now().UTC().Format(time.DateOnly), Requests: 1, BytesIn: in, BytesOut: out}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.addPending(u)
+	if k := t.keys[keyID]; k != nil && k.day == u.Day { k.today.add(u); k.month.add(u) }
+}
+
+// Report returns what key used today and this month, rereading the store
+// if it last did a Flush ago or yesterday.
+func (t *Tracker) Report(ctx context.Context, keyID primitive.ObjectID) (Report, error) {
+	now := t.now().UTC()
+	day := now.Format(time.DateOnly)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+	t.mu.Lock()
+	k := t.keys[keyID]
+	t.mu.Unlock()
+	if k == nil || k.day != day || now.Sub(k.loaded) >= t.cfg.Flush {
+		var err error
+		if k, err = t.load(ctx, keyID, now, month); err != nil { return Report{}, err }
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return Report{
+		Today: Period{Totals: k.today, Resets: today.AddDate(0, 0, 1)},
+		Month: Period{Totals: k.month, Resets: month.AddDate(0, 1, 0)},
+	}, nil
+}
+
+// load reads key's month from the store and adds what is pending here.
+// Counts a flush adds to the store after the read are missed until the
+// next load: a key gets away with a little more, never less.
+func (t *Tracker) load(ctx context.Context, keyID primitive.ObjectID, now, month time.Time) (*keyTotals, error) {
+	days, err := t.s.Usage(ctx, keyID, month.Format(time.DateOnly))
+	if err != nil { return nil, err }
+	k := &keyTotals{loaded: now, day: now.Format(time.DateOnly)}
+	for _, u := range days {
+		k.month.add(u)
+		if u.Day == k.day { k.today.add(u) }
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for dk, u := range t.pending {
+		if dk.id != keyID || dk.day < month.Format(time.DateOnly) { continue }
+		k.month.add(u)
+		if dk.day == k.day { k.today.add(u) }
+	}
+	t.keys[keyID] = k
+	return k, nil
+}
+
+// Run adds the counts to the store every Flush until ctx ends, and then
+// once more.
+func (t *Tracker) Run(ctx context.Context) error {
+	slog.Info("tracking API key usage", "flush", t.cfg.Flush.String(), "quotas", t.cfg.Quotas != (Quotas{}))
+	tick := time.NewTicker(t.cfg.Flush)
+	defer tick.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			flushCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+			defer cancel()
+			t.Flush(flushCtx)
+			return nil
+		case <-tick.C:
+			t.Flush(ctx)
+		}
+	}
+}
+
+// Flush adds the pending counts to the store, keeping them for the next
+// one if it fails, and forgets the keys not seen for a Flush.
+func (t *Tracker) Flush(ctx context.Context) {
+	t.mu.Lock()
+	us := make([]store.Usage, 0, len(t.pending))
+	for _, u := range t.pending { us = append(us, u) }
+	clear(t.pending)
+	for id, k := range t.keys {
+		if t.now().Sub(k.loaded) >= t.cfg.Flush { delete(t.keys, id) }
+	}
+	t.mu.Unlock()
+
+	if err := t.s.AddUsage(ctx, us); err != nil {
+		slog.ErrorContext(ctx, "adding up API key usage", "keys", len(us), "err", err)
+		t.mu.Lock()
+		for _, u := range us { t.addPending(u) }
+		t.mu.Unlock()
+	}
+}
+
+// addPending adds u to the pending counts; t.mu must be held.
+func (t *Tracker) addPending(u store.Usage) {
+	dk := dayKey{u.KeyID, u.Day}
+	p, found := t.pending[dk]
+	if !found { t.pending[dk] = u; return }
+	p.Requests, p.BytesIn, p.BytesOut = p.Requests+u.Requests, p.BytesIn+u.BytesIn, p.BytesOut+u.BytesOut
+	t.pending[dk] = p
+}
@@ -0,0 +1,64 @@
+package usage
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"app/internal/store"
+)
+
+// flaky is a UsageStore whose AddUsage fails while down.
+type flaky struct {
+	store.UsageStore
+	down bool
+}
+
+func (f *flaky) AddUsage(ctx context.Context, us []store.Usage) error {
+	if f.down { return errors.New("unreachable") }
+	return f.UsageStore.AddUsage(ctx, us)
+}
+
+func TestTracker(t *testing.T) {
+	ctx, key := context.Background(), primitive.NewObjectID()
+	s := &flaky{UsageStore: store.NewMemoryUsage()}
+	// Another server counted some of October, and September doesn't count.
+	if err := s.AddUsage(ctx, []store.Usage{{KeyID: key, Day: "2026-10-02", Requests: 9, BytesOut: 900}, {KeyID: key, Day: "2026-09-30", Requests: 500}}); err != nil { t.Fatal(err) }
+
+	tr := New(s, Config{Flush: time.Minute, Quotas: Quotas{DailyRequests: 3, MonthlyBytes: 1000}})
+	now := time.Date(2026, 10, 31, 23, 0, 0, 0, time.UTC)
+	tr.now = func() time.Time { return now }
+
+	if err := tr.Allow(ctx, key); err != nil { t.Fatal(err) }
+	tr.Record(key, "team-a", 50, 40)
+	if err := tr.Allow(ctx, key); err != nil { t.Fatalf("990 bytes of 1000: %v", err) }
+	tr.Record(key, "team-a", 0, 20)
+	var exceeded *ExceededError
+	if err := tr.Allow(ctx, key); !errors.As(err, &exceeded) || exceeded.Quota != "monthly_bytes" || !exceeded.Resets.Equal(time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("1010 bytes of 1000: %v", err)
+	}
+
+	// A failed flush keeps the counts for the next.
+	s.down = true
+	tr.Flush(ctx)
+	s.down = false
+	tr.Flush(ctx)
+	days, err := s.Usage(ctx, key, "2026-10-31")
+	if err != nil || len(days) != 1 || days[0].Requests != 2 || days[0].BytesIn != 50 || days[0].BytesOut != 60 || days[0].Tenant != "team-a" { t.Fatalf("stored: %+v, %v", days, err) }
+
+	// The store is reread a Flush later, so another server's counts show.
+	if err := s.AddUsage(ctx, []store.Usage{{KeyID: key, Day: "2026-10-31", Requests: 1}}); err != nil { t.Fatal(err) }
+	now = now.Add(time.Minute)
+	tr.Record(key, "team-a", 0, 0)
+	r, err := tr.Report(ctx, key)
+	if err != nil || r.Today.Requests != 4 || r.Month.Requests != 13 { t.Fatalf("report: %+v, %v", r, err) }
+	if err := tr.Allow(ctx, key); !errors.As(err, &exceeded) || exceeded.Quota != "monthly_bytes" { t.Fatalf("monthly quotas come first: %v", err) }
+
+	// November starts afresh.
+	now = time.Date(2026, 11, 1, 0, 30, 0, 0, time.UTC)
+	if err := tr.Allow(ctx, key); err != nil { t.Fatalf("in November: %v", err) }
+	if r, err := tr.Report(ctx, key); err != nil || r.Month.Requests != 0 || !r.Today.Resets.Equal(time.Date(2026, 11, 2, 0, 0, 0, 0, time.UTC)) { t.Fatalf("November: %+v, %v", r, err) }
+}
@@ -0,0 +1,141 @@
+// Package validate holds the input rules for names, shared by the HTTP and
+// gRPC front ends so both reject exactly the same documents.
+package validate
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"app/internal/store"
+)
+
+// Limits on the optional fields so a single document can't be used to
+// store arbitrary blobs.
+const (
+	MaxNameLen       = 200
+	MaxTags          = 20
+	MaxTagLen        = 64
+	MaxMetadataBytes = 4 << 10
+	MaxNoteLen       = 2000
+)
+
+// NameSymbols are the characters a name may hold besides letters, marks
+// and digits of any script and single spaces.
+const NameSymbols = "-'.,&()_/:#+@!?"
+
+// FieldError is one validation failure, reported to clients in a 422.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// fieldErrors collects validation failures; its check methods validate one
+// field each, trimming it in place.
+type fieldErrors []FieldError
+
+func (e *fieldErrors) add(field, format string, args ...any) {
+	*e = append(*e, FieldError{field, fmt.Sprintf(format, args...)})
+}
+
+// checkName trims the name and collapses runs of whitespace inside it to a
+// single space, so "Ada  Lovelace" and "Ada Lovelace" are the same name.
+func (e *fieldErrors) checkName(name *string) {
+	*name = strings.Join(strings.Fields(*name), " ")
+	switch {
+	case *name == "":
+		e.add("name", "is required")
+	case utf8.RuneCountInString(*name) > MaxNameLen:
+		e.add("name", "must be at most %d characters", MaxNameLen)
+	case !utf8.ValidString(*name):
+		e.add("name", "is not valid UTF-8")
+	default:
+		if i := strings.IndexFunc(*name, disallowed); i >= 0 {
+			r, _ := utf8.DecodeRuneInString((*name)[i:])
+			e.add("name", "contains %q; only letters, digits, spaces and %s are allowed", r, NameSymbols)
+		}
+	}
+}
+
+func disallowed(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsMark(r) && !unicode.IsDigit(r) && r != ' ' && !strings.ContainsRune(NameSymbols, r)
+}
+
+func (e *fieldErrors) checkTags(tags []string) {
+	if len(tags) > MaxTags { e.add("tags", "at most %d allowed", MaxTags) }
+	for i := range tags { e.checkTag(fmt.Sprintf("tags[%d]", i), &tags[i]) }
+}
+
+func (e *fieldErrors) checkTag(field string, tag *string) {
+	*tag = strings.TrimSpace(*tag)
+	if *tag == "" { e.add(field, "must be a non-empty string"); return }
+	if len(*tag) > MaxTagLen { e.add(field, "exceeds %d bytes", MaxTagLen) }
+}
+
+func (e *fieldErrors) checkMetadata(m map[string]any) {
+	if len(m) == 0 { return }
+	b, err := json.Marshal(m)
+	if err != nil {
+		e.add("metadata", "is not serializable")
+	} else if len(b) > MaxMetadataBytes {
+		e.add("metadata", "exceeds %d bytes", MaxMetadataBytes)
+	}
+}
+
+// checkExpiry rounds the expiry to the millisecond the stores keep and
+// checks it is still to come.
+func (e *fieldErrors) checkExpiry(at *time.Time) {
+	if at == nil { return }
+	*at = at.UTC().Truncate(time.Millisecond)
+	if !at.After(time.Now()) { e.add("expires_at", "must be in the future") }
+}
+
+// Name trims user input in place and reports every field that is invalid;
+// nil means the Name is fine.
+func Name(n *store.Name) []FieldError {
+	var errs fieldErrors
+	errs.checkName(&n.Name)
+	errs.checkTags(n.Tags)
+	errs.checkMetadata(n.Metadata)
+	errs.checkExpiry(n.ExpiresAt)
+	return errs
+}
+
+// Tag trims a single tag, as added by POST /names/{id}/tags, and checks it
+// like each of a Name's tags.
+func Tag(tag *string) []FieldError {
+	var errs fieldErrors
+	errs.checkTag("tag", tag)
+	return errs
+}
+
+// Note trims the note's body and checks it isn't empty or too long.
+func Note(n *store.Note) []FieldError {
+	var errs fieldErrors
+	n.Body = strings.TrimSpace(n.Body)
+	switch {
+	case n.Body == "":
+		errs.add("body", "is required")
+	case utf8.RuneCountInString(n.Body) > MaxNoteLen:
+		errs.add("body", "must be at most %d characters", MaxNoteLen)
+	case !utf8.ValidString(n.Body):
+		errs.add("body", "is not valid UTF-8")
+	}
+	return errs
+}
+
+// Patch is Name for the fields a PATCH sets.
+func Patch(p *store.NamePatch) []FieldError {
+	var errs fieldErrors
+	if p.Name == nil && p.Tags == nil && p.Metadata == nil && !p.ExpiresAt.Set {
+		errs.add("body", "must set at least one of name, tags, metadata, expires_at")
+	}
+	if p.Name != nil { errs.checkName(p.Name) }
+	if p.Tags != nil { errs.checkTags(*p.Tags) }
+	if p.Metadata != nil { errs.checkMetadata(*p.Metadata) }
+	errs.checkExpiry(p.ExpiresAt.At)
+	return errs
+}
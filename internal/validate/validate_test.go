@@ -0,0 +1,46 @@
+package validate
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"app/internal/store"
+)
+
+func TestName(t *testing.T) {
+	for _, tc := range []struct {
+		in, want string
+		err      string // substring of the name error; "" if valid
+	}{
+		{"  Ada \t Lovelace\n", "Ada Lovelace", ""},
+		{"José Ñúñez", "José Ñúñez", ""},
+		{"O'Brien-Smith (Jr.)", "O'Brien-Smith (Jr.)", ""},
+		{"山田 太郎", "山田 太郎", ""},
+		{" \n ", "", "is required"},
+		{"a\u200bb", "", `contains '\u200b'`},
+		{"<script>", "", `contains '<'`},
+		{"\xff", "", "not valid UTF-8"},
+		{strings.Repeat("x", MaxNameLen+1), "", "at most"},
+	} {
+		n := store.Name{Name: tc.in}
+		errs := Name(&n)
+		switch {
+		case tc.err == "" && (errs != nil || n.Name != tc.want):
+			t.Errorf("%q: got %q, %v; want %q", tc.in, n.Name, errs, tc.want)
+		case tc.err != "" && (len(errs) != 1 || !strings.Contains(errs[0].Message, tc.err)):
+			t.Errorf("%q: got %v, want an error containing %q", tc.in, errs, tc.err)
+		}
+	}
+}
+
+func TestExpiry(t *testing.T) {
+	soon, past := time.Now().Add(time.Hour+time.Microsecond), time.Now().Add(-time.Minute)
+	n := store.Name{Name: "Ada", ExpiresAt: &soon}
+	if errs := Name(&n); errs != nil || n.ExpiresAt.Nanosecond()%int(time.Millisecond) != 0 { t.Fatalf("future expiry: %v, %v", errs, n.ExpiresAt) }
+	n.ExpiresAt = &past
+	if errs := Name(&n); len(errs) != 1 || errs[0].Field != "expires_at" { t.Fatalf("past expiry: %v", errs) }
+
+	if errs := Patch(&store.NamePatch{ExpiresAt: store.Expiry{Set: true}}); errs != nil { t.Fatalf("clearing the expiry: %v", errs) }
+	if errs := Patch(&store.NamePatch{ExpiresAt: store.Expiry{Set: true, At: &past}}); len(errs) != 1 { t.Fatalf("patching a past expiry: %v", errs) }
+}
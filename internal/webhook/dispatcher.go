@@ -0,0 +1,216 @@
+// Package webhook tells other services of the changes to names as they
+// happen: each tenant registers webhooks, URLs with the events they want,
+// and every write queues a delivery to each of them in a
+// store.WebhookStore. Workers in any instance POST the deliveries, signed
+// with the webhook's secret, and retry the ones that fail with exponential
+// backoff, keeping a log of every attempt.
+//
+// A delivery is a POST of a Payload as JSON, with the headers
+//
+//	X-Webhook-Event:     the event, such as created
+//	X-Webhook-Delivery:  the delivery's ID, the same on every attempt
+//	X-Webhook-Timestamp: when it was sent, in Unix seconds
+//	X-Webhook-Signature: sha256=<hex HMAC-SHA256 of "<timestamp>.<body>">
+//
+// Any 2xx answer delivers it; anything else, redirects included, or no
+// answer within the timeout, is retried until MaxAttempts.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"app/internal/metrics"
+	"app/internal/store"
+	"app/internal/tenant"
+)
+
+// Config tunes a Dispatcher.
+type Config struct {
+	Workers     int           // deliveries POSTed at once; 0 only queues them
+	Timeout     time.Duration // for the webhook to answer
+	Poll        time.Duration // how often idle workers look for deliveries due
+	MaxAttempts int           // POSTs before a delivery fails for good
+	Backoff     time.Duration // wait after the first failed attempt, doubled after each further one
+	MaxBackoff  time.Duration // the longest wait
+	Retention   time.Duration // how long finished deliveries are kept
+}
+
+// Dispatcher is a WebhookStore whose EnqueueDeliveries also wakes the
+// workers, and those workers.
+type Dispatcher struct {
+	store.WebhookStore
+	cfg    Config
+	client *http.Client
+	wake   chan struct{}
+}
+
+func New(s store.WebhookStore, cfg Config) *Dispatcher {
+	client := &http.Client{
+		Timeout:       cfg.Timeout,
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+	return &Dispatcher{WebhookStore: s, cfg: cfg, client: client, wake: make(chan struct{}, 1)}
+}
+
+// EnqueueDeliveries queues ds and wakes an idle worker.
+func (d *Dispatcher) EnqueueDeliveries(ctx context.Context, ds []store.Delivery) error {
+	if len(ds) == 0 { return nil }
+	if err := d.WebhookStore.EnqueueDeliveries(ctx, ds); err != nil { return err }
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Run runs the workers, and purges old deliveries, until ctx ends.
+func (d *Dispatcher) Run(ctx context.Context) error {
+	if d.cfg.Workers == 0 { return nil }
+	slog.Info("delivering webhooks", "workers", d.cfg.Workers)
+	var wg sync.WaitGroup
+	for range d.cfg.Workers {
+		wg.Add(1)
+		go func() { defer wg.Done(); d.work(ctx) }()
+	}
+	d.purge(ctx)
+	wg.Wait()
+	return nil
+}
+
+// work claims and delivers one delivery after another, waiting for a
+// wake-up or the next poll when none is due. A claim holds the delivery
+// for twice the timeout, long enough to POST it and record how it went.
+func (d *Dispatcher) work(ctx context.Context) {
+	for ctx.Err() == nil {
+		del, err := d.ClaimDelivery(ctx, 2*d.cfg.Timeout)
+		if err == nil { d.deliver(ctx, del); continue }
+		if !errors.Is(err, store.ErrNotFound) && ctx.Err() == nil { slog.ErrorContext(ctx, "claiming a webhook delivery", "err", err) }
+		select {
+		case <-ctx.Done():
+		case <-d.wake:
+		case <-time.After(d.cfg.Poll):
+		}
+	}
+}
+
+// purge removes the deliveries finished more than Retention ago, hourly.
+func (d *Dispatcher) purge(ctx context.Context) {
+	tick := time.NewTicker(time.Hour)
+	defer tick.Stop()
+	for {
+		if err := d.PurgeDeliveries(ctx, time.Now().Add(-d.cfg.Retention)); err != nil && ctx.Err() == nil {
+			slog.ErrorContext(ctx, "purging old webhook deliveries", "err", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick.C:
+		}
+	}
+}
+
+// deliver POSTs del to its webhook and records the attempt: delivered,
+// due again after the backoff, or failed for good.
+func (d *Dispatcher) deliver(ctx context.Context, del store.Delivery) {
+	log := slog.With("delivery", del.ID.Hex(), "webhook", del.WebhookID.Hex(), "tenant", del.Tenant)
+	hook, err := d.Webhook(tenant.NewContext(ctx, del.Tenant), del.WebhookID)
+	if errors.Is(err, store.ErrNotFound) { return } // deleted, its deliveries with it
+	if err != nil {
+		if ctx.Err() == nil { log.Error("reading a webhook", "err", err) }
+		return // tried again once the claim lapses
+	}
+
+	attempt := d.post(ctx, hook, del)
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	switch {
+	case ctx.Err() != nil:
+		// Shutting down: the attempt doesn't count, and the delivery is due
+		// again at once.
+		del.NextAttemptAt = &now
+	case attempt.StatusCode >= 200 && attempt.StatusCode < 300:
+		del.Status, del.Attempts = store.DeliveryDelivered, append(del.Attempts, attempt)
+		metrics.WebhookAttempt("delivered")
+	case len(del.Attempts)+1 >= d.cfg.MaxAttempts:
+		del.Status, del.Attempts = store.DeliveryFailed, append(del.Attempts, attempt)
+		metrics.WebhookAttempt("failed")
+		log.Warn("webhook delivery failed for good", "attempts", len(del.Attempts), "status", attempt.StatusCode, "err", attempt.Error)
+	default:
+		del.Attempts = append(del.Attempts, attempt)
+		next := now.Add(d.backoff(len(del.Attempts)))
+		del.NextAttemptAt = &next
+		metrics.WebhookAttempt("retry")
+	}
+	// Recorded even while shutting down, with a grace of its own.
+	fctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer cancel()
+	if err := d.FinishDelivery(fctx, del); err != nil && !errors.Is(err, store.ErrNotFound) {
+		log.Error("recording a webhook delivery", "err", err)
+	}
+}
+
+// backoff returns the wait after the nth failed attempt.
+func (d *Dispatcher) backoff(n int) time.Duration {
+	wait := d.cfg.Backoff
+	for ; n > 1 && wait < d.cfg.MaxBackoff; n-- { wait *= 2 }
+	return min(wait, d.cfg.MaxBackoff)
+}
+
+// post sends del to hook once.
+func (d *Dispatcher) post(ctx context.Context, hook store.Webhook, del store.Delivery) (attempt store.DeliveryAttempt) {
+	start := time.Now()
+	attempt.At = start.UTC().Truncate(time.Millisecond)
+	defer func() { attempt.DurationMS = time.Since(start).Milliseconds() }()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(del.Payload))
+	if err != nil { attempt.Error = err.Error(); return attempt }
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "names-api-webhooks")
+	req.Header.Set("X-Webhook-Event", del.Event)
+	req.Header.Set("X-Webhook-Delivery", del.ID.Hex())
+	req.Header.Set("X-Webhook-Timestamp", strconv.FormatInt(start.Unix(), 10))
+	req.Header.Set("X-Webhook-Signature", Sign(hook.Secret, start, del.Payload))
+	resp, err := d.client.Do(req)
+	if err != nil { attempt.Error = err.Error(); return attempt }
+	resp.Body.Close()
+	attempt.StatusCode = resp.StatusCode
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 { attempt.Error = fmt.Sprintf("answered %s", resp.Status) }
+	return attempt
+}
+
+// Sign returns the X-Webhook-Signature of body sent at t with secret, for
+// receivers to compare with the header, in constant time, and with t from
+// X-Webhook-Timestamp checked to be recent.
+func Sign(secret string, t time.Time, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(t.Unix(), 10) + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// EnqueueEvent queues a delivery of the outbox's event e to each webhook of
+// its tenant that wants it; it is an outbox.Sink.
+func (d *Dispatcher) EnqueueEvent(ctx context.Context, e store.OutboxEvent) error {
+	ctx = tenant.NewContext(ctx, e.Tenant)
+	hooks, err := d.Webhooks(ctx)
+	if err != nil { return err }
+	payload, err := json.Marshal(Payload{ID: e.ID.Hex(), Event: e.Type, NameID: e.NameID, Name: e.Name, At: e.At, RequestID: e.RequestID})
+	if err != nil { return err }
+	var ds []store.Delivery
+	for _, h := range hooks {
+		if slices.Contains(h.Events, e.Type) { ds = append(ds, store.Delivery{WebhookID: h.ID, Event: e.Type, Payload: payload}) }
+	}
+	return d.EnqueueDeliveries(ctx, ds)
+}
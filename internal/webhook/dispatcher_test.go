@@ -0,0 +1,90 @@
+package webhook
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"app/internal/store"
+	"app/internal/tenant"
+)
+
+func testConfig() Config {
+	return Config{Workers: 2, Timeout: time.Second, Poll: 10 * time.Millisecond, MaxAttempts: 3, Backoff: time.Millisecond, MaxBackoff: 4 * time.Millisecond, Retention: time.Hour}
+}
+
+// start runs d until the test ends.
+func start(t *testing.T, d *Dispatcher) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- d.Run(ctx) }()
+	t.Cleanup(func() { cancel(); <-done })
+}
+
+// wait polls the log of hook until its one delivery is done.
+func wait(t *testing.T, ctx context.Context, s store.WebhookStore, hook store.Webhook) store.Delivery {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		log, err := s.Deliveries(ctx, hook.ID, 1)
+		if err != nil { t.Fatal(err) }
+		if len(log) == 1 && log[0].Status != store.DeliveryPending { return log[0] }
+	}
+	t.Fatalf("webhook %s never got its delivery", hook.URL)
+	return store.Delivery{}
+}
+
+func TestDispatcher(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		sent, _ := strconv.ParseInt(r.Header.Get("X-Webhook-Timestamp"), 10, 64)
+		if r.Header.Get("X-Webhook-Signature") != Sign("s3cret", time.Unix(sent, 0), body) { t.Errorf("bad signature %q", r.Header.Get("X-Webhook-Signature")) }
+		if r.Header.Get("X-Webhook-Event") != "created" || r.Header.Get("X-Webhook-Delivery") == "" { t.Errorf("headers %v", r.Header) }
+		if calls.Add(1) == 1 { w.WriteHeader(http.StatusServiceUnavailable); return }
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	s := store.NewMemoryWebhooks()
+	d := New(s, testConfig())
+	start(t, d)
+	ctx := tenant.NewContext(context.Background(), "team-a")
+	hook := store.Webhook{URL: srv.URL, Events: []string{"created"}, Secret: "s3cret"}
+	if err := s.CreateWebhook(ctx, &hook); err != nil { t.Fatal(err) }
+	if err := d.EnqueueDeliveries(ctx, []store.Delivery{{WebhookID: hook.ID, Event: "created", Payload: []byte(`{"event":"created"}`)}}); err != nil { t.Fatal(err) }
+
+	// The first attempt is turned away, the retry delivered.
+	got := wait(t, ctx, s, hook)
+	if got.Status != store.DeliveryDelivered || len(got.Attempts) != 2 { t.Fatalf("delivery: %+v", got) }
+	if a := got.Attempts[0]; a.StatusCode != 503 || a.Error != "answered 503 Service Unavailable" { t.Fatalf("first attempt: %+v", a) }
+	if a := got.Attempts[1]; a.StatusCode != 204 || a.Error != "" { t.Fatalf("second attempt: %+v", a) }
+}
+
+func TestDispatcherGivesUp(t *testing.T) {
+	srv := httptest.NewServer(http.RedirectHandler("https://example.com/", http.StatusFound))
+	defer srv.Close()
+
+	s := store.NewMemoryWebhooks()
+	start(t, New(s, testConfig()))
+	ctx := context.Background()
+	hook := store.Webhook{URL: srv.URL, Events: []string{"removed"}}
+	_ = s.CreateWebhook(ctx, &hook)
+	_ = s.EnqueueDeliveries(ctx, []store.Delivery{{WebhookID: hook.ID, Event: "removed", Payload: []byte(`{}`)}})
+
+	// Redirects aren't followed, so this one never gets through.
+	got := wait(t, ctx, s, hook)
+	if got.Status != store.DeliveryFailed || len(got.Attempts) != 3 || got.Attempts[2].StatusCode != 302 || got.FinishedAt == nil { t.Fatalf("delivery: %+v", got) }
+}
+
+func TestBackoff(t *testing.T) {
+	d := New(nil, Config{Backoff: 30 * time.Second, MaxBackoff: 5 * time.Minute})
+	for n, want := range map[int]time.Duration{1: 30 * time.Second, 2: time.Minute, 4: 4 * time.Minute, 5: 5 * time.Minute, 60: 5 * time.Minute} {
+		if got := d.backoff(n); got != want { t.Errorf("backoff(%d) = %v, want %v", n, got, want) }
+	}
+}
@@ -0,0 +1,193 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"slices"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"app/internal/requestid"
+	"app/internal/store"
+)
+
+// Events are what a webhook may subscribe to: the types of NameChange.
+var Events = []string{"created", "updated", "deleted", "restored", "removed"}
+
+// Payload is the body POSTed for an event. Name is the document after the
+// change, or before it for removed.
+//
+// ID is the outbox event's, with the outbox on (see package outbox): the
+// same on each delivery of the event, as the outbox may queue one twice.
+type Payload struct {
+	ID        string             `json:"id,omitempty"`
+	Event     string             `json:"event"`
+	NameID    primitive.ObjectID `json:"name_id"`
+	Name      *store.Name        `json:"name"`
+	At        time.Time          `json:"at"`
+	RequestID string             `json:"request_id,omitempty"`
+}
+
+// Names wraps a NameStore and queues a delivery to each subscribed webhook
+// of the tenant for every successful write to it. Reads pass straight
+// through.
+//
+// As with the audit log, deliveries are queued just after the write and a
+// failure to queue them is logged, not returned.
+type Names struct {
+	store.NameStore
+	hooks store.WebhookStore
+}
+
+func NewNames(s store.NameStore, hooks store.WebhookStore) *Names {
+	return &Names{NameStore: s, hooks: hooks}
+}
+
+// subscribed returns the tenant's webhooks that want event.
+func (w *Names) subscribed(ctx context.Context, event string) []store.Webhook {
+	hooks, err := w.hooks.Webhooks(ctx)
+	if err != nil { slog.ErrorContext(ctx, "reading webhooks", "err", err) }
+	return slices.DeleteFunc(hooks, func(h store.Webhook) bool { return !slices.Contains(h.Events, event) })
+}
+
+// lookup returns the current documents of ids, soft-deleted ones included,
+// for the payloads; a failure costs them their name.
+func (w *Names) lookup(ctx context.Context, ids ...primitive.ObjectID) map[primitive.ObjectID]store.Name {
+	docs, err := w.NameStore.Lookup(ctx, ids)
+	if err != nil { slog.ErrorContext(ctx, "reading names for webhooks", "err", err) }
+	return docs
+}
+
+// change is a name written to, with its document for the payload.
+type change struct {
+	id   primitive.ObjectID
+	name *store.Name
+}
+
+// notify queues a delivery of event about each of changes to each of
+// hooks, even if ctx has been canceled meanwhile, once the transaction it
+// may be part of has committed.
+func (w *Names) notify(ctx context.Context, hooks []store.Webhook, event string, changes ...change) {
+	if len(hooks) == 0 || len(changes) == 0 { return }
+	now := time.Now().UTC()
+	var ds []store.Delivery
+	for _, c := range changes {
+		payload, err := json.Marshal(Payload{Event: event, NameID: c.id, Name: c.name, At: now, RequestID: requestid.FromContext(ctx)})
+		if err != nil { slog.ErrorContext(ctx, "encoding a webhook payload", "err", err); continue }
+		for _, h := range hooks { ds = append(ds, store.Delivery{WebhookID: h.ID, Event: event, Payload: payload}) }
+	}
+	store.AfterCommit(ctx, func(ctx context.Context) {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+		defer cancel()
+		if err := w.hooks.EnqueueDeliveries(ctx, ds); err != nil {
+			slog.ErrorContext(ctx, "queueing webhook deliveries", "deliveries", len(ds), "err", err)
+		}
+	})
+}
+
+func (w *Names) Create(ctx context.Context, n *store.Name) error {
+	if err := w.NameStore.Create(ctx, n); err != nil { return err }
+	after := *n
+	w.notify(ctx, w.subscribed(ctx, "created"), "created", change{n.ID, &after})
+	return nil
+}
+
+func (w *Names) CreateIfAbsent(ctx context.Context, n *store.Name) (bool, error) {
+	created, err := w.NameStore.CreateIfAbsent(ctx, n)
+	if created { after := *n; w.notify(ctx, w.subscribed(ctx, "created"), "created", change{n.ID, &after}) }
+	return created, err
+}
+
+func (w *Names) Upsert(ctx context.Context, n store.Name, ifVersion int64) (*store.Name, store.Name, error) {
+	before, after, err := w.NameStore.Upsert(ctx, n, ifVersion)
+	if err != nil { return before, after, err }
+	event := "updated"
+	if before == nil { event = "created" }
+	w.notify(ctx, w.subscribed(ctx, event), event, change{after.ID, &after})
+	return before, after, nil
+}
+
+func (w *Names) Update(ctx context.Context, id primitive.ObjectID, n store.Name, ifVersion int64) (store.Name, error) {
+	after, err := w.NameStore.Update(ctx, id, n, ifVersion)
+	if err == nil { w.notify(ctx, w.subscribed(ctx, "updated"), "updated", change{id, &after}) }
+	return after, err
+}
+
+func (w *Names) Patch(ctx context.Context, id primitive.ObjectID, p store.NamePatch, ifVersion int64) (store.Name, error) {
+	after, err := w.NameStore.Patch(ctx, id, p, ifVersion)
+	if err == nil { w.notify(ctx, w.subscribed(ctx, "updated"), "updated", change{id, &after}) }
+	return after, err
+}
+
+func (w *Names) SoftDelete(ctx context.Context, id primitive.ObjectID, ifVersion int64) error {
+	if err := w.NameStore.SoftDelete(ctx, id, ifVersion); err != nil { return err }
+	if hooks := w.subscribed(ctx, "deleted"); len(hooks) > 0 { w.notify(ctx, hooks, "deleted", found(w.lookup(ctx, id), id)) }
+	return nil
+}
+
+// HardDelete reads the name first only if someone wants to hear of it.
+func (w *Names) HardDelete(ctx context.Context, id primitive.ObjectID, ifVersion int64) error {
+	hooks := w.subscribed(ctx, "removed")
+	var before map[primitive.ObjectID]store.Name
+	if len(hooks) > 0 { before = w.lookup(ctx, id) }
+	if err := w.NameStore.HardDelete(ctx, id, ifVersion); err != nil { return err }
+	w.notify(ctx, hooks, "removed", found(before, id))
+	return nil
+}
+
+func (w *Names) Restore(ctx context.Context, id primitive.ObjectID) (store.Name, error) {
+	after, err := w.NameStore.Restore(ctx, id)
+	if err == nil { w.notify(ctx, w.subscribed(ctx, "restored"), "restored", change{id, &after}) }
+	return after, err
+}
+
+func (w *Names) CreateMany(ctx context.Context, ns []store.Name) ([]error, error) {
+	errs, err := w.NameStore.CreateMany(ctx, ns)
+	if err == nil { w.notifyCreated(ctx, ns, errs) }
+	return errs, err
+}
+
+func (w *Names) InsertMany(ctx context.Context, ns []store.Name) ([]error, error) {
+	errs, err := w.NameStore.InsertMany(ctx, ns)
+	if err == nil { w.notifyCreated(ctx, ns, errs) }
+	return errs, err
+}
+
+// notifyCreated tells of the items of a batch insert that were stored.
+func (w *Names) notifyCreated(ctx context.Context, ns []store.Name, errs []error) {
+	hooks := w.subscribed(ctx, "created")
+	if len(hooks) == 0 { return }
+	var created []change
+	for i := range ns {
+		if i < len(errs) && errs[i] == nil { n := ns[i]; created = append(created, change{n.ID, &n}) }
+	}
+	w.notify(ctx, hooks, "created", created...)
+}
+
+func (w *Names) DeleteMany(ctx context.Context, ids []primitive.ObjectID, hard bool) (map[primitive.ObjectID]bool, error) {
+	event := "deleted"
+	if hard { event = "removed" }
+	hooks := w.subscribed(ctx, event)
+	var before map[primitive.ObjectID]store.Name
+	if hard && len(hooks) > 0 { before = w.lookup(ctx, ids...) }
+	existed, err := w.NameStore.DeleteMany(ctx, ids, hard)
+	if err != nil || len(hooks) == 0 { return existed, err }
+
+	var deleted []primitive.ObjectID
+	for _, id := range ids {
+		if existed[id] && !slices.Contains(deleted, id) { deleted = append(deleted, id) }
+	}
+	if !hard { before = w.lookup(ctx, deleted...) }
+	changes := make([]change, 0, len(deleted))
+	for _, id := range deleted { changes = append(changes, found(before, id)) }
+	w.notify(ctx, hooks, event, changes...)
+	return existed, nil
+}
+
+// found returns the change to id with its document in docs, if any.
+func found(docs map[primitive.ObjectID]store.Name, id primitive.ObjectID) change {
+	if n, ok := docs[id]; ok { return change{id, &n} }
+	return change{id: id}
+}
@@ -0,0 +1,40 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"app/internal/store"
+	"app/internal/tenant"
+)
+
+func TestNames(t *testing.T) {
+	ctx := tenant.NewContext(context.Background(), "team-a")
+	hooks := store.NewMemoryWebhooks()
+	names := NewNames(store.NewMemoryNames(), hooks)
+	all, removals := store.Webhook{URL: "https://a.example", Events: Events}, store.Webhook{URL: "https://b.example", Events: []string{"removed"}}
+	_ = hooks.CreateWebhook(ctx, &all)
+	_ = hooks.CreateWebhook(ctx, &removals)
+
+	n := store.Name{Name: "alice"}
+	if err := names.Create(ctx, &n); err != nil { t.Fatal(err) }
+	if _, err := names.Patch(ctx, n.ID, store.NamePatch{Tags: &[]string{"vip"}}, 2); err == nil { t.Fatal("stale patch went through") }
+	if err := names.HardDelete(ctx, n.ID, store.AnyVersion); err != nil { t.Fatal(err) }
+	// Another tenant's writes go to its own webhooks, of which it has none.
+	if err := names.Create(tenant.NewContext(context.Background(), "team-b"), &store.Name{Name: "bob"}); err != nil { t.Fatal(err) }
+
+	events := func(h store.Webhook) (out []Payload) {
+		t.Helper()
+		log, err := hooks.Deliveries(ctx, h.ID, 10)
+		if err != nil { t.Fatal(err) }
+		for _, d := range log {
+			var p Payload
+			if err := json.Unmarshal(d.Payload, &p); err != nil || p.Event != d.Event { t.Fatalf("payload %s: %v", d.Payload, err) }
+			out = append(out, p)
+		}
+		return out
+	}
+	if got := events(all); len(got) != 2 || got[1].Event != "created" || got[0].Event != "removed" || got[0].Name == nil || got[0].Name.Name != "alice" { t.Fatalf("all events: %+v", got) }
+	if got := events(removals); len(got) != 1 || got[0].NameID != n.ID { t.Fatalf("removals: %+v", got) }
+}
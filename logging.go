@@ -0,0 +1,28 @@
+package main
+
+import (
+	"log/slog"
+	"os"
+
+	"app/internal/requestid"
+)
+
+// setupLogging installs a JSON slog logger as the process default. Every
+// record logged with a request context carries that request's ID. level was
+// validated with the rest of the configuration.
+func setupLogging(level string) {
+	var l slog.Level
+	_ = l.UnmarshalText([]byte(level))
+	h := slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: l})
+	slog.SetDefault(slog.New(requestid.LogHandler{Handler: h}))
+}
+
+func must(err error) {
+	if err != nil { fatal(err.Error()) }
+}
+
+// fatal logs at error level and exits; the slog counterpart of log.Fatal.
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
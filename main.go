@@ -2,200 +2,261 @@ package main
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
-	"log"
-	"net/http"
+	"flag"
+	"fmt"
+	"log/slog"
 	"os"
-	"strings"
+	"os/signal"
+	"syscall"
 	"time"
 
-	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
-)
-
-type Name struct {
-	ID   primitive.ObjectID `json:"id,omitempty" bson:"_id,omitempty"`
-	Name string             `json:"name" bson:"name"`
-}
-
-var (
-	client     *mongo.Client
-	collection *mongo.Collection
+	"app/internal/auth"
+	"app/internal/backup"
+	"app/internal/chaos"
+	"app/internal/cleanup"
+	"app/internal/config"
+	"app/internal/debugserver"
+	"app/internal/grpcapi"
+	"app/internal/handlers"
+	"app/internal/jobs"
+	"app/internal/leader"
+	"app/internal/server"
+	"app/internal/tracing"
 )
 
 func main() {
-	// ---- Mongo init ----
-	mongoURI := getenv("MONGO_URI", "mongodb://localhost:27017")
-	dbName := getenv("DB_NAME", "testdb")
-	colName := getenv("COLLECTION", "names")
+	cfg, err := config.Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) { return }
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "invalid configuration:\n"+err.Error())
+		os.Exit(2)
+	}
+	if cfg.PrintConfig { must(cfg.Print(os.Stdout)); return }
+	setupLogging(cfg.LogLevel)
+	ctx := context.Background()
 
-	var err error
-	client, err = mongo.Connect(context.Background(), options.Client().ApplyURI(mongoURI))
+	// ---- Tracing ----
+	shutdownTracing, err := tracing.Setup(ctx)
 	must(err)
-	must(client.Ping(context.Background(), nil))
-
-	collection = client.Database(dbName).Collection(colName)
-	log.Printf("Connected to MongoDB %s, DB=%s, Collection=%s", mongoURI, dbName, colName)
-
-	// ---- HTTP routes ----
-	http.HandleFunc("/health", healthHandler)
-	http.HandleFunc("/names", namesHandler)     // POST /names, GET /names
-	http.HandleFunc("/names/", nameByIDHandler) // GET/PUT/DELETE /names/{id}
-
-	addr := getenv("ADDR", ":8080")
-	log.Printf("Serving on %s", addr)
-	must(http.ListenAndServe(addr, corsMiddleware(http.DefaultServeMux)))
-}
-
-// ========== Handlers ==========
-
-func healthHandler(w http.ResponseWriter, r *http.Request) {
-	ok(w, map[string]string{"status": "ok"})
-}
-
-// POST /names  { "name": "Alice" }
-// GET  /names  -> list
-func namesHandler(w http.ResponseWriter, r *http.Request) {
-	switch r.Method {
-	case http.MethodPost:
-		var payload Name
-		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
-			badRequest(w, "invalid JSON: "+err.Error()); return
-		}
-		payload.Name = strings.TrimSpace(payload.Name)
-		if payload.Name == "" {
-			badRequest(w, "`name` is required"); return
-		}
-
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-		defer cancel()
-		res, err := collection.InsertOne(ctx, bson.M{"name": payload.Name})
-		if err != nil {
-			internal(w, err); return
-		}
-		id := res.InsertedID.(primitive.ObjectID)
-		created(w, Name{ID: id, Name: payload.Name})
+	if tracing.Enabled() { slog.Info("exporting traces over OTLP") }
 
-	case http.MethodGet:
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-		defer cancel()
-		cur, err := collection.Find(ctx, bson.D{})
-		if err != nil {
-			internal(w, err); return
-		}
-		defer cur.Close(ctx)
-
-		var out []Name
-		for cur.Next(ctx) {
-			var n Name
-			if err := cur.Decode(&n); err != nil { internal(w, err); return }
-			out = append(out, n)
-		}
-		if err := cur.Err(); err != nil {
-			internal(w, err); return
-		}
-		ok(w, out)
+	// ---- Storage ----
+	be, err := openBackend(ctx, cfg)
+	must(err)
+	if cfg.Migrate { must(be.close(ctx)); slog.Info("the store is migrated"); return }
+	be.useRetry(cfg)
+	must(be.useShadow(ctx, cfg))
+	be.useChaos(cfg)
+	be.useAudit()
+	must(be.useCache(ctx, cfg)) // after the audit log, which reads around the cache
+	be.useRevisions()
+	be.useNotes(cfg)
+	be.useOwners()
+	hooks := be.useWebhooks(cfg)
+	must(be.useBus(ctx, cfg))
+	box := be.useOutbox(cfg)
+	changeLog := be.useChanges(cfg)
+	be.useIDs(cfg)
+	normalizer := be.useNormalize(cfg)
+	must(be.usePrewrite(cfg))
+	must(be.useCaptures(ctx, cfg))
+	tracker := be.useUsage(cfg)
 
-	default:
-		methodNotAllowed(w, http.MethodGet, http.MethodPost)
+	var backups backup.Target
+	switch {
+	case cfg.Backup.Dir != "":
+		backups = backup.Dir(cfg.Backup.Dir)
+	case cfg.Backup.S3URL != "":
+		backups, err = backup.NewS3(cfg.Backup.S3URL, cfg.Backup.S3Region, cfg.Backup.S3AccessKey, cfg.Backup.S3SecretKey)
+		must(err)
 	}
-}
-
-// GET /names/{id}
-// PUT /names/{id}  { "name": "Bob" }
-// DELETE /names/{id}
-func nameByIDHandler(w http.ResponseWriter, r *http.Request) {
-	idStr, err := extractID(r.URL.Path, "/names/")
-	if err != nil { notFound(w); return }
-
-	oid, err := primitive.ObjectIDFromHex(idStr)
-	if err != nil { badRequest(w, "invalid id"); return }
-
-	switch r.Method {
-	case http.MethodGet:
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-		defer cancel()
-		var n Name
-		err := collection.FindOne(ctx, bson.M{"_id": oid}).Decode(&n)
-		if errors.Is(err, mongo.ErrNoDocuments) { notFound(w); return }
-		if err != nil { internal(w, err); return }
-		ok(w, n)
-
-	case http.MethodPut:
-		var payload struct{ Name string `json:"name"` }
-		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
-			badRequest(w, "invalid JSON: "+err.Error()); return
-		}
-		payload.Name = strings.TrimSpace(payload.Name)
-		if payload.Name == "" {
-			badRequest(w, "`name` is required"); return
-		}
-
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-		defer cancel()
-		res, err := collection.UpdateByID(ctx, oid, bson.M{"$set": bson.M{"name": payload.Name}})
-		if err != nil { internal(w, err); return }
-		if res.MatchedCount == 0 { notFound(w); return }
-		ok(w, Name{ID: oid, Name: payload.Name})
-
-	case http.MethodDelete:
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-		defer cancel()
-		res, err := collection.DeleteOne(ctx, bson.M{"_id": oid})
-		if err != nil { internal(w, err); return }
-		if res.DeletedCount == 0 { notFound(w); return }
-		noContent(w)
-
-	default:
-		methodNotAllowed(w, http.MethodGet, http.MethodPut, http.MethodDelete)
+	var elector *leader.Elector
+	if cfg.Leader.Enabled {
+		elector = leader.New(be.leases, leader.Config{ID: cfg.Leader.ID, TTL: cfg.Leader.TTL, Renew: cfg.Leader.Renew})
 	}
-}
 
-// ========== Helpers ==========
+	// ---- Auth ----
+	tokens := auth.NewTokens([]byte(cfg.Auth.JWTSecret), cfg.Auth.JWTTTL)
+	if !tokens.Enabled() {
+		slog.Warn("JWT_SECRET is not set, authentication is disabled and /names is open to everyone")
+	}
 
-func extractID(path, prefix string) (string, error) {
-	if !strings.HasPrefix(path, prefix) { return "", errors.New("bad path") }
-	rest := strings.TrimPrefix(path, prefix)
-	parts := strings.Split(strings.Trim(rest, "/"), "/")
-	if len(parts) < 1 || parts[0] == "" { return "", errors.New("no id") }
-	return parts[0], nil
-}
+	// ---- Background jobs ----
+	pool := jobs.New(be.jobs, jobs.Config{
+		Workers:     cfg.Jobs.Workers,
+		Lease:       cfg.Jobs.Lease,
+		Poll:        cfg.Jobs.Poll,
+		Retention:   cfg.Jobs.Retention,
+		MaxAttempts: cfg.Jobs.MaxAttempts,
+	})
 
-func getenv(k, def string) string {
-	if v := os.Getenv(k); v != "" { return v }
-	return def
-}
+	// ---- HTTP server ----
+	h := handlers.New(handlers.Deps{
+		Names: be.names, Tx: be.tx, Users: be.users, APIKeys: be.keys, Audit: be.audit, History: be.hist, Stats: be.stats, Dups: be.dups, Sample: be.sample, NameKeys: be.byKey, Notes: be.notes, Revisions: be.revs, Docs: be.docs, Resources: be.res, Tokens: tokens, Pool: be.pool, Checks: be.checks,
+		Jobs:            pool,
+		Webhooks:        hooks,
+		Captures:        be.caps,
+		Usage:           tracker,
+		Shadow:          be.shadow,
+		Changes:         changeLog,
+		Backups:         backups,
+		Normalize:       normalizer,
+		Leader:          elector,
+		AllowHardDelete: cfg.AllowHardDelete,
+		AllowSeed:       !cfg.Production(),
+		ImportMaxBytes:  cfg.ImportMaxBytes,
+		ListConsistency: cfg.Mongo.ListConsistency,
+	})
+	sunset, _ := time.Parse(time.DateOnly, cfg.LegacySunset) // validated; zero if unset
+	routeLimits, _ := config.RouteLimits(cfg.Concurrency.Routes) // validated
+	srv := server.New(server.Config{
+		Addr:           cfg.Addr,
+		ShutdownGrace:  cfg.ShutdownGrace,
+		DrainGrace:     cfg.DrainGrace,
+		IdempotencyTTL: cfg.IdempotencyTTL,
+		MaxBodyBytes:   cfg.MaxBodyBytes,
+		RequestTimeout: cfg.RequestTimeout,
+		LegacySunset:   sunset,
+		Envelope:       cfg.Envelope,
+		HTTP: server.HTTPConfig{
+			ReadHeaderTimeout: cfg.HTTP.ReadHeaderTimeout,
+			ReadTimeout:       cfg.HTTP.ReadTimeout,
+			WriteTimeout:      cfg.HTTP.WriteTimeout,
+			IdleTimeout:       cfg.HTTP.IdleTimeout,
+			MaxHeaderBytes:    cfg.HTTP.MaxHeaderBytes,
+			HSTSMaxAge:        cfg.HTTP.HSTSMaxAge,
+		},
+		RateLimit: server.RateLimitConfig{
+			RPS:          cfg.RateLimit.RPS,
+			Burst:        cfg.RateLimit.Burst,
+			MaxClients:   cfg.RateLimit.MaxClients,
+			TrustProxy:   cfg.RateLimit.TrustProxy,
+			APIKeyHeader: cfg.RateLimit.APIKeyHeader,
+		},
+		TLS: server.TLSConfig{
+			CertFile:         cfg.TLS.CertFile,
+			KeyFile:          cfg.TLS.KeyFile,
+			AutocertDomains:  config.Split(cfg.TLS.AutocertDomains),
+			AutocertCacheDir: cfg.TLS.AutocertCacheDir,
+			AutocertEmail:    cfg.TLS.AutocertEmail,
+			RedirectAddr:     cfg.TLS.RedirectAddr,
+		},
+		CORS: server.CORSConfig{
+			AllowedOrigins:   config.Split(cfg.CORS.AllowedOrigins),
+			AllowedMethods:   config.Split(cfg.CORS.AllowedMethods),
+			AllowedHeaders:   config.Split(cfg.CORS.AllowedHeaders),
+			AllowCredentials: cfg.CORS.AllowCredentials,
+			MaxAge:           cfg.CORS.MaxAge,
+		},
+		Compression: server.CompressionConfig{
+			MinBytes: cfg.Compression.MinBytes,
+			Level:    cfg.Compression.Level,
+			Zstd:     cfg.Compression.Zstd,
+		},
+		Concurrency: server.ConcurrencyConfig{
+			MaxInFlight:      cfg.Concurrency.MaxInFlight,
+			RouteMaxInFlight: cfg.Concurrency.RouteMaxInFlight,
+			Routes:           routeLimits,
+			QueueTimeout:     cfg.Concurrency.QueueTimeout,
+		},
+		Capture: server.CaptureConfig{
+			Percent:      cfg.Capture.Percent,
+			MaxBodyBytes: cfg.Capture.MaxBodyBytes,
+			Sink:         be.caps,
+		},
+		Chaos: server.ChaosConfig{
+			Faults: chaos.Faults{
+				Latency:     cfg.Chaos.Latency,
+				LatencyRate: cfg.Chaos.LatencyRate,
+				ErrorRate:   cfg.Chaos.ErrorRate,
+				TimeoutRate: cfg.Chaos.TimeoutRate,
+			},
+			Header: !cfg.Production(),
+		},
+		Usage: tracker,
+	}, h, tokens, be.idem, be.keys)
 
-func must(err error) {
-	if err != nil { log.Fatal(err) }
-}
+	sigCtx, stop := signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	context.AfterFunc(sigCtx, stop) // a second signal kills the process immediately
 
-func corsMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
-		w.Header().Set("Access-Control-Allow-Methods", "GET,POST,PUT,DELETE,OPTIONS")
-		if r.Method == http.MethodOptions { w.WriteHeader(http.StatusNoContent); return }
-		next.ServeHTTP(w, r)
-	})
-}
+	// ---- gRPC and debug servers ----
+	// The servers stop together: on a signal, or as soon as any one fails.
+	// The job workers, the webhook deliveries, the outbox, the usage counts,
+	// the cleanup and the election stop with them, and are waited for before
+	// the store they work on is closed. Under LEADER_ELECTION the webhook
+	// deliveries, the outbox and the cleanup run in the leader alone.
+	runCtx, cancelRun := context.WithCancel(sigCtx)
+	defer cancelRun()
+	singleton := func(name string, run func(context.Context) error, done chan<- error) {
+		if elector != nil { elector.Lead(name, run); done <- nil; return }
+		go func() { done <- run(runCtx) }()
+	}
+	jobsDone := make(chan error, 1)
+	go func() { jobsDone <- pool.Run(runCtx) }()
+	hooksDone := make(chan error, 1)
+	singleton("webhooks", hooks.Run, hooksDone)
+	outboxDone := make(chan error, 1)
+	if box != nil {
+		singleton("outbox", box.Run, outboxDone)
+	} else {
+		outboxDone <- nil
+	}
+	// The usage counts outlast the HTTP server, so as to store those of
+	// the requests it finishes while shutting down.
+	usageCtx, stopUsage := context.WithCancel(ctx)
+	defer stopUsage()
+	usageDone := make(chan error, 1)
+	if tracker != nil {
+		go func() { usageDone <- tracker.Run(usageCtx) }()
+	} else {
+		usageDone <- nil
+	}
+	cleanupDone := make(chan error, 1)
+	if cfg.Cleanup.Schedule != "off" {
+		schedule, _ := cleanup.ParseSchedule(cfg.Cleanup.Schedule) // validated
+		c := cleanup.New(be.names, be.due, cleanup.Config{Schedule: schedule, DeletedRetention: cfg.Cleanup.DeletedRetention, BatchSize: cfg.Cleanup.BatchSize})
+		singleton("cleanup", c.Run, cleanupDone)
+	} else {
+		cleanupDone <- nil
+	}
+	leaderDone := make(chan error, 1)
+	if elector != nil {
+		go func() { leaderDone <- elector.Run(runCtx) }()
+	} else {
+		leaderDone <- nil
+	}
+	grpcDone := make(chan error, 1)
+	if cfg.GRPCAddr != "" {
+		gs := grpcapi.New(grpcapi.Config{
+			Addr:            cfg.GRPCAddr,
+			ShutdownGrace:   cfg.ShutdownGrace,
+			AllowHardDelete: cfg.AllowHardDelete,
+		}, be.names, tokens)
+		go func() { grpcDone <- gs.Run(runCtx); cancelRun() }()
+	} else {
+		grpcDone <- nil
+	}
+	adminDone := make(chan error, 1)
+	if cfg.AdminAddr != "" {
+		ds := debugserver.New(debugserver.Config{Addr: cfg.AdminAddr, ShutdownGrace: cfg.ShutdownGrace})
+		go func() { adminDone <- ds.Run(runCtx); cancelRun() }()
+	} else {
+		adminDone <- nil
+	}
+	httpErr := srv.Run(runCtx)
+	cancelRun()
+	stopUsage()
+	if err := errors.Join(httpErr, <-grpcDone, <-adminDone, <-jobsDone, <-hooksDone, <-outboxDone, <-usageDone, <-cleanupDone, <-leaderDone); err != nil { fatal("server failed", "err", err) }
 
-// ---- response helpers ----
-func jsonWrite(w http.ResponseWriter, status int, v any) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(status)
-	_ = json.NewEncoder(w).Encode(v)
-}
-func ok(w http.ResponseWriter, v any)          { jsonWrite(w, http.StatusOK, v) }
-func created(w http.ResponseWriter, v any)     { jsonWrite(w, http.StatusCreated, v) }
-func badRequest(w http.ResponseWriter, msg any){ jsonWrite(w, http.StatusBadRequest, map[string]any{"error": msg}) }
-func notFound(w http.ResponseWriter)           { jsonWrite(w, http.StatusNotFound, map[string]string{"error":"not found"}) }
-func internal(w http.ResponseWriter, err error){ jsonWrite(w, http.StatusInternalServerError, map[string]any{"error": err.Error()}) }
-func noContent(w http.ResponseWriter)          { w.WriteHeader(http.StatusNoContent) }
-func methodNotAllowed(w http.ResponseWriter, allowed ...string) {
-	w.Header().Set("Allow", strings.Join(allowed, ", "))
-	jsonWrite(w, http.StatusMethodNotAllowed, map[string]any{"error":"method not allowed","allow":allowed})
+	disconnectCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if err := be.close(disconnectCtx); err != nil {
+		slog.Error("closing the store", "err", err)
+	}
+	if err := shutdownTracing(disconnectCtx); err != nil {
+		slog.Error("flushing traces", "err", err)
+	}
+	slog.Info("shutdown complete")
 }
@@ -0,0 +1,170 @@
+// The admin UI: everything goes through /api/v1 with the bearer token from
+// signing in, kept for the browser tab's lifetime only.
+"use strict";
+
+const api = "/api/v1";
+const $ = (id) => document.getElementById(id);
+let token = sessionStorage.getItem("token");
+let next = ""; // cursor of the next page of the list, if any
+
+// call sends a request to the API and returns its JSON body, or throws an
+// Error with the problem's message. A 401 drops the token and asks to sign in.
+async function call(method, path, body, headers = {}) {
+  headers.Accept = "application/json; envelope=false"; // bodies as they are, whatever RESPONSE_ENVELOPE says
+  if (token) headers.Authorization = "Bearer " + token;
+  if (body !== undefined) headers["Content-Type"] = "application/json";
+  const resp = await fetch(api + path, { method, headers, body: body === undefined ? undefined : JSON.stringify(body) });
+  if (resp.status === 204) return null;
+  const data = await resp.json().catch(() => ({}));
+  if (resp.status === 401 && path !== "/auth/login") { signOut(); throw new Error("Sign in to continue."); }
+  if (!resp.ok) {
+    const fields = (data.fields || []).map((f) => `${f.field} ${f.message}`).join("; ");
+    throw new Error(fields || data.detail || data.error || resp.statusText);
+  }
+  return data;
+}
+
+function say(text, isError) {
+  const m = $("message");
+  m.textContent = text;
+  m.className = isError ? "error" : "";
+  m.hidden = !text;
+}
+
+function show(signedIn) {
+  $("login").hidden = signedIn;
+  $("app").hidden = !signedIn;
+  $("logout").hidden = !token;
+}
+
+function signOut() {
+  token = null;
+  sessionStorage.removeItem("token");
+  $("who").textContent = "";
+  show(false);
+}
+
+// load replaces the table with the first page of names matching the search
+// form, or appends the next page when more is true.
+async function load(more) {
+  const form = $("search").elements;
+  const q = form.q.value.trim();
+  let items;
+  try {
+    if (q) {
+      // Searches are one page of the best matches.
+      items = (await call("GET", "/names/search?mode=prefix&limit=100&q=" + encodeURIComponent(q))).items;
+      next = "";
+    } else {
+      let path = "/names?limit=50&sort=" + encodeURIComponent(form.sort.value);
+      if (more && next) path += "&after=" + encodeURIComponent(next);
+      const page = await call("GET", path);
+      items = page.items;
+      next = page.next || "";
+    }
+  } catch (err) {
+    say(err.message, true);
+    return;
+  }
+  show(true);
+  const rows = $("rows");
+  if (!more) rows.replaceChildren();
+  for (const n of items) rows.append(row(n));
+  $("empty").hidden = rows.children.length > 0;
+  $("more").hidden = !next;
+}
+
+function row(n) {
+  const tr = document.createElement("tr");
+  const cell = (text) => { const td = document.createElement("td"); td.textContent = text; tr.append(td); return td; };
+  cell(n.name);
+  const tags = cell("");
+  for (const t of n.tags || []) {
+    const span = document.createElement("span");
+    span.className = "tag";
+    span.textContent = t;
+    tags.append(span);
+  }
+  cell(new Date(n.updated_at).toLocaleString());
+  cell(String(n.version));
+  const actions = cell("");
+  const button = (label, fn) => { const b = document.createElement("button"); b.type = "button"; b.textContent = label; b.onclick = fn; actions.append(b); };
+  button("Edit", () => edit(n));
+  button("Delete", () => remove(n));
+  return tr;
+}
+
+function edit(n) {
+  const f = $("editor").elements;
+  f.id.value = n ? n.id : "";
+  f.version.value = n ? n.version : "";
+  f.expires.value = n && n.expires_at ? n.expires_at : "";
+  f.name.value = n ? n.name : "";
+  f.tags.value = n && n.tags ? n.tags.join(", ") : "";
+  f.metadata.value = n && n.metadata ? JSON.stringify(n.metadata, null, 2) : "";
+  $("editor-title").textContent = n ? "Edit " + n.name : "New name";
+  $("cancel").hidden = !n;
+  if (n) f.name.focus();
+}
+
+async function remove(n) {
+  if (!confirm(`Delete ${n.name}? It goes to the trash and can be restored.`)) return;
+  try {
+    await call("DELETE", "/names/" + n.id, undefined, { "If-Match": `"${n.version}"` });
+    say(`Deleted ${n.name}.`);
+    load(false);
+  } catch (err) {
+    say(err.message, true);
+  }
+}
+
+$("login").onsubmit = async (e) => {
+  e.preventDefault();
+  const f = e.target.elements;
+  try {
+    const res = await call("POST", "/auth/login", { username: f.username.value, password: f.password.value });
+    token = res.token;
+    sessionStorage.setItem("token", token);
+    $("who").textContent = `${f.username.value} (${res.role})`;
+    f.password.value = "";
+    say("");
+    load(false);
+  } catch (err) {
+    say(err.message, true);
+  }
+};
+
+$("logout").onclick = () => { signOut(); say("Signed out."); };
+$("search").onsubmit = (e) => { e.preventDefault(); load(false); };
+$("search").elements.sort.onchange = () => load(false);
+$("more").onclick = () => load(true);
+$("cancel").onclick = () => edit(null);
+
+$("editor").onsubmit = async (e) => {
+  e.preventDefault();
+  const f = e.target.elements;
+  const body = { name: f.name.value.trim() };
+  if (f.expires.value) body.expires_at = f.expires.value; // PUT would clear it otherwise
+  const tags = f.tags.value.split(",").map((t) => t.trim()).filter(Boolean);
+  if (tags.length) body.tags = tags;
+  if (f.metadata.value.trim()) {
+    try { body.metadata = JSON.parse(f.metadata.value); } catch { say("Metadata must be a JSON object.", true); return; }
+  }
+  try {
+    if (f.id.value) {
+      await call("PUT", "/names/" + f.id.value, body, { "If-Match": `"${f.version.value}"` });
+      say(`Saved ${body.name}.`);
+    } else {
+      await call("POST", "/names", body);
+      say(`Created ${body.name}.`);
+    }
+    edit(null);
+    load(false);
+  } catch (err) {
+    say(err.message, true);
+  }
+};
+
+// Without authentication configured the API answers straight away;
+// otherwise the first call's 401 brings up the sign-in form.
+load(false);
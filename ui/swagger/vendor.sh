@@ -0,0 +1,9 @@
+#!/bin/sh
+# Vendors the Swagger UI that GET /api/v1/docs serves into this directory,
+# from the npm registry: its stylesheet, its bundle and their licence. The
+# version is pinned; bump it here and re-run `go generate ./ui` to upgrade.
+set -eu
+version=5.17.14
+cd "$(dirname "$0")"
+curl -fsSL "https://registry.npmjs.org/swagger-ui-dist/-/swagger-ui-dist-$version.tgz" |
+	tar -xzf - --strip-components=1 package/swagger-ui.css package/swagger-ui-bundle.js package/LICENSE
@@ -0,0 +1,19 @@
+// Package ui holds the admin UI: a single page that lists, searches,
+// creates, edits and deletes names through the HTTP API, for people who'd
+// rather not use curl. It is plain HTML, CSS and JavaScript, with no build
+// step, embedded into the binary.
+package ui
+
+import "embed"
+
+// Files are the UI's assets, with index.html at the root.
+//
+//go:embed index.html app.js style.css
+var Files embed.FS
+
+// Swagger holds the Swagger UI dist files the API docs page loads, vendored
+// by swagger/vendor.sh rather than pulled from a CDN by every browser.
+//
+//go:generate sh swagger/vendor.sh
+//go:embed swagger
+var Swagger embed.FS